@@ -18,6 +18,7 @@ import (
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/storage"
+	lru "github.com/hashicorp/golang-lru"
 	"golang.org/x/crypto/scrypt"
 	"golang.org/x/crypto/sha3"
 )
@@ -34,6 +35,23 @@ var (
 
 const EmptyCredentials = ""
 
+// sharedSecretCacheSize is the number of ECDH shared secrets kept in sharedSecrets
+const sharedSecretCacheSize = 1024
+
+// sharedSecrets caches the ECDH shared secrets derived by NewSessionKeyPK,
+// keyed by the public keys of the local and the remote party, so that the scalar
+// multiplication is not repeated for every entry of the same publisher
+// Entries of a local key are removed with ForgetSharedSecrets
+var sharedSecrets *lru.Cache
+
+func init() {
+	var err error
+	sharedSecrets, err = lru.New(sharedSecretCacheSize)
+	if err != nil {
+		panic(err)
+	}
+}
+
 type AccessEntry struct {
 	Type      AccessType
 	Publisher string
@@ -100,6 +118,7 @@ type AccessType string
 const AccessTypePass = AccessType("pass")
 const AccessTypePK = AccessType("pk")
 const AccessTypeACT = AccessType("act")
+const AccessTypeMaster = AccessType("master")
 
 // NewAccessEntryPassword creates a manifest AccessEntry in order to create an ACT protected by a password
 func NewAccessEntryPassword(salt []byte, kdfParams *KdfParams) (*AccessEntry, error) {
@@ -187,6 +206,21 @@ func sessionKeyPassword(password string, salt []byte, kdfParams *KdfParams) ([]b
 
 // NewSessionKeyPK creates a new ACT Session Key using an ECDH shared secret for the given key pair and the given salt value
 func NewSessionKeyPK(private *ecdsa.PrivateKey, public *ecdsa.PublicKey, salt []byte) ([]byte, error) {
+	bytes, err := sharedSecret(private, public)
+	if err != nil {
+		return nil, err
+	}
+	sessionKey := crypto.Keccak256(salt, bytes)
+	return sessionKey, nil
+}
+
+// sharedSecret returns the ECDH shared secret of the key pair, deriving it
+// only if it is not found in the cache
+func sharedSecret(private *ecdsa.PrivateKey, public *ecdsa.PublicKey) ([]byte, error) {
+	key := sharedSecretKey(&private.PublicKey, public)
+	if v, ok := sharedSecrets.Get(key); ok {
+		return v.([]byte), nil
+	}
 	granteePubEcies := ecies.ImportECDSAPublic(public)
 	privateKey := ecies.ImportECDSA(private)
 
@@ -194,9 +228,28 @@ func NewSessionKeyPK(private *ecdsa.PrivateKey, public *ecdsa.PublicKey, salt []
 	if err != nil {
 		return nil, err
 	}
-	bytes = append(salt, bytes...)
-	sessionKey := crypto.Keccak256(bytes)
-	return sessionKey, nil
+	sharedSecrets.Add(key, bytes)
+	return bytes, nil
+}
+
+// sharedSecretKey returns the cache key of the shared secret of two parties
+// the public key of the local party leads so its entries can be found by prefix
+func sharedSecretKey(local, remote *ecdsa.PublicKey) string {
+	return string(crypto.CompressPubkey(local)) + string(crypto.CompressPubkey(remote))
+}
+
+// ForgetSharedSecrets removes the cached shared secrets derived with the given
+// private key, it should be called when the key is replaced or no longer used
+func ForgetSharedSecrets(private *ecdsa.PrivateKey) {
+	if private == nil {
+		return
+	}
+	prefix := string(crypto.CompressPubkey(&private.PublicKey))
+	for _, k := range sharedSecrets.Keys() {
+		if strings.HasPrefix(k.(string), prefix) {
+			sharedSecrets.Remove(k)
+		}
+	}
 }
 
 func (a *API) doDecrypt(ctx context.Context, credentials string, pk *ecdsa.PrivateKey) DecryptFunc {
@@ -329,6 +382,12 @@ func (a *API) doDecrypt(ctx context.Context, credentials string, pk *ecdsa.Priva
 			m.Hash = hex.EncodeToString(decodedMainRef)
 			m.Access = nil
 			return nil
+		case "master":
+			masterKey, err := ParseMasterKey(credentials)
+			if err != nil {
+				return ErrDecrypt
+			}
+			return decryptMasterEntry(m, masterKey)
 		}
 		return ErrUnknownAccessType
 	}
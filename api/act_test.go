@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestSessionKeyPKCache tests that both parties derive the same session key
+// with cached shared secrets and that the secrets of a key can be forgotten
+func TestSessionKeyPKCache(t *testing.T) {
+	publisher, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	grantee, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("salt")
+
+	key, err := NewSessionKeyPK(publisher, &grantee.PublicKey, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sharedSecrets.Get(sharedSecretKey(&publisher.PublicKey, &grantee.PublicKey)); !ok {
+		t.Fatal("expected shared secret to be cached")
+	}
+	cached, err := NewSessionKeyPK(publisher, &grantee.PublicKey, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, cached) {
+		t.Fatalf("expected session key %x from cache, got %x", key, cached)
+	}
+	granteeKey, err := NewSessionKeyPK(grantee, &publisher.PublicKey, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, granteeKey) {
+		t.Fatalf("expected grantee session key %x, got %x", key, granteeKey)
+	}
+
+	ForgetSharedSecrets(publisher)
+	if _, ok := sharedSecrets.Get(sharedSecretKey(&publisher.PublicKey, &grantee.PublicKey)); ok {
+		t.Fatal("expected shared secret of publisher to be forgotten")
+	}
+	if _, ok := sharedSecrets.Get(sharedSecretKey(&grantee.PublicKey, &publisher.PublicKey)); !ok {
+		t.Fatal("expected shared secret of grantee to be cached")
+	}
+	derived, err := NewSessionKeyPK(publisher, &grantee.PublicKey, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, derived) {
+		t.Fatalf("expected derived session key %x, got %x", key, derived)
+	}
+}
@@ -34,6 +34,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -47,14 +48,18 @@ import (
 	"github.com/ethersphere/swarm/storage/feed"
 	"github.com/ethersphere/swarm/storage/feed/lookup"
 	"github.com/opentracing/opentracing-go"
+	olog "github.com/opentracing/opentracing-go/log"
 )
 
 var (
+	apiReadOnlyReject      = metrics.NewRegisteredCounter("api/readonly/reject", nil)
 	apiResolveCount        = metrics.NewRegisteredCounter("api/resolve/count", nil)
 	apiResolveFail         = metrics.NewRegisteredCounter("api/resolve/fail", nil)
 	apiGetCount            = metrics.NewRegisteredCounter("api/get/count", nil)
 	apiGetNotFound         = metrics.NewRegisteredCounter("api/get/notfound", nil)
 	apiGetHTTP300          = metrics.NewRegisteredCounter("api/get/http/300", nil)
+	apiGetInline           = metrics.NewRegisteredCounter("api/get/inline", nil)
+	apiGetInlineMismatch   = metrics.NewRegisteredCounter("api/get/inline/mismatch", nil)
 	apiManifestUpdateCount = metrics.NewRegisteredCounter("api/manifestupdate/count", nil)
 	apiManifestUpdateFail  = metrics.NewRegisteredCounter("api/manifestupdate/fail", nil)
 	apiManifestListCount   = metrics.NewRegisteredCounter("api/manifestlist/count", nil)
@@ -74,8 +79,19 @@ var (
 	apiAppendFileCount     = metrics.NewRegisteredCounter("api/appendfile/count", nil)
 	apiAppendFileFail      = metrics.NewRegisteredCounter("api/appendfile/fail", nil)
 	apiGetInvalid          = metrics.NewRegisteredCounter("api/get/invalid", nil)
+
+	// latency histograms per operation, in nanoseconds
+	apiResolveLatency = metrics.NewRegisteredHistogram("api/resolve/latency", nil, metrics.NewExpDecaySample(1028, 0.015))
+	apiGetLatency     = metrics.NewRegisteredHistogram("api/get/latency", nil, metrics.NewExpDecaySample(1028, 0.015))
+	apiPutLatency     = metrics.NewRegisteredHistogram("api/put/latency", nil, metrics.NewExpDecaySample(1028, 0.015))
+	apiModifyLatency  = metrics.NewRegisteredHistogram("api/modify/latency", nil, metrics.NewExpDecaySample(1028, 0.015))
 )
 
+// updateLatency records the time elapsed since start in the given histogram
+func updateLatency(h metrics.Histogram, start time.Time) {
+	h.Update(int64(time.Since(start)))
+}
+
 // ResolverFunc is function which takes a domain in the form of a string and resolves it to a content hash
 type ResolverFunc func(domain string) (common.Hash, error)
 
@@ -94,6 +110,11 @@ type ResolveValidator interface {
 	HeaderByNumber(context.Context, *big.Int) (*types.Header, error)
 }
 
+// Publisher points names at content hashes
+type Publisher interface {
+	PublishContent(ctx context.Context, name string, hash common.Hash, resolver common.Address) error
+}
+
 // NoResolverError is returned by MultiResolver.Resolve if no resolver
 // can be found for the address.
 type NoResolverError struct {
@@ -113,6 +134,68 @@ func (e *NoResolverError) Error() string {
 	return fmt.Sprintf("no ENS endpoint configured to resolve .%s TLD names", e.TLD)
 }
 
+// ManifestNotFoundError is returned if the manifest at an address
+// cannot be loaded.
+type ManifestNotFoundError struct {
+	Addr storage.Address
+	Err  error
+}
+
+// Error ManifestNotFoundError implements error
+func (e *ManifestNotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error the manifest failed to load with
+func (e *ManifestNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidHashError is returned if an address or the content of a feed
+// update is expected to be a swarm hash but it is not.
+type InvalidHashError struct {
+	Msg string
+}
+
+// Error InvalidHashError implements error
+func (e *InvalidHashError) Error() string {
+	return e.Msg
+}
+
+// Kinds of resolvers reported in a Resolution
+const (
+	ResolverENS = "ens"
+	ResolverRNS = "rns"
+)
+
+// Resolution is a resolved content hash together with the provenance of the
+// resolution, so that gateways can emit receipts that clients can verify
+// against the chain the name was resolved on.
+type Resolution struct {
+	Address     storage.Address // resolved content hash
+	Name        string          // resolved name, empty if the address is a hash
+	Resolver    string          // kind of the resolver that answered, empty if the address is a hash
+	TLD         string          // top level domain of the resolvers, empty for the default resolvers
+	Index       int             // position of the resolver that answered among the resolvers of the TLD
+	BlockNumber *big.Int        // block at which the name was resolved, nil if not known
+	BlockHash   common.Hash     // hash of the block at which the name was resolved
+	DirectHash  bool            // whether the address was parsed as a hash without resolution
+}
+
+// setBlock records the block of the chain the resolver resolves names on, which is
+// the block of the AsOf of the context if it is set, or the latest block otherwise
+func (r *Resolution) setBlock(ctx context.Context, v ResolveValidator) error {
+	header, err := v.HeaderByNumber(ctx, AsOfFromContext(ctx).Block)
+	if err != nil {
+		return err
+	}
+	if header != nil {
+		r.BlockNumber = header.Number
+		r.BlockHash = header.Hash()
+	}
+	return nil
+}
+
 // MultiResolver is used to resolve URL addresses based on their TLDs.
 // Each TLD can have multiple resolvers, and the resolution from the
 // first one in the sequence will be returned.
@@ -152,7 +235,7 @@ func NewMultiResolver(opts ...MultiResolverOption) (m *MultiResolver) {
 // the Hash from the first one which does not return error
 // will be returned.
 func (m *MultiResolver) Resolve(addr string) (h common.Hash, err error) {
-	rs, err := m.getResolveValidator(addr)
+	_, rs, err := m.getResolveValidator(addr)
 	if err != nil {
 		return h, err
 	}
@@ -165,21 +248,66 @@ func (m *MultiResolver) Resolve(addr string) (h common.Hash, err error) {
 	return
 }
 
+// ResolveAll resolves the address like Resolve, and reports which resolver
+// answered together with the block of its chain the address was resolved at,
+// which is the latest block unless the context is returned by WithAsOf.
+func (m *MultiResolver) ResolveAll(ctx context.Context, addr string) (*Resolution, error) {
+	tld, rs, err := m.getResolveValidator(addr)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range rs {
+		var h common.Hash
+		h, err = resolveName(ctx, r, addr)
+		if err != nil {
+			continue
+		}
+		res := &Resolution{
+			Address:  h[:],
+			Name:     addr,
+			Resolver: ResolverENS,
+			TLD:      tld,
+			Index:    i,
+		}
+		if err := res.setBlock(ctx, r); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	return nil, err
+}
+
+// PublishContent points the name at the content hash with the first resolver
+// for the TLD of the name that is able to publish, see ens.ENS.PublishContent
+func (m *MultiResolver) PublishContent(ctx context.Context, name string, hash common.Hash, resolver common.Address) error {
+	tld, rs, err := m.getResolveValidator(name)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs {
+		if p, ok := r.(Publisher); ok {
+			return p.PublishContent(ctx, name, hash, resolver)
+		}
+	}
+	return fmt.Errorf("no ENS resolver for .%s TLD names is able to publish", tld)
+}
+
 // getResolveValidator uses the hostname to retrieve the resolver associated with the top level domain
-func (m *MultiResolver) getResolveValidator(name string) ([]ResolveValidator, error) {
+// It also returns the TLD the resolvers are registered for, which is empty for the default resolvers
+func (m *MultiResolver) getResolveValidator(name string) (string, []ResolveValidator, error) {
 	rs := m.resolvers[""]
 	tld := path.Ext(name)
 	if tld != "" {
 		tld = tld[1:]
 		rstld, ok := m.resolvers[tld]
 		if ok {
-			return rstld, nil
+			return tld, rstld, nil
 		}
 	}
 	if len(rs) == 0 {
-		return rs, NewNoResolverError(tld)
+		return "", rs, NewNoResolverError(tld)
 	}
-	return rs, nil
+	return "", rs, nil
 }
 
 /*
@@ -194,8 +322,17 @@ type API struct {
 	rns       Resolver //provides access to rns resolvers
 	Tags      *chunk.Tags
 	Decryptor func(context.Context, string) DecryptFunc
+	readOnly  bool  // gateway mode, all write paths are disabled
+	inline    int64 // content of files up to this size is inlined in their manifest entries
+
+	prefetchMu sync.Mutex
+	prefetched map[string]time.Time // start of the latest prefetch of the manifests prefetched within prefetchInterval
+	prefetches int                  // number of prefetches in the background in progress
 }
 
+// ErrReadOnly is returned by the write paths of the API if the node runs in gateway mode
+var ErrReadOnly = errors.New("write operations are disabled on this read-only node")
+
 // NewAPI the api constructor initialises a new API instance.
 func NewAPI(fileStore *storage.FileStore, dns Resolver, rns Resolver, feedHandler *feed.Handler, pk *ecdsa.PrivateKey, tags *chunk.Tags) (self *API) {
 	self = &API{
@@ -211,6 +348,44 @@ func NewAPI(fileStore *storage.FileStore, dns Resolver, rns Resolver, feedHandle
 	return
 }
 
+// SetReadOnly enables or disables the gateway mode of the API
+// In gateway mode uploads, manifest modifications and feed updates fail with ErrReadOnly
+// It is expected to be called once on startup, before the API is served
+func (a *API) SetReadOnly(readOnly bool) {
+	a.readOnly = readOnly
+}
+
+// SetInlineSize sets the size up to which the content of files added to manifests
+// is also inlined in their entries, so that it is served without retrieving it,
+// 0 disables inlining, sizes above MaxManifestInlineSize are capped to it
+// It is expected to be called once on startup, before the API is served
+func (a *API) SetInlineSize(size int64) {
+	if size > MaxManifestInlineSize {
+		size = MaxManifestInlineSize
+	}
+	a.inline = size
+}
+
+// ReadOnly returns true if the API runs in gateway mode and rejects write operations
+func (a *API) ReadOnly() bool {
+	return a.readOnly
+}
+
+// ChunkSize returns the size of the chunks uploaded content is split into
+func (a *API) ChunkSize() int64 {
+	return a.fileStore.ChunkSize()
+}
+
+// checkWritable returns ErrReadOnly if the API runs in gateway mode
+// Every write path of the API calls it before storing any content
+func (a *API) checkWritable() error {
+	if a.readOnly {
+		apiReadOnlyReject.Inc(1)
+		return ErrReadOnly
+	}
+	return nil
+}
+
 // Retrieve FileStore reader API
 func (a *API) Retrieve(ctx context.Context, addr storage.Address) (reader storage.LazySectionReader, isEncrypted bool) {
 	return a.fileStore.Retrieve(ctx, addr)
@@ -226,12 +401,30 @@ func (a *API) RetrieveFeedUpdate(ctx context.Context, addr storage.Address) ([]b
 
 // Store wraps the Store API call of the embedded FileStore
 func (a *API) Store(ctx context.Context, data io.Reader, size int64, toEncrypt bool) (addr storage.Address, wait func(ctx context.Context) error, err error) {
-	log.Debug("api.store", "size", size)
+	log.Request(ctx).Debug("api.store", "size", size)
+	if err := a.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+	defer updateLatency(apiPutLatency, time.Now())
+
+	var sp opentracing.Span
+	ctx, sp = spancontext.StartSpan(
+		ctx,
+		"api.store")
+	defer sp.Finish()
+	sp.LogFields(olog.Int64("size", size), olog.Bool("encrypt", toEncrypt))
+
 	return a.fileStore.Store(ctx, data, size, toEncrypt)
 }
 
+// UploadStats returns the deduplication statistics of the upload tracked by the tag in the context
+func (a *API) UploadStats(ctx context.Context) (storage.UploadStats, error) {
+	return a.fileStore.UploadStats(ctx)
+}
+
 // Resolve a name into a content-addressed hash
 // where address could be an ENS/RNS name, or a content addressed hash
+// Names are resolved at the block of the AsOf of the context if it is set
 func (a *API) Resolve(ctx context.Context, address string) (storage.Address, error) {
 	// if the address is a hash, do not resolve
 	if hashMatcher.MatchString(address) {
@@ -245,7 +438,7 @@ func (a *API) Resolve(ctx context.Context, address string) (storage.Address, err
 			return nil, fmt.Errorf("no RNS to resolve name: %q", address)
 		}
 
-		resolved, err := a.rns.Resolve(address)
+		resolved, err := resolveName(ctx, a.rns, address)
 		if err != nil {
 			return nil, err
 		}
@@ -254,16 +447,91 @@ func (a *API) Resolve(ctx context.Context, address string) (storage.Address, err
 	// if DNS is not configured, return an error
 	if a.dns == nil {
 		apiResolveFail.Inc(1)
-		return nil, fmt.Errorf("no DNS to resolve name: %q", address)
+		return nil, NewNoResolverError(tld(address))
 	}
 	// try and resolve the address
-	resolved, err := a.dns.Resolve(address)
+	resolved, err := resolveName(ctx, a.dns, address)
 	if err != nil {
 		return nil, err
 	}
 	return resolved[:], nil
 }
 
+// PublishName points the ENS name at the content address, registering the name and
+// setting the resolver if needed, and returns once the name resolves to the address
+func (a *API) PublishName(ctx context.Context, name string, addr storage.Address, resolver common.Address) error {
+	if a.readOnly {
+		return ErrReadOnly
+	}
+	if len(addr) != common.HashLength {
+		return fmt.Errorf("can not publish an address of length %d, only unencrypted content can be published", len(addr))
+	}
+	if tld(name) == "rsk" {
+		return fmt.Errorf("publishing RNS names is not supported: %q", name)
+	}
+	if a.dns == nil {
+		return fmt.Errorf("no DNS to publish name: %q", name)
+	}
+	p, ok := a.dns.(Publisher)
+	if !ok {
+		return fmt.Errorf("DNS is not able to publish name: %q", name)
+	}
+	return p.PublishContent(ctx, name, common.BytesToHash(addr), resolver)
+}
+
+// ResolveAll resolves an address like Resolve, and returns the resolved hash
+// with the provenance of the resolution
+func (a *API) ResolveAll(ctx context.Context, address string) (*Resolution, error) {
+	// if the address is a hash, do not resolve
+	if hashMatcher.MatchString(address) {
+		return &Resolution{Address: common.Hex2Bytes(address), DirectHash: true}, nil
+	}
+	if tld(address) == "rsk" {
+		if a.rns == nil {
+			apiResolveFail.Inc(1)
+			return nil, fmt.Errorf("no RNS to resolve name: %q", address)
+		}
+		resolved, err := resolveName(ctx, a.rns, address)
+		if err != nil {
+			return nil, err
+		}
+		res := &Resolution{
+			Address:  resolved[:],
+			Name:     address,
+			Resolver: ResolverRNS,
+			TLD:      "rsk",
+		}
+		if v, ok := a.rns.(ResolveValidator); ok {
+			if err := res.setBlock(ctx, v); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	if a.dns == nil {
+		apiResolveFail.Inc(1)
+		return nil, NewNoResolverError(tld(address))
+	}
+	if m, ok := a.dns.(*MultiResolver); ok {
+		return m.ResolveAll(ctx, address)
+	}
+	resolved, err := resolveName(ctx, a.dns, address)
+	if err != nil {
+		return nil, err
+	}
+	res := &Resolution{
+		Address:  resolved[:],
+		Name:     address,
+		Resolver: ResolverENS,
+	}
+	if v, ok := a.dns.(ResolveValidator); ok {
+		if err := res.setBlock(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
 //
 func tld(address string) (tld string) {
 	splitAddress := strings.Split(address, ".")
@@ -276,7 +544,8 @@ func tld(address string) (tld string) {
 // Resolve resolves a URI to an Address using the MultiResolver.
 func (a *API) ResolveURI(ctx context.Context, uri *URI, credentials string) (storage.Address, error) {
 	apiResolveCount.Inc(1)
-	log.Trace("resolving", "uri", uri.Addr)
+	defer updateLatency(apiResolveLatency, time.Now())
+	log.Request(ctx).Trace("resolving", "uri", uri.Addr)
 
 	var sp opentracing.Span
 	ctx, sp = spancontext.StartSpan(
@@ -288,7 +557,7 @@ func (a *API) ResolveURI(ctx context.Context, uri *URI, credentials string) (sto
 	if uri.Immutable() {
 		key := uri.Address()
 		if key == nil {
-			return nil, fmt.Errorf("immutable address not a content hash: %q", uri.Addr)
+			return nil, &InvalidHashError{Msg: fmt.Sprintf("immutable address not a content hash: %q", uri.Addr)}
 		}
 		return key, nil
 	}
@@ -335,51 +604,141 @@ func (a *API) ResolveURI(ctx context.Context, uri *URI, credentials string) (sto
 
 // Get uses iterative manifest retrieval and prefix matching
 // to resolve basePath to content using FileStore retrieve
+// feeds are looked up at the time of the AsOf of the context if it is set
 // it returns a section reader, mimeType, status, the key of the actual content and an error
 func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, mimeType string, status int, contentAddr storage.Address, err error) {
-	log.Debug("api.get", "key", manifestAddr, "path", path)
 	apiGetCount.Inc(1)
+	defer updateLatency(apiGetLatency, time.Now())
+
+	var sp opentracing.Span
+	ctx, sp = spancontext.StartSpan(
+		ctx,
+		"api.get")
+	defer sp.Finish()
+
+	reader, entry, status, contentAddr, err := a.get(ctx, decrypt, manifestAddr, path, nil)
+	sp.LogFields(
+		olog.String("path", path),
+		olog.Int("status", status))
+	if entry != nil {
+		mimeType = entry.ContentType
+	}
+	return reader, mimeType, status, contentAddr, err
+}
+
+// ManifestHop is a manifest traversed while resolving a path
+type ManifestHop struct {
+	Manifest storage.Address `json:"manifest"` // address of the manifest
+	Path     string          `json:"path"`     // path looked up in the manifest
+	Entry    *ManifestEntry  `json:"entry"`    // entry of the manifest matching the path
+}
+
+// EntryMetadata describes the content a path resolves to
+type EntryMetadata struct {
+	Entry       *ManifestEntry  `json:"entry"`       // the resolved manifest entry
+	ContentAddr storage.Address `json:"contentAddr"` // address of the content the entry points to
+	ContentType string          `json:"contentType"`
+	Size        int64           `json:"size"` // size of the content as reported by its root chunk
+	ModTime     time.Time       `json:"modTime"`
+	Trail       []ManifestHop   `json:"trail"` // manifests traversed from the root manifest to the entry
+}
+
+// GetWithMetadata resolves path to content like Get, and returns together
+// with the reader the resolved entry, its size, modification time and content
+// type and the trail of the manifests traversed to find it
+// The returned metadata is never nil and holds the trail traversed so far on error
+// The size is only set for unambiguous entries, for which the existence of the
+// root chunk of the content is checked by retrieving it
+func (a *API) GetWithMetadata(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string) (reader storage.LazySectionReader, meta *EntryMetadata, status int, err error) {
+	apiGetCount.Inc(1)
+	defer updateLatency(apiGetLatency, time.Now())
+
+	var sp opentracing.Span
+	ctx, sp = spancontext.StartSpan(
+		ctx,
+		"api.get")
+	defer sp.Finish()
+
+	meta = &EntryMetadata{}
+	reader, meta.Entry, status, meta.ContentAddr, err = a.get(ctx, decrypt, manifestAddr, path, &meta.Trail)
+	defer func() {
+		sp.LogFields(
+			olog.String("path", path),
+			olog.Int("status", status))
+	}()
+	if meta.Entry != nil {
+		meta.ContentType = meta.Entry.ContentType
+		meta.ModTime = meta.Entry.ModTime
+	}
+	if err != nil || status == http.StatusMultipleChoices {
+		return reader, meta, status, err
+	}
+
+	meta.Size, err = reader.Size(ctx, nil)
+	if err != nil {
+		apiGetNotFound.Inc(1)
+		return nil, meta, http.StatusNotFound, fmt.Errorf("file not found: %v", err)
+	}
+	return reader, meta, status, nil
+}
+
+// maxFeedHops limits the number of feeds resolved by a single Get call, as
+// the updates of feeds may reference each other in a loop
+const maxFeedHops = 16
+
+// feedHopsKey is the context key of the number of feeds resolved so far
+type feedHopsKey struct{}
+
+// get resolves path in the manifest with the given address. It calls itself
+// recursively for nested manifests so that metrics and tracing are only
+// recorded once per Get call. The matched entries of the traversed manifests
+// are appended to trail unless it is nil.
+func (a *API) get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string, trail *[]ManifestHop) (reader storage.LazySectionReader, me *ManifestEntry, status int, contentAddr storage.Address, err error) {
+	logger := log.Request(ctx)
+	logger.Debug("api.get", "key", manifestAddr, "path", path)
 	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil, decrypt)
 	if err != nil {
 		apiGetNotFound.Inc(1)
 		status = http.StatusNotFound
-		return nil, "", http.StatusNotFound, nil, err
+		return nil, nil, http.StatusNotFound, nil, &ManifestNotFoundError{Addr: manifestAddr, Err: err}
 	}
 
-	log.Debug("trie getting entry", "key", manifestAddr, "path", path)
-	entry, _ := trie.getEntry(path)
+	logger.Debug("trie getting entry", "key", manifestAddr, "path", path)
+	entry, fullpath := trie.getEntry(path)
 
 	if entry != nil {
-		log.Debug("trie got entry", "key", manifestAddr, "path", path, "entry.Hash", entry.Hash)
+		logger.Debug("trie got entry", "key", manifestAddr, "path", path, "entry.Hash", entry.Hash)
+		addHop(trail, manifestAddr, path, &entry.ManifestEntry)
 
 		if entry.ContentType == ManifestType {
-			log.Debug("entry is manifest", "key", manifestAddr, "new key", entry.Hash)
+			logger.Debug("entry is manifest", "key", manifestAddr, "new key", entry.Hash)
 			adr, err := hex.DecodeString(entry.Hash)
 			if err != nil {
-				return nil, "", 0, nil, err
+				return nil, nil, 0, nil, err
 			}
-			return a.Get(ctx, decrypt, adr, entry.Path)
+			return a.get(ctx, decrypt, adr, entry.Path, trail)
 		}
 
 		// we need to do some extra work if this is a Swarm feed manifest
 		if entry.ContentType == FeedContentType {
 			if entry.Feed == nil {
-				return reader, mimeType, status, nil, fmt.Errorf("Cannot decode Feed in manifest")
+				return reader, nil, status, nil, fmt.Errorf("Cannot decode Feed in manifest")
 			}
-			_, err := a.feed.Lookup(ctx, feed.NewQueryLatest(entry.Feed, lookup.NoClue))
+			// the update at the time of the AsOf of the context, or the latest one
+			_, err := a.feed.Lookup(ctx, feed.NewQuery(entry.Feed, AsOfFromContext(ctx).Time, lookup.NoClue))
 			if err != nil {
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
-				log.Debug(fmt.Sprintf("get feed update content error: %v", err))
-				return reader, mimeType, status, nil, err
+				logger.Debug(fmt.Sprintf("get feed update content error: %v", err))
+				return reader, nil, status, nil, err
 			}
 			// get the data of the update
 			_, contentAddr, err := a.feed.GetContent(entry.Feed)
 			if err != nil {
 				apiGetNotFound.Inc(1)
 				status = http.StatusNotFound
-				log.Warn(fmt.Sprintf("get feed update content error: %v", err))
-				return reader, mimeType, status, nil, err
+				logger.Warn(fmt.Sprintf("get feed update content error: %v", err))
+				return reader, nil, status, nil, err
 			}
 
 			// extract content hash
@@ -387,31 +746,22 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 				apiGetInvalid.Inc(1)
 				status = http.StatusUnprocessableEntity
 				errorMessage := fmt.Sprintf("invalid swarm hash in feed update. Expected %d bytes. Got %d", storage.AddressLength, len(contentAddr))
-				log.Warn(errorMessage)
-				return reader, mimeType, status, nil, errors.New(errorMessage)
-			}
-			manifestAddr = storage.Address(contentAddr)
-			log.Trace("feed update contains swarm hash", "key", manifestAddr)
-
-			// get the manifest the swarm hash points to
-			trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil, NOOPDecrypt)
-			if err != nil {
-				apiGetNotFound.Inc(1)
-				status = http.StatusNotFound
-				log.Warn(fmt.Sprintf("loadManifestTrie (feed update) error: %v", err))
-				return reader, mimeType, status, nil, err
+				logger.Warn(errorMessage)
+				return reader, nil, status, nil, &InvalidHashError{Msg: errorMessage}
 			}
+			logger.Trace("feed update contains swarm hash", "key", storage.Address(contentAddr))
 
-			// finally, get the manifest entry
-			// it will always be the entry on path ""
-			entry, _ = trie.getEntry(path)
-			if entry == nil {
-				status = http.StatusNotFound
-				apiGetNotFound.Inc(1)
-				err = fmt.Errorf("manifest (feed update) entry for '%s' not found", path)
-				log.Trace("manifest (feed update) entry not found", "key", manifestAddr, "path", path)
-				return reader, mimeType, status, nil, err
+			// the feed may be mounted at any path of the manifest, the rest
+			// of the path is resolved in the manifest of the latest update
+			hops, _ := ctx.Value(feedHopsKey{}).(int)
+			if hops >= maxFeedHops {
+				apiGetInvalid.Inc(1)
+				status = http.StatusUnprocessableEntity
+				return reader, nil, status, nil, fmt.Errorf("too many nested feeds resolving '%s'", path)
 			}
+			ctx = context.WithValue(ctx, feedHopsKey{}, hops+1)
+			rest := strings.TrimPrefix(RegularSlashes(path)[len(fullpath):], "/")
+			return a.get(ctx, NOOPDecrypt, storage.Address(contentAddr), rest, trail)
 		}
 
 		// regardless of feed update manifests or normal manifests we will converge at this point
@@ -420,25 +770,51 @@ func (a *API) Get(ctx context.Context, decrypt DecryptFunc, manifestAddr storage
 		status = entry.Status
 		if status == http.StatusMultipleChoices {
 			apiGetHTTP300.Inc(1)
-			return nil, entry.ContentType, status, contentAddr, err
+			return nil, &entry.ManifestEntry, status, contentAddr, err
 		}
-		mimeType = entry.ContentType
-		log.Debug("content lookup key", "key", contentAddr, "mimetype", mimeType)
+		me = &entry.ManifestEntry
+		if me.Data != nil {
+			// the inlined data is only served if it is the content of the entry,
+			// otherwise the content is retrieved by its hash
+			if len(me.Data) <= MaxManifestInlineSize && a.fileStore.Matches(ctx, me.Data, contentAddr) {
+				apiGetInline.Inc(1)
+				return newInlineReader(ctx, me.Data), me, status, contentAddr, nil
+			}
+			apiGetInlineMismatch.Inc(1)
+			logger.Warn("inlined data does not match the content hash, retrieving content", "key", contentAddr)
+		}
+		logger.Debug("content lookup key", "key", contentAddr, "mimetype", me.ContentType)
 		reader, _ = a.fileStore.Retrieve(ctx, contentAddr)
 	} else {
 		// no entry found
 		status = http.StatusNotFound
 		apiGetNotFound.Inc(1)
 		err = fmt.Errorf("Not found: could not find resource '%s'", path)
-		log.Trace("manifest entry not found", "key", contentAddr, "path", path)
+		logger.Trace("manifest entry not found", "key", contentAddr, "path", path)
 	}
 	return
 }
 
+// addHop appends a traversed manifest to the trail unless it is nil
+func addHop(trail *[]ManifestHop, manifestAddr storage.Address, path string, entry *ManifestEntry) {
+	if trail == nil {
+		return
+	}
+	*trail = append(*trail, ManifestHop{
+		Manifest: manifestAddr,
+		Path:     path,
+		Entry:    entry,
+	})
+}
+
 // Delete handles removing a file from the manifest.
 // This creates a new manifest without the given path
 func (a *API) Delete(ctx context.Context, addr string, path string) (storage.Address, error) {
 	apiDeleteCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiDeleteFail.Inc(1)
+		return nil, err
+	}
 	uri, err := Parse("bzz:/" + addr)
 	if err != nil {
 		apiDeleteFail.Inc(1)
@@ -600,6 +976,10 @@ func (a *API) GetManifestList(ctx context.Context, decryptor DecryptFunc, addr s
 
 func (a *API) UpdateManifest(ctx context.Context, addr storage.Address, update func(mw *ManifestWriter) error) (storage.Address, error) {
 	apiManifestUpdateCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiManifestUpdateFail.Inc(1)
+		return nil, err
+	}
 	mw, err := a.NewManifestWriter(ctx, addr, nil)
 	if err != nil {
 		apiManifestUpdateFail.Inc(1)
@@ -623,6 +1003,18 @@ func (a *API) UpdateManifest(ctx context.Context, addr storage.Address, update f
 // Modify loads manifest and checks the content hash before recalculating and storing the manifest.
 func (a *API) Modify(ctx context.Context, addr storage.Address, path, contentHash, contentType string) (storage.Address, error) {
 	apiModifyCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiModifyFail.Inc(1)
+		return nil, err
+	}
+	defer updateLatency(apiModifyLatency, time.Now())
+
+	var sp opentracing.Span
+	ctx, sp = spancontext.StartSpan(
+		ctx,
+		"api.modify")
+	defer sp.Finish()
+
 	quitC := make(chan bool)
 	trie, err := loadManifest(ctx, a.fileStore, addr, quitC, NOOPDecrypt)
 	if err != nil {
@@ -648,8 +1040,13 @@ func (a *API) Modify(ctx context.Context, addr storage.Address, path, contentHas
 }
 
 // AddFile creates a new manifest entry, adds it to swarm, then adds a file to swarm.
-func (a *API) AddFile(ctx context.Context, mhash, path, fname string, content []byte, nameresolver bool) (storage.Address, string, error) {
+// mode is the mode of the manifest entry of the file
+func (a *API) AddFile(ctx context.Context, mhash, path, fname string, content []byte, mode int64, nameresolver bool) (storage.Address, string, error) {
 	apiAddFileCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiAddFileFail.Inc(1)
+		return nil, "", err
+	}
 
 	uri, err := Parse("bzz:/" + mhash)
 	if err != nil {
@@ -670,7 +1067,7 @@ func (a *API) AddFile(ctx context.Context, mhash, path, fname string, content []
 	entry := &ManifestEntry{
 		Path:        filepath.Join(path, fname),
 		ContentType: mime.TypeByExtension(filepath.Ext(fname)),
-		Mode:        0700,
+		Mode:        mode,
 		Size:        int64(len(content)),
 		ModTime:     time.Now(),
 	}
@@ -699,6 +1096,10 @@ func (a *API) AddFile(ctx context.Context, mhash, path, fname string, content []
 
 func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestPath, defaultPath string, mw *ManifestWriter) (storage.Address, error) {
 	apiUploadTarCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiUploadTarFail.Inc(1)
+		return nil, err
+	}
 	var contentKey storage.Address
 	tr := tar.NewReader(bodyReader)
 	defer bodyReader.Close()
@@ -749,6 +1150,7 @@ func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestP
 				Mode:        hdr.Mode,
 				Size:        hdr.Size,
 				ModTime:     hdr.ModTime,
+				Data:        entry.Data,
 			}
 			contentKey, err = mw.AddEntry(ctx, nil, entry)
 			if err != nil {
@@ -767,6 +1169,10 @@ func (a *API) UploadTar(ctx context.Context, bodyReader io.ReadCloser, manifestP
 // RemoveFile removes a file entry in a manifest.
 func (a *API) RemoveFile(ctx context.Context, mhash string, path string, fname string, nameresolver bool) (string, error) {
 	apiRmFileCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiRmFileFail.Inc(1)
+		return "", err
+	}
 
 	uri, err := Parse("bzz:/" + mhash)
 	if err != nil {
@@ -807,8 +1213,13 @@ func (a *API) RemoveFile(ctx context.Context, mhash string, path string, fname s
 }
 
 // AppendFile removes old manifest, appends file entry to new manifest and adds it to Swarm.
-func (a *API) AppendFile(ctx context.Context, mhash, path, fname string, existingSize int64, content []byte, oldAddr storage.Address, offset int64, addSize int64, nameresolver bool) (storage.Address, string, error) {
+// mode is the mode of the manifest entry of the file
+func (a *API) AppendFile(ctx context.Context, mhash, path, fname string, existingSize int64, content []byte, oldAddr storage.Address, offset int64, addSize int64, mode int64, nameresolver bool) (storage.Address, string, error) {
 	apiAppendFileCount.Inc(1)
+	if err := a.checkWritable(); err != nil {
+		apiAppendFileFail.Inc(1)
+		return nil, "", err
+	}
 
 	buffSize := offset + addSize
 	if buffSize < existingSize {
@@ -866,7 +1277,7 @@ func (a *API) AppendFile(ctx context.Context, mhash, path, fname string, existin
 	entry := &ManifestEntry{
 		Path:        filepath.Join(path, fname),
 		ContentType: mime.TypeByExtension(filepath.Ext(fname)),
-		Mode:        0700,
+		Mode:        mode,
 		Size:        totalSize,
 		ModTime:     time.Now(),
 	}
@@ -930,6 +1341,15 @@ func (a *API) FeedsLookup(ctx context.Context, query *feed.Query) ([]byte, error
 	return data, nil
 }
 
+// FeedsLatest looks up the latest update of the given feed and returns the address of its chunk
+// along with its data
+func (a *API) FeedsLatest(ctx context.Context, fd *feed.Feed) (storage.Address, []byte, error) {
+	if _, err := a.feed.Lookup(ctx, feed.NewQueryLatest(fd, lookup.NoClue)); err != nil {
+		return nil, nil, err
+	}
+	return a.feed.GetContent(fd)
+}
+
 // FeedsNewRequest creates a Request object to update a specific feed
 func (a *API) FeedsNewRequest(ctx context.Context, feed *feed.Feed) (*feed.Request, error) {
 	return a.feed.NewRequest(ctx, feed)
@@ -937,6 +1357,9 @@ func (a *API) FeedsNewRequest(ctx context.Context, feed *feed.Feed) (*feed.Reque
 
 // FeedsUpdate publishes a new update on the given feed
 func (a *API) FeedsUpdate(ctx context.Context, request *feed.Request) (storage.Address, error) {
+	if err := a.checkWritable(); err != nil {
+		return nil, err
+	}
 	return a.feed.Update(ctx, request)
 }
 
@@ -25,17 +25,22 @@ import (
 	"io"
 	"io/ioutil"
 	"math/big"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethersphere/swarm/chunk"
 	chunktesting "github.com/ethersphere/swarm/chunk/testing"
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
 	rns "github.com/rnsdomains/rns-go-lib/resolver"
 )
@@ -146,6 +151,44 @@ func TestApiPut(t *testing.T) {
 	})
 }
 
+// TestApiReadOnly tests that the write paths of the api fail in gateway mode
+// while the content stored before can still be retrieved
+func TestApiReadOnly(t *testing.T) {
+	testAPI(t, func(api *API, tags *chunk.Tags, toEncrypt bool) {
+		content := "hello"
+		exp := expResponse(content, "text/plain", 0)
+		ctx := context.TODO()
+		addr, wait, err := putString(ctx, api, content, exp.MimeType, toEncrypt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		api.SetReadOnly(true)
+
+		if _, _, err := putString(ctx, api, content, exp.MimeType, toEncrypt); err != ErrReadOnly {
+			t.Fatalf("expected error %v, got %v", ErrReadOnly, err)
+		}
+		if _, err := api.NewManifest(ctx, toEncrypt); err != ErrReadOnly {
+			t.Fatalf("expected error %v, got %v", ErrReadOnly, err)
+		}
+		if _, err := api.Modify(ctx, addr, "foo", "", ""); err != ErrReadOnly {
+			t.Fatalf("expected error %v, got %v", ErrReadOnly, err)
+		}
+		if _, err := api.Delete(ctx, addr.Hex(), ""); err != ErrReadOnly {
+			t.Fatalf("expected error %v, got %v", ErrReadOnly, err)
+		}
+		if _, _, err := api.AddFile(ctx, addr.Hex(), "/", "foo", []byte(content), 0700, true); err != ErrReadOnly {
+			t.Fatalf("expected error %v, got %v", ErrReadOnly, err)
+		}
+
+		resp := testGet(t, api, addr.Hex(), "")
+		checkResponse(t, resp, exp)
+	})
+}
+
 // TestApiTagLarge tests that the the number of chunks counted is larger for a larger input
 func TestApiTagLarge(t *testing.T) {
 	const contentLength = 4096 * 4095
@@ -178,10 +221,176 @@ func TestApiTagLarge(t *testing.T) {
 	})
 }
 
+// TestApiGetWithMetadata tests that the metadata of the resolved entry and
+// the trail of the traversed manifests are returned along with the content
+func TestApiGetWithMetadata(t *testing.T) {
+	testAPI(t, func(api *API, tags *chunk.Tags, toEncrypt bool) {
+		ctx := context.TODO()
+		content := "hello"
+		modTime := time.Unix(1500000000, 0).UTC()
+
+		store := func(data string) storage.Address {
+			addr, wait, err := api.Store(ctx, strings.NewReader(data), int64(len(data)), toEncrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+			return addr
+		}
+		contentAddr := store(content)
+		inner := store(fmt.Sprintf(`{"entries":[{"hash":"%v","contentType":"text/plain","mod_time":"%s"}]}`, contentAddr, modTime.Format(time.RFC3339)))
+		outer := store(fmt.Sprintf(`{"entries":[{"hash":"%v","contentType":"%s"}]}`, inner, ManifestType))
+
+		reader, meta, status, err := api.GetWithMetadata(ctx, NOOPDecrypt, outer, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != 0 {
+			t.Fatalf("expected status 0, got %d", status)
+		}
+		if !bytes.Equal(meta.ContentAddr, contentAddr) {
+			t.Fatalf("expected content address %v, got %v", contentAddr, meta.ContentAddr)
+		}
+		if meta.ContentType != "text/plain" {
+			t.Fatalf("expected content type text/plain, got %q", meta.ContentType)
+		}
+		if meta.Size != int64(len(content)) {
+			t.Fatalf("expected size %d, got %d", len(content), meta.Size)
+		}
+		if !meta.ModTime.Equal(modTime) {
+			t.Fatalf("expected mod time %v, got %v", modTime, meta.ModTime)
+		}
+		data := make([]byte, meta.Size)
+		if _, err := reader.ReadAt(data, 0); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expected content %q, got %q", content, data)
+		}
+
+		if len(meta.Trail) != 2 {
+			t.Fatalf("expected 2 manifests in the trail, got %d", len(meta.Trail))
+		}
+		for i, m := range []storage.Address{outer, inner} {
+			if !bytes.Equal(meta.Trail[i].Manifest, m) {
+				t.Fatalf("expected manifest %v at trail position %d, got %v", m, i, meta.Trail[i].Manifest)
+			}
+		}
+		if meta.Trail[0].Entry.ContentType != ManifestType {
+			t.Fatalf("expected manifest entry at trail position 0, got %q", meta.Trail[0].Entry.ContentType)
+		}
+		if meta.Trail[1].Entry != meta.Entry {
+			t.Fatal("expected last entry of the trail to be the resolved entry")
+		}
+
+		named := store(fmt.Sprintf(`{"entries":[{"hash":"%v","path":"file"}]}`, contentAddr))
+		_, meta, status, err = api.GetWithMetadata(ctx, NOOPDecrypt, named, "missing")
+		if err == nil {
+			t.Fatal("expected error for missing entry")
+		}
+		if status != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, status)
+		}
+		if meta == nil {
+			t.Fatal("expected metadata on error")
+		}
+	})
+}
+
+// TestApiInlineSmallFiles tests that the content of files up to the inline size is
+// inlined in the entries of unencrypted manifests and served from them, that it is
+// still retrievable by the hash of the entries and that inlined data not matching
+// the hash is not served
+func TestApiInlineSmallFiles(t *testing.T) {
+	testAPI(t, func(api *API, tags *chunk.Tags, toEncrypt bool) {
+		ctx := context.TODO()
+		api.SetInlineSize(8)
+		files := map[string]string{
+			"small": "tiny",
+			"large": "not inlined",
+		}
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			for path, content := range files {
+				if _, err := mw.AddEntry(ctx, strings.NewReader(content), &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(len(content))}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		trie, err := loadManifest(ctx, api.fileStore, addr, nil, NOOPDecrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inlined := !toEncrypt
+		if entry, _ := trie.getEntry("small"); entry == nil || (string(entry.Data) == "tiny") != inlined {
+			t.Fatalf("expected inlined content of small file %v, got entry %v", inlined, entry)
+		}
+		if entry, _ := trie.getEntry("large"); entry == nil || entry.Data != nil {
+			t.Fatalf("expected content of large file not to be inlined, got entry %v", entry)
+		}
+
+		for path, content := range files {
+			reader, _, _, contentAddr, err := api.Get(ctx, NOOPDecrypt, addr, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := reader.(*inlineReader); ok != (inlined && path == "small") {
+				t.Fatalf("%s: expected inlined reader %v, got %T", path, inlined && path == "small", reader)
+			}
+			checkResponse(t, &testResponse{reader: reader, Response: &Response{MimeType: "text/plain", Size: int64(len(content))}}, expResponse(content, "text/plain", 0))
+
+			// the content is also stored for the nodes which do not read the inlined data
+			reader, _ = api.Retrieve(ctx, contentAddr)
+			data, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Fatalf("%s: expected stored content %q, got %q", path, content, data)
+			}
+		}
+
+		// data inlined in an entry that is not its content is not served
+		large, _ := trie.getEntry("large")
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			_, err := mw.AddEntry(ctx, nil, &ManifestEntry{Path: "forged", Hash: large.Hash, ContentType: "text/plain", Size: 6, Data: []byte("forged")})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, _, _, _, err := api.Get(ctx, NOOPDecrypt, addr, "forged")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := reader.(*inlineReader); ok {
+			t.Fatal("expected forged inlined data not to be served")
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != files["large"] {
+			t.Fatalf("expected content %q retrieved by hash, got %q", files["large"], data)
+		}
+	})
+}
+
 // testResolver implements the Resolver interface and either returns the given
 // hash if it is set, or returns a "name not found" error
 type testResolveValidator struct {
-	hash *common.Hash
+	hash   *common.Hash
+	header *types.Header // latest header of the chain, nil if not known
 }
 
 func newTestResolveValidator(addr string) *testResolveValidator {
@@ -216,7 +425,7 @@ func (t *testResolveValidator) Owner(node [32]byte) (addr common.Address, err er
 	return
 }
 func (t *testResolveValidator) HeaderByNumber(context.Context, *big.Int) (header *types.Header, err error) {
-	return
+	return t.header, nil
 }
 
 // TestAPIResolve tests resolving URIs which can either contain content hashes
@@ -248,7 +457,7 @@ func TestAPIResolve(t *testing.T) {
 			desc:      "DNS not configured, ENS address, returns error",
 			dns:       nil,
 			addr:      ensAddr,
-			expectErr: errors.New(`no DNS to resolve name: "swarm.eth"`),
+			expectErr: NewNoResolverError("eth"),
 		},
 		{
 			desc:   "DNS not configured, hash address, hash resolves, returns hash",
@@ -497,6 +706,192 @@ func TestMultiResolver(t *testing.T) {
 	}
 }
 
+// testPublisher is a resolver that records the content published under names
+type testPublisher struct {
+	*testResolveValidator
+	published map[string]common.Hash
+}
+
+func (t *testPublisher) PublishContent(_ context.Context, name string, hash common.Hash, _ common.Address) error {
+	t.published[name] = hash
+	return nil
+}
+
+// TestAPIPublishName tests that names are published with the first
+// resolver of their TLD that is able to publish
+func TestAPIPublishName(t *testing.T) {
+	publisher := &testPublisher{
+		testResolveValidator: newTestResolveValidator(""),
+		published:            make(map[string]common.Hash),
+	}
+	api := &API{
+		dns: NewMultiResolver(
+			MultiResolverOptionWithResolver(newTestResolveValidator(""), ""),
+			MultiResolverOptionWithResolver(newTestResolveValidator(""), "eth"),
+			MultiResolverOptionWithResolver(publisher, "eth"),
+		),
+	}
+	addr := storage.Address(common.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222").Bytes())
+
+	if err := api.PublishName(context.TODO(), "swarm.eth", addr, common.Address{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := publisher.published["swarm.eth"]; !bytes.Equal(got[:], addr) {
+		t.Fatalf("got published hash %s, want %s", got.Hex(), addr.Hex())
+	}
+
+	if err := api.PublishName(context.TODO(), "swarm.test", addr, common.Address{}); err == nil {
+		t.Fatal("expected error publishing with a resolver that is not able to publish")
+	}
+	if err := api.PublishName(context.TODO(), "encrypted.eth", make(storage.Address, 64), common.Address{}); err == nil {
+		t.Fatal("expected error publishing an encrypted address")
+	}
+	api.SetReadOnly(true)
+	if err := api.PublishName(context.TODO(), "swarm.eth", addr, common.Address{}); err != ErrReadOnly {
+		t.Fatalf("got error %v, want %v", err, ErrReadOnly)
+	}
+}
+
+// TestAPIResolveAll tests that resolving an address reports which resolver
+// answered and the block the name was resolved at
+func TestAPIResolveAll(t *testing.T) {
+	hashAddr := "1111111111111111111111111111111111111111111111111111111111111111"
+	ethHash := "0x2222222222222222222222222222222222222222222222222222222222222222"
+	doesntResolve := newTestResolveValidator("")
+	ethResolve := newTestResolveValidator(ethHash)
+	ethResolve.header = &types.Header{Number: big.NewInt(42)}
+
+	api := &API{
+		dns: NewMultiResolver(
+			MultiResolverOptionWithResolver(doesntResolve, ""),
+			MultiResolverOptionWithResolver(doesntResolve, "eth"),
+			MultiResolverOptionWithResolver(ethResolve, "eth"),
+		),
+	}
+
+	res, err := api.ResolveAll(context.TODO(), "swarm.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address.Hex() != ethHash[2:] {
+		t.Fatalf("expected address %s, got %s", ethHash[2:], res.Address.Hex())
+	}
+	if res.Name != "swarm.eth" || res.Resolver != ResolverENS || res.TLD != "eth" || res.Index != 1 || res.DirectHash {
+		t.Fatalf("unexpected provenance %+v", res)
+	}
+	if res.BlockNumber == nil || res.BlockNumber.Int64() != 42 || res.BlockHash != ethResolve.header.Hash() {
+		t.Fatalf("expected block 42 %s, got %v %s", ethResolve.header.Hash().Hex(), res.BlockNumber, res.BlockHash.Hex())
+	}
+
+	res, err = api.ResolveAll(context.TODO(), hashAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address.Hex() != hashAddr || !res.DirectHash || res.Resolver != "" || res.BlockNumber != nil {
+		t.Fatalf("expected hash to be parsed directly, got %+v", res)
+	}
+
+	_, err = api.ResolveAll(context.TODO(), "swarm.test")
+	if err == nil || err.Error() != `DNS name not found: "swarm.test"` {
+		t.Fatalf("expected name not found error, got %v", err)
+	}
+}
+
+// testHistoricalResolveValidator resolves names to the hashes
+// they had at block numbers, as a HistoricalResolver
+type testHistoricalResolveValidator struct {
+	*testResolveValidator
+	history map[int64]common.Hash
+}
+
+func (t *testHistoricalResolveValidator) ResolveAt(ctx context.Context, name string, blockNumber *big.Int) (common.Hash, error) {
+	if blockNumber == nil {
+		return t.Resolve(name)
+	}
+	h, ok := t.history[blockNumber.Int64()]
+	if !ok {
+		return common.Hash{}, fmt.Errorf("DNS name not found: %q at block %v", name, blockNumber)
+	}
+	return h, nil
+}
+
+func (t *testHistoricalResolveValidator) HeaderByNumber(_ context.Context, blockNumber *big.Int) (*types.Header, error) {
+	if blockNumber == nil {
+		return t.header, nil
+	}
+	return &types.Header{Number: blockNumber}, nil
+}
+
+// TestAPIResolveAsOf tests resolving names at past blocks
+// with the context returned by WithAsOf
+func TestAPIResolveAsOf(t *testing.T) {
+	latestHash := "0x2222222222222222222222222222222222222222222222222222222222222222"
+	pastHash := common.HexToHash("0x3333333333333333333333333333333333333333333333333333333333333333")
+
+	ethResolve := &testHistoricalResolveValidator{
+		testResolveValidator: newTestResolveValidator(latestHash),
+		history: map[int64]common.Hash{
+			10: pastHash,
+		},
+	}
+	ethResolve.header = &types.Header{Number: big.NewInt(42)}
+
+	api := &API{
+		dns: NewMultiResolver(
+			MultiResolverOptionWithResolver(newTestResolveValidator(""), "eth"),
+			MultiResolverOptionWithResolver(ethResolve, "eth"),
+			MultiResolverOptionWithResolver(newTestResolveValidator(latestHash), "test"),
+		),
+	}
+
+	addr, err := api.Resolve(context.Background(), "swarm.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Hex() != latestHash[2:] {
+		t.Fatalf("expected latest address %s, got %s", latestHash[2:], addr.Hex())
+	}
+
+	ctx := WithAsOf(context.Background(), AsOf{Block: big.NewInt(10)})
+	addr, err = api.Resolve(ctx, "swarm.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Hex() != pastHash.Hex()[2:] {
+		t.Fatalf("expected past address %s, got %s", pastHash.Hex()[2:], addr.Hex())
+	}
+
+	res, err := api.ResolveAll(ctx, "swarm.eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Address.Hex() != pastHash.Hex()[2:] || res.Index != 1 {
+		t.Fatalf("unexpected resolution %+v", res)
+	}
+	if res.BlockNumber == nil || res.BlockNumber.Int64() != 10 {
+		t.Fatalf("expected resolution at block 10, got %v", res.BlockNumber)
+	}
+
+	_, err = api.Resolve(WithAsOf(context.Background(), AsOf{Block: big.NewInt(5)}), "swarm.eth")
+	if err == nil {
+		t.Fatal("expected error resolving name before it was set")
+	}
+
+	// resolvers that are not able to resolve at past blocks fail
+	_, err = api.Resolve(ctx, "swarm.test")
+	if err == nil {
+		t.Fatal("expected error resolving with a resolver that can not resolve at a block")
+	}
+	// the time of feeds does not change name resolution
+	addr, err = api.Resolve(WithAsOf(context.Background(), AsOf{Time: 1000}), "swarm.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.Hex() != latestHash[2:] {
+		t.Fatalf("expected latest address %s, got %s", latestHash[2:], addr.Hex())
+	}
+}
+
 func TestDecryptOriginForbidden(t *testing.T) {
 	ctx := context.TODO()
 	ctx = sctx.SetHost(ctx, "swarm-gateways.net")
@@ -645,3 +1040,277 @@ func putString(ctx context.Context, a *API, content string, contentType string,
 		return waitManifest(ctx)
 	}, nil
 }
+
+// TestApiGetMountedFeed tests that a feed mounted at a path of a manifest
+// resolves the rest of the path in the latest update of the feed, while the
+// other entries of the manifest stay immutable
+func TestApiGetMountedFeed(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "bzz-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	tags := chunk.NewTags()
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	feeds, err := feed.NewTestHandler(datadir, &feed.HandlerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer feeds.Close()
+	api := NewAPI(fileStore, nil, nil, feeds.Handler, nil, tags)
+	ctx := context.TODO()
+
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := feed.NewGenericSigner(privKey)
+	topic, _ := feed.NewTopic("blog", nil)
+	fd := &feed.Feed{Topic: topic, User: signer.Address()}
+
+	store := func(data string) storage.Address {
+		addr, wait, err := api.Store(ctx, strings.NewReader(data), int64(len(data)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	// publish stores a manifest with a single post as the latest update of the feed
+	publish := func(request *feed.Request, post string) {
+		manifestAddr := store(fmt.Sprintf(`{"entries":[{"hash":"%v","path":"post","contentType":"text/plain"}]}`, store(post)))
+		request.SetData(manifestAddr)
+		if err := request.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := api.FeedsUpdate(ctx, request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publish(feed.NewFirstRequest(topic), "first post")
+
+	siteAddr, err := api.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siteAddr, err = api.UpdateManifest(ctx, siteAddr, func(mw *ManifestWriter) error {
+		for _, path := range []string{"index.html", "docs/readme"} {
+			if _, err := mw.AddEntry(ctx, strings.NewReader(path), &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(len(path))}); err != nil {
+				return err
+			}
+		}
+		if err := mw.AddFeed("blog", fd); err != nil {
+			return err
+		}
+		return mw.AddFeed("docs/news", fd)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(post string) {
+		for _, path := range []string{"index.html", "docs/readme"} {
+			checkResponse(t, testGet(t, api, siteAddr.Hex(), path), expResponse(path, "text/plain", 0))
+		}
+		for _, path := range []string{"blog/post", "/docs/news/post"} {
+			checkResponse(t, testGet(t, api, siteAddr.Hex(), path), expResponse(post, "text/plain", 0))
+		}
+		for _, path := range []string{"blogpost", "blog/missing"} {
+			if _, _, status, _, err := api.Get(ctx, NOOPDecrypt, siteAddr, path); err == nil || status != http.StatusNotFound {
+				t.Fatalf("expected path %q not to be found, got status %d, error %v", path, status, err)
+			}
+		}
+	}
+	check("first post")
+
+	request, err := api.FeedsNewRequest(ctx, fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(request, "second post")
+	check("second post")
+}
+
+// TestApiGetFeedManifestSubpath tests that the paths below a feed manifest
+// resolve in the manifest of the latest update, traversing nested manifests,
+// and that the paths missing in the update are not found
+func TestApiGetFeedManifestSubpath(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "bzz-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+	tags := chunk.NewTags()
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	feeds, err := feed.NewTestHandler(datadir, &feed.HandlerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer feeds.Close()
+	api := NewAPI(fileStore, nil, nil, feeds.Handler, nil, tags)
+	ctx := context.TODO()
+
+	// addFiles stores a manifest with an entry of each path, its content being the path
+	addFiles := func(paths ...string) storage.Address {
+		addr, err := api.NewManifest(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			for _, path := range paths {
+				if _, err := mw.AddEntry(ctx, strings.NewReader(path), &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(len(path))}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	nestedAddr := addFiles("deep/file")
+	siteAddr := addFiles("index.html", "path/to/file")
+	siteAddr, err = api.UpdateManifest(ctx, siteAddr, func(mw *ManifestWriter) error {
+		_, err := mw.AddEntry(ctx, nil, &ManifestEntry{Path: "nested/", Hash: nestedAddr.Hex(), ContentType: ManifestType})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := feed.NewGenericSigner(privKey)
+	topic, _ := feed.NewTopic("site", nil)
+	request := feed.NewFirstRequest(topic)
+	request.SetData(siteAddr)
+	if err := request.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.FeedsUpdate(ctx, request); err != nil {
+		t.Fatal(err)
+	}
+	feedManifestAddr, err := api.NewFeedManifest(ctx, &request.Feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"index.html", "path/to/file", "/path//to/file", "nested/deep/file"} {
+		expected := strings.TrimPrefix(RegularSlashes(path), "nested/")
+		checkResponse(t, testGet(t, api, feedManifestAddr.Hex(), path), expResponse(expected, "text/plain", 0))
+	}
+	for _, path := range []string{"path/to/missing", "nested/missing", "nested/deep/file/more", "missing"} {
+		if _, _, status, _, err := api.Get(ctx, NOOPDecrypt, feedManifestAddr, path); err == nil || status != http.StatusNotFound {
+			t.Fatalf("expected path %q not to be found, got status %d, error %v", path, status, err)
+		}
+	}
+}
+
+// TestVerifyManifest tests that missing and corrupt chunks of the content
+// of a manifest are reported with the paths of their entries
+func TestVerifyManifest(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		datadir, err := ioutil.TempDir("", "bzz-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(datadir)
+		// the chunks are not validated on put, so that they can be corrupted
+		store, err := localstore.New(datadir, make([]byte, 32), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		tags := chunk.NewTags()
+		api := NewAPI(storage.NewFileStore(store, store, storage.NewFileStoreParams(), tags), nil, nil, nil, nil, tags)
+
+		ctx := context.TODO()
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		large := testutil.RandomBytes(1, 3*chunk.DefaultSize+100)
+		files := map[string][]byte{
+			"index.html": []byte("<h1>swarm</h1>"),
+			"dir/large":  large,
+			"dir/small":  []byte("small"),
+		}
+		refs := make(map[string]storage.Address)
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			for path, data := range files {
+				ref, err := mw.AddEntry(ctx, bytes.NewReader(data), &ManifestEntry{
+					Path:        path,
+					ContentType: "text/plain",
+					Size:        int64(len(data)),
+				})
+				if err != nil {
+					return err
+				}
+				refs[path] = ref
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := api.VerifyManifest(ctx, NOOPDecrypt, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Problems) != 0 {
+			t.Fatalf("expected no problems, got %+v", report.Problems)
+		}
+		// the large file has 4 data chunks and its root chunk
+		if report.Entries < len(files) || report.Chunks < 7+1 {
+			t.Fatalf("expected all entries and chunks to be verified, got %d entries and %d chunks", report.Entries, report.Chunks)
+		}
+
+		missing := refs["dir/small"][:storage.AddressLength]
+		if err := store.Set(ctx, chunk.ModeSetRemove, missing); err != nil {
+			t.Fatal(err)
+		}
+		corrupt := refs["dir/large"][:storage.AddressLength]
+		if err := store.Set(ctx, chunk.ModeSetRemove, corrupt); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Put(ctx, chunk.ModePutUpload, storage.NewChunk(corrupt, append(make([]byte, 8), "corrupt"...))); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err = api.VerifyManifest(ctx, NOOPDecrypt, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Problems) != 2 {
+			t.Fatalf("expected 2 problems, got %+v", report.Problems)
+		}
+		for _, p := range report.Problems {
+			switch p.Path {
+			case "dir/small":
+				if p.Chunk != missing.Hex() {
+					t.Fatalf("expected missing chunk %s, got %s", missing.Hex(), p.Chunk)
+				}
+			case "dir/large":
+				if p.Chunk != corrupt.Hex() || p.Err != storage.ErrChunkCorrupt.Error() {
+					t.Fatalf("expected corrupt chunk %s, got %+v", corrupt.Hex(), p)
+				}
+			default:
+				t.Fatalf("unexpected problem %+v", p)
+			}
+		}
+	}
+}
@@ -45,6 +45,8 @@ import (
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+	"github.com/ethersphere/swarm/storage/pin"
 	"github.com/pborman/uuid"
 )
 
@@ -64,8 +66,12 @@ func NewClient(gateway string) *Client {
 
 // Client wraps interaction with a swarm HTTP gateway.
 type Client struct {
-	Gateway    string
-	httpClient *http.Client
+	Gateway      string
+	httpClient   *http.Client
+	ctx          context.Context // context of the requests, see WithContext
+	retries      int             // number of retries of failed requests, see SetRetries
+	retryBackoff time.Duration   // wait before the first retry
+	retryAll     bool            // requests with non-idempotent methods are retried too, see SetRetryNonIdempotent
 }
 
 // UploadRaw uploads raw data to swarm and returns the resulting hash. If toEncrypt is true it
@@ -90,7 +96,7 @@ func (c *Client) UploadRaw(r io.Reader, size int64, toEncrypt, toPin, anonymous
 		req.Header.Set(swarmhttp.PinHeaderName, "true")
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -108,8 +114,11 @@ func (c *Client) UploadRaw(r io.Reader, size int64, toEncrypt, toPin, anonymous
 // DownloadRaw downloads raw data from swarm and it returns a ReadCloser and a bool whether the
 // content was encrypted
 func (c *Client) DownloadRaw(hash string) (io.ReadCloser, bool, error) {
-	uri := c.Gateway + "/bzz-raw:/" + hash
-	res, err := c.httpClient.Get(uri)
+	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz-raw:/"+hash, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	res, err := c.do(req)
 	if err != nil {
 		return nil, false, err
 	}
@@ -121,6 +130,52 @@ func (c *Client) DownloadRaw(hash string) (io.ReadCloser, bool, error) {
 	return res.Body, isEncrypted, nil
 }
 
+// Exists reports whether the content with the given hash is retrievable, checking
+// its chunk tree down to depth levels below the root chunk, the whole tree if
+// depth is negative, without downloading it
+func (c *Client) Exists(hash string, depth int) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, fmt.Sprintf("%s/bzz-raw:/%s?depth=%d", c.Gateway, hash, depth), nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+}
+
+// DownloadRange downloads length bytes from offset of the file with the given path
+// from the swarm manifest with the given hash, the rest of the file if length is
+// not positive. The returned ReadCloser streams the content as it is retrieved.
+func (c *Client) DownloadRange(hash, path string, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz:/"+hash+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent && !(res.StatusCode == http.StatusOK && offset == 0) {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return res.Body, nil
+}
+
 // File represents a file in a swarm manifest and is used for uploading and
 // downloading content to and from swarm
 type File struct {
@@ -173,8 +228,11 @@ func (c *Client) Upload(file *File, manifest string, toEncrypt, toPin, anonymous
 // Download downloads a file with the given path from the swarm manifest with
 // the given hash (i.e. it gets bzz:/<hash>/<path>)
 func (c *Client) Download(hash, path string) (*File, error) {
-	uri := c.Gateway + "/bzz:/" + hash + "/" + path
-	res, err := c.httpClient.Get(uri)
+	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz:/"+hash+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -234,7 +292,7 @@ func (c *Client) DownloadDirectory(hash, path, destDir, credentials string) erro
 		req.SetBasicAuth("", credentials)
 	}
 	req.Header.Set("Accept", "application/x-tar")
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -319,7 +377,7 @@ func (c *Client) DownloadFile(hash, path, dest, credentials string) error {
 	if credentials != "" {
 		req.SetBasicAuth("", credentials)
 	}
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -418,7 +476,7 @@ func (c *Client) List(hash, prefix, credentials string) (*api.ManifestList, erro
 	if credentials != "" {
 		req.SetBasicAuth("", credentials)
 	}
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -437,6 +495,54 @@ func (c *Client) List(hash, prefix, credentials string) (*api.ManifestList, erro
 	return &list, nil
 }
 
+// VerifyManifest retrieves all chunks of the swarm manifest with the given hash
+// and of the content of its entries and returns the report of the missing and
+// corrupt ones
+func (c *Client) VerifyManifest(hash, credentials string) (*api.VerifyReport, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz-verify:/"+hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if credentials != "" {
+		req.SetBasicAuth("", credentials)
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var report api.VerifyReport
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// RemoveEntry removes the entry with the given path from the swarm manifest
+// with the given hash and returns the hash of the resulting manifest
+func (c *Client) RemoveEntry(hash, path string) (string, error) {
+	req, err := http.NewRequest(http.MethodDelete, c.Gateway+"/bzz:/"+hash+"/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // Uploader uploads files to swarm using a provided UploadFn
 type Uploader interface {
 	Upload(UploadFn) error
@@ -512,7 +618,7 @@ type UploadFn func(file *File) error
 // TarUpload uses the given Uploader to upload files to swarm as a tar stream,
 // returning the resulting manifest hash
 func (c *Client) TarUpload(hash string, uploader Uploader, defaultPath string, toEncrypt, toPin, anonymous bool) (string, error) {
-	ctx, sp := spancontext.StartSpan(context.Background(), "api.client.tarupload")
+	ctx, sp := spancontext.StartSpan(c.context(), "api.client.tarupload")
 	defer sp.Finish()
 
 	var tn time.Time
@@ -607,6 +713,44 @@ func (c *Client) TarUpload(hash string, uploader Uploader, defaultPath string, t
 	return string(data), nil
 }
 
+// UploadTar uploads the files of the tar stream read from r to the manifest
+// with the given hash, or to a new manifest if hash is empty, and returns the
+// resulting manifest hash. The stream is uploaded as it is read, so the request
+// is not retried.
+func (c *Client) UploadTar(r io.Reader, hash, defaultPath string, toEncrypt, toPin bool) (string, error) {
+	addr := hash
+	if hash == "" && toEncrypt {
+		addr = "encrypt"
+	}
+	req, err := http.NewRequest(http.MethodPost, c.Gateway+"/bzz:/"+addr, r)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	req.Header.Set(swarmhttp.TagHeaderName, fmt.Sprintf("tar_upload_%d", time.Now().Unix()))
+	if defaultPath != "" {
+		q := req.URL.Query()
+		q.Set("defaultpath", defaultPath)
+		req.URL.RawQuery = q.Encode()
+	}
+	if toPin {
+		req.Header.Set(swarmhttp.PinHeaderName, "true")
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // MultipartUpload uses the given Uploader to upload files to swarm as a
 // multipart form, returning the resulting manifest hash
 func (c *Client) MultipartUpload(hash string, uploader Uploader, toPin, anonymous bool) (string, error) {
@@ -652,7 +796,7 @@ func (c *Client) MultipartUpload(hash string, uploader Uploader, toPin, anonymou
 		reqW.CloseWithError(err)
 	}()
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return "", err
 	}
@@ -675,7 +819,7 @@ func (c *Client) TagByHash(hash string) (*chunk.Tag, error) {
 		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -694,6 +838,91 @@ func (c *Client) TagByHash(hash string) (*chunk.Tag, error) {
 	return tag, err
 }
 
+// Pin pins the content with the given hash in the local store of the node, the
+// file or collection of a manifest unless raw is true
+func (c *Client) Pin(hash string, raw bool) error {
+	req, err := http.NewRequest(http.MethodPost, c.Gateway+"/bzz-pin:/"+hash, nil)
+	if err != nil {
+		return err
+	}
+	if raw {
+		q := req.URL.Query()
+		q.Set("raw", "true")
+		req.URL.RawQuery = q.Encode()
+	}
+	return c.doPin(req)
+}
+
+// Unpin unpins the content with the given hash in the local store of the node
+func (c *Client) Unpin(hash string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.Gateway+"/bzz-pin:/"+hash, nil)
+	if err != nil {
+		return err
+	}
+	return c.doPin(req)
+}
+
+func (c *Client) doPin(req *http.Request) error {
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	return nil
+}
+
+// Pins returns the content pinned in the local store of the node
+func (c *Client) Pins() ([]pin.PinInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.Gateway+"/bzz-pin:/", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	var pins []pin.PinInfo
+	if err := json.NewDecoder(res.Body).Decode(&pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+// RemoveUpload removes the chunks of the upload with the given hash from the local store
+// of the node, except those that are pinned or belong to other uploads, and returns the
+// counts of the chunks. The hash is of a collection manifest unless raw is true
+func (c *Client) RemoveUpload(hash string, raw bool) (*pin.RemoveStats, error) {
+	req, err := http.NewRequest(http.MethodDelete, c.Gateway+"/bzz-tag:/"+hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if raw {
+		q := req.URL.Query()
+		q.Set("raw", "true")
+		req.URL.RawQuery = q.Encode()
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
+	stats := &pin.RemoveStats{}
+	if err := json.NewDecoder(res.Body).Decode(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
 // ErrNoFeedUpdatesFound is returned when Swarm cannot find updates of the given feed
 var ErrNoFeedUpdatesFound = errors.New("No updates found for this feed")
 
@@ -744,10 +973,14 @@ func (c *Client) updateFeed(request *feed.Request, createManifest bool) (io.Read
 		return nil, err
 	}
 
-	res, err := c.httpClient.Do(req)
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status: %s", res.Status)
+	}
 
 	return res.Body, nil
 }
@@ -777,7 +1010,11 @@ func (c *Client) queryFeed(query *feed.Query, manifestAddressOrDomain string, me
 		values.Set("meta", "1")
 	}
 	URL.RawQuery = values.Encode()
-	res, err := http.Get(URL.String())
+	req, err := http.NewRequest(http.MethodGet, URL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -822,6 +1059,34 @@ func (c *Client) GetFeedRequest(query *feed.Query, manifestAddressOrDomain strin
 	return &metadata, nil
 }
 
+// PublishFeed publishes data as the next update of the feed with the given
+// topic of the signer, creating the feed with it if there are no updates yet
+func (c *Client) PublishFeed(signer feed.Signer, topic feed.Topic, data []byte) error {
+	fd := &feed.Feed{Topic: topic, User: signer.Address()}
+	request, err := c.GetFeedRequest(feed.NewQueryLatest(fd, lookup.NoClue), "")
+	if err == ErrNoFeedUpdatesFound {
+		request = feed.NewFirstRequest(topic)
+	} else if err != nil {
+		return err
+	}
+	request.SetData(data)
+	if err := request.Sign(signer); err != nil {
+		return err
+	}
+	return c.UpdateFeed(request)
+}
+
+// FeedLatest returns the content of the latest update of the feed,
+// ErrNoFeedUpdatesFound if it has no updates
+func (c *Client) FeedLatest(fd *feed.Feed) ([]byte, error) {
+	reader, err := c.QueryFeed(feed.NewQueryLatest(fd, lookup.NoClue), "")
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
 func GetClientTrace(traceMsg, metricPrefix, ruid string, tn *time.Time) *httptrace.ClientTrace {
 	trace := &httptrace.ClientTrace{
 		GetConn: func(_ string) {
@@ -17,13 +17,20 @@
 package client
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
+	"encoding/hex"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -648,3 +655,237 @@ func TestClientCreateUpdateFeed(t *testing.T) {
 		t.Fatalf("Expected: %v, got %v", databytes, gotData)
 	}
 }
+
+// TestClientRetries tests that failed requests are retried
+// with the configured number of retries, the requests with
+// non-idempotent methods only if enabled
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte("bar"))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.SetRetries(2, time.Millisecond)
+	reader, _, err := client.DownloadRaw("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "bar" {
+		t.Fatalf("expected %q, got %q", "bar", data)
+	}
+
+	// uploads are posted, which is not retried by default
+	atomic.StoreInt32(&calls, 0)
+	if _, err := client.UploadRaw(bytes.NewReader([]byte("foo")), 3, false, false, false); err == nil {
+		t.Fatal("expected error for a post without retries")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 call, got %d", n)
+	}
+
+	client.SetRetryNonIdempotent(true)
+	atomic.StoreInt32(&calls, 0)
+	client.SetRetries(1, time.Millisecond)
+	if _, err := client.UploadRaw(bytes.NewReader([]byte("foo")), 3, false, false, false); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	client.SetRetries(2, time.Millisecond)
+	hash, err := client.UploadRaw(bytes.NewReader([]byte("foo")), 3, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "foo" {
+		t.Fatalf("expected request body to be resent, got %q", hash)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 calls, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.WithContext(ctx).UploadRaw(bytes.NewReader([]byte("foo")), 3, false, false, false); err == nil {
+		t.Fatal("expected error with cancelled context")
+	}
+}
+
+// TestClientManifestOperations tests range downloads, tar uploads
+// and removal of manifest entries
+func TestClientManifestOperations(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	files := map[string]string{
+		"index.html": "<h1>swarm</h1>",
+		"data/a.txt": "0123456789",
+	}
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.UploadTar(buf, "", "index.html", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 4, "0123"},
+		{3, 4, "3456"},
+		{6, 0, "6789"},
+	} {
+		r, err := client.DownloadRange(hash, "data/a.txt", tc.offset, tc.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Fatalf("range %d+%d: expected %q, got %q", tc.offset, tc.length, tc.want, got)
+		}
+	}
+
+	newHash, err := client.RemoveEntry(hash, "data/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newHash == hash {
+		t.Fatal("expected a new manifest hash")
+	}
+	list, err := client.List(newHash, "data/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Entries) != 0 || len(list.CommonPrefixes) != 0 {
+		t.Fatalf("expected removed entry not to be listed, got %+v", list)
+	}
+	file, err := client.Download(newHash, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != files["index.html"] {
+		t.Fatalf("expected default path content %q, got %q", files["index.html"], got)
+	}
+}
+
+// TestClientPinning tests pinning, listing and unpinning of content
+func TestClientPinning(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	hash, err := client.UploadRaw(bytes.NewReader(testutil.RandomBytes(1, 10000)), 10000, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Pin(hash, true); err != nil {
+		t.Fatal(err)
+	}
+	pins, err := client.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || hex.EncodeToString(pins[0].Address) != hash || !pins[0].IsRaw {
+		t.Fatalf("unexpected pins %+v", pins)
+	}
+	if err := client.Unpin(hash); err != nil {
+		t.Fatal(err)
+	}
+	pins, err = client.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatalf("expected no pins, got %+v", pins)
+	}
+}
+
+// TestClientPublishFeed tests publishing feed updates
+// and retrieving the latest one
+func TestClientPublishFeed(t *testing.T) {
+	signer, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	topic, _ := feed.NewTopic("publish", nil)
+	fd := &feed.Feed{Topic: topic, User: signer.Address()}
+	if _, err := client.FeedLatest(fd); err != ErrNoFeedUpdatesFound {
+		t.Fatalf("expected ErrNoFeedUpdatesFound, got %v", err)
+	}
+
+	for _, data := range []string{"first", "second"} {
+		if err := client.PublishFeed(signer, topic, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := client.FeedLatest(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Fatalf("expected %q, got %q", data, got)
+		}
+	}
+}
+
+// TestClientVerifyManifest tests verifying an intact manifest
+func TestClientVerifyManifest(t *testing.T) {
+	srv := swarmhttp.NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+	client := NewClient(srv.URL)
+
+	dir := newTestDirectory(t)
+	defer os.RemoveAll(dir)
+	hash, err := client.UploadDirectory(dir, "", "", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := client.VerifyManifest(hash, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Root != hash || len(report.Problems) != 0 || report.Entries == 0 || report.Chunks == 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
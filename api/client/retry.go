@@ -0,0 +1,115 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// WithContext returns a copy of the client whose requests are made with ctx,
+// so that they are cancelled with it
+func (c *Client) WithContext(ctx context.Context) *Client {
+	cc := *c
+	cc.ctx = ctx
+	return &cc
+}
+
+// SetRetries sets the number of times a request is retried after a network
+// error or a server error response. The first retry is made after backoff,
+// which is doubled for each further retry. Only requests with idempotent
+// methods are retried, unless set otherwise by SetRetryNonIdempotent. Requests
+// with a body that cannot be replayed, like streamed uploads, are never retried.
+func (c *Client) SetRetries(retries int, backoff time.Duration) {
+	c.retries = retries
+	c.retryBackoff = backoff
+}
+
+// SetRetryNonIdempotent sets whether the requests with non-idempotent methods,
+// like the POST requests of uploads, are retried as well. A request that failed
+// may still have been processed by the server, so retrying it may repeat its
+// effects, like publishing a feed update or creating a tag twice.
+func (c *Client) SetRetryNonIdempotent(retry bool) {
+	c.retryAll = retry
+}
+
+// context returns the context the requests of the client are made with
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// do sends the request with the context of the client, retrying it as set by SetRetries
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := c.context()
+	if req.Context() == context.Background() {
+		req = req.WithContext(ctx)
+	}
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.httpClient.Do(req)
+		if attempt >= c.retries || !c.retriable(req, res, err) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retriable tells whether the request can be sent again after the response or error
+func (c *Client) retriable(req *http.Request, res *http.Response, err error) bool {
+	if !c.retryAll && !idempotent(req.Method) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if req.Context().Err() != nil {
+		return false
+	}
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+// idempotent tells whether sending a request with the method more than once
+// has the same effect as sending it once, see RFC 7231 section 4.2.2
+func idempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -29,7 +30,10 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/contracts/ens"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/retrieval"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/bridge"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/swap"
 )
@@ -62,6 +66,11 @@ type Config struct {
 	SwapLogLevel            int            // log level of swap related audit logs
 	Contract                common.Address // address of the chequebook contract
 	SwapChequebookFactory   common.Address // address of the chequebook factory contract
+	SwapBeneficiary         common.Address // address cheques for this node are issued to, the owner address if empty
+	SwapFreeChunks          int64          // chunks retrieved from and by every peer per window without accounting
+	SwapFreeBytes           int64          // bytes retrieved from and by every peer per window without accounting
+	SwapFreeWindow          time.Duration  // window of the free retrieval quota
+	SwapConfirmations       uint64         // blocks after which swap transactions are considered final
 	// end of Swap configs
 
 	*network.HiveParams
@@ -78,14 +87,50 @@ type Config struct {
 	NetworkID          uint64
 	SyncEnabled        bool
 	PushSyncEnabled    bool
+	PushSyncReplicas   int // nodes of the neighbourhood storing a push-synced chunk, the closest one included, explicit replication is disabled if less than two
 	LightNodeEnabled   bool
+	RetrieveRelay      bool // light node relays the retrieve requests of its peers, full nodes always do
 	BootnodeMode       bool
 	DisableAutoConnect bool
 	EnablePinning      bool
+	GatewayMode        bool             // read-only node, all write paths of the API are disabled
+	EnableAPIKeys      bool             // HTTP requests require an API key and are subject to its quota
+	WebSocketPss       bool             // pss messages can be received over the /bzz-ws WebSocket endpoint of the HTTP gateway
+	PssMailbox         bool             // pss messages for offline recipients can be deposited in mailboxes of this node
+	PssMailboxOwners   []common.Address // owners of the mailboxes the messages deposited for this node are fetched from
+	PssBridge          *bridge.Config   // whisper topics relayed to pss topics and back, the bridge is disabled without routes
 	Cors               string
 	BzzAccount         string
 	GlobalStoreAPI     string
-	privateKey         *ecdsa.PrivateKey
+	SyncUpdateDelay    time.Duration              // time the syncing server waits for more chunks before it offers an incomplete batch
+	SyncBandwidth      int64                      // bytes of chunk data per second delivered to syncing peers at most, 0 is unlimited
+	RetrieveBandwidth  int64                      // bytes of chunk data per second delivered for retrieve requests at most, 0 is unlimited
+	RetrieveMaxHops    uint8                      // number of times a retrieve request is forwarded at most
+	RetrieveByLatency  bool                       // retrieve requests are forwarded to the lowest latency peer among the equally close ones
+	RetrieveFarFetched retrieval.FarFetchedPolicy // how retrieve requests for chunks far outside the neighbourhood are treated
+	RetrieveFarSlack   int                        // proximity orders outside the neighbourhood depth retrieve requests can be far fetched from
+	LogVmodule         string                     // per-module log verbosity as in the vmodule flag, e.g. "network/*=4,swap=5"
+	ManifestInlineSize int64                      // content of uploaded files up to this size is inlined in their manifest entries, 0 disables it
+	CloneSource        string                     // enode URL of the node whose local store is imported, clone mode is disabled if empty
+	CloneBins          []uint8                    // proximity order bins imported from the clone source, all of them if empty
+	CloneServe         []string                   // enode URLs of the nodes allowed to clone the local store, clone streams are not served if empty
+	// repair of pinned and uploaded content missing from its neighbourhood, disabled if the interval is zero
+	PinRepairInterval     time.Duration // time between repair rounds
+	PinRepairSampleSize   int           // number of chunks of each root probed in a round
+	PinRepairProbeTimeout time.Duration // time to wait for a probed chunk to be delivered
+	// checkpointing of the latest manifests of swarmfs mounts, disabled if both are zero
+	SwarmFSCheckpointInterval time.Duration // save the changed manifests at this interval
+	SwarmFSCheckpointBytes    int64         // save the manifest of a mount once this many bytes were written
+	SwarmFSTrash              bool          // move files removed on swarmfs mounts under .trash/ in the manifest
+	SwarmFSUmask              os.FileMode   // masked from the permissions of swarmfs entries without mode in the manifest and of directories
+	// reading ahead of the reads on swarmfs mounts, the swarmfs defaults are used if both are zero
+	SwarmFSReadahead     int   // bytes read from a file for a smaller read request
+	SwarmFSReadCacheSize int64 // limit of the bytes read ahead and kept in memory for a mount
+	// retrievals priced per chunk, settled with swap
+	RetrieveChunkPrice     uint64 // price in honey of the chunks delivered for retrieve requests priced per chunk, swap.DefaultChunkPrice if zero
+	RetrieveFreeNeighbours bool   // chunks are delivered free of charge to the peers in the neighbourhood
+	RetrieveMaxChunkPrice  uint64 // the most paid for a retrieved chunk, retrievals are priced per message if zero
+	privateKey             *ecdsa.PrivateKey
 }
 
 //NewConfig creates a default config with all parameters to set to defaults
@@ -100,8 +145,11 @@ func NewConfig() *Config {
 		SwapDisconnectThreshold: swap.DefaultDisconnectThreshold,
 		SwapLogPath:             "",
 		SwapLogLevel:            swap.DefaultSwapLogLevel,
+		SwapFreeWindow:          time.Hour,
+		SwapConfirmations:       swap.DefaultTransactionConfirmations,
 		HiveParams:              network.NewHiveParams(),
 		Pss:                     pss.NewParams(),
+		PssBridge:               bridge.NewConfig(),
 		EnsRoot:                 ens.Address,
 		EnsAPIs:                 nil,
 		RnsAPI:                  "",
@@ -111,7 +159,14 @@ func NewConfig() *Config {
 		NetworkID:               network.DefaultNetworkID,
 		SyncEnabled:             true,
 		PushSyncEnabled:         true,
+		SyncUpdateDelay:         timeouts.BatchTimeout,
+		RetrieveMaxHops:         retrieval.DefaultMaxHops,
+		RetrieveFarSlack:        retrieval.DefaultFarFetchedSlack,
 		EnablePinning:           false,
+		PinRepairInterval:       6 * time.Hour,
+		PinRepairSampleSize:     16,
+		PinRepairProbeTimeout:   10 * time.Second,
+		SwarmFSUmask:            0077,
 	}
 }
 
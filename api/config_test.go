@@ -17,10 +17,14 @@
 package api
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/storage/localstore"
 )
 
 func TestConfig(t *testing.T) {
@@ -63,3 +67,67 @@ func TestConfig(t *testing.T) {
 		t.Fatal("Failed to correctly initialize StoreParams")
 	}
 }
+
+func TestConfigValidate(t *testing.T) {
+	if err := NewConfig().Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+
+	// port 0 lets the node listen on an ephemeral port
+	c := NewConfig()
+	c.Port = "0"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("ephemeral port: %v", err)
+	}
+
+	c = NewConfig()
+	c.DbCapacity = localstore.MinCapacity - 1
+	c.CacheCapacity = 100
+	c.ChunkerProfile = "unknown"
+	c.ManifestInlineSize = MaxManifestInlineSize + 1
+	c.Port = "http"
+	c.ListenAddr = "127.0.0.1:8500"
+	c.Cors = "*, localhost"
+	c.SwapPaymentThreshold = c.SwapDisconnectThreshold
+	c.PinRepairSampleSize = 0
+	c.Pss.AddressHintMinBits = c.Pss.AddressHintMaxBits + 1
+	err := c.Validate()
+	cerr, ok := err.(ConfigError)
+	if !ok {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	var fields []string
+	for _, p := range cerr {
+		fields = append(fields, strings.SplitN(p, ":", 2)[0])
+	}
+	want := []string{"DbCapacity", "CacheCapacity", "ChunkerProfile", "ManifestInlineSize", "Port", "ListenAddr", "Cors", "Pss.AddressHintMinBits", "SwapPaymentThreshold", "PinRepairSampleSize"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected problems with %v, got %v", want, err)
+	}
+
+	// the gateway settings require the gateway and the path must be a directory
+	f, err := ioutil.TempFile("", "swarm-config-validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+	c = NewConfig()
+	c.Path = f.Name()
+	c.Port = ""
+	c.EnableAPIKeys = true
+	c.WebSocketPss = true
+	err = c.Validate()
+	cerr, ok = err.(ConfigError)
+	if !ok {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	fields = nil
+	for _, p := range cerr {
+		fields = append(fields, strings.SplitN(p, ":", 2)[0])
+	}
+	want = []string{"Path", "EnableAPIKeys", "WebSocketPss"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("expected problems with %v, got %v", want, err)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+	"github.com/ethersphere/swarm/swap"
+)
+
+// ConfigError lists the problems found validating a Config, one per invalid field
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	if len(e) == 1 {
+		return "invalid configuration: " + e[0]
+	}
+	return fmt.Sprintf("invalid configuration, %d problems:\n  %s", len(e), strings.Join(e, "\n  "))
+}
+
+// Validate checks the settings of all subsystems in the config, whether they
+// come from the defaults of NewConfig, the TOML config file or the flags, which
+// cmd/swarm applies in this order, and returns a ConfigError listing every
+// invalid field with the reason, or nil if the config is valid
+// Zero values are valid wherever the subsystem treats them as disabled or as
+// its default, so only values the node cannot run with are reported.
+func (c *Config) Validate() error {
+	var errs ConfigError
+	problem := func(field string, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	// storage
+	if fi, err := os.Stat(c.Path); c.Path != "" && err == nil && !fi.IsDir() {
+		problem("Path", "%q is not a directory", c.Path)
+	}
+	if c.DbCapacity != 0 && c.DbCapacity < localstore.MinCapacity {
+		problem("DbCapacity", "must be 0 for the default or at least %d chunks", localstore.MinCapacity)
+	}
+	if c.CacheCapacity != 0 {
+		problem("CacheCapacity", "is not used, the local store has no separate cache, set DbCapacity instead")
+	}
+	if c.FileStoreParams != nil {
+		if c.Hash != "" && storage.MakeHashFunc(c.Hash) == nil {
+			problem("Hash", "unknown hash %q, expected SHA3, SHA256 or BMT", c.Hash)
+		}
+		if c.ChunkerProfile != "" {
+			if _, err := storage.ChunkerProfileByName(c.ChunkerProfile); err != nil {
+				problem("ChunkerProfile", "%v", err)
+			}
+		}
+	}
+	if c.ManifestInlineSize < 0 || c.ManifestInlineSize > MaxManifestInlineSize {
+		problem("ManifestInlineSize", "must be between 0 and %d", MaxManifestInlineSize)
+	}
+
+	// network and sync
+	if c.Port != "" {
+		if port, err := strconv.Atoi(c.Port); err != nil || port < 0 || port > 65535 {
+			problem("Port", "%q is not a port number", c.Port)
+		}
+	}
+	if c.ListenAddr != "" && net.ParseIP(c.ListenAddr) == nil && strings.ContainsAny(c.ListenAddr, ":/ ") {
+		problem("ListenAddr", "%q is not an IP address or host name", c.ListenAddr)
+	}
+	if c.Cors != "" {
+		for _, origin := range strings.Split(c.Cors, ",") {
+			if origin = strings.TrimSpace(origin); origin != "*" && !strings.Contains(origin, "://") {
+				problem("Cors", "invalid origin %q, expected * or scheme://host", origin)
+			}
+		}
+	}
+	if c.Port == "" {
+		if c.EnableAPIKeys {
+			problem("EnableAPIKeys", "requires the HTTP gateway, which is disabled without Port")
+		}
+		if c.WebSocketPss {
+			problem("WebSocketPss", "requires the HTTP gateway, which is disabled without Port")
+		}
+	}
+	if c.SyncUpdateDelay < 0 {
+		problem("SyncUpdateDelay", "must not be negative")
+	}
+	if c.SyncBandwidth < 0 {
+		problem("SyncBandwidth", "must not be negative")
+	}
+	if c.RetrieveBandwidth < 0 {
+		problem("RetrieveBandwidth", "must not be negative")
+	}
+	if c.PushSyncReplicas < 0 {
+		problem("PushSyncReplicas", "must not be negative")
+	}
+	if c.RetrieveFarSlack < 0 {
+		problem("RetrieveFarSlack", "must not be negative")
+	}
+	if c.HiveParams != nil {
+		for role, urls := range c.StaticPeers {
+			for _, url := range urls {
+				if _, err := enode.ParseV4(url); err != nil {
+					problem("StaticPeers", "invalid %s enode URL %q: %v", role, url, err)
+				}
+			}
+		}
+		for role, count := range c.StaticPeerTargets {
+			if count < 0 {
+				problem("StaticPeerTargets", "negative number of %s peers", role)
+			}
+		}
+	}
+	if c.CloneSource != "" {
+		if _, err := enode.ParseV4(c.CloneSource); err != nil {
+			problem("CloneSource", "invalid enode URL %q: %v", c.CloneSource, err)
+		}
+	}
+	for _, bin := range c.CloneBins {
+		if int(bin) > chunk.MaxPO {
+			problem("CloneBins", "invalid bin %d, expected 0 to %d", bin, chunk.MaxPO)
+		}
+	}
+	for _, url := range c.CloneServe {
+		if _, err := enode.ParseV4(url); err != nil {
+			problem("CloneServe", "invalid enode URL %q: %v", url, err)
+		}
+	}
+
+	// pss
+	if c.Pss != nil {
+		if c.Pss.MsgTTL < 0 {
+			problem("Pss.MsgTTL", "must not be negative")
+		}
+		if c.Pss.HandlerConcurrency < 0 {
+			problem("Pss.HandlerConcurrency", "must not be negative")
+		}
+		if c.Pss.AddressHintMaxBits > message.MaxHintBits {
+			problem("Pss.AddressHintMaxBits", "must be at most %d", message.MaxHintBits)
+		}
+		if c.Pss.AddressHintMinBits > c.Pss.AddressHintMaxBits {
+			problem("Pss.AddressHintMinBits", "must not be greater than AddressHintMaxBits %d", c.Pss.AddressHintMaxBits)
+		}
+	}
+	if c.PssBridge != nil {
+		for i, r := range c.PssBridge.Routes {
+			if err := r.Validate(); err != nil {
+				problem(fmt.Sprintf("PssBridge.Routes[%d]", i), "%v", err)
+			}
+		}
+		if len(c.PssBridge.Routes) > 0 && c.PssBridge.PollInterval <= 0 {
+			problem("PssBridge.PollInterval", "must be positive if there are routes")
+		}
+	}
+
+	// swap
+	if c.SwapEnabled && c.NetworkID != swap.AllowedNetworkID {
+		problem("SwapEnabled", "swap can only be enabled under network ID %d, found %d", swap.AllowedNetworkID, c.NetworkID)
+	}
+	if c.SwapDisconnectThreshold != 0 && c.SwapPaymentThreshold >= c.SwapDisconnectThreshold {
+		problem("SwapPaymentThreshold", "must be less than SwapDisconnectThreshold %d", c.SwapDisconnectThreshold)
+	}
+	if c.SwapFreeChunks < 0 {
+		problem("SwapFreeChunks", "must not be negative")
+	}
+	if c.SwapFreeBytes < 0 {
+		problem("SwapFreeBytes", "must not be negative")
+	}
+	if (c.SwapFreeChunks > 0 || c.SwapFreeBytes > 0) && c.SwapFreeWindow <= 0 {
+		problem("SwapFreeWindow", "must be positive if there is a free quota")
+	}
+
+	// logging
+	if c.LogVmodule != "" {
+		if err := log.NewGlogHandler(log.DiscardHandler()).Vmodule(c.LogVmodule); err != nil {
+			problem("LogVmodule", "%v", err)
+		}
+	}
+
+	// pinning
+	if c.PinRepairInterval < 0 {
+		problem("PinRepairInterval", "must not be negative")
+	}
+	if c.PinRepairInterval > 0 {
+		if c.PinRepairSampleSize <= 0 {
+			problem("PinRepairSampleSize", "must be positive if repair is enabled")
+		}
+		if c.PinRepairProbeTimeout <= 0 {
+			problem("PinRepairProbeTimeout", "must be positive if repair is enabled")
+		}
+	}
+
+	// swarmfs
+	if c.SwarmFSCheckpointInterval < 0 {
+		problem("SwarmFSCheckpointInterval", "must not be negative")
+	}
+	if c.SwarmFSCheckpointBytes < 0 {
+		problem("SwarmFSCheckpointBytes", "must not be negative")
+	}
+	if c.SwarmFSUmask&^0777 != 0 {
+		problem("SwarmFSUmask", "%#o has bits other than permissions", uint32(c.SwarmFSUmask))
+	}
+	if c.SwarmFSReadahead < 0 {
+		problem("SwarmFSReadahead", "must not be negative")
+	}
+	if c.SwarmFSReadCacheSize < 0 {
+		problem("SwarmFSReadCacheSize", "must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -24,6 +24,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -48,6 +50,26 @@ func NewFileSystem(api *API) *FileSystem {
 //
 // DEPRECATED: Use the HTTP API instead
 func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error) {
+	return fs.upload(lpath, index, toEncrypt, false)
+}
+
+// UploadDeterministic uploads a local directory like Upload, but normalizes the
+// manifest so that identical contents always produce the identical root hash,
+// regardless of file system iteration order, timestamps, modes and the mime
+// types configured on the host
+// Entries are added in the order of their paths without modification times and
+// modes, and content types are derived from a fixed extension table or sniffed
+// from the content. Content is not encrypted, as encryption keys are random.
+//
+// DEPRECATED: Use the HTTP API instead
+func (fs *FileSystem) UploadDeterministic(lpath, index string) (string, error) {
+	return fs.upload(lpath, index, false, true)
+}
+
+func (fs *FileSystem) upload(lpath, index string, toEncrypt, deterministic bool) (string, error) {
+	if err := fs.api.checkWritable(); err != nil {
+		return "", err
+	}
 	var list []*manifestTrieEntry
 	localpath, err := filepath.Abs(filepath.Clean(lpath))
 	if err != nil {
@@ -134,7 +156,11 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 				return
 			}
 
-			list[i].ContentType, err = DetectContentType(f.Name(), f)
+			if deterministic {
+				list[i].ContentType, err = detectContentTypeDeterministic(f.Name(), f)
+			} else {
+				list[i].ContentType, err = DetectContentType(f.Name(), f)
+			}
 			if err != nil {
 				errors[i] = err
 				return
@@ -146,15 +172,25 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 		sem <- true
 	}
 
-	trie := &manifestTrie{
-		fileStore: fs.api.fileStore,
-	}
-	quitC := make(chan bool)
 	for i, entry := range list {
 		if errors[i] != nil {
 			return "", errors[i]
 		}
 		entry.Path = RegularSlashes(entry.Path[start:])
+	}
+	if deterministic {
+		// the walk visits directory entries in the order of their names, which
+		// differs from the order of the full paths, e.g. for "a/b" and "a.b"
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Path < list[j].Path
+		})
+	}
+
+	trie := &manifestTrie{
+		fileStore: fs.api.fileStore,
+	}
+	quitC := make(chan bool)
+	for _, entry := range list {
 		if entry.Path == index {
 			ientry := newManifestTrieEntry(&ManifestEntry{
 				ContentType: entry.ContentType,
@@ -173,6 +209,36 @@ func (fs *FileSystem) Upload(lpath, index string, toEncrypt bool) (string, error
 	return hs, err2
 }
 
+// deterministicContentTypes maps file extensions to content types independently
+// of the mime types configured on the host
+var deterministicContentTypes = map[string]string{
+	".css":  "text/css; charset=utf-8",
+	".gif":  "image/gif",
+	".htm":  "text/html; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".js":   "application/javascript",
+	".json": "application/json",
+	".mjs":  "application/javascript",
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".wasm": "application/wasm",
+	".webp": "image/webp",
+	".xml":  "text/xml; charset=utf-8",
+}
+
+// detectContentTypeDeterministic returns the content type of a file from the
+// fixed extension table, or sniffs it from the content for other extensions
+func detectContentTypeDeterministic(fileName string, f io.ReadSeeker) (string, error) {
+	if ctype, ok := deterministicContentTypes[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return ctype, nil
+	}
+	// sniffing is used when the extension is not known, so an empty name skips the host table
+	return DetectContentType("", f)
+}
+
 // Download replicates the manifest basePath structure on the local filesystem
 // under localpath
 //
@@ -278,11 +344,11 @@ func retrieveToFile(quitC chan bool, fileStore *storage.FileStore, addr storage.
 	}
 	reader, _ := fileStore.Retrieve(context.TODO(), addr)
 	writer := bufio.NewWriter(f)
-	size, err := reader.Size(context.TODO(), quitC)
-	if err != nil {
+	if _, err := reader.Size(context.TODO(), quitC); err != nil {
 		return err
 	}
-	if _, err = io.CopyN(writer, reader, size); err != nil {
+	// the reader drains itself into the writer with read ahead
+	if _, err = io.Copy(writer, reader); err != nil {
 		return err
 	}
 	if err := writer.Flush(); err != nil {
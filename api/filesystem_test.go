@@ -23,6 +23,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/swarm/chunk"
@@ -201,3 +202,63 @@ func TestApiFileUploadWithRootFile(t *testing.T) {
 		checkResponse(t, resp, exp)
 	})
 }
+
+func TestApiDirUploadDeterministic(t *testing.T) {
+	files := map[string]string{
+		"index.html": "<html></html>",
+		"style.css":  "body {}",
+		"a.b":        "a dot b",
+		"a/b":        "a slash b",
+		"a/c/d.json": "{}",
+	}
+	// writeDir writes files to a new directory in the given order of their paths
+	writeDir := func(paths []string, modTime time.Time) string {
+		dir, err := ioutil.TempDir("", "bzz-deterministic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range paths {
+			file := filepath.Join(dir, filepath.FromSlash(p))
+			if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(file, []byte(files[p]), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	dir0 := writeDir([]string{"index.html", "style.css", "a.b", "a/b", "a/c/d.json"}, time.Unix(1000, 0))
+	defer os.RemoveAll(dir0)
+	dir1 := writeDir([]string{"a/c/d.json", "a/b", "a.b", "style.css", "index.html"}, time.Unix(2000, 0))
+	defer os.RemoveAll(dir1)
+	if err := os.Chmod(filepath.Join(dir1, "a.b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testFileSystem(t, func(fs *FileSystem, _ bool) {
+		hash0, err := fs.UploadDeterministic(dir0, "index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash1, err := fs.UploadDeterministic(dir1, "index.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash0 != hash1 {
+			t.Fatalf("got different hashes %s and %s for identical contents", hash0, hash1)
+		}
+
+		for path, ctype := range map[string]string{
+			"style.css":  "text/css; charset=utf-8",
+			"a/c/d.json": "application/json",
+			"a.b":        "text/plain; charset=utf-8",
+		} {
+			resp := testGet(t, fs.api, hash0, path)
+			checkResponse(t, resp, expResponse(files[path], ctype, 0))
+		}
+	})
+}
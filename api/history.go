@@ -0,0 +1,95 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/swarm/contracts/ens"
+)
+
+var (
+	_ HistoricalResolver = (*ens.ENS)(nil)
+	_ HistoricalResolver = (*MultiResolver)(nil)
+)
+
+// AsOf is a past state that names and feeds are resolved at, so that
+// content can be viewed as it was at that time.
+type AsOf struct {
+	Block *big.Int // block number at which names are resolved, latest if nil
+	Time  uint64   // unix time at which feeds are looked up, latest if zero
+}
+
+// asOfKey is the context key of the AsOf set by WithAsOf
+type asOfKey struct{}
+
+// WithAsOf returns a context that makes Resolve, ResolveAll, ResolveURI
+// and Get resolve names and feeds in the past state defined by asOf.
+func WithAsOf(ctx context.Context, asOf AsOf) context.Context {
+	return context.WithValue(ctx, asOfKey{}, asOf)
+}
+
+// AsOfFromContext returns the AsOf set by WithAsOf, or the
+// zero value, which resolves the latest state, if it is not set.
+func AsOfFromContext(ctx context.Context) AsOf {
+	asOf, _ := ctx.Value(asOfKey{}).(AsOf)
+	return asOf
+}
+
+// HistoricalResolver resolves names in the state of the chain at a block number.
+// ens.ENS and MultiResolver are HistoricalResolvers.
+type HistoricalResolver interface {
+	ResolveAt(ctx context.Context, name string, blockNumber *big.Int) (common.Hash, error)
+}
+
+// ResolveAt resolves the name like Resolve, but in the state of the chain
+// at the block number with the resolvers that are HistoricalResolvers.
+func (m *MultiResolver) ResolveAt(ctx context.Context, name string, blockNumber *big.Int) (h common.Hash, err error) {
+	_, rs, err := m.getResolveValidator(name)
+	if err != nil {
+		return h, err
+	}
+	err = fmt.Errorf("no ENS resolver can resolve %q at block %v", name, blockNumber)
+	for _, r := range rs {
+		hr, ok := r.(HistoricalResolver)
+		if !ok {
+			continue
+		}
+		h, err = hr.ResolveAt(ctx, name, blockNumber)
+		if err == nil {
+			return h, nil
+		}
+	}
+	return h, err
+}
+
+// resolveName resolves the name with the resolver, at the
+// block number of the AsOf of the context if it is set.
+func resolveName(ctx context.Context, r Resolver, name string) (common.Hash, error) {
+	blockNumber := AsOfFromContext(ctx).Block
+	if blockNumber == nil {
+		return r.Resolve(name)
+	}
+	hr, ok := r.(HistoricalResolver)
+	if !ok {
+		return common.Hash{}, fmt.Errorf("resolver can not resolve %q at block %v", name, blockNumber)
+	}
+	return hr.ResolveAt(ctx, name, blockNumber)
+}
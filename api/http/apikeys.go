@@ -0,0 +1,461 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/state"
+)
+
+// APIKeyHeaderName is the header carrying the API key of a request
+const APIKeyHeaderName = "x-swarm-api-key"
+
+const apiKeyPrefix = "key_"
+
+var (
+	// ErrUnknownAPIKey is returned if the API key of a request is missing or not known
+	ErrUnknownAPIKey = errors.New("unknown API key")
+	// ErrQuotaExceeded is returned if the daily upload or download quota of an API key is used up
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrRateLimited is returned if an API key made more requests than allowed in the current minute
+	ErrRateLimited = errors.New("request rate limit exceeded")
+
+	// quotaNow is the clock of the quota accounting, replaced in tests
+	quotaNow = time.Now
+)
+
+// Quota limits the use of the gateway by the holder of an API key
+// Zero values are unlimited
+type Quota struct {
+	UploadBytes       int64 // bytes uploaded per day
+	DownloadBytes     int64 // bytes downloaded per day
+	RequestsPerMinute int   // requests per minute
+}
+
+// APIKey is an API key with its quota and the usage of the current day
+// as persisted in the state store, the key itself is only stored hashed
+type APIKey struct {
+	Hash       string // hex encoded sha256 hash of the key
+	Name       string // name of the application using the key
+	Quota      Quota
+	Day        string // UTC date the usage is accounted for
+	Uploaded   int64  // bytes uploaded on Day
+	Downloaded int64  // bytes downloaded on Day
+}
+
+// reset resets the usage if the day changed
+func (k *APIKey) reset(t time.Time) {
+	if day := t.UTC().Format("2006-01-02"); k.Day != day {
+		k.Day = day
+		k.Uploaded = 0
+		k.Downloaded = 0
+	}
+}
+
+// remaining returns the number of bytes that can still be transferred today
+// given the daily limit and the usage, or -1 if the limit is not set
+func remaining(limit, used int64) int64 {
+	if limit == 0 {
+		return -1
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+// apiKeyState is an API key with its request rate window, which is not persisted
+type apiKeyState struct {
+	key      *APIKey
+	window   time.Time // start of the current request rate window
+	requests int       // requests made in the current window
+}
+
+// APIKeys is the registry of the API keys of a gateway serving multiple applications
+// The keys and their daily usage are persisted in the state store
+// It is also the RPC API managing the keys
+type APIKeys struct {
+	store state.Store
+	mtx   sync.Mutex
+	keys  map[string]*apiKeyState // loaded keys by hash
+}
+
+// NewAPIKeys creates the API key registry persisting the keys in the store
+func NewAPIKeys(store state.Store) *APIKeys {
+	return &APIKeys{
+		store: store,
+		keys:  make(map[string]*apiKeyState),
+	}
+}
+
+func hashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// Add creates an API key for the named application with the given quota
+// The returned key is not stored and cannot be retrieved later
+func (k *APIKeys) Add(name string, quota Quota) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	apiKey := &APIKey{
+		Hash:  hashAPIKey(key),
+		Name:  name,
+		Quota: quota,
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	if err := k.store.Put(apiKeyPrefix+apiKey.Hash, apiKey); err != nil {
+		return "", err
+	}
+	k.keys[apiKey.Hash] = &apiKeyState{key: apiKey}
+	return key, nil
+}
+
+// Remove revokes the API key with the given hash
+func (k *APIKeys) Remove(hash string) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	delete(k.keys, hash)
+	return k.store.Delete(apiKeyPrefix + hash)
+}
+
+// List returns all API keys with their usage
+func (k *APIKeys) List() ([]*APIKey, error) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+
+	var keys []*APIKey
+	err := k.store.Iterate(apiKeyPrefix, func(key, value []byte) (bool, error) {
+		hash := strings.TrimPrefix(string(key), apiKeyPrefix)
+		if s, ok := k.keys[hash]; ok {
+			apiKey := *s.key
+			keys = append(keys, &apiKey)
+			return false, nil
+		}
+		apiKey := new(APIKey)
+		if err := json.Unmarshal(value, apiKey); err != nil {
+			return true, err
+		}
+		keys = append(keys, apiKey)
+		return false, nil
+	})
+	return keys, err
+}
+
+// load returns the state of the API key with the given hash
+// the caller is expected to hold the lock
+func (k *APIKeys) load(hash string) (*apiKeyState, error) {
+	if s, ok := k.keys[hash]; ok {
+		return s, nil
+	}
+	apiKey := new(APIKey)
+	if err := k.store.Get(apiKeyPrefix+hash, apiKey); err != nil {
+		if err == state.ErrNotFound {
+			return nil, ErrUnknownAPIKey
+		}
+		return nil, err
+	}
+	s := &apiKeyState{key: apiKey}
+	k.keys[hash] = s
+	return s, nil
+}
+
+// begin checks that the API key can make a request and accounts for it in the
+// request rate, returning the number of bytes it can still upload or download
+// today, -1 meaning unlimited
+// Requests are rejected once the quota of the kind of transfer they make is used up
+func (k *APIKeys) begin(key string, upload bool) (hash string, remainingBytes int64, err error) {
+	if key == "" {
+		return "", 0, ErrUnknownAPIKey
+	}
+	hash = hashAPIKey(key)
+	t := quotaNow()
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	s, err := k.load(hash)
+	if err != nil {
+		return "", 0, err
+	}
+	q := s.key.Quota
+	if q.RequestsPerMinute > 0 {
+		if t.Sub(s.window) >= time.Minute {
+			s.window = t
+			s.requests = 0
+		}
+		if s.requests >= q.RequestsPerMinute {
+			return "", 0, ErrRateLimited
+		}
+		s.requests++
+	}
+	s.key.reset(t)
+	if upload {
+		remainingBytes = remaining(q.UploadBytes, s.key.Uploaded)
+	} else {
+		remainingBytes = remaining(q.DownloadBytes, s.key.Downloaded)
+	}
+	if remainingBytes == 0 {
+		return "", 0, ErrQuotaExceeded
+	}
+	return hash, remainingBytes, nil
+}
+
+// use adds n bytes transferred by a request to the usage of the API key, or returns
+// ErrQuotaExceeded if they exceed the quota, negative n gives back unused bytes
+// The usage is shared by the concurrent requests of the key, so that together they
+// stay within the quota, and it is persisted by settle
+func (k *APIKeys) use(hash string, upload bool, n int64) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	s, err := k.load(hash)
+	if err != nil {
+		return err
+	}
+	s.key.reset(quotaNow())
+	used, limit := &s.key.Downloaded, s.key.Quota.DownloadBytes
+	if upload {
+		used, limit = &s.key.Uploaded, s.key.Quota.UploadBytes
+	}
+	if n > 0 && limit > 0 && *used+n > limit {
+		return ErrQuotaExceeded
+	}
+	*used += n
+	if *used < 0 {
+		// the bytes were used on the previous day
+		*used = 0
+	}
+	return nil
+}
+
+// settle persists the usage of the API key once a request is served
+func (k *APIKeys) settle(hash string) error {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	s, ok := k.keys[hash]
+	if !ok {
+		// removed while the request was served
+		return nil
+	}
+	return k.store.Put(apiKeyPrefix+hash, s.key)
+}
+
+// quotaReader accounts for the bytes of the request body and fails once they
+// exceed the upload quota
+type quotaReader struct {
+	io.ReadCloser
+	keys *APIKeys
+	hash string
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if qerr := r.keys.use(r.hash, true, int64(n)); qerr != nil {
+			return n, qerr
+		}
+	}
+	return n, err
+}
+
+// quotaResponseWriter accounts for the bytes of the response and stops writing
+// once they exceed the download quota
+// The bytes of responses with a known length are accounted for before the
+// response is written, and responses exceeding the quota are rejected
+type quotaResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	keys        *APIKeys
+	hash        string
+	upload      bool  // the request uploads content, its response is not accounted for
+	wroteHeader bool  // the header is written
+	limited     bool  // the response is rejected as it exceeds the quota
+	reserved    int64 // bytes accounted for in advance, that are not written yet
+}
+
+func (w *quotaResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if !w.upload && status == http.StatusOK {
+		if size, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil && size > 0 {
+			if err := w.keys.use(w.hash, false, size); err != nil {
+				w.limited = true
+				metrics.GetOrRegisterCounter("api/http/apikey/limited", nil).Inc(1)
+				for _, name := range []string{"Content-Length", "Content-Encoding", "Content-Disposition", "Etag", "Last-Modified"} {
+					w.Header().Del(name)
+				}
+				respondAPIError(w.ResponseWriter, w.r, "Response exceeds the download quota", http.StatusTooManyRequests, err)
+				return
+			}
+			w.reserved = size
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *quotaResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.limited {
+		return 0, ErrQuotaExceeded
+	}
+	if !w.upload {
+		if n := int64(len(p)); n <= w.reserved {
+			w.reserved -= n
+		} else {
+			if err := w.keys.use(w.hash, false, n-w.reserved); err != nil {
+				return 0, err
+			}
+			w.reserved = 0
+		}
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// release gives back the bytes accounted for in advance that were not written
+func (w *quotaResponseWriter) release() {
+	if w.reserved > 0 {
+		w.keys.use(w.hash, false, -w.reserved)
+		w.reserved = 0
+	}
+}
+
+// Hijack lets the connections of guarded requests be upgraded to WebSocket
+// The bytes read from the connection count as uploaded and the bytes written
+// as downloaded, and the connection fails once they exceed the quota
+func (w *quotaResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	qc := &quotaConn{Conn: conn, keys: w.keys, hash: w.hash}
+	// the bytes buffered before the connection was hijacked are read first
+	buffered, err := rw.Reader.Peek(rw.Reader.Buffered())
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := w.keys.use(w.hash, true, int64(len(buffered))); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	r := io.MultiReader(bytes.NewReader(buffered), qc)
+	return qc, bufio.NewReadWriter(bufio.NewReader(r), bufio.NewWriter(qc)), nil
+}
+
+// quotaConn is a hijacked connection accounting for the bytes read and written
+type quotaConn struct {
+	net.Conn
+	keys *APIKeys
+	hash string
+}
+
+func (c *quotaConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		if qerr := c.keys.use(c.hash, true, int64(n)); qerr != nil {
+			return n, qerr
+		}
+	}
+	return n, err
+}
+
+func (c *quotaConn) Write(p []byte) (int, error) {
+	if err := c.keys.use(c.hash, false, int64(len(p))); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// APIKeyGuard is a middleware requiring the API key header on requests and enforcing
+// the quota of the key on the bytes uploaded in request bodies and downloaded in the
+// responses to GET requests, it lets all requests through if API keys are not enabled
+// The bytes are accounted for as they are transferred, so that concurrent requests
+// of a key stay within its quota together, and a transfer exceeding the quota fails
+// Responses of a known length exceeding the download quota are rejected before they
+// are written. The traffic of connections upgraded to WebSocket is accounted for too
+func APIKeyGuard(h http.Handler, getKeys func() *APIKeys) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := getKeys()
+		if keys == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// the content served by GET requests counts as downloaded, the body
+		// of all other requests as uploaded
+		upload := r.Method != http.MethodGet
+		hash, remainingBytes, err := keys.begin(r.Header.Get(APIKeyHeaderName), upload)
+		switch err {
+		case nil:
+		case ErrUnknownAPIKey:
+			metrics.GetOrRegisterCounter("api/http/apikey/unknown", nil).Inc(1)
+			respondAPIError(w, r, "A valid API key is required", http.StatusUnauthorized, err)
+			return
+		case ErrQuotaExceeded, ErrRateLimited:
+			metrics.GetOrRegisterCounter("api/http/apikey/limited", nil).Inc(1)
+			respondAPIError(w, r, err.Error(), http.StatusTooManyRequests, err)
+			return
+		default:
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
+			return
+		}
+		if upload && remainingBytes >= 0 && r.ContentLength > remainingBytes {
+			metrics.GetOrRegisterCounter("api/http/apikey/limited", nil).Inc(1)
+			respondAPIError(w, r, "Request exceeds the upload quota", http.StatusTooManyRequests, ErrQuotaExceeded)
+			return
+		}
+
+		if upload && r.Body != nil {
+			r.Body = &quotaReader{ReadCloser: r.Body, keys: keys, hash: hash}
+		}
+		writer := &quotaResponseWriter{ResponseWriter: w, r: r, keys: keys, hash: hash, upload: upload}
+		h.ServeHTTP(writer, r)
+		writer.release()
+
+		if err := keys.settle(hash); err != nil {
+			log.Error("accounting API key usage", "ruid", GetRUID(r.Context()), "err", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ethersphere/swarm/api"
+)
+
+// ErrorCode is a stable identifier of the kind of failure of a request,
+// so that API clients can handle errors without parsing messages
+type ErrorCode string
+
+// Error codes of the error responses
+const (
+	ErrCodeBadRequest       ErrorCode = "bad_request"
+	ErrCodeInvalidURI       ErrorCode = "invalid_uri"
+	ErrCodeUnauthorized     ErrorCode = "unauthorized"
+	ErrCodeForbidden        ErrorCode = "forbidden"
+	ErrCodeNotFound         ErrorCode = "not_found"
+	ErrCodeManifestNotFound ErrorCode = "manifest_not_found"
+	ErrCodeNoResolver       ErrorCode = "no_resolver"
+	ErrCodeMultihashInvalid ErrorCode = "multihash_invalid"
+	ErrCodeDecrypt          ErrorCode = "decryption_failed"
+	ErrCodeTooLarge         ErrorCode = "request_too_large"
+	ErrCodeUnprocessable    ErrorCode = "unprocessable_entity"
+	ErrCodeReadOnly         ErrorCode = "read_only"
+	ErrCodeQuotaExceeded    ErrorCode = "quota_exceeded"
+	ErrCodeInternal         ErrorCode = "internal_error"
+)
+
+// ErrorResponse is the body of error responses to clients that do not accept HTML
+type ErrorResponse struct {
+	Code      ErrorCode `json:"code"`              // stable error code
+	Status    int       `json:"status"`            // HTTP status of the response
+	Message   string    `json:"message"`           // human readable message
+	Details   string    `json:"details,omitempty"` // error the request failed with, if any
+	RequestID string    `json:"request_id"`        // ruid of the request, as logged by the node
+}
+
+// errorCode maps the error a request failed with to an error code, falling back
+// to the code of the HTTP status if the error is not of a known type
+func errorCode(err error, status int) ErrorCode {
+	if err != nil {
+		var noResolverErr *api.NoResolverError
+		var manifestErr *api.ManifestNotFoundError
+		var hashErr *api.InvalidHashError
+		switch {
+		case err == api.ErrReadOnly:
+			return ErrCodeReadOnly
+		case errors.As(err, &noResolverErr):
+			return ErrCodeNoResolver
+		case errors.As(err, &hashErr):
+			return ErrCodeMultihashInvalid
+		case isDecryptError(err):
+			return ErrCodeDecrypt
+		case errors.As(err, &manifestErr), err == api.ErrCannotLoadFeedManifest, err == api.ErrNotAFeedManifest:
+			return ErrCodeManifestNotFound
+		}
+	}
+	return statusErrorCode(status)
+}
+
+// statusErrorCode returns the generic error code of an HTTP status
+func statusErrorCode(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodeTooLarge
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrCodeQuotaExceeded
+	}
+	if status >= 400 && status < 500 {
+		return ErrCodeBadRequest
+	}
+	return ErrCodeInternal
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"fmt"
+	"math/big"
 	"net/http"
 	"runtime/debug"
 	"strconv"
@@ -14,8 +15,8 @@ import (
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/pin"
-	"github.com/pborman/uuid"
 )
 
 // Adapt chains h (main request handler) main handler to adapters (middleware handlers)
@@ -34,7 +35,7 @@ type Adapter func(http.Handler) http.Handler
 // as a unique identifier and injects it into the request context
 func SetRequestID(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r = r.WithContext(SetRUID(r.Context(), uuid.New()[:8]))
+		r = r.WithContext(SetRUID(r.Context(), sctx.NewRequestID()))
 		metrics.GetOrRegisterCounter(fmt.Sprintf("http/request/%s", r.Method), nil).Inc(1)
 		log.Info("created ruid for request", "ruid", GetRUID(r.Context()), "method", r.Method, "url", r.RequestURI)
 
@@ -59,8 +60,7 @@ func ParseURI(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		uri, err := api.Parse(strings.TrimLeft(r.URL.Path, "/"))
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			respondError(w, r, fmt.Sprintf("invalid URI %q", r.URL.Path), http.StatusBadRequest)
+			respondAPIError(w, r, fmt.Sprintf("invalid URI %q", r.URL.Path), http.StatusBadRequest, err)
 			return
 		}
 		if uri.Addr != "" && strings.HasPrefix(uri.Addr, "0x") {
@@ -102,7 +102,7 @@ func InitLoggingResponseWriter(h http.Handler) http.Handler {
 // when the Content-Length header is set, an ETA on chunking will be available since the
 // number of chunks to be split is known in advance (not including enclosing manifest chunks)
 // the tag can later be accessed using the appropriate identifier in the request context
-func InitUploadTag(h http.Handler, tags *chunk.Tags) http.Handler {
+func InitUploadTag(h http.Handler, tags *chunk.Tags, chunkSize int64) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var (
 			tagName        string
@@ -125,9 +125,9 @@ func InitUploadTag(h http.Handler, tags *chunk.Tags) http.Handler {
 			if uri != nil {
 				log.Debug("got uri from context")
 				if uri.Addr == encryptAddr {
-					estimatedTotal = calculateNumberOfChunks(r.ContentLength, true)
+					estimatedTotal = calculateNumberOfChunks(r.ContentLength, chunkSize, true)
 				} else {
-					estimatedTotal = calculateNumberOfChunks(r.ContentLength, false)
+					estimatedTotal = calculateNumberOfChunks(r.ContentLength, chunkSize, false)
 				}
 			}
 		}
@@ -162,6 +162,65 @@ func InstrumentOpenTracing(h http.Handler) http.Handler {
 	})
 }
 
+// ReadOnlyGuard is a middleware rejecting the requests to write paths
+// if the API runs in gateway mode, before the request body is read
+func ReadOnlyGuard(h http.Handler, a *api.API) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.ReadOnly() {
+			respondAPIError(w, r, "Write operations are disabled on this read-only node", http.StatusForbidden, api.ErrReadOnly)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SetAsOf is a middleware that sets the past state in which names and feeds
+// are resolved from the block and time query parameters, where block is the
+// block number for ENS names and time is the unix time for feed updates
+func SetAsOf(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var asOf api.AsOf
+		query := r.URL.Query()
+		if v := query.Get("block"); v != "" {
+			block, ok := new(big.Int).SetString(v, 10)
+			if !ok || block.Sign() < 0 {
+				respondError(w, r, fmt.Sprintf("invalid block number %q", v), http.StatusBadRequest)
+				return
+			}
+			asOf.Block = block
+		}
+		if v := query.Get("time"); v != "" {
+			t, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				respondError(w, r, fmt.Sprintf("invalid time %q", v), http.StatusBadRequest)
+				return
+			}
+			asOf.Time = t
+		}
+		if asOf.Block != nil || asOf.Time != 0 {
+			r = r.WithContext(api.WithAsOf(r.Context(), asOf))
+			log.Debug("resolving as of", "ruid", GetRUID(r.Context()), "block", asOf.Block, "time", asOf.Time)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// SetRefresh is a middleware that makes the chunks of the content which recently
+// failed to be fetched requested from the network again if the request has the
+// Cache-Control: no-cache header, e.g. when a user reloads a page
+func SetRefresh(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+			if strings.TrimSpace(strings.ToLower(directive)) == "no-cache" {
+				r = r.WithContext(storage.WithRefresh(r.Context()))
+				log.Debug("refreshing failed fetches", "ruid", GetRUID(r.Context()))
+				break
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
 // PinningEnabledPassthrough allows a request through the middleware in the following cases:
 // 1. checkHeader = true;		api != nil;	header PinHeaderName = true (x-swarm-pin: true) // header is set (hence api use is needed) and api not nil
 // 2. checkHeader = false;	api != nil																									// api not nil (don't care about header)
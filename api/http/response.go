@@ -79,8 +79,38 @@ func respondTemplate(w http.ResponseWriter, r *http.Request, templateName, msg s
 }
 
 func respondError(w http.ResponseWriter, r *http.Request, msg string, code int) {
-	log.Info("respondError", "ruid", GetRUID(r.Context()), "uri", GetURI(r.Context()), "code", code, "msg", msg)
-	respondTemplate(w, r, "error", msg, code)
+	respondAPIError(w, r, msg, code, nil)
+}
+
+// respondAPIError responds with an HTML error page to browsers and with an ErrorResponse
+// JSON document to all other clients, the error code of which is derived from err
+// if it is of a known type or from the HTTP status otherwise
+func respondAPIError(w http.ResponseWriter, r *http.Request, msg string, status int, err error) {
+	code := errorCode(err, status)
+	log.Info("respondError", "ruid", GetRUID(r.Context()), "uri", GetURI(r.Context()), "status", status, "code", code, "msg", msg)
+	acceptHeader := r.Header.Get("Accept")
+	if !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
+		respondTemplate(w, r, "error", msg, status)
+		return
+	}
+
+	resp := &ErrorResponse{
+		Code:      code,
+		Status:    status,
+		Message:   msg,
+		RequestID: GetRUID(r.Context()),
+	}
+	if err != nil && err.Error() != msg {
+		resp.Details = err.Error()
+	}
+	jsonCounter.Inc(1)
+	w.Header().Del("Cache-Control")
+	w.Header().Del("ETag")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error("error encoding error response", "ruid", resp.RequestID, "err", err)
+	}
 }
 
 func respond(w http.ResponseWriter, r *http.Request, params *ResponseParams) {
@@ -18,11 +18,14 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
 
+	"github.com/ethersphere/swarm/api"
 	"golang.org/x/net/html"
 )
 
@@ -168,3 +171,61 @@ func isJSON(s string) bool {
 	var js map[string]interface{}
 	return json.Unmarshal([]byte(s), &js) == nil
 }
+
+// TestErrorResponse tests that clients which do not accept HTML get an error
+// response with the error code mapped from the error the request failed with
+func TestErrorResponse(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/bzz:/1234567890123456789012345678901234567890123456789012345678901234/")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Invalid Status Code received, expected 404, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Invalid Content-Type received, expected application/json, got %s", ct)
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatal(err)
+	}
+	if errResp.Code != ErrCodeManifestNotFound {
+		t.Fatalf("Invalid error code received, expected %s, got %s", ErrCodeManifestNotFound, errResp.Code)
+	}
+	if errResp.Status != http.StatusNotFound {
+		t.Fatalf("Invalid status received, expected 404, got %d", errResp.Status)
+	}
+	if errResp.Message == "" {
+		t.Fatal("Expected an error message")
+	}
+	if errResp.RequestID == "" {
+		t.Fatal("Expected a request id")
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		want   ErrorCode
+	}{
+		{nil, http.StatusBadRequest, ErrCodeBadRequest},
+		{nil, http.StatusNotFound, ErrCodeNotFound},
+		{nil, http.StatusInternalServerError, ErrCodeInternal},
+		{errors.New("unknown"), http.StatusUnauthorized, ErrCodeUnauthorized},
+		{api.NewNoResolverError("eth"), http.StatusNotFound, ErrCodeNoResolver},
+		{&api.ManifestNotFoundError{Err: errors.New("not found")}, http.StatusNotFound, ErrCodeManifestNotFound},
+		{api.ErrCannotLoadFeedManifest, http.StatusNotFound, ErrCodeManifestNotFound},
+		{&api.InvalidHashError{Msg: "invalid"}, http.StatusUnprocessableEntity, ErrCodeMultihashInvalid},
+		{fmt.Errorf("get: %w", api.ErrDecrypt), http.StatusUnauthorized, ErrCodeDecrypt},
+	} {
+		if got := errorCode(tc.err, tc.status); got != tc.want {
+			t.Errorf("error %v with status %d: got code %s, want %s", tc.err, tc.status, got, tc.want)
+		}
+	}
+}
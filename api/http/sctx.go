@@ -10,15 +10,14 @@ import (
 type uriKey struct{}
 
 func GetRUID(ctx context.Context) string {
-	v, ok := ctx.Value(sctx.HTTPRequestIDKey{}).(string)
-	if ok {
+	if v := sctx.GetRequestID(ctx); v != "" {
 		return v
 	}
 	return "xxxxxxxx"
 }
 
 func SetRUID(ctx context.Context, ruid string) context.Context {
-	return context.WithValue(ctx, sctx.HTTPRequestIDKey{}, ruid)
+	return sctx.SetRequestID(ctx, ruid)
 }
 
 func GetURI(ctx context.Context) *api.URI {
@@ -29,6 +29,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -38,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/api/http/langos"
 	"github.com/ethersphere/swarm/chunk"
@@ -67,18 +69,31 @@ var (
 	getTagCount     = metrics.NewRegisteredCounter("api/http/get/tag/count", nil)
 	getTagNotFound  = metrics.NewRegisteredCounter("api/http/get/tag/notfound", nil)
 	getTagFail      = metrics.NewRegisteredCounter("api/http/get/tag/fail", nil)
+	deleteTagCount  = metrics.NewRegisteredCounter("api/http/delete/tag/count", nil)
+	deleteTagFail   = metrics.NewRegisteredCounter("api/http/delete/tag/fail", nil)
 	getPinCount     = metrics.NewRegisteredCounter("api/http/get/pin/count", nil)
 	getPinFail      = metrics.NewRegisteredCounter("api/http/get/pin/fail", nil)
 	postPinCount    = metrics.NewRegisteredCounter("api/http/post/pin/count", nil)
 	postPinFail     = metrics.NewRegisteredCounter("api/http/post/pin/fail", nil)
 	deletePinCount  = metrics.NewRegisteredCounter("api/http/delete/pin/count", nil)
 	deletePinFail   = metrics.NewRegisteredCounter("api/http/delete/pin/fail", nil)
+	getVerifyCount  = metrics.NewRegisteredCounter("api/http/get/verify/count", nil)
+	getVerifyFail   = metrics.NewRegisteredCounter("api/http/get/verify/fail", nil)
+	headCount       = metrics.NewRegisteredCounter("api/http/head/count", nil)
+	headNotFound    = metrics.NewRegisteredCounter("api/http/head/notfound", nil)
+	headFail        = metrics.NewRegisteredCounter("api/http/head/fail", nil)
 )
 
 const (
-	TagHeaderName       = "x-swarm-tag"       // Presence of this in header indicates the tag
-	AnonymousHeaderName = "x-swarm-anonymous" // Presence of this in header indicates only pull sync should be used for upload
-	PinHeaderName       = "x-swarm-pin"       // Presence of this in header indicates pinning required
+	TagHeaderName       = "x-swarm-tag"        // Presence of this in header indicates the tag
+	AnonymousHeaderName = "x-swarm-anonymous"  // Presence of this in header indicates only pull sync should be used for upload
+	PinHeaderName       = "x-swarm-pin"        // Presence of this in header indicates pinning required
+	PrefetchHeaderName  = "x-swarm-prefetch"   // Comma separated paths set as the prefetch list of the uploaded manifest
+	MasterKeyHeaderName = "x-swarm-master-key" // Hex encoded master key encrypting the references of the uploaded entries
+
+	StoredHeaderName = "x-swarm-chunks-stored" // Number of chunks of the upload newly stored
+	SeenHeaderName   = "x-swarm-chunks-seen"   // Number of chunks of the upload already present locally
+	SyncedHeaderName = "x-swarm-chunks-synced" // Number of chunks of the upload confirmed synced by receipts
 
 	encryptAddr    = "encrypt"
 	tarContentType = "application/x-tar"
@@ -102,12 +117,28 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 	}
 	c := cors.New(cors.Options{
 		AllowedOrigins: allowedOrigins,
-		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodDelete, http.MethodPatch, http.MethodPut},
+		AllowedMethods: []string{http.MethodPost, http.MethodGet, http.MethodDelete, http.MethodPatch, http.MethodPut, http.MethodHead},
 		MaxAge:         600,
 		AllowedHeaders: []string{"*"},
 	})
 
-	server := &Server{api: api, pinAPI: pinAPI}
+	server := &Server{
+		api:            api,
+		pinAPI:         pinAPI,
+		ws:             rpc.NewServer(),
+		transformers:   make(map[string]Transformer),
+		transformCache: newTransformCache(),
+		transformSlots: make(chan struct{}, maxTransforms),
+		feeds:          NewFeedSubscriptionAPI(api),
+	}
+	if err := server.ws.RegisterName("bzz", server.feeds); err != nil {
+		log.Error("Could not register the bzz WebSocket API", "err", err)
+	}
+
+	// requests are only checked against the API keys once they are enabled with SetAPIKeys
+	apiKeyAdapter := Adapter(func(h http.Handler) http.Handler {
+		return APIKeyGuard(h, server.getAPIKeys)
+	})
 
 	defaultMiddlewares := []Adapter{
 		RecoverPanic,
@@ -116,10 +147,11 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		InitLoggingResponseWriter,
 		ParseURI,
 		InstrumentOpenTracing,
+		apiKeyAdapter,
 	}
 
 	tagAdapter := Adapter(func(h http.Handler) http.Handler {
-		return InitUploadTag(h, api.Tags)
+		return InitUploadTag(h, api.Tags, api.ChunkSize())
 	})
 
 	pinAdapter := func(checkHeader bool) Adapter {
@@ -128,13 +160,26 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		})
 	}
 
-	defaultPostMiddlewares := append(defaultMiddlewares, tagAdapter)
+	// write paths are rejected centrally if the node runs in gateway mode
+	readOnlyAdapter := Adapter(func(h http.Handler) http.Handler {
+		return ReadOnlyGuard(h, api)
+	})
+
+	// content can be read as it was in the past with the block and time query parameters
+	// and missing content is searched for again with the Cache-Control: no-cache header
+	defaultReadMiddlewares := append(defaultMiddlewares, SetAsOf, SetRefresh)
+	defaultWriteMiddlewares := append(defaultMiddlewares, readOnlyAdapter)
+	defaultPostMiddlewares := append(defaultMiddlewares, readOnlyAdapter, tagAdapter)
 
 	mux := http.NewServeMux()
 	mux.Handle("/bzz:/", methodHandler{
 		"GET": Adapt(
 			http.HandlerFunc(server.HandleBzzGet),
-			defaultMiddlewares...,
+			defaultReadMiddlewares...,
+		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleHead),
+			defaultReadMiddlewares...,
 		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostFiles),
@@ -142,13 +187,17 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		),
 		"DELETE": Adapt(
 			http.HandlerFunc(server.HandleDelete),
-			defaultMiddlewares...,
+			defaultWriteMiddlewares...,
 		),
 	})
 	mux.Handle("/bzz-raw:/", methodHandler{
 		"GET": Adapt(
 			http.HandlerFunc(server.HandleGet),
-			defaultMiddlewares...,
+			defaultReadMiddlewares...,
+		),
+		"HEAD": Adapt(
+			http.HandlerFunc(server.HandleHead),
+			defaultReadMiddlewares...,
 		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostRaw),
@@ -158,19 +207,25 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 	mux.Handle("/bzz-immutable:/", methodHandler{
 		"GET": Adapt(
 			http.HandlerFunc(server.HandleBzzGet),
-			defaultMiddlewares...,
+			defaultReadMiddlewares...,
 		),
 	})
 	mux.Handle("/bzz-hash:/", methodHandler{
 		"GET": Adapt(
 			http.HandlerFunc(server.HandleGet),
-			defaultMiddlewares...,
+			defaultReadMiddlewares...,
 		),
 	})
 	mux.Handle("/bzz-list:/", methodHandler{
 		"GET": Adapt(
 			http.HandlerFunc(server.HandleGetList),
-			defaultMiddlewares...,
+			defaultReadMiddlewares...,
+		),
+	})
+	mux.Handle("/bzz-verify:/", methodHandler{
+		"GET": Adapt(
+			http.HandlerFunc(server.HandleVerify),
+			defaultReadMiddlewares...,
 		),
 	})
 	mux.Handle("/bzz-feed:/", methodHandler{
@@ -180,7 +235,7 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePostFeed),
-			defaultMiddlewares...,
+			defaultWriteMiddlewares...,
 		),
 	})
 	mux.Handle("/bzz-tag:/", methodHandler{
@@ -188,6 +243,10 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 			http.HandlerFunc(server.HandleGetTag),
 			defaultMiddlewares...,
 		),
+		"DELETE": Adapt(
+			http.HandlerFunc(server.HandleDeleteTag),
+			append(defaultWriteMiddlewares, pinAdapter(false))...,
+		),
 	})
 	mux.Handle("/bzz-feed-raw:/", methodHandler{
 		"GET": Adapt(
@@ -202,11 +261,21 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 		),
 		"POST": Adapt(
 			http.HandlerFunc(server.HandlePin),
-			append(defaultMiddlewares, pinAdapter(false))...,
+			append(defaultWriteMiddlewares, pinAdapter(false))...,
 		),
 		"DELETE": Adapt(
 			http.HandlerFunc(server.HandleUnpin),
-			append(defaultMiddlewares, pinAdapter(false))...,
+			append(defaultWriteMiddlewares, pinAdapter(false))...,
+		),
+	})
+	// the WebSocket handler hijacks the connection, the response writer
+	// must not be wrapped by logging or tracing middlewares
+	mux.Handle("/bzz-ws", methodHandler{
+		"GET": Adapt(
+			server.ws.WebsocketHandler(allowedOrigins),
+			RecoverPanic,
+			SetRequestID,
+			apiKeyAdapter,
 		),
 	})
 	mux.Handle("/", methodHandler{
@@ -221,6 +290,17 @@ func NewServer(api *api.API, pinAPI *pin.API, corsString string) *Server {
 	return server
 }
 
+// SetAPIKeys enables the API keys, requests to the bzz endpoints are rejected unless
+// they carry a known API key within its quota
+// It is expected to be called before the server starts serving requests
+func (s *Server) SetAPIKeys(keys *APIKeys) {
+	s.apiKeys = keys
+}
+
+func (s *Server) getAPIKeys() *APIKeys {
+	return s.apiKeys
+}
+
 func (s *Server) ListenAndServe(addr string) error {
 	s.listenAddr = addr
 	return http.ListenAndServe(addr, s)
@@ -232,9 +312,15 @@ func (s *Server) ListenAndServe(addr string) error {
 // https://github.com/atom/electron/blob/master/docs/api/protocol.md
 type Server struct {
 	http.Handler
-	api        *api.API
-	pinAPI     *pin.API
-	listenAddr string
+	api            *api.API
+	pinAPI         *pin.API
+	apiKeys        *APIKeys               // API keys with quotas, nil if not enabled
+	ws             *rpc.Server            // RPC server of the /bzz-ws WebSocket endpoint
+	feeds          *FeedSubscriptionAPI   // feed subscriptions of the /bzz-ws endpoint
+	transformers   map[string]Transformer // transformers by name, see RegisterTransformer
+	transformCache *transformCache
+	transformSlots chan struct{} // bounds the number of contents transformed at the same time
+	listenAddr     string
 }
 
 func (s *Server) HandleBzzGet(w http.ResponseWriter, r *http.Request) {
@@ -246,10 +332,10 @@ func (s *Server) HandleBzzGet(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			if isDecryptError(err) {
 				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", uri.Address().String()))
-				respondError(w, r, err.Error(), http.StatusUnauthorized)
+				respondAPIError(w, r, err.Error(), http.StatusUnauthorized, err)
 				return
 			}
-			respondError(w, r, fmt.Sprintf("Had an error building the tarball: %v", err), http.StatusInternalServerError)
+			respondAPIError(w, r, fmt.Sprintf("Had an error building the tarball: %v", err), http.StatusInternalServerError, err)
 			return
 		}
 		defer reader.Close()
@@ -330,7 +416,7 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 	addr, wait, err := s.api.Store(r.Context(), r.Body, r.ContentLength, toEncrypt)
 	if err != nil {
 		postRawFail.Inc(1)
-		respondError(w, r, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -352,7 +438,7 @@ func (s *Server) HandlePostRaw(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 
 	w.Header().Set(TagHeaderName, fmt.Sprint(tagUID))
-	w.Header().Set("Access-Control-Expose-Headers", TagHeaderName)
+	setUploadStatsHeaders(w, tag)
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, addr)
@@ -382,7 +468,7 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 	contentType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
 		postFilesFail.Inc(1)
-		respondError(w, r, err.Error(), http.StatusBadRequest)
+		respondAPIError(w, r, err.Error(), http.StatusBadRequest, err)
 		return
 	}
 
@@ -395,12 +481,22 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 	// Set the pinCounter if there is a pin header present in the request
 	headerPin := r.Header.Get(PinHeaderName)
 
+	var masterKey []byte
+	if key := r.Header.Get(MasterKeyHeaderName); key != "" {
+		masterKey, err = api.ParseMasterKey(key)
+		if err != nil {
+			postFilesFail.Inc(1)
+			respondAPIError(w, r, fmt.Sprintf("invalid master key: %v", err), http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	var addr storage.Address
 	if uri.Addr != "" && uri.Addr != encryptAddr {
 		addr, err = s.api.Resolve(r.Context(), uri.Addr)
 		if err != nil {
 			postFilesFail.Inc(1)
-			respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusInternalServerError)
+			respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusInternalServerError, err)
 			return
 		}
 		log.Debug("resolved key", "ruid", ruid, "key", addr)
@@ -408,17 +504,25 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 		addr, err = s.api.NewManifest(r.Context(), toEncrypt)
 		if err != nil {
 			postFilesFail.Inc(1)
-			respondError(w, r, err.Error(), http.StatusInternalServerError)
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 			return
 		}
 		log.Debug("new manifest", "ruid", ruid, "key", addr)
 	}
 	newAddr, err := s.api.UpdateManifest(r.Context(), addr, func(mw *api.ManifestWriter) error {
+		if prefetch := r.Header.Get(PrefetchHeaderName); prefetch != "" {
+			mw.SetPrefetch(api.ParsePrefetchList(prefetch))
+		}
+		if masterKey != nil {
+			if err := mw.SetMasterKey(masterKey); err != nil {
+				return err
+			}
+		}
 		switch contentType {
 		case tarContentType:
 			_, err := s.handleTarUpload(r, mw)
 			if err != nil {
-				respondError(w, r, fmt.Sprintf("error uploading tarball: %v", err), http.StatusInternalServerError)
+				respondAPIError(w, r, fmt.Sprintf("error uploading tarball: %v", err), http.StatusInternalServerError, err)
 				return err
 			}
 			return nil
@@ -431,7 +535,7 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 	})
 	if err != nil {
 		postFilesFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("cannot create manifest: %s", err), http.StatusInternalServerError)
+		respondAPIError(w, r, fmt.Sprintf("cannot create manifest: %s", err), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -458,12 +562,26 @@ func (s *Server) HandlePostFiles(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set(TagHeaderName, fmt.Sprint(tagUID))
-	w.Header().Set("Access-Control-Expose-Headers", TagHeaderName)
+	setUploadStatsHeaders(w, tag)
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, newAddr)
 }
 
+// setUploadStatsHeaders sets the deduplication statistics of the upload tracked by the tag
+// as response headers and exposes them together with the tag header
+func setUploadStatsHeaders(w http.ResponseWriter, tag *chunk.Tag) {
+	exposed := []string{TagHeaderName}
+	if tag != nil {
+		stats := storage.NewUploadStats(tag)
+		w.Header().Set(StoredHeaderName, strconv.FormatInt(stats.Stored, 10))
+		w.Header().Set(SeenHeaderName, strconv.FormatInt(stats.Seen, 10))
+		w.Header().Set(SyncedHeaderName, strconv.FormatInt(stats.Synced, 10))
+		exposed = append(exposed, StoredHeaderName, SeenHeaderName, SyncedHeaderName)
+	}
+	w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+}
+
 func (s *Server) handleTarUpload(r *http.Request, mw *api.ManifestWriter) (storage.Address, error) {
 	log.Debug("handle.tar.upload", "ruid", GetRUID(r.Context()), "tag", sctx.GetTag(r.Context()))
 
@@ -522,9 +640,11 @@ func (s *Server) handleMultipartUpload(r *http.Request, boundary string, mw *api
 		uri := GetURI(r.Context())
 		path := path.Join(uri.Path, name)
 		entry := &api.ManifestEntry{
-			Path:        path,
-			ContentType: part.Header.Get("Content-Type"),
-			Size:        size,
+			Path:            path,
+			ContentType:     part.Header.Get("Content-Type"),
+			Size:            size,
+			CacheControl:    part.Header.Get("Cache-Control"),
+			ContentEncoding: part.Header.Get("Content-Encoding"),
 		}
 		log.Debug("adding path to new manifest", "ruid", ruid, "bytes", entry.Size, "path", entry.Path)
 		contentKey, err := mw.AddEntry(r.Context(), reader, entry)
@@ -539,10 +659,11 @@ func (s *Server) handleDirectUpload(r *http.Request, mw *api.ManifestWriter) err
 	ruid := GetRUID(r.Context())
 	log.Debug("handle.direct.upload", "ruid", ruid)
 	key, err := mw.AddEntry(r.Context(), r.Body, &api.ManifestEntry{
-		Path:        GetURI(r.Context()).Path,
-		ContentType: r.Header.Get("Content-Type"),
-		Mode:        0644,
-		Size:        r.ContentLength,
+		Path:            GetURI(r.Context()).Path,
+		ContentType:     r.Header.Get("Content-Type"),
+		Mode:            0644,
+		Size:            r.ContentLength,
+		ContentEncoding: r.Header.Get("Content-Encoding"),
 	})
 	if err != nil {
 		return err
@@ -563,7 +684,7 @@ func (s *Server) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	newKey, err := s.api.Delete(r.Context(), uri.Addr, uri.Path)
 	if err != nil {
 		deleteFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("could not delete from manifest: %v", err), http.StatusInternalServerError)
+		respondAPIError(w, r, fmt.Sprintf("could not delete from manifest: %v", err), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -584,7 +705,7 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 	// Creation and update must send feed.updateRequestJSON JSON structure
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		respondError(w, r, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -595,7 +716,7 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 		if err == api.ErrCannotLoadFeedManifest || err == api.ErrCannotResolveFeedURI {
 			httpStatus = http.StatusNotFound
 		}
-		respondError(w, r, fmt.Sprintf("cannot retrieve feed from manifest: %s", err), httpStatus)
+		respondAPIError(w, r, fmt.Sprintf("cannot retrieve feed from manifest: %s", err), httpStatus, err)
 		return
 	}
 
@@ -604,7 +725,7 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
 	if err := updateRequest.FromValues(query, body); err != nil { // decodes request from query parameters
-		respondError(w, r, err.Error(), http.StatusBadRequest)
+		respondAPIError(w, r, err.Error(), http.StatusBadRequest, err)
 		return
 	}
 
@@ -614,12 +735,12 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 		// to update this feed
 		// Check this early, to avoid creating a feed and then not being able to set its first update.
 		if err = updateRequest.Verify(); err != nil {
-			respondError(w, r, err.Error(), http.StatusForbidden)
+			respondAPIError(w, r, err.Error(), http.StatusForbidden, err)
 			return
 		}
 		_, err = s.api.FeedsUpdate(r.Context(), &updateRequest)
 		if err != nil {
-			respondError(w, r, err.Error(), http.StatusInternalServerError)
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 			return
 		}
 		fallthrough
@@ -629,7 +750,7 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 		// feed identification used to retrieve feed updates later
 		m, err := s.api.NewFeedManifest(r.Context(), &updateRequest.Feed)
 		if err != nil {
-			respondError(w, r, fmt.Sprintf("failed to create feed manifest: %v", err), http.StatusInternalServerError)
+			respondAPIError(w, r, fmt.Sprintf("failed to create feed manifest: %v", err), http.StatusInternalServerError, err)
 			return
 		}
 		// the key to the manifest will be passed back to the client
@@ -637,7 +758,7 @@ func (s *Server) HandlePostFeed(w http.ResponseWriter, r *http.Request) {
 		// the manifest key can be set as content in the resolver of the ENS name
 		outdata, err := json.Marshal(m)
 		if err != nil {
-			respondError(w, r, fmt.Sprintf("failed to create json response: %s", err), http.StatusInternalServerError)
+			respondAPIError(w, r, fmt.Sprintf("failed to create json response: %s", err), http.StatusInternalServerError, err)
 			return
 		}
 		fmt.Fprint(w, string(outdata))
@@ -676,7 +797,7 @@ func (s *Server) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
 		if err == api.ErrCannotLoadFeedManifest || err == api.ErrCannotResolveFeedURI {
 			httpStatus = http.StatusNotFound
 		}
-		respondError(w, r, fmt.Sprintf("cannot retrieve feed information from manifest: %s", err), httpStatus)
+		respondAPIError(w, r, fmt.Sprintf("cannot retrieve feed information from manifest: %s", err), httpStatus, err)
 		return
 	}
 
@@ -685,12 +806,12 @@ func (s *Server) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
 		unsignedUpdateRequest, err := s.api.FeedsNewRequest(r.Context(), fd)
 		if err != nil {
 			getFail.Inc(1)
-			respondError(w, r, fmt.Sprintf("cannot retrieve feed metadata for feed=%s: %s", fd.Hex(), err), http.StatusNotFound)
+			respondAPIError(w, r, fmt.Sprintf("cannot retrieve feed metadata for feed=%s: %s", fd.Hex(), err), http.StatusNotFound, err)
 			return
 		}
 		rawResponse, err := unsignedUpdateRequest.MarshalJSON()
 		if err != nil {
-			respondError(w, r, fmt.Sprintf("cannot encode unsigned feed update request: %v", err), http.StatusInternalServerError)
+			respondAPIError(w, r, fmt.Sprintf("cannot encode unsigned feed update request: %v", err), http.StatusInternalServerError, err)
 			return
 		}
 		w.Header().Add("Content-type", "application/json")
@@ -701,7 +822,7 @@ func (s *Server) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
 
 	lookupParams := &feed.Query{Feed: *fd}
 	if err = lookupParams.FromValues(r.URL.Query()); err != nil { // parse period, version
-		respondError(w, r, fmt.Sprintf("invalid feed update request:%s", err), http.StatusBadRequest)
+		respondAPIError(w, r, fmt.Sprintf("invalid feed update request:%s", err), http.StatusBadRequest, err)
 		return
 	}
 
@@ -710,7 +831,7 @@ func (s *Server) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
 	// any error from the switch statement will end up here
 	if err != nil {
 		code, err2 := s.translateFeedError(w, r, "feed lookup fail", err)
-		respondError(w, r, err2.Error(), code)
+		respondAPIError(w, r, err2.Error(), code, err)
 		return
 	}
 
@@ -728,14 +849,14 @@ func (s *Server) HandleGetFeedRaw(w http.ResponseWriter, r *http.Request) {
 	ref, err := hexutil.Decode("0x" + uri.Addr)
 	if err != nil {
 		httpStatus := http.StatusBadRequest
-		respondError(w, r, fmt.Sprintf("chunk retrieval fail: %s", err), httpStatus)
+		respondAPIError(w, r, fmt.Sprintf("chunk retrieval fail: %s", err), httpStatus, err)
 		return
 	}
 
 	data, err := s.api.RetrieveFeedUpdate(r.Context(), ref)
 	if err != nil {
 		httpStatus := http.StatusNotFound
-		respondError(w, r, fmt.Sprintf("feed chunk not found: %s", err), httpStatus)
+		respondAPIError(w, r, fmt.Sprintf("feed chunk not found: %s", err), httpStatus, err)
 		return
 	}
 	w.Header().Set("Content-Type", api.MimeOctetStream)
@@ -779,7 +900,7 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 	addr, err := s.api.ResolveURI(r.Context(), uri, pass)
 	if err != nil {
 		getFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
+		respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound, err)
 		return
 	}
 	w.Header().Set("Cache-Control", "max-age=2147483648, immutable") // url was of type bzz://<hex key>/path, so we are sure it is immutable.
@@ -804,7 +925,7 @@ func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
 		reader, isEncrypted := s.api.Retrieve(r.Context(), addr)
 		if _, err := reader.Size(r.Context(), nil); err != nil {
 			getFail.Inc(1)
-			respondError(w, r, fmt.Sprintf("root chunk not found %s: %s", addr, err), http.StatusNotFound)
+			respondAPIError(w, r, fmt.Sprintf("root chunk not found %s: %s", addr, err), http.StatusNotFound, err)
 			return
 		}
 
@@ -843,14 +964,14 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 
 	// ensure the root path has a trailing slash so that relative URLs work
 	if uri.Path == "" && !strings.HasSuffix(r.URL.Path, "/") {
-		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		http.Redirect(w, r, rootPathURL(r.URL), http.StatusMovedPermanently)
 		return
 	}
 
 	addr, err := s.api.Resolve(r.Context(), uri.Addr)
 	if err != nil {
 		getListFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
+		respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound, err)
 		return
 	}
 	log.Debug("handle.get.list: resolved", "ruid", ruid, "key", addr)
@@ -860,10 +981,10 @@ func (s *Server) HandleGetList(w http.ResponseWriter, r *http.Request) {
 		getListFail.Inc(1)
 		if isDecryptError(err) {
 			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", addr.String()))
-			respondError(w, r, err.Error(), http.StatusUnauthorized)
+			respondAPIError(w, r, err.Error(), http.StatusUnauthorized, err)
 			return
 		}
-		respondError(w, r, err.Error(), http.StatusInternalServerError)
+		respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -901,7 +1022,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 
 	// ensure the root path has a trailing slash so that relative URLs work
 	if uri.Path == "" && !strings.HasSuffix(r.URL.Path, "/") {
-		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		http.Redirect(w, r, rootPathURL(r.URL), http.StatusMovedPermanently)
 		return
 	}
 	var err error
@@ -911,7 +1032,7 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 		manifestAddr, err = s.api.Resolve(r.Context(), uri.Addr)
 		if err != nil {
 			getFileFail.Inc(1)
-			respondError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound)
+			respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound, err)
 			return
 		}
 	} else {
@@ -920,7 +1041,13 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("handle.get.file: resolved", "ruid", ruid, "key", manifestAddr)
 
-	reader, contentType, status, contentKey, err := s.api.Get(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path)
+	// retrieve the entries likely needed together with the root while it is served
+	if uri.Path == "" {
+		s.api.PrefetchInBackground(r.Context(), credentials, manifestAddr)
+	}
+
+	reader, meta, status, err := s.api.GetWithMetadata(r.Context(), s.api.Decryptor(r.Context(), credentials), manifestAddr, uri.Path)
+	contentKey := meta.ContentAddr
 
 	etag := common.Bytes2Hex(contentKey)
 	noneMatchEtag := r.Header.Get("If-None-Match")
@@ -935,17 +1062,17 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if isDecryptError(err) {
 			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", manifestAddr))
-			respondError(w, r, err.Error(), http.StatusUnauthorized)
+			respondAPIError(w, r, err.Error(), http.StatusUnauthorized, err)
 			return
 		}
 
 		switch status {
 		case http.StatusNotFound:
 			getFileNotFound.Inc(1)
-			respondError(w, r, err.Error(), http.StatusNotFound)
+			respondAPIError(w, r, err.Error(), http.StatusNotFound, err)
 		default:
 			getFileFail.Inc(1)
-			respondError(w, r, err.Error(), http.StatusInternalServerError)
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -958,10 +1085,10 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 			getFileFail.Inc(1)
 			if isDecryptError(err) {
 				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", manifestAddr))
-				respondError(w, r, err.Error(), http.StatusUnauthorized)
+				respondAPIError(w, r, err.Error(), http.StatusUnauthorized, err)
 				return
 			}
-			respondError(w, r, err.Error(), http.StatusInternalServerError)
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
 			return
 		}
 
@@ -971,24 +1098,55 @@ func (s *Server) HandleGetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// check the root chunk exists by retrieving the file's size
-	if _, err := reader.Size(r.Context(), nil); err != nil {
-		getFileNotFound.Inc(1)
-		respondError(w, r, fmt.Sprintf("file not found %s: %s", uri, err), http.StatusNotFound)
-		return
-	}
-
-	if contentType != "" {
-		w.Header().Set("Content-Type", contentType)
+	if meta.ContentType != "" {
+		w.Header().Set("Content-Type", meta.ContentType)
 	}
+	setEntryHeaders(w, meta.Entry)
 
 	fileName := uri.Addr
 	if found := path.Base(uri.Path); found != "" && found != "." && found != "/" {
 		fileName = found
 	}
-	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", fileName))
+	if w.Header().Get("Content-Disposition") == "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", fileName))
+	}
+
+	modTime := meta.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if name := r.URL.Query().Get(TransformQueryParam); name != "" {
+		s.serveTransformed(w, r, name, contentKey, meta.ContentType, meta.Entry.ContentEncoding, reader, fileName, modTime, credentials)
+		return
+	}
+	http.ServeContent(w, r, fileName, modTime, langos.NewBufferedReadSeeker(reader, getFileBufferSize))
+}
 
-	http.ServeContent(w, r, fileName, time.Now(), langos.NewBufferedReadSeeker(reader, getFileBufferSize))
+// entryHeaders are the response headers the custom headers of a manifest entry
+// can set, other headers are ignored so that manifest authors cannot change the
+// security policies or redirects of the gateway origin
+var entryHeaders = map[string]bool{
+	"Cache-Control":       true,
+	"Content-Disposition": true,
+	"Content-Language":    true,
+	"Link":                true,
+}
+
+// setEntryHeaders sets the response headers defined in the metadata of a manifest entry
+func setEntryHeaders(w http.ResponseWriter, entry *api.ManifestEntry) {
+	for name, value := range entry.Headers {
+		name = http.CanonicalHeaderKey(name)
+		if !entryHeaders[name] {
+			continue
+		}
+		w.Header().Set(name, value)
+	}
+	if entry.CacheControl != "" {
+		w.Header().Set("Cache-Control", entry.CacheControl)
+	}
+	if entry.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", entry.ContentEncoding)
+	}
 }
 
 // HandleGetTag responds to the following request
@@ -1051,6 +1209,161 @@ func (s *Server) HandleGetTag(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleDeleteTag handles a DELETE request to bzz-tag:/<hash> or bzz-tag:/?Id=<uid> and
+// removes the chunks of the upload from the local store, keeping the chunks that are pinned
+// or that belong to other uploads, and responds with the JSON counts of the chunks
+// The upload is a collection unless the raw query parameter is true
+func (s *Server) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	deleteTagCount.Inc(1)
+	ruid := GetRUID(r.Context())
+	uri := GetURI(r.Context())
+	log.Debug("handle.delete.tag", "ruid", ruid, "uri", r.RequestURI)
+	if uri == nil {
+		deleteTagFail.Inc(1)
+		respondError(w, r, "Error decoding uri", http.StatusBadRequest)
+		return
+	}
+	isRaw := strings.ToLower(r.URL.Query().Get("raw")) == "true"
+
+	var stats pin.RemoveStats
+	var err error
+	if fileAddr := uri.Address(); fileAddr != nil {
+		stats, err = s.pinAPI.RemoveFiles(fileAddr, isRaw, "")
+	} else {
+		tagString := r.URL.Query().Get("Id")
+		if tagString == "" {
+			deleteTagFail.Inc(1)
+			respondError(w, r, "Missing one of the mandatory argument", http.StatusBadRequest)
+			return
+		}
+		u64, perr := strconv.ParseUint(tagString, 10, 32)
+		if perr != nil {
+			deleteTagFail.Inc(1)
+			respondError(w, r, "Invalid Id argument", http.StatusBadRequest)
+			return
+		}
+		if _, terr := s.api.Tags.Get(uint32(u64)); terr != nil {
+			deleteTagFail.Inc(1)
+			respondError(w, r, "Tag not found", http.StatusNotFound)
+			return
+		}
+		stats, err = s.pinAPI.RemoveTag(uint32(u64), isRaw, "")
+	}
+	if err != nil {
+		deleteTagFail.Inc(1)
+		respondAPIError(w, r, fmt.Sprintf("error removing upload: %s", err), http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Debug("removed upload", "ruid", ruid, "removed", stats.Removed, "pinned", stats.Pinned, "shared", stats.Shared, "neighbourhood", stats.Neighbourhood, "unreadable", stats.Unreadable)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&stats)
+}
+
+// HandleVerify handles a GET request to bzz-verify:/<manifest> and responds
+// with the JSON report of the missing and corrupt chunks of the manifest and
+// of the content of its entries
+func (s *Server) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	ruid := GetRUID(r.Context())
+	uri := GetURI(r.Context())
+	_, credentials, _ := r.BasicAuth()
+	log.Debug("handle.get.verify", "ruid", ruid, "uri", uri)
+	getVerifyCount.Inc(1)
+
+	addr, err := s.api.Resolve(r.Context(), uri.Addr)
+	if err != nil {
+		getVerifyFail.Inc(1)
+		respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound, err)
+		return
+	}
+
+	report, err := s.api.VerifyManifest(r.Context(), s.api.Decryptor(r.Context(), credentials), addr)
+	if err != nil {
+		getVerifyFail.Inc(1)
+		respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleHead answers whether the content of a bzz-raw or bzz URI is retrievable
+// without serving it, with 200 OK if it is and 404 Not Found if it is not
+// The depth query parameter is the number of levels of the chunk tree of the
+// content checked below its root chunk, the whole tree if it is negative and
+// only the root chunk by default. The manifest of bzz URIs is walked to the
+// entry at the path, whose content is checked.
+func (s *Server) HandleHead(w http.ResponseWriter, r *http.Request) {
+	ruid := GetRUID(r.Context())
+	uri := GetURI(r.Context())
+	_, credentials, _ := r.BasicAuth()
+	log.Debug("handle.head", "ruid", ruid, "uri", uri)
+	headCount.Inc(1)
+
+	var depth int
+	if d := r.URL.Query().Get("depth"); d != "" {
+		var err error
+		depth, err = strconv.Atoi(d)
+		if err != nil {
+			headFail.Inc(1)
+			respondError(w, r, fmt.Sprintf("invalid depth %q", d), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addr, err := s.api.Resolve(r.Context(), uri.Addr)
+	if err != nil {
+		headFail.Inc(1)
+		respondAPIError(w, r, fmt.Sprintf("cannot resolve %s: %s", uri.Addr, err), http.StatusNotFound, err)
+		return
+	}
+
+	ref := addr
+	if !uri.Raw() {
+		_, contentType, status, contentAddr, err := s.api.Get(r.Context(), s.api.Decryptor(r.Context(), credentials), addr, uri.Path)
+		if err != nil {
+			if isDecryptError(err) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", addr))
+				respondAPIError(w, r, err.Error(), http.StatusUnauthorized, err)
+				return
+			}
+			if status == http.StatusNotFound {
+				headNotFound.Inc(1)
+				respondAPIError(w, r, err.Error(), http.StatusNotFound, err)
+				return
+			}
+			headFail.Inc(1)
+			respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
+			return
+		}
+		if status == http.StatusMultipleChoices {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		ref = contentAddr
+	}
+
+	ok, err := s.api.Exists(r.Context(), ref, depth)
+	if err != nil {
+		headFail.Inc(1)
+		respondAPIError(w, r, err.Error(), http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		headNotFound.Inc(1)
+		respondError(w, r, fmt.Sprintf("%s is not retrievable", uri), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf("%q", ref.Hex()))
+	w.WriteHeader(http.StatusOK)
+}
+
 // HandlePin takes a root hash as argument and pins a given file or collection in the local Swarm DB
 func (s *Server) HandlePin(w http.ResponseWriter, r *http.Request) {
 	postPinCount.Inc(1)
@@ -1074,7 +1387,7 @@ func (s *Server) HandlePin(w http.ResponseWriter, r *http.Request) {
 	err := s.pinAPI.PinFiles(fileAddr, isRaw, "")
 	if err != nil {
 		postPinFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError)
+		respondAPIError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1100,7 +1413,7 @@ func (s *Server) HandleUnpin(w http.ResponseWriter, r *http.Request) {
 	err := s.pinAPI.UnpinFiles(fileAddr, "")
 	if err != nil {
 		deletePinFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError)
+		respondAPIError(w, r, fmt.Sprintf("error pinning file %s: %s", fileAddr.Hex(), err), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1118,7 +1431,7 @@ func (s *Server) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 	pinnedFiles, err := s.pinAPI.ListPins()
 	if err != nil {
 		getPinFail.Inc(1)
-		respondError(w, r, fmt.Sprintf("error getting pinned files: %s", err), http.StatusInternalServerError)
+		respondAPIError(w, r, fmt.Sprintf("error getting pinned files: %s", err), http.StatusInternalServerError, err)
 		return
 	}
 
@@ -1127,16 +1440,18 @@ func (s *Server) HandleGetPins(w http.ResponseWriter, r *http.Request) {
 }
 
 // calculateNumberOfChunks calculates the number of chunks in an arbitrary content length
-func calculateNumberOfChunks(contentLength int64, isEncrypted bool) int64 {
-	if contentLength < 4096 {
+// split into chunks of chunkSize bytes
+func calculateNumberOfChunks(contentLength, chunkSize int64, isEncrypted bool) int64 {
+	if contentLength < chunkSize {
 		return 1
 	}
-	branchingFactor := 128
+	refSize := int64(storage.AddressLength)
 	if isEncrypted {
-		branchingFactor = 64
+		refSize *= 2
 	}
+	branchingFactor := chunkSize / refSize
 
-	dataChunks := math.Ceil(float64(contentLength) / float64(4096))
+	dataChunks := math.Ceil(float64(contentLength) / float64(chunkSize))
 	totalChunks := dataChunks
 	intermediate := dataChunks / float64(branchingFactor)
 
@@ -1172,3 +1487,13 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 func isDecryptError(err error) bool {
 	return strings.Contains(err.Error(), api.ErrDecrypt.Error())
 }
+
+// rootPathURL returns the path of the URL with a trailing slash, keeping the
+// query, so that parameters like the time of feeds are preserved on redirects
+func rootPathURL(u *url.URL) string {
+	p := u.Path + "/"
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
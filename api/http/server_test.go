@@ -18,6 +18,7 @@ package http
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ecdsa"
@@ -29,6 +30,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -45,6 +47,7 @@ import (
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/chunk"
 	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/feed"
 	"github.com/ethersphere/swarm/storage/feed/lookup"
@@ -472,6 +475,42 @@ func TestBzzWithFeed(t *testing.T) {
 	if !bytes.Equal(retrievedData, dataBytes) {
 		t.Fatalf("retrieved data mismatch, expected %x, got %x", dataBytes, retrievedData)
 	}
+
+	// get the content as it was before the first feed update
+	resp, err = http.Get(getBzzURL + "?time=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d for content before the feed update, got %s", http.StatusNotFound, resp.Status)
+	}
+
+	// get the content as it was at the time of the feed update
+	resp, err = http.Get(fmt.Sprintf("%s?time=%d", getBzzURL, srv.CurrentTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("err %s", resp.Status)
+	}
+	retrievedData, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(retrievedData, dataBytes) {
+		t.Fatalf("retrieved data mismatch, expected %x, got %x", dataBytes, retrievedData)
+	}
+
+	resp, err = http.Get(getBzzURL + "?time=yesterday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status %d for invalid time, got %s", http.StatusBadRequest, resp.Status)
+	}
 }
 
 // Test Swarm feeds using the raw update methods
@@ -958,11 +997,11 @@ func testBzzGetPath(encrypted bool, t *testing.T) {
 	}
 
 	nonhashresponses := []string{
-		`cannot resolve name: no DNS to resolve name: "name"`,
-		`cannot resolve nonhash: no DNS to resolve name: "nonhash"`,
-		`cannot resolve nonhash: no DNS to resolve name: "nonhash"`,
-		`cannot resolve nonhash: no DNS to resolve name: "nonhash"`,
-		`cannot resolve nonhash: no DNS to resolve name: "nonhash"`,
+		`cannot resolve name: no ENS resolver`,
+		`cannot resolve nonhash: no ENS resolver`,
+		`cannot resolve nonhash: no ENS resolver`,
+		`cannot resolve nonhash: no ENS resolver`,
+		`cannot resolve nonhash: no ENS resolver`,
 	}
 
 	for i, url := range nonhashtests {
@@ -979,8 +1018,85 @@ func testBzzGetPath(encrypted bool, t *testing.T) {
 		if err != nil {
 			t.Fatalf("ReadAll failed: %v", err)
 		}
-		if !strings.Contains(string(respbody), nonhashresponses[i]) {
-			t.Fatalf("Non-Hash response body does not match, expected: %v, got: %v", nonhashresponses[i], string(respbody))
+		var errResp ErrorResponse
+		if err := json.Unmarshal(respbody, &errResp); err != nil {
+			t.Fatalf("Non-Hash response body is not an error response: %v: %s", err, string(respbody))
+		}
+		if errResp.Message != nonhashresponses[i] {
+			t.Fatalf("Non-Hash response body does not match, expected: %v, got: %v", nonhashresponses[i], errResp.Message)
+		}
+		if errResp.Code != ErrCodeNoResolver {
+			t.Fatalf("Non-Hash response error code does not match, expected: %v, got: %v", ErrCodeNoResolver, errResp.Code)
+		}
+	}
+}
+
+// TestBzzGetEntryHeaders tests that the HTTP metadata of a manifest entry
+// is sent with the response headers, and that only the allowed custom headers are sent
+func TestBzzGetEntryHeaders(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	store := func(data []byte) storage.Address {
+		ctx := context.TODO()
+		addr, wait, err := srv.FileStore.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+
+	content := store([]byte("compressed content"))
+	manifest, err := json.Marshal(&api.Manifest{
+		Entries: []api.ManifestEntry{
+			{
+				Hash:            content.Hex(),
+				Path:            "app.js",
+				ContentType:     "application/javascript",
+				CacheControl:    "public, max-age=3600",
+				ContentEncoding: "gzip",
+				Headers: map[string]string{
+					"content-language":          "en",
+					"link":                      "</style.css>; rel=preload",
+					"x-custom":                  "value",
+					"content-length":            "1",
+					"strict-transport-security": "max-age=0",
+					"location":                  "https://example.com",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := store(manifest)
+
+	// disable compression so that the client does not decode the content
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	res, err := client.Get(fmt.Sprintf("%s/bzz:/%s/app.js", srv.URL, addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":              "application/javascript",
+		"Cache-Control":             "public, max-age=3600",
+		"Content-Encoding":          "gzip",
+		"Content-Language":          "en",
+		"Link":                      "</style.css>; rel=preload",
+		"Content-Length":            strconv.Itoa(len("compressed content")),
+		"X-Custom":                  "",
+		"Strict-Transport-Security": "",
+		"Location":                  "",
+	} {
+		if got := res.Header.Get(name); got != want {
+			t.Errorf("header %s: expected %q, got %q", name, want, got)
 		}
 	}
 }
@@ -1275,6 +1391,210 @@ func TestMethodsNotAllowed(t *testing.T) {
 
 }
 
+// TestReadOnly checks that a node in gateway mode rejects all write paths
+// with a read_only error and still serves content
+func TestReadOnly(t *testing.T) {
+	srv := NewTestSwarmServer(t, func(a *api.API, pinAPI *pin.API) TestServer {
+		a.SetReadOnly(true)
+		return NewServer(a, pinAPI, "")
+	}, nil, nil)
+	defer srv.Close()
+
+	data := []byte("foo")
+	ctx := context.Background()
+	addr, wait, err := srv.FileStore.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		method string
+		url    string
+	}{
+		{http.MethodPost, fmt.Sprintf("%s/bzz-raw:/", srv.URL)},
+		{http.MethodPost, fmt.Sprintf("%s/bzz:/", srv.URL)},
+		{http.MethodPost, fmt.Sprintf("%s/bzz:/%s/foo.txt", srv.URL, addr)},
+		{http.MethodDelete, fmt.Sprintf("%s/bzz:/%s/foo.txt", srv.URL, addr)},
+		{http.MethodPost, fmt.Sprintf("%s/bzz-feed:/", srv.URL)},
+		{http.MethodPost, fmt.Sprintf("%s/bzz-pin:/%s", srv.URL, addr)},
+		{http.MethodDelete, fmt.Sprintf("%s/bzz-pin:/%s", srv.URL, addr)},
+	} {
+		res, body := httpDo(c.method, c.url, bytes.NewReader([]byte("bar")), nil, false, t)
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s %s: expected status %d, got %d", c.method, c.url, http.StatusForbidden, res.StatusCode)
+		}
+		var errResp ErrorResponse
+		if err := json.Unmarshal([]byte(body), &errResp); err != nil {
+			t.Fatalf("%s %s: %v", c.method, c.url, err)
+		}
+		if errResp.Code != ErrCodeReadOnly {
+			t.Fatalf("%s %s: expected error code %q, got %q", c.method, c.url, ErrCodeReadOnly, errResp.Code)
+		}
+	}
+
+	res, body := httpDo(http.MethodGet, fmt.Sprintf("%s/bzz-raw:/%s", srv.URL, addr), nil, nil, false, t)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+	if body != string(data) {
+		t.Fatalf("expected body %q, got %q", data, body)
+	}
+}
+
+// TestAPIKeys tests that requests require a known API key once API keys are enabled
+// and that the request rate and the daily upload and download quotas are enforced
+func TestAPIKeys(t *testing.T) {
+	keys := NewAPIKeys(state.NewInmemoryStore())
+	srv := NewTestSwarmServer(t, func(a *api.API, pinAPI *pin.API) TestServer {
+		s := NewServer(a, pinAPI, "")
+		s.SetAPIKeys(keys)
+		return s
+	}, nil, nil)
+	defer srv.Close()
+
+	key, err := keys.Add("app", Quota{UploadBytes: 10, DownloadBytes: 5, RequestsPerMinute: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]string{APIKeyHeaderName: key}
+
+	expectStatus := func(res *http.Response, body string, status int, code ErrorCode) {
+		t.Helper()
+		if res.StatusCode != status {
+			t.Fatalf("expected status %d, got %d: %s", status, res.StatusCode, body)
+		}
+		if code == "" {
+			return
+		}
+		var errResp ErrorResponse
+		if err := json.Unmarshal([]byte(body), &errResp); err != nil {
+			t.Fatal(err)
+		}
+		if errResp.Code != code {
+			t.Fatalf("expected error code %q, got %q", code, errResp.Code)
+		}
+	}
+
+	res, body := httpDo(http.MethodPost, srv.URL+"/bzz-raw:/", bytes.NewReader([]byte("foo")), nil, false, t)
+	expectStatus(res, body, http.StatusUnauthorized, ErrCodeUnauthorized)
+	res, body = httpDo(http.MethodPost, srv.URL+"/bzz-raw:/", bytes.NewReader([]byte("foo")), map[string]string{APIKeyHeaderName: "unknown"}, false, t)
+	expectStatus(res, body, http.StatusUnauthorized, ErrCodeUnauthorized)
+
+	// 1st request: within the upload quota
+	res, addr := httpDo(http.MethodPost, srv.URL+"/bzz-raw:/", bytes.NewReader([]byte("foo")), headers, false, t)
+	expectStatus(res, addr, http.StatusOK, "")
+	// 2nd request: above the remaining upload quota
+	res, body = httpDo(http.MethodPost, srv.URL+"/bzz-raw:/", bytes.NewReader([]byte("foo bar baz")), headers, false, t)
+	expectStatus(res, body, http.StatusTooManyRequests, ErrCodeQuotaExceeded)
+	// 3rd request: within the download quota
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusOK, "")
+	if body != "foo" {
+		t.Fatalf("expected body %q, got %q", "foo", body)
+	}
+	// 4th request: exceeds the remaining download quota
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusTooManyRequests, ErrCodeQuotaExceeded)
+	// 5th request: above the request rate
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusTooManyRequests, ErrCodeQuotaExceeded)
+
+	list, err := keys.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected 1 API key, got %d", len(list))
+	}
+	if list[0].Name != "app" || list[0].Uploaded != 3 || list[0].Downloaded != 3 {
+		t.Fatalf("unexpected API key usage %+v", list[0])
+	}
+
+	// the download quota is not enough for the content for the rest of the day, uploads are still possible
+	defer func(f func() time.Time) { quotaNow = f }(quotaNow)
+	quotaNow = func() time.Time { return time.Now().Add(time.Minute) }
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusTooManyRequests, ErrCodeQuotaExceeded)
+	res, body = httpDo(http.MethodPost, srv.URL+"/bzz-raw:/", bytes.NewReader([]byte("bar")), headers, false, t)
+	expectStatus(res, body, http.StatusOK, "")
+	quotaNow = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusOK, "")
+
+	if err := keys.Remove(list[0].Hash); err != nil {
+		t.Fatal(err)
+	}
+	res, body = httpDo(http.MethodGet, srv.URL+"/bzz-raw:/"+addr, nil, headers, false, t)
+	expectStatus(res, body, http.StatusUnauthorized, ErrCodeUnauthorized)
+}
+
+// TestAPIKeysWebSocket tests that the traffic of connections upgraded to WebSocket
+// is accounted for in the usage of the API key, and that it fails once it exceeds the quota
+func TestAPIKeysWebSocket(t *testing.T) {
+	keys := NewAPIKeys(state.NewInmemoryStore())
+	srv := NewTestSwarmServer(t, func(a *api.API, pinAPI *pin.API) TestServer {
+		s := NewServer(a, pinAPI, "")
+		s.SetAPIKeys(keys)
+		return s
+	}, nil, nil)
+	defer srv.Close()
+
+	// upgrade performs the WebSocket handshake and returns the response status
+	upgrade := func(key string) int {
+		t.Helper()
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/bzz-ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set(APIKeyHeaderName, key)
+		if err := req.Write(conn); err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			// the connection is closed without a response
+			return 0
+		}
+		return res.StatusCode
+	}
+
+	key, err := keys.Add("app", Quota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := upgrade(key); status != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status %d, got %d", http.StatusSwitchingProtocols, status)
+	}
+	list, err := keys.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Downloaded == 0 {
+		t.Fatalf("expected the handshake response accounted for, got %+v", list)
+	}
+
+	// the handshake response exceeds the download quota
+	limited, err := keys.Add("limited", Quota{DownloadBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := upgrade(limited); status == http.StatusSwitchingProtocols {
+		t.Fatal("expected handshake to fail")
+	}
+}
+
 func httpDo(httpMethod string, url string, reqBody io.Reader, headers map[string]string, verbose bool, t *testing.T) (*http.Response, string) {
 	// Build the Request
 	req, err := http.NewRequest(httpMethod, url, reqBody)
@@ -1630,7 +1950,7 @@ func TestCalculateNumberOfChunks(t *testing.T) {
 		{len: 1000000, chunks: 248},
 		{len: 325839339210, chunks: 79550620 + 621490 + 4856 + 38 + 1},
 	} {
-		res := calculateNumberOfChunks(tc.len, false)
+		res := calculateNumberOfChunks(tc.len, chunk.DefaultSize, false)
 		if res != tc.chunks {
 			t.Fatalf("expected result for %d bytes to be %d got %d", tc.len, tc.chunks, res)
 		}
@@ -1650,13 +1970,34 @@ func TestCalculateNumberOfChunksEncrypted(t *testing.T) {
 		{len: 1000000, chunks: 245 + 4 + 1},
 		{len: 325839339210, chunks: 79550620 + 1242979 + 19422 + 304 + 5 + 1},
 	} {
-		res := calculateNumberOfChunks(tc.len, true)
+		res := calculateNumberOfChunks(tc.len, chunk.DefaultSize, true)
 		if res != tc.chunks {
 			t.Fatalf("expected result for %d bytes to be %d got %d", tc.len, tc.chunks, res)
 		}
 	}
 }
 
+// TestCalculateNumberOfChunksProfile tests that the number of chunks is
+// calculated with the chunk size of the chunker profile
+func TestCalculateNumberOfChunksProfile(t *testing.T) {
+	size := storage.SmallChunkerProfile.ChunkSize
+	for _, tc := range []struct {
+		len       int64
+		encrypted bool
+		chunks    int64
+	}{
+		{len: 1000, chunks: 1},
+		{len: 5000, chunks: 5 + 1},
+		{len: 100000, chunks: 98 + 4 + 1},
+		{len: 100000, encrypted: true, chunks: 98 + 7 + 1},
+	} {
+		res := calculateNumberOfChunks(tc.len, size, tc.encrypted)
+		if res != tc.chunks {
+			t.Fatalf("expected result for %d bytes encrypted %v to be %d got %d", tc.len, tc.encrypted, tc.chunks, res)
+		}
+	}
+}
+
 // testResolver implements the Resolver interface and either returns the given
 // hash if it is set, or returns a "name not found" error
 type testResolveValidator struct {
@@ -1759,3 +2100,57 @@ func unpinFile(t *testing.T, srv *TestSwarmServer, rootHash []byte) []byte {
 	}
 	return unpinMessage
 }
+
+// TestBzzHead tests checking that content is retrievable with HEAD requests
+func TestBzzHead(t *testing.T) {
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+
+	// a root chunk referencing 4 data chunks
+	data := testutil.RandomBytes(1, 3*chunk.DefaultSize+100)
+	resp, err := http.Post(srv.URL+"/bzz-raw:/", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawURL := srv.URL + "/bzz-raw:/" + string(hash)
+	fileURL := uploadContent(t, srv.URL, "text/plain", []byte("hello swarm"))
+
+	head := func(url string, status int) http.Header {
+		t.Helper()
+		resp, err := http.Head(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("HEAD %s: expected status %d, got %s", url, status, resp.Status)
+		}
+		return resp.Header
+	}
+	head(rawURL, http.StatusOK)
+	head(rawURL+"?depth=-1", http.StatusOK)
+	head(rawURL+"?depth=all", http.StatusBadRequest)
+	head(srv.URL+"/bzz-raw:/"+hex.EncodeToString(make([]byte, 32)), http.StatusNotFound)
+	if ctype := head(fileURL, http.StatusOK).Get("Content-Type"); ctype != "text/plain" {
+		t.Fatalf("expected content type text/plain, got %q", ctype)
+	}
+	head(srv.URL+"/bzz:/"+hex.EncodeToString(make([]byte, 32))+"/", http.StatusNotFound)
+
+	// remove the first data chunk, only the root chunk is left to be found
+	ctx := context.Background()
+	root, err := srv.FileStore.ChunkStore.Get(ctx, chunk.ModeGetLookup, storage.Address(common.Hex2Bytes(string(hash))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.FileStore.ChunkStore.Set(ctx, chunk.ModeSetRemove, root.Data()[8:8+storage.AddressLength]); err != nil {
+		t.Fatal(err)
+	}
+	head(rawURL+"?depth=0", http.StatusOK)
+	head(rawURL+"?depth=1", http.StatusNotFound)
+	head(rawURL+"?depth=-1", http.StatusNotFound)
+}
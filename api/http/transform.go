@@ -0,0 +1,434 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/api/http/langos"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TransformQueryParam is the query parameter of the requests to the bzz endpoint naming
+// the transformer applied to the content served, the other query parameters of the
+// request are passed to the transformer
+const TransformQueryParam = "transform"
+
+const (
+	maxTransformSize         = 32 * 1024 * 1024 // maximum size of the content that is transformed
+	maxTransformCacheEntries = 4096             // maximum number of references to transformed contents kept in memory
+	maxImageDimension        = 16384            // maximum width and height of the images resized
+	maxTransforms            = 4                // maximum number of contents transformed at the same time
+)
+
+var (
+	getTransformCount  = metrics.NewRegisteredCounter("api/http/get/transform/count", nil)
+	getTransformCached = metrics.NewRegisteredCounter("api/http/get/transform/cached", nil)
+	getTransformFail   = metrics.NewRegisteredCounter("api/http/get/transform/fail", nil)
+	getTransformBusy   = metrics.NewRegisteredCounter("api/http/get/transform/busy", nil)
+)
+
+// Transformer converts the content served by the gateway, like rendering markdown
+// as HTML or resizing images
+type Transformer interface {
+	// Accepts reports whether content of the given type can be transformed
+	Accepts(contentType string) bool
+	// Params returns the names of the query parameters the transformer uses, only
+	// these are passed to Transform and tell transformed contents apart in the cache
+	Params() []string
+	// Transform returns the transformed content and its content type
+	// The params are the query parameters of the request named by Params
+	Transform(ctx context.Context, r io.Reader, contentType string, params url.Values) ([]byte, string, error)
+}
+
+// RegisterTransformer makes the transformer available to the requests to the bzz endpoint
+// with the transform query parameter set to the given name
+// It is expected to be called before the server starts serving requests
+func (s *Server) RegisterTransformer(name string, t Transformer) error {
+	if name == "" {
+		return errors.New("empty transformer name")
+	}
+	if _, ok := s.transformers[name]; ok {
+		return fmt.Errorf("transformer %q already registered", name)
+	}
+	s.transformers[name] = t
+	return nil
+}
+
+// transformed is a reference to a transformed content stored in swarm
+type transformed struct {
+	addr        storage.Address
+	contentType string
+}
+
+// transformCache looks up the transformed contents by their source content,
+// transformer and parameters
+// The transformed contents are stored in swarm, and only their references are kept
+// in memory, the oldest references are dropped once there are more than
+// maxTransformCacheEntries
+type transformCache struct {
+	mtx     sync.Mutex
+	entries map[string]transformed
+	keys    []string // in the order the entries were added
+}
+
+func newTransformCache() *transformCache {
+	return &transformCache{
+		entries: make(map[string]transformed),
+	}
+}
+
+// transformParams returns the query parameters of the request the transformer uses
+func transformParams(t Transformer, query url.Values) url.Values {
+	params := make(url.Values)
+	for _, name := range t.Params() {
+		if v, ok := query[name]; ok {
+			params[name] = v
+		}
+	}
+	return params
+}
+
+// transformKey returns the key of the transformed content in the cache
+func transformKey(source storage.Address, name string, params url.Values) string {
+	h := sha256.New()
+	h.Write(source)
+	h.Write([]byte(name))
+	h.Write([]byte(params.Encode())) // sorted by key
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *transformCache) get(key string) (transformed, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t, ok := c.entries[key]
+	return t, ok
+}
+
+func (c *transformCache) put(key string, t transformed) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.keys) >= maxTransformCacheEntries {
+		delete(c.entries, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, key)
+	c.entries[key] = t
+}
+
+// remove drops the reference, used once the transformed content cannot be retrieved
+func (c *transformCache) remove(key string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		return
+	}
+	delete(c.entries, key)
+	for i, k := range c.keys {
+		if k == key {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// transformSource returns the content to transform, decoding the content encoding
+// of the manifest entry, as transformers expect the content itself
+func transformSource(reader storage.LazySectionReader, size int64, encoding string) (io.Reader, error) {
+	r := io.NewSectionReader(reader, 0, size)
+	switch encoding {
+	case "", "identity":
+		return r, nil
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		// the decoded content is bounded too, as it may be much larger than the encoded one
+		data, err := ioutil.ReadAll(io.LimitReader(gr, maxTransformSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxTransformSize {
+			return nil, fmt.Errorf("decoded content is larger than %d bytes", maxTransformSize)
+		}
+		return bytes.NewReader(data), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// clearEntryHeaders removes the headers describing the source content, that do not
+// apply to the transformed content
+func clearEntryHeaders(w http.ResponseWriter) {
+	for _, name := range []string{"Content-Encoding", "Content-Length", "Content-Range", "Accept-Ranges"} {
+		w.Header().Del(name)
+	}
+}
+
+// serveTransformed serves the content transformed by the named transformer
+// Content with the gzip content encoding is decoded before it is transformed, and
+// the transformed content is served without a content encoding
+// The transformed content is stored in swarm and its reference is cached, so that it
+// is only transformed again once it is dropped from the cache or the local store.
+// Content requested with credentials is not cached, as the cache does not check them
+// Transforming may take a lot of memory and time, so at most maxTransforms contents
+// are transformed at the same time, and other requests that are not served from the
+// cache are answered with 503
+func (s *Server) serveTransformed(w http.ResponseWriter, r *http.Request, name string, source storage.Address, contentType, contentEncoding string, reader storage.LazySectionReader, fileName string, modTime time.Time, credentials string) {
+	getTransformCount.Inc(1)
+
+	t, ok := s.transformers[name]
+	if !ok {
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("unknown transformer %q", name), http.StatusBadRequest)
+		return
+	}
+	if !t.Accepts(contentType) {
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("transformer %q does not accept content type %q", name, contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	switch contentEncoding {
+	case "", "identity", "gzip":
+	default:
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("content encoding %q cannot be transformed", contentEncoding), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	params := transformParams(t, r.URL.Query())
+	key := transformKey(source, name, params)
+
+	cache := credentials == ""
+	if cache {
+		if cached, ok := s.transformCache.get(key); ok {
+			reader, _ := s.api.Retrieve(r.Context(), cached.addr)
+			_, err := reader.Size(r.Context(), nil)
+			if err == nil {
+				getTransformCached.Inc(1)
+				clearEntryHeaders(w)
+				w.Header().Set("Content-Type", cached.contentType)
+				w.Header().Set("ETag", fmt.Sprintf("%q", key))
+				http.ServeContent(w, r, fileName, modTime, langos.NewBufferedReadSeeker(reader, getFileBufferSize))
+				return
+			}
+			log.Debug("transformed content not retrieved", "ref", cached.addr, "err", err)
+			s.transformCache.remove(key)
+		}
+	}
+
+	select {
+	case s.transformSlots <- struct{}{}:
+		defer func() { <-s.transformSlots }()
+	default:
+		getTransformBusy.Inc(1)
+		w.Header().Set("Retry-After", "1")
+		respondError(w, r, "too many contents being transformed", http.StatusServiceUnavailable)
+		return
+	}
+
+	size, err := reader.Size(r.Context(), nil)
+	if err != nil {
+		getTransformFail.Inc(1)
+		respondError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if size > maxTransformSize {
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("content of %d bytes is too large to transform", size), http.StatusBadRequest)
+		return
+	}
+	src, err := transformSource(reader, size, contentEncoding)
+	if err != nil {
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("decode content: %v", err), http.StatusBadRequest)
+		return
+	}
+	data, transformedType, err := t.Transform(r.Context(), src, contentType, params)
+	if err != nil {
+		getTransformFail.Inc(1)
+		respondError(w, r, fmt.Sprintf("transform %q: %v", name, err), http.StatusBadRequest)
+		return
+	}
+
+	if cache {
+		if addr, err := s.storeTransformed(r.Context(), data); err != nil {
+			log.Warn("store transformed content", "err", err)
+		} else {
+			s.transformCache.put(key, transformed{addr: addr, contentType: transformedType})
+			w.Header().Set("ETag", fmt.Sprintf("%q", key))
+		}
+	}
+	clearEntryHeaders(w)
+	w.Header().Set("Content-Type", transformedType)
+	http.ServeContent(w, r, fileName, modTime, bytes.NewReader(data))
+}
+
+// storeTransformed stores the transformed content in swarm and returns its reference
+func (s *Server) storeTransformed(ctx context.Context, data []byte) (storage.Address, error) {
+	addr, wait, err := s.api.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// ImageResizer is a Transformer scaling PNG, JPEG and GIF images to the width and height
+// query parameters, keeping the aspect ratio if only one of them is set
+// GIF images are served as PNG, with only their first frame
+type ImageResizer struct {
+	MaxPixels int // maximum number of pixels of the source and the resized image
+}
+
+// Accepts reports whether the content type is an image that can be resized
+func (ir *ImageResizer) Accepts(contentType string) bool {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Params returns the width and height query parameters
+func (ir *ImageResizer) Params() []string {
+	return []string{"width", "height"}
+}
+
+// Transform resizes the image to the width and height query parameters
+func (ir *ImageResizer) Transform(ctx context.Context, r io.Reader, contentType string, params url.Values) ([]byte, string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	// the dimensions are bounded before they are multiplied, so that the product does not overflow
+	if config.Width > maxImageDimension || config.Height > maxImageDimension {
+		return nil, "", fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+	if ir.MaxPixels > 0 && config.Width*config.Height > ir.MaxPixels {
+		return nil, "", fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	width, height, err := resizeDimensions(config.Width, config.Height, params)
+	if err != nil {
+		return nil, "", err
+	}
+	if ir.MaxPixels > 0 && width*height > ir.MaxPixels {
+		return nil, "", fmt.Errorf("resized image of %dx%d pixels is too large", width, height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	bounds := src.Bounds()
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, nil)
+		contentType = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, dst)
+		contentType = "image/png"
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), contentType, nil
+}
+
+// resizeDimensions returns the dimensions of the resized image from the width and
+// height query parameters
+func resizeDimensions(width, height int, params url.Values) (int, int, error) {
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("empty image")
+	}
+	parse := func(name string) (int, error) {
+		v := params.Get(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxImageDimension {
+			return 0, fmt.Errorf("invalid %s %q", name, v)
+		}
+		return n, nil
+	}
+	w, err := parse("width")
+	if err != nil {
+		return 0, 0, err
+	}
+	h, err := parse("height")
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case w == 0 && h == 0:
+		return 0, 0, errors.New("width or height required")
+	case w == 0:
+		w = width * h / height
+	case h == 0:
+		h = height * w / width
+	}
+	if w > maxImageDimension || h > maxImageDimension {
+		return 0, 0, fmt.Errorf("resized image of %dx%d pixels is too large", w, h)
+	}
+	if w == 0 {
+		w = 1
+	}
+	if h == 0 {
+		h = 1
+	}
+	return w, h, nil
+}
@@ -0,0 +1,383 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/pin"
+)
+
+// upperTransformer is a Transformer converting plain text to upper case
+type upperTransformer struct {
+	calls int64 // accessed atomically
+}
+
+func (u *upperTransformer) Accepts(contentType string) bool {
+	return contentType == "text/plain"
+}
+
+func (u *upperTransformer) Params() []string {
+	return []string{"suffix"}
+}
+
+func (u *upperTransformer) Transform(ctx context.Context, r io.Reader, contentType string, params url.Values) ([]byte, string, error) {
+	atomic.AddInt64(&u.calls, 1)
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(strings.ToUpper(string(data)) + params.Get("suffix")), "text/x-upper", nil
+}
+
+// TestTransform tests that the content served by the bzz endpoint is transformed by the
+// transformer named in the query, and the transformed content is cached by the
+// parameters the transformer uses
+func TestTransform(t *testing.T) {
+	upper := &upperTransformer{}
+	var server *Server
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server = NewServer(api, pinAPI, "")
+		if err := server.RegisterTransformer("upper", upper); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.RegisterTransformer("upper", upper); err == nil {
+			t.Error("expected error registering transformer twice")
+		}
+		if err := server.RegisterTransformer("resize", &ImageResizer{MaxPixels: 1000}); err != nil {
+			t.Fatal(err)
+		}
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	textURL := uploadContent(t, srv.URL, "text/plain", []byte("hello swarm"))
+
+	for _, tc := range []struct {
+		query       string
+		status      int
+		contentType string
+		body        string
+		calls       int64
+	}{
+		{query: "", status: http.StatusOK, contentType: "text/plain", body: "hello swarm"},
+		{query: "?transform=upper", status: http.StatusOK, contentType: "text/x-upper", body: "HELLO SWARM", calls: 1},
+		{query: "?transform=upper", status: http.StatusOK, contentType: "text/x-upper", body: "HELLO SWARM", calls: 1},
+		{query: "?transform=upper&suffix=!", status: http.StatusOK, contentType: "text/x-upper", body: "HELLO SWARM!", calls: 2},
+		{query: "?transform=upper&suffix=!&other=1", status: http.StatusOK, contentType: "text/x-upper", body: "HELLO SWARM!", calls: 2},
+		{query: "?transform=unknown", status: http.StatusBadRequest, calls: 2},
+		{query: "?transform=resize&width=10", status: http.StatusUnsupportedMediaType, calls: 2},
+	} {
+		resp, err := http.Get(textURL + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%q: got status %v, want %v", tc.query, resp.StatusCode, tc.status)
+		}
+		if calls := atomic.LoadInt64(&upper.calls); calls != tc.calls {
+			t.Errorf("%q: got %v transformations, want %v", tc.query, calls, tc.calls)
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := resp.Header.Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%q: got content type %q, want %q", tc.query, got, tc.contentType)
+		}
+		if string(body) != tc.body {
+			t.Errorf("%q: got body %q, want %q", tc.query, body, tc.body)
+		}
+	}
+
+	// the transformed contents are stored in swarm
+	server.transformCache.mtx.Lock()
+	entries := len(server.transformCache.entries)
+	for _, cached := range server.transformCache.entries {
+		reader, _ := srv.FileStore.Retrieve(context.Background(), cached.addr)
+		size, err := reader.Size(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, size)
+		if _, err := reader.ReadAt(data, 0); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "HELLO SWARM") {
+			t.Errorf("got stored transformed content %q", data)
+		}
+	}
+	server.transformCache.mtx.Unlock()
+	if entries != 2 {
+		t.Errorf("got %v cached transformed contents, want 2", entries)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatal(err)
+	}
+	imageURL := uploadContent(t, srv.URL, "image/png", buf.Bytes())
+
+	for _, tc := range []struct {
+		query  string
+		status int
+		width  int
+		height int
+	}{
+		{query: "?transform=resize&width=10", status: http.StatusOK, width: 10, height: 5},
+		{query: "?transform=resize&height=20", status: http.StatusOK, width: 40, height: 20},
+		{query: "?transform=resize&width=3&height=4", status: http.StatusOK, width: 3, height: 4},
+		{query: "?transform=resize", status: http.StatusBadRequest},
+		{query: "?transform=resize&width=-1", status: http.StatusBadRequest},
+		{query: "?transform=resize&width=100", status: http.StatusBadRequest}, // exceeds MaxPixels
+		{query: "?transform=resize&width=4294967296&height=4294967296", status: http.StatusBadRequest},
+		{query: "?transform=resize&height=16384", status: http.StatusBadRequest}, // width exceeds maxImageDimension
+	} {
+		resp, err := http.Get(imageURL + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.status {
+			resp.Body.Close()
+			t.Fatalf("%q: got status %v, want %v", tc.query, resp.StatusCode, tc.status)
+		}
+		if tc.status != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		img, err := png.Decode(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != tc.width || b.Dy() != tc.height {
+			t.Errorf("%q: got image of %dx%d pixels, want %dx%d", tc.query, b.Dx(), b.Dy(), tc.width, tc.height)
+		}
+	}
+}
+
+// TestTransformContentEncoding tests that content stored with the gzip content encoding
+// is decoded before it is transformed and served without the content encoding, and that
+// content with other encodings is not transformed
+func TestTransformContentEncoding(t *testing.T) {
+	upper := &upperTransformer{}
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server := NewServer(api, pinAPI, "")
+		if err := server.RegisterTransformer("upper", upper); err != nil {
+			t.Fatal(err)
+		}
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	store := func(data []byte) storage.Address {
+		ctx := context.TODO()
+		addr, wait, err := srv.FileStore.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write([]byte("hello swarm")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gzipped := store(buf.Bytes())
+	manifest, err := json.Marshal(&api.Manifest{
+		Entries: []api.ManifestEntry{
+			{
+				Hash:            gzipped.Hex(),
+				Path:            "hello.txt",
+				ContentType:     "text/plain",
+				ContentEncoding: "gzip",
+			},
+			{
+				Hash:            gzipped.Hex(),
+				Path:            "hello.br",
+				ContentType:     "text/plain",
+				ContentEncoding: "br",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := store(manifest)
+
+	// disable compression so that the client does not decode the content
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(fmt.Sprintf("%s/bzz:/%s/hello.txt?transform=upper", srv.URL, addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		if got := resp.Header.Get("Content-Encoding"); got != "" {
+			t.Errorf("got content encoding %q, want none", got)
+		}
+		if string(body) != "HELLO SWARM" {
+			t.Errorf("got body %q, want %q", body, "HELLO SWARM")
+		}
+	}
+	if calls := atomic.LoadInt64(&upper.calls); calls != 1 {
+		t.Errorf("got %v transformations, want 1", calls)
+	}
+
+	resp, err := client.Get(fmt.Sprintf("%s/bzz:/%s/hello.br?transform=upper", srv.URL, addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusUnsupportedMediaType)
+	}
+}
+
+// blockingTransformer is a Transformer whose transforms block until they are released
+type blockingTransformer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTransformer) Accepts(contentType string) bool {
+	return true
+}
+
+func (b *blockingTransformer) Params() []string {
+	return []string{"n"}
+}
+
+func (b *blockingTransformer) Transform(ctx context.Context, r io.Reader, contentType string, params url.Values) ([]byte, string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return []byte(params.Get("n")), "text/plain", nil
+}
+
+// TestTransformConcurrency tests that requests are answered with 503 while the
+// maximum number of contents are being transformed
+func TestTransformConcurrency(t *testing.T) {
+	blocking := &blockingTransformer{
+		started: make(chan struct{}, maxTransforms),
+		release: make(chan struct{}),
+	}
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server := NewServer(api, pinAPI, "")
+		if err := server.RegisterTransformer("blocking", blocking); err != nil {
+			t.Fatal(err)
+		}
+		return server
+	}, nil, nil)
+	defer srv.Close()
+
+	textURL := uploadContent(t, srv.URL, "text/plain", []byte("hello swarm"))
+	get := func(n int) (*http.Response, error) {
+		return http.Get(fmt.Sprintf("%s?transform=blocking&n=%d", textURL, n))
+	}
+
+	errC := make(chan error, maxTransforms)
+	for i := 0; i < maxTransforms; i++ {
+		go func(n int) {
+			resp, err := get(n)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+				}
+			}
+			errC <- err
+		}(i)
+	}
+	for i := 0; i < maxTransforms; i++ {
+		<-blocking.started
+	}
+
+	resp, err := get(maxTransforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	close(blocking.release)
+	for i := 0; i < maxTransforms; i++ {
+		if err := <-errC; err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err = get(maxTransforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
+
+// uploadContent uploads the data to the bzz endpoint and returns the URL it is served at
+func uploadContent(t *testing.T, srvURL, contentType string, data []byte) string {
+	t.Helper()
+	resp, err := http.Post(srvURL+"/bzz:/", contentType, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: got status %v", resp.Status)
+	}
+	hash, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%s/bzz:/%s/", srvURL, hash)
+}
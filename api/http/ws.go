@@ -0,0 +1,229 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+)
+
+// wsFeedPollInterval is the time between lookups of the latest update of
+// the feeds subscribed to over the WebSocket endpoint
+var wsFeedPollInterval = 5 * time.Second
+
+var (
+	// wsFeedMaxSubscriptions is the maximum number of feed subscriptions of the endpoint
+	wsFeedMaxSubscriptions = 1024
+	// wsFeedMaxConnSubscriptions is the maximum number of feed subscriptions of a connection
+	wsFeedMaxConnSubscriptions = 32
+)
+
+var errFeedSubscriptionLimit = errors.New("too many feed subscriptions")
+
+// FeedUpdate is the notification pushed to the subscribers of a feed
+// when a new update is found
+type FeedUpdate struct {
+	Feed    feed.Feed       `json:"feed"`
+	Address storage.Address `json:"address"` // address of the update chunk
+	Data    hexutil.Bytes   `json:"data"`
+}
+
+// FeedSubscriptionAPI is served in the bzz namespace of the /bzz-ws endpoint
+// and pushes the updates of feeds to its subscribers.
+// The subscribers of the same feed share a single poller.
+type FeedSubscriptionAPI struct {
+	api *api.API
+
+	mu      sync.Mutex
+	pollers map[feed.Feed]*feedPoller
+	conns   map[<-chan interface{}]int // subscriptions by the closed channel of their connection
+	count   int                        // number of subscriptions
+}
+
+// feedPoller looks up the latest update of a feed and pushes it to the subscribers
+type feedPoller struct {
+	feed feed.Feed
+	subs map[rpc.ID]*feedSubscriber
+	last *FeedUpdate // latest update found, pushed to new subscribers
+	quit chan struct{}
+}
+
+type feedSubscriber struct {
+	notifier *rpc.Notifier
+	id       rpc.ID
+	closed   <-chan interface{} // closed with the connection of the subscriber
+}
+
+// NewFeedSubscriptionAPI creates a new FeedSubscriptionAPI
+func NewFeedSubscriptionAPI(api *api.API) *FeedSubscriptionAPI {
+	return &FeedSubscriptionAPI{
+		api:     api,
+		pollers: make(map[feed.Feed]*feedPoller),
+		conns:   make(map[<-chan interface{}]int),
+	}
+}
+
+// Feed subscribes to the updates of the given feed.
+// The latest update is pushed as soon as it is found, later updates as they are published.
+func (f *FeedSubscriptionAPI) Feed(ctx context.Context, fd feed.Feed) (*rpc.Subscription, error) {
+	ctx = api.NewRPCRequest(ctx, "bzz_subscribe_feed")
+	return f.subscribe(ctx, &fd)
+}
+
+// FeedManifest subscribes to the updates of the feed referenced by the given
+// feed manifest address or ENS name
+func (f *FeedSubscriptionAPI) FeedManifest(ctx context.Context, manifest string) (*rpc.Subscription, error) {
+	ctx = api.NewRPCRequest(ctx, "bzz_subscribe_feedManifest")
+	addr, err := f.api.Resolve(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := f.api.ResolveFeedManifest(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return f.subscribe(ctx, fd)
+}
+
+func (f *FeedSubscriptionAPI) subscribe(ctx context.Context, fd *feed.Feed) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	closed := notifier.Closed()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.count >= wsFeedMaxSubscriptions || f.conns[closed] >= wsFeedMaxConnSubscriptions {
+		return nil, errFeedSubscriptionLimit
+	}
+	sub := notifier.CreateSubscription()
+	s := &feedSubscriber{
+		notifier: notifier,
+		id:       sub.ID,
+		closed:   closed,
+	}
+	f.count++
+	f.conns[closed]++
+
+	p, ok := f.pollers[*fd]
+	if !ok {
+		p = &feedPoller{
+			feed: *fd,
+			subs: make(map[rpc.ID]*feedSubscriber),
+			quit: make(chan struct{}),
+		}
+		f.pollers[*fd] = p
+		go f.poll(p)
+	}
+	p.subs[sub.ID] = s
+	if p.last != nil {
+		// notifications are buffered until the subscription is sent to the client
+		if err := notifier.Notify(sub.ID, p.last); err != nil {
+			log.Debug("feed subscription notify", "feed", fd.Hex(), "err", err)
+		}
+	}
+
+	go func() {
+		select {
+		case <-sub.Err():
+		case <-closed:
+		}
+		f.unsubscribe(p, s)
+	}()
+
+	return sub, nil
+}
+
+// unsubscribe removes the subscriber from the poller, which is stopped
+// once it has no subscribers left
+func (f *FeedSubscriptionAPI) unsubscribe(p *feedPoller, s *feedSubscriber) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := p.subs[s.id]; !ok {
+		return
+	}
+	delete(p.subs, s.id)
+	f.count--
+	if f.conns[s.closed]--; f.conns[s.closed] == 0 {
+		delete(f.conns, s.closed)
+	}
+	if len(p.subs) == 0 {
+		delete(f.pollers, p.feed)
+		close(p.quit)
+	}
+}
+
+// poll looks up the latest update of the feed of the poller periodically
+// and pushes the new updates to its subscribers until it is stopped
+func (f *FeedSubscriptionAPI) poll(p *feedPoller) {
+	ticker := time.NewTicker(wsFeedPollInterval)
+	defer ticker.Stop()
+
+	var last storage.Address
+	for {
+		// the context of the subscription request is done once the subscription is created
+		lookupCtx, cancel := context.WithTimeout(context.Background(), wsFeedPollInterval)
+		addr, data, err := f.api.FeedsLatest(lookupCtx, &p.feed)
+		cancel()
+		if err != nil {
+			log.Trace("feed subscription lookup", "feed", p.feed.Hex(), "err", err)
+		} else if !bytes.Equal(addr, last) {
+			last = addr
+			update := &FeedUpdate{Feed: p.feed, Address: addr, Data: data}
+
+			f.mu.Lock()
+			p.last = update
+			subs := make([]*feedSubscriber, 0, len(p.subs))
+			for _, s := range p.subs {
+				subs = append(subs, s)
+			}
+			f.mu.Unlock()
+
+			for _, s := range subs {
+				if err := s.notifier.Notify(s.id, update); err != nil {
+					log.Debug("feed subscription notify", "feed", p.feed.Hex(), "err", err)
+					f.unsubscribe(p, s)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// RegisterWebSocketAPI makes the methods of the service available to the clients
+// of the /bzz-ws endpoint in the given namespace, subscriptions included.
+func (s *Server) RegisterWebSocketAPI(namespace string, service interface{}) error {
+	return s.ws.RegisterName(namespace, service)
+}
@@ -0,0 +1,236 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/ethersphere/swarm/storage/pin"
+)
+
+// TestBzzWebSocketFeed tests that the subscribers of a feed on the /bzz-ws
+// endpoint are pushed the latest update and the updates published later
+func TestBzzWebSocketFeed(t *testing.T) {
+	defer func(interval time.Duration) { wsFeedPollInterval = interval }(wsFeedPollInterval)
+	wsFeedPollInterval = 10 * time.Millisecond
+
+	srv := NewTestSwarmServer(t, serverFunc, nil, nil)
+	defer srv.Close()
+	signer, _, _ := newTestSigner()
+
+	topic, _ := feed.NewTopic("foo.eth", nil)
+	request := feed.NewFirstRequest(topic)
+	request.SetData([]byte("foo"))
+	if err := request.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	b := postFeedUpdate(t, srv, request, true)
+	manifestAddr := &storage.Address{}
+	if err := json.Unmarshal(b, manifestAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := rpc.DialWebsocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/bzz-ws", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	updates := make(chan FeedUpdate)
+	sub, err := client.Subscribe(ctx, "bzz", updates, "feedManifest", manifestAddr.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	expectUpdate := func(data []byte) {
+		select {
+		case u := <-updates:
+			if u.Feed != request.Feed {
+				t.Fatalf("expected update of feed %v, got %v", request.Feed.Hex(), u.Feed.Hex())
+			}
+			if !bytes.Equal(u.Data, data) {
+				t.Fatalf("expected update data %q, got %q", data, u.Data)
+			}
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for update %q", data)
+		}
+	}
+	expectUpdate([]byte("foo"))
+
+	// the next update is pushed once published
+	srv.CurrentTime++
+	resp, err := http.Get(fmt.Sprintf("%s/bzz-feed:/%s/?meta=1", srv.URL, manifestAddr.Hex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = &feed.Request{}
+	if err := request.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	request.SetData([]byte("bar"))
+	if err := request.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	postFeedUpdate(t, srv, request, false)
+	expectUpdate([]byte("bar"))
+}
+
+// TestBzzWebSocketFeedLimits tests that the feed subscriptions of the /bzz-ws
+// endpoint are limited per connection and in total and share a poller per feed
+func TestBzzWebSocketFeedLimits(t *testing.T) {
+	defer func(interval time.Duration, max, maxConn int) {
+		wsFeedPollInterval = interval
+		wsFeedMaxSubscriptions = max
+		wsFeedMaxConnSubscriptions = maxConn
+	}(wsFeedPollInterval, wsFeedMaxSubscriptions, wsFeedMaxConnSubscriptions)
+	wsFeedPollInterval = 10 * time.Millisecond
+	wsFeedMaxSubscriptions = 3
+	wsFeedMaxConnSubscriptions = 2
+
+	var server *Server
+	srv := NewTestSwarmServer(t, func(api *api.API, pinAPI *pin.API) TestServer {
+		server = NewServer(api, pinAPI, "")
+		return server
+	}, nil, nil)
+	defer srv.Close()
+	signer, _, _ := newTestSigner()
+
+	topic, _ := feed.NewTopic("foo.eth", nil)
+	request := feed.NewFirstRequest(topic)
+	request.SetData([]byte("foo"))
+	if err := request.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	b := postFeedUpdate(t, srv, request, true)
+	manifestAddr := &storage.Address{}
+	if err := json.Unmarshal(b, manifestAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dial := func() *rpc.Client {
+		client, err := rpc.DialWebsocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/bzz-ws", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	subscribe := func(client *rpc.Client) (*rpc.ClientSubscription, error) {
+		return client.Subscribe(ctx, "bzz", make(chan FeedUpdate, 10), "feedManifest", manifestAddr.Hex())
+	}
+	// waitFeeds waits for the expected number of subscriptions and pollers
+	waitFeeds := func(count, pollers int) {
+		t.Helper()
+		for {
+			server.feeds.mu.Lock()
+			c, p := server.feeds.count, len(server.feeds.pollers)
+			server.feeds.mu.Unlock()
+			if c == count && p == pollers {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				t.Fatalf("got %v subscriptions and %v pollers, want %v and %v", c, p, count, pollers)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	client := dial()
+	defer client.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := subscribe(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := subscribe(client); err == nil || err.Error() != errFeedSubscriptionLimit.Error() {
+		t.Fatalf("expected error %v over the connection limit, got %v", errFeedSubscriptionLimit, err)
+	}
+
+	other := dial()
+	defer other.Close()
+	sub, err := subscribe(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subscribe(other); err == nil || err.Error() != errFeedSubscriptionLimit.Error() {
+		t.Fatalf("expected error %v over the total limit, got %v", errFeedSubscriptionLimit, err)
+	}
+	waitFeeds(3, 1)
+
+	// subscriptions are released when unsubscribed or when the connection is closed
+	sub.Unsubscribe()
+	waitFeeds(2, 1)
+	if _, err := subscribe(other); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	waitFeeds(1, 1)
+	other.Close()
+	waitFeeds(0, 0)
+}
+
+func postFeedUpdate(t *testing.T, srv *TestSwarmServer, request *feed.Request, manifest bool) []byte {
+	t.Helper()
+	u, err := url.Parse(srv.URL + "/bzz-feed:/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	body := request.AppendValues(query)
+	if manifest {
+		query.Set("manifest", "1")
+	}
+	u.RawQuery = query.Encode()
+	resp, err := http.Post(u.String(), "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("feed update returned %s", resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
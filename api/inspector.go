@@ -23,17 +23,27 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/stream"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/shed"
 	"github.com/ethersphere/swarm/storage"
 	"github.com/ethersphere/swarm/storage/localstore"
 )
 
 const InspectorIsPullSyncingTolerance = 15 * time.Second
 
+const (
+	InspectorProbeConcurrency = 8                // number of chunks probed at the same time by ProbeChunks
+	InspectorProbeTimeout     = 10 * time.Second // time ProbeChunks waits for a chunk to be delivered
+)
+
 type Inspector struct {
 	api      *API
 	hive     *network.Hive
@@ -56,6 +66,12 @@ func (i *Inspector) KademliaInfo() network.KademliaInfo {
 	return i.hive.KademliaInfo()
 }
 
+// KademliaTable returns the full kademlia table with bins, peers and their
+// connectivity, also as a graph that can be shown by the network visualizer
+func (i *Inspector) KademliaTable() network.KademliaTable {
+	return i.hive.Table()
+}
+
 func (i *Inspector) IsPushSynced(tagname string) bool {
 	tags := i.api.Tags.All()
 
@@ -99,10 +115,11 @@ func (i *Inspector) DeliveriesPerPeer() map[string]int64 {
 // Has checks whether each chunk address is present in the underlying datastore,
 // the bool in the returned structs indicates if the underlying datastore has
 // the chunk stored with the given address (true), or not (false)
-func (i *Inspector) Has(chunkAddresses []storage.Address) string {
+func (i *Inspector) Has(ctx context.Context, chunkAddresses []storage.Address) string {
+	ctx = NewRPCRequest(ctx, "bzz_has")
 	hostChunks := []string{}
 	for _, addr := range chunkAddresses {
-		has, err := i.netStore.Has(context.Background(), addr)
+		has, err := i.netStore.Has(ctx, addr)
 		if err != nil {
 			log.Error(err.Error())
 		}
@@ -116,6 +133,31 @@ func (i *Inspector) Has(chunkAddresses []storage.Address) string {
 	return strings.Join(hostChunks, "")
 }
 
+// ProbeChunks queries the network for the chunks with the given addresses,
+// bypassing the local store, and reports whether and how fast each of them
+// was retrieved
+func (i *Inspector) ProbeChunks(ctx context.Context, chunkAddresses []storage.Address) (*storage.ProbeReport, error) {
+	ctx = NewRPCRequest(ctx, "bzz_probeChunks")
+	prober := storage.NewProber(i.netStore.Probe, InspectorProbeConcurrency, InspectorProbeTimeout)
+	return prober.Probe(ctx, chunkAddresses...)
+}
+
+// PublishName points the ENS name at the content hash with a transaction
+// of the node account and waits until it is mined. The name is registered and
+// the resolver is set if needed, see API.PublishName
+func (i *Inspector) PublishName(ctx context.Context, name string, hash storage.Address, resolver common.Address) error {
+	ctx = NewRPCRequest(ctx, "bzz_publishName")
+	return i.api.PublishName(ctx, name, hash, resolver)
+}
+
+// NewRPCRequest sets a new request identifier in the context of an RPC request,
+// so that its logs in the API and storage layers can be correlated
+func NewRPCRequest(ctx context.Context, method string) context.Context {
+	ctx = sctx.SetRequestID(ctx, sctx.NewRequestID())
+	log.Request(ctx).Info("created ruid for rpc request", "method", method)
+	return ctx
+}
+
 func (i *Inspector) PeerStreams() (string, error) {
 	peerInfo, err := i.stream.PeerInfo()
 	if err != nil {
@@ -131,3 +173,90 @@ func (i *Inspector) PeerStreams() (string, error) {
 func (i *Inspector) StorageIndices() (map[string]int, error) {
 	return i.ls.DebugIndices()
 }
+
+// StorageIndexStats returns the approximate number of keys and size on disk of every index of the local store
+func (i *Inspector) StorageIndexStats() (map[string]shed.IndexStats, error) {
+	return i.ls.IndexStats()
+}
+
+// CompactStorageIndex compacts the keys of the named index of the local store that start
+// with the prefix, the whole index if it is empty. Compacting after large deletions,
+// like garbage collection runs, reclaims disk space and speeds up reads.
+func (i *Inspector) CompactStorageIndex(name string, prefix hexutil.Bytes) error {
+	return i.ls.CompactIndex(name, prefix)
+}
+
+// ProximityHistogram returns the number of stored chunks in every proximity order
+// bin relative to the node's address, together with the storage radius derived from it
+func (i *Inspector) ProximityHistogram() (*ProximityHistogram, error) {
+	histogram, err := i.ls.ProximityHistogram()
+	if err != nil {
+		return nil, err
+	}
+	radius, err := i.ls.StorageRadius()
+	if err != nil {
+		return nil, err
+	}
+	return &ProximityHistogram{Bins: histogram, Radius: radius}, nil
+}
+
+// ProximityHistogram is the number of stored chunks per proximity order bin
+// and the lowest bin the node has the capacity to hold
+type ProximityHistogram struct {
+	Bins   []uint64 `json:"bins"`
+	Radius uint8    `json:"radius"`
+}
+
+// InspectorMaxSampleSize is the maximal number of chunks sampled by SampleChunks
+const InspectorMaxSampleSize = 1000
+
+// SampleChunks returns a random sample of up to n stored chunks with proximity
+// order to the node's address from minPO to maxPO, each with a proof of its
+// possession for the nonce of the caller, see PossessionProof. It lets auditors
+// spot-check that the node stores the chunks of its neighbourhood.
+func (i *Inspector) SampleChunks(minPO, maxPO uint8, n int, nonce hexutil.Bytes) ([]ChunkPossession, error) {
+	if n > InspectorMaxSampleSize {
+		n = InspectorMaxSampleSize
+	}
+	addrs, err := i.ls.Sample(minPO, maxPO, n)
+	if err != nil {
+		return nil, err
+	}
+	sample := make([]ChunkPossession, 0, len(addrs))
+	for _, addr := range addrs {
+		// the chunks are only looked up, so that sampling does not change their gc order
+		ch, err := i.ls.Get(context.Background(), chunk.ModeGetLookup, addr)
+		if err == chunk.ErrChunkNotFound {
+			// removed by the garbage collection since sampled
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sample = append(sample, ChunkPossession{
+			Address: addr,
+			Proof:   PossessionProof(ch.Data(), nonce),
+		})
+	}
+	return sample, nil
+}
+
+// ChunkPossession is the address of a sampled chunk and the proof of its possession
+type ChunkPossession struct {
+	Address storage.Address `json:"address"`
+	Proof   hexutil.Bytes   `json:"proof"`
+}
+
+// PossessionProof returns the proof of the possession of the chunk data for
+// the nonce, the keccak256 hash of the data concatenated with the nonce
+func PossessionProof(data, nonce []byte) []byte {
+	return crypto.Keccak256(data, nonce)
+}
+
+// SimulateGC reports which chunks a garbage collection run would remove to reduce
+// the number of garbage collectable chunks to target, without removing anything.
+// A target of 0 uses the target of regular garbage collection runs. Evicted chunks
+// stored within the last recentSeconds are reported as recent.
+func (i *Inspector) SimulateGC(target uint64, recentSeconds uint64) (*localstore.GCSimulation, error) {
+	return i.ls.SimulateGarbageCollection(target, time.Duration(recentSeconds)*time.Second)
+}
@@ -26,6 +26,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -41,9 +42,15 @@ const (
 	manifestSizeLimit = 5 * 1024 * 1024
 )
 
+// manifestConcurrency is the number of trie nodes of a manifest that are
+// retrieved or stored in parallel by an operation on the whole trie
+var manifestConcurrency = 8
+
 // Manifest represents a swarm manifest
 type Manifest struct {
 	Entries []ManifestEntry `json:"entries,omitempty"`
+	// paths of the entries retrieved in the background when the root of the manifest is served
+	Prefetch []string `json:"prefetch,omitempty"`
 }
 
 // ManifestEntry represents an entry in a swarm manifest
@@ -57,6 +64,12 @@ type ManifestEntry struct {
 	Status      int          `json:"status,omitempty"`
 	Access      *AccessEntry `json:"access,omitempty"`
 	Feed        *feed.Feed   `json:"feed,omitempty"`
+	Data        []byte       `json:"data,omitempty"` // content of small files inlined in the manifest, see API.SetInlineSize
+
+	// optional HTTP metadata sent with the content of the entry
+	CacheControl    string            `json:"cacheControl,omitempty"`    // value of the Cache-Control header
+	ContentEncoding string            `json:"contentEncoding,omitempty"` // encoding of pre-compressed content, e.g. gzip
+	Headers         map[string]string `json:"headers,omitempty"`         // additional response headers, only Cache-Control, Content-Disposition, Content-Language and Link are sent
 }
 
 // ManifestList represents the result of listing files in a manifest
@@ -105,25 +118,46 @@ func (a *API) NewFeedManifest(ctx context.Context, feed *feed.Feed) (storage.Add
 
 // ManifestWriter is used to add and remove entries from an underlying manifest
 type ManifestWriter struct {
-	api   *API
-	trie  *manifestTrie
-	quitC chan bool
+	api       *API
+	trie      *manifestTrie
+	quitC     chan bool
+	masterKey []byte // encrypts the references of the added entries, see SetMasterKey
 }
 
 func (a *API) NewManifestWriter(ctx context.Context, addr storage.Address, quitC chan bool) (*ManifestWriter, error) {
+	if err := a.checkWritable(); err != nil {
+		return nil, err
+	}
 	trie, err := loadManifest(ctx, a.fileStore, addr, quitC, NOOPDecrypt)
 	if err != nil {
 		return nil, fmt.Errorf("error loading manifest %s: %s", addr, err)
 	}
-	return &ManifestWriter{a, trie, quitC}, nil
+	return &ManifestWriter{api: a, trie: trie, quitC: quitC}, nil
 }
 
+// MaxManifestInlineSize is the largest size of the content inlined in a manifest entry,
+// it is well below the size of a chunk so that the manifest entries around it still fit
+// the chunk of the manifest after the data is encoded
+const MaxManifestInlineSize = 1024
+
 // AddEntry stores the given data and adds the resulting address to the manifest
+// The data of files up to the inline size of the API is also inlined in the entry
+// and set as the Data of e. It is still stored, so that the entry can be read by
+// nodes which retrieve the content by its hash.
 func (m *ManifestWriter) AddEntry(ctx context.Context, data io.Reader, e *ManifestEntry) (addr storage.Address, err error) {
 	entry := newManifestTrieEntry(e, nil)
+	if data != nil && m.inline(e) {
+		buf := make([]byte, e.Size)
+		if _, err := io.ReadFull(data, buf); err != nil {
+			return nil, fmt.Errorf("error reading inlined content: %v", err)
+		}
+		data = bytes.NewReader(buf)
+		entry.Data = buf
+		e.Data = buf
+	}
 	if data != nil {
 		var wait func(context.Context) error
-		addr, wait, err = m.api.Store(ctx, data, e.Size, m.trie.encrypted)
+		addr, wait, err = m.api.Store(ctx, data, e.Size, m.trie.encrypted || m.masterKey != nil)
 		if err != nil {
 			return nil, err
 		}
@@ -136,16 +170,125 @@ func (m *ManifestWriter) AddEntry(ctx context.Context, data io.Reader, e *Manife
 	if entry.Hash == "" {
 		return addr, errors.New("missing entry hash")
 	}
+	if m.masterKey != nil && entry.ContentType != ManifestType && entry.Access == nil {
+		if err := encryptMasterEntry(&entry.ManifestEntry, m.masterKey); err != nil {
+			return nil, err
+		}
+	}
 	m.trie.addEntry(entry, m.quitC)
 	return addr, nil
 }
 
+// inline returns true if the content of the entry is inlined in the manifest
+// The entries encrypted with the master key or under access control are not
+// inlined, as the content would be readable without the keys, and neither are
+// the entries of encrypted manifests, as the inlined data could not be verified
+// against the encrypted reference of the content
+func (m *ManifestWriter) inline(e *ManifestEntry) bool {
+	return e.Size > 0 && e.Size <= m.api.inline && e.ContentType != ManifestType && e.Access == nil && m.masterKey == nil && !m.trie.encrypted
+}
+
+// AddFeed mounts the feed at the given path of the manifest, so that the path
+// and everything below it resolves to the latest update of the feed
+func (m *ManifestWriter) AddFeed(path string, fd *feed.Feed) error {
+	if fd == nil {
+		return errors.New("missing feed")
+	}
+	entry := newManifestTrieEntry(&ManifestEntry{
+		Path:        RegularSlashes(path),
+		ContentType: FeedContentType,
+		Feed:        fd,
+	}, nil)
+	return m.trie.addEntry(entry, m.quitC)
+}
+
 // RemoveEntry removes the given path from the manifest
 func (m *ManifestWriter) RemoveEntry(path string) error {
 	m.trie.deleteEntry(path, m.quitC)
 	return nil
 }
 
+// SetModTime sets the modification time of the entry at the given path
+// The content of the entry is left unchanged
+func (m *ManifestWriter) SetModTime(path string, modTime time.Time) error {
+	entry, fullpath := m.trie.getEntry(path)
+	if entry == nil || fullpath != RegularSlashes(path) || entry.ContentType == ManifestType {
+		return fmt.Errorf("manifest entry %q not found", path)
+	}
+	e := entry.ManifestEntry
+	e.Path = fullpath
+	e.ModTime = modTime
+	return m.trie.addEntry(newManifestTrieEntry(&e, nil), m.quitC)
+}
+
+// SetMode sets the permission bits of the entry at the given path
+// The content of the entry is left unchanged
+func (m *ManifestWriter) SetMode(path string, mode int64) error {
+	entry, fullpath := m.trie.getEntry(path)
+	if entry == nil || fullpath != RegularSlashes(path) || entry.ContentType == ManifestType {
+		return fmt.Errorf("manifest entry %q not found", path)
+	}
+	e := entry.ManifestEntry
+	e.Path = fullpath
+	e.Mode = mode
+	return m.trie.addEntry(newManifestTrieEntry(&e, nil), m.quitC)
+}
+
+// MoveEntry moves the entry at the given path to a new path
+// The content of the entry is left unchanged
+func (m *ManifestWriter) MoveEntry(from, to string) error {
+	entry, fullpath := m.trie.getEntry(from)
+	if entry == nil || fullpath != RegularSlashes(from) || entry.ContentType == ManifestType {
+		return fmt.Errorf("manifest entry %q not found", from)
+	}
+	e := entry.ManifestEntry
+	e.Path = RegularSlashes(to)
+	m.trie.deleteEntry(fullpath, m.quitC)
+	return m.trie.addEntry(newManifestTrieEntry(&e, nil), m.quitC)
+}
+
+// MovePrefix moves all entries with paths starting with the given prefix to
+// paths starting with the new prefix, returning the number of moved entries
+// The content of the entries is left unchanged
+func (m *ManifestWriter) MovePrefix(from, to string) (int, error) {
+	var entries []ManifestEntry
+	err := m.trie.listWithPrefix(from, m.quitC, func(entry *manifestTrieEntry, suffix string) {
+		e := entry.ManifestEntry
+		e.Path = suffix
+		entries = append(entries, e)
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		m.trie.deleteEntry(from+e.Path, m.quitC)
+	}
+	for i := range entries {
+		e := &entries[i]
+		e.Path = RegularSlashes(to + e.Path)
+		if err := m.trie.addEntry(newManifestTrieEntry(e, nil), m.quitC); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// RemovePrefix removes all entries with paths starting with the given prefix
+// from the manifest, returning the number of removed entries
+func (m *ManifestWriter) RemovePrefix(prefix string) (int, error) {
+	var paths []string
+	err := m.trie.listWithPrefix(prefix, m.quitC, func(entry *manifestTrieEntry, suffix string) {
+		paths = append(paths, prefix+suffix)
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range paths {
+		m.trie.deleteEntry(path, m.quitC)
+	}
+	return len(paths), nil
+}
+
 // Store stores the manifest, returning the resulting storage address
 func (m *ManifestWriter) Store() (storage.Address, error) {
 	return m.trie.ref, m.trie.recalcAndStore()
@@ -213,6 +356,28 @@ type manifestTrie struct {
 	ref       storage.Address         // if ref != nil, it is stored
 	encrypted bool
 	decrypt   DecryptFunc
+	prefetch  []string // prefetch list of the manifest
+}
+
+// inlineReader serves the content inlined in a manifest entry
+type inlineReader struct {
+	*bytes.Reader
+	ctx context.Context
+}
+
+func newInlineReader(ctx context.Context, data []byte) *inlineReader {
+	return &inlineReader{
+		Reader: bytes.NewReader(data),
+		ctx:    ctx,
+	}
+}
+
+func (r *inlineReader) Size(context.Context, chan bool) (int64, error) {
+	return r.Reader.Size(), nil
+}
+
+func (r *inlineReader) Context() context.Context {
+	return r.ctx
 }
 
 func newManifestTrieEntry(entry *ManifestEntry, subtrie *manifestTrie) *manifestTrieEntry {
@@ -262,7 +427,8 @@ func readManifest(mr storage.LazySectionReader, addr storage.Address, fileStore
 
 	log.Debug("manifest retrieved", "addr", addr)
 	var man struct {
-		Entries []*manifestTrieEntry `json:"entries"`
+		Entries  []*manifestTrieEntry `json:"entries"`
+		Prefetch []string             `json:"prefetch"`
 	}
 	err = json.Unmarshal(manifestData, &man)
 	if err != nil {
@@ -277,6 +443,7 @@ func readManifest(mr storage.LazySectionReader, addr storage.Address, fileStore
 		fileStore: fileStore,
 		encrypted: isEncrypted,
 		decrypt:   decrypt,
+		prefetch:  man.Prefetch,
 	}
 	for _, entry := range man.Entries {
 		err = trie.addEntry(entry, quitC)
@@ -333,6 +500,7 @@ func (mt *manifestTrie) addEntry(entry *manifestTrieEntry, quitC chan bool) erro
 	subtrie := &manifestTrie{
 		fileStore: mt.fileStore,
 		encrypted: mt.encrypted,
+		decrypt:   mt.decrypt,
 	}
 	entry.Path = entry.Path[cpl:]
 	oldentry.Path = oldentry.Path[cpl:]
@@ -392,27 +560,69 @@ func (mt *manifestTrie) deleteEntry(path string, quitC chan bool) {
 	}
 }
 
+// nodeWorkers bounds the number of trie nodes processed in parallel by an operation on a manifest
+type nodeWorkers chan struct{}
+
+func newNodeWorkers() nodeWorkers {
+	return make(nodeWorkers, manifestConcurrency)
+}
+
+// run calls f in a new goroutine if a worker is free and in the calling goroutine
+// otherwise, so that the nested calls on subtries cannot deadlock waiting for workers
+func (w nodeWorkers) run(wg *sync.WaitGroup, f func()) {
+	wg.Add(1)
+	select {
+	case w <- struct{}{}:
+		go func() {
+			defer func() {
+				<-w
+				wg.Done()
+			}()
+			f()
+		}()
+	default:
+		defer wg.Done()
+		f()
+	}
+}
+
+// recalcAndStore stores the modified nodes of the trie, the subtries of a node in parallel
 func (mt *manifestTrie) recalcAndStore() error {
+	return mt.recalcAndStoreWith(newNodeWorkers())
+}
+
+func (mt *manifestTrie) recalcAndStoreWith(workers nodeWorkers) error {
 	if mt.ref != nil {
 		return nil
 	}
 
-	var buffer bytes.Buffer
-	buffer.WriteString(`{"entries":[`)
+	var wg sync.WaitGroup
+	var errs [len(mt.entries)]error
+	for i, entry := range &mt.entries {
+		// feed entries have no hash, they reference the feed instead
+		if entry != nil && entry.Hash == "" && entry.ContentType != FeedContentType {
+			i, entry := i, entry
+			workers.run(&wg, func() {
+				if errs[i] = entry.subtrie.recalcAndStoreWith(workers); errs[i] == nil {
+					entry.Hash = entry.subtrie.ref.Hex()
+				}
+			})
+		}
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 
-	list := &Manifest{}
+	// the entries are listed in the order of the trie, so that the
+	// hash of the node does not depend on the order the subtries are stored
+	list := &Manifest{Prefetch: mt.prefetch}
 	for _, entry := range &mt.entries {
 		if entry != nil {
-			if entry.Hash == "" { // TODO: paralellize
-				err := entry.subtrie.recalcAndStore()
-				if err != nil {
-					return err
-				}
-				entry.Hash = entry.subtrie.ref.Hex()
-			}
 			list.Entries = append(list.Entries, entry.ManifestEntry)
 		}
-
 	}
 
 	manifest, err := json.Marshal(list)
@@ -496,7 +706,43 @@ func (mt *manifestTrie) listWithPrefixInt(prefix, rp string, quitC chan bool, cb
 	return nil
 }
 
+// loadSubTries retrieves the subtries listed with the prefix in parallel
+func (mt *manifestTrie) loadSubTries(prefix string, workers nodeWorkers, quitC chan bool) error {
+	plen := len(prefix)
+	var wg sync.WaitGroup
+	var errs [len(mt.entries)]error
+	for i, entry := range &mt.entries {
+		if entry == nil || entry.ContentType != ManifestType {
+			continue
+		}
+		l := plen
+		if len(entry.Path) < l {
+			l = len(entry.Path)
+		}
+		if prefix[:l] != entry.Path[:l] {
+			continue
+		}
+		i, entry := i, entry
+		workers.run(&wg, func() {
+			if errs[i] = mt.loadSubTrie(entry, quitC); errs[i] == nil {
+				errs[i] = entry.subtrie.loadSubTries(prefix[l:], workers, quitC)
+			}
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (mt *manifestTrie) listWithPrefix(prefix string, quitC chan bool, cb func(entry *manifestTrieEntry, suffix string)) (err error) {
+	// the subtries are retrieved in parallel before the entries are listed in order
+	if err := mt.loadSubTries(prefix, newNodeWorkers(), quitC); err != nil {
+		return err
+	}
 	return mt.listWithPrefixInt(prefix, "", quitC, cb)
 }
 
@@ -553,6 +799,10 @@ func (mt *manifestTrie) findPrefixOf(path string, quitC chan bool) (entry *manif
 				entry.Status = http.StatusMultipleChoices
 			}
 
+		} else if entry.ContentType == FeedContentType && path[epl] == '/' {
+			// a feed mounted inside the manifest, the rest of the path
+			// is resolved in the latest update of the feed
+			return entry, epl
 		} else {
 			//entry is not a manifest, return it
 			if path != entry.Path {
@@ -18,12 +18,15 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/storage"
@@ -174,3 +177,246 @@ func TestReadManifestOverSizeLimit(t *testing.T) {
 		t.Fatalf("got error mesage %q, expected %q", got, want)
 	}
 }
+
+// TestManifestPrefetch tests that the prefetch list of a manifest is stored,
+// kept when the manifest is updated and that its entries can be prefetched
+func TestManifestPrefetch(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		ctx := context.TODO()
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefetch := ParsePrefetchList(" /app.js, style.css,,")
+		if !reflect.DeepEqual(prefetch, []string{"app.js", "style.css"}) {
+			t.Fatalf("unexpected prefetch list %v", prefetch)
+		}
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			for _, path := range []string{"index.html", "app.js", "style.css"} {
+				content := "content of " + path
+				entry := &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(len(content))}
+				if _, err := mw.AddEntry(ctx, strings.NewReader(content), entry); err != nil {
+					return err
+				}
+			}
+			mw.SetPrefetch(prefetch)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the prefetch list is kept when entries are changed
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			return mw.RemoveEntry("index.html")
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		trie, err := loadManifest(ctx, api.fileStore, addr, nil, NOOPDecrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(trie.prefetch, prefetch) {
+			t.Fatalf("expected prefetch list %v, got %v", prefetch, trie.prefetch)
+		}
+
+		prefetched, err := api.Prefetch(ctx, NOOPDecrypt, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefetched != 2 {
+			t.Fatalf("expected 2 entries prefetched, got %v", prefetched)
+		}
+
+		// entries that do not fit in the size left for the manifest are not prefetched
+		defer func(size int64) { maxPrefetchTotalSize = size }(maxPrefetchTotalSize)
+		maxPrefetchTotalSize = int64(len("content of app.js"))
+		prefetched, err = api.Prefetch(ctx, NOOPDecrypt, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefetched != 1 {
+			t.Fatalf("expected 1 entry prefetched, got %v", prefetched)
+		}
+	})
+}
+
+// TestManifestPrefetchInBackground tests that the prefetch in the background
+// outlives the context it is started with and is not repeated for the same manifest
+func TestManifestPrefetchInBackground(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		ctx := context.TODO()
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			for _, path := range []string{"app.js", "style.css"} {
+				content := "content of " + path
+				entry := &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(len(content))}
+				if _, err := mw.AddEntry(ctx, strings.NewReader(content), entry); err != nil {
+					return err
+				}
+			}
+			mw.SetPrefetch([]string{"app.js", "style.css"})
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		type result struct {
+			prefetched int
+			err        error
+		}
+		results := make(chan result, 2)
+		defer func(hook func(storage.Address, int, error)) { testHookPrefetch = hook }(testHookPrefetch)
+		testHookPrefetch = func(_ storage.Address, prefetched int, err error) {
+			results <- result{prefetched, err}
+		}
+
+		// the request the prefetch is started for ends right away
+		reqCtx, cancel := context.WithCancel(ctx)
+		api.PrefetchInBackground(reqCtx, "", addr)
+		cancel()
+		api.PrefetchInBackground(ctx, "", addr)
+
+		select {
+		case r := <-results:
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			if r.prefetched != 2 {
+				t.Fatalf("expected 2 entries prefetched, got %v", r.prefetched)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for the prefetch")
+		}
+		select {
+		case <-results:
+			t.Fatal("manifest prefetched again")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+// TestManifestParallelLoadAndStore tests that storing the subtries of a manifest in parallel
+// results in the same root hash as storing them sequentially, and that all entries
+// are listed after the subtries are retrieved in parallel
+func TestManifestParallelLoadAndStore(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		var paths []string
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				paths = append(paths, fmt.Sprintf("dir%d/sub%d/file%d.txt", i, j, j))
+			}
+		}
+		newTrie := func() *manifestTrie {
+			trie := &manifestTrie{
+				fileStore: api.fileStore,
+				encrypted: toEncrypt,
+			}
+			for i, path := range paths {
+				entry := newManifestTrieEntry(&ManifestEntry{
+					Hash:        fmt.Sprintf("%064x", i),
+					Path:        path,
+					ContentType: "text/plain",
+				}, nil)
+				if err := trie.addEntry(entry, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return trie
+		}
+
+		defer func(c int) { manifestConcurrency = c }(manifestConcurrency)
+		manifestConcurrency = 1
+		sequential := newTrie()
+		if err := sequential.recalcAndStore(); err != nil {
+			t.Fatal(err)
+		}
+		manifestConcurrency = 8
+		parallel := newTrie()
+		if err := parallel.recalcAndStore(); err != nil {
+			t.Fatal(err)
+		}
+		// encrypted manifests are stored with random keys
+		if !toEncrypt && !bytes.Equal(sequential.ref, parallel.ref) {
+			t.Fatalf("expected root %s of parallel store to match sequential store, got %s", sequential.ref, parallel.ref)
+		}
+
+		trie, err := loadManifest(context.TODO(), api.fileStore, parallel.ref, nil, NOOPDecrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for prefix, want := range map[string]int{"": 100, "dir3/": 10, "dir3/sub4/": 1, "none/": 0} {
+			var got []string
+			err := trie.listWithPrefix(prefix, nil, func(entry *manifestTrieEntry, suffix string) {
+				got = append(got, prefix+suffix)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != want {
+				t.Fatalf("expected %d entries with prefix %q, got %d", want, prefix, len(got))
+			}
+			if prefix == "" && !reflect.DeepEqual(got, paths) {
+				t.Fatalf("expected entries in trie order %v, got %v", paths, got)
+			}
+		}
+	})
+}
+
+// TestManifestWriterMoveEntry tests that entries moved to a new path keep
+// their content, and that all entries with a prefix can be removed
+func TestManifestWriterMoveEntry(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		trie := &manifestTrie{fileStore: api.fileStore}
+		for _, path := range []string{"index.html", "img/logo.png", "img/bg.png"} {
+			if err := trie.addEntry(newManifestTrieEntry(&ManifestEntry{Hash: path, Path: path}, nil), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mw := &ManifestWriter{api: api, trie: trie}
+
+		if err := mw.MoveEntry("img/logo.png", ".trash/img/logo.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := mw.MoveEntry("img/bg.png", ".trash/img/bg.png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := mw.MoveEntry("img", ".trash/img"); err == nil {
+			t.Fatal("expected error moving an entry that does not exist")
+		}
+		checkEntry(t, "img/logo.png", "-", false, trie)
+		checkEntry(t, ".trash/img/logo.png", ".trash/img/logo.png", false, trie)
+		if entry, _ := trie.getEntry(".trash/img/logo.png"); entry.Hash != "img/logo.png" {
+			t.Fatalf("expected moved entry to keep its content, got %q", entry.Hash)
+		}
+
+		// all entries of a directory move with their prefix
+		n, err := mw.MovePrefix(".trash/img/", ".trash/images/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Fatalf("expected 2 entries to be moved, got %d", n)
+		}
+		checkEntry(t, ".trash/img/bg.png", "-", false, trie)
+		checkEntry(t, ".trash/images/bg.png", ".trash/images/bg.png", false, trie)
+		if entry, _ := trie.getEntry(".trash/images/logo.png"); entry == nil || entry.Hash != "img/logo.png" {
+			t.Fatalf("expected moved entry to keep its content, got %v", entry)
+		}
+
+		n, err = mw.RemovePrefix(".trash/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Fatalf("expected 2 entries to be removed, got %d", n)
+		}
+		checkEntry(t, ".trash/img/logo.png", "-", false, trie)
+		checkEntry(t, "index.html", "index.html", false, trie)
+	})
+}
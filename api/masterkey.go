@@ -0,0 +1,181 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// MasterKeyLength is the length of the master key of a manifest
+const MasterKeyLength = 32
+
+var errMasterKeyLength = fmt.Errorf("master key should be %d bytes long", MasterKeyLength)
+
+// NewAccessEntryMaster creates a manifest AccessEntry for an entry whose reference
+// is encrypted with a key derived from the master key of the manifest
+func NewAccessEntryMaster(salt []byte) (*AccessEntry, error) {
+	if len(salt) != 32 {
+		return nil, fmt.Errorf("salt should be 32 bytes long")
+	}
+	return &AccessEntry{
+		Type: AccessTypeMaster,
+		Salt: salt,
+	}, nil
+}
+
+// NewSessionKeyMaster derives the key of a manifest entry from the master key of
+// the manifest and the salt of the entry, so that every entry is encrypted with a
+// different key and sharing the master key grants access to all of them
+func NewSessionKeyMaster(masterKey []byte, accessEntry *AccessEntry) ([]byte, error) {
+	if accessEntry.Type != AccessTypeMaster {
+		return nil, errors.New("incorrect access entry type")
+	}
+	if len(masterKey) != MasterKeyLength {
+		return nil, errMasterKeyLength
+	}
+	return crypto.Keccak256(accessEntry.Salt, masterKey), nil
+}
+
+// ParseMasterKey decodes a hex encoded master key
+func ParseMasterKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != MasterKeyLength {
+		return nil, errMasterKeyLength
+	}
+	return key, nil
+}
+
+// decryptMasterEntry decrypts the reference of a manifest entry encrypted with a
+// key derived from the master key
+func decryptMasterEntry(m *ManifestEntry, masterKey []byte) error {
+	key, err := NewSessionKeyMaster(masterKey, m.Access)
+	if err != nil {
+		return ErrDecrypt
+	}
+	ref, err := hex.DecodeString(m.Hash)
+	if err != nil {
+		return err
+	}
+	enc := NewRefEncryption(len(ref) - 8)
+	decodedRef, err := enc.Decrypt(ref, key)
+	if err != nil {
+		return ErrDecrypt
+	}
+	m.Hash = hex.EncodeToString(decodedRef)
+	m.Access = nil
+	return nil
+}
+
+// encryptMasterEntry encrypts the reference of a manifest entry with a key derived
+// from the master key, keeping the salt of the entry if it already has one
+func encryptMasterEntry(m *ManifestEntry, masterKey []byte) error {
+	ae := m.Access
+	if ae == nil {
+		salt := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return err
+		}
+		var err error
+		if ae, err = NewAccessEntryMaster(salt); err != nil {
+			return err
+		}
+	}
+	key, err := NewSessionKeyMaster(masterKey, ae)
+	if err != nil {
+		return err
+	}
+	ref, err := hex.DecodeString(m.Hash)
+	if err != nil {
+		return err
+	}
+	enc := NewRefEncryption(len(ref))
+	encrypted, err := enc.Encrypt(ref, key)
+	if err != nil {
+		return err
+	}
+	m.Hash = hex.EncodeToString(encrypted)
+	m.Access = ae
+	return nil
+}
+
+// SetMasterKey sets the master key of the manifest, the content of the entries
+// added afterwards is encrypted and their references are encrypted with keys
+// derived from the master key instead of being stored in the manifest
+func (m *ManifestWriter) SetMasterKey(key []byte) error {
+	if len(key) != MasterKeyLength {
+		return errMasterKeyLength
+	}
+	m.masterKey = key
+	return nil
+}
+
+// RewrapKeys encrypts the references of the entries encrypted with the old master
+// key with the new master key, returning the number of entries rewrapped
+// Only the manifest changes, the content of the entries is not uploaded again, so
+// it is encrypted with the same content keys. Rewrapping is not revocation: the
+// holders of the old master key can still decrypt the old manifest, and the content
+// with the content keys they decrypted from it. Revoking access to the content
+// requires uploading it again under a new master key.
+func (m *ManifestWriter) RewrapKeys(oldKey, newKey []byte) (int, error) {
+	var entries []ManifestEntry
+	err := m.trie.listWithPrefix("", m.quitC, func(entry *manifestTrieEntry, suffix string) {
+		if entry.Access != nil && entry.Access.Type == AccessTypeMaster {
+			e := entry.ManifestEntry
+			e.Path = suffix
+			entries = append(entries, e)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := range entries {
+		e := &entries[i]
+		ae := e.Access
+		if err := decryptMasterEntry(e, oldKey); err != nil {
+			return 0, fmt.Errorf("manifest entry %q: %v", e.Path, err)
+		}
+		e.Access = ae
+		if err := encryptMasterEntry(e, newKey); err != nil {
+			return 0, err
+		}
+		if err := m.trie.addEntry(newManifestTrieEntry(e, nil), m.quitC); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// RewrapManifest rotates the master key of a manifest, returning the address of
+// the manifest with the references of its entries encrypted with the new key
+// It does not revoke the access of the holders of the old key, see RewrapKeys
+func (a *API) RewrapManifest(ctx context.Context, addr storage.Address, oldKey, newKey []byte) (storage.Address, error) {
+	return a.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+		_, err := mw.RewrapKeys(oldKey, newKey)
+		return err
+	})
+}
@@ -0,0 +1,109 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestMasterKey tests that the entries added with a master key can only be
+// retrieved with the master key, and that rotating the master key rewraps the
+// references of the entries without changing their content
+func TestMasterKey(t *testing.T) {
+	testAPI(t, func(api *API, _ *chunk.Tags, toEncrypt bool) {
+		ctx := sctx.SetHost(context.TODO(), "localhost")
+		oldKey := bytes.Repeat([]byte{1}, MasterKeyLength)
+		newKey := bytes.Repeat([]byte{2}, MasterKeyLength)
+
+		addr, err := api.NewManifest(ctx, toEncrypt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr, err = api.UpdateManifest(ctx, addr, func(mw *ManifestWriter) error {
+			if err := mw.SetMasterKey(oldKey); err != nil {
+				return err
+			}
+			for _, path := range []string{"index.html", "img/logo.png"} {
+				content := strings.NewReader(path)
+				_, err := mw.AddEntry(ctx, content, &ManifestEntry{Path: path, ContentType: "text/plain", Size: int64(content.Len())})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		get := func(addr storage.Address, key []byte, path string) (storage.Address, error) {
+			reader, _, _, contentAddr, err := api.Get(ctx, api.Decryptor(ctx, hex.EncodeToString(key)), addr, path)
+			if err != nil {
+				return nil, err
+			}
+			content, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return nil, err
+			}
+			if string(content) != path {
+				t.Fatalf("expected content %q, got %q", path, content)
+			}
+			return contentAddr, nil
+		}
+		contentAddr, err := get(addr, oldKey, "img/logo.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(contentAddr) != 64 {
+			t.Fatalf("expected content to be encrypted, got reference %v", contentAddr)
+		}
+		if _, err := get(addr, newKey, "img/logo.png"); err == nil {
+			t.Fatal("expected error retrieving entry with a different master key")
+		}
+
+		rotated, err := api.RewrapManifest(ctx, addr, oldKey, newKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotatedAddr, err := get(rotated, newKey, "img/logo.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rotatedAddr, contentAddr) {
+			t.Fatalf("expected content %v to be kept on rotation, got %v", contentAddr, rotatedAddr)
+		}
+		if _, err := get(rotated, newKey, "index.html"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := get(rotated, oldKey, "index.html"); err == nil {
+			t.Fatal("expected error retrieving entry with the rotated master key")
+		}
+		// rewrapping is not revocation, the old manifest is still readable with the old key
+		if _, err := get(addr, oldKey, "index.html"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/sctx"
+	"github.com/ethersphere/swarm/storage"
+)
+
+var (
+	// maxPrefetchPaths is the number of paths of the prefetch list of a manifest that are retrieved
+	maxPrefetchPaths = 64
+	// maxPrefetchSize is the size of the largest entry that is prefetched
+	maxPrefetchSize int64 = 4 * 1024 * 1024
+	// maxPrefetchTotalSize is the number of bytes prefetched at most for a manifest
+	maxPrefetchTotalSize int64 = 16 * 1024 * 1024
+	// prefetchConcurrency is the number of entries retrieved in parallel
+	prefetchConcurrency = 8
+	// maxBackgroundPrefetches is the number of manifests whose prefetch lists are retrieved
+	// in the background at the same time, serving more roots does not trigger prefetching
+	maxBackgroundPrefetches = 16
+	// prefetchInterval is the time after the prefetch of a manifest during which
+	// serving its root does not trigger it again
+	prefetchInterval = 10 * time.Minute
+	// prefetchTimeout is the time allowed for retrieving the prefetch list of a manifest
+	prefetchTimeout = time.Minute
+
+	apiPrefetchCount   = metrics.NewRegisteredCounter("api/prefetch/count", nil)
+	apiPrefetchFail    = metrics.NewRegisteredCounter("api/prefetch/fail", nil)
+	apiPrefetchSkipped = metrics.NewRegisteredCounter("api/prefetch/skipped", nil)
+)
+
+// SetPrefetch sets the paths of the manifest entries likely needed together
+// with the root of the manifest, e.g. the scripts, styles and images of a website
+// They are retrieved in the background when the root of the manifest is served
+func (m *ManifestWriter) SetPrefetch(paths []string) {
+	m.trie.prefetch = paths
+	m.trie.ref = nil
+}
+
+// ParsePrefetchList splits a comma separated list of paths into a prefetch list
+func ParsePrefetchList(s string) (paths []string) {
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimLeft(strings.TrimSpace(p), "/"); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// PrefetchInBackground retrieves the prefetch list of the manifest in the background
+// so that the chunks of the entries are available locally once the client requests them
+// The prefetch outlives the request, so it only keeps the host of the request context,
+// which the decryption of the entries is allowed for, and the credentials.
+// Calls for a manifest prefetched within prefetchInterval and calls while
+// maxBackgroundPrefetches are in progress are ignored.
+func (a *API) PrefetchInBackground(ctx context.Context, credentials string, manifestAddr storage.Address) {
+	key := manifestAddr.Hex()
+	now := time.Now()
+
+	a.prefetchMu.Lock()
+	if a.prefetched == nil {
+		a.prefetched = make(map[string]time.Time)
+	}
+	if started, ok := a.prefetched[key]; (ok && now.Sub(started) < prefetchInterval) || a.prefetches >= maxBackgroundPrefetches {
+		a.prefetchMu.Unlock()
+		apiPrefetchSkipped.Inc(1)
+		return
+	}
+	for k, started := range a.prefetched {
+		if now.Sub(started) >= prefetchInterval {
+			delete(a.prefetched, k)
+		}
+	}
+	a.prefetched[key] = now
+	a.prefetches++
+	a.prefetchMu.Unlock()
+
+	// the prefetch outlives the request, it keeps only its host and identifier
+	detached := sctx.SetHost(context.Background(), sctx.GetHost(ctx))
+	if ruid := sctx.GetRequestID(ctx); ruid != "" {
+		detached = sctx.SetRequestID(detached, ruid)
+	}
+	ctx, cancel := context.WithTimeout(detached, prefetchTimeout)
+	decrypt := a.Decryptor(ctx, credentials)
+	go func() {
+		defer cancel()
+
+		prefetched, err := a.Prefetch(ctx, decrypt, manifestAddr)
+		if err != nil {
+			log.Debug("api.prefetch", "manifest", manifestAddr, "err", err)
+		}
+
+		a.prefetchMu.Lock()
+		a.prefetches--
+		a.prefetchMu.Unlock()
+
+		if testHookPrefetch != nil {
+			testHookPrefetch(manifestAddr, prefetched, err)
+		}
+	}()
+}
+
+// testHookPrefetch is called with the result of every prefetch in the background
+var testHookPrefetch func(manifestAddr storage.Address, prefetched int, err error)
+
+// Prefetch retrieves the content of the paths in the prefetch list of the manifest
+// and returns the number of entries retrieved
+// Entries that cannot be resolved or are larger than maxPrefetchSize are skipped, as well
+// as the ones that do not fit in maxPrefetchTotalSize with the entries retrieved before
+func (a *API) Prefetch(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address) (prefetched int, err error) {
+	trie, err := loadManifest(ctx, a.fileStore, manifestAddr, nil, decrypt)
+	if err != nil {
+		return 0, &ManifestNotFoundError{Addr: manifestAddr, Err: err}
+	}
+	paths := trie.prefetch
+	if len(paths) > maxPrefetchPaths {
+		paths = paths[:maxPrefetchPaths]
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		count int
+	)
+	budget := maxPrefetchTotalSize
+	// reserve takes size from the budget of the manifest if it fits
+	reserve := func(size int64) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if size > budget {
+			return false
+		}
+		budget -= size
+		return true
+	}
+	sem := make(chan struct{}, prefetchConcurrency)
+	for _, path := range paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return count, ctx.Err()
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			apiPrefetchCount.Inc(1)
+			ok, err := a.prefetch(ctx, decrypt, manifestAddr, path, reserve)
+			if err != nil {
+				apiPrefetchFail.Inc(1)
+				log.Trace("api.prefetch: entry", "manifest", manifestAddr, "path", path, "err", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				count++
+				mu.Unlock()
+			}
+		}(path)
+	}
+	wg.Wait()
+	return count, nil
+}
+
+// prefetch retrieves all chunks of the content the path resolves to if reserve
+// accepts its size, it returns false if the content is not retrieved
+func (a *API) prefetch(ctx context.Context, decrypt DecryptFunc, manifestAddr storage.Address, path string, reserve func(size int64) bool) (bool, error) {
+	reader, _, status, _, err := a.get(ctx, decrypt, manifestAddr, path, nil)
+	if err != nil {
+		return false, err
+	}
+	// ambiguous paths are not prefetched
+	if status == http.StatusMultipleChoices {
+		return false, nil
+	}
+	size, err := reader.Size(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	if size > maxPrefetchSize || !reserve(size) {
+		return false, nil
+	}
+	// a single read of the whole content retrieves the chunks of the tree in parallel
+	if n, err := reader.ReadAt(make([]byte, size), 0); err != nil && !(err == io.EOF && int64(n) == size) {
+		return false, err
+	}
+	return true, nil
+}
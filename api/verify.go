@@ -0,0 +1,147 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethersphere/swarm/storage"
+)
+
+// VerifyProblem is a missing or corrupt part of the content of a manifest
+type VerifyProblem struct {
+	Path  string `json:"path"`            // path of the entry in the manifest, empty for the root manifest
+	Ref   string `json:"ref,omitempty"`   // reference of the entry content or of the submanifest
+	Chunk string `json:"chunk,omitempty"` // address of the missing or corrupt chunk, if the problem is a chunk
+	Err   string `json:"error"`
+}
+
+// VerifyReport is the result of verifying a manifest, it is valid if it has no problems
+type VerifyReport struct {
+	Root     string          `json:"root"`
+	Entries  int             `json:"entries"` // number of entries verified, including submanifests
+	Chunks   int             `json:"chunks"`  // number of chunks verified
+	Problems []VerifyProblem `json:"problems,omitempty"`
+}
+
+// VerifyManifest walks the manifest with the given root and its submanifests and
+// retrieves every chunk of the manifests and of the content of their entries,
+// checking that it hashes to its address. The chunks that are missing or corrupt
+// are reported with the path of the entry they belong to, as are the entries that
+// cannot be reached. An error is returned only if the context is done before the
+// whole manifest is verified.
+func (a *API) VerifyManifest(ctx context.Context, decrypt DecryptFunc, root storage.Address) (*VerifyReport, error) {
+	report := &VerifyReport{
+		Root: root.Hex(),
+	}
+	if err := a.verifyManifest(ctx, decrypt, root, "", report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// verifyManifest verifies the manifest with the given address mounted at path
+func (a *API) verifyManifest(ctx context.Context, decrypt DecryptFunc, addr storage.Address, path string, report *VerifyReport) error {
+	ok, err := a.verifyTree(ctx, storage.Reference(addr), path, report)
+	if err != nil || !ok {
+		return err
+	}
+	trie, err := loadManifest(ctx, a.fileStore, addr, nil, decrypt)
+	if err != nil {
+		report.problem(path, addr, "", fmt.Errorf("load manifest: %v", err))
+		return nil
+	}
+
+	for _, entry := range &trie.entries {
+		if entry == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Entries++
+		entryPath := path + entry.Path
+		if entry.Access != nil {
+			if decrypt == nil {
+				report.problem(entryPath, nil, "", ErrDecrypt)
+				continue
+			}
+			if err := decrypt(&entry.ManifestEntry); err != nil {
+				report.problem(entryPath, nil, "", err)
+				continue
+			}
+		}
+		// feeds are mounted without content of their own
+		if entry.ContentType == FeedContentType && entry.Hash == "" {
+			continue
+		}
+		ref, err := hex.DecodeString(entry.Hash)
+		if err != nil || len(ref) == 0 {
+			report.problem(entryPath, nil, "", fmt.Errorf("invalid entry hash %q", entry.Hash))
+			continue
+		}
+		if entry.ContentType == ManifestType {
+			err = a.verifyManifest(ctx, decrypt, ref, entryPath, report)
+		} else {
+			_, err = a.verifyTree(ctx, ref, entryPath, report)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyTree verifies the chunk tree of the reference and reports whether it is intact
+func (a *API) verifyTree(ctx context.Context, ref storage.Reference, path string, report *VerifyReport) (bool, error) {
+	ok := true
+	count, err := a.fileStore.VerifyTree(ctx, ref, func(chunkRef storage.Reference, err error) {
+		ok = false
+		addr := chunkRef
+		if len(addr) > storage.AddressLength {
+			addr = addr[:storage.AddressLength]
+		}
+		report.problem(path, storage.Address(ref), storage.Address(addr).Hex(), err)
+	})
+	if err != nil {
+		return false, err
+	}
+	report.Chunks += count
+	return ok, nil
+}
+
+// Exists reports whether the content with the given reference is retrievable,
+// checking its chunk tree down to depth levels below the root chunk, the whole
+// tree if depth is negative. As few chunks are retrieved as the answer takes,
+// only the root chunk if depth is zero.
+func (a *API) Exists(ctx context.Context, ref storage.Address, depth int) (bool, error) {
+	return a.fileStore.TreeExists(ctx, storage.Reference(ref), depth)
+}
+
+func (r *VerifyReport) problem(path string, ref storage.Address, chunk string, err error) {
+	p := VerifyProblem{
+		Path:  path,
+		Chunk: chunk,
+		Err:   err.Error(),
+	}
+	if ref != nil {
+		p.Ref = ref.Hex()
+	}
+	r.Problems = append(r.Problems, p)
+}
@@ -51,6 +51,8 @@ Two implementations are provided:
   that is simple to understand
 * Hasher is optimized for speed taking advantage of concurrency with minimalistic
   control structure to coordinate the concurrent routines
+  if the base hash is Keccak256 and the CPU supports it (amd64 with AVX2), data written
+  sequentially is instead hashed level by level, four sections at a time in vector lanes
 
   BMT Hasher implements the following interfaces
 	* standard golang hash.Hash - synchronous, reusable
@@ -115,6 +117,7 @@ type TreePool struct {
 	Size         int            // the total length of the data (count * size)
 	count        int            // current count of (ever) allocated resources
 	zerohashes   [][]byte       // lookup table for predictable padding subtrees for all levels
+	batch        batchHasher    // hashes the sections of a level in batches, nil if not supported
 }
 
 // NewTreePool creates a tree pool with hasher, segment size, segment count and capacity
@@ -140,6 +143,7 @@ func NewTreePool(hasher BaseHasherFunc, segmentCount, capacity int) *TreePool {
 		Size:         segmentCount * segmentSize,
 		Depth:        depth,
 		zerohashes:   zerohashes,
+		batch:        newBatchHasher(hasher),
 	}
 }
 
@@ -189,6 +193,7 @@ type tree struct {
 	section []byte      // the rightmost open section (double segment)
 	result  chan []byte // result channel
 	span    []byte      // The span of the data subsumed under the chunk
+	data    []byte      // data written sequentially if the pool hashes in batches
 }
 
 // node is a reuseable segment hasher representing a node in a BMT
@@ -342,10 +347,15 @@ func (h *Hasher) Sum(b []byte) (s []byte) {
 		return h.GetZeroHash()
 	}
 	h.mtx.Unlock()
-	// write the last section with final flag set to true
-	go h.WriteSection(t.cursor, t.section, true, true)
-	// wait for the result
-	s = <-t.result
+	if len(t.data) > 0 {
+		// the data written sequentially is hashed level by level
+		s = h.pool.sumBatch(t.data)
+	} else {
+		// write the last section with final flag set to true
+		go h.WriteSection(t.cursor, t.section, true, true)
+		// wait for the result
+		s = <-t.result
+	}
 	if t.span == nil {
 		t.span = LengthToSpan(h.size)
 	}
@@ -367,6 +377,14 @@ func (h *Hasher) Write(b []byte) (int, error) {
 	h.size += len(b)
 	h.mtx.Unlock()
 	t := h.getTree()
+	if h.pool.batch != nil {
+		// buffer the data so that the sections are hashed in batches on Sum
+		if len(t.data)+l > h.pool.Size {
+			b = b[:h.pool.Size-len(t.data)]
+		}
+		t.data = append(t.data, b...)
+		return l, nil
+	}
 	secsize := 2 * h.pool.SegmentSize
 	// calculate length of missing bit to complete current open section
 	smax := secsize - t.offset
@@ -425,6 +443,7 @@ func (h *Hasher) releaseTree() {
 		t.cursor = 0
 		t.offset = 0
 		t.span = nil
+		t.data = t.data[:0]
 		t.section = make([]byte, h.pool.SegmentSize*2)
 		select {
 		case <-t.result:
@@ -566,6 +585,23 @@ func (h *Hasher) writeFinalNode(level int, n *node, bh hash.Hash, isLeft bool, s
 	}
 }
 
+// sumBatch calculates the BMT root hash of the data by hashing the sections of
+// every level in batches, padding the levels with the hashes of all-zero subtrees
+func (p *TreePool) sumBatch(data []byte) []byte {
+	secsize := 2 * p.SegmentSize
+	// the levels are hashed in place in a buffer padded to full sections
+	level := make([]byte, (len(data)+secsize-1)/secsize*secsize, p.Size+secsize)
+	copy(level, data)
+	for i := 0; i < p.Depth; i++ {
+		if len(level)%secsize != 0 {
+			level = append(level, p.zerohashes[i]...)
+		}
+		p.batch(level, level)
+		level = level[:len(level)/2]
+	}
+	return level
+}
+
 // getTree obtains a BMT resource by reserving one from the pool and assigns it to the bmt field
 func (h *Hasher) getTree() *tree {
 	if h.bmt != nil {
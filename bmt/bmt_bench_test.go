@@ -27,3 +27,12 @@ func BenchmarkBMTUsed(t *testing.B) {
 		benchmarkBMT(t, size)
 	})
 }
+
+func BenchmarkBMTConcurrent(t *testing.B) {
+	size := 4096
+	t.Run(fmt.Sprintf("%v_size_%v", "BMT", size), func(t *testing.B) {
+		defer func(batch bool) { keccakX4 = batch }(keccakX4)
+		keccakX4 = false
+		benchmarkBMT(t, size)
+	})
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bmt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// keccak256Empty is the Keccak256 hash of empty input, used to tell the legacy
+// Keccak256 base hash apart from other hashes of the same size
+var keccak256Empty, _ = hex.DecodeString("c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470")
+
+// batchHasher hashes the consecutive sections (double segments) of a BMT level
+// into dst, which may overlap the start of the sections
+type batchHasher func(dst, sections []byte)
+
+// newBatchHasher returns the batch hasher for the base hash selected at runtime,
+// or nil if the base hash is not Keccak256 or the CPU does not support hashing
+// sections in parallel lanes, in which case the sections are hashed one by one
+func newBatchHasher(hasher BaseHasherFunc) batchHasher {
+	if !keccakX4 {
+		return nil
+	}
+	h := hasher()
+	if h.Size() != 32 || h.BlockSize() != 136 || !bytes.Equal(h.Sum(nil), keccak256Empty) {
+		return nil
+	}
+	return keccak256Sections
+}
+
+// keccak256Sections hashes 64 byte sections into 32 byte digests, four at a time
+// in the parallel lanes of keccakF1600x4
+// The sections of a batch are absorbed before its digests are written, so dst may
+// overlap the start of the sections
+func keccak256Sections(dst, sections []byte) {
+	count := len(sections) / 64
+	var a [25][4]uint64
+	for n := 0; n < count; n += 4 {
+		a = [25][4]uint64{}
+		for k := 0; k < 4 && n+k < count; k++ {
+			s := sections[(n+k)*64:]
+			for i := 0; i < 8; i++ {
+				a[i][k] = binary.LittleEndian.Uint64(s[i*8:])
+			}
+			// a section fits in the rate of a single block, padded with the
+			// legacy Keccak domain byte and the final bit of the block
+			a[8][k] = 0x01
+			a[16][k] = 0x80 << 56
+		}
+		keccakF1600x4(&a)
+		for k := 0; k < 4 && n+k < count; k++ {
+			d := dst[(n+k)*32:]
+			for i := 0; i < 4; i++ {
+				binary.LittleEndian.PutUint64(d[i*8:], a[i][k])
+			}
+		}
+	}
+}
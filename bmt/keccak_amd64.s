@@ -0,0 +1,211 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build amd64,!appengine,!gccgo
+
+#include "textflag.h"
+
+// The state of four Keccak instances is kept interleaved, lane i of instance k at
+// offset 32*i+8*k, so that every lane of the four instances is in one YMM register.
+// B, the state after the rho and pi steps, is kept on the stack.
+
+// THETA_C computes the parity of column x of the state into dst
+#define THETA_C(l0, l1, l2, l3, l4, dst) \
+	VMOVDQU l0(DI), dst; \
+	VPXOR   l1(DI), dst, dst; \
+	VPXOR   l2(DI), dst, dst; \
+	VPXOR   l3(DI), dst, dst; \
+	VPXOR   l4(DI), dst, dst
+
+// THETA_D computes the value xored to the lanes of a column from the parities
+// of its neighbour columns
+#define THETA_D(prev, next, dst) \
+	VPSLLQ $1, next, Y10; \
+	VPSRLQ $63, next, Y11; \
+	VPOR   Y10, Y11, Y11; \
+	VPXOR  prev, Y11, dst
+
+// RHO_PI applies theta to a lane, rotates it and moves it to its position in B
+#define RHO_PI(src, d, rot, dst) \
+	VMOVDQU src(DI), Y10; \
+	VPXOR   d, Y10, Y10; \
+	VPSLLQ  $rot, Y10, Y11; \
+	VPSRLQ  $(64-rot), Y10, Y10; \
+	VPOR    Y11, Y10, Y10; \
+	VMOVDQU Y10, dst(SP)
+
+// CHI computes a lane of the state from three lanes of its row in B
+#define CHI(b0, b1, b2, dst) \
+	VPANDN  b2, b1, Y10; \
+	VPXOR   b0, Y10, Y10; \
+	VMOVDQU Y10, dst(DI)
+
+// func keccakF1600x4(a *[25][4]uint64)
+TEXT ·keccakF1600x4(SB), 0, $800-8
+	MOVQ a+0(FP), DI
+	LEAQ roundConstants<>(SB), SI
+	MOVQ $24, CX
+
+loop:
+	// theta
+	THETA_C(0, 160, 320, 480, 640, Y0)
+	THETA_C(32, 192, 352, 512, 672, Y1)
+	THETA_C(64, 224, 384, 544, 704, Y2)
+	THETA_C(96, 256, 416, 576, 736, Y3)
+	THETA_C(128, 288, 448, 608, 768, Y4)
+	THETA_D(Y4, Y1, Y5)
+	THETA_D(Y0, Y2, Y6)
+	THETA_D(Y1, Y3, Y7)
+	THETA_D(Y2, Y4, Y8)
+	THETA_D(Y3, Y0, Y9)
+
+	// rho and pi
+	VMOVDQU 0(DI), Y10
+	VPXOR   Y5, Y10, Y10
+	VMOVDQU Y10, 0(SP)
+	RHO_PI(32, Y6, 1, 320)
+	RHO_PI(64, Y7, 62, 640)
+	RHO_PI(96, Y8, 28, 160)
+	RHO_PI(128, Y9, 27, 480)
+	RHO_PI(160, Y5, 36, 512)
+	RHO_PI(192, Y6, 44, 32)
+	RHO_PI(224, Y7, 6, 352)
+	RHO_PI(256, Y8, 55, 672)
+	RHO_PI(288, Y9, 20, 192)
+	RHO_PI(320, Y5, 3, 224)
+	RHO_PI(352, Y6, 10, 544)
+	RHO_PI(384, Y7, 43, 64)
+	RHO_PI(416, Y8, 25, 384)
+	RHO_PI(448, Y9, 39, 704)
+	RHO_PI(480, Y5, 41, 736)
+	RHO_PI(512, Y6, 45, 256)
+	RHO_PI(544, Y7, 15, 576)
+	RHO_PI(576, Y8, 21, 96)
+	RHO_PI(608, Y9, 8, 416)
+	RHO_PI(640, Y5, 18, 448)
+	RHO_PI(672, Y6, 2, 768)
+	RHO_PI(704, Y7, 61, 288)
+	RHO_PI(736, Y8, 56, 608)
+	RHO_PI(768, Y9, 14, 128)
+
+	// chi
+	VMOVDQU 0(SP), Y0
+	VMOVDQU 32(SP), Y1
+	VMOVDQU 64(SP), Y2
+	VMOVDQU 96(SP), Y3
+	VMOVDQU 128(SP), Y4
+	CHI(Y0, Y1, Y2, 0)
+	CHI(Y1, Y2, Y3, 32)
+	CHI(Y2, Y3, Y4, 64)
+	CHI(Y3, Y4, Y0, 96)
+	CHI(Y4, Y0, Y1, 128)
+	VMOVDQU 160(SP), Y0
+	VMOVDQU 192(SP), Y1
+	VMOVDQU 224(SP), Y2
+	VMOVDQU 256(SP), Y3
+	VMOVDQU 288(SP), Y4
+	CHI(Y0, Y1, Y2, 160)
+	CHI(Y1, Y2, Y3, 192)
+	CHI(Y2, Y3, Y4, 224)
+	CHI(Y3, Y4, Y0, 256)
+	CHI(Y4, Y0, Y1, 288)
+	VMOVDQU 320(SP), Y0
+	VMOVDQU 352(SP), Y1
+	VMOVDQU 384(SP), Y2
+	VMOVDQU 416(SP), Y3
+	VMOVDQU 448(SP), Y4
+	CHI(Y0, Y1, Y2, 320)
+	CHI(Y1, Y2, Y3, 352)
+	CHI(Y2, Y3, Y4, 384)
+	CHI(Y3, Y4, Y0, 416)
+	CHI(Y4, Y0, Y1, 448)
+	VMOVDQU 480(SP), Y0
+	VMOVDQU 512(SP), Y1
+	VMOVDQU 544(SP), Y2
+	VMOVDQU 576(SP), Y3
+	VMOVDQU 608(SP), Y4
+	CHI(Y0, Y1, Y2, 480)
+	CHI(Y1, Y2, Y3, 512)
+	CHI(Y2, Y3, Y4, 544)
+	CHI(Y3, Y4, Y0, 576)
+	CHI(Y4, Y0, Y1, 608)
+	VMOVDQU 640(SP), Y0
+	VMOVDQU 672(SP), Y1
+	VMOVDQU 704(SP), Y2
+	VMOVDQU 736(SP), Y3
+	VMOVDQU 768(SP), Y4
+	CHI(Y0, Y1, Y2, 640)
+	CHI(Y1, Y2, Y3, 672)
+	CHI(Y2, Y3, Y4, 704)
+	CHI(Y3, Y4, Y0, 736)
+	CHI(Y4, Y0, Y1, 768)
+
+	// iota
+	VPBROADCASTQ (SI), Y10
+	VPXOR        0(DI), Y10, Y10
+	VMOVDQU      Y10, 0(DI)
+	ADDQ         $8, SI
+
+	DECQ CX
+	JNZ  loop
+
+	VZEROUPPER
+	RET
+
+DATA roundConstants<>+0x00(SB)/8, $0x0000000000000001
+DATA roundConstants<>+0x08(SB)/8, $0x0000000000008082
+DATA roundConstants<>+0x10(SB)/8, $0x800000000000808A
+DATA roundConstants<>+0x18(SB)/8, $0x8000000080008000
+DATA roundConstants<>+0x20(SB)/8, $0x000000000000808B
+DATA roundConstants<>+0x28(SB)/8, $0x0000000080000001
+DATA roundConstants<>+0x30(SB)/8, $0x8000000080008081
+DATA roundConstants<>+0x38(SB)/8, $0x8000000000008009
+DATA roundConstants<>+0x40(SB)/8, $0x000000000000008A
+DATA roundConstants<>+0x48(SB)/8, $0x0000000000000088
+DATA roundConstants<>+0x50(SB)/8, $0x0000000080008009
+DATA roundConstants<>+0x58(SB)/8, $0x000000008000000A
+DATA roundConstants<>+0x60(SB)/8, $0x000000008000808B
+DATA roundConstants<>+0x68(SB)/8, $0x800000000000008B
+DATA roundConstants<>+0x70(SB)/8, $0x8000000000008089
+DATA roundConstants<>+0x78(SB)/8, $0x8000000000008003
+DATA roundConstants<>+0x80(SB)/8, $0x8000000000008002
+DATA roundConstants<>+0x88(SB)/8, $0x8000000000000080
+DATA roundConstants<>+0x90(SB)/8, $0x000000000000800A
+DATA roundConstants<>+0x98(SB)/8, $0x800000008000000A
+DATA roundConstants<>+0xa0(SB)/8, $0x8000000080008081
+DATA roundConstants<>+0xa8(SB)/8, $0x8000000000008080
+DATA roundConstants<>+0xb0(SB)/8, $0x0000000080000001
+DATA roundConstants<>+0xb8(SB)/8, $0x8000000080008008
+GLOBL roundConstants<>(SB), RODATA, $192
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
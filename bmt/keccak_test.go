@@ -0,0 +1,85 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bmt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	bmttestutil "github.com/ethersphere/swarm/bmt/testutil"
+	"github.com/ethersphere/swarm/testutil"
+	"golang.org/x/crypto/sha3"
+)
+
+// TestKeccak256Sections tests that sections hashed in parallel lanes match
+// their Keccak256 hashes, also when the digests overwrite the sections
+func TestKeccak256Sections(t *testing.T) {
+	if !keccakX4 {
+		t.Skip("batch hashing not supported")
+	}
+	for _, count := range []int{1, 3, 4, 5, 64} {
+		sections := testutil.RandomBytes(count, count*64)
+		exp := make([]byte, 0, count*32)
+		for i := 0; i < count; i++ {
+			exp = append(exp, sha3hash(sections[i*64:(i+1)*64])...)
+		}
+		got := make([]byte, count*32)
+		keccak256Sections(got, sections)
+		if !bytes.Equal(got, exp) {
+			t.Fatalf("%d sections: expected %x, got %x", count, exp, got)
+		}
+		keccak256Sections(sections, sections)
+		if !bytes.Equal(sections[:count*32], exp) {
+			t.Fatalf("%d sections in place: expected %x, got %x", count, exp, sections[:count*32])
+		}
+	}
+}
+
+// TestBatchHasher tests that the batch hasher is only selected for Keccak256 and
+// that hashing in batches matches the concurrent hashing of the sections
+func TestBatchHasher(t *testing.T) {
+	if !keccakX4 {
+		t.Skip("batch hashing not supported")
+	}
+	if newBatchHasher(sha3.New256) != nil {
+		t.Fatal("expected no batch hasher for SHA3-256")
+	}
+	data := testutil.RandomBytes(1, bmttestutil.BufferSize)
+	for _, count := range bmttestutil.Counts {
+		t.Run(fmt.Sprintf("segments_%v", count), func(t *testing.T) {
+			pool := NewTreePool(sha3.NewLegacyKeccak256, count, PoolSize)
+			defer pool.Drain(0)
+			if pool.batch == nil {
+				t.Fatal("expected batch hasher for Keccak256")
+			}
+			concurrent := NewTreePool(sha3.NewLegacyKeccak256, count, PoolSize)
+			defer concurrent.Drain(0)
+			concurrent.batch = nil
+			for _, n := range []int{1, 31, 32, 33, 64, count*32 - 1, count * 32} {
+				if n < 1 {
+					continue
+				}
+				exp := syncHash(New(concurrent), n, data[:n])
+				got := syncHash(New(pool), n, data[:n])
+				if !bytes.Equal(got, exp) {
+					t.Fatalf("length %d: expected %x, got %x", n, exp, got)
+				}
+			}
+		})
+	}
+}
@@ -54,16 +54,19 @@ type BzzEth struct {
 	netStore *storage.NetStore // netstore to retrieve and store
 	kad      *network.Kademlia // kademlia to determine if a header chunk belongs to us
 	quit     chan struct{}     // quit channel to close go routines
+	client   *headerClient     // requests headers from peers on behalf of light clients
 }
 
 // New constructs the BzzEth node service
 func New(netStore *storage.NetStore, kad *network.Kademlia) *BzzEth {
-	return &BzzEth{
+	b := &BzzEth{
 		peers:    newPeers(),
 		netStore: netStore,
 		kad:      kad,
 		quit:     make(chan struct{}),
 	}
+	b.client = newHeaderClient(b)
+	return b
 }
 
 // Run is the bzzeth protocol run function.
@@ -130,6 +133,7 @@ func (b *BzzEth) handleNewBlockHeaders(ctx context.Context, p *Peer, msg *NewBlo
 	addresses := make([]chunk.Address, len(*msg))
 	for i, h := range *msg {
 		addresses[i] = h.Hash.Bytes()
+		b.client.announce(h.BlockHeight, h.Hash)
 		log.Trace("Received hashes ", "Header", hex.EncodeToString(h.Hash.Bytes()))
 	}
 	yes, err := b.netStore.Store.HasMulti(ctx, addresses...)
@@ -577,7 +581,14 @@ func (b *BzzEth) Protocols() []p2p.Protocol {
 
 // APIs return APIs defined on the node service
 func (b *BzzEth) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "bzzeth",
+			Version:   "1.0",
+			Service:   NewAPI(b),
+			Public:    false,
+		},
+	}
 }
 
 // Start starts the BzzEth node service
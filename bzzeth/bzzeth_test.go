@@ -29,6 +29,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -87,7 +88,7 @@ func newTestNetworkStore(t *testing.T) (prvkey *ecdsa.PrivateKey, netStore *stor
 	}
 
 	netStore = storage.NewNetStore(localStore, network.NewBzzAddr(bzzAddr, nil))
-	r := retrieval.New(kad, netStore, network.NewBzzAddr(bzzAddr, nil), nil)
+	r := retrieval.New(kad, netStore, network.NewBzzAddr(bzzAddr, nil), nil, nil)
 	netStore.RemoteGet = r.RequestFromPeers
 
 	cleanup = func() {
@@ -267,8 +268,11 @@ func isPeerDisconnected(id enode.ID, b *BzzEth) (p *Peer) {
 	return
 }
 
-func newBlockHeaderExchange(tester *p2ptest.ProtocolTester, peerID enode.ID, requestID uint32, offered *NewBlockHeaders, wanted []chunk.Address) error {
-	return tester.TestExchanges(
+// newBlockHeaderExchange offers block headers and expects the wanted ones to be
+// requested, it returns the random ID of the request
+func newBlockHeaderExchange(tester *p2ptest.ProtocolTester, peerID enode.ID, offered *NewBlockHeaders, wanted []chunk.Address) (uint32, error) {
+	var req GetBlockHeaders
+	err := tester.TestExchanges(
 		p2ptest.Exchange{
 			Label: "NewBlockHeaders",
 			Triggers: []p2ptest.Trigger{
@@ -282,13 +286,15 @@ func newBlockHeaderExchange(tester *p2ptest.ProtocolTester, peerID enode.ID, req
 				{
 					Code: 2,
 					Msg: GetBlockHeaders{
-						Rid:    uint64(requestID),
 						Hashes: wanted,
 					},
-					Peer: peerID,
+					Peer:     peerID,
+					Ignore:   []string{"Rid"},
+					Received: &req,
 				},
 			},
 		})
+	return uint32(req.Rid), err
 }
 
 func blockHeaderExchange(tester *p2ptest.ProtocolTester, peerID enode.ID, requestID uint32, wantedData []rlp.RawValue) error {
@@ -400,15 +406,6 @@ func TestNewBlockHeaders(t *testing.T) {
 		wanted[i] = hdr.Hash().Bytes()
 	}
 
-	// overwrite newRequestIDFunc to be deterministic
-	defer func(f func() uint32) {
-		newRequestIDFunc = f
-	}(newRequestIDFunc)
-
-	newRequestIDFunc = func() uint32 {
-		return 42
-	}
-
 	// overwrite finishStorageFunc to test deterministic storage of headers
 	finishStorageTesting := func(chunks []chunk.Chunk) {
 		checkStorage(t, wantedIndexes, wanted, wantedData, netstore)
@@ -442,7 +439,7 @@ func TestNewBlockHeaders(t *testing.T) {
 	}
 
 	// Adding ignored hash also .. this hash will be ignored while storing
-	err = newBlockHeaderExchange(tester, node.ID(), newRequestIDFunc(), &offeredBlocks, wanted)
+	requestID, err := newBlockHeaderExchange(tester, node.ID(), &offeredBlocks, wanted)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -454,7 +451,7 @@ func TestNewBlockHeaders(t *testing.T) {
 		t.Fatal(err)
 	}
 	wantedData[len(wantedIndexes)] = unsolRes
-	err = blockHeaderExchange(tester, node.ID(), newRequestIDFunc(), wantedData)
+	err = blockHeaderExchange(tester, node.ID(), requestID, wantedData)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -538,7 +535,7 @@ func TestGetAvailableBlockHeaders(t *testing.T) {
 	}
 
 	//Now trigger the get header request
-	err = getBlockHeaderExchange(tester, node.ID(), newRequestIDFunc(), wantedHeaderHashes, offeredHeaders)
+	err = getBlockHeaderExchange(tester, node.ID(), 42, wantedHeaderHashes, offeredHeaders)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -576,3 +573,160 @@ func checkDelivery(t *testing.T, wantedIndexes []int, wanted []chunk.Address, ha
 		}
 	}
 }
+
+// TestGetBlockHeaderFromPeer tests that concurrent light client requests for headers
+// missing from the localstore are batched into one GetBlockHeaders request to a
+// peer serving headers, and that the delivered headers are returned and stored
+func TestGetBlockHeaderFromPeer(t *testing.T) {
+	prvKey, netstore, cleanup := newTestNetworkStore(t)
+	defer cleanup()
+
+	tester, b, teardown, err := newBzzEthTester(t, prvKey, netstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	defer func(s func([]chunk.Chunk), d time.Duration) {
+		finishStorageFunc = s
+		headerBatchWait = d
+	}(finishStorageFunc, headerBatchWait)
+	// other tests may have left their storage checks in place
+	finishStorageFunc = finishStorage
+	headerBatchWait = 500 * time.Millisecond
+
+	node := tester.Nodes[0]
+	if err := handshakeExchange(tester, node.ID(), true, true); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// wait for the peer to be added to the pool
+	for i := 0; i < 100 && b.peers.getEth() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	hashes := make([]chunk.Address, 2)
+	headers := make([]rlp.RawValue, 2)
+	for i := range hashes {
+		hdr := types.Header{Number: new(big.Int).SetUint64(uint64(i))}
+		res, err := rlp.EncodeToBytes(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = hdr.Hash().Bytes()
+		headers[i] = res
+	}
+
+	// the first header is requested twice, which must not result in a second request
+	requested := []int{0, 1, 0}
+	type result struct {
+		i   int
+		hdr []byte
+		err error
+	}
+	results := make(chan result, len(requested))
+	for _, i := range requested {
+		i := i
+		go func() {
+			hdr, err := b.GetBlockHeader(context.Background(), common.BytesToHash(hashes[i]))
+			results <- result{i, hdr, err}
+		}()
+		// wait for the request to be pending so that the order of hashes in the batch is deterministic
+		for j := 0; j < 100; j++ {
+			b.client.mtx.Lock()
+			_, ok := b.client.inflight[common.BytesToHash(hashes[i])]
+			b.client.mtx.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var req GetBlockHeaders
+	err = tester.TestExchanges(
+		p2ptest.Exchange{
+			Label: "GetBlockHeaders",
+			Expects: []p2ptest.Expect{
+				{
+					Code: 2,
+					Msg: GetBlockHeaders{
+						Hashes: hashes,
+					},
+					Peer:     node.ID(),
+					Ignore:   []string{"Rid"},
+					Received: &req,
+				},
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockHeaderExchange(tester, node.ID(), uint32(req.Rid), headers); err != nil {
+		t.Fatal(err)
+	}
+
+	for range requested {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				t.Fatalf("header %d: %v", r.i, r.err)
+			}
+			if !bytes.Equal(r.hdr, headers[r.i]) {
+				t.Fatalf("header %d: got %x, want %x", r.i, r.hdr, headers[r.i])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for headers")
+		}
+	}
+
+	for i, h := range hashes {
+		var err error
+		for j := 0; j < 100; j++ {
+			if _, err = netstore.Store.Get(context.Background(), chunk.ModeGetLookup, h); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("header %d not stored: %v", i, err)
+		}
+	}
+}
+
+// TestGetBlockHeaderNoServingPeer tests that headers missing from the localstore
+// cannot be retrieved without a peer serving headers, and that unknown
+// block numbers are rejected
+func TestGetBlockHeaderNoServingPeer(t *testing.T) {
+	_, netstore, cleanup := newTestNetworkStore(t)
+	defer cleanup()
+
+	defer func(d time.Duration) { headerBatchWait = d }(headerBatchWait)
+	headerBatchWait = 0
+
+	b := New(netstore, nil)
+
+	hdr := types.Header{Number: big.NewInt(1)}
+	if _, err := b.GetBlockHeader(context.Background(), hdr.Hash()); err != ErrNoServingPeer {
+		t.Fatalf("expected error %v, got %v", ErrNoServingPeer, err)
+	}
+	if _, err := b.GetBlockHeaderByNumber(context.Background(), 1); err != ErrUnknownBlockNumber {
+		t.Fatalf("expected error %v, got %v", ErrUnknownBlockNumber, err)
+	}
+
+	// a header in the localstore is returned by its announced number
+	res, err := rlp.EncodeToBytes(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := netstore.Store.Put(context.Background(), chunk.ModePutUpload, newChunk(res)); err != nil {
+		t.Fatal(err)
+	}
+	b.client.announce(1, hdr.Hash())
+	got, err := b.GetBlockHeaderByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, res) {
+		t.Fatalf("got header %x, want %x", got, res)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bzzeth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+)
+
+var (
+	// ErrNoServingPeer is returned if a header is not found locally and no
+	// connected peer serves headers
+	ErrNoServingPeer = errors.New("no peer serving headers")
+	// ErrHeaderNotDelivered is returned if the peer a header was requested from
+	// did not deliver it in time
+	ErrHeaderNotDelivered = errors.New("header not delivered")
+	// ErrUnknownBlockNumber is returned if no header hash was announced for a block number
+	ErrUnknownBlockNumber = errors.New("unknown block number")
+
+	errInvalidHeader = errors.New("invalid header")
+	errShuttingDown  = errors.New("shutting down")
+)
+
+var (
+	headerBatchWait      = 50 * time.Millisecond // time to wait for concurrent requests to join a batch
+	maxHeaderBatchSize   = 64                    // number of headers requested in one GetBlockHeaders message
+	headerRequestTimeout = 10 * time.Second      // time to wait for the headers of a batch to be delivered
+	maxAnnouncedHeaders  = 1024                  // number of announced block numbers remembered
+)
+
+// headerRequest is a request for a header shared by all callers requesting the same hash
+type headerRequest struct {
+	done   chan struct{} // closed once the header is delivered or the request failed
+	header []byte        // rlp encoded header
+	err    error         // error the request failed with
+}
+
+// headerClient requests headers from the peers serving them on behalf of light clients
+// Concurrent requests of the same header are served by a single request, and requests
+// of different headers arriving within headerBatchWait are sent in a single message
+type headerClient struct {
+	b        *BzzEth
+	mtx      sync.Mutex
+	inflight map[common.Hash]*headerRequest // requests waiting for delivery by hash
+	pending  []chunk.Address                // hashes waiting to be sent in the next batch
+	timer    *time.Timer                    // timer sending the pending batch
+	numbers  map[uint64]common.Hash         // hashes of the announced headers by block number
+}
+
+func newHeaderClient(b *BzzEth) *headerClient {
+	return &headerClient{
+		b:        b,
+		inflight: make(map[common.Hash]*headerRequest),
+		numbers:  make(map[uint64]common.Hash),
+	}
+}
+
+// GetBlockHeader returns the rlp encoded header with the given hash
+// The header is looked up in the local store first, if it is not found
+// it is requested from a peer serving headers, validated and stored
+func (b *BzzEth) GetBlockHeader(ctx context.Context, hash common.Hash) ([]byte, error) {
+	ch, err := b.netStore.Store.Get(ctx, chunk.ModeGetRequest, hash.Bytes())
+	if err == nil {
+		return ch.Data(), nil
+	}
+	if err != chunk.ErrChunkNotFound {
+		return nil, err
+	}
+
+	req := b.client.request(hash)
+	select {
+	case <-req.done:
+		return req.header, req.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.quit:
+		return nil, errShuttingDown
+	}
+}
+
+// GetBlockHeaderByNumber returns the rlp encoded header of the block number
+// announced by the peers with NewBlockHeaders
+func (b *BzzEth) GetBlockHeaderByNumber(ctx context.Context, number uint64) ([]byte, error) {
+	hash, ok := b.client.hash(number)
+	if !ok {
+		return nil, ErrUnknownBlockNumber
+	}
+	return b.GetBlockHeader(ctx, hash)
+}
+
+// request returns the request for the header, creating it and adding
+// the hash to the pending batch if it is not requested yet
+func (c *headerClient) request(hash common.Hash) *headerRequest {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if req, ok := c.inflight[hash]; ok {
+		return req
+	}
+	req := &headerRequest{done: make(chan struct{})}
+	c.inflight[hash] = req
+	c.pending = append(c.pending, hash.Bytes())
+	if len(c.pending) >= maxHeaderBatchSize {
+		c.flush()
+	} else if c.timer == nil {
+		c.timer = time.AfterFunc(headerBatchWait, func() {
+			c.mtx.Lock()
+			defer c.mtx.Unlock()
+			c.flush()
+		})
+	}
+	return req
+}
+
+// flush sends the pending batch to a peer serving headers
+// the caller is expected to hold the lock
+func (c *headerClient) flush() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if len(c.pending) == 0 {
+		return
+	}
+	hashes := c.pending
+	c.pending = nil
+
+	p := c.b.peers.getEth()
+	if p == nil {
+		for _, h := range hashes {
+			c.deliverNoLock(common.BytesToHash(h), nil, ErrNoServingPeer)
+		}
+		return
+	}
+	go c.fetch(p, hashes)
+}
+
+// fetch requests the headers from the peer and delivers them to the waiting requests
+func (c *headerClient) fetch(p *Peer, hashes []chunk.Address) {
+	ctx, cancel := context.WithTimeout(context.Background(), headerRequestTimeout)
+	defer cancel()
+
+	// buffered so that the delivery of the peer never blocks on this request
+	deliveries := make(chan []byte, len(hashes))
+	req, err := p.getBlockHeaders(ctx, hashes, deliveries)
+	if err != nil {
+		p.logger.Debug("bzzeth.client.fetch: sending GetBlockHeaders", "err", err)
+		for _, h := range hashes {
+			c.deliver(common.BytesToHash(h), nil, err)
+		}
+		return
+	}
+	defer req.cancel()
+
+	remaining := make(map[common.Hash]struct{}, len(hashes))
+	for _, h := range hashes {
+		remaining[common.BytesToHash(h)] = struct{}{}
+	}
+	for len(remaining) > 0 {
+		select {
+		case hdr := <-deliveries:
+			hash := common.BytesToHash(crypto.Keccak256(hdr))
+			delete(remaining, hash)
+			if err := validateHeader(hdr); err != nil {
+				p.logger.Debug("bzzeth.client.fetch: invalid header", "hash", hash, "err", err)
+				c.deliver(hash, nil, err)
+				continue
+			}
+			c.deliver(hash, hdr, nil)
+		case <-ctx.Done():
+			p.logger.Debug("bzzeth.client.fetch: not delivered", "count", len(remaining))
+			for hash := range remaining {
+				c.deliver(hash, nil, ErrHeaderNotDelivered)
+			}
+			return
+		case <-c.b.quit:
+			return
+		}
+	}
+}
+
+// deliver resolves the request of the header
+func (c *headerClient) deliver(hash common.Hash, header []byte, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.deliverNoLock(hash, header, err)
+}
+
+// deliverNoLock resolves the request of the header
+// the caller is expected to hold the lock
+func (c *headerClient) deliverNoLock(hash common.Hash, header []byte, err error) {
+	req, ok := c.inflight[hash]
+	if !ok {
+		return
+	}
+	delete(c.inflight, hash)
+	req.header = header
+	req.err = err
+	close(req.done)
+}
+
+// announce records the hash of the header announced for a block number
+// evicting the lowest block number once more than maxAnnouncedHeaders are known
+func (c *headerClient) announce(number uint64, hash common.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.numbers[number] = hash
+	if len(c.numbers) <= maxAnnouncedHeaders {
+		return
+	}
+	lowest := number
+	for n := range c.numbers {
+		if n < lowest {
+			lowest = n
+		}
+	}
+	delete(c.numbers, lowest)
+}
+
+// hash returns the hash of the header announced for a block number
+func (c *headerClient) hash(number uint64) (common.Hash, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	hash, ok := c.numbers[number]
+	return hash, ok
+}
+
+// validateHeader checks that the data delivered is an rlp encoded block header
+// the hash of the header is checked against the requested hashes on delivery
+func validateHeader(data []byte) error {
+	var hdr types.Header
+	if err := rlp.DecodeBytes(data, &hdr); err != nil {
+		return errInvalidHeader
+	}
+	return nil
+}
+
+// API is the RPC API of bzzeth serving block headers to light clients
+type API struct {
+	b *BzzEth
+}
+
+// NewAPI creates the bzzeth RPC API
+func NewAPI(b *BzzEth) *API {
+	return &API{b: b}
+}
+
+// GetBlockHeader returns the rlp encoded header with the given hash
+func (a *API) GetBlockHeader(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
+	hdr, err := a.b.GetBlockHeader(ctx, hash)
+	if err != nil {
+		log.Debug("bzzeth.api.GetBlockHeader", "hash", hash, "err", err)
+		return nil, err
+	}
+	return hdr, nil
+}
+
+// GetBlockHeaderByNumber returns the rlp encoded header of the given block number
+func (a *API) GetBlockHeaderByNumber(ctx context.Context, number hexutil.Uint64) (hexutil.Bytes, error) {
+	hdr, err := a.b.GetBlockHeaderByNumber(ctx, uint64(number))
+	if err != nil {
+		log.Debug("bzzeth.api.GetBlockHeaderByNumber", "number", number, "err", err)
+		return nil, err
+	}
+	return hdr, nil
+}
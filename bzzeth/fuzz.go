@@ -0,0 +1,45 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package bzzeth
+
+// FuzzBzzEth is the go-fuzz entry point of the decoding of the bzzeth messages
+// received from ethereum nodes and swarm peers and of the validation of the block
+// headers they deliver, see protocols.Spec.FuzzMsg for the format of the input
+func FuzzBzzEth(data []byte) int {
+	msg, err := Spec.FuzzMsg(data)
+	if err != nil {
+		return 0
+	}
+	switch msg := msg.(type) {
+	case *NewBlockHeaders:
+		for _, h := range *msg {
+			_ = h.Hash.Hex()
+		}
+	case *GetBlockHeaders:
+		for _, addr := range msg.Hashes {
+			_ = addr.Hex()
+		}
+	case *BlockHeaders:
+		for _, h := range msg.Headers {
+			newChunk(h)
+			validateHeader(h)
+		}
+	}
+	return 1
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package bzzeth
+
+import (
+	"flag"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+var fuzzCorpus = flag.String("fuzz.corpus", "", "directory to write the corpus seeds of the fuzzers to")
+
+// TestFuzzBzzEthCorpus checks the seeds of FuzzBzzEth, messages like the ones
+// of the header exchange tests, and writes them to the corpus directory if it is
+// given with the fuzz.corpus flag
+func TestFuzzBzzEthCorpus(t *testing.T) {
+	offered := make(NewBlockHeaders, 3)
+	wanted := make([]chunk.Address, len(offered))
+	headers := make([]rlp.RawValue, len(offered))
+	for i := range offered {
+		hdr := types.Header{Number: new(big.Int).SetUint64(uint64(i))}
+		offered[i].Hash = hdr.Hash()
+		offered[i].BlockHeight = uint64(i)
+		wanted[i] = hdr.Hash().Bytes()
+		res, err := rlp.EncodeToBytes(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers[i] = res
+	}
+
+	var seeds [][]byte
+	for _, msg := range []interface{}{
+		&Handshake{ServeHeaders: true},
+		&offered,
+		&GetBlockHeaders{Rid: 1, Hashes: wanted},
+		&BlockHeaders{Rid: 1, Headers: headers},
+	} {
+		seed, err := Spec.FuzzSeed(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FuzzBzzEth(seed) != 1 {
+			t.Fatalf("seed of message %v rejected", msg)
+		}
+		seeds = append(seeds, seed)
+	}
+	if *fuzzCorpus != "" {
+		if err := protocols.WriteFuzzCorpus(*fuzzCorpus, seeds...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -81,7 +81,8 @@ func (p *peers) getEth() *Peer {
 
 	for _, peer := range p.peers {
 		// Only peers that can serve headers will be selected
-		if peer.serveHeaders {
+		// Swarm nodes drop the connection on any message, so they are never selected
+		if peer.serveHeaders && !isSwarmNodeFunc(peer) {
 			return peer
 		}
 	}
@@ -101,10 +102,6 @@ type request struct {
 	cancel func()          // function to call in case of cancellation of the GetBlockHeaders event
 }
 
-// newRequestIDFunc is used to generate unique ID for requests
-// tests can reassign for deterministic ids
-var newRequestIDFunc = newRequestID
-
 // newRequestID generates a 32-bit random number to be used as unique id for s
 // no reuse of id across peers
 func newRequestID() uint32 {
@@ -125,7 +122,7 @@ func (r *requests) create(hashes []chunk.Address, c chan []byte) (*request, uint
 		hashes: make(map[string]bool),
 		c:      c,
 	}
-	id := newRequestIDFunc()
+	id := newRequestID()
 	for _, h := range hashes {
 		req.hashes[hex.EncodeToString(h)] = false
 	}
@@ -25,9 +25,10 @@ import (
 
 // Spec is the protocol spec for bzzeth
 var Spec = &protocols.Spec{
-	Name:       "bzzeth",
-	Version:    1,
-	MaxMsgSize: 10 * 1024 * 1024,
+	Name:             "bzzeth",
+	Version:          1,
+	MaxMsgSize:       10 * 1024 * 1024,
+	MaxHandshakeSize: 1024,
 	Messages: []interface{}{
 		Handshake{},
 		NewBlockHeaders{},
@@ -1,9 +1,15 @@
 package client
 
 import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm"
+	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/storage"
 )
 
@@ -79,3 +85,49 @@ func (b *Bzz) IsPushSynced(tagname string) (bool, error) {
 
 	return isSynced, nil
 }
+
+// ProbeChunks queries the network from the node for the given chunks, bypassing
+// its local store, and returns the retrievability and latency of each chunk
+func (b *Bzz) ProbeChunks(ctx context.Context, addrs []storage.Address) (*storage.ProbeReport, error) {
+	var report storage.ProbeReport
+
+	err := b.client.CallContext(ctx, &report, "bzz_probeChunks", addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// PublishName points the ENS name at the content hash with a transaction of the
+// node account, registering the name and setting the resolver if needed, and
+// returns once the name resolves to the hash
+func (b *Bzz) PublishName(ctx context.Context, name string, hash storage.Address, resolver common.Address) error {
+	return b.client.CallContext(ctx, nil, "bzz_publishName", name, hash, resolver)
+}
+
+// SampleChunks returns a random sample of up to n chunks stored by the node with
+// proximity order to its address from minPO to maxPO, with the proofs of their
+// possession for the nonce, which can be checked with api.PossessionProof
+func (b *Bzz) SampleChunks(ctx context.Context, minPO, maxPO uint8, n int, nonce []byte) ([]api.ChunkPossession, error) {
+	var sample []api.ChunkPossession
+
+	err := b.client.CallContext(ctx, &sample, "bzz_sampleChunks", minPO, maxPO, n, hexutil.Bytes(nonce))
+	if err != nil {
+		return nil, err
+	}
+
+	return sample, nil
+}
+
+// KademliaTable returns the full kademlia table of the node
+func (b *Bzz) KademliaTable(ctx context.Context) (*network.KademliaTable, error) {
+	var table network.KademliaTable
+
+	err := b.client.CallContext(ctx, &table, "bzz_kademliaTable")
+	if err != nil {
+		return nil, err
+	}
+
+	return &table, nil
+}
@@ -67,6 +67,12 @@ func main() {
 			Usage:   "measure network aggregate capacity",
 			Action:  wrapCliCommand("sliding-window", slidingWindowCmd),
 		},
+		{
+			Name:    "probe",
+			Aliases: []string{"p"},
+			Usage:   "measure retrievability and latency of uploaded chunks",
+			Action:  wrapCliCommand("probe", probeCmd),
+		},
 	}
 
 	sort.Sort(cli.FlagsByName(app.Flags))
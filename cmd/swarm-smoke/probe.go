@@ -0,0 +1,113 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethersphere/swarm/client"
+	"github.com/ethersphere/swarm/testutil"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+func probeCmd(ctx *cli.Context) error {
+	// use input seed if it has been set
+	if inputSeed != 0 {
+		seed = inputSeed
+	}
+
+	randomBytes := testutil.RandomBytes(seed, filesize*1000)
+
+	errc := make(chan error)
+
+	go func() {
+		errc <- probe(ctx, randomBytes)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+		if err != nil {
+			metrics.GetOrRegisterCounter(fmt.Sprintf("%s/fail", commandName), nil).Inc(1)
+		}
+	case <-time.After(time.Duration(timeout) * time.Second):
+		metrics.GetOrRegisterCounter(fmt.Sprintf("%s/timeout", commandName), nil).Inc(1)
+
+		err = fmt.Errorf("timeout after %v sec", timeout)
+	}
+
+	return err
+}
+
+// probe uploads the data to the first host and has the other hosts probe
+// the network for its chunks, reporting how many of them are retrievable
+// and how fast
+func probe(c *cli.Context, randomBytes []byte) error {
+	hash, err := upload(randomBytes, httpEndpoint(hosts[0]))
+	if err != nil {
+		return err
+	}
+	log.Info("uploaded successfully", "hash", hash, "host", hosts[0])
+
+	if syncDelay {
+		waitToSync()
+	}
+
+	addrs, err := getAllRefs(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	probers := hosts[1:]
+	if len(probers) == 0 {
+		probers = hosts
+	}
+
+	var missing int
+	for _, host := range probers {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		rpcClient, err := rpc.DialContext(ctx, wsEndpoint(host))
+		if err != nil {
+			cancel()
+			return fmt.Errorf("error dialing host %s: %v", host, err)
+		}
+		report, err := client.NewBzz(rpcClient).ProbeChunks(ctx, addrs)
+		rpcClient.Close()
+		cancel()
+		if err != nil {
+			return fmt.Errorf("error probing chunks from host %s: %v", host, err)
+		}
+
+		metrics.GetOrRegisterCounter(fmt.Sprintf("%s/missing", commandName), nil).Inc(int64(report.Missing))
+		metrics.GetOrRegisterResettingTimer(fmt.Sprintf("%s/latency", commandName), nil).Update(report.MeanLatency)
+		log.Info("probed chunks", "host", host, "chunks", len(addrs), "retrieved", report.Retrieved, "missing", report.Missing, "failed", report.Failed, "mean latency", report.MeanLatency, "max latency", report.MaxLatency)
+		for _, ref := range report.MissingRefs() {
+			log.Debug("chunk not retrieved", "host", host, "ref", ref)
+		}
+		missing += report.Missing + report.Failed
+	}
+
+	if missing > 0 && bail {
+		return fmt.Errorf("%d chunks could not be retrieved", missing)
+	}
+	return nil
+}
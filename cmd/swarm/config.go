@@ -21,12 +21,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
 	cli "gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -35,7 +39,12 @@ import (
 	"github.com/naoina/toml"
 
 	bzzapi "github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/retrieval"
+	"github.com/ethersphere/swarm/pss/bridge"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/storage"
 )
 
 var (
@@ -66,6 +75,7 @@ const (
 	SwarmEnvNetworkID               = "SWARM_NETWORK_ID"
 	SwarmEnvChequebookAddr          = "SWARM_CHEQUEBOOK_ADDR"
 	SwarmEnvChequebookFactoryAddr   = "SWARM_SWAP_CHEQUEBOOK_FACTORY_ADDR"
+	SwarmEnvSwapBeneficiary         = "SWARM_SWAP_BENEFICIARY"
 	SwarmEnvSwapSkipDeposit         = "SWARM_SWAP_SKIP_DEPOSIT"
 	SwarmEnvSwapDepositAmount       = "SWARM_SWAP_DEPOSIT_AMOUNT"
 	SwarmEnvSwapEnable              = "SWARM_SWAP_ENABLE"
@@ -76,6 +86,7 @@ const (
 	SwarmEnvSwapLogPath             = "SWARM_SWAP_LOG_PATH"
 	SwarmEnvSwapLogLevel            = "SWARM_SWAP_LOG_LEVEL"
 	SwarmEnvLightNodeEnable         = "SWARM_LIGHT_NODE_ENABLE"
+	SwarmEnvRetrieveRelay           = "SWARM_RETRIEVE_RELAY"
 	SwarmEnvENSAPI                  = "SWARM_ENS_API"
 	SwarmEnvRNSAPI                  = "SWARM_RNS_API"
 	SwarmEnvENSAddr                 = "SWARM_ENS_ADDR"
@@ -90,6 +101,11 @@ const (
 	SwarmAccessPassword             = "SWARM_ACCESS_PASSWORD"
 	SwarmAutoDefaultPath            = "SWARM_AUTO_DEFAULTPATH"
 	SwarmGlobalstoreAPI             = "SWARM_GLOBALSTORE_API"
+	SwarmEnvGatewayMode             = "SWARM_GATEWAY_MODE"
+	SwarmEnvAPIKeys                 = "SWARM_API_KEYS"
+	SwarmEnvWebSocketPss            = "SWARM_WS_PSS"
+	SwarmEnvPssMailbox              = "SWARM_PSS_MAILBOX"
+	SwarmEnvSyncUpdateDelay         = "SWARM_SYNC_UPDATE_DELAY"
 	GethEnvDataDir                  = "GETH_DATADIR"
 )
 
@@ -187,6 +203,12 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if chequebookFactoryAddress := ctx.GlobalString(SwarmSwapChequebookFactoryFlag.Name); chequebookFactoryAddress != "" {
 		currentConfig.SwapChequebookFactory = common.HexToAddress(chequebookFactoryAddress)
 	}
+	if beneficiary := ctx.GlobalString(SwarmSwapBeneficiaryFlag.Name); beneficiary != "" {
+		if !common.IsHexAddress(beneficiary) {
+			utils.Fatalf("--%s: invalid address %q", SwarmSwapBeneficiaryFlag.Name, beneficiary)
+		}
+		currentConfig.SwapBeneficiary = common.HexToAddress(beneficiary)
+	}
 	networkid := ctx.GlobalUint64(SwarmNetworkIdFlag.Name)
 	if networkid != 0 && networkid != network.DefaultNetworkID {
 		currentConfig.NetworkID = networkid
@@ -228,6 +250,18 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if disconnectThreshold := ctx.GlobalUint64(SwarmSwapDisconnectThresholdFlag.Name); disconnectThreshold != 0 {
 		currentConfig.SwapDisconnectThreshold = disconnectThreshold
 	}
+	if ctx.GlobalIsSet(SwarmSwapFreeChunksFlag.Name) {
+		currentConfig.SwapFreeChunks = ctx.GlobalInt64(SwarmSwapFreeChunksFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmSwapFreeBytesFlag.Name) {
+		currentConfig.SwapFreeBytes = ctx.GlobalInt64(SwarmSwapFreeBytesFlag.Name)
+	}
+	if window := ctx.GlobalDuration(SwarmSwapFreeWindowFlag.Name); window > 0 {
+		currentConfig.SwapFreeWindow = window
+	}
+	if confirmations := ctx.GlobalUint64(SwarmSwapConfirmationsFlag.Name); confirmations > 0 {
+		currentConfig.SwapConfirmations = confirmations
+	}
 	if ctx.GlobalIsSet(SwarmNoSyncFlag.Name) {
 		val := !ctx.GlobalBool(SwarmNoSyncFlag.Name)
 		currentConfig.SyncEnabled, currentConfig.PushSyncEnabled = val, val // if the flag is set (true) - push and pull sync should be disabled
@@ -235,6 +269,9 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if ctx.GlobalIsSet(SwarmLightNodeEnabled.Name) {
 		currentConfig.LightNodeEnabled = true
 	}
+	if ctx.GlobalIsSet(SwarmRetrieveRelayFlag.Name) {
+		currentConfig.RetrieveRelay = ctx.GlobalBool(SwarmRetrieveRelayFlag.Name)
+	}
 	if ctx.GlobalIsSet(EnsAPIFlag.Name) {
 		ensAPIs := ctx.GlobalStringSlice(EnsAPIFlag.Name)
 		// preserve backward compatibility to disable ENS with --ens-api=""
@@ -261,18 +298,150 @@ func flagsOverride(currentConfig *bzzapi.Config, ctx *cli.Context) *bzzapi.Confi
 	if ctx.GlobalIsSet(SwarmStoreCacheCapacity.Name) {
 		currentConfig.CacheCapacity = ctx.GlobalUint(SwarmStoreCacheCapacity.Name)
 	}
+	if profile := ctx.GlobalString(SwarmChunkerProfileFlag.Name); profile != "" {
+		if _, err := storage.ChunkerProfileByName(profile); err != nil {
+			utils.Fatalf("invalid chunker profile: %v", err)
+		}
+		currentConfig.ChunkerProfile = profile
+	}
 	if ctx.GlobalIsSet(SwarmBootnodeModeFlag.Name) {
 		currentConfig.BootnodeMode = ctx.GlobalBool(SwarmBootnodeModeFlag.Name)
 	}
 	if ctx.GlobalIsSet(SwarmDisableAutoConnectFlag.Name) {
 		currentConfig.DisableAutoConnect = ctx.GlobalBool(SwarmDisableAutoConnectFlag.Name)
 	}
+	if ctx.GlobalIsSet(SwarmStaticPeerFlag.Name) {
+		currentConfig.StaticPeers = make(map[string][]string)
+		for _, v := range ctx.GlobalStringSlice(SwarmStaticPeerFlag.Name) {
+			i := strings.Index(v, "@")
+			if i <= 0 {
+				utils.Fatalf("invalid static peer %q, expected format role@enode-url", v)
+			}
+			role := v[:i]
+			currentConfig.StaticPeers[role] = append(currentConfig.StaticPeers[role], v[i+1:])
+		}
+	}
+	if ctx.GlobalIsSet(SwarmStaticPeerTargetFlag.Name) {
+		currentConfig.StaticPeerTargets = make(map[string]int)
+		for _, v := range ctx.GlobalStringSlice(SwarmStaticPeerTargetFlag.Name) {
+			i := strings.LastIndex(v, ":")
+			if i <= 0 {
+				utils.Fatalf("invalid static peer target %q, expected format role:count", v)
+			}
+			count, err := strconv.Atoi(v[i+1:])
+			if err != nil || count < 0 {
+				utils.Fatalf("invalid static peer target count %q", v)
+			}
+			currentConfig.StaticPeerTargets[v[:i]] = count
+		}
+	}
 	if ctx.GlobalIsSet(SwarmGlobalStoreAPIFlag.Name) {
 		currentConfig.GlobalStoreAPI = ctx.GlobalString(SwarmGlobalStoreAPIFlag.Name)
 	}
+	if ctx.GlobalIsSet(SwarmPushSyncReplicasFlag.Name) {
+		currentConfig.PushSyncReplicas = ctx.GlobalInt(SwarmPushSyncReplicasFlag.Name)
+	}
 	if ctx.GlobalBool(SwarmEnablePinningFlag.Name) {
 		currentConfig.EnablePinning = true
 	}
+	if ctx.GlobalIsSet(SwarmPinRepairIntervalFlag.Name) {
+		currentConfig.PinRepairInterval = ctx.GlobalDuration(SwarmPinRepairIntervalFlag.Name)
+	}
+	if samples := ctx.GlobalInt(SwarmPinRepairSamplesFlag.Name); samples > 0 {
+		currentConfig.PinRepairSampleSize = samples
+	}
+	if timeout := ctx.GlobalDuration(SwarmPinRepairProbeTimeoutFlag.Name); timeout > 0 {
+		currentConfig.PinRepairProbeTimeout = timeout
+	}
+	if ctx.GlobalBool(SwarmGatewayModeFlag.Name) {
+		currentConfig.GatewayMode = true
+	}
+	if ctx.GlobalBool(SwarmAPIKeysFlag.Name) {
+		currentConfig.EnableAPIKeys = true
+	}
+	if ctx.GlobalBool(SwarmWebSocketPssFlag.Name) {
+		currentConfig.WebSocketPss = true
+	}
+	if ctx.GlobalBool(SwarmPssMailboxFlag.Name) {
+		currentConfig.PssMailbox = true
+	}
+	if ctx.GlobalIsSet(SwarmPssMailboxOwnerFlag.Name) {
+		currentConfig.PssMailboxOwners = nil
+		for _, owner := range ctx.GlobalStringSlice(SwarmPssMailboxOwnerFlag.Name) {
+			if !common.IsHexAddress(owner) {
+				utils.Fatalf("invalid pss mailbox owner %q, expected a hex address", owner)
+			}
+			currentConfig.PssMailboxOwners = append(currentConfig.PssMailboxOwners, common.HexToAddress(owner))
+		}
+	}
+	if ctx.GlobalIsSet(SwarmPssBridgeFlag.Name) {
+		currentConfig.PssBridge.Routes = nil
+		for _, v := range ctx.GlobalStringSlice(SwarmPssBridgeFlag.Name) {
+			route, err := parsePssBridgeRoute(v)
+			if err != nil {
+				utils.Fatalf("invalid pss bridge route %q: %v", v, err)
+			}
+			currentConfig.PssBridge.Routes = append(currentConfig.PssBridge.Routes, route)
+		}
+	}
+	if delay := ctx.GlobalDuration(SwarmSyncUpdateDelayFlag.Name); delay > 0 {
+		currentConfig.SyncUpdateDelay = delay
+	}
+	if ctx.GlobalIsSet(SwarmSyncBandwidthFlag.Name) {
+		currentConfig.SyncBandwidth = ctx.GlobalInt64(SwarmSyncBandwidthFlag.Name)
+	}
+	if ctx.GlobalIsSet(SwarmRetrieveBandwidthFlag.Name) {
+		currentConfig.RetrieveBandwidth = ctx.GlobalInt64(SwarmRetrieveBandwidthFlag.Name)
+	}
+	if hops := ctx.GlobalUint(SwarmRetrieveMaxHopsFlag.Name); hops > 0 {
+		if hops > math.MaxUint8 {
+			utils.Fatalf("--%s must be at most %d", SwarmRetrieveMaxHopsFlag.Name, math.MaxUint8)
+		}
+		currentConfig.RetrieveMaxHops = uint8(hops)
+	}
+	if ctx.GlobalBool(SwarmRetrieveLatencyFlag.Name) {
+		currentConfig.RetrieveByLatency = true
+	}
+	if ctx.GlobalIsSet(SwarmRetrieveFarFetchedFlag.Name) {
+		policy, err := retrieval.ParseFarFetchedPolicy(ctx.GlobalString(SwarmRetrieveFarFetchedFlag.Name))
+		if err != nil {
+			utils.Fatalf("--%s: %v", SwarmRetrieveFarFetchedFlag.Name, err)
+		}
+		currentConfig.RetrieveFarFetched = policy
+	}
+	if ctx.GlobalIsSet(SwarmRetrieveFarFetchedSlackFlag.Name) {
+		currentConfig.RetrieveFarSlack = ctx.GlobalInt(SwarmRetrieveFarFetchedSlackFlag.Name)
+	}
+	if price := ctx.GlobalUint64(SwarmRetrieveChunkPriceFlag.Name); price > 0 {
+		currentConfig.RetrieveChunkPrice = price
+	}
+	if ctx.GlobalBool(SwarmRetrieveFreeNeighboursFlag.Name) {
+		currentConfig.RetrieveFreeNeighbours = true
+	}
+	if ctx.GlobalIsSet(SwarmRetrieveMaxChunkPriceFlag.Name) {
+		currentConfig.RetrieveMaxChunkPrice = ctx.GlobalUint64(SwarmRetrieveMaxChunkPriceFlag.Name)
+	}
+	if size := ctx.GlobalInt64(SwarmManifestInlineSizeFlag.Name); size > 0 {
+		currentConfig.ManifestInlineSize = size
+	}
+	if source := ctx.GlobalString(SwarmCloneFlag.Name); source != "" {
+		currentConfig.CloneSource = source
+	}
+	if ctx.GlobalIsSet(SwarmCloneBinsFlag.Name) {
+		currentConfig.CloneBins = nil
+		for _, bin := range ctx.GlobalIntSlice(SwarmCloneBinsFlag.Name) {
+			if bin < 0 || bin > chunk.MaxPO {
+				utils.Fatalf("invalid clone bin %d, expected 0 to %d", bin, chunk.MaxPO)
+			}
+			currentConfig.CloneBins = append(currentConfig.CloneBins, uint8(bin))
+		}
+	}
+	if ctx.GlobalIsSet(SwarmCloneServeFlag.Name) {
+		currentConfig.CloneServe = ctx.GlobalStringSlice(SwarmCloneServeFlag.Name)
+	}
+	if vmodule := ctx.GlobalString(vmoduleFlag.Name); vmodule != "" {
+		currentConfig.LogVmodule = vmodule
+	}
 	return currentConfig
 }
 
@@ -293,6 +462,34 @@ func dumpConfig(ctx *cli.Context) error {
 	return nil
 }
 
+// parsePssBridgeRoute parses a route of the pss bridge in the format of the pss-bridge flag,
+// whisper-topic:whisper-key:pss-topic[:pss-key] with all values in hex
+func parsePssBridgeRoute(s string) (route bridge.Route, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 && len(parts) != 4 {
+		return route, errors.New("expected format whisper-topic:whisper-key:pss-topic[:pss-key]")
+	}
+	values := make([][]byte, len(parts))
+	for i, part := range parts {
+		if values[i], err = hexutil.Decode(part); err != nil {
+			return route, err
+		}
+	}
+	if len(values[0]) != whisper.TopicLength {
+		return route, fmt.Errorf("whisper topic must be %d bytes long", whisper.TopicLength)
+	}
+	if len(values[2]) != message.TopicLength {
+		return route, fmt.Errorf("pss topic must be %d bytes long", message.TopicLength)
+	}
+	route.WhisperTopic = whisper.BytesToTopic(values[0])
+	route.WhisperSymKey = values[1]
+	copy(route.PssTopic[:], values[2])
+	if len(values) == 4 {
+		route.PssSymKey = values[3]
+	}
+	return route, route.Validate()
+}
+
 //validate configuration parameters
 func validateConfig(cfg *bzzapi.Config) (err error) {
 	for _, ensAPI := range cfg.EnsAPIs {
@@ -302,7 +499,7 @@ func validateConfig(cfg *bzzapi.Config) (err error) {
 			}
 		}
 	}
-	return nil
+	return cfg.Validate()
 }
 
 //validate EnsAPIs configuration parameter
@@ -25,6 +25,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -33,8 +34,10 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rpc"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
 	"github.com/ethersphere/swarm"
 	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/pss/message"
 	"github.com/ethersphere/swarm/swap"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -695,6 +698,14 @@ func TestConfigValidate(t *testing.T) {
 			}},
 			err: "invalid format [tld:][contract-addr@]url for ENS API endpoint configuration \"@/data/testnet/geth.ipc\": missing contract address",
 		},
+		{
+			cfg: &api.Config{CloneBins: []uint8{200}},
+			err: "invalid configuration: CloneBins: invalid bin 200, expected 0 to 16",
+		},
+		{
+			cfg: &api.Config{CloneServe: []string{"localhost:30399"}},
+			err: "invalid configuration: CloneServe: invalid enode URL \"localhost:30399\": invalid URL scheme, want \"enode\"",
+		},
 	} {
 		err := validateConfig(c.cfg)
 		if c.err != "" && err.Error() != c.err {
@@ -706,6 +717,39 @@ func TestConfigValidate(t *testing.T) {
 	}
 }
 
+func TestParsePssBridgeRoute(t *testing.T) {
+	key := "0x" + strings.Repeat("01", 32)
+	route, err := parsePssBridgeRoute("0x01020304:" + key + ":0x05060708:" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.WhisperTopic != (whisper.TopicType{1, 2, 3, 4}) || route.PssTopic != (message.Topic{5, 6, 7, 8}) {
+		t.Fatalf("unexpected topics %v and %v", route.WhisperTopic, route.PssTopic)
+	}
+	if len(route.WhisperSymKey) != 32 || len(route.PssSymKey) != 32 {
+		t.Fatalf("unexpected keys %x and %x", route.WhisperSymKey, route.PssSymKey)
+	}
+	route, err = parsePssBridgeRoute("0x01020304:" + key + ":0x05060708")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.PssSymKey != nil {
+		t.Fatalf("expected no pss key, got %x", route.PssSymKey)
+	}
+
+	for _, v := range []string{
+		"0x01020304:" + key,
+		"0x010203:" + key + ":0x05060708",
+		"0x01020304:0x0102:0x05060708",
+		"0x01020304:" + key + ":0x0506",
+		"0x01020304:" + key + ":05060708",
+	} {
+		if _, err := parsePssBridgeRoute(v); err == nil {
+			t.Errorf("expected error parsing %q", v)
+		}
+	}
+}
+
 func assignTCPPort() (string, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -74,6 +74,11 @@ var (
 		Usage:  "SWAP chequebook factory contract address",
 		EnvVar: SwarmEnvChequebookFactoryAddr,
 	}
+	SwarmSwapBeneficiaryFlag = cli.StringFlag{
+		Name:   "swap-beneficiary",
+		Usage:  "Address cheques for this node are issued to, cheques issued to an address other than the node's account are kept pending for the beneficiary to cash (default the node's account)",
+		EnvVar: SwarmEnvSwapBeneficiary,
+	}
 	SwarmSwapEnabledFlag = cli.BoolFlag{
 		Name:   "swap",
 		Usage:  "Swarm SWAP enabled (default false)",
@@ -94,6 +99,22 @@ var (
 		Usage:  "honey amount at which a peer disconnects",
 		EnvVar: SwarmEnvSwapDisconnectThreshold,
 	}
+	SwarmSwapFreeChunksFlag = cli.Int64Flag{
+		Name:  "swap-free-chunks",
+		Usage: "Number of chunks retrieved from and by every peer per window without accounting, so that peers without swap can retrieve small amounts",
+	}
+	SwarmSwapFreeBytesFlag = cli.Int64Flag{
+		Name:  "swap-free-bytes",
+		Usage: "Number of bytes retrieved from and by every peer per window without accounting",
+	}
+	SwarmSwapFreeWindowFlag = cli.DurationFlag{
+		Name:  "swap-free-window",
+		Usage: "Window of the free retrieval quota (default 1h)",
+	}
+	SwarmSwapConfirmationsFlag = cli.Uint64Flag{
+		Name:  "swap-confirmations",
+		Usage: "Number of blocks after which the chequebook deployment and cheque cashouts are considered final (default 6)",
+	}
 	SwarmNoSyncFlag = cli.BoolFlag{
 		Name:   "no-sync",
 		Usage:  "disable syncing",
@@ -114,6 +135,11 @@ var (
 		Usage:  "Enable Swarm LightNode (default false)",
 		EnvVar: SwarmEnvLightNodeEnable,
 	}
+	SwarmRetrieveRelayFlag = cli.BoolFlag{
+		Name:   "relay",
+		Usage:  "Relay the retrieve requests of peers on a light node, so that light nodes behind it can be served",
+		EnvVar: SwarmEnvRetrieveRelay,
+	}
 	EnsAPIFlag = cli.StringSliceFlag{
 		Name:   "ens-api",
 		Usage:  "ENS API endpoint for a TLD and with contract address, can be repeated, format [tld:][contract-addr@]url",
@@ -203,6 +229,18 @@ var (
 		Name:  "disable-auto-connect",
 		Usage: "Disables the peer discovery mechanism in the hive protocol as well as the auto connect loop (manual peer addition)",
 	}
+	SwarmStaticPeerFlag = cli.StringSliceFlag{
+		Name:  "static-peer",
+		Usage: "Peer to keep connected to independently of the kademlia connections, can be repeated, format role@enode-url",
+	}
+	SwarmStaticPeerTargetFlag = cli.StringSliceFlag{
+		Name:  "static-peer-target",
+		Usage: "Number of static peers of a role to keep connected (default all), can be repeated, format role:count",
+	}
+	SwarmChunkerProfileFlag = cli.StringFlag{
+		Name:  "chunker-profile",
+		Usage: "Chunk size profile uploaded content is split with (default, small)",
+	}
 	SwarmFeedNameFlag = cli.StringFlag{
 		Name:  "name",
 		Usage: "User-defined name for the new feed, limited to 32 characters. If combined with topic, it will refer to a subtopic with this name",
@@ -232,10 +270,111 @@ var (
 		Name:  "pin",
 		Usage: "Use this flag to pin the file after upload is complete. This flag is used when uploading a file.",
 	}
+	SwarmPushSyncReplicasFlag = cli.IntFlag{
+		Name:  "push-sync-replicas",
+		Usage: "Number of nodes of the neighbourhood a push-synced chunk is replicated to by the closest node, itself included, less than two leaves replication to pull syncing",
+	}
 	SwarmEnablePinningFlag = cli.BoolFlag{
 		Name:  "enable-pinning",
 		Usage: "Use this flag to enable the pinning feature",
 	}
+	SwarmPinRepairIntervalFlag = cli.DurationFlag{
+		Name:  "pin-repair-interval",
+		Usage: "Interval of probing pinned and uploaded content and re-pushing chunks missing from their neighbourhood, 0 disables it",
+	}
+	SwarmPinRepairSamplesFlag = cli.IntFlag{
+		Name:  "pin-repair-samples",
+		Usage: "Number of chunks of each pinned or uploaded root probed in a repair round",
+	}
+	SwarmPinRepairProbeTimeoutFlag = cli.DurationFlag{
+		Name:  "pin-repair-probe-timeout",
+		Usage: "Time to wait for a probed chunk to be retrieved before it is considered missing",
+	}
+	SwarmGatewayModeFlag = cli.BoolFlag{
+		Name:   "gateway",
+		Usage:  "Run a read-only gateway, disabling uploads, manifest modifications, feed updates, pinning and FUSE writes",
+		EnvVar: SwarmEnvGatewayMode,
+	}
+	SwarmAPIKeysFlag = cli.BoolFlag{
+		Name:   "api-keys",
+		Usage:  "Require an API key on HTTP requests and enforce its quota, keys are managed with the apikeys RPC API",
+		EnvVar: SwarmEnvAPIKeys,
+	}
+	SwarmWebSocketPssFlag = cli.BoolFlag{
+		Name:   "ws-pss",
+		Usage:  "Let clients of the /bzz-ws WebSocket endpoint of the HTTP gateway subscribe to pss topics",
+		EnvVar: SwarmEnvWebSocketPss,
+	}
+	SwarmPssMailboxFlag = cli.BoolFlag{
+		Name:   "pss-mailbox",
+		Usage:  "Deposit pss messages for offline recipients in mailboxes published in feeds of this node",
+		EnvVar: SwarmEnvPssMailbox,
+	}
+	SwarmPssMailboxOwnerFlag = cli.StringSliceFlag{
+		Name:  "pss-mailbox-owner",
+		Usage: "Address of a node whose mailbox the pss messages deposited for this node are fetched from, can be repeated",
+	}
+	SwarmPssBridgeFlag = cli.StringSliceFlag{
+		Name:  "pss-bridge",
+		Usage: "Whisper topic whose messages are relayed to a pss topic and back, can be repeated, format whisper-topic:whisper-key:pss-topic[:pss-key] in hex, messages are relayed raw to all nodes without a pss key",
+	}
+	SwarmSyncUpdateDelayFlag = cli.DurationFlag{
+		Name:   "sync-update-delay",
+		Usage:  "Time the syncing server waits for more chunks before it offers an incomplete batch, can be changed with a config reload (default 2s)",
+		EnvVar: SwarmEnvSyncUpdateDelay,
+	}
+	SwarmSyncBandwidthFlag = cli.Int64Flag{
+		Name:  "sync-bandwidth",
+		Usage: "Bytes of chunk data per second delivered to syncing peers at most, 0 is unlimited, can be changed with a config reload",
+	}
+	SwarmRetrieveBandwidthFlag = cli.Int64Flag{
+		Name:  "retrieve-bandwidth",
+		Usage: "Bytes of chunk data per second delivered for retrieve requests at most, 0 is unlimited, can be changed with a config reload",
+	}
+	SwarmRetrieveMaxHopsFlag = cli.UintFlag{
+		Name:  "retrieve-max-hops",
+		Usage: "Number of times a retrieve request is forwarded at most before it is dropped (default 20)",
+	}
+	SwarmRetrieveLatencyFlag = cli.BoolFlag{
+		Name:  "retrieve-latency",
+		Usage: "Forward retrieve requests to the peer with the lowest measured round trip time among the peers equally close to the chunk",
+	}
+	SwarmRetrieveFarFetchedFlag = cli.StringFlag{
+		Name:  "retrieve-far-fetched",
+		Usage: "How retrieve requests for chunks far outside the neighbourhood from peers no farther from the chunk are treated: serve, local (serve from the local store only) or reject (default serve)",
+	}
+	SwarmRetrieveFarFetchedSlackFlag = cli.IntFlag{
+		Name:  "retrieve-far-fetched-slack",
+		Usage: "Number of proximity orders a chunk can be outside the neighbourhood depth before retrieve requests for it are far fetched (default 2)",
+	}
+	SwarmRetrieveChunkPriceFlag = cli.Uint64Flag{
+		Name:  "retrieve-chunk-price",
+		Usage: "Price in honey of the chunks delivered for retrieve requests priced per chunk (default the swap chunk price)",
+	}
+	SwarmRetrieveFreeNeighboursFlag = cli.BoolFlag{
+		Name:  "retrieve-free-neighbours",
+		Usage: "Deliver chunks free of charge for retrieve requests priced per chunk from peers in the neighbourhood",
+	}
+	SwarmRetrieveMaxChunkPriceFlag = cli.Uint64Flag{
+		Name:  "retrieve-max-chunk-price",
+		Usage: "The most paid in honey for a retrieved chunk, offered in retrieve requests so that they are priced per chunk, 0 prices them per message",
+	}
+	SwarmManifestInlineSizeFlag = cli.Int64Flag{
+		Name:  "manifest-inline-size",
+		Usage: "Inline the content of uploaded files up to this size in bytes in their manifest entries, so that they are served without retrieving further chunks, at most 1024, 0 disables it",
+	}
+	SwarmCloneFlag = cli.StringFlag{
+		Name:  "clone",
+		Usage: "Enode URL of a node to import all locally stored chunks from, to replace it as a storer; an interrupted import resumes on restart",
+	}
+	SwarmCloneBinsFlag = cli.IntSliceFlag{
+		Name:  "clone-bins",
+		Usage: "Proximity order bin to import from the --clone node, can be repeated (default all bins)",
+	}
+	SwarmCloneServeFlag = cli.StringSliceFlag{
+		Name:  "clone-serve",
+		Usage: "Enode URL of a node allowed to clone the local store with --clone, can be repeated (default none)",
+	}
 	SwarmProgressFlag = cli.BoolFlag{
 		Name:  "progress",
 		Usage: "Use this flag to enable tracking of the upload progress through the CLI",
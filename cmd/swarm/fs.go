@@ -61,6 +61,22 @@ var fsCommand = cli.Command{
 			ArgsUsage:          "swarm fs list",
 			Description:        "Lists all mounted swarmfs volumes. This assumes you already have a Swarm node running locally. You must reference the correct path to your bzzd.ipc file",
 		},
+		{
+			Action:             mountStats,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "stats",
+			Usage:              "show the statistics of a swarmfs mount",
+			ArgsUsage:          "swarm fs stats <mount point>",
+			Description:        "Shows the reads, writes, chunk fetches and open handles of the swarmfs mount residing at <mount point> since it was mounted. This assumes you already have a Swarm node running locally. You must reference the correct path to your bzzd.ipc file",
+		},
+		{
+			Action:             purgeTrash,
+			CustomHelpTemplate: helpTemplate,
+			Name:               "purge-trash",
+			Usage:              "purge the trash of a swarmfs mount",
+			ArgsUsage:          "swarm fs purge-trash <mount point>",
+			Description:        "Removes the files moved to the .trash directory on removal from the latest manifest of the swarmfs mount residing at <mount point>. This assumes you already have a Swarm node running locally. You must reference the correct path to your bzzd.ipc file",
+		},
 	},
 }
 
@@ -113,6 +129,29 @@ func unmount(cliContext *cli.Context) {
 	fmt.Printf("%s\n", mf.LatestManifest) //print the latest manifest hash for user reference
 }
 
+func purgeTrash(cliContext *cli.Context) {
+	args := cliContext.Args()
+
+	if len(args) < 1 {
+		utils.Fatalf("Usage: swarm fs purge-trash <mount path>")
+	}
+	client, err := dialRPC(cliContext)
+	if err != nil {
+		utils.Fatalf("had an error dailing to RPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	mf := fuse.MountInfo{}
+	err = client.CallContext(ctx, &mf, "swarmfs_purgeTrash", args[0])
+	if err != nil {
+		utils.Fatalf("encountered an error calling the RPC endpoint while purging the trash: %v", err)
+	}
+	fmt.Printf("%s\n", mf.LatestManifest) //print the latest manifest hash for user reference
+}
+
 func listMounts(cliContext *cli.Context) {
 	client, err := dialRPC(cliContext)
 	if err != nil {
@@ -137,10 +176,41 @@ func listMounts(cliContext *cli.Context) {
 			fmt.Printf("\tMount point: %s\n", mountInfo.MountPoint)
 			fmt.Printf("\tLatest Manifest: %s\n", mountInfo.LatestManifest)
 			fmt.Printf("\tStart Manifest: %s\n", mountInfo.StartManifest)
+			printMountStats(&mountInfo.Stats)
 		}
 	}
 }
 
+func mountStats(cliContext *cli.Context) {
+	args := cliContext.Args()
+
+	if len(args) < 1 {
+		utils.Fatalf("Usage: swarm fs stats <mount path>")
+	}
+	client, err := dialRPC(cliContext)
+	if err != nil {
+		utils.Fatalf("had an error dailing to RPC endpoint: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stats := fuse.MountStats{}
+	err = client.CallContext(ctx, &stats, "swarmfs_stats", args[0])
+	if err != nil {
+		utils.Fatalf("encountered an error calling the RPC endpoint while getting the mount stats: %v", err)
+	}
+	printMountStats(&stats)
+}
+
+func printMountStats(stats *fuse.MountStats) {
+	fmt.Printf("\tReads: %d (%d bytes)\n", stats.Reads, stats.BytesRead)
+	fmt.Printf("\tWrites: %d (%d bytes)\n", stats.Writes, stats.BytesWritten)
+	fmt.Printf("\tChunks: %d local, %d fetched (%.1f%% cache hits)\n", stats.ChunkHits, stats.ChunkFetches, stats.CacheHitRatio*100)
+	fmt.Printf("\tOpen handles: %d\n", stats.OpenHandles)
+}
+
 func dialRPC(ctx *cli.Context) (*rpc.Client, error) {
 	endpoint := getIPCEndpoint(ctx)
 	log.Info("IPC endpoint", "path", endpoint)
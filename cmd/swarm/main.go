@@ -136,6 +136,8 @@ func init() {
 		feedCommand,
 		// See list.go
 		listCommand,
+		// See verify.go
+		verifyCommand,
 		// See hash.go
 		hashCommand,
 		// See download.go
@@ -188,17 +190,47 @@ func init() {
 		SwarmSwapLogLevelFlag,
 		SwarmSwapChequebookAddrFlag,
 		SwarmSwapChequebookFactoryFlag,
+		SwarmSwapBeneficiaryFlag,
 		SwarmSwapSkipDepositFlag,
 		SwarmSwapDepositAmountFlag,
+		SwarmSwapFreeChunksFlag,
+		SwarmSwapFreeBytesFlag,
+		SwarmSwapFreeWindowFlag,
+		SwarmSwapConfirmationsFlag,
 		// end of swap flags
 		SwarmNoSyncFlag,
+		SwarmPushSyncReplicasFlag,
 		SwarmLightNodeEnabled,
+		SwarmRetrieveRelayFlag,
 		SwarmListenAddrFlag,
 		SwarmPortFlag,
 		SwarmAccountFlag,
 		SwarmBzzKeyHexFlag,
 		SwarmNetworkIdFlag,
 		SwarmEnablePinningFlag,
+		SwarmPinRepairIntervalFlag,
+		SwarmPinRepairSamplesFlag,
+		SwarmPinRepairProbeTimeoutFlag,
+		SwarmGatewayModeFlag,
+		SwarmAPIKeysFlag,
+		SwarmWebSocketPssFlag,
+		SwarmPssMailboxFlag,
+		SwarmPssMailboxOwnerFlag,
+		SwarmPssBridgeFlag,
+		SwarmSyncUpdateDelayFlag,
+		SwarmSyncBandwidthFlag,
+		SwarmRetrieveBandwidthFlag,
+		SwarmRetrieveMaxHopsFlag,
+		SwarmRetrieveLatencyFlag,
+		SwarmRetrieveFarFetchedFlag,
+		SwarmRetrieveFarFetchedSlackFlag,
+		SwarmRetrieveChunkPriceFlag,
+		SwarmRetrieveFreeNeighboursFlag,
+		SwarmRetrieveMaxChunkPriceFlag,
+		SwarmManifestInlineSizeFlag,
+		SwarmCloneFlag,
+		SwarmCloneBinsFlag,
+		SwarmCloneServeFlag,
 		// upload flags
 		SwarmApiFlag,
 		SwarmRecursiveFlag,
@@ -209,11 +241,14 @@ func init() {
 		// bootnode mode
 		SwarmBootnodeModeFlag,
 		SwarmDisableAutoConnectFlag,
+		SwarmStaticPeerFlag,
+		SwarmStaticPeerTargetFlag,
 		// storage flags
 		SwarmStorePath,
 		SwarmStoreCapacity,
 		SwarmStoreCacheCapacity,
 		SwarmGlobalStoreAPIFlag,
+		SwarmChunkerProfileFlag,
 		// debugging
 		SwarmMutexProfileFlag,
 		SwarmBlockProfileFlag,
@@ -247,13 +282,14 @@ func init() {
 			return err
 		}
 		swarmmetrics.Setup(swarmmetrics.Options{
-			Endoint:       ctx.GlobalString(flags.MetricsInfluxDBEndpointFlag.Name),
-			Database:      ctx.GlobalString(flags.MetricsInfluxDBDatabaseFlag.Name),
-			Username:      ctx.GlobalString(flags.MetricsInfluxDBUsernameFlag.Name),
-			Password:      ctx.GlobalString(flags.MetricsInfluxDBPasswordFlag.Name),
-			EnableExport:  ctx.GlobalBool(flags.MetricsEnableInfluxDBExportFlag.Name),
-			DataDirectory: ctx.GlobalString(utils.DataDirFlag.Name),
-			InfluxDBTags:  ctx.GlobalString(flags.MetricsInfluxDBTagsFlag.Name),
+			Endoint:        ctx.GlobalString(flags.MetricsInfluxDBEndpointFlag.Name),
+			Database:       ctx.GlobalString(flags.MetricsInfluxDBDatabaseFlag.Name),
+			Username:       ctx.GlobalString(flags.MetricsInfluxDBUsernameFlag.Name),
+			Password:       ctx.GlobalString(flags.MetricsInfluxDBPasswordFlag.Name),
+			EnableExport:   ctx.GlobalBool(flags.MetricsEnableInfluxDBExportFlag.Name),
+			DataDirectory:  ctx.GlobalString(utils.DataDirFlag.Name),
+			InfluxDBTags:   ctx.GlobalString(flags.MetricsInfluxDBTagsFlag.Name),
+			PrometheusAddr: ctx.GlobalString(flags.MetricsPrometheusAddrFlag.Name),
 		})
 		tracing.Setup(tracing.Options{
 			Enabled:  ctx.GlobalBool(flags.TracingEnabledFlag.Name),
@@ -366,7 +402,31 @@ func bzzd(ctx *cli.Context) error {
 		stack.Stop()
 	}()
 
-	// add swarm bootnodes, because swarm doesn't use p2p package's discovery discv5
+	//reload the operational parameters on sighup or through the bzzconfig API
+	var swarmService *swarm.Swarm
+	if err := stack.Service(&swarmService); err != nil {
+		return err
+	}
+	swarmService.SetConfigLoader(func() (*bzzapi.Config, error) {
+		return buildConfig(ctx)
+	})
+	go func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGHUP)
+		defer signal.Stop(sigc)
+		for range sigc {
+			log.Info("Got sighup, reloading swarm config...")
+			changes, err := swarmService.ReloadConfig()
+			if err != nil {
+				log.Error("Failed to reload swarm config", "applied", len(changes), "err", err)
+				continue
+			}
+			log.Info("Reloaded swarm config", "changes", len(changes))
+		}
+	}()
+
+	// add swarm bootnodes, because swarm doesn't use p2p package's discovery,
+	// except for the bzz topics of discovery v5 if it is enabled with --v5disc
 	go func() {
 		s := stack.Server()
 
@@ -0,0 +1,71 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	swarm "github.com/ethersphere/swarm/api/client"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var verifyCommand = cli.Command{
+	Action:             verify,
+	CustomHelpTemplate: helpTemplate,
+	Name:               "verify",
+	Usage:              "verify that all chunks of a manifest and its content are retrievable and intact",
+	ArgsUsage:          "<manifest>",
+	Description: `Retrieves every chunk of the manifest, of its submanifests and of the content
+of their entries and checks that it hashes to its address. The missing and corrupt
+chunks are listed with the paths of the entries they belong to, and the command
+exits with an error if there are any, e.g. to validate a backup before relying on it.`,
+}
+
+func verify(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) != 1 {
+		utils.Fatalf("Usage: swarm verify <manifest>")
+	}
+
+	bzzapi := strings.TrimRight(ctx.GlobalString(SwarmApiFlag.Name), "/")
+	client := swarm.NewClient(bzzapi)
+	report, err := client.VerifyManifest(args[0], "")
+	if err != nil {
+		utils.Fatalf("Failed to verify manifest: %s", err)
+	}
+
+	fmt.Printf("verified %d entries and %d chunks of %s\n", report.Entries, report.Chunks, report.Root)
+	if len(report.Problems) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 2, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tCHUNK\tERROR")
+	for _, p := range report.Problems {
+		path := p.Path
+		if path == "" {
+			path = "/"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", path, p.Chunk, p.Err)
+	}
+	w.Flush()
+	utils.Fatalf("Found %d problems", len(report.Problems))
+}
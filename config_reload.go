@@ -0,0 +1,379 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swarm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/internal/debug"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/state"
+)
+
+// maxConfigChanges is the number of the latest applied config changes kept for auditing
+const maxConfigChanges = 100
+
+// configChangesKey is the state store key of the applied config changes
+const configChangesKey = "config_changes"
+
+var errNoConfigLoader = errors.New("config reload not supported")
+
+// ConfigChange is a change of an operational parameter applied without restarting the node
+type ConfigChange struct {
+	Time  time.Time `json:"time"`
+	Param string    `json:"param"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+}
+
+// configParam is an operational parameter changed by a config
+type configParam struct {
+	name     string
+	old, new interface{}
+	validate func() error // may be nil
+	apply    func() error
+}
+
+// SetConfigLoader sets the function that loads the config on ReloadConfig,
+// from the same sources the node was started with
+func (s *Swarm) SetConfigLoader(load func() (*api.Config, error)) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	s.configLoader = load
+}
+
+// ReloadConfig loads the config with the function set by SetConfigLoader and
+// applies the operational parameters that changed, see ApplyConfig
+func (s *Swarm) ReloadConfig() ([]ConfigChange, error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	if s.configLoader == nil {
+		return nil, errNoConfigLoader
+	}
+	c, err := s.configLoader()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %v", err)
+	}
+	return s.applyConfig(c)
+}
+
+// ApplyConfig changes the operational parameters of the running node to the ones
+// of the config: the sync update delay, the bandwidth caps of retrieval and syncing,
+// the free retrieval quota, the local store capacity, the swap thresholds and the
+// per-module log verbosity. All changed parameters are validated before any is
+// applied, other parameters of the config take effect only on restart and are ignored.
+func (s *Swarm) ApplyConfig(c *api.Config) ([]ConfigChange, error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	return s.applyConfig(c)
+}
+
+// ConfigChanges returns the latest config changes applied to the node, oldest first
+func (s *Swarm) ConfigChanges() []ConfigChange {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	return append([]ConfigChange(nil), s.configChanges...)
+}
+
+// Config returns the current config of the node, with the operational parameters
+// changed since it was started, it must not be modified
+func (s *Swarm) Config() *api.Config {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	return s.config
+}
+
+// applyConfig applies the changed parameters, the caller is expected to hold reloadLock
+// The applied parameters are set on a copy of the current config, which replaces it
+// once all of them are applied, so that the readers of the config never see it change
+func (s *Swarm) applyConfig(c *api.Config) (changes []ConfigChange, err error) {
+	cur := s.config
+	next := *cur
+	var params []*configParam
+
+	// the vmodule pattern is validated when it is applied, so it goes first
+	if c.LogVmodule != cur.LogVmodule {
+		params = append(params, &configParam{
+			name: "LogVmodule", old: cur.LogVmodule, new: c.LogVmodule,
+			apply: func() error {
+				if err := debug.Handler.Vmodule(c.LogVmodule); err != nil {
+					return err
+				}
+				next.LogVmodule = c.LogVmodule
+				return nil
+			},
+		})
+	}
+	if c.SyncUpdateDelay != cur.SyncUpdateDelay {
+		params = append(params, &configParam{
+			name: "SyncUpdateDelay", old: cur.SyncUpdateDelay, new: c.SyncUpdateDelay,
+			validate: func() error {
+				if c.SyncUpdateDelay <= 0 {
+					return errors.New("must be positive")
+				}
+				return nil
+			},
+			apply: func() error {
+				s.streamer.SetBatchTimeout(c.SyncUpdateDelay)
+				next.SyncUpdateDelay = c.SyncUpdateDelay
+				return nil
+			},
+		})
+	}
+	if c.RetrieveBandwidth != cur.RetrieveBandwidth {
+		params = append(params, &configParam{
+			name: "RetrieveBandwidth", old: cur.RetrieveBandwidth, new: c.RetrieveBandwidth,
+			validate: func() error {
+				if c.RetrieveBandwidth < 0 {
+					return errors.New("must not be negative")
+				}
+				return nil
+			},
+			apply: func() error {
+				s.retrieval.SetBandwidth(c.RetrieveBandwidth)
+				next.RetrieveBandwidth = c.RetrieveBandwidth
+				return nil
+			},
+		})
+	}
+	if c.SyncBandwidth != cur.SyncBandwidth {
+		params = append(params, &configParam{
+			name: "SyncBandwidth", old: cur.SyncBandwidth, new: c.SyncBandwidth,
+			validate: func() error {
+				if c.SyncBandwidth < 0 {
+					return errors.New("must not be negative")
+				}
+				return nil
+			},
+			apply: func() error {
+				s.streamer.SetBandwidth(c.SyncBandwidth)
+				next.SyncBandwidth = c.SyncBandwidth
+				return nil
+			},
+		})
+	}
+	if c.DbCapacity != cur.DbCapacity {
+		params = append(params, &configParam{
+			name: "DbCapacity", old: cur.DbCapacity, new: c.DbCapacity,
+			apply: func() error {
+				if err := s.localStore.SetCapacity(c.DbCapacity); err != nil {
+					return err
+				}
+				next.DbCapacity = c.DbCapacity
+				return nil
+			},
+		})
+	}
+	if c.SwapFreeChunks != cur.SwapFreeChunks || c.SwapFreeBytes != cur.SwapFreeBytes || c.SwapFreeWindow != cur.SwapFreeWindow {
+		params = append(params, &configParam{
+			name: "SwapFreeQuota",
+			old:  freeQuotaString(cur.SwapFreeChunks, cur.SwapFreeBytes, cur.SwapFreeWindow),
+			new:  freeQuotaString(c.SwapFreeChunks, c.SwapFreeBytes, c.SwapFreeWindow),
+			validate: func() error {
+				if c.SwapFreeChunks < 0 || c.SwapFreeBytes < 0 {
+					return errors.New("negative limit")
+				}
+				if (c.SwapFreeChunks > 0 || c.SwapFreeBytes > 0) && c.SwapFreeWindow <= 0 {
+					return errors.New("window must be positive")
+				}
+				return nil
+			},
+			apply: func() error {
+				s.retrieval.SetFreeQuota(c.SwapFreeChunks, c.SwapFreeBytes, c.SwapFreeWindow)
+				next.SwapFreeChunks, next.SwapFreeBytes, next.SwapFreeWindow = c.SwapFreeChunks, c.SwapFreeBytes, c.SwapFreeWindow
+				return nil
+			},
+		})
+	}
+	if s.swap != nil && (c.SwapPaymentThreshold != cur.SwapPaymentThreshold || c.SwapDisconnectThreshold != cur.SwapDisconnectThreshold) {
+		params = append(params, &configParam{
+			name: "SwapThresholds",
+			old:  fmt.Sprintf("payment %d, disconnect %d", cur.SwapPaymentThreshold, cur.SwapDisconnectThreshold),
+			new:  fmt.Sprintf("payment %d, disconnect %d", c.SwapPaymentThreshold, c.SwapDisconnectThreshold),
+			validate: func() error {
+				if c.SwapDisconnectThreshold <= c.SwapPaymentThreshold {
+					return errors.New("disconnect threshold must be higher than payment threshold")
+				}
+				return nil
+			},
+			apply: func() error {
+				if err := s.swap.SetThresholds(int64(c.SwapPaymentThreshold), int64(c.SwapDisconnectThreshold)); err != nil {
+					return err
+				}
+				next.SwapPaymentThreshold, next.SwapDisconnectThreshold = c.SwapPaymentThreshold, c.SwapDisconnectThreshold
+				return nil
+			},
+		})
+	}
+
+	for _, p := range params {
+		if p.validate == nil {
+			continue
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s %v: %v", p.name, p.new, err)
+		}
+	}
+
+	// the changes applied before an error are recorded
+	defer func() {
+		if len(changes) == 0 {
+			return
+		}
+		s.configMu.Lock()
+		s.config = &next
+		s.configMu.Unlock()
+
+		s.configChanges = append(s.configChanges, changes...)
+		if l := len(s.configChanges); l > maxConfigChanges {
+			s.configChanges = s.configChanges[l-maxConfigChanges:]
+		}
+		if err := s.stateStore.Put(configChangesKey, s.configChanges); err != nil {
+			log.Error("Failed to store config changes", "err", err)
+		}
+	}()
+	for _, p := range params {
+		if err := p.apply(); err != nil {
+			return changes, fmt.Errorf("apply %s %v: %v", p.name, p.new, err)
+		}
+		change := ConfigChange{
+			Time:  time.Now(),
+			Param: p.name,
+			Old:   fmt.Sprint(p.old),
+			New:   fmt.Sprint(p.new),
+		}
+		log.Info("Applied config change", "param", change.Param, "old", change.Old, "new", change.New)
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// loadConfigChanges loads the config changes applied before the node was started
+func (s *Swarm) loadConfigChanges() error {
+	err := s.stateStore.Get(configChangesKey, &s.configChanges)
+	if err == state.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// SetLogVmodule changes the log verbosity of the modules matching the patterns
+// of the rules, in the syntax of the vmodule flag, e.g. "pss/*=5,hive.go=4",
+// and keeps the verbosity of the other modules. A level of zero removes the
+// rule of the pattern. The rules are applied as a LogVmodule config change,
+// so a reload of the config file resets them. It returns the resulting rules.
+func (s *Swarm) SetLogVmodule(rules string) (string, error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+	vmodule, err := mergeVmodule(s.config.LogVmodule, rules)
+	if err != nil {
+		return "", err
+	}
+	c := *s.config
+	c.LogVmodule = vmodule
+	if _, err := s.applyConfig(&c); err != nil {
+		return "", err
+	}
+	return vmodule, nil
+}
+
+// mergeVmodule returns the vmodule rules with the rules of the update, which go
+// first to take precedence over the broader patterns of the current rules
+func mergeVmodule(current, update string) (string, error) {
+	type rule struct {
+		pattern string
+		level   int
+	}
+	parse := func(rules string) (parsed []rule, err error) {
+		for _, r := range strings.Split(rules, ",") {
+			if strings.TrimSpace(r) == "" {
+				continue
+			}
+			parts := strings.Split(r, "=")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid vmodule rule %q", r)
+			}
+			pattern := strings.TrimSpace(parts[0])
+			level, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if pattern == "" || err != nil {
+				return nil, fmt.Errorf("invalid vmodule rule %q", r)
+			}
+			parsed = append(parsed, rule{pattern, level})
+		}
+		return parsed, nil
+	}
+	cur, err := parse(current)
+	if err != nil {
+		return "", err
+	}
+	upd, err := parse(update)
+	if err != nil {
+		return "", err
+	}
+
+	var merged []string
+	seen := make(map[string]bool)
+	for _, r := range append(upd, cur...) {
+		if seen[r.pattern] {
+			continue
+		}
+		seen[r.pattern] = true
+		if r.level > 0 {
+			merged = append(merged, fmt.Sprintf("%s=%d", r.pattern, r.level))
+		}
+	}
+	return strings.Join(merged, ","), nil
+}
+
+func freeQuotaString(chunks, bytes int64, window time.Duration) string {
+	return fmt.Sprintf("%d chunks, %d bytes per %v", chunks, bytes, window)
+}
+
+// ConfigAPI provides an API to reload the operational parameters
+// of the node and to audit the applied changes
+type ConfigAPI struct {
+	swarm *Swarm
+}
+
+// Reload loads the config from the sources the node was started with
+// and applies the operational parameters that changed
+func (a *ConfigAPI) Reload() ([]ConfigChange, error) {
+	return a.swarm.ReloadConfig()
+}
+
+// Changes returns the latest config changes applied to the node, oldest first
+func (a *ConfigAPI) Changes() []ConfigChange {
+	return a.swarm.ConfigChanges()
+}
+
+// LogAPI provides an API to change the log verbosity of the modules of the node
+// at runtime, it is registered in the debug namespace
+type LogAPI struct {
+	swarm *Swarm
+}
+
+// VmoduleSwarm changes the log verbosity of the modules matching the patterns of
+// the rules, e.g. "network/stream=5,pss/*=4", and returns the resulting rules
+// of all modules, see Swarm.SetLogVmodule. Empty rules change nothing.
+func (a *LogAPI) VmoduleSwarm(rules string) (string, error) {
+	return a.swarm.SetLogVmodule(rules)
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swarm
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/internal/debug"
+)
+
+// TestReloadConfig tests that the changed operational parameters are validated
+// and applied to the running node, and that the applied changes are persisted
+func TestReloadConfig(t *testing.T) {
+	config := api.NewConfig()
+
+	dir, err := ioutil.TempDir("", "swarm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config.Path = dir
+
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodekey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Init(privkey, nodekey)
+
+	s, err := NewSwarm(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ReloadConfig(); err != errNoConfigLoader {
+		t.Fatalf("expected error %v, got %v", errNoConfigLoader, err)
+	}
+
+	var loaded api.Config
+	var loadErr error
+	s.SetConfigLoader(func() (*api.Config, error) {
+		c := loaded
+		return &c, loadErr
+	})
+
+	// no change of the operational parameters is applied if any of them is invalid
+	loaded = *config
+	loaded.DbCapacity = 1000
+	loaded.SyncUpdateDelay = -time.Second
+	if _, err := s.ReloadConfig(); err == nil {
+		t.Fatal("expected error reloading invalid sync update delay")
+	}
+	if capacity := s.localStore.Capacity(); capacity == 1000 {
+		t.Fatal("expected capacity not changed by invalid config")
+	}
+
+	loadErr = errors.New("bad config")
+	if _, err := s.ReloadConfig(); err == nil {
+		t.Fatal("expected error loading config")
+	}
+	loadErr = nil
+
+	loaded.SyncUpdateDelay = 100 * time.Millisecond
+	loaded.LogVmodule = "network/*=4"
+	defer debug.Handler.Vmodule("")
+	changes, err := s.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if capacity := s.localStore.Capacity(); capacity != 1000 {
+		t.Fatalf("expected capacity %v, got %v", 1000, capacity)
+	}
+	if delay := s.streamer.BatchTimeout(); delay != 100*time.Millisecond {
+		t.Fatalf("expected sync update delay %v, got %v", 100*time.Millisecond, delay)
+	}
+	// the changes are applied to a copy of the config the node was started with
+	if c := s.Config(); c.DbCapacity != 1000 || c.SyncUpdateDelay != 100*time.Millisecond || c.LogVmodule != "network/*=4" {
+		t.Fatalf("expected changed config, got %+v", c)
+	}
+	if config.DbCapacity == 1000 || config.LogVmodule != "" {
+		t.Fatal("expected the config the node was started with not changed")
+	}
+
+	// reloading an unchanged config applies nothing
+	changes, err = s.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+
+	// the bandwidth caps are operational parameters
+	loaded.RetrieveBandwidth = 1 << 20
+	loaded.SyncBandwidth = -1
+	if _, err := s.ReloadConfig(); err == nil {
+		t.Fatal("expected error reloading negative sync bandwidth")
+	}
+	loaded.SyncBandwidth = 1 << 19
+	changes, err = s.ReloadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Param != "RetrieveBandwidth" || changes[1].Param != "SyncBandwidth" {
+		t.Fatalf("expected bandwidth changes, got %+v", changes)
+	}
+	if c := s.Config(); c.RetrieveBandwidth != 1<<20 || c.SyncBandwidth != 1<<19 {
+		t.Fatalf("expected bandwidth caps %v and %v, got %v and %v", 1<<20, 1<<19, c.RetrieveBandwidth, c.SyncBandwidth)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the applied changes are audited across restarts
+	s, err = NewSwarm(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	audited := s.ConfigChanges()
+	if len(audited) != 5 {
+		t.Fatalf("expected 5 audited changes, got %+v", audited)
+	}
+	for i, param := range []string{"LogVmodule", "SyncUpdateDelay", "DbCapacity"} {
+		if audited[i].Param != param {
+			t.Fatalf("expected change %v of %s, got %+v", i, param, audited[i])
+		}
+	}
+	if audited[2].New != "1000" {
+		t.Fatalf("expected new capacity %v, got %v", 1000, audited[2].New)
+	}
+
+	// the log verbosity of modules is changed as a config change
+	vmodule, err := s.SetLogVmodule("pss/*=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vmodule != "pss/*=5" {
+		t.Fatalf("expected vmodule %q, got %q", "pss/*=5", vmodule)
+	}
+	if _, err := s.SetLogVmodule("pss=x"); err == nil {
+		t.Fatal("expected error setting invalid vmodule")
+	}
+	audited = s.ConfigChanges()
+	if len(audited) != 6 || audited[5].Param != "LogVmodule" || audited[5].New != vmodule {
+		t.Fatalf("expected LogVmodule change to %q, got %+v", vmodule, audited)
+	}
+}
+
+func TestMergeVmodule(t *testing.T) {
+	for _, tc := range []struct {
+		current, update string
+		want            string
+		err             bool
+	}{
+		{"", "", "", false},
+		{"network/*=4", "", "network/*=4", false},
+		{"", "pss=5", "pss=5", false},
+		{"network/*=4", "network/stream=5", "network/stream=5,network/*=4", false},
+		{"network/*=4,pss=5", "pss = 3", "pss=3,network/*=4", false},
+		{"network/*=4,pss=5", "network/*=0", "pss=5", false},
+		{"network/*=4", "pss", "", true},
+		{"network/*=4", "pss=high", "", true},
+		{"network/*=4", "=5", "", true},
+	} {
+		got, err := mergeVmodule(tc.current, tc.update)
+		if (err != nil) != tc.err {
+			t.Fatalf("merge %q into %q: expected error %v, got %v", tc.update, tc.current, tc.err, err)
+		}
+		if got != tc.want {
+			t.Fatalf("merge %q into %q: expected %q, got %q", tc.update, tc.current, tc.want, got)
+		}
+	}
+}
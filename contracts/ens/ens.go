@@ -22,7 +22,9 @@ package ens
 //go:generate abigen --sol contract/PublicResolver.sol --exc contract/ENS.sol:ENS --pkg contract --out contract/publicresolver.go
 
 import (
+	"context"
 	"encoding/binary"
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -104,8 +106,8 @@ func EnsNode(name string) common.Hash {
 	return crypto.Keccak256Hash(parentNode[:], parentLabel[:])
 }
 
-func (ens *ENS) getResolver(node [32]byte) (*contract.PublicResolverSession, error) {
-	resolverAddr, err := ens.Resolver(node)
+func (ens *ENS) getResolver(opts bind.CallOpts, node [32]byte) (*contract.PublicResolverSession, error) {
+	resolverAddr, err := ens.Contract.Resolver(&opts, node)
 	if err != nil {
 		return nil, err
 	}
@@ -115,12 +117,13 @@ func (ens *ENS) getResolver(node [32]byte) (*contract.PublicResolverSession, err
 	}
 	return &contract.PublicResolverSession{
 		Contract:     resolver,
+		CallOpts:     opts,
 		TransactOpts: ens.TransactOpts,
 	}, nil
 }
 
-func (ens *ENS) getFallbackResolver(node [32]byte) (*fallback_contract.PublicResolverSession, error) {
-	resolverAddr, err := ens.Resolver(node)
+func (ens *ENS) getFallbackResolver(opts bind.CallOpts, node [32]byte) (*fallback_contract.PublicResolverSession, error) {
+	resolverAddr, err := ens.Contract.Resolver(&opts, node)
 	if err != nil {
 		return nil, err
 	}
@@ -130,6 +133,7 @@ func (ens *ENS) getFallbackResolver(node [32]byte) (*fallback_contract.PublicRes
 	}
 	return &fallback_contract.PublicResolverSession{
 		Contract:     resolver,
+		CallOpts:     opts,
 		TransactOpts: ens.TransactOpts,
 	}, nil
 }
@@ -151,9 +155,23 @@ func (ens *ENS) getRegistrar(node [32]byte) (*contract.FIFSRegistrarSession, err
 
 // Resolve is a non-transactional call that returns the content hash associated with a name.
 func (ens *ENS) Resolve(name string) (common.Hash, error) {
+	return ens.resolve(ens.CallOpts, name)
+}
+
+// ResolveAt is a non-transactional call that returns the content hash associated with
+// a name in the state of the chain at the block number, or at the latest block if the
+// block number is nil.
+func (ens *ENS) ResolveAt(ctx context.Context, name string, blockNumber *big.Int) (common.Hash, error) {
+	opts := ens.CallOpts
+	opts.Context = ctx
+	opts.BlockNumber = blockNumber
+	return ens.resolve(opts, name)
+}
+
+func (ens *ENS) resolve(opts bind.CallOpts, name string) (common.Hash, error) {
 	node := EnsNode(name)
 
-	resolver, err := ens.getResolver(node)
+	resolver, err := ens.getResolver(opts, node)
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -165,7 +183,7 @@ func (ens *ENS) Resolve(name string) (common.Hash, error) {
 	}
 
 	if !supported {
-		resolver, err := ens.getFallbackResolver(node)
+		resolver, err := ens.getFallbackResolver(opts, node)
 		if err != nil {
 			return common.Hash{}, err
 		}
@@ -190,7 +208,7 @@ func (ens *ENS) Resolve(name string) (common.Hash, error) {
 func (ens *ENS) Addr(name string) (common.Address, error) {
 	node := EnsNode(name)
 
-	resolver, err := ens.getResolver(node)
+	resolver, err := ens.getResolver(ens.CallOpts, node)
 	if err != nil {
 		return common.Address{}, err
 	}
@@ -206,7 +224,7 @@ func (ens *ENS) Addr(name string) (common.Address, error) {
 func (ens *ENS) SetAddr(name string, addr common.Address) (*types.Transaction, error) {
 	node := EnsNode(name)
 
-	resolver, err := ens.getResolver(node)
+	resolver, err := ens.getResolver(ens.CallOpts, node)
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +249,7 @@ func (ens *ENS) Register(name string) (*types.Transaction, error) {
 func (ens *ENS) SetContentHash(name string, hash []byte) (*types.Transaction, error) {
 	node := EnsNode(name)
 
-	resolver, err := ens.getResolver(node)
+	resolver, err := ens.getResolver(ens.CallOpts, node)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +264,7 @@ func (ens *ENS) SetContentHash(name string, hash []byte) (*types.Transaction, er
 	}
 
 	if !supported {
-		resolver, err := ens.getFallbackResolver(node)
+		resolver, err := ens.getFallbackResolver(ens.CallOpts, node)
 		if err != nil {
 			return nil, err
 		}
@@ -17,13 +17,21 @@
 package ens
 
 import (
+	"context"
+	"fmt"
+	gomath "math"
 	"math/big"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethersphere/swarm/contracts/ens/contract"
 	"github.com/ethersphere/swarm/contracts/ens/fallback_contract"
@@ -38,8 +46,35 @@ var (
 	testAddr     = common.HexToAddress("0x1234123412341234123412341234123412341234")
 )
 
+// historyBackend is a simulated backend that serves calls at past blocks from the
+// states of the simulated chain, as the simulated backend only serves the latest block
+type historyBackend struct {
+	*backends.SimulatedBackend
+}
+
+func (b *historyBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	chain := b.Blockchain()
+	if blockNumber == nil || blockNumber.Cmp(chain.CurrentBlock().Number()) == 0 {
+		return b.SimulatedBackend.CallContract(ctx, call, blockNumber)
+	}
+	block := chain.GetBlockByNumber(blockNumber.Uint64())
+	if block == nil {
+		return nil, fmt.Errorf("unknown block %v", blockNumber)
+	}
+	statedb, err := chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	// the caller pays for the gas of the call as in the simulated backend
+	statedb.SetBalance(call.From, math.MaxBig256)
+	msg := types.NewMessage(call.From, call.To, 0, new(big.Int), 50000000, big.NewInt(1), call.Data, false)
+	evm := vm.NewEVM(core.NewEVMContext(msg, block.Header(), chain, nil), statedb, chain.Config(), vm.Config{})
+	ret, _, _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(gomath.MaxUint64))
+	return ret, err
+}
+
 func TestENS(t *testing.T) {
-	contractBackend := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}, 10000000)
+	contractBackend := &historyBackend{backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}, 10000000)}
 	transactOpts := bind.NewKeyedTransactor(key)
 
 	ensAddr, ens, err := DeployENS(transactOpts, contractBackend)
@@ -54,6 +89,8 @@ func TestENS(t *testing.T) {
 	}
 	contractBackend.Commit()
 
+	unresolved := contractBackend.Blockchain().CurrentBlock().Number()
+
 	// Deploy a resolver and make it responsible for the name.
 	resolverAddr, _, _, err := contract.DeployPublicResolver(transactOpts, contractBackend, ensAddr)
 	if err != nil {
@@ -75,6 +112,8 @@ func TestENS(t *testing.T) {
 	}
 	contractBackend.Commit()
 
+	set := contractBackend.Blockchain().CurrentBlock().Number()
+
 	// Try to resolve the name.
 	resolvedHash, err := ens.Resolve(name)
 	if err != nil {
@@ -84,6 +123,41 @@ func TestENS(t *testing.T) {
 		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), resolvedHash.Hex())
 	}
 
+	// Change the content hash for the name.
+	newHash := crypto.Keccak256Hash([]byte("my new content"))
+	cid, err = EncodeSwarmHash(newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ens.SetContentHash(name, cid); err != nil {
+		t.Fatalf("can't set content hash: %v", err)
+	}
+	contractBackend.Commit()
+	latest := contractBackend.Blockchain().CurrentBlock().Number()
+
+	// Try to resolve the name at the block the content hash was set,
+	// and at the latest block, given or not
+	for _, tc := range []struct {
+		block *big.Int
+		hash  common.Hash
+	}{
+		{set, hash},
+		{latest, newHash},
+		{nil, newHash},
+	} {
+		resolvedHash, err = ens.ResolveAt(context.Background(), name, tc.block)
+		if err != nil {
+			t.Fatalf("expected no error at block %v, got %v", tc.block, err)
+		}
+		if resolvedHash.Hex() != tc.hash.Hex() {
+			t.Fatalf("resolve at block %v error, expected %v, got %v", tc.block, tc.hash.Hex(), resolvedHash.Hex())
+		}
+	}
+	// the name has no resolver before it was set
+	if resolvedHash, err = ens.ResolveAt(context.Background(), name, unresolved); err == nil {
+		t.Fatalf("expected error resolving before the resolver was set, got %v", resolvedHash.Hex())
+	}
+
 	// set the address for the name
 	if _, err = ens.SetAddr(name, testAddr); err != nil {
 		t.Fatalf("can't set address: %v", err)
@@ -124,3 +198,76 @@ func TestENS(t *testing.T) {
 		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), resolvedHash.Hex())
 	}
 }
+
+func TestPublishContent(t *testing.T) {
+	contractBackend := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}, 10000000)
+	transactOpts := bind.NewKeyedTransactor(key)
+
+	ensAddr, ens, err := DeployENS(transactOpts, contractBackend)
+	if err != nil {
+		t.Fatalf("can't deploy root registry: %v", err)
+	}
+	resolverAddr, _, _, err := contract.DeployPublicResolver(transactOpts, contractBackend, ensAddr)
+	if err != nil {
+		t.Fatalf("can't deploy resolver: %v", err)
+	}
+	contractBackend.Commit()
+
+	// mine the published transactions
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				contractBackend.Commit()
+			case <-quit:
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := ens.PublishContent(ctx, name, hash, common.Address{}); err != ErrNoResolver {
+		t.Fatalf("got error %v, want %v", err, ErrNoResolver)
+	}
+
+	// the name is registered by the previous call
+	if err := ens.PublishContent(ctx, name, hash, resolverAddr); err != nil {
+		t.Fatalf("can't publish content: %v", err)
+	}
+	resolvedHash, err := ens.Resolve(name)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resolvedHash != hash {
+		t.Fatalf("resolve error, expected %v, got %v", hash.Hex(), resolvedHash.Hex())
+	}
+
+	// a subnode of an owned name is created
+	subname := "www." + name
+	if err := ens.PublishContent(ctx, subname, fallbackHash, resolverAddr); err != nil {
+		t.Fatalf("can't publish content under subnode: %v", err)
+	}
+	resolvedHash, err = ens.Resolve(subname)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resolvedHash != fallbackHash {
+		t.Fatalf("resolve error, expected %v, got %v", fallbackHash.Hex(), resolvedHash.Hex())
+	}
+
+	// another account can not publish under the name
+	otherKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewENS(bind.NewKeyedTransactor(otherKey), ensAddr, contractBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.PublishContent(ctx, name, fallbackHash, resolverAddr); err != ErrNameOwned {
+		t.Fatalf("got error %v, want %v", err, ErrNameOwned)
+	}
+}
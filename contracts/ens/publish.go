@@ -0,0 +1,121 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrNameOwned is returned when publishing content under a name owned by another account
+	ErrNameOwned = errors.New("name is owned by another account")
+	// ErrNoResolver is returned when publishing content under a name without a resolver
+	// and no resolver to set for it is given
+	ErrNoResolver = errors.New("name has no resolver")
+	// ErrNoReceipts is returned when waiting for a transaction with a backend
+	// that does not provide transaction receipts
+	ErrNoReceipts = errors.New("contract backend does not provide transaction receipts")
+)
+
+// PublishContent points the name at the swarm content hash and waits for each of the
+// transactions to be mined, so that the name resolves to the hash once it returns.
+// If the name is not owned yet, it is created for the caller, as a subnode if the caller
+// owns the parent name, or else with the FIFS registrar of the parent name. If the name
+// has no resolver, resolverAddr is set as its resolver.
+func (ens *ENS) PublishContent(ctx context.Context, name string, hash common.Hash, resolverAddr common.Address) error {
+	node := EnsNode(name)
+	from := ens.TransactOpts.From
+
+	owner, err := ens.Owner(node)
+	if err != nil {
+		return err
+	}
+	if owner != from {
+		if owner != (common.Address{}) {
+			return ErrNameOwned
+		}
+		parentNode, label := ensParentNode(name)
+		parentOwner, err := ens.Owner(parentNode)
+		if err != nil {
+			return err
+		}
+		var tx *types.Transaction
+		if parentOwner == from {
+			tx, err = ens.SetSubnodeOwner(parentNode, label, from)
+		} else {
+			tx, err = ens.Register(name)
+		}
+		if err != nil {
+			return fmt.Errorf("create name %s: %v", name, err)
+		}
+		if err := ens.waitMined(ctx, tx); err != nil {
+			return fmt.Errorf("create name %s: %v", name, err)
+		}
+	}
+
+	resolver, err := ens.Resolver(node)
+	if err != nil {
+		return err
+	}
+	if resolver == (common.Address{}) {
+		if resolverAddr == (common.Address{}) {
+			return ErrNoResolver
+		}
+		tx, err := ens.SetResolver(node, resolverAddr)
+		if err != nil {
+			return fmt.Errorf("set resolver of %s: %v", name, err)
+		}
+		if err := ens.waitMined(ctx, tx); err != nil {
+			return fmt.Errorf("set resolver of %s: %v", name, err)
+		}
+	}
+
+	cid, err := EncodeSwarmHash(hash)
+	if err != nil {
+		return err
+	}
+	tx, err := ens.SetContentHash(name, cid)
+	if err != nil {
+		return fmt.Errorf("set content hash of %s: %v", name, err)
+	}
+	if err := ens.waitMined(ctx, tx); err != nil {
+		return fmt.Errorf("set content hash of %s: %v", name, err)
+	}
+	return nil
+}
+
+// waitMined waits for the transaction to be mined and checks that it succeeded
+func (ens *ENS) waitMined(ctx context.Context, tx *types.Transaction) error {
+	backend, ok := ens.contractBackend.(bind.DeployBackend)
+	if !ok {
+		return ErrNoReceipts
+	}
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s failed", tx.Hash().Hex())
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	chequebookFactory "github.com/ethersphere/go-sw3/contracts-v0-2-0/simpleswapfactory"
 	"github.com/ethersphere/swarm/swap/chain"
 )
@@ -34,6 +35,10 @@ type simpleSwapFactory struct {
 type SimpleSwapFactory interface {
 	// DeploySimpleSwap deploys a new SimpleSwap contract from the factory and returns the ready to use Contract abstraction
 	DeploySimpleSwap(auth *bind.TransactOpts, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int) (Contract, error)
+	// SendDeploySimpleSwap sends the transaction deploying a new SimpleSwap contract from the factory without waiting for it to be mined
+	SendDeploySimpleSwap(auth *bind.TransactOpts, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int) (*types.Transaction, error)
+	// DeployedSimpleSwap returns the SimpleSwap contract deployed by the transaction with the given receipt
+	DeployedSimpleSwap(receipt *types.Receipt) (Contract, error)
 	// VerifyContract verifies that the supplied address was deployed by this factory
 	VerifyContract(address common.Address) error
 	// VerifySelf verifies that this is a valid factory on the network
@@ -74,12 +79,7 @@ func (sf simpleSwapFactory) VerifySelf() error {
 
 // DeploySimpleSwap deploys a new SimpleSwap contract from the factory and returns the ready to use Contract abstraction
 func (sf simpleSwapFactory) DeploySimpleSwap(auth *bind.TransactOpts, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int) (Contract, error) {
-	// for some reason the automatic gas estimation is too low
-	// this value was determined by experimentation and is higher than what works in truffle
-	// this might be due to the simulated backend running on a different evm version
-	// the deployment cost should always be constant
-	auth.GasLimit = 2000000
-	tx, err := sf.instance.DeploySimpleSwap(auth, issuer, defaultHardDepositTimeoutDuration)
+	tx, err := sf.SendDeploySimpleSwap(auth, issuer, defaultHardDepositTimeoutDuration)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +89,21 @@ func (sf simpleSwapFactory) DeploySimpleSwap(auth *bind.TransactOpts, issuer com
 		return nil, err
 	}
 
+	return sf.DeployedSimpleSwap(receipt)
+}
+
+// SendDeploySimpleSwap sends the transaction deploying a new SimpleSwap contract from the factory without waiting for it to be mined
+func (sf simpleSwapFactory) SendDeploySimpleSwap(auth *bind.TransactOpts, issuer common.Address, defaultHardDepositTimeoutDuration *big.Int) (*types.Transaction, error) {
+	// for some reason the automatic gas estimation is too low
+	// this value was determined by experimentation and is higher than what works in truffle
+	// this might be due to the simulated backend running on a different evm version
+	// the deployment cost should always be constant
+	auth.GasLimit = 2000000
+	return sf.instance.DeploySimpleSwap(auth, issuer, defaultHardDepositTimeoutDuration)
+}
+
+// DeployedSimpleSwap returns the SimpleSwap contract deployed by the transaction with the given receipt
+func (sf simpleSwapFactory) DeployedSimpleSwap(receipt *types.Receipt) (Contract, error) {
 	// we iterate through the logs until we find the SimpleSwapDeployed event which contains the address of the new SimpleSwap contract
 	address := common.Address{}
 	for _, log := range receipt.Logs {
@@ -104,12 +119,7 @@ func (sf simpleSwapFactory) DeploySimpleSwap(auth *bind.TransactOpts, issuer com
 		return nil, errors.New("contract deployment failed")
 	}
 
-	simpleSwap, err := InstanceAt(address, sf.backend)
-	if err != nil {
-		return nil, err
-	}
-
-	return simpleSwap, nil
+	return InstanceAt(address, sf.backend)
 }
 
 // VerifyContract verifies that the supplied address was deployed by this factory
@@ -0,0 +1,148 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/log"
+)
+
+// checkpointPath returns the path of the checkpoint file of a mount point
+func checkpointPath(dir, mountPoint string) string {
+	return filepath.Join(dir, hex.EncodeToString(crypto.Keccak256([]byte(mountPoint))[:8])+".json")
+}
+
+// ReadCheckpoint reads the last checkpoint saved for the mount point, it can be
+// used to find the latest manifest of a mount that was not unmounted cleanly
+func ReadCheckpoint(dir, mountPoint string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(checkpointPath(dir, mountPoint))
+	if err != nil {
+		return nil, err
+	}
+	c := new(Checkpoint)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// startCheckpointing launches the goroutine saving the checkpoints of the mount
+// it does nothing if checkpointing is not enabled
+func (mi *MountInfo) startCheckpointing() {
+	if mi.checkpoints == nil || (mi.checkpoints.Interval == 0 && mi.checkpoints.DirtyBytes == 0) {
+		return
+	}
+	mi.checkpointQuit = make(chan struct{})
+	mi.checkpointDone = make(chan struct{})
+	go func() {
+		defer close(mi.checkpointDone)
+		var tickC <-chan time.Time
+		if mi.checkpoints.Interval > 0 {
+			ticker := time.NewTicker(mi.checkpoints.Interval)
+			defer ticker.Stop()
+			tickC = ticker.C
+		}
+		for {
+			select {
+			case <-tickC:
+			case <-mi.checkpointC:
+			case <-mi.checkpointQuit:
+				return
+			}
+			if err := mi.checkpoint(); err != nil {
+				log.Error("swarmfs checkpoint failed", "mountpoint", mi.MountPoint, "err", err)
+			}
+		}
+	}()
+}
+
+// stopCheckpointing terminates the checkpointing goroutine and saves the final
+// state of the mount
+func (mi *MountInfo) stopCheckpointing() {
+	if mi.checkpointQuit == nil {
+		return
+	}
+	close(mi.checkpointQuit)
+	<-mi.checkpointDone
+	if err := mi.checkpoint(); err != nil {
+		log.Error("swarmfs checkpoint failed", "mountpoint", mi.MountPoint, "err", err)
+	}
+}
+
+// written accounts for bytes written to the mount and requests a checkpoint
+// once the configured amount of dirty bytes is reached
+// the caller is expected to hold the lock
+func (mi *MountInfo) written(n int) {
+	mi.dirtyBytes += int64(n)
+	if mi.checkpoints == nil || mi.checkpoints.DirtyBytes == 0 || mi.dirtyBytes < mi.checkpoints.DirtyBytes {
+		return
+	}
+	select {
+	case mi.checkpointC <- struct{}{}:
+	default:
+	}
+}
+
+// checkpoint saves the latest manifest of the mount if it changed since
+// the last checkpoint
+func (mi *MountInfo) checkpoint() error {
+	mi.lock.RLock()
+	c := &Checkpoint{
+		MountPoint:     mi.MountPoint,
+		StartManifest:  mi.StartManifest,
+		LatestManifest: mi.LatestManifest,
+		Time:           time.Now(),
+	}
+	changed := mi.LatestManifest != mi.CheckpointManifest
+	mi.lock.RUnlock()
+	if !changed {
+		return nil
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(mi.checkpoints.Dir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so that a crash can not leave a partial checkpoint
+	path := checkpointPath(mi.checkpoints.Dir, mi.MountPoint)
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	mi.lock.Lock()
+	mi.CheckpointManifest = c.LatestManifest
+	mi.CheckpointTime = c.Time
+	mi.dirtyBytes = 0
+	mi.lock.Unlock()
+	log.Debug("swarmfs checkpoint", "mountpoint", mi.MountPoint, "manifest", c.LatestManifest)
+	return nil
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
@@ -36,6 +37,7 @@ var (
 	_ fs.NodeCreater         = (*SwarmDir)(nil)
 	_ fs.NodeRemover         = (*SwarmDir)(nil)
 	_ fs.NodeMkdirer         = (*SwarmDir)(nil)
+	_ fs.NodeRenamer         = (*SwarmDir)(nil)
 )
 
 type SwarmDir struct {
@@ -67,7 +69,7 @@ func (sd *SwarmDir) Attr(ctx context.Context, a *fuse.Attr) error {
 	sd.lock.RLock()
 	defer sd.lock.RUnlock()
 	a.Inode = sd.inode
-	a.Mode = os.ModeDir | 0700
+	a.Mode = os.ModeDir | sd.mountInfo.entryMode(0)
 	a.Uid = uint32(os.Getuid())
 	a.Gid = uint32(os.Getegid())
 	return nil
@@ -105,11 +107,16 @@ func (sd *SwarmDir) Create(ctx context.Context, req *fuse.CreateRequest, resp *f
 
 	newFile := NewSwarmFile(sd.path, req.Name, sd.mountInfo)
 	newFile.fileSize = 0 // 0 means, file is not in swarm yet and it is just created
+	// the permissions of the new file are written with its content, or saved in its manifest entry on unmount
+	newFile.mode = req.Mode.Perm() &^ req.Umask
+	newFile.chmodded = true
 
 	sd.lock.Lock()
 	defer sd.lock.Unlock()
 	sd.files = append(sd.files, newFile)
 
+	// the created file is open, its handle is released like the opened ones
+	atomic.AddInt64(&sd.mountInfo.counters.openHandles, 1)
 	return newFile, newFile, nil
 }
 
@@ -159,3 +166,14 @@ func (sd *SwarmDir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fs.Node,
 
 	return newDir, nil
 }
+
+// Rename moves a file or directory to the new directory, moving the manifest
+// entries of the files without copying their content
+func (sd *SwarmDir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fs.Node) error {
+	log.Debug("swarmfs Rename", "path", sd.path, "req.OldName", req.OldName, "req.NewName", req.NewName)
+	nd, ok := newDir.(*SwarmDir)
+	if !ok {
+		return fuse.EIO
+	}
+	return renameInSwarm(sd, req.OldName, nd, req.NewName)
+}
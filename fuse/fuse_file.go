@@ -23,6 +23,8 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"bazil.org/fuse"
 	"bazil.org/fuse/fs"
@@ -41,9 +43,12 @@ var (
 )
 
 var (
-	_ fs.Node         = (*SwarmFile)(nil)
-	_ fs.HandleReader = (*SwarmFile)(nil)
-	_ fs.HandleWriter = (*SwarmFile)(nil)
+	_ fs.Node           = (*SwarmFile)(nil)
+	_ fs.HandleReader   = (*SwarmFile)(nil)
+	_ fs.HandleWriter   = (*SwarmFile)(nil)
+	_ fs.NodeSetattrer  = (*SwarmFile)(nil)
+	_ fs.NodeOpener     = (*SwarmFile)(nil)
+	_ fs.HandleReleaser = (*SwarmFile)(nil)
 )
 
 type SwarmFile struct {
@@ -52,10 +57,17 @@ type SwarmFile struct {
 	path     string
 	addr     storage.Address
 	fileSize int64
+	modTime  time.Time   // modification time of the manifest entry
+	accTime  time.Time   // access time set on the mount, not persisted
+	touched  bool        // whether modTime was set on the mount and needs to be saved in the manifest
+	mode     os.FileMode // permission bits of the manifest entry
+	chmodded bool        // whether mode was set on the mount and needs to be saved in the manifest
 	reader   storage.LazySectionReader
+	handles  int64 // number of open handles of the file, accessed atomically
 
-	mountInfo *MountInfo
-	lock      *sync.RWMutex
+	mountInfo  *MountInfo
+	lock       *sync.RWMutex
+	readerLock sync.Mutex // guards reader, shared by reads until the content changes
 }
 
 func NewSwarmFile(path, fname string, minfo *MountInfo) *SwarmFile {
@@ -70,6 +82,9 @@ func NewSwarmFile(path, fname string, minfo *MountInfo) *SwarmFile {
 		mountInfo: minfo,
 		lock:      &sync.RWMutex{},
 	}
+	if minfo != nil {
+		newFile.mode = minfo.entryMode(0)
+	}
 	return newFile
 }
 
@@ -78,13 +93,12 @@ func (sf *SwarmFile) Attr(ctx context.Context, a *fuse.Attr) error {
 	sf.lock.Lock()
 	defer sf.lock.Unlock()
 	a.Inode = sf.inode
-	//TODO: need to get permission as argument
-	a.Mode = 0700
+	a.Mode = sf.mode
 	a.Uid = uint32(os.Getuid())
 	a.Gid = uint32(os.Getegid())
 
 	if sf.fileSize == -1 {
-		reader, _ := sf.mountInfo.swarmApi.Retrieve(ctx, sf.addr)
+		reader, _ := sf.mountInfo.swarmApi.Retrieve(sf.mountInfo.readContext(ctx), sf.addr)
 		quitC := make(chan bool)
 		size, err := reader.Size(ctx, quitC)
 		if err != nil {
@@ -96,6 +110,42 @@ func (sf *SwarmFile) Attr(ctx context.Context, a *fuse.Attr) error {
 		close(quitC)
 	}
 	a.Size = uint64(sf.fileSize)
+	a.Mtime = sf.modTime
+	a.Atime = sf.modTime
+	if sf.accTime.After(sf.modTime) {
+		a.Atime = sf.accTime
+	}
+	return nil
+}
+
+// Setattr sets the access and modification times and the permissions of the file,
+// e.g. with touch or chmod
+// The modification time and the permissions are saved in the manifest entry of the file
+// when the mount is unmounted
+// Other attributes cannot be changed on the mount and are left as they are
+func (sf *SwarmFile) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	log.Debug("swarmfs Setattr", "path", sf.path, "req.String", req.String())
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+	if req.Valid.Mtime() {
+		if req.Valid.MtimeNow() {
+			sf.modTime = time.Now()
+		} else {
+			sf.modTime = req.Mtime
+		}
+		sf.touched = true
+	}
+	if req.Valid.Atime() {
+		if req.Valid.AtimeNow() {
+			sf.accTime = time.Now()
+		} else {
+			sf.accTime = req.Atime
+		}
+	}
+	if req.Valid.Mode() {
+		sf.mode = req.Mode.Perm()
+		sf.chmodded = true
+	}
 	return nil
 }
 
@@ -103,17 +153,40 @@ func (sf *SwarmFile) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse
 	log.Debug("swarmfs Read", "path", sf.path, "req.String", req.String())
 	sf.lock.RLock()
 	defer sf.lock.RUnlock()
+
+	// serve sequential reads from the content read ahead
+	if data, ok := sf.mountInfo.readCache.get(sf, req.Offset, req.Size); ok {
+		resp.Data = data
+		atomic.AddUint64(&sf.mountInfo.counters.reads, 1)
+		atomic.AddUint64(&sf.mountInfo.counters.bytesRead, uint64(len(data)))
+		return nil
+	}
+
+	sf.readerLock.Lock()
 	if sf.reader == nil {
-		sf.reader, _ = sf.mountInfo.swarmApi.Retrieve(ctx, sf.addr)
+		// the reader outlives the request, so it is not bound to its context
+		sf.reader, _ = sf.mountInfo.swarmApi.Retrieve(sf.mountInfo.readContext(context.Background()), sf.addr)
 	}
-	buf := make([]byte, req.Size)
-	n, err := sf.reader.ReadAt(buf, req.Offset)
+	reader := sf.reader
+	sf.readerLock.Unlock()
+
+	// the content is read directly from the chunks of the file, and
+	// up to the readahead of the mount is kept for the following reads
+	buf := make([]byte, sf.mountInfo.readCache.readSize(req.Size))
+	n, err := reader.ReadAt(buf, req.Offset)
+	var eof bool
 	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		eof = true
 		err = nil
 	}
+	if err == nil && n > req.Size {
+		sf.mountInfo.readCache.put(sf, req.Offset, buf[:n], eof)
+		n = req.Size
+	}
 	resp.Data = buf[:n]
-	sf.reader = nil
 
+	atomic.AddUint64(&sf.mountInfo.counters.reads, 1)
+	atomic.AddUint64(&sf.mountInfo.counters.bytesRead, uint64(n))
 	return err
 }
 
@@ -142,5 +215,27 @@ func (sf *SwarmFile) Write(ctx context.Context, req *fuse.WriteRequest, resp *fu
 		log.Warn("swarmfs Invalid write request size(%v) : off(%v)", sf.fileSize, req.Offset)
 		return errInvalidOffset
 	}
+	atomic.AddUint64(&sf.mountInfo.counters.writes, 1)
+	atomic.AddUint64(&sf.mountInfo.counters.bytesWritten, uint64(resp.Size))
+	return nil
+}
+
+// Open counts the open handles of the file and of the mount, the file itself is used as the handle
+func (sf *SwarmFile) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fs.Handle, error) {
+	log.Debug("swarmfs Open", "path", sf.path, "req.String", req.String())
+	atomic.AddInt64(&sf.handles, 1)
+	atomic.AddInt64(&sf.mountInfo.counters.openHandles, 1)
+	return sf, nil
+}
+
+// Release counts the handle of the file as closed, and drops the content
+// read ahead for the file once its last handle is closed, as the handles
+// of a file share its content
+func (sf *SwarmFile) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	log.Debug("swarmfs Release", "path", sf.path, "req.String", req.String())
+	atomic.AddInt64(&sf.mountInfo.counters.openHandles, -1)
+	if atomic.AddInt64(&sf.handles, -1) <= 0 {
+		sf.mountInfo.readCache.drop(sf)
+	}
 	return nil
 }
@@ -0,0 +1,134 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"container/list"
+	"sync"
+)
+
+// readCache keeps the content read ahead of the read requests on the files
+// of a mount, one buffer for every file, within the size limit of the mount.
+// The least recently used buffers are dropped when the limit is reached.
+type readCache struct {
+	readahead int
+	limit     int64
+	size      int64
+	buffers   *list.List                   // of *readBuffer, the most recently used first
+	files     map[*SwarmFile]*list.Element // buffers by their files
+	mu        sync.Mutex
+}
+
+// readBuffer is the content of a file starting at the offset
+type readBuffer struct {
+	file   *SwarmFile
+	offset int64
+	data   []byte
+	eof    bool // whether the content ends with the end of the file
+}
+
+func newReadCache(params *ReadCacheParams) *readCache {
+	c := &readCache{
+		buffers: list.New(),
+		files:   make(map[*SwarmFile]*list.Element),
+	}
+	if params != nil && params.Readahead > 0 && params.Size > 0 {
+		c.readahead = params.Readahead
+		c.limit = params.Size
+	}
+	return c
+}
+
+// readSize returns the number of bytes to read from
+// the file for a read request of the size
+func (c *readCache) readSize(size int) int {
+	if c.readahead > size {
+		return c.readahead
+	}
+	return size
+}
+
+// get returns the content of the file at the offset, with up to size bytes,
+// if it was read ahead. Less than size bytes are returned only at the end
+// of the file.
+func (c *readCache) get(sf *SwarmFile, offset int64, size int) (data []byte, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.files[sf]
+	if !ok {
+		return nil, false
+	}
+	b := e.Value.(*readBuffer)
+	if offset < b.offset || offset > b.offset+int64(len(b.data)) {
+		return nil, false
+	}
+	data = b.data[offset-b.offset:]
+	if len(data) < size && !b.eof {
+		return nil, false
+	}
+	if len(data) > size {
+		data = data[:size]
+	}
+	c.buffers.MoveToFront(e)
+	return data, true
+}
+
+// put keeps the content of the file read from the offset, replacing the
+// content kept for the file before. The data must not be changed after it
+// is put.
+func (c *readCache) put(sf *SwarmFile, offset int64, data []byte, eof bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(sf)
+	if int64(len(data)) > c.limit {
+		return
+	}
+	c.files[sf] = c.buffers.PushFront(&readBuffer{
+		file:   sf,
+		offset: offset,
+		data:   data,
+		eof:    eof,
+	})
+	c.size += int64(len(data))
+	for c.size > c.limit {
+		c.remove(c.buffers.Back().Value.(*readBuffer).file)
+	}
+}
+
+// drop removes the content kept for the file,
+// it must be called when the content changes
+func (c *readCache) drop(sf *SwarmFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(sf)
+}
+
+// remove removes the content kept for the file, it must be called with the lock held
+func (c *readCache) remove(sf *SwarmFile) {
+	e, ok := c.files[sf]
+	if !ok {
+		return
+	}
+	c.buffers.Remove(e)
+	delete(c.files, sf)
+	c.size -= int64(len(e.Value.(*readBuffer).data))
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux darwin freebsd
+
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"bazil.org/fuse"
+)
+
+// TestReadCache tests that the content read ahead is served for the reads
+// within it, and that the buffers are dropped beyond the size limit
+func TestReadCache(t *testing.T) {
+	c := newReadCache(&ReadCacheParams{Readahead: 8, Size: 16})
+	if s := c.readSize(4); s != 8 {
+		t.Fatalf("got read size %v, want 8", s)
+	}
+	if s := c.readSize(10); s != 10 {
+		t.Fatalf("got read size %v, want 10", s)
+	}
+
+	a, b, d := &SwarmFile{}, &SwarmFile{}, &SwarmFile{}
+	c.put(a, 4, []byte("01234567"), false)
+
+	for _, tc := range []struct {
+		offset int64
+		size   int
+		want   []byte
+	}{
+		{offset: 4, size: 4, want: []byte("0123")},
+		{offset: 8, size: 4, want: []byte("4567")},
+		{offset: 6, size: 2, want: []byte("23")},
+		{offset: 10, size: 4, want: nil},
+		{offset: 2, size: 4, want: nil},
+		{offset: 12, size: 4, want: nil},
+	} {
+		data, ok := c.get(a, tc.offset, tc.size)
+		if ok != (tc.want != nil) {
+			t.Fatalf("got %v at offset %v, want %v", ok, tc.offset, tc.want != nil)
+		}
+		if !bytes.Equal(data, tc.want) {
+			t.Fatalf("got %q at offset %v, want %q", data, tc.offset, tc.want)
+		}
+	}
+
+	// the content ending with the file is served for the reads beyond it
+	c.put(b, 0, []byte("abc"), true)
+	data, ok := c.get(b, 1, 4)
+	if !ok || string(data) != "bc" {
+		t.Fatalf("got %q %v, want %q", data, ok, "bc")
+	}
+	data, ok = c.get(b, 3, 4)
+	if !ok || len(data) != 0 {
+		t.Fatalf("got %q %v at the end of the file", data, ok)
+	}
+
+	// a is used more recently than b, so b is dropped
+	if _, ok := c.get(a, 4, 1); !ok {
+		t.Fatal("content of a not found")
+	}
+	c.put(d, 0, []byte("ABCDEFGH"), false)
+	if c.size != 16 {
+		t.Fatalf("got size %v, want 16", c.size)
+	}
+	if _, ok := c.get(b, 0, 1); ok {
+		t.Fatal("content of b not dropped")
+	}
+	if _, ok := c.get(a, 4, 1); !ok {
+		t.Fatal("content of a dropped")
+	}
+
+	// content larger than the limit is not kept
+	c.put(d, 0, make([]byte, 17), false)
+	if _, ok := c.get(d, 0, 1); ok {
+		t.Fatal("content larger than the limit kept")
+	}
+
+	c.drop(a)
+	if _, ok := c.get(a, 4, 1); ok {
+		t.Fatal("content of a not dropped")
+	}
+	if c.size != 0 || c.buffers.Len() != 0 || len(c.files) != 0 {
+		t.Fatalf("got size %v with %v buffers, want empty", c.size, c.buffers.Len())
+	}
+
+	// reading ahead is disabled without a size limit
+	c = newReadCache(&ReadCacheParams{Readahead: 8})
+	if s := c.readSize(4); s != 4 {
+		t.Fatalf("got read size %v, want 4", s)
+	}
+}
+
+// TestReadCacheRelease tests that the content read ahead for a file
+// is kept until the last handle of the file is released
+func TestReadCacheRelease(t *testing.T) {
+	mi := &MountInfo{readCache: newReadCache(&ReadCacheParams{Readahead: 8, Size: 16})}
+	sf := NewSwarmFile("", "a", mi)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := sf.Open(ctx, &fuse.OpenRequest{}, &fuse.OpenResponse{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mi.readCache.put(sf, 0, []byte("01234567"), true)
+
+	if err := sf.Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mi.readCache.get(sf, 0, 4); !ok {
+		t.Fatal("content dropped with a handle open")
+	}
+	if err := sf.Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mi.readCache.get(sf, 0, 4); ok {
+		t.Fatal("content not dropped with the last handle")
+	}
+}
@@ -17,6 +17,7 @@
 package fuse
 
 import (
+	"os"
 	"sync"
 	"time"
 
@@ -28,6 +29,14 @@ const (
 	mountTimeout   = time.Second * 5
 	unmountTimeout = time.Second * 10
 	maxFUSEMounts  = 5
+
+	// TrashDir is the directory of the manifest removed files are moved to
+	// on mounts with trash enabled
+	TrashDir = ".trash"
+
+	// DefaultUmask is masked from the permissions of the entries without mode in the
+	// manifest and of the directories, which gives rwx------ as with earlier versions
+	DefaultUmask os.FileMode = 0077
 )
 
 var (
@@ -42,6 +51,60 @@ type SwarmFS struct {
 	swarmApi     *api.API
 	activeMounts map[string]*MountInfo
 	swarmFsLock  *sync.RWMutex
+	checkpoints  *CheckpointParams
+	trash        bool
+	readCache    *ReadCacheParams
+	umask        os.FileMode
+}
+
+// CheckpointParams configures the periodic saving of the latest manifests of
+// the mounts, so that the edits are not lost if the node crashes before unmount
+type CheckpointParams struct {
+	Dir        string        // directory the checkpoints are saved in
+	Interval   time.Duration // save the changed manifests at this interval, disabled if zero
+	DirtyBytes int64         // save the manifest of a mount once this many bytes were written, disabled if zero
+}
+
+// ReadCacheParams configures reading ahead of the read requests on the files of
+// the mounts, so that sequential reads are served with fewer retrievals
+type ReadCacheParams struct {
+	Readahead int   // bytes read from a file for a smaller read request, disabled if zero
+	Size      int64 // limit of the bytes read ahead and kept for all files of a mount
+}
+
+// Default read cache parameters, reading ahead eight read requests
+// of 128KiB, and keeping the content read ahead for up to 32 files
+const (
+	DefaultReadahead     = 1024 * 1024
+	DefaultReadCacheSize = 32 * DefaultReadahead
+)
+
+// NewReadCacheParams returns the default read cache parameters
+func NewReadCacheParams() *ReadCacheParams {
+	return &ReadCacheParams{
+		Readahead: DefaultReadahead,
+		Size:      DefaultReadCacheSize,
+	}
+}
+
+// Checkpoint is the saved state of a mount
+type Checkpoint struct {
+	MountPoint     string    `json:"mountPoint"`
+	StartManifest  string    `json:"startManifest"`
+	LatestManifest string    `json:"latestManifest"`
+	Time           time.Time `json:"time"`
+}
+
+// MountStats are the counters of the file operations on a mount since it was mounted
+type MountStats struct {
+	Reads         uint64  `json:"reads"`         // number of read requests
+	BytesRead     uint64  `json:"bytesRead"`     // bytes returned by the read requests
+	Writes        uint64  `json:"writes"`        // number of write requests
+	BytesWritten  uint64  `json:"bytesWritten"`  // bytes written by the write requests
+	ChunkHits     uint64  `json:"chunkHits"`     // chunks read that were found in the local store
+	ChunkFetches  uint64  `json:"chunkFetches"`  // chunks read that needed to be fetched from peers
+	CacheHitRatio float64 `json:"cacheHitRatio"` // ratio of the chunk hits to all chunks read
+	OpenHandles   int64   `json:"openHandles"`   // number of files currently open
 }
 
 func NewSwarmFS(api *api.API) *SwarmFS {
@@ -50,12 +113,45 @@ func NewSwarmFS(api *api.API) *SwarmFS {
 			swarmApi:     api,
 			swarmFsLock:  &sync.RWMutex{},
 			activeMounts: map[string]*MountInfo{},
+			readCache:    NewReadCacheParams(),
+			umask:        DefaultUmask,
 		}
 	})
 	return swarmfs
 
 }
 
+// SetCheckpointParams enables checkpointing of the mounts created afterwards
+func (swarmfs *SwarmFS) SetCheckpointParams(params *CheckpointParams) {
+	swarmfs.swarmFsLock.Lock()
+	defer swarmfs.swarmFsLock.Unlock()
+	swarmfs.checkpoints = params
+}
+
+// SetTrash enables moving the files removed on the mounts created afterwards
+// under TrashDir in the manifest, so that they can be recovered until the trash is purged
+func (swarmfs *SwarmFS) SetTrash(enabled bool) {
+	swarmfs.swarmFsLock.Lock()
+	defer swarmfs.swarmFsLock.Unlock()
+	swarmfs.trash = enabled
+}
+
+// SetReadCacheParams sets the read ahead and the limit of the read content
+// kept in memory for every mount created afterwards
+func (swarmfs *SwarmFS) SetReadCacheParams(params *ReadCacheParams) {
+	swarmfs.swarmFsLock.Lock()
+	defer swarmfs.swarmFsLock.Unlock()
+	swarmfs.readCache = params
+}
+
+// SetUmask sets the umask the permissions of the entries without mode in the manifest
+// and of the directories are derived from, for the mounts created afterwards
+func (swarmfs *SwarmFS) SetUmask(umask os.FileMode) {
+	swarmfs.swarmFsLock.Lock()
+	defer swarmfs.swarmFsLock.Unlock()
+	swarmfs.umask = umask & os.ModePerm
+}
+
 // Inode numbers need to be unique, they are used for caching inside fuse
 func NewInode() uint64 {
 	inodeLock.Lock()
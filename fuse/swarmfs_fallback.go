@@ -20,6 +20,7 @@ package fuse
 
 import (
 	"errors"
+	"time"
 )
 
 var errNoFUSE = errors.New("FUSE is not supported on this platform")
@@ -29,9 +30,12 @@ func isFUSEUnsupportedError(err error) bool {
 }
 
 type MountInfo struct {
-	MountPoint     string
-	StartManifest  string
-	LatestManifest string
+	MountPoint         string
+	StartManifest      string
+	LatestManifest     string
+	CheckpointManifest string
+	CheckpointTime     time.Time
+	Stats              MountStats
 }
 
 func (self *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
@@ -42,10 +46,18 @@ func (self *SwarmFS) Unmount(mountpoint string) (bool, error) {
 	return false, errNoFUSE
 }
 
+func (self *SwarmFS) PurgeTrash(mountpoint string) (*MountInfo, error) {
+	return nil, errNoFUSE
+}
+
 func (self *SwarmFS) Listmounts() ([]*MountInfo, error) {
 	return nil, errNoFUSE
 }
 
+func (self *SwarmFS) Stats(mountpoint string) (*MountStats, error) {
+	return nil, errNoFUSE
+}
+
 func (self *SwarmFS) Stop() error {
 	return nil
 }
@@ -20,18 +20,25 @@ package fuse
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
+	"bazil.org/fuse"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
 )
 
@@ -1661,3 +1668,422 @@ func Upload(uploadDir, index string, a *api.API, toEncrypt bool) (hash string, e
 	hash, err = fs.Upload(uploadDir, index, toEncrypt)
 	return hash, err
 }
+
+// TestCheckpoint tests that the latest manifest of a mount is saved once
+// enough bytes are written and on stopping, but only if it changed
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarmfs-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mi := NewMountInfo("start", "/mnt/swarm", nil)
+	mi.checkpoints = &CheckpointParams{
+		Dir:        dir,
+		DirtyBytes: 10,
+	}
+	mi.startCheckpointing()
+
+	waitCheckpoint := func(manifest string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			c, err := ReadCheckpoint(dir, mi.MountPoint)
+			if err == nil && c.LatestManifest == manifest {
+				if c.StartManifest != "start" {
+					t.Fatalf("expected start manifest %q, got %q", "start", c.StartManifest)
+				}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timeout waiting for checkpoint of manifest %q", manifest)
+	}
+
+	mi.lock.Lock()
+	mi.LatestManifest = "first"
+	mi.written(5)
+	mi.lock.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := ReadCheckpoint(dir, mi.MountPoint); !os.IsNotExist(err) {
+		t.Fatalf("expected no checkpoint below the dirty bytes threshold, got %v", err)
+	}
+
+	mi.lock.Lock()
+	mi.written(5)
+	mi.lock.Unlock()
+	waitCheckpoint("first")
+
+	mi.lock.Lock()
+	mi.LatestManifest = "second"
+	mi.lock.Unlock()
+	mi.stopCheckpointing()
+	waitCheckpoint("second")
+
+	mi.lock.RLock()
+	defer mi.lock.RUnlock()
+	if mi.CheckpointManifest != "second" {
+		t.Fatalf("expected checkpoint manifest %q, got %q", "second", mi.CheckpointManifest)
+	}
+	if mi.dirtyBytes != 0 {
+		t.Fatalf("expected no dirty bytes after checkpoint, got %d", mi.dirtyBytes)
+	}
+}
+
+// TestSaveModTimes tests that modification times set on the files of a mount
+// are saved in their manifest entries
+func TestSaveModTimes(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "fuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	a := api.NewAPI(fileStore, nil, nil, nil, nil, chunk.NewTags())
+
+	ctx := context.TODO()
+	addr, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fkey, mhash, err := a.AddFile(ctx, addr.Hex(), "/dir", "a.txt", []byte("content"), 0700, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mi := NewMountInfo(mhash, "/mnt/swarm", a)
+	mi.rootDir = NewSwarmDir("/", mi)
+	dir := NewSwarmDir("/dir", mi)
+	mi.rootDir.directories = append(mi.rootDir.directories, dir)
+	file := NewSwarmFile("/dir", "a.txt", mi)
+	file.addr = fkey
+	dir.files = append(dir.files, file)
+
+	modTime := time.Unix(1500000000, 0)
+	req := &fuse.SetattrRequest{
+		Valid: fuse.SetattrMtime,
+		Mtime: modTime,
+	}
+	if err := file.Setattr(ctx, req, &fuse.SetattrResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveAttributes(mi); err != nil {
+		t.Fatal(err)
+	}
+	if mi.LatestManifest == mhash {
+		t.Fatal("expected manifest to change")
+	}
+	if file.touched {
+		t.Fatal("expected modification time to be saved")
+	}
+
+	_, entries, err := a.BuildDirectoryTree(ctx, mi.LatestManifest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := entries["dir/a.txt"]
+	if !ok {
+		t.Fatal("expected entry dir/a.txt in manifest")
+	}
+	if !entry.ModTime.Equal(modTime) {
+		t.Fatalf("expected mod time %v, got %v", modTime, entry.ModTime)
+	}
+	if entry.Hash != fkey.Hex() {
+		t.Fatalf("expected content %s, got %s", fkey.Hex(), entry.Hash)
+	}
+}
+
+// TestRename tests that files and directories renamed across directories move
+// their manifest entries with their content, and the nodes of the mount
+func TestRename(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "fuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	a := api.NewAPI(fileStore, nil, nil, nil, nil, chunk.NewTags())
+
+	ctx := context.TODO()
+	addr, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mhash := addr.Hex()
+	keys := make(map[string]string)
+	for _, path := range []string{"dir/a.txt", "dir/sub/b.txt", "other/c.txt", "other/d.txt"} {
+		var fkey storage.Address
+		fkey, mhash, err = a.AddFile(ctx, mhash, "/"+filepath.Dir(path), filepath.Base(path), []byte(path), 0700, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[path] = fkey.Hex()
+	}
+
+	mi := NewMountInfo(mhash, "/mnt/swarm", a)
+	mi.rootDir = NewSwarmDir("/", mi)
+	dir := NewSwarmDir("/dir", mi)
+	sub := NewSwarmDir("/dir/sub", mi)
+	other := NewSwarmDir("/other", mi)
+	mi.rootDir.directories = append(mi.rootDir.directories, dir, other)
+	dir.directories = append(dir.directories, sub)
+	newFile := func(d *SwarmDir, name string) *SwarmFile {
+		f := NewSwarmFile(d.path, name, mi)
+		f.addr = storage.Address(common.Hex2Bytes(keys[manifestPath(filepath.Join(d.path, name))]))
+		d.files = append(d.files, f)
+		return f
+	}
+	fileA := newFile(dir, "a.txt")
+	fileB := newFile(sub, "b.txt")
+	newFile(other, "c.txt")
+	newFile(other, "d.txt")
+
+	// a file moved to another directory replaces the file with the new name
+	if err := dir.Rename(ctx, &fuse.RenameRequest{OldName: "a.txt", NewName: "c.txt"}, other); err != nil {
+		t.Fatal(err)
+	}
+	if len(dir.files) != 0 || len(other.files) != 2 || other.files[1] != fileA {
+		t.Fatalf("expected file moved to other directory, got %d and %d files", len(dir.files), len(other.files))
+	}
+	if fileA.path != "/other" || fileA.name != "c.txt" {
+		t.Fatalf("expected file at /other/c.txt, got %s/%s", fileA.path, fileA.name)
+	}
+
+	// a directory moves with all its files
+	if err := dir.Rename(ctx, &fuse.RenameRequest{OldName: "sub", NewName: "moved"}, mi.rootDir); err != nil {
+		t.Fatal(err)
+	}
+	if len(dir.directories) != 0 || len(mi.rootDir.directories) != 3 {
+		t.Fatal("expected directory moved to root directory")
+	}
+	if sub.path != "/moved" || sub.name != "moved" || fileB.path != "/moved" {
+		t.Fatalf("expected directory at /moved, got %s with file in %s", sub.path, fileB.path)
+	}
+
+	if err := dir.Rename(ctx, &fuse.RenameRequest{OldName: "none", NewName: "x"}, other); err != fuse.ENOENT {
+		t.Fatalf("expected error %v, got %v", fuse.ENOENT, err)
+	}
+	if err := mi.rootDir.Rename(ctx, &fuse.RenameRequest{OldName: "dir", NewName: "moved"}, mi.rootDir); err != fuse.Errno(syscall.ENOTEMPTY) {
+		t.Fatalf("expected error %v, got %v", syscall.ENOTEMPTY, err)
+	}
+
+	// a file not stored yet removes the entry of the file it replaces
+	unstored := NewSwarmFile(other.path, "new.txt", mi)
+	other.files = append(other.files, unstored)
+	if err := other.Rename(ctx, &fuse.RenameRequest{OldName: "new.txt", NewName: "d.txt"}, other); err != nil {
+		t.Fatal(err)
+	}
+	if len(other.files) != 2 || other.files[1] != unstored || unstored.name != "d.txt" {
+		t.Fatal("expected file replaced by file not stored yet")
+	}
+
+	_, entries, err := a.BuildDirectoryTree(ctx, mi.LatestManifest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"other/c.txt": keys["dir/a.txt"],
+		"moved/b.txt": keys["dir/sub/b.txt"],
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d manifest entries, got %d", len(want), len(entries))
+	}
+	for path, key := range want {
+		entry, ok := entries[path]
+		if !ok {
+			t.Fatalf("expected entry %s in manifest", path)
+		}
+		if entry.Hash != key {
+			t.Fatalf("expected content %s of %s, got %s", key, path, entry.Hash)
+		}
+	}
+}
+
+// TestMountStats tests that the reads, writes, chunks got and open handles
+// of the files are counted in the stats of their mount
+func TestMountStats(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "fuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	localStore, err := localstore.New(datadir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+	netStore := storage.NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	fileStore := storage.NewFileStore(storage.NewLNetStore(netStore), localStore, storage.NewFileStoreParams(), chunk.NewTags())
+	a := api.NewAPI(fileStore, nil, nil, nil, nil, chunk.NewTags())
+
+	ctx := context.TODO()
+	addr, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("content")
+	fkey, mhash, err := a.AddFile(ctx, addr.Hex(), "/", "a.txt", content, 0700, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mi := NewMountInfo(mhash, "/mnt/swarm", a)
+	file := NewSwarmFile("/", "a.txt", mi)
+	file.addr = fkey
+	file.fileSize = int64(len(content))
+
+	handle, err := file.Open(ctx, &fuse.OpenRequest{}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := mi.stats(); stats.OpenHandles != 1 {
+		t.Fatalf("expected 1 open handle, got %d", stats.OpenHandles)
+	}
+	for i := 0; i < 2; i++ {
+		resp := &fuse.ReadResponse{}
+		if err := handle.(*SwarmFile).Read(ctx, &fuse.ReadRequest{Size: 100}, resp); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(resp.Data, content) {
+			t.Fatalf("expected content %q, got %q", content, resp.Data)
+		}
+	}
+	if err := file.Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := mi.stats()
+	if stats.Reads != 2 || stats.BytesRead != uint64(2*len(content)) {
+		t.Fatalf("expected 2 reads of %d bytes, got %d reads of %d bytes", 2*len(content), stats.Reads, stats.BytesRead)
+	}
+	if stats.OpenHandles != 0 {
+		t.Fatalf("expected no open handles, got %d", stats.OpenHandles)
+	}
+	if stats.ChunkHits == 0 || stats.ChunkFetches != 0 || stats.CacheHitRatio != 1 {
+		t.Fatalf("expected only chunk hits, got %+v", stats)
+	}
+}
+
+// TestSaveModes tests that the permissions of the files of a mount default to the umask
+// of the mount and that permissions set on the mount, including none at all, are saved
+// in their manifest entries
+func TestSaveModes(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "fuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(datadir)
+
+	fileStore, cleanup, err := storage.NewLocalFileStore(datadir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	a := api.NewAPI(fileStore, nil, nil, nil, nil, chunk.NewTags())
+
+	ctx := context.TODO()
+	addr, err := a.NewManifest(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fkey, mhash, err := a.AddFile(ctx, addr.Hex(), "/dir", "a.txt", []byte("content"), 0700, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entries, err := a.BuildDirectoryTree(ctx, mhash, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := entries["dir/a.txt"].ModTime
+
+	mi := NewMountInfo(mhash, "/mnt/swarm", a)
+	mi.umask = 0022
+	if mode := mi.entryMode(0); mode != 0755 {
+		t.Fatalf("expected default mode %v, got %v", os.FileMode(0755), mode)
+	}
+	if mode := mi.entryMode(0100640); mode != 0640 {
+		t.Fatalf("expected mode %v, got %v", os.FileMode(0640), mode)
+	}
+	if mode := mi.entryMode(manifestMode(0)); mode != 0 {
+		t.Fatalf("expected no permissions, got %v", mode)
+	}
+
+	mi.rootDir = NewSwarmDir("/", mi)
+	dir := NewSwarmDir("/dir", mi)
+	mi.rootDir.directories = append(mi.rootDir.directories, dir)
+	file := NewSwarmFile("/dir", "a.txt", mi)
+	file.addr = fkey
+	dir.files = append(dir.files, file)
+
+	var attr fuse.Attr
+	if err := dir.Attr(ctx, &attr); err != nil {
+		t.Fatal(err)
+	}
+	if attr.Mode != os.ModeDir|0755 {
+		t.Fatalf("expected directory mode %v, got %v", os.ModeDir|0755, attr.Mode)
+	}
+
+	for _, perm := range []os.FileMode{0640, 0} {
+		req := &fuse.SetattrRequest{
+			Valid: fuse.SetattrMode,
+			Mode:  perm,
+		}
+		if err := file.Setattr(ctx, req, &fuse.SetattrResponse{}); err != nil {
+			t.Fatal(err)
+		}
+		latest := mi.LatestManifest
+		if err := saveAttributes(mi); err != nil {
+			t.Fatal(err)
+		}
+		if mi.LatestManifest == latest {
+			t.Fatal("expected manifest to change")
+		}
+		if file.chmodded {
+			t.Fatal("expected mode to be saved")
+		}
+
+		_, entries, err = a.BuildDirectoryTree(ctx, mi.LatestManifest, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := entries["dir/a.txt"]
+		if !ok {
+			t.Fatal("expected entry dir/a.txt in manifest")
+		}
+		if mode := mi.entryMode(entry.Mode); mode != perm {
+			t.Fatalf("expected mode %v, got %v", perm, mode)
+		}
+		if !entry.ModTime.Equal(modTime) {
+			t.Fatalf("expected mod time %v to be unchanged, got %v", modTime, entry.ModTime)
+		}
+		if entry.Hash != fkey.Hex() {
+			t.Fatalf("expected content %s, got %s", fkey.Hex(), entry.Hash)
+		}
+	}
+
+	// the permissions of a file are written with its content
+	file.mode = 0600
+	file.fileSize = int64(len("content"))
+	if err := appendToExistingFileInSwarm(file, []byte("!"), int64(len("content")), 1); err != nil {
+		t.Fatal(err)
+	}
+	_, entries, err = a.BuildDirectoryTree(ctx, mi.LatestManifest, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := mi.entryMode(entries["dir/a.txt"].Mode); mode != 0600 {
+		t.Fatalf("expected mode %v of the written file, got %v", os.FileMode(0600), mode)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"bazil.org/fuse"
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
 )
 
 var (
@@ -52,14 +54,60 @@ func isFUSEUnsupportedError(err error) bool {
 
 // MountInfo contains information about every active mount
 type MountInfo struct {
-	MountPoint     string
-	StartManifest  string
-	LatestManifest string
-	rootDir        *SwarmDir
-	fuseConnection *fuse.Conn
-	swarmApi       *api.API
-	lock           *sync.RWMutex
-	serveClose     chan struct{}
+	MountPoint         string
+	StartManifest      string
+	LatestManifest     string
+	CheckpointManifest string     // latest manifest saved by the last checkpoint
+	CheckpointTime     time.Time  // time of the last checkpoint
+	Stats              MountStats // counters of the mount, updated when the mounts are listed
+	rootDir            *SwarmDir
+	fuseConnection     *fuse.Conn
+	swarmApi           *api.API
+	lock               *sync.RWMutex
+	serveClose         chan struct{}
+	checkpoints        *CheckpointParams
+	trash              bool          // removed files are moved under TrashDir
+	dirtyBytes         int64         // bytes written since the last checkpoint
+	checkpointC        chan struct{} // requests a checkpoint
+	checkpointQuit     chan struct{} // terminates the checkpointing goroutine
+	checkpointDone     chan struct{} // closed when the checkpointing goroutine terminated
+	counters           mountCounters
+	readCache          *readCache  // content read ahead of the read requests on the files
+	umask              os.FileMode // masked from the permissions of entries without mode and of directories
+}
+
+// mountCounters are updated atomically by the file operations on the mount
+type mountCounters struct {
+	reads        uint64
+	bytesRead    uint64
+	writes       uint64
+	bytesWritten uint64
+	openHandles  int64
+	fetches      storage.FetchStats // chunks got by the readers of the mount
+}
+
+// stats returns the current counters of the mount
+func (mi *MountInfo) stats() MountStats {
+	c := &mi.counters
+	stats := MountStats{
+		Reads:        atomic.LoadUint64(&c.reads),
+		BytesRead:    atomic.LoadUint64(&c.bytesRead),
+		Writes:       atomic.LoadUint64(&c.writes),
+		BytesWritten: atomic.LoadUint64(&c.bytesWritten),
+		ChunkHits:    c.fetches.Hits(),
+		ChunkFetches: c.fetches.Fetches(),
+		OpenHandles:  atomic.LoadInt64(&c.openHandles),
+	}
+	if total := stats.ChunkHits + stats.ChunkFetches; total > 0 {
+		stats.CacheHitRatio = float64(stats.ChunkHits) / float64(total)
+	}
+	return stats
+}
+
+// readContext returns the context the content of the mount is retrieved
+// with, so that the chunks got are counted in the stats of the mount
+func (mi *MountInfo) readContext(ctx context.Context) context.Context {
+	return storage.WithFetchStats(ctx, &mi.counters.fetches)
 }
 
 func NewMountInfo(mhash, mpoint string, sapi *api.API) *MountInfo {
@@ -73,10 +121,32 @@ func NewMountInfo(mhash, mpoint string, sapi *api.API) *MountInfo {
 		swarmApi:       sapi,
 		lock:           &sync.RWMutex{},
 		serveClose:     make(chan struct{}),
+		checkpointC:    make(chan struct{}, 1),
+		readCache:      newReadCache(nil),
+		umask:          DefaultUmask,
 	}
 	return newMountInfo
 }
 
+// regularFileMode is the type bits of a regular file in the mode of tar headers, set in
+// the modes saved in manifest entries so that a mode without permission bits is told
+// apart from an entry without mode
+const regularFileMode = 0100000
+
+// entryMode returns the permission bits of a manifest entry mode, or the permissions
+// under the umask of the mount if the entry has no mode
+func (mi *MountInfo) entryMode(mode int64) os.FileMode {
+	if mode != 0 {
+		return os.FileMode(mode) & os.ModePerm
+	}
+	return os.ModePerm &^ mi.umask
+}
+
+// manifestMode returns the manifest entry mode of a file with the permission bits perm
+func manifestMode(perm os.FileMode) int64 {
+	return regularFileMode | int64(perm&os.ModePerm)
+}
+
 func (swarmfs *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	log.Info("swarmfs", "mounting hash", mhash, "mount point", mountpoint)
 	if mountpoint == "" {
@@ -105,13 +175,24 @@ func (swarmfs *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	}
 
 	log.Trace("swarmfs mount: getting manifest tree")
-	_, manifestEntryMap, err := swarmfs.swarmApi.BuildDirectoryTree(context.TODO(), mhash, true)
+	ctx := api.NewRPCRequest(context.Background(), "swarmfs_mount")
+	addr, manifestEntryMap, err := swarmfs.swarmApi.BuildDirectoryTree(ctx, mhash, true)
 	if err != nil {
 		return nil, err
 	}
+	swarmfs.swarmApi.PrefetchInBackground(ctx, "", addr)
 
 	log.Trace("swarmfs mount: building mount info")
 	mi := NewMountInfo(mhash, cleanedMountPoint, swarmfs.swarmApi)
+	mi.checkpoints = swarmfs.checkpoints
+	mi.trash = swarmfs.trash
+	mi.readCache = newReadCache(swarmfs.readCache)
+	mi.umask = swarmfs.umask
+	if mi.checkpoints != nil {
+		if c, err := ReadCheckpoint(mi.checkpoints.Dir, cleanedMountPoint); err == nil && c.LatestManifest != mhash {
+			log.Warn("swarmfs found checkpoint of a previous mount", "mountpoint", cleanedMountPoint, "manifest", c.LatestManifest, "time", c.Time)
+		}
+	}
 
 	dirTree := map[string]*SwarmDir{}
 	rootDir := NewSwarmDir("/", mi)
@@ -147,11 +228,18 @@ func (swarmfs *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 		}
 		thisFile := NewSwarmFile(basepath, filepath.Base(fullpath), mi)
 		thisFile.addr = addr
+		thisFile.modTime = entry.ModTime
+		thisFile.mode = mi.entryMode(entry.Mode)
 
 		parentDir.files = append(parentDir.files, thisFile)
 	}
 
-	fconn, err := fuse.Mount(cleanedMountPoint, fuse.FSName("swarmfs"), fuse.VolumeName(mhash))
+	options := []fuse.MountOption{fuse.FSName("swarmfs"), fuse.VolumeName(mhash)}
+	// a node in gateway mode only serves the content, the write paths of the mount are disabled
+	if swarmfs.swarmApi.ReadOnly() {
+		options = append(options, fuse.ReadOnly())
+	}
+	fconn, err := fuse.Mount(cleanedMountPoint, options...)
 	if isFUSEUnsupportedError(err) {
 		log.Error("swarmfs error - FUSE not installed", "mountpoint", cleanedMountPoint, "err", err)
 		return nil, err
@@ -224,6 +312,7 @@ func (swarmfs *SwarmFS) Mount(mhash, mountpoint string) (*MountInfo, error) {
 	}
 
 	timer.Stop()
+	mi.startCheckpointing()
 	swarmfs.activeMounts[cleanedMountPoint] = mi
 	return mi, nil
 }
@@ -259,6 +348,11 @@ func (swarmfs *SwarmFS) Unmount(mountpoint string) (*MountInfo, error) {
 	delete(swarmfs.activeMounts, cleanedMountPoint)
 
 	<-mountInfo.serveClose
+	// no requests are served anymore, the file tree can be saved
+	if err := saveAttributes(mountInfo); err != nil {
+		log.Error("swarmfs could not save file attributes", "mountpoint", cleanedMountPoint, "err", err)
+	}
+	mountInfo.stopCheckpointing()
 
 	succString := fmt.Sprintf("swarmfs unmounting %v succeeded", cleanedMountPoint)
 	log.Info(succString)
@@ -266,16 +360,55 @@ func (swarmfs *SwarmFS) Unmount(mountpoint string) (*MountInfo, error) {
 	return mountInfo, nil
 }
 
+// PurgeTrash removes the files moved under TrashDir from the latest manifest of a mount
+func (swarmfs *SwarmFS) PurgeTrash(mountpoint string) (*MountInfo, error) {
+	swarmfs.swarmFsLock.RLock()
+	defer swarmfs.swarmFsLock.RUnlock()
+
+	cleanedMountPoint, err := filepath.Abs(filepath.Clean(mountpoint))
+	if err != nil {
+		return nil, err
+	}
+	mountInfo := swarmfs.activeMounts[cleanedMountPoint]
+	if mountInfo == nil {
+		return nil, fmt.Errorf("swarmfs %s is not mounted", cleanedMountPoint)
+	}
+	if err := purgeTrash(mountInfo); err != nil {
+		return nil, err
+	}
+	return mountInfo, nil
+}
+
 func (swarmfs *SwarmFS) Listmounts() []*MountInfo {
 	swarmfs.swarmFsLock.RLock()
 	defer swarmfs.swarmFsLock.RUnlock()
 	rows := make([]*MountInfo, 0, len(swarmfs.activeMounts))
 	for _, mi := range swarmfs.activeMounts {
+		mi.lock.Lock()
+		mi.Stats = mi.stats()
+		mi.lock.Unlock()
 		rows = append(rows, mi)
 	}
 	return rows
 }
 
+// Stats returns the counters of the file operations on the mount
+func (swarmfs *SwarmFS) Stats(mountpoint string) (*MountStats, error) {
+	swarmfs.swarmFsLock.RLock()
+	defer swarmfs.swarmFsLock.RUnlock()
+
+	cleanedMountPoint, err := filepath.Abs(filepath.Clean(mountpoint))
+	if err != nil {
+		return nil, err
+	}
+	mountInfo := swarmfs.activeMounts[cleanedMountPoint]
+	if mountInfo == nil {
+		return nil, fmt.Errorf("swarmfs %s is not mounted", cleanedMountPoint)
+	}
+	stats := mountInfo.stats()
+	return &stats, nil
+}
+
 func (swarmfs *SwarmFS) Stop() bool {
 	for mp := range swarmfs.activeMounts {
 		mountInfo := swarmfs.activeMounts[mp]
@@ -22,8 +22,14 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
+	"bazil.org/fuse"
+	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/log"
 )
 
@@ -47,7 +53,10 @@ func externalUnmount(mountPoint string) error {
 }
 
 func addFileToSwarm(sf *SwarmFile, content []byte, size int) error {
-	fkey, mhash, err := sf.mountInfo.swarmApi.AddFile(context.TODO(), sf.mountInfo.LatestManifest, sf.path, sf.name, content, true)
+	sf.lock.RLock()
+	mode := manifestMode(sf.mode)
+	sf.lock.RUnlock()
+	fkey, mhash, err := sf.mountInfo.swarmApi.AddFile(context.TODO(), sf.mountInfo.LatestManifest, sf.path, sf.name, content, mode, true)
 	if err != nil {
 		return err
 	}
@@ -55,17 +64,30 @@ func addFileToSwarm(sf *SwarmFile, content []byte, size int) error {
 	sf.lock.Lock()
 	defer sf.lock.Unlock()
 	sf.addr = fkey
+	sf.reader = nil
+	sf.mountInfo.readCache.drop(sf)
 	sf.fileSize = int64(size)
+	sf.modTime = time.Now()
 
 	sf.mountInfo.lock.Lock()
 	defer sf.mountInfo.lock.Unlock()
 	sf.mountInfo.LatestManifest = mhash
+	sf.mountInfo.written(len(content))
 
 	log.Info("swarmfs added new file:", "fname", sf.name, "new Manifest hash", mhash)
 	return nil
 }
 
 func removeFileFromSwarm(sf *SwarmFile) error {
+	sf.lock.RLock()
+	path := strings.TrimPrefix(filepath.Join(sf.path, sf.name), "/")
+	stored := sf.addr != nil
+	sf.lock.RUnlock()
+	// files removed from the trash and files not stored yet are dropped
+	if sf.mountInfo.trash && stored && !inTrash(path) {
+		return moveFileToTrash(sf.mountInfo, path, time.Now())
+	}
+
 	mkey, err := sf.mountInfo.swarmApi.RemoveFile(context.TODO(), sf.mountInfo.LatestManifest, sf.path, sf.name, true)
 	if err != nil {
 		return err
@@ -79,6 +101,82 @@ func removeFileFromSwarm(sf *SwarmFile) error {
 	return nil
 }
 
+// inTrash reports whether the manifest path is under TrashDir
+func inTrash(path string) bool {
+	return path == TrashDir || strings.HasPrefix(path, TrashDir+"/")
+}
+
+// trashPath returns the path under TrashDir a file removed at the given time is moved to
+func trashPath(path string, removed time.Time) string {
+	return TrashDir + "/" + removed.UTC().Format("2006-01-02T15:04:05Z") + "/" + path
+}
+
+// updateManifest applies the update to the latest manifest of the mount
+func updateManifest(mi *MountInfo, update func(mw *api.ManifestWriter) error) (string, error) {
+	mi.lock.RLock()
+	latest := mi.LatestManifest
+	mi.lock.RUnlock()
+	uri, err := api.Parse("bzz:/" + latest)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.TODO()
+	addr, err := mi.swarmApi.ResolveURI(ctx, uri, api.EmptyCredentials)
+	if err != nil {
+		return "", err
+	}
+	mkey, err := mi.swarmApi.UpdateManifest(ctx, addr, update)
+	if err != nil {
+		return "", err
+	}
+
+	mi.lock.Lock()
+	defer mi.lock.Unlock()
+	mi.LatestManifest = mkey.Hex()
+	return mi.LatestManifest, nil
+}
+
+// moveFileToTrash moves the manifest entry of a removed file under TrashDir,
+// keeping the removal time in the path
+func moveFileToTrash(mi *MountInfo, path string, removed time.Time) error {
+	to := trashPath(path, removed)
+	mhash, err := updateManifest(mi, func(mw *api.ManifestWriter) error {
+		return mw.MoveEntry(path, to)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("swarmfs moved file to trash:", "path", path, "trash path", to, "new Manifest hash", mhash)
+	return nil
+}
+
+// purgeTrash removes the files under TrashDir from the latest manifest of the mount
+func purgeTrash(mi *MountInfo) error {
+	var purged int
+	mhash, err := updateManifest(mi, func(mw *api.ManifestWriter) (err error) {
+		purged, err = mw.RemovePrefix(TrashDir + "/")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// the trash directory is not served anymore
+	root := mi.rootDir
+	root.lock.Lock()
+	for i, d := range root.directories {
+		if d.path == "/"+TrashDir {
+			root.directories = append(root.directories[:i], root.directories[i+1:]...)
+			break
+		}
+	}
+	root.lock.Unlock()
+
+	log.Info("swarmfs purged trash:", "files", purged, "new Manifest hash", mhash)
+	return nil
+}
+
 func removeDirectoryFromSwarm(sd *SwarmDir) error {
 	if len(sd.directories) == 0 && len(sd.files) == 0 {
 		return nil
@@ -101,8 +199,145 @@ func removeDirectoryFromSwarm(sd *SwarmDir) error {
 	return nil
 }
 
+// manifestPath returns the manifest path of a path on the mount
+func manifestPath(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+// replacesStoredFile reports whether the directory has a stored file with the name,
+// the caller is expected to hold the lock of the directory
+func replacesStoredFile(dir *SwarmDir, name string) bool {
+	for _, f := range dir.files {
+		if f.name == name {
+			f.lock.RLock()
+			defer f.lock.RUnlock()
+			return f.addr != nil
+		}
+	}
+	return false
+}
+
+// renameInSwarm moves the file or directory with the name from the directory to
+// the other one under the new name, replacing a file or an empty directory with
+// the new name. The manifest entries of the files are moved without copying their
+// content, those of a directory by moving all entries with its path as prefix.
+func renameInSwarm(from *SwarmDir, name string, to *SwarmDir, newName string) error {
+	from.lock.Lock()
+	defer from.lock.Unlock()
+	if to != from {
+		to.lock.Lock()
+		defer to.lock.Unlock()
+	}
+	if to == from && name == newName {
+		return nil
+	}
+	mi := from.mountInfo
+
+	for i, f := range from.files {
+		if f.name != name {
+			continue
+		}
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		newPath := manifestPath(filepath.Join(to.path, newName))
+		// files not stored yet have no manifest entry, the entry of a replaced
+		// file is overwritten by the move or removed if there is none to move
+		if f.addr != nil {
+			oldPath := manifestPath(filepath.Join(f.path, f.name))
+			mhash, err := updateManifest(mi, func(mw *api.ManifestWriter) error {
+				return mw.MoveEntry(oldPath, newPath)
+			})
+			if err != nil {
+				return err
+			}
+			log.Info("swarmfs moved file:", "path", oldPath, "new path", newPath, "new Manifest hash", mhash)
+		} else if replacesStoredFile(to, newName) {
+			mhash, err := updateManifest(mi, func(mw *api.ManifestWriter) error {
+				return mw.RemoveEntry(newPath)
+			})
+			if err != nil {
+				return err
+			}
+			log.Info("swarmfs removed replaced file:", "path", newPath, "new Manifest hash", mhash)
+		}
+		from.files = append(from.files[:i], from.files[i+1:]...)
+		for j, t := range to.files {
+			if t.name == newName {
+				to.files = append(to.files[:j], to.files[j+1:]...)
+				break
+			}
+		}
+		f.path = to.path
+		f.name = newName
+		to.files = append(to.files, f)
+		return nil
+	}
+
+	for i, d := range from.directories {
+		if d.name != name {
+			continue
+		}
+		var replaced *SwarmDir
+		for _, t := range to.directories {
+			if t.name == newName {
+				t.lock.RLock()
+				empty := len(t.files) == 0 && len(t.directories) == 0
+				t.lock.RUnlock()
+				if !empty {
+					return fuse.Errno(syscall.ENOTEMPTY)
+				}
+				replaced = t
+				break
+			}
+		}
+		newPath := filepath.Join(to.path, newName)
+		oldPrefix := manifestPath(d.path) + "/"
+		newPrefix := manifestPath(newPath) + "/"
+		var moved int
+		mhash, err := updateManifest(mi, func(mw *api.ManifestWriter) (err error) {
+			moved, err = mw.MovePrefix(oldPrefix, newPrefix)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		log.Info("swarmfs moved directory:", "path", oldPrefix, "new path", newPrefix, "files", moved, "new Manifest hash", mhash)
+
+		from.directories = append(from.directories[:i], from.directories[i+1:]...)
+		for j, t := range to.directories {
+			if t == replaced {
+				to.directories = append(to.directories[:j], to.directories[j+1:]...)
+				break
+			}
+		}
+		setDirPath(d, newPath)
+		to.directories = append(to.directories, d)
+		return nil
+	}
+	return fuse.ENOENT
+}
+
+// setDirPath sets the path of the directory, and of the files and directories in it
+func setDirPath(sd *SwarmDir, path string) {
+	sd.lock.Lock()
+	defer sd.lock.Unlock()
+	sd.path = path
+	sd.name = filepath.Base(path)
+	for _, f := range sd.files {
+		f.lock.Lock()
+		f.path = path
+		f.lock.Unlock()
+	}
+	for _, d := range sd.directories {
+		setDirPath(d, filepath.Join(path, d.name))
+	}
+}
+
 func appendToExistingFileInSwarm(sf *SwarmFile, content []byte, offset int64, length int64) error {
-	fkey, mhash, err := sf.mountInfo.swarmApi.AppendFile(context.TODO(), sf.mountInfo.LatestManifest, sf.path, sf.name, sf.fileSize, content, sf.addr, offset, length, true)
+	sf.lock.RLock()
+	mode := manifestMode(sf.mode)
+	sf.lock.RUnlock()
+	fkey, mhash, err := sf.mountInfo.swarmApi.AppendFile(context.TODO(), sf.mountInfo.LatestManifest, sf.path, sf.name, sf.fileSize, content, sf.addr, offset, length, mode, true)
 	if err != nil {
 		return err
 	}
@@ -110,12 +345,90 @@ func appendToExistingFileInSwarm(sf *SwarmFile, content []byte, offset int64, le
 	sf.lock.Lock()
 	defer sf.lock.Unlock()
 	sf.addr = fkey
+	sf.reader = nil
+	sf.mountInfo.readCache.drop(sf)
 	sf.fileSize = sf.fileSize + int64(len(content))
+	sf.modTime = time.Now()
 
 	sf.mountInfo.lock.Lock()
 	defer sf.mountInfo.lock.Unlock()
 	sf.mountInfo.LatestManifest = mhash
+	sf.mountInfo.written(len(content))
 
 	log.Info("swarmfs appended file:", "fname", sf.name, "new Manifest hash", mhash)
 	return nil
 }
+
+// touchedFiles returns the files of the directory tree whose modification time
+// or permissions were set on the mount
+func touchedFiles(sd *SwarmDir) (files []*SwarmFile) {
+	sd.lock.RLock()
+	defer sd.lock.RUnlock()
+	for _, d := range sd.directories {
+		files = append(files, touchedFiles(d)...)
+	}
+	for _, f := range sd.files {
+		f.lock.RLock()
+		if (f.touched || f.chmodded) && f.addr != nil {
+			files = append(files, f)
+		}
+		f.lock.RUnlock()
+	}
+	return files
+}
+
+// saveAttributes saves the modification times and the permissions set on the mount in the
+// manifest entries of the files, so that tools relying on them see them on the next mount
+func saveAttributes(mi *MountInfo) error {
+	files := touchedFiles(mi.rootDir)
+	if len(files) == 0 {
+		return nil
+	}
+
+	mi.lock.RLock()
+	latest := mi.LatestManifest
+	mi.lock.RUnlock()
+	uri, err := api.Parse("bzz:/" + latest)
+	if err != nil {
+		return err
+	}
+	ctx := context.TODO()
+	addr, err := mi.swarmApi.ResolveURI(ctx, uri, api.EmptyCredentials)
+	if err != nil {
+		return err
+	}
+	mkey, err := mi.swarmApi.UpdateManifest(ctx, addr, func(mw *api.ManifestWriter) error {
+		for _, f := range files {
+			f.lock.RLock()
+			path := strings.TrimPrefix(filepath.Join(f.path, f.name), "/")
+			var err error
+			if f.touched {
+				err = mw.SetModTime(path, f.modTime)
+			}
+			if err == nil && f.chmodded {
+				err = mw.SetMode(path, manifestMode(f.mode))
+			}
+			f.lock.RUnlock()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		f.lock.Lock()
+		f.touched = false
+		f.chmodded = false
+		f.lock.Unlock()
+	}
+
+	mi.lock.Lock()
+	defer mi.lock.Unlock()
+	mi.LatestManifest = mkey.Hex()
+
+	log.Info("swarmfs saved file attributes:", "files", len(files), "new Manifest hash", mkey)
+	return nil
+}
@@ -30,6 +30,7 @@ var Metrics = []cli.Flag{
 	MetricsInfluxDBUsernameFlag,
 	MetricsInfluxDBPasswordFlag,
 	MetricsInfluxDBTagsFlag,
+	MetricsPrometheusAddrFlag,
 }
 
 var (
@@ -66,4 +67,9 @@ var (
 		Usage: "Comma-separated InfluxDB tags (key/values) attached to all measurements",
 		Value: "host=localhost",
 	}
+	MetricsPrometheusAddrFlag = cli.StringFlag{
+		Name:  "metrics.prometheus.addr",
+		Usage: "Address of the HTTP server exporting the metrics in the Prometheus format at /metrics (disabled if empty)",
+		Value: "",
+	}
 )
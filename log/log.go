@@ -1,8 +1,11 @@
 package log
 
 import (
+	"context"
+
 	l "github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/sctx"
 )
 
 const (
@@ -27,6 +30,62 @@ func NewBaseAddressLogger(baseAddr string, ctx ...interface{}) l.Logger {
 	return l.New(ctx...)
 }
 
+// WithRequest returns a logger that adds the identifier of the HTTP or RPC
+// request the context belongs to to the lines logged with the given logger,
+// or the given logger if the context does not belong to a request.
+// No child logger is created, the identifier is added to the context of the
+// lines as they are logged, so that it is cheap on the paths of requests
+func WithRequest(ctx context.Context, logger Logger) Logger {
+	if ruid := sctx.GetRequestID(ctx); ruid != "" {
+		return &requestLogger{Logger: logger, ruid: ruid}
+	}
+	return logger
+}
+
+// requestLogger adds the identifier of a request to the lines logged with the logger
+type requestLogger struct {
+	Logger
+	ruid string
+}
+
+func (r *requestLogger) ctx(ctx []interface{}) []interface{} {
+	return append([]interface{}{"ruid", r.ruid}, ctx...)
+}
+
+func (r *requestLogger) New(ctx ...interface{}) Logger {
+	return r.Logger.New(r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Trace(msg string, ctx ...interface{}) {
+	r.Logger.Trace(msg, r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Debug(msg string, ctx ...interface{}) {
+	r.Logger.Debug(msg, r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Info(msg string, ctx ...interface{}) {
+	r.Logger.Info(msg, r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Warn(msg string, ctx ...interface{}) {
+	r.Logger.Warn(msg, r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Error(msg string, ctx ...interface{}) {
+	r.Logger.Error(msg, r.ctx(ctx)...)
+}
+
+func (r *requestLogger) Crit(msg string, ctx ...interface{}) {
+	r.Logger.Crit(msg, r.ctx(ctx)...)
+}
+
+// Request returns the root logger with the identifier of the HTTP or RPC
+// request the context belongs to, see WithRequest
+func Request(ctx context.Context) Logger {
+	return WithRequest(ctx, l.Root())
+}
+
 // New creates new swarm logger
 func New(ctx ...interface{}) Logger {
 	return l.New(ctx)
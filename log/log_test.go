@@ -0,0 +1,72 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package log
+
+import (
+	"context"
+	"testing"
+
+	l "github.com/ethereum/go-ethereum/log"
+	"github.com/ethersphere/swarm/sctx"
+)
+
+// TestWithRequest tests that the identifier of the request a context
+// belongs to is added to the log lines
+func TestWithRequest(t *testing.T) {
+	var records []*l.Record
+	logger := l.New("base", "test")
+	logger.SetHandler(l.FuncHandler(func(r *l.Record) error {
+		records = append(records, r)
+		return nil
+	}))
+
+	if WithRequest(context.Background(), logger) != logger {
+		t.Fatal("expected the logger itself for a context without a request")
+	}
+
+	ctx := sctx.SetRequestID(context.Background(), "abcd1234")
+	WithRequest(ctx, logger).Info("test", "key", "value")
+	if len(records) != 1 {
+		t.Fatalf("got %d log records, want 1", len(records))
+	}
+	want := []interface{}{"base", "test", "ruid", "abcd1234", "key", "value"}
+	got := records[0].Ctx
+	if len(got) != len(want) {
+		t.Fatalf("got log context %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got log context %v, want %v", got, want)
+		}
+	}
+
+	// the child loggers keep the identifier
+	WithRequest(ctx, logger).New("child", "yes").Debug("test")
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want 2", len(records))
+	}
+	want = []interface{}{"base", "test", "ruid", "abcd1234", "child", "yes"}
+	got = records[1].Ctx
+	if len(got) != len(want) {
+		t.Fatalf("got log context %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got log context %v, want %v", got, want)
+		}
+	}
+}
@@ -24,9 +24,10 @@ import (
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/metrics"
-	"github.com/ethereum/go-ethereum/metrics/prometheus"
+	gethprometheus "github.com/ethereum/go-ethereum/metrics/prometheus"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/metrics/influxdb"
+	"github.com/ethersphere/swarm/metrics/prometheus"
 )
 
 type Options struct {
@@ -37,6 +38,9 @@ type Options struct {
 	EnableExport  bool
 	DataDirectory string
 	InfluxDBTags  string
+	// PrometheusAddr is the address of the HTTP server exporting
+	// the metrics at /metrics, it is not started if empty
+	PrometheusAddr string
 }
 
 func init() {
@@ -62,7 +66,25 @@ func Setup(o Options) {
 			go influxdb.InfluxDBWithTags(metrics.DefaultRegistry, 10*time.Second, o.Endoint, o.Database, o.Username, o.Password, "swarm.", tagsMap)
 			go influxdb.InfluxDBWithTags(metrics.AccountingRegistry, 10*time.Second, o.Endoint, o.Database, o.Username, o.Password, "accounting.", tagsMap)
 		}
-		http.Handle("/debug/metrics/prometheus/accounting", prometheus.Handler(metrics.AccountingRegistry))
+		http.Handle("/debug/metrics/prometheus/accounting", gethprometheus.Handler(metrics.AccountingRegistry))
+
+		if o.PrometheusAddr != "" {
+			log.Info("Enabling swarm metrics export to Prometheus", "addr", o.PrometheusAddr)
+			go servePrometheus(o.PrometheusAddr)
+		}
+	}
+}
+
+// servePrometheus serves the swarm and accounting metrics in the Prometheus
+// format at /metrics
+func servePrometheus(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", prometheus.Handler(map[string]metrics.Registry{
+		"swarm":      metrics.DefaultRegistry,
+		"accounting": metrics.AccountingRegistry,
+	}))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error("Failure in running Prometheus metrics server", "err", err)
 	}
 }
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/metrics/labels"
 	"github.com/influxdata/influxdb/client"
 )
 
@@ -130,26 +131,39 @@ func (r *reporter) send() error {
 		now := time.Now()
 		namespace := r.namespace
 
+		// the labels of the metric are sent as tags along with the tags of the reporter
+		key := name
+		name, lbls := labels.Split(name)
+		tags := r.tags
+		if len(lbls) > 0 {
+			tags = make(map[string]string, len(r.tags)+len(lbls))
+			for k, v := range r.tags {
+				tags[k] = v
+			}
+			for k, v := range lbls {
+				tags[k] = v
+			}
+		}
 		name = mutateKey(name)
 
 		switch metric := i.(type) {
 		case metrics.Counter:
 			v := metric.Count()
-			l := r.cache[name]
+			l := r.cache[key]
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.count", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"value": v - l,
 				},
 				Time: now,
 			})
-			r.cache[name] = v
+			r.cache[key] = v
 		case metrics.Gauge:
 			ms := metric.Snapshot()
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.gauge", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"value": ms.Value(),
 				},
@@ -159,7 +173,7 @@ func (r *reporter) send() error {
 			ms := metric.Snapshot()
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.gauge", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"value": ms.Value(),
 				},
@@ -170,7 +184,7 @@ func (r *reporter) send() error {
 			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.histogram", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"count":    ms.Count(),
 					"max":      ms.Max(),
@@ -191,7 +205,7 @@ func (r *reporter) send() error {
 			ms := metric.Snapshot()
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.meter", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"count": ms.Count(),
 					"m1":    ms.Rate1(),
@@ -206,7 +220,7 @@ func (r *reporter) send() error {
 			ps := ms.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999})
 			pts = append(pts, client.Point{
 				Measurement: fmt.Sprintf("%s%s.timer", namespace, name),
-				Tags:        r.tags,
+				Tags:        tags,
 				Fields: map[string]interface{}{
 					"count":    ms.Count(),
 					"max":      ms.Max(),
@@ -235,7 +249,7 @@ func (r *reporter) send() error {
 				val := t.Values()
 				pts = append(pts, client.Point{
 					Measurement: fmt.Sprintf("%s%s.span", namespace, name),
-					Tags:        r.tags,
+					Tags:        tags,
 					Fields: map[string]interface{}{
 						"count": len(val),
 						"max":   val[len(val)-1],
@@ -0,0 +1,99 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package labels encodes labels, such as the peer or the topic a measurement
+// is about, in the names of metrics, so that the exporters can report them as
+// Prometheus labels or InfluxDB tags instead of as separate metrics.
+//
+// Every distinct label value is a separate series, so labels are only used for
+// bounded sets of values: the topics are those with registered handlers and the
+// peers are the connected ones, their metrics are unregistered on disconnection.
+package labels
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// PeerKey is the key of the label of the peer a measurement is about
+const PeerKey = "peer"
+
+// Name returns the name of the metric with the labels given as key value pairs,
+// in the form name{key=value,key=value}. The labels are sorted by their keys so
+// that the same labels always result in the same metric.
+func Name(name string, keyvalues ...string) string {
+	if len(keyvalues) < 2 {
+		return name
+	}
+	pairs := make([]string, 0, len(keyvalues)/2)
+	for i := 0; i+1 < len(keyvalues); i += 2 {
+		pairs = append(pairs, escape(keyvalues[i])+"="+escape(keyvalues[i+1]))
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Split returns the name of the metric without the labels and the labels
+// encoded in it by Name, which are nil if there are none.
+func Split(name string) (string, map[string]string) {
+	start := strings.IndexByte(name, '{')
+	if start < 0 || !strings.HasSuffix(name, "}") {
+		return name, nil
+	}
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(name[start+1:len(name)-1], ",") {
+		if i := strings.IndexByte(pair, '='); i > 0 {
+			labels[pair[:i]] = pair[i+1:]
+		}
+	}
+	return name[:start], labels
+}
+
+// Peer returns the value of the peer label for the overlay address of a peer,
+// the hex encoding of its first 16 bytes as in the metrics named after the peers
+func Peer(addr []byte) string {
+	if len(addr) > 16 {
+		addr = addr[:16]
+	}
+	return hex.EncodeToString(addr)
+}
+
+// Unregister removes the metrics with the prefix and the label from the registry,
+// or from the default registry if it is nil. It is called when a peer disconnects
+// so that the metrics of the peer are not kept for the lifetime of the node.
+func Unregister(r metrics.Registry, prefix, key, value string) {
+	if r == nil {
+		r = metrics.DefaultRegistry
+	}
+	var names []string
+	r.Each(func(name string, _ interface{}) {
+		base, labels := Split(name)
+		if strings.HasPrefix(base, prefix) && labels[key] == escape(value) {
+			names = append(names, name)
+		}
+	})
+	for _, name := range names {
+		r.Unregister(name)
+	}
+}
+
+// escape replaces the characters that delimit the labels
+func escape(s string) string {
+	return strings.NewReplacer("{", "_", "}", "_", ",", "_", "=", "_").Replace(s)
+}
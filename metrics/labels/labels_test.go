@@ -0,0 +1,83 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package labels
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+func TestLabels(t *testing.T) {
+	for _, tc := range []struct {
+		keyvalues []string
+		name      string
+		labels    map[string]string
+	}{
+		{nil, "pss/handle", nil},
+		{[]string{"topic"}, "pss/handle", nil},
+		{[]string{"topic", "0xabcd"}, "pss/handle{topic=0xabcd}", map[string]string{"topic": "0xabcd"}},
+		{[]string{"topic", "0xabcd", "peer", "0102"}, "pss/handle{peer=0102,topic=0xabcd}", map[string]string{"topic": "0xabcd", "peer": "0102"}},
+		{[]string{"topic", "a=b,{c}"}, "pss/handle{topic=a_b__c_}", map[string]string{"topic": "a_b__c_"}},
+	} {
+		name := Name("pss/handle", tc.keyvalues...)
+		if name != tc.name {
+			t.Fatalf("got name %q, want %q", name, tc.name)
+		}
+		base, labels := Split(name)
+		if base != "pss/handle" {
+			t.Fatalf("got name %q, want %q", base, "pss/handle")
+		}
+		if !reflect.DeepEqual(labels, tc.labels) {
+			t.Fatalf("got labels %v, want %v", labels, tc.labels)
+		}
+	}
+}
+
+// TestUnregister tests that only the metrics with the prefix and the label are unregistered
+func TestUnregister(t *testing.T) {
+	r := metrics.NewRegistry()
+	peer := Peer([]byte{1, 2})
+	for _, name := range []string{
+		Name("network/retrieve/peer/delivery", PeerKey, peer),
+		Name("network/retrieve/peer/delivery", PeerKey, Peer([]byte{3, 4})),
+		Name("network/stream/peer/delivery", PeerKey, peer),
+		"network/retrieve/delivery",
+	} {
+		metrics.GetOrRegisterCounter(name, r).Inc(1)
+	}
+	Unregister(r, "network/retrieve/", PeerKey, peer)
+
+	var names []string
+	r.Each(func(name string, _ interface{}) {
+		names = append(names, name)
+	})
+	want := map[string]bool{
+		"network/retrieve/peer/delivery{peer=0304}": true,
+		"network/stream/peer/delivery{peer=0102}":   true,
+		"network/retrieve/delivery":                 true,
+	}
+	if len(names) != len(want) {
+		t.Fatalf("got metrics %v, want %v", names, want)
+	}
+	for _, name := range names {
+		if !want[name] {
+			t.Fatalf("got metric %q, want %v", name, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package prometheus exports the metrics of swarm in the Prometheus text format,
+// reporting the labels encoded in the names of the metrics as Prometheus labels
+// and histograms and timers as summaries.
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/metrics/labels"
+)
+
+// quantiles reported for histograms and timers
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// resettingTimerQuantiles are the quantiles reported for resetting timers,
+// which take percentages instead of fractions
+var resettingTimerQuantiles = []float64{50, 95, 99}
+
+// invalidNameChars matches the characters not allowed in Prometheus metric names
+var invalidNameChars = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+// Handler returns an HTTP handler which reports the metrics of the registries
+// in the Prometheus text format. The names of the metrics of every registry are
+// prefixed with the namespace the registry is mapped to.
+func Handler(registries map[string]metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := newCollector()
+		for namespace, reg := range registries {
+			reg.Each(func(name string, i interface{}) {
+				c.add(namespace, name, i)
+			})
+		}
+		var buf bytes.Buffer
+		c.write(&buf)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+		w.Write(buf.Bytes())
+	})
+}
+
+// family is a set of samples with the same metric name and type, which differ
+// in their labels
+type family struct {
+	typ     string
+	samples []string
+}
+
+// collector groups the samples of the metrics into families, as the type of
+// a metric name may only be reported once
+type collector struct {
+	families map[string]*family
+}
+
+func newCollector() *collector {
+	return &collector{
+		families: make(map[string]*family),
+	}
+}
+
+// add adds the samples of the metric with the given name to the collector
+func (c *collector) add(namespace, name string, i interface{}) {
+	name, lbls := labels.Split(name)
+	name = metricName(namespace, name)
+
+	switch m := i.(type) {
+	case metrics.Counter:
+		c.sample(name, "counter", "", lbls, "", m.Count())
+	case metrics.Gauge:
+		c.sample(name, "gauge", "", lbls, "", m.Snapshot().Value())
+	case metrics.GaugeFloat64:
+		c.sample(name, "gauge", "", lbls, "", m.Snapshot().Value())
+	case metrics.Meter:
+		c.sample(name, "counter", "", lbls, "", m.Snapshot().Count())
+	case metrics.Histogram:
+		ms := m.Snapshot()
+		c.summary(name, lbls, quantiles, ms.Percentiles(quantiles), ms.Sum(), ms.Count())
+	case metrics.Timer:
+		ms := m.Snapshot()
+		c.summary(name, lbls, quantiles, ms.Percentiles(quantiles), ms.Sum(), ms.Count())
+	case metrics.ResettingTimer:
+		ms := m.Snapshot()
+		values := ms.Values()
+		if len(values) == 0 {
+			return
+		}
+		var sum int64
+		for _, v := range values {
+			sum += v
+		}
+		fractions := make([]float64, len(resettingTimerQuantiles))
+		for i, q := range resettingTimerQuantiles {
+			fractions[i] = q / 100
+		}
+		ps := ms.Percentiles(resettingTimerQuantiles)
+		percentiles := make([]float64, len(ps))
+		for i, p := range ps {
+			percentiles[i] = float64(p)
+		}
+		c.summary(name, lbls, fractions, percentiles, sum, int64(len(values)))
+	default:
+		log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
+	}
+}
+
+// summary adds the samples of a summary, its quantiles, sum and count
+func (c *collector) summary(name string, lbls map[string]string, qs, values []float64, sum, count int64) {
+	for i, q := range qs {
+		c.sample(name, "summary", "", lbls, "quantile=\""+strconv.FormatFloat(q, 'f', -1, 64)+"\"", values[i])
+	}
+	c.sample(name, "summary", "_sum", lbls, "", sum)
+	c.sample(name, "summary", "_count", lbls, "", count)
+}
+
+// sample adds a sample to the metric family with the given name and type,
+// with the suffix appended to the name of the sample and the extra label
+// appended to its labels
+func (c *collector) sample(name, typ, suffix string, lbls map[string]string, extra string, value interface{}) {
+	f, ok := c.families[name]
+	if !ok {
+		f = &family{typ: typ}
+		c.families[name] = f
+	}
+	if f.typ != typ {
+		log.Warn("Prometheus metric reported with different types", "name", name, "type", f.typ, "other", typ)
+		return
+	}
+	f.samples = append(f.samples, fmt.Sprintf("%s%s%s %v", name, suffix, formatLabels(lbls, extra), value))
+}
+
+// write writes all families sorted by their names
+func (c *collector) write(buf *bytes.Buffer) {
+	names := make([]string, 0, len(c.families))
+	for name := range c.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := c.families[name]
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, f.typ)
+		sort.Strings(f.samples)
+		for _, s := range f.samples {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// metricName returns the Prometheus metric name of a swarm metric
+func metricName(namespace, name string) string {
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	return invalidNameChars.ReplaceAllString(name, "_")
+}
+
+// formatLabels returns the labels sorted by their names with the extra label
+// appended, in the Prometheus text format
+func formatLabels(lbls map[string]string, extra string) string {
+	if len(lbls) == 0 && extra == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(lbls)+1)
+	for k, v := range lbls {
+		pairs = append(pairs, invalidNameChars.ReplaceAllString(k, "_")+"="+strconv.Quote(v))
+	}
+	sort.Strings(pairs)
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/metrics/labels"
+)
+
+// TestHandler tests that the metrics are reported in families with a single
+// type line, the labels encoded in their names as Prometheus labels and
+// histograms and timers as summaries
+func TestHandler(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	reg := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("pss/send", reg).Inc(3)
+	metrics.GetOrRegisterCounter(labels.Name("pss/handle", "topic", "0x01020304"), reg).Inc(1)
+	metrics.GetOrRegisterCounter(labels.Name("pss/handle", "topic", "0x05060708"), reg).Inc(2)
+	metrics.GetOrRegisterGauge("localstore/gc.size", reg).Update(42)
+	h := metrics.GetOrRegisterHistogram("api/size", reg, metrics.NewUniformSample(100))
+	for i := int64(1); i <= 4; i++ {
+		h.Update(i)
+	}
+	metrics.GetOrRegisterResettingTimer("network/retrieve/time", reg).Update(time.Second)
+
+	accounting := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter(labels.Name("balance", "peer", "0102"), accounting).Inc(5)
+
+	rec := httptest.NewRecorder()
+	Handler(map[string]metrics.Registry{
+		"swarm":      reg,
+		"accounting": accounting,
+	}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(body)
+
+	for _, want := range []string{
+		"# TYPE accounting_balance counter\naccounting_balance{peer=\"0102\"} 5\n",
+		"# TYPE swarm_api_size summary\n",
+		"swarm_api_size_count 4\n",
+		"swarm_api_size_sum 10\n",
+		"swarm_api_size{quantile=\"0.5\"} 2.5\n",
+		"# TYPE swarm_localstore_gc_size gauge\nswarm_localstore_gc_size 42\n",
+		"# TYPE swarm_network_retrieve_time summary\n",
+		"swarm_network_retrieve_time_count 1\n",
+		"swarm_network_retrieve_time{quantile=\"0.99\"} 1e+09\n",
+		"# TYPE swarm_pss_handle counter\nswarm_pss_handle{topic=\"0x01020304\"} 1\nswarm_pss_handle{topic=\"0x05060708\"} 2\n",
+		"# TYPE swarm_pss_send counter\nswarm_pss_send 3\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "# TYPE swarm_pss_handle "); n != 1 {
+		t.Errorf("got %v type lines of swarm_pss_handle, want 1", n)
+	}
+}
@@ -215,3 +215,42 @@ func (c *Capabilities) Match(capsCompare *Capabilities) bool {
 	}
 	return true
 }
+
+// Has returns true if the capability with the given id is registered
+// and the bit at position idx is set in its vector
+func (c *Capabilities) Has(id CapabilityID, idx int) bool {
+	cap := c.Get(id)
+	if cap == nil || idx < 0 || idx > len(cap.Cap)-1 {
+		return false
+	}
+	return cap.Cap[idx]
+}
+
+// Negotiate returns the capabilities common to the receiver and the argument
+// Only capability ids present in both are included, and only the bits set in both are set
+// Capability vectors may grow with new versions of a module, so when the lengths differ
+// the bits beyond the shorter vector are considered unsupported by the peer with the older version
+func (c *Capabilities) Negotiate(other *Capabilities) *Capabilities {
+	result := NewCapabilities()
+	if other == nil {
+		return result
+	}
+	for _, cp := range c.Caps {
+		otherCp := other.Get(cp.Id)
+		if otherCp == nil {
+			continue
+		}
+		bitCount := len(cp.Cap)
+		if len(otherCp.Cap) < bitCount {
+			bitCount = len(otherCp.Cap)
+		}
+		common := NewCapability(cp.Id, bitCount)
+		for i := 0; i < bitCount; i++ {
+			if cp.Cap[i] && otherCp.Cap[i] {
+				common.Set(i)
+			}
+		}
+		result.Add(common)
+	}
+	return result
+}
@@ -228,3 +228,47 @@ func TestCapabilitiesQuery(t *testing.T) {
 	}
 
 }
+
+// TestCapabilitiesNegotiate tests that negotiation yields the capabilities common to both sides
+func TestCapabilitiesNegotiate(t *testing.T) {
+	local := NewCapabilities()
+	c1 := NewCapability(1, 3)
+	c1.Set(0)
+	c1.Set(1)
+	local.Add(c1)
+	c2 := NewCapability(42, 9)
+	c2.Set(2)
+	c2.Set(8)
+	local.Add(c2)
+
+	remote := NewCapabilities()
+	r1 := NewCapability(1, 3)
+	r1.Set(1)
+	r1.Set(2)
+	remote.Add(r1)
+	// older version of capability 42 with a shorter vector
+	r2 := NewCapability(42, 4)
+	r2.Set(2)
+	r2.Set(3)
+	remote.Add(r2)
+	r3 := NewCapability(666, 1)
+	r3.Set(0)
+	remote.Add(r3)
+
+	negotiated := local.Negotiate(remote)
+	if negotiated.Get(666) != nil {
+		t.Fatalf("expected capability only advertised by remote to be excluded, got %s", negotiated)
+	}
+	if s := negotiated.String(); s != "1:010,42:0010" {
+		t.Fatalf("expected negotiated capabilities '1:010,42:0010', got '%s'", s)
+	}
+	if !negotiated.Has(1, 1) {
+		t.Fatal("expected negotiated capability 1 to have bit 1 set")
+	}
+	if negotiated.Has(1, 0) || negotiated.Has(42, 8) || negotiated.Has(43, 0) {
+		t.Fatalf("unexpected bits set in negotiated capabilities %s", negotiated)
+	}
+	if n := local.Negotiate(nil); len(n.Caps) != 0 {
+		t.Fatalf("expected empty negotiation with nil capabilities, got %s", n)
+	}
+}
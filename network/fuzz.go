@@ -0,0 +1,50 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package network
+
+import (
+	"github.com/ethersphere/swarm/network/capability"
+)
+
+// FuzzBzzHandshake is the go-fuzz entry point of the decoding and the checks of
+// the bzz handshake received from peers, see protocols.Spec.FuzzMsg for the
+// format of the input
+func FuzzBzzHandshake(data []byte) int {
+	msg, err := BzzSpec.FuzzMsg(data)
+	if err != nil {
+		return 0
+	}
+	hs := msg.(*HandshakeMsg)
+	local := RandomBzzAddr().WithCapabilities(capability.NewCapabilities())
+	local.Capabilities.Add(newFullCapability())
+	b := &Bzz{
+		NetworkID: hs.NetworkID,
+		localAddr: local,
+	}
+	if err := b.checkHandshake(hs); err != nil {
+		return 0
+	}
+	// what is done with the address of the peer after a successful handshake
+	local.Capabilities.Negotiate(hs.Addr.Capabilities)
+	hs.Addr.ID()
+	hs.Addr.IsStorer()
+	hs.Addr.IsRetrieveRelay()
+	_ = hs.String()
+	return 1
+}
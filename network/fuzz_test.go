@@ -0,0 +1,63 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package network
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+var fuzzCorpus = flag.String("fuzz.corpus", "", "directory to write the corpus seeds of the fuzzers to")
+
+// TestFuzzBzzHandshakeCorpus checks the seeds of FuzzBzzHandshake, the handshakes
+// of the protocol tests, and writes them to the corpus directory if it is given
+// with the fuzz.corpus flag
+func TestFuzzBzzHandshakeCorpus(t *testing.T) {
+	relayCaps := capability.NewCapabilities()
+	relayCaps.Add(newRelayCapability())
+	relay := &HandshakeMsg{
+		Version:   TestProtocolVersion,
+		NetworkID: TestProtocolNetworkID,
+		Addr:      RandomBzzAddr().WithCapabilities(relayCaps),
+	}
+
+	var seeds [][]byte
+	for _, msg := range []*HandshakeMsg{
+		correctBzzHandshake(RandomBzzAddr(), false),
+		correctBzzHandshake(RandomBzzAddr(), true),
+		relay,
+	} {
+		seed, err := BzzSpec.FuzzSeed(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FuzzBzzHandshake(seed) != 1 {
+			t.Fatalf("seed of handshake %v rejected", msg)
+		}
+		seeds = append(seeds, seed)
+	}
+	if *fuzzCorpus != "" {
+		if err := protocols.WriteFuzzCorpus(*fuzzCorpus, seeds...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package network
 import (
 	"context"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -27,12 +28,17 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/network/pubsubchannel"
 	"github.com/ethersphere/swarm/state"
 )
 
 const connectionsKey = "conns"
 const addressesKey = "peers"
 
+// staticPeerDialInterval is the time after which a static peer that did
+// not connect is dialed again
+var staticPeerDialInterval = 10 * time.Second
+
 /*
 Hive is the logistic manager of the swarm
 
@@ -48,6 +54,10 @@ type HiveParams struct {
 	PeersBroadcastSetSize uint8 // how many peers to use when relaying
 	MaxPeersPerRequest    uint8 // max size for peer address batches
 	KeepAliveInterval     time.Duration
+	StaticPeers           map[string][]string // enode URLs of the static peers by role, e.g. "bootnode", "relay"
+	StaticPeerTargets     map[string]int      // number of static peers of a role to keep connected, all of them if not set
+	IsolationTimeout      time.Duration       // time without peers or with depth 0 after which bootnodes and known peers are dialed again, disabled if zero
+	TopicDiscovery        bool                // advertise and search the bzz topics with discovery v5, if the server runs it
 }
 
 // NewHiveParams returns hive config with only the
@@ -57,6 +67,8 @@ func NewHiveParams() *HiveParams {
 		PeersBroadcastSetSize: 3,
 		MaxPeersPerRequest:    5,
 		KeepAliveInterval:     500 * time.Millisecond,
+		IsolationTimeout:      2 * time.Minute,
+		TopicDiscovery:        true,
 	}
 }
 
@@ -66,12 +78,23 @@ type Hive struct {
 	*Kademlia                     // the overlay connectiviy driver
 	Store       state.Store       // storage interface to save peers across sessions
 	addPeer     func(*enode.Node) // server callback to connect to a peer
+	server      *p2p.Server       // server the hive runs on, nil in tests
 	// bookkeeping
 	lock    sync.Mutex
 	peers   map[enode.ID]*BzzPeer
+	static  []*staticPeer // static peers maintained independently of the kademlia suggestions
 	ticker  *time.Ticker
 	done    chan struct{}
 	started bool
+
+	isolation       isolation                    // isolation state, only accessed by the connect loop
+	isolationPubSub *pubsubchannel.PubSubChannel // signals isolation recovery attempts
+
+	reachMtx     sync.Mutex
+	reachNonce   uint64                          // nonce of the last reachability request
+	reachPending map[uint64]*reachabilityRequest // reachability requests waiting for a response by nonce
+	dialUnderlay func([]byte, net.Addr) error    // dials back the underlay address of a peer connected from the remote address
+	removePeer   func(*enode.Node)               // server callback to disconnect a peer and stop dialing it, nil in tests
 }
 
 // NewHive constructs a new hive
@@ -80,10 +103,13 @@ type Hive struct {
 // StateStore: to save peers across sessions
 func NewHive(params *HiveParams, kad *Kademlia, store state.Store) *Hive {
 	return &Hive{
-		HiveParams: params,
-		Kademlia:   kad,
-		Store:      store,
-		peers:      make(map[enode.ID]*BzzPeer),
+		HiveParams:      params,
+		Kademlia:        kad,
+		Store:           store,
+		peers:           make(map[enode.ID]*BzzPeer),
+		reachPending:    make(map[uint64]*reachabilityRequest),
+		dialUnderlay:    dialUnderlay,
+		isolationPubSub: pubsubchannel.New(10),
 	}
 }
 
@@ -101,6 +127,13 @@ func (h *Hive) start(server *p2p.Server, addPeerFunc func(*enode.Node)) error {
 	log.Info("Starting hive", "baseaddr", fmt.Sprintf("%x", h.BaseAddr()[:4]))
 	// assigns the p2p.Server#AddPeer function to connect to peers
 	h.addPeer = addPeerFunc
+	h.server = server
+	if server != nil {
+		h.removePeer = server.RemovePeer
+	}
+	if err := h.loadStaticPeers(); err != nil {
+		return err
+	}
 	// if state store is specified, load peers to prepopulate the overlay address book
 	if h.Store != nil {
 		log.Info("Detected an existing store. trying to load peers")
@@ -114,7 +147,8 @@ func (h *Hive) start(server *p2p.Server, addPeerFunc func(*enode.Node)) error {
 	// done channel to signal the connect goroutine to return after Stop
 	h.done = make(chan struct{})
 	// this loop is doing bootstrapping and maintains a healthy table
-	if !h.DisableAutoConnect {
+	// as well as the connections to the static peers
+	if !h.DisableAutoConnect || len(h.static) > 0 {
 		go h.connect()
 	}
 	h.started = true
@@ -158,7 +192,13 @@ func (h *Hive) connect() {
 	for {
 		select {
 		case <-h.ticker.C:
-			h.tickHive()
+			if !h.DisableAutoConnect {
+				h.tickHive()
+				if h.IsolationTimeout > 0 {
+					h.checkIsolation(time.Now())
+				}
+			}
+			h.connectStaticPeers()
 		case <-h.done:
 			return
 		}
@@ -184,6 +224,137 @@ func (h *Hive) tickHive() {
 	}
 }
 
+// ConnectInRange dials at most max known peers that have proximity order po or
+// more to target, as suggested by SuggestPeersInRange, and returns the number
+// of peers dialed
+// It lets higher layers fill under-populated parts of the address space without
+// waiting for the hive to suggest peers on its ticks
+func (h *Hive) ConnectInRange(target []byte, po int, max int) int {
+	if h.addPeer == nil {
+		return 0
+	}
+	var dialed int
+	for _, addr := range h.SuggestPeersInRange(target, po, max) {
+		under, err := enode.ParseV4(string(addr.Under()))
+		if err != nil {
+			log.Warn(fmt.Sprintf("%08x unable to connect to bee %08x: invalid node URL: %v", h.BaseAddr()[:4], addr.Address()[:4], err))
+			continue
+		}
+		log.Trace(fmt.Sprintf("%08x attempt to connect to bee %08x in range of %x", h.BaseAddr()[:4], addr.Address()[:4], target))
+		h.addPeer(under)
+		dialed++
+	}
+	return dialed
+}
+
+// staticPeer is a node from HiveParams.StaticPeers
+type staticPeer struct {
+	node   *enode.Node
+	role   string
+	dialed time.Time // last time the node was dialed
+	added  bool      // added to the peers the server keeps dialing
+}
+
+// loadStaticPeers parses the enode URLs of the static peers
+func (h *Hive) loadStaticPeers() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.static = nil
+	for role, urls := range h.StaticPeers {
+		for _, url := range urls {
+			node, err := enode.ParseV4(url)
+			if err != nil {
+				return fmt.Errorf("invalid %s static peer %q: %v", role, url, err)
+			}
+			h.static = append(h.static, &staticPeer{
+				node: node,
+				role: role,
+			})
+		}
+	}
+	return nil
+}
+
+// connectStaticPeers dials static peers of every role that has fewer peers
+// connected or being dialed than its target, regardless of the number of
+// peers suggested by kademlia
+// The server keeps dialing the peers added to it, so at most the target number
+// of peers of a role are added, the static peers connected beyond the target are
+// removed from the server, which disconnects them, and so are the peers being
+// dialed beyond the target and the peers that did not connect within
+// staticPeerDialInterval, which may be dialed again
+func (h *Hive) connectStaticPeers() {
+	now := time.Now()
+	var dial, remove []*staticPeer
+
+	h.lock.Lock()
+	for role, peers := range h.staticPeersByRole() {
+		target, ok := h.StaticPeerTargets[role]
+		if !ok {
+			target = len(peers)
+		}
+		var candidates, pending []*staticPeer
+		var connected int
+		for _, sp := range peers {
+			if _, ok := h.peers[sp.node.ID()]; ok {
+				connected++
+				if connected > target {
+					log.Debug(fmt.Sprintf("%08x disconnecting static peer %s above the target", h.BaseAddr()[:4], sp.node.ID().TerminalString()), "role", role)
+					remove = append(remove, sp)
+				}
+				continue
+			}
+			if sp.added {
+				if now.Sub(sp.dialed) < staticPeerDialInterval {
+					// dialed recently
+					pending = append(pending, sp)
+					continue
+				}
+				remove = append(remove, sp)
+			}
+			candidates = append(candidates, sp)
+		}
+		slots := target - connected
+		for i, sp := range pending {
+			if i >= slots {
+				remove = append(remove, sp)
+			}
+		}
+		for i := 0; i < slots-len(pending) && i < len(candidates); i++ {
+			dial = append(dial, candidates[i])
+		}
+	}
+	for _, sp := range remove {
+		sp.added = false
+	}
+	for _, sp := range dial {
+		sp.dialed = now
+		sp.added = true
+	}
+	h.lock.Unlock()
+
+	for _, sp := range remove {
+		if h.removePeer != nil {
+			h.removePeer(sp.node)
+		}
+	}
+	for _, sp := range dial {
+		log.Trace(fmt.Sprintf("%08x attempt to connect to static peer %s", h.BaseAddr()[:4], sp.node.ID().TerminalString()), "role", sp.role)
+		h.addPeer(sp.node)
+	}
+}
+
+// staticPeersByRole returns the static peers grouped by their role
+// the caller is expected to hold the lock
+func (h *Hive) staticPeersByRole() map[string][]*staticPeer {
+	roles := make(map[string][]*staticPeer)
+	for _, sp := range h.static {
+		roles[sp.role] = append(roles[sp.role], sp)
+	}
+	return roles
+}
+
 // Run protocol run function
 func (h *Hive) Run(p *BzzPeer) error {
 	h.trackPeer(p)
@@ -243,6 +414,32 @@ func (h *Hive) PeerInfo(id enode.ID) interface{} {
 	}
 }
 
+// PeerCapabilities is the RPC representation of the capabilities of a connected peer
+type PeerCapabilities struct {
+	Advertised string // capabilities advertised by the peer in the handshake
+	Negotiated string // capabilities common to both nodes
+}
+
+// PeerCapabilities returns the advertised and negotiated capabilities
+// of all connected peers keyed by their hex encoded overlay address
+func (h *Hive) PeerCapabilities() map[string]PeerCapabilities {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	caps := make(map[string]PeerCapabilities, len(h.peers))
+	for _, p := range h.peers {
+		var advertised string
+		if p.Capabilities != nil {
+			advertised = p.Capabilities.String()
+		}
+		caps[hexutil.Encode(p.Over())] = PeerCapabilities{
+			Advertised: advertised,
+			Negotiated: p.NegotiatedCapabilities().String(),
+		}
+	}
+	return caps
+}
+
 // Peer returns a bzz peer from the Hive. If there is no peer
 // with the provided enode id, a nil value is returned.
 func (h *Hive) Peer(id enode.ID) *BzzPeer {
@@ -341,6 +538,10 @@ func (h *Hive) handleMsg(p *Peer) func(context.Context, interface{}) error {
 			return h.handlePeersMsg(p, msg)
 		case *subPeersMsg:
 			return h.handleSubPeersMsg(ctx, p, msg)
+		case *reachabilityRequestMsg:
+			return h.handleReachabilityRequestMsg(p, msg)
+		case *reachabilityResponseMsg:
+			return h.handleReachabilityResponseMsg(p, msg)
 		}
 
 		return fmt.Errorf("unknown message type: %T", msg)
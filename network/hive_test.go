@@ -17,7 +17,9 @@
 package network
 
 import (
+	"crypto/ecdsa"
 	"io/ioutil"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -26,8 +28,10 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network/capability"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/pot"
 	"github.com/ethersphere/swarm/state"
@@ -296,6 +300,288 @@ func TestHiveStateConnections(t *testing.T) {
 	})
 }
 
+// TestHiveStaticPeers checks that the static peers of each role are dialed
+// up to the target count of the role, counting connected and pending peers,
+// and that the peers connected above the target are disconnected
+func TestHiveStaticPeers(t *testing.T) {
+	defer func(d time.Duration) { staticPeerDialInterval = d }(staticPeerDialInterval)
+
+	newNode := func() *enode.Node {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enode.NewV4(&key.PublicKey, net.IPv4(127, 0, 0, 1), 30303, 30303)
+	}
+	gateways := []*enode.Node{newNode(), newNode(), newNode()}
+	relay := newNode()
+
+	params := NewHiveParams()
+	params.StaticPeers = map[string][]string{
+		"gateway": {gateways[0].String(), gateways[1].String(), gateways[2].String()},
+		"relay":   {relay.String()},
+	}
+	params.StaticPeerTargets = map[string]int{
+		"gateway": 2,
+	}
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHive(params, NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams()), nil)
+	if err := h.loadStaticPeers(); err != nil {
+		t.Fatal(err)
+	}
+	dialed := make(map[enode.ID]int)
+	h.addPeer = func(n *enode.Node) {
+		dialed[n.ID()]++
+	}
+	removed := make(map[enode.ID]int)
+	h.removePeer = func(n *enode.Node) {
+		removed[n.ID()]++
+	}
+	count := func(m map[enode.ID]int, nodes ...*enode.Node) (n int) {
+		for _, node := range nodes {
+			n += m[node.ID()]
+		}
+		return n
+	}
+	countDials := func(nodes ...*enode.Node) int {
+		return count(dialed, nodes...)
+	}
+
+	h.connectStaticPeers()
+	if n := countDials(gateways...); n != 2 {
+		t.Fatalf("expected 2 gateways dialed, got %d", n)
+	}
+	if n := countDials(relay); n != 1 {
+		t.Fatalf("expected relay dialed once, got %d", n)
+	}
+
+	// peers being dialed count towards the targets
+	h.connectStaticPeers()
+	if n := countDials(append(gateways, relay)...); n != 3 {
+		t.Fatalf("expected no more dials, got %d", n-3)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected no peers removed, got %d", len(removed))
+	}
+
+	// connected peers count towards the targets, the peers that did not connect are
+	// removed from the server, and only as many as the target allows are dialed again
+	staticPeerDialInterval = 0
+	h.peers[gateways[2].ID()] = &BzzPeer{}
+	h.connectStaticPeers()
+	if n := countDials(gateways...); n != 3 {
+		t.Fatalf("expected 3 gateway dials, got %d", n)
+	}
+	if n := count(removed, gateways...); n != 2 {
+		t.Fatalf("expected 2 gateways removed, got %d", n)
+	}
+	if n := dialed[gateways[2].ID()]; n != 0 {
+		t.Fatalf("expected connected gateway not to be dialed, got %d dials", n)
+	}
+	if n := countDials(relay); n != 2 {
+		t.Fatalf("expected relay dialed twice, got %d", n)
+	}
+
+	// static peers connected above the target are removed from the server, which disconnects them
+	for _, g := range gateways {
+		h.peers[g.ID()] = &BzzPeer{}
+	}
+	h.connectStaticPeers()
+	if n := countDials(gateways...); n != 3 {
+		t.Fatalf("expected no more gateway dials, got %d", n-3)
+	}
+	if n := count(removed, gateways...); n != 3 {
+		t.Fatalf("expected 1 more gateway removed, got %d", n-2)
+	}
+	if n := removed[gateways[2].ID()]; n != 1 {
+		t.Fatalf("expected the gateway above the target removed, got %d removals", n)
+	}
+
+	params.StaticPeers["relay"] = []string{"invalid"}
+	if err := h.loadStaticPeers(); err == nil {
+		t.Fatal("expected error for invalid static peer")
+	}
+}
+
+// TestHiveIsolation checks that an isolated hive dials the static peers and
+// twice as many known peers on every attempt, and signals the attempts and the recovery
+func TestHiveIsolation(t *testing.T) {
+	defer func(d time.Duration) { isolationMinInterval = d }(isolationMinInterval)
+	isolationMinInterval = 10 * time.Second
+
+	bootnode := RandomBzzAddr()
+	params := NewHiveParams()
+	params.IsolationTimeout = time.Minute
+	params.StaticPeers = map[string][]string{
+		"bootnode": {string(bootnode.Under())},
+	}
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kad := NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams())
+	h := NewHive(params, kad, nil)
+	if err := h.loadStaticPeers(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := h.Register(RandomBzzAddr()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var dialed []enode.ID
+	h.addPeer = func(n *enode.Node) {
+		dialed = append(dialed, n.ID())
+	}
+	sub := h.SubscribeToIsolation()
+	defer sub.Unsubscribe()
+	expectEvent := func(attempt int, recovered bool) {
+		t.Helper()
+		select {
+		case msg := <-sub.ReceiveChannel():
+			e := msg.(IsolationEvent)
+			if e.Attempt != attempt || e.Recovered != recovered {
+				t.Fatalf("expected isolation event of attempt %d recovered %v, got %+v", attempt, recovered, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for isolation event")
+		}
+	}
+
+	now := time.Now()
+	h.checkIsolation(now)
+	h.checkIsolation(now.Add(59 * time.Second))
+	if len(dialed) != 0 {
+		t.Fatalf("expected no dials before the isolation timeout, got %d", len(dialed))
+	}
+
+	h.checkIsolation(now.Add(61 * time.Second))
+	expectEvent(1, false)
+	if len(dialed) != 1+isolationDialBatch {
+		t.Fatalf("expected bootnode and %d known peers dialed, got %d dials", isolationDialBatch, len(dialed))
+	}
+	if dialed[0] != bootnode.ID() {
+		t.Fatalf("expected bootnode %v dialed first, got %v", bootnode.ID(), dialed[0])
+	}
+
+	// the next attempt is due after half the isolation timeout and dials twice as many known peers
+	dialed = nil
+	h.checkIsolation(now.Add(89 * time.Second))
+	if len(dialed) != 0 {
+		t.Fatalf("expected no dials before the next attempt, got %d", len(dialed))
+	}
+	h.checkIsolation(now.Add(91 * time.Second))
+	expectEvent(2, false)
+	if len(dialed) != 1+2*isolationDialBatch {
+		t.Fatalf("expected bootnode and %d known peers dialed, got %d dials", 2*isolationDialBatch, len(dialed))
+	}
+
+	// the number of known peers dialed is capped
+	dialed = nil
+	if n := h.recoverFromIsolation(100); n != 1+20 {
+		t.Fatalf("expected bootnode and 20 known peers dialed, got %d dials", n)
+	}
+
+	// once the retry budget is used, attempts are made every isolation timeout
+	h.isolation.attempt = isolationMaxAttempts
+	later := h.isolation.next
+	h.checkIsolation(later)
+	expectEvent(isolationMaxAttempts+1, false)
+	if want := later.Add(params.IsolationTimeout); !h.isolation.next.Equal(want) {
+		t.Fatalf("expected next attempt at %v, got %v", want, h.isolation.next)
+	}
+
+	// connected peers beyond depth 0 end the isolation
+	base := pot.NewAddressFromBytes(kad.BaseAddr())
+	for i := 0; i < 4; i++ {
+		kad.On(newTestDiscoveryPeer(pot.RandomAddressAt(base, i), kad))
+	}
+	h.peers[bootnode.ID()] = &BzzPeer{BzzAddr: bootnode}
+	h.checkIsolation(now.Add(92 * time.Second))
+	expectEvent(0, true)
+	if !h.isolation.since.IsZero() {
+		t.Fatal("expected hive not to be isolated")
+	}
+}
+
+// TestHiveTopicDiscovery tests the topics of the bzz capabilities, and that the nodes
+// found in topic searches are dialed only while the node needs peers, and not again
+// before the redial interval
+func TestHiveTopicDiscovery(t *testing.T) {
+	for c, want := range map[*capability.Capability]discv5.Topic{
+		fullCapability:  "bzz@4/full",
+		lightCapability: "bzz@4/light",
+		relayCapability: "bzz@4/relay",
+		capability.NewCapability(CapabilityID, 16): "",
+	} {
+		if topic := bzzCapabilityTopic(4, c); topic != want {
+			t.Fatalf("expected topic %q for capability %v, got %q", want, c, topic)
+		}
+	}
+
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kad := NewKademlia(PrivateKeyToBzzKey(prvkey), NewKadParams())
+	h := NewHive(NewHiveParams(), kad, nil)
+	var dialed []enode.ID
+	h.addPeer = func(n *enode.Node) {
+		dialed = append(dialed, n.ID())
+	}
+	self := enode.PubkeyToIDV4(&prvkey.PublicKey)
+	td := newTopicDiscovery(h, self, 4, lightCapability)
+	if len(td.advertise) != 2 || td.advertise[1] != "bzz@4/light" {
+		t.Fatalf("expected bzz and light topics advertised, got %v", td.advertise)
+	}
+
+	newNode := func(key *ecdsa.PrivateKey) *discv5.Node {
+		return discv5.NewNode(discv5.PubkeyID(&key.PublicKey), net.IP{127, 0, 0, 1}, 30399, 30399)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if td.found(newNode(prvkey), now) {
+		t.Fatal("expected the node itself not dialed")
+	}
+	if !td.found(newNode(key), now) {
+		t.Fatal("expected found node dialed")
+	}
+	if td.found(newNode(key), now.Add(topicRedialInterval/2)) {
+		t.Fatal("expected found node not dialed again before the redial interval")
+	}
+	if !td.found(newNode(key), now.Add(topicRedialInterval)) {
+		t.Fatal("expected found node dialed again after the redial interval")
+	}
+	if len(dialed) != 2 || dialed[0] != enode.PubkeyToIDV4(&key.PublicKey) {
+		t.Fatalf("expected found node dialed twice, got %v", dialed)
+	}
+
+	// connected peers are not dialed
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	h.peers[id] = &BzzPeer{BzzAddr: RandomBzzAddr()}
+	if td.found(newNode(key), now.Add(2*topicRedialInterval)) {
+		t.Fatal("expected connected peer not dialed")
+	}
+	delete(h.peers, id)
+
+	// no nodes are dialed once the node has enough peers beyond depth 0
+	base := pot.NewAddressFromBytes(kad.BaseAddr())
+	for i := 0; i < topicMinPeers; i++ {
+		addr := RandomBzzAddr()
+		h.peers[addr.ID()] = &BzzPeer{BzzAddr: addr}
+		kad.On(newTestDiscoveryPeer(pot.RandomAddressAt(base, i%4), kad))
+	}
+	if td.found(newNode(key), now.Add(2*topicRedialInterval)) {
+		t.Fatal("expected no dials with enough peers")
+	}
+}
+
 // Create a Peer with the suggested address and store the relationshsip enode -> BzzAddr for later retrieval
 func testAddPeer(suggestedPeer *BzzAddr, h1 *Hive, nodeIdToBzzAddr map[string]*BzzAddr) {
 	byteAddresses := suggestedPeer.Address()
@@ -0,0 +1,173 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network/pubsubchannel"
+)
+
+// isolationDialBatch is the number of known peers dialed by the first attempt
+// to recover from isolation, every further attempt dials twice as many
+// up to isolationDialBatch<<isolationMaxDialShift
+const isolationDialBatch = 4
+
+const isolationMaxDialShift = 6
+
+// isolationMaxAttempts is the number of attempts to recover from isolation with
+// shrinking intervals, the further attempts are made once every isolation timeout
+const isolationMaxAttempts = 8
+
+// isolationMinInterval is the shortest time between attempts to recover from isolation,
+// the time between attempts halves from the isolation timeout down to it
+var isolationMinInterval = 10 * time.Second
+
+// IsolationEvent is published by the hive on every attempt to recover from isolation,
+// i.e. having no connected peers or a neighbourhood depth of 0 for longer than
+// the isolation timeout, and once the node recovered
+type IsolationEvent struct {
+	Since     time.Time // time the node became isolated
+	Attempt   int       // number of the recovery attempt, zero once recovered
+	Dialed    int       // number of bootnodes, static peers and known peers dialed by the attempt
+	Peers     int       // number of connected peers
+	Depth     int       // neighbourhood depth
+	Recovered bool      // whether the node is not isolated anymore
+}
+
+// isolation is the state of the isolation of the node
+type isolation struct {
+	since   time.Time // time the node became isolated, zero if it is not isolated
+	attempt int       // number of recovery attempts since the node is isolated
+	next    time.Time // time of the next recovery attempt
+}
+
+// SubscribeToIsolation returns a subscription to the IsolationEvents of the hive
+func (h *Hive) SubscribeToIsolation() *pubsubchannel.Subscription {
+	return h.isolationPubSub.Subscribe()
+}
+
+// checkIsolation tracks whether the node is isolated and redials bootnodes,
+// static peers and known peers with escalating aggressiveness while it is
+// isolated for longer than the isolation timeout
+func (h *Hive) checkIsolation(now time.Time) {
+	h.lock.Lock()
+	peers := len(h.peers)
+	h.lock.Unlock()
+	depth := h.NeighbourhoodDepth()
+
+	if peers > 0 && depth > 0 {
+		if !h.isolation.since.IsZero() {
+			if h.isolation.attempt > 0 {
+				log.Info(fmt.Sprintf("%08x hive recovered from isolation", h.BaseAddr()[:4]), "since", h.isolation.since, "attempts", h.isolation.attempt, "peers", peers, "depth", depth)
+				metrics.GetOrRegisterCounter("network/hive/isolation/recovered", nil).Inc(1)
+				h.isolationPubSub.Publish(IsolationEvent{
+					Since:     h.isolation.since,
+					Peers:     peers,
+					Depth:     depth,
+					Recovered: true,
+				})
+			}
+			h.isolation = isolation{}
+		}
+		return
+	}
+	if h.isolation.since.IsZero() {
+		h.isolation = isolation{
+			since: now,
+			next:  now.Add(h.IsolationTimeout),
+		}
+		return
+	}
+	if now.Before(h.isolation.next) {
+		return
+	}
+
+	h.isolation.attempt++
+	interval := h.IsolationTimeout
+	if h.isolation.attempt < isolationMaxAttempts {
+		interval >>= uint(h.isolation.attempt)
+		if interval < isolationMinInterval {
+			interval = isolationMinInterval
+		}
+	}
+	h.isolation.next = now.Add(interval)
+
+	dialed := h.recoverFromIsolation(h.isolation.attempt)
+	log.Warn(fmt.Sprintf("%08x hive isolated, dialing bootnodes and known peers", h.BaseAddr()[:4]), "since", h.isolation.since, "attempt", h.isolation.attempt, "dialed", dialed, "peers", peers, "depth", depth)
+	metrics.GetOrRegisterCounter("network/hive/isolation/attempts", nil).Inc(1)
+	h.isolationPubSub.Publish(IsolationEvent{
+		Since:   h.isolation.since,
+		Attempt: h.isolation.attempt,
+		Dialed:  dialed,
+		Peers:   peers,
+		Depth:   depth,
+	})
+}
+
+// recoverFromIsolation dials the bootnodes of the server and the static peers
+// not connected on every attempt, as well as known peers not connected, twice
+// as many as on the previous attempt up to a limit, regardless of the number of
+// times they were retried
+// It returns the number of nodes dialed
+func (h *Hive) recoverFromIsolation(attempt int) int {
+	var nodes []*enode.Node
+	h.lock.Lock()
+	if h.server != nil {
+		for _, node := range h.server.BootstrapNodes {
+			if _, ok := h.peers[node.ID()]; ok {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	for _, sp := range h.static {
+		if _, ok := h.peers[sp.node.ID()]; ok {
+			continue
+		}
+		sp.dialed = time.Now()
+		nodes = append(nodes, sp.node)
+	}
+	h.lock.Unlock()
+
+	shift := attempt - 1
+	if shift > isolationMaxDialShift {
+		shift = isolationMaxDialShift
+	}
+	max := isolationDialBatch << uint(shift)
+	var known int
+	h.EachUnconnectedAddr(nil, 255, func(addr *BzzAddr, _ int) bool {
+		node, err := enode.ParseV4(string(addr.Under()))
+		if err != nil {
+			log.Warn(fmt.Sprintf("%08x unable to connect to bee %08x: invalid node URL: %v", h.BaseAddr()[:4], addr.Address()[:4], err))
+			return true
+		}
+		nodes = append(nodes, node)
+		known++
+		return known < max
+	})
+
+	for _, node := range nodes {
+		log.Trace(fmt.Sprintf("%08x attempt to connect to %s to recover from isolation", h.BaseAddr()[:4], node.ID().TerminalString()))
+		h.addPeer(node)
+	}
+	return len(nodes)
+}
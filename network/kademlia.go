@@ -173,9 +173,13 @@ func (k *Kademlia) addToCapabilityIndex(p interface{}) {
 			if vCap.IsSameAs(idxItem.Capability) {
 				log.Trace("Added peer to capability index", "conn", ok, "s", s, "v", vCap, "p", p)
 				if ok {
-					k.capabilityIndex[s].conns, _, _ = pot.Add(idxItem.conns, newEntryFromPeer(ePeer), Pof)
+					conns, _, _ := pot.Add(idxItem.conns, newEntryFromPeer(ePeer), Pof)
+					pot.Release(idxItem.conns, conns)
+					k.capabilityIndex[s].conns = conns
 				} else {
-					k.capabilityIndex[s].addrs, _, _ = pot.Add(idxItem.addrs, newEntryFromBzzAddress(eAddr), Pof)
+					addrs, _, _ := pot.Add(idxItem.addrs, newEntryFromBzzAddress(eAddr), Pof)
+					pot.Release(idxItem.addrs, addrs)
+					k.capabilityIndex[s].addrs = addrs
 				}
 			}
 		}
@@ -203,6 +207,7 @@ func (k *Kademlia) removeFromCapabilityIndex(p interface{}, disconnectOnly bool)
 			})
 			if found {
 				log.Trace("Removed peer from capability conns index", "s", s, "p", ePeer)
+				pot.Release(idxItem.conns, conns)
 				idxItem.conns = conns
 			}
 		}
@@ -212,6 +217,7 @@ func (k *Kademlia) removeFromCapabilityIndex(p interface{}, disconnectOnly bool)
 			})
 			if found {
 				log.Trace("Removed peer from capability addrs index", "s", s, "p", eAddr)
+				pot.Release(idxItem.addrs, addrs)
 				idxItem.addrs = addrs
 			}
 		}
@@ -296,7 +302,7 @@ func (k *Kademlia) Register(peers ...*BzzAddr) error {
 			return fmt.Errorf("add peers: %x is self", k.base)
 		}
 		index := k.defaultIndex
-		index.addrs, _, _, _ = pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
+		addrs, _, _, _ := pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
 			// if not found
 			if v == nil {
 				log.Trace("registering new peer", "addr", p)
@@ -315,6 +321,8 @@ func (k *Kademlia) Register(peers ...*BzzAddr) error {
 
 			return v
 		})
+		pot.Release(index.addrs, addrs)
+		index.addrs = addrs
 		k.addToCapabilityIndex(newEntryFromBzzAddress(p))
 		size++
 	}
@@ -507,8 +515,7 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 	var ins bool
 	index := k.defaultIndex
 	peerEntry := newEntryFromPeer(p)
-	var po int
-	index.conns, po, _, _ = pot.Swap(index.conns, peerEntry, Pof, func(v pot.Val) pot.Val {
+	conns, po, _, _ := pot.Swap(index.conns, peerEntry, Pof, func(v pot.Val) pot.Val {
 		// if not found live
 		if v == nil {
 			ins = true
@@ -518,6 +525,8 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 		// found among live peers, do nothing
 		return v
 	})
+	pot.Release(index.conns, conns)
+	index.conns = conns
 	k.addToCapabilityIndex(p)
 	// notify subscribers asynchronously
 	k.onOffPeerPubSub.Publish(onOffPeerSignal{peer: p, po: po, on: true})
@@ -526,9 +535,11 @@ func (k *Kademlia) On(p *Peer) (uint8, bool) {
 		a := newEntryFromBzzAddress(p.BzzAddr)
 		a.conn = p
 		// insert new online peer into addrs
-		index.addrs, _, _, _ = pot.Swap(index.addrs, a, Pof, func(v pot.Val) pot.Val {
+		addrs, _, _, _ := pot.Swap(index.addrs, a, Pof, func(v pot.Val) pot.Val {
 			return a
 		})
+		pot.Release(index.addrs, addrs)
+		index.addrs = addrs
 	}
 	// calculate if depth of saturation changed
 	depth := uint8(k.saturation())
@@ -637,18 +648,22 @@ func (k *Kademlia) Off(p *Peer) {
 	k.lock.Lock()
 	defer k.lock.Unlock()
 	index := k.defaultIndex
-	index.addrs, _, _, _ = pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
+	addrs, _, _, _ := pot.Swap(index.addrs, p, Pof, func(v pot.Val) pot.Val {
 		// v cannot be nil, must check otherwise we overwrite entry
 		if v == nil {
 			panic(fmt.Sprintf("connected peer not found %v", p))
 		}
 		return newEntryFromBzzAddress(p.BzzAddr)
 	})
+	pot.Release(index.addrs, addrs)
+	index.addrs = addrs
 	// note the following only ran if the peer was a lightnode
-	index.conns, _, _, _ = pot.Swap(index.conns, p, Pof, func(_ pot.Val) pot.Val {
+	conns, _, _, _ := pot.Swap(index.conns, p, Pof, func(_ pot.Val) pot.Val {
 		// v cannot be nil, but no need to check
 		return nil
 	})
+	pot.Release(index.conns, conns)
+	index.conns = conns
 	k.removeFromCapabilityIndex(p, true)
 	k.setNeighbourhoodDepth()
 	k.onOffPeerPubSub.Publish(onOffPeerSignal{peer: p, po: -1, on: false})
@@ -691,6 +706,23 @@ func (k *Kademlia) eachConn(base []byte, db *pot.Pot, o int, f func(*Peer, int)
 	})
 }
 
+// UpdateLatency adds a measured round trip time to the smoothed latency of
+// the connected peer with the overlay address, it returns false if there is
+// no such peer
+func (k *Kademlia) UpdateLatency(addr []byte, rtt time.Duration) (found bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	// the first neighbour of the address is the peer itself if it is connected
+	k.defaultIndex.conns.EachNeighbour(addr, Pof, func(val pot.Val, _ int) bool {
+		if p := val.(*entry).conn; bytes.Equal(p.Over(), addr) {
+			p.UpdateLatency(rtt)
+			found = true
+		}
+		return false
+	})
+	return found
+}
+
 //In order to clarify iterator functions, we have created several functions types to identify the purpose of each
 //param to those functions.
 
@@ -785,6 +817,70 @@ func (k *Kademlia) eachAddr(base []byte, db *pot.Pot, o int, f func(*BzzAddr, in
 	})
 }
 
+// EachUnconnectedAddrFiltered performs the same action as EachUnconnectedAddr
+// with the difference that it will only return peers that matches the specified capability index filter
+func (k *Kademlia) EachUnconnectedAddrFiltered(base []byte, capKey string, o int, f func(*BzzAddr, int) bool) error {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	c, ok := k.capabilityIndex[capKey]
+	if !ok {
+		return fmt.Errorf("Unregistered capability index '%s'", capKey)
+	}
+	k.eachUnconnectedAddr(base, c.addrs, o, f)
+	return nil
+}
+
+// EachUnconnectedAddr called with (base, po, f) is an iterator applying f to each known
+// but not connected peer that has proximity order o or less as measured from the base,
+// i.e. the addresses that can be dialed to fill the bins of base
+// if base is nil, kademlia base address is used
+func (k *Kademlia) EachUnconnectedAddr(base []byte, o int, f func(*BzzAddr, int) bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	k.eachUnconnectedAddr(base, k.defaultIndex.addrs, o, f)
+}
+
+func (k *Kademlia) eachUnconnectedAddr(base []byte, db *pot.Pot, o int, f func(*BzzAddr, int) bool) {
+	// the entries of the capability indexes do not record the connection,
+	// so connected peers are looked up among the live peers
+	connected := make(map[string]bool)
+	k.defaultIndex.conns.Each(func(val pot.Val) bool {
+		connected[string(val.(*entry).Address())] = true
+		return true
+	})
+	k.eachAddr(base, db, o, func(addr *BzzAddr, po int) bool {
+		if connected[string(addr.Address())] {
+			return true
+		}
+		return f(addr, po)
+	})
+}
+
+// SuggestPeersInRange returns at most max callable addresses of known but not connected
+// peers that have proximity order po or more to target, closest to target first
+// Higher layers use it to dial peers covering an address range proactively,
+// e.g. when the bin of a chunk is under-populated, instead of waiting for
+// SuggestPeer to fill the bin on the hive ticks
+// As with SuggestPeer, the suggested peers are counted as retried
+func (k *Kademlia) SuggestPeersInRange(target []byte, po int, max int) (suggested []*BzzAddr) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	metrics.GetOrRegisterCounter("kad/suggestpeersinrange", nil).Inc(1)
+
+	k.defaultIndex.addrs.EachNeighbour(target, Pof, func(val pot.Val, p int) bool {
+		if len(suggested) >= max || p < po {
+			return false
+		}
+		e := val.(*entry)
+		if k.callable(e) {
+			suggested = append(suggested, e.BzzAddr)
+		}
+		return true
+	})
+	return suggested
+}
+
 // neighbourhoodRadiusForPot returns the neighbourhood radius of the kademlia
 // neighbourhood radius encloses the nearest neighbour set with size >= neighbourhoodSize
 // i.e., neighbourhood radius is the deepest PO such that all bins not shallower altogether
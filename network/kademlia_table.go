@@ -0,0 +1,173 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/pot"
+)
+
+// Connectivity states of peers in the KademliaTable.
+const (
+	PeerStateConnected = "connected"
+	PeerStateKnown     = "known"
+)
+
+// KademliaTable is the full kademlia table of a node, with every known peer
+// in its proximity order bin. Nodes and Conns represent the same table as a
+// graph of the node and its peers, in the nodes and connections form used by
+// the simulation network visualizer, where nodes are identified by their
+// overlay addresses.
+type KademliaTable struct {
+	Self              string         `json:"self"`
+	Depth             int            `json:"depth"`
+	Saturation        int            `json:"saturation"`
+	NeighbourhoodSize int            `json:"neighbourhoodSize"`
+	MinBinSize        int            `json:"minBinSize"`
+	TotalConnections  int            `json:"totalConnections"`
+	TotalKnown        int            `json:"totalKnown"`
+	Bins              []KademliaBin  `json:"bins"`
+	Nodes             []KademliaNode `json:"nodes"`
+	Conns             []KademliaConn `json:"conns"`
+	Time              time.Time      `json:"time"`
+}
+
+// KademliaBin holds peers with the same proximity order to the node.
+type KademliaBin struct {
+	ProximityOrder int            `json:"po"`
+	Connected      int            `json:"connected"`
+	Known          int            `json:"known"`
+	Peers          []KademliaPeer `json:"peers"`
+}
+
+// KademliaPeer is a peer in the KademliaTable.
+type KademliaPeer struct {
+	Address      string        `json:"address"`
+	Underlay     string        `json:"underlay"`
+	ID           string        `json:"id,omitempty"`
+	Capabilities string        `json:"capabilities"`
+	State        string        `json:"state"`
+	LastSeen     time.Time     `json:"lastSeen"`
+	Retries      int           `json:"retries"`
+	Latency      time.Duration `json:"latency,omitempty"` // smoothed round trip time of connected peers, if measured
+}
+
+// KademliaNode is a node in the graph representation of the KademliaTable.
+type KademliaNode struct {
+	ID             string `json:"id"`
+	ProximityOrder int    `json:"po"`
+	Up             bool   `json:"up"`
+}
+
+// KademliaConn is a connection between the node and a
+// peer in the graph representation of the KademliaTable.
+type KademliaConn struct {
+	One   string `json:"one"`
+	Other string `json:"other"`
+	Up    bool   `json:"up"`
+}
+
+// Table returns the full kademlia table.
+func (k *Kademlia) Table() KademliaTable {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.table()
+}
+
+func (k *Kademlia) table() (t KademliaTable) {
+	t.Self = hex.EncodeToString(k.base)
+	t.Depth = depthForPot(k.defaultIndex.conns, k.NeighbourhoodSize, k.base)
+	t.Saturation = k.saturation()
+	t.NeighbourhoodSize = k.NeighbourhoodSize
+	t.MinBinSize = k.MinBinSize
+	t.TotalConnections = k.defaultIndex.conns.Size()
+	t.TotalKnown = k.defaultIndex.addrs.Size()
+	t.Bins = make([]KademliaBin, 0)
+	t.Nodes = []KademliaNode{
+		{
+			ID: t.Self,
+			Up: true,
+		},
+	}
+	t.Conns = make([]KademliaConn, 0)
+	t.Time = time.Now()
+
+	connected := make(map[string]*Peer)
+	k.defaultIndex.conns.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		bin.ValIterator(func(val pot.Val) bool {
+			e := val.(*entry)
+			connected[e.Hex()] = e.conn
+			return true
+		})
+		return true
+	}, true)
+
+	k.defaultIndex.addrs.EachBin(k.base, Pof, 0, func(bin *pot.Bin) bool {
+		b := KademliaBin{
+			ProximityOrder: bin.ProximityOrder,
+			Peers:          make([]KademliaPeer, 0, bin.Size),
+		}
+		bin.ValIterator(func(val pot.Val) bool {
+			e := val.(*entry)
+			p := KademliaPeer{
+				Address:  e.Hex(),
+				Underlay: string(e.Under()),
+				State:    PeerStateKnown,
+				LastSeen: e.seenAt,
+				Retries:  e.retries,
+			}
+			if id := e.ID(); id != (enode.ID{}) {
+				p.ID = id.String()
+			}
+			if e.Capabilities != nil {
+				p.Capabilities = e.Capabilities.String()
+			}
+			if conn, ok := connected[p.Address]; ok {
+				p.State = PeerStateConnected
+				p.Latency = conn.Latency()
+				b.Connected++
+			}
+			b.Known++
+			b.Peers = append(b.Peers, p)
+			return true
+		})
+		sort.Slice(b.Peers, func(i, j int) bool {
+			return b.Peers[i].Address < b.Peers[j].Address
+		})
+		for _, p := range b.Peers {
+			up := p.State == PeerStateConnected
+			t.Nodes = append(t.Nodes, KademliaNode{
+				ID:             p.Address,
+				ProximityOrder: b.ProximityOrder,
+				Up:             up,
+			})
+			t.Conns = append(t.Conns, KademliaConn{
+				One:   t.Self,
+				Other: p.Address,
+				Up:    up,
+			})
+		}
+		t.Bins = append(t.Bins, b)
+		return true
+	}, true)
+
+	return t
+}
@@ -17,6 +17,8 @@
 package network
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
@@ -583,6 +585,55 @@ func TestSuggestPeerRetries(t *testing.T) {
 	tk.checkSuggestPeer("<nil>", 0, false)
 }
 
+func TestEachUnconnectedAddr(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("01000000", "10000000", "00100000")
+	tk.On("00100000", "00010000")
+
+	var got []string
+	tk.EachUnconnectedAddr(nil, 255, func(addr *BzzAddr, po int) bool {
+		got = append(got, pot.ToBin(addr.Address())[:8])
+		return true
+	})
+	want := []string{"01000000", "10000000"}
+	if len(got) != len(want) {
+		t.Fatalf("got unconnected addresses %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got unconnected addresses %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSuggestPeersInRange(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("11000000", "11100000", "10000000", "01000000")
+	tk.On("11110000")
+
+	target := pot.NewAddressFromString("11111111")
+	check := func(max int, want ...string) {
+		t.Helper()
+		var got []string
+		for _, addr := range tk.SuggestPeersInRange(target, 2, max) {
+			got = append(got, pot.ToBin(addr.Address())[:8])
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got suggested peers %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("got suggested peers %v, want %v", got, want)
+			}
+		}
+	}
+	// the closest unconnected peer to the target
+	check(1, "11100000")
+	// the suggested peer is not callable again until its retry interval passes
+	check(2, "11000000")
+	check(2)
+}
+
 func TestKademliaHiveString(t *testing.T) {
 	tk := newTestKademlia(t, "00000000")
 	tk.On("01000000", "00100000")
@@ -716,6 +767,152 @@ func TestKademlia_SubscribeToNeighbourhoodDepthChange(t *testing.T) {
 	})
 }
 
+// TestKademliaTable checks bins, peer states and the graph
+// representation of the full kademlia table.
+func TestKademliaTable(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("10000000", "11000000", "01000000")
+	tk.On("10000000", "00100000")
+
+	table := tk.Table()
+
+	if table.Self != hex.EncodeToString(tk.BaseAddr()) {
+		t.Errorf("got self %s, want %x", table.Self, tk.BaseAddr())
+	}
+	if table.TotalConnections != 2 {
+		t.Errorf("got %v connections, want 2", table.TotalConnections)
+	}
+	if table.TotalKnown != 4 {
+		t.Errorf("got %v known peers, want 4", table.TotalKnown)
+	}
+
+	hexAddr := func(s string) string {
+		return hex.EncodeToString(testKadPeerAddr(s).Address())
+	}
+	type wantPeer struct {
+		addr  string
+		state string
+	}
+	for i, want := range []struct {
+		po    int
+		peers []wantPeer
+	}{
+		{
+			po: 0,
+			peers: []wantPeer{
+				{addr: hexAddr("10000000"), state: PeerStateConnected},
+				{addr: hexAddr("11000000"), state: PeerStateKnown},
+			},
+		},
+		{
+			po: 1,
+			peers: []wantPeer{
+				{addr: hexAddr("01000000"), state: PeerStateKnown},
+			},
+		},
+		{
+			po: 2,
+			peers: []wantPeer{
+				{addr: hexAddr("00100000"), state: PeerStateConnected},
+			},
+		},
+	} {
+		if i >= len(table.Bins) {
+			t.Fatalf("got %v bins, want more", len(table.Bins))
+		}
+		bin := table.Bins[i]
+		if bin.ProximityOrder != want.po {
+			t.Errorf("bin %v: got po %v, want %v", i, bin.ProximityOrder, want.po)
+		}
+		if len(bin.Peers) != len(want.peers) || bin.Known != len(want.peers) {
+			t.Fatalf("bin %v: got %v peers, want %v", i, len(bin.Peers), len(want.peers))
+		}
+		var connected int
+		for j, p := range bin.Peers {
+			if p.Address != want.peers[j].addr {
+				t.Errorf("bin %v peer %v: got address %s, want %s", i, j, p.Address, want.peers[j].addr)
+			}
+			if p.State != want.peers[j].state {
+				t.Errorf("bin %v peer %v: got state %s, want %s", i, j, p.State, want.peers[j].state)
+			}
+			if p.LastSeen.IsZero() {
+				t.Errorf("bin %v peer %v: last seen not set", i, j)
+			}
+			if p.State == PeerStateConnected {
+				connected++
+			}
+		}
+		if bin.Connected != connected {
+			t.Errorf("bin %v: got %v connected, want %v", i, bin.Connected, connected)
+		}
+	}
+	if len(table.Bins) != 3 {
+		t.Errorf("got %v bins, want 3", len(table.Bins))
+	}
+
+	if len(table.Nodes) != 5 {
+		t.Fatalf("got %v nodes, want 5", len(table.Nodes))
+	}
+	if table.Nodes[0].ID != table.Self || !table.Nodes[0].Up {
+		t.Errorf("got first node %+v, want self", table.Nodes[0])
+	}
+	if len(table.Conns) != 4 {
+		t.Fatalf("got %v conns, want 4", len(table.Conns))
+	}
+	var up int
+	for _, c := range table.Conns {
+		if c.One != table.Self {
+			t.Errorf("got conn from %s, want from self", c.One)
+		}
+		if c.Up {
+			up++
+		}
+	}
+	if up != 2 {
+		t.Errorf("got %v conns up, want 2", up)
+	}
+
+	if _, err := json.Marshal(table); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestKademliaUpdateLatency tests that the round trip times measured for a connected
+// peer are smoothed into its latency, which is reported in the kademlia table
+func TestKademliaUpdateLatency(t *testing.T) {
+	tk := newTestKademlia(t, "00000000")
+	tk.Register("01000000")
+	peer := tk.newTestKadPeer("10000000")
+	tk.Kademlia.On(peer)
+	tk.On("11000000")
+
+	if tk.UpdateLatency(testKadPeerAddr("01000000").Address(), time.Second) {
+		t.Fatal("expected no latency update of a peer that is not connected")
+	}
+	addr := peer.Address()
+	for _, rtt := range []time.Duration{800 * time.Millisecond, 1600 * time.Millisecond} {
+		if !tk.UpdateLatency(addr, rtt) {
+			t.Fatal("expected latency update of connected peer")
+		}
+	}
+	// the first measurement is taken as is, the next ones are weighted by 1/8
+	if want := 900 * time.Millisecond; peer.Latency() != want {
+		t.Fatalf("got latency %v, want %v", peer.Latency(), want)
+	}
+
+	for _, bin := range tk.Table().Bins {
+		for _, p := range bin.Peers {
+			want := time.Duration(0)
+			if p.Address == hex.EncodeToString(addr) {
+				want = peer.Latency()
+			}
+			if p.Latency != want {
+				t.Errorf("peer %s: got latency %v in table, want %v", p.Address, p.Latency, want)
+			}
+		}
+	}
+}
+
 // TestCapabilitiesIndex checks that capability indices contains only the peers that have the filters' capability bits set
 // It tests the state of the indices after registering, connecting, disconnecting and removing peers
 //
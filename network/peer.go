@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -28,6 +30,7 @@ import (
 
 // Peer wraps BzzPeer and embeds Kademlia overlay connectivity driver
 type Peer struct {
+	latency int64 // smoothed round trip time in nanoseconds, 0 if not measured, first for 64-bit alignment
 	*BzzPeer
 	kad       *Kademlia
 	sentPeers bool            // whether we already sent peer closer to this address
@@ -35,6 +38,8 @@ type Peer struct {
 	peers     map[string]bool // tracks node records sent to the peer
 	depth     uint8           // the proximity order advertised by remote as depth of saturation
 	key       string          // peer key. Hex form of Address()
+
+	dialingBack int32 // set while the underlay address of the peer is dialed for a reachability test
 }
 
 // NewPeer constructs a discovery peer
@@ -55,6 +60,32 @@ func (d *Peer) Key() string {
 	return d.key
 }
 
+// latencySmoothing is the inverse of the weight of a new round trip time
+// measurement in the smoothed latency of a peer, as in the TCP SRTT
+const latencySmoothing = 8
+
+// UpdateLatency adds a measured round trip time to the smoothed latency of the peer
+func (d *Peer) UpdateLatency(rtt time.Duration) {
+	if rtt <= 0 {
+		rtt = 1
+	}
+	for {
+		old := atomic.LoadInt64(&d.latency)
+		latency := int64(rtt)
+		if old != 0 {
+			latency = old + (int64(rtt)-old)/latencySmoothing
+		}
+		if atomic.CompareAndSwapInt64(&d.latency, old, latency) {
+			return
+		}
+	}
+}
+
+// Latency returns the smoothed round trip time of the peer, 0 if it was never measured
+func (d *Peer) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.latency))
+}
+
 // Label returns a short string representation for debugging purposes
 func (d *Peer) Label() string {
 	return d.key[:4]
@@ -44,6 +44,7 @@ var (
 	// temporary presets to emulate the legacy LightNode/full node regime
 	fullCapability  *capability.Capability
 	lightCapability *capability.Capability
+	relayCapability *capability.Capability
 )
 
 const (
@@ -56,9 +57,11 @@ var DefaultTestNetworkID = rand.Uint64()
 
 // BzzSpec is the spec of the generic swarm handshake
 var BzzSpec = &protocols.Spec{
-	Name:       "bzz",
-	Version:    14,
-	MaxMsgSize: 10 * 1024 * 1024,
+	Name:             "bzz",
+	Version:          14,
+	MaxMsgSize:       10 * 1024 * 1024,
+	HandshakeTimeout: bzzHandshakeTimeout,
+	MaxHandshakeSize: 4 * 1024,
 	Messages: []interface{}{
 		HandshakeMsg{},
 	},
@@ -67,11 +70,21 @@ var BzzSpec = &protocols.Spec{
 // DiscoverySpec is the spec for the bzz discovery subprotocols
 var DiscoverySpec = &protocols.Spec{
 	Name:       "hive",
-	Version:    11,
+	Version:    12,
 	MaxMsgSize: 10 * 1024 * 1024,
 	Messages: []interface{}{
 		peersMsg{},
 		subPeersMsg{},
+		reachabilityRequestMsg{},
+		reachabilityResponseMsg{},
+	},
+	Lanes: map[protocols.Lane][]interface{}{
+		protocols.LaneControl: {
+			peersMsg{},
+			subPeersMsg{},
+			reachabilityRequestMsg{},
+			reachabilityResponseMsg{},
+		},
 	},
 }
 
@@ -79,6 +92,7 @@ var DiscoverySpec = &protocols.Spec{
 func init() {
 	fullCapability = newFullCapability()
 	lightCapability = newLightCapability()
+	relayCapability = newRelayCapability()
 }
 
 // temporary convenience functions for legacy "LightNode"
@@ -92,6 +106,17 @@ func isLightCapability(c *capability.Capability) bool {
 	return lightCapability.IsSameAs(c)
 }
 
+// light node that does not store chunks, but relays the retrieve requests
+// of its peers, so that gateways behind it can be served
+func newRelayCapability() *capability.Capability {
+	c := newLightCapability()
+	c.Set(capabilitiesRelayRetrieve)
+	return c
+}
+func isRelayCapability(c *capability.Capability) bool {
+	return relayCapability.IsSameAs(c)
+}
+
 // temporary convenience functions for legacy "full node"
 func newFullCapability() *capability.Capability {
 	c := capability.NewCapability(CapabilityID, 16)
@@ -107,14 +132,36 @@ func isFullCapability(c *capability.Capability) bool {
 	return fullCapability.IsSameAs(c)
 }
 
+// IsStorer returns true if the address advertises the storer capability
+// Subsystems that sync chunks to a peer should consult it before engaging the peer
+func (a *BzzAddr) IsStorer() bool {
+	return a.hasBzzCapability(capabilitiesStorer)
+}
+
+// IsRetrieveRelay returns true if the address advertises that it relays retrieve requests
+// Retrieval should only forward requests to peers with this capability
+func (a *BzzAddr) IsRetrieveRelay() bool {
+	return a.hasBzzCapability(capabilitiesRelayRetrieve)
+}
+
+// hasBzzCapability checks the bit idx of the bzz capability of the address
+// addresses not advertising the bzz capability at all are treated as legacy full nodes
+func (a *BzzAddr) hasBzzCapability(idx int) bool {
+	if a.Capabilities == nil || a.Capabilities.Get(CapabilityID) == nil {
+		return true
+	}
+	return a.Capabilities.Has(CapabilityID, idx)
+}
+
 // BzzConfig captures the config params used by the hive
 type BzzConfig struct {
-	Address      *BzzAddr
-	HiveParams   *HiveParams
-	NetworkID    uint64
-	LightNode    bool // temporarily kept as we still only define light/full on operational level
-	BootnodeMode bool
-	SyncEnabled  bool
+	Address       *BzzAddr
+	HiveParams    *HiveParams
+	NetworkID     uint64
+	LightNode     bool // temporarily kept as we still only define light/full on operational level
+	RetrieveRelay bool // light node relays the retrieve requests of its peers, full nodes always do
+	BootnodeMode  bool
+	SyncEnabled   bool
 }
 
 // Bzz is the swarm protocol bundle
@@ -128,6 +175,7 @@ type Bzz struct {
 	streamerRun   func(*BzzPeer) error
 	retrievalSpec *protocols.Spec
 	retrievalRun  func(*BzzPeer) error
+	topics        *topicDiscovery // nil if topic discovery is not running
 }
 
 // NewBzz is the swarm protocol constructor
@@ -161,7 +209,9 @@ func NewBzz(config *BzzConfig, kad *Kademlia, store state.Store, streamerSpec, r
 
 	bzz.localAddr.Capabilities = kad.Capabilities
 	// temporary soon-to-be-legacy light/full, as above
-	if config.LightNode {
+	if config.LightNode && config.RetrieveRelay {
+		bzz.localAddr.Capabilities.Add(newRelayCapability())
+	} else if config.LightNode {
 		bzz.localAddr.Capabilities.Add(newLightCapability())
 	} else {
 		bzz.localAddr.Capabilities.Add(newFullCapability())
@@ -170,8 +220,26 @@ func NewBzz(config *BzzConfig, kad *Kademlia, store state.Store, streamerSpec, r
 	return bzz
 }
 
+// Start starts the hive, and the topic discovery if it is enabled
+// and the server runs discovery v5
+// Implements node.Service
+func (b *Bzz) Start(server *p2p.Server) error {
+	if err := b.Hive.Start(server); err != nil {
+		return err
+	}
+	if b.TopicDiscovery && !b.DisableAutoConnect && server.DiscV5 != nil {
+		b.topics = newTopicDiscovery(b.Hive, server.Self().ID(), b.NetworkID, b.localAddr.Capabilities.Get(CapabilityID))
+		b.topics.start(server.DiscV5)
+	}
+	return nil
+}
+
 // Stop Implements node.Service
 func (b *Bzz) Stop() error {
+	if b.topics != nil {
+		b.topics.stop()
+		b.topics = nil
+	}
 	return b.Hive.Stop()
 }
 
@@ -214,26 +282,56 @@ func (b *Bzz) Protocols() []p2p.Protocol {
 		},
 	}
 	if b.streamerSpec != nil && b.streamerRun != nil {
-		protocol = append(protocol, p2p.Protocol{
-			Name:    b.streamerSpec.Name,
-			Version: b.streamerSpec.Version,
-			Length:  b.streamerSpec.Length(),
-			Run:     b.RunProtocol(b.streamerSpec, b.streamerRun),
-		})
+		protocol = append(protocol, b.specProtocols(b.streamerSpec, b.streamerRun)...)
 	}
 	if b.retrievalSpec != nil && b.retrievalRun != nil {
+		protocol = append(protocol, b.specProtocols(b.retrievalSpec, b.retrievalRun)...)
+	}
+	return protocol
+}
+
+// specProtocols returns a protocol for each version supported by the spec
+// devp2p runs the highest version supported by both peers, so peers running
+// different versions in the supported range keep peering during upgrades
+func (b *Bzz) specProtocols(spec *protocols.Spec, run func(*BzzPeer) error) []p2p.Protocol {
+	var protocol []p2p.Protocol
+	for _, v := range spec.Versions() {
 		protocol = append(protocol, p2p.Protocol{
-			Name:    b.retrievalSpec.Name,
-			Version: b.retrievalSpec.Version,
-			Length:  b.retrievalSpec.Length(),
-			Run:     b.RunProtocol(b.retrievalSpec, b.retrievalRun),
+			Name:    spec.Name,
+			Version: v,
+			Length:  spec.Length(),
+			Run:     b.RunProtocol(spec, run),
 		})
 	}
 	return protocol
 }
 
+// checkSpecVersions warns about the subprotocols the peer advertises in versions
+// none of which are supported, as devp2p does not run them at all
+func (b *Bzz) checkSpecVersions(p *p2p.Peer) {
+	for _, spec := range []*protocols.Spec{b.streamerSpec, b.retrievalSpec} {
+		if spec == nil {
+			continue
+		}
+		var advertised []uint
+		for _, c := range p.Caps() {
+			if c.Name == spec.Name {
+				advertised = append(advertised, c.Version)
+			}
+		}
+		if len(advertised) == 0 {
+			continue
+		}
+		if _, ok := spec.Negotiate(p.Caps()); !ok {
+			versions := spec.Versions()
+			log.Warn(fmt.Sprintf("%08x: no common %s protocol version with peer %08x", b.localAddr.Over()[:4], spec.Name, p.ID().Bytes()[:4]), "supported", fmt.Sprintf("%d-%d", versions[len(versions)-1], versions[0]), "peer", advertised)
+		}
+	}
+}
+
 // APIs returns the APIs offered by bzz
 // * hive
+// * capabilities and reachability
 // Bzz implements the node.Service interface
 func (b *Bzz) APIs() []rpc.API {
 	return []rpc.API{
@@ -247,6 +345,11 @@ func (b *Bzz) APIs() []rpc.API {
 			Version:   "4.0",
 			Service:   capability.NewAPI(b.Kademlia.Capabilities),
 		},
+		{
+			Namespace: "bzz",
+			Version:   "4.0",
+			Service:   NewReachabilityAPI(b),
+		},
 	}
 }
 
@@ -278,6 +381,7 @@ func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*
 			Peer:       protocols.NewPeer(p, rw, spec),
 			BzzAddr:    handshake.peerAddr,
 			lastActive: time.Now(),
+			negotiated: handshake.negotiated,
 		}
 
 		log.Debug("peer created", "addr", handshake.peerAddr.String())
@@ -289,17 +393,14 @@ func (b *Bzz) RunProtocol(spec *protocols.Spec, run func(*BzzPeer) error) func(*
 // performHandshake implements the negotiation of the bzz handshake
 // shared among swarm subprotocols
 func (b *Bzz) performHandshake(p *protocols.Peer, handshake *HandshakeMsg) error {
-	ctx, cancel := context.WithTimeout(context.Background(), bzzHandshakeTimeout)
-	defer func() {
-		close(handshake.done)
-		cancel()
-	}()
-	rsh, err := p.Handshake(ctx, handshake, b.checkHandshake)
+	defer close(handshake.done)
+	rsh, err := p.Handshake(context.Background(), handshake, b.checkHandshake)
 	if err != nil {
 		handshake.err = err
 		return err
 	}
 	handshake.peerAddr = rsh.(*HandshakeMsg).Addr
+	handshake.negotiated = b.localAddr.Capabilities.Negotiate(handshake.peerAddr.Capabilities)
 	return nil
 }
 
@@ -319,6 +420,7 @@ func (b *Bzz) runBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 
 		return err
 	}
+	b.checkSpecVersions(p)
 	// fail if we get another handshake
 	msg, err := rw.ReadMsg()
 	if err != nil {
@@ -331,9 +433,10 @@ func (b *Bzz) runBzz(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 // BzzPeer is the bzz protocol view of a protocols.Peer (itself an extension of p2p.Peer)
 // implements the Peer interface and all interfaces Peer implements: Addr, OverlayPeer
 type BzzPeer struct {
-	*protocols.Peer           // represents the connection for online peers
-	*BzzAddr                  // remote address -> implements Addr interface = protocols.Peer
-	lastActive      time.Time // time is updated whenever mutexes are releasing
+	*protocols.Peer                          // represents the connection for online peers
+	*BzzAddr                                 // remote address -> implements Addr interface = protocols.Peer
+	lastActive      time.Time                // time is updated whenever mutexes are releasing
+	negotiated      *capability.Capabilities // capabilities common to both nodes, set after the handshake
 }
 
 func NewBzzPeer(p *protocols.Peer) *BzzPeer {
@@ -349,6 +452,15 @@ func (p *BzzPeer) ID() enode.ID {
 	return p.Peer.ID()
 }
 
+// NegotiatedCapabilities returns the capabilities common to the local node and the peer
+// as negotiated in the bzz handshake
+func (p *BzzPeer) NegotiatedCapabilities() *capability.Capabilities {
+	if p.negotiated == nil {
+		return capability.NewCapabilities()
+	}
+	return p.negotiated
+}
+
 /*
  Handshake
 
@@ -364,6 +476,8 @@ type HandshakeMsg struct {
 
 	// peerAddr is the address received in the peer handshake
 	peerAddr *BzzAddr
+	// negotiated holds the capabilities common to both nodes
+	negotiated *capability.Capabilities
 
 	init chan bool
 	done chan struct{}
@@ -384,8 +498,8 @@ func (b *Bzz) checkHandshake(hs interface{}) error {
 	if rhs.Version != uint64(BzzSpec.Version) {
 		return fmt.Errorf("version mismatch %d (!= %d)", rhs.Version, BzzSpec.Version)
 	}
-	// temporary check for valid capability settings, legacy full/light
-	if !isFullCapability(rhs.Addr.Capabilities.Get(0)) && !isLightCapability(rhs.Addr.Capabilities.Get(0)) {
+	// temporary check for valid capability settings, legacy full/light and relaying light
+	if c := rhs.Addr.Capabilities.Get(0); !isFullCapability(c) && !isLightCapability(c) && !isRelayCapability(c) {
 		return fmt.Errorf("invalid capabilities setting: %s", rhs.Addr.Capabilities)
 	}
 	return nil
@@ -408,9 +408,51 @@ func TestBzzHandshakeLightNode(t *testing.T) {
 						t.Fatalf("peer LightNode flag is %v, should be %v", cp.String(), nodeCapability.String())
 					}
 				}
+				negotiated := pt.bzz.handshakes[node.ID()].negotiated
+				if cp := negotiated.Get(CapabilityID); !nodeCapability.IsSameAs(cp) {
+					t.Fatalf("negotiated capability is %v, should be %v", cp, nodeCapability)
+				}
+				peerAddr := pt.bzz.handshakes[node.ID()].peerAddr
+				if peerAddr.IsStorer() == test.lightNode || peerAddr.IsRetrieveRelay() == test.lightNode {
+					t.Fatalf("peer storer/relay capability is %v/%v, should be %v", peerAddr.IsStorer(), peerAddr.IsRetrieveRelay(), !test.lightNode)
+				}
 			case <-time.After(10 * time.Second):
 				t.Fatal("test timeout")
 			}
 		})
 	}
 }
+
+// TestBzzHandshakeRelayNode tests that light nodes relaying retrieve
+// requests are accepted and recognised as relays, but not as storers
+func TestBzzHandshakeRelayNode(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := newBzzHandshakeTester(1, prvkey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pt.Stop()
+
+	node := pt.Nodes[0]
+	msg := newBzzHandshakeMsg(TestProtocolVersion, TestProtocolNetworkID, NewBzzAddrFromEnode(node), true)
+	msg.Addr.Capabilities = capability.NewCapabilities()
+	msg.Addr.Capabilities.Add(newRelayCapability())
+
+	err = pt.testHandshake(correctBzzHandshake(pt.addr, false), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-pt.bzz.handshakes[node.ID()].done:
+		peerAddr := pt.bzz.handshakes[node.ID()].peerAddr
+		if peerAddr.IsStorer() || !peerAddr.IsRetrieveRelay() {
+			t.Fatalf("peer storer/relay capability is %v/%v, should be false/true", peerAddr.IsStorer(), peerAddr.IsRetrieveRelay())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("test timeout")
+	}
+}
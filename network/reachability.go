@@ -0,0 +1,254 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/log"
+)
+
+var (
+	// reachabilityPeers is the number of connected peers asked in turn to dial back
+	reachabilityPeers = 3
+	// reachabilityDialTimeout is the time a peer waits for the dial back to connect
+	reachabilityDialTimeout = 5 * time.Second
+	// reachabilityTimeout is the time to wait for the response of a peer asked to dial back
+	reachabilityTimeout = 10 * time.Second
+
+	// ErrNoReachabilityPeer is returned by the reachability self-test if no connected peer responded
+	ErrNoReachabilityPeer = errors.New("no peer available to test reachability")
+	errReachabilityBusy   = errors.New("dial back in progress")
+)
+
+// reachabilityRequestMsg asks the peer to dial the advertised underlay address
+// of the sender to confirm that the sender accepts inbound connections
+// The address dialed is always the one advertised in the bzz handshake, and only
+// if its IP is the remote IP of the connection, so that the message cannot be used
+// to make the peer connect to arbitrary hosts
+type reachabilityRequestMsg struct {
+	Nonce uint64
+}
+
+// reachabilityResponseMsg is the result of the dial back requested with the same nonce
+type reachabilityResponseMsg struct {
+	Nonce     uint64
+	Reachable bool
+	Error     string // reason the dial back failed, if it did
+}
+
+// reachabilityRequest is a reachability self-test waiting for the response of a peer
+type reachabilityRequest struct {
+	peer enode.ID
+	c    chan *reachabilityResponseMsg
+}
+
+// dialUnderlay opens and closes a TCP connection to the enode URL of the underlay address
+// of a peer connected from the remote address, the underlay is not dialed unless its IP
+// is the remote IP
+func dialUnderlay(underlay []byte, remote net.Addr) error {
+	node, err := enode.ParseV4(string(underlay))
+	if err != nil {
+		return fmt.Errorf("invalid underlay address: %v", err)
+	}
+	if node.IP() == nil || node.TCP() == 0 {
+		return fmt.Errorf("underlay address %s has no TCP endpoint", node.ID().TerminalString())
+	}
+	if ip := remoteIP(remote); ip == nil || !ip.Equal(node.IP()) {
+		return fmt.Errorf("underlay address IP %s is not the remote IP of the peer %v", node.IP(), remote)
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", node.IP(), node.TCP()), reachabilityDialTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// remoteIP returns the IP of the remote address of a connection, nil if it has none
+func remoteIP(remote net.Addr) net.IP {
+	switch a := remote.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// handleReachabilityRequestMsg dials back the advertised underlay address of the peer
+// The dial runs in the background so that it does not block the message loop,
+// requests arriving while a dial back to the peer is in progress are refused
+func (h *Hive) handleReachabilityRequestMsg(d *Peer, msg *reachabilityRequestMsg) error {
+	if !atomic.CompareAndSwapInt32(&d.dialingBack, 0, 1) {
+		go d.Send(context.TODO(), &reachabilityResponseMsg{Nonce: msg.Nonce, Error: errReachabilityBusy.Error()})
+		return nil
+	}
+	go func() {
+		defer atomic.StoreInt32(&d.dialingBack, 0)
+
+		resp := &reachabilityResponseMsg{
+			Nonce:     msg.Nonce,
+			Reachable: true,
+		}
+		if err := h.dialUnderlay(d.Under(), d.RemoteAddr()); err != nil {
+			resp.Reachable = false
+			resp.Error = err.Error()
+		}
+		log.Trace("hive: reachability dial back", "peer", d.ShortOver(), "reachable", resp.Reachable, "err", resp.Error)
+		d.Send(context.TODO(), resp)
+	}()
+	return nil
+}
+
+// handleReachabilityResponseMsg delivers the response to the self-test waiting for it
+// Responses with an unknown nonce or from a peer that was not asked are ignored
+func (h *Hive) handleReachabilityResponseMsg(d *Peer, msg *reachabilityResponseMsg) error {
+	h.reachMtx.Lock()
+	defer h.reachMtx.Unlock()
+
+	req, ok := h.reachPending[msg.Nonce]
+	if !ok || req.peer != d.ID() {
+		return nil
+	}
+	delete(h.reachPending, msg.Nonce)
+	req.c <- msg
+	return nil
+}
+
+// requestDialBack asks the peer to dial back and waits for its response
+func (h *Hive) requestDialBack(ctx context.Context, d *Peer) (*reachabilityResponseMsg, error) {
+	req := &reachabilityRequest{
+		peer: d.ID(),
+		c:    make(chan *reachabilityResponseMsg, 1),
+	}
+	h.reachMtx.Lock()
+	h.reachNonce++
+	nonce := h.reachNonce
+	h.reachPending[nonce] = req
+	h.reachMtx.Unlock()
+	defer func() {
+		h.reachMtx.Lock()
+		delete(h.reachPending, nonce)
+		h.reachMtx.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, reachabilityTimeout)
+	defer cancel()
+	if err := d.Send(ctx, &reachabilityRequestMsg{Nonce: nonce}); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-req.c:
+		if resp.Error == errReachabilityBusy.Error() {
+			return nil, errReachabilityBusy
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// checkReachability asks the connected peers closest to the node in turn to dial
+// back its advertised underlay address until one of them responds
+// It returns the peer that responded and whether it could connect
+func (h *Hive) checkReachability(ctx context.Context) (*Peer, *reachabilityResponseMsg, error) {
+	var peers []*Peer
+	h.EachConn(nil, 255, func(p *Peer, _ int) bool {
+		peers = append(peers, p)
+		return len(peers) < reachabilityPeers
+	})
+	for _, p := range peers {
+		resp, err := h.requestDialBack(ctx, p)
+		if err != nil {
+			log.Debug("hive: reachability request failed", "peer", p.ShortOver(), "err", err)
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			continue
+		}
+		if resp.Reachable {
+			metrics.GetOrRegisterCounter("network/hive/reachability/reachable", nil).Inc(1)
+			log.Info("hive: node is reachable", "peer", p.ShortOver())
+		} else {
+			metrics.GetOrRegisterCounter("network/hive/reachability/unreachable", nil).Inc(1)
+			log.Warn("hive: node is not reachable from the network, inbound connections fail", "peer", p.ShortOver(), "err", resp.Error)
+		}
+		return p, resp, nil
+	}
+	metrics.GetOrRegisterCounter("network/hive/reachability/nopeer", nil).Inc(1)
+	return nil, nil, ErrNoReachabilityPeer
+}
+
+// Reachability is the RPC representation of the reachability of the node
+type Reachability struct {
+	Underlay   string // underlay address advertised to peers
+	NAT        string // NAT port mapping mechanism, empty if none is configured
+	ExternalIP string // external IP address reported by the NAT mapping
+	NATError   string // error querying the NAT mapping, if any
+	Peer       string // overlay address of the peer that dialed back
+	Reachable  bool   // whether the peer could connect to the underlay address
+	Error      string // reason the dial back failed, if it did
+}
+
+// ReachabilityAPI is the RPC API reporting whether the node accepts inbound connections
+type ReachabilityAPI struct {
+	b *Bzz
+}
+
+// NewReachabilityAPI creates the reachability RPC API
+func NewReachabilityAPI(b *Bzz) *ReachabilityAPI {
+	return &ReachabilityAPI{b: b}
+}
+
+// Reachability reports the advertised underlay address and the NAT mapping of the node,
+// and asks a connected peer to dial the underlay address to confirm inbound reachability
+// Many sync failures are caused by nodes that cannot be connected to, this makes them visible
+func (a *ReachabilityAPI) Reachability(ctx context.Context) (*Reachability, error) {
+	r := &Reachability{
+		Underlay: string(a.b.localAddr.Under()),
+	}
+	if srv := a.b.Hive.server; srv != nil && srv.NAT != nil {
+		r.NAT = srv.NAT.String()
+		ip, err := srv.NAT.ExternalIP()
+		if err != nil {
+			r.NATError = err.Error()
+		} else {
+			r.ExternalIP = ip.String()
+		}
+	}
+
+	p, resp, err := a.b.Hive.checkReachability(ctx)
+	if err != nil {
+		if err == ErrNoReachabilityPeer {
+			r.Error = err.Error()
+			return r, nil
+		}
+		return nil, err
+	}
+	r.Peer = hexutil.Encode(p.Over())
+	r.Reachable = resp.Reachable
+	r.Error = resp.Error
+	return r, nil
+}
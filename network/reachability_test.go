@@ -0,0 +1,148 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+)
+
+// TestDialUnderlay tests that the dial back succeeds if the underlay address accepts connections
+func TestDialUnderlay(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	underlay := []byte(enode.NewV4(&prvkey.PublicKey, net.IPv4(127, 0, 0, 1), port, port).URLv4())
+
+	remote := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 30399}
+
+	if err := dialUnderlay(underlay, remote); err != nil {
+		t.Fatalf("expected underlay to be reachable, got %v", err)
+	}
+	// the underlay is not dialed if the peer is connected from another IP
+	if err := dialUnderlay(underlay, &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 30399}); err == nil {
+		t.Fatal("expected error dialing an underlay address of another IP than the peer")
+	}
+	ln.Close()
+	if err := dialUnderlay(underlay, remote); err == nil {
+		t.Fatal("expected underlay not to be reachable after the listener is closed")
+	}
+	if err := dialUnderlay([]byte("not an enode"), remote); err == nil {
+		t.Fatal("expected error dialing invalid underlay")
+	}
+}
+
+// TestReachabilityExchange tests that a peer asked to dial back responds with the result
+// of dialing the advertised underlay address, and that the self-test reports the response
+func TestReachabilityExchange(t *testing.T) {
+	params := NewHiveParams()
+	params.Discovery = false
+	s, h, err := newHiveTester(params, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	dialErr := errors.New("connection refused")
+	h.dialUnderlay = func([]byte, net.Addr) error {
+		return dialErr
+	}
+	node := s.Nodes[0]
+
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "dial back requested by peer",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 2,
+				Msg:  &reachabilityRequestMsg{Nonce: 42},
+				Peer: node.ID(),
+			},
+		},
+		Expects: []p2ptest.Expect{
+			{
+				Code: 3,
+				Msg:  &reachabilityResponseMsg{Nonce: 42, Error: dialErr.Error()},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		p    *Peer
+		resp *reachabilityResponseMsg
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		p, resp, err := h.checkReachability(context.Background())
+		results <- result{p, resp, err}
+	}()
+
+	err = s.TestExchanges(p2ptest.Exchange{
+		Label: "dial back requested from peer",
+		Expects: []p2ptest.Expect{
+			{
+				Code: 2,
+				Msg:  &reachabilityRequestMsg{Nonce: 1},
+				Peer: node.ID(),
+			},
+		},
+	}, p2ptest.Exchange{
+		Label: "dial back result of peer",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 3,
+				Msg:  &reachabilityResponseMsg{Nonce: 1, Reachable: true},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.p.ID() != node.ID() {
+			t.Fatalf("expected response from peer %s, got %s", node.ID(), r.p.ID())
+		}
+		if !r.resp.Reachable {
+			t.Fatal("expected node to be reachable")
+		}
+	case <-time.After(reachabilityTimeout):
+		t.Fatal("timeout waiting for reachability result")
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+const historyKey = "retrieval_history"
+
+var (
+	// maxHistorySize is the number of recently connected peers remembered
+	maxHistorySize = 256
+	// historicDialTimeout is the time to wait for a historic peer to connect
+	historicDialTimeout = 3 * time.Second
+
+	historicRequestCount = metrics.NewRegisteredCounter("network/retrieve/historic_request", nil)
+	historicDialFail     = metrics.NewRegisteredCounter("network/retrieve/historic_dial_fail", nil)
+)
+
+// historicPeer is a peer the node was connected to with the retrieve protocol
+type historicPeer struct {
+	Addr     *network.BzzAddr
+	LastSeen time.Time
+}
+
+// peerHistory keeps the most recently seen retrieve relay peers, so that chunks
+// can still be requested from the peers that were closest to them when the
+// currently connected peers do not deliver, e.g. because the neighbourhood churned
+type peerHistory struct {
+	mtx     sync.Mutex
+	size    int
+	peers   map[string]*historicPeer     // peers by overlay address
+	waiting map[enode.ID][]chan struct{} // dialed peers waiting to connect
+}
+
+func newPeerHistory(size int) *peerHistory {
+	return &peerHistory{
+		size:    size,
+		peers:   make(map[string]*historicPeer),
+		waiting: make(map[enode.ID][]chan struct{}),
+	}
+}
+
+// seen records the peer with the current time and evicts the least recently
+// seen peer if the history is full
+func (h *peerHistory) seen(addr *network.BzzAddr) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.add(&historicPeer{
+		Addr:     addr,
+		LastSeen: time.Now(),
+	})
+}
+
+// add records a historic peer, the caller is expected to hold the lock
+func (h *peerHistory) add(hp *historicPeer) {
+	key := hex.EncodeToString(hp.Addr.Over())
+	if p, ok := h.peers[key]; ok && p.LastSeen.After(hp.LastSeen) {
+		return
+	}
+	h.peers[key] = hp
+	for len(h.peers) > h.size {
+		var oldest string
+		for k, p := range h.peers {
+			if oldest == "" || p.LastSeen.Before(h.peers[oldest].LastSeen) {
+				oldest = k
+			}
+		}
+		delete(h.peers, oldest)
+	}
+}
+
+// closest returns the historic peers ordered by proximity to the address,
+// the most recently seen first among peers of the same proximity
+func (h *peerHistory) closest(addr storage.Address) []*historicPeer {
+	h.mtx.Lock()
+	peers := make([]*historicPeer, 0, len(h.peers))
+	for _, p := range h.peers {
+		peers = append(peers, p)
+	}
+	h.mtx.Unlock()
+
+	sort.Slice(peers, func(i, j int) bool {
+		poi := chunk.Proximity(peers[i].Addr.Over(), addr)
+		poj := chunk.Proximity(peers[j].Addr.Over(), addr)
+		if poi != poj {
+			return poi > poj
+		}
+		return peers[i].LastSeen.After(peers[j].LastSeen)
+	})
+	return peers
+}
+
+// wait returns a channel that is closed when the peer connects
+func (h *peerHistory) wait(id enode.ID) chan struct{} {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	c := make(chan struct{})
+	h.waiting[id] = append(h.waiting[id], c)
+	return c
+}
+
+// unwait removes the channel returned by wait if the peer did not connect
+func (h *peerHistory) unwait(id enode.ID, c chan struct{}) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	waiting := h.waiting[id]
+	for i, w := range waiting {
+		if w == c {
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) == 0 {
+		delete(h.waiting, id)
+		return
+	}
+	h.waiting[id] = waiting
+}
+
+// connected notifies the dialers waiting for the peer
+func (h *peerHistory) connected(id enode.ID) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for _, c := range h.waiting[id] {
+		close(c)
+	}
+	delete(h.waiting, id)
+}
+
+// load adds the historic peers persisted in the state store
+func (h *peerHistory) load(store state.Store) error {
+	var peers []*historicPeer
+	if err := store.Get(historyKey, &peers); err != nil {
+		if err == state.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	for _, p := range peers {
+		if p.Addr != nil {
+			h.add(p)
+		}
+	}
+	return nil
+}
+
+// save persists the historic peers in the state store
+func (h *peerHistory) save(store state.Store) error {
+	h.mtx.Lock()
+	peers := make([]*historicPeer, 0, len(h.peers))
+	for _, p := range h.peers {
+		peers = append(peers, p)
+	}
+	h.mtx.Unlock()
+
+	return store.Put(historyKey, peers)
+}
+
+// historicPeer dials the historic peers closest to the requested chunk in turn and
+// returns the first one that connects, or nil if none of them did
+// Only peers strictly closer to the chunk than this node are dialed, or peers in
+// the neighbourhood of this node if the chunk falls within it, the same rule
+// findPeerLB applies to the connected peers
+func (r *Retrieval) historicPeer(ctx context.Context, req *storage.Request) *Peer {
+	if r.dial == nil {
+		return nil
+	}
+	myPo := chunk.Proximity(req.Addr, r.kad.BaseAddr())
+	depth := r.kad.NeighbourhoodDepth()
+	originPo := r.getOriginPo(req)
+
+	for _, hp := range r.history.closest(req.Addr) {
+		po := chunk.Proximity(hp.Addr.Over(), req.Addr)
+		if myPo < depth && po <= myPo {
+			break
+		}
+		if myPo >= depth && (po < depth || po <= originPo) {
+			break
+		}
+		id := hp.Addr.ID()
+		if id == (enode.ID{}) || bytes.Equal(req.Origin.Bytes(), id.Bytes()) || req.SkipPeer(id.String()) {
+			continue
+		}
+		// connected peers have been considered by findPeerLB already
+		if r.getPeer(id) != nil {
+			continue
+		}
+		node, err := enode.ParseV4(string(hp.Addr.Under()))
+		if err != nil {
+			continue
+		}
+		// do not dial the peer again for the same request
+		req.PeersToSkip.Store(id.String(), time.Now())
+		if p := r.dialHistoric(ctx, node); p != nil {
+			historicRequestCount.Inc(1)
+			return p
+		}
+		historicDialFail.Inc(1)
+	}
+	return nil
+}
+
+// dialHistoric connects to the peer and waits until it runs the retrieve protocol
+// The server keeps dialing the peers it is asked to connect to, so the peer is
+// disconnected unless it connects, or else once its retrievals are over
+func (r *Retrieval) dialHistoric(ctx context.Context, node *enode.Node) *Peer {
+	c := r.history.wait(node.ID())
+	defer r.history.unwait(node.ID(), c)
+
+	r.logger.Debug("retrieval.dialHistoric", "peer", node.ID())
+	r.dial(node)
+
+	timer := time.NewTimer(historicDialTimeout)
+	defer timer.Stop()
+	select {
+	case <-c:
+		if p := r.getPeer(node.ID()); p != nil {
+			p.setDialed(node)
+			return p
+		}
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-r.quit:
+	}
+	r.undialHistoric(node)
+	return nil
+}
+
+// undialHistoric disconnects from the historic peer and stops dialing it
+func (r *Retrieval) undialHistoric(node *enode.Node) {
+	if r.undial == nil {
+		return
+	}
+	r.logger.Debug("retrieval.undialHistoric", "peer", node.ID())
+	r.undial(node)
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestPeerHistory tests that the peer history evicts the least recently seen peers,
+// orders peers by proximity to an address and survives a round trip through the state store
+func TestPeerHistory(t *testing.T) {
+	h := newPeerHistory(3)
+	var addrs []*network.BzzAddr
+	for i := 0; i < 4; i++ {
+		addr := network.RandomBzzAddr()
+		addrs = append(addrs, addr)
+		h.seen(addr)
+		time.Sleep(time.Millisecond)
+	}
+	if len(h.peers) != 3 {
+		t.Fatalf("expected 3 peers in history, got %d", len(h.peers))
+	}
+	for _, p := range h.peers {
+		if bytes.Equal(p.Addr.Over(), addrs[0].Over()) {
+			t.Fatal("expected least recently seen peer to be evicted")
+		}
+	}
+
+	target := make(storage.Address, len(addrs[2].Over()))
+	copy(target, addrs[2].Over())
+	target[len(target)-1] ^= 1
+	closest := h.closest(target)
+	if !bytes.Equal(closest[0].Addr.Over(), addrs[2].Over()) {
+		t.Fatalf("expected closest peer %s, got %s", addrs[2], closest[0].Addr)
+	}
+
+	store := state.NewInmemoryStore()
+	if err := h.save(store); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newPeerHistory(3)
+	if err := loaded.load(store); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.peers) != 3 {
+		t.Fatalf("expected 3 loaded peers, got %d", len(loaded.peers))
+	}
+	if !bytes.Equal(loaded.closest(target)[0].Addr.Over(), addrs[2].Over()) {
+		t.Fatal("expected closest loaded peer to match")
+	}
+}
+
+// TestHistoricPeer tests that historic peers are dialed closest first,
+// only once per request, that a connected historic peer is returned and
+// that historic peers are disconnected once they fail to connect or their
+// retrievals are over
+func TestHistoricPeer(t *testing.T) {
+	defer func(t time.Duration) { historicDialTimeout = t }(historicDialTimeout)
+	historicDialTimeout = 50 * time.Millisecond
+
+	base := network.RandomBzzAddr()
+	kad := network.NewKademlia(base.Over(), network.NewKadParams())
+	r := New(kad, nil, base, nil, nil)
+
+	near := network.RandomBzzAddr()
+	far := network.RandomBzzAddr()
+	r.history.seen(near)
+	r.history.seen(far)
+
+	target := make(storage.Address, len(near.Over()))
+	copy(target, near.Over())
+	target[len(target)-1] ^= 1
+
+	var dialed, undialed []enode.ID
+	r.undial = func(n *enode.Node) {
+		undialed = append(undialed, n.ID())
+	}
+	r.dial = func(n *enode.Node) {
+		dialed = append(dialed, n.ID())
+		// only the near peer accepts the connection
+		if n.ID() == near.ID() {
+			r.addPeer(NewPeer(&network.BzzPeer{
+				BzzAddr: near,
+				Peer:    protocols.NewPeer(p2p.NewPeer(near.ID(), "near", nil), nil, nil),
+			}, base))
+		}
+	}
+
+	req := storage.NewRequest(target)
+	p := r.historicPeer(context.Background(), req)
+	if p == nil || p.ID() != near.ID() {
+		t.Fatalf("expected near peer, got %v", p)
+	}
+	if !req.SkipPeer(near.ID().String()) {
+		t.Fatal("expected near peer to be skipped for the request")
+	}
+	p.addRetrieval(1, target, nil)
+	if len(undialed) != 0 {
+		t.Fatalf("expected no peer to be disconnected, got %v", undialed)
+	}
+	r.expireRetrieval(p, 1)
+	if len(undialed) != 1 || undialed[0] != near.ID() {
+		t.Fatalf("expected near peer to be disconnected once its retrieval is over, got %v", undialed)
+	}
+
+	// the near peer is skipped now, so the far peer is dialed, which does not connect
+	if p := r.historicPeer(context.Background(), req); p != nil {
+		t.Fatalf("expected no peer, got %v", p)
+	}
+	if len(dialed) != 2 || dialed[0] != near.ID() || dialed[1] != far.ID() {
+		t.Fatalf("expected near and far peer to be dialed in turn, got %v", dialed)
+	}
+	if len(undialed) != 2 || undialed[1] != far.ID() {
+		t.Fatalf("expected far peer to be disconnected after it failed to connect, got %v", undialed)
+	}
+	if p := r.historicPeer(context.Background(), req); p != nil {
+		t.Fatalf("expected no peer once all historic peers were tried, got %v", p)
+	}
+	if len(dialed) != 2 {
+		t.Fatalf("expected no more dials, got %v", dialed)
+	}
+}
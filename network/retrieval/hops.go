@@ -0,0 +1,133 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// DefaultMaxHops is the default number of times a retrieve request is forwarded,
+// it is well above the length of the routes kademlia finds in large networks
+const DefaultMaxHops uint8 = 20
+
+// hopsVersion is the first bzz-retrieve protocol version whose peers count the
+// hops of retrieve requests, the hop count of the requests exchanged with peers
+// running older versions is left empty
+const hopsVersion = 3
+
+var (
+	// ErrHopLimit is returned when a retrieve request cannot be forwarded
+	// because it already travelled the maximum number of hops
+	ErrHopLimit = errors.New("retrieve request hop limit reached")
+
+	retrieveRequestHopLimit = metrics.NewRegisteredCounter("network/retrieve/request_hop_limit", nil)
+	retrieveRequestLoop     = metrics.NewRegisteredCounter("network/retrieve/request_loop", nil)
+	retrieveRequestNoRelay  = metrics.NewRegisteredCounter("network/retrieve/request_norelay", nil)
+)
+
+// countsHops reports whether the retrieve requests from and for the peer carry a hop count
+func (p *Peer) countsHops() bool {
+	return p.Version() >= hopsVersion
+}
+
+// nextHops returns the hop count of a retrieve request forwarded on behalf of
+// the request, the maximum for requests originating at this node
+func nextHops(req *storage.Request, max uint8) (uint8, error) {
+	if req.HopCount == 0 {
+		return max, nil
+	}
+	if req.HopCount == 1 {
+		retrieveRequestHopLimit.Inc(1)
+		return 0, ErrHopLimit
+	}
+	return req.HopCount - 1, nil
+}
+
+// receivedHops returns the hop count of a received retrieve request, which
+// peers cannot raise above the maximum of this node, nor set to zero to make
+// the request look like one originating at this node
+func receivedHops(hops, max uint8) uint8 {
+	if hops == 0 {
+		return 1
+	}
+	if hops > max {
+		return max
+	}
+	return hops
+}
+
+// forwardCache keeps the retrieve requests the node forwarded and is waiting
+// for a delivery of, so that forwarding loops are detected: if a request for
+// the same chunk comes back with fewer hops left than the node forwarded it
+// with, it most likely is the node's own request that travelled in a circle
+type forwardCache struct {
+	mtx      sync.Mutex
+	forwards map[string]*forward
+}
+
+type forward struct {
+	hops  uint8 // highest hop count the chunk was requested with
+	count int   // number of pending forwards of the chunk
+}
+
+func newForwardCache() *forwardCache {
+	return &forwardCache{
+		forwards: make(map[string]*forward),
+	}
+}
+
+// add records a forwarded request and returns the function removing it
+func (c *forwardCache) add(addr storage.Address, hops uint8) func() {
+	key := addr.Hex()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	f, ok := c.forwards[key]
+	if !ok {
+		f = &forward{}
+		c.forwards[key] = f
+	}
+	f.count++
+	if hops > f.hops {
+		f.hops = hops
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mtx.Lock()
+			defer c.mtx.Unlock()
+			f.count--
+			if f.count == 0 {
+				delete(c.forwards, key)
+			}
+		})
+	}
+}
+
+// isLoop tells whether a received request for the chunk with hops left
+// is a request forwarded by the node itself
+func (c *forwardCache) isLoop(addr storage.Address, hops uint8) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	f, ok := c.forwards[addr.Hex()]
+	return ok && hops < f.hops
+}
@@ -0,0 +1,254 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/capability"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestHops tests the hop counts of forwarded and received retrieve requests
+func TestHops(t *testing.T) {
+	for _, tc := range []struct {
+		received uint8
+		want     uint8
+		err      error
+	}{
+		{0, 10, nil}, // originating at the node
+		{10, 9, nil},
+		{2, 1, nil},
+		{1, 0, ErrHopLimit},
+	} {
+		req := storage.NewRequest(storage.Address(hash0[:]))
+		req.HopCount = tc.received
+		hops, err := nextHops(req, 10)
+		if err != tc.err || hops != tc.want {
+			t.Fatalf("received %d: expected %d hops and error %v, got %d and %v", tc.received, tc.want, tc.err, hops, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		received, want uint8
+	}{
+		{0, 1},
+		{1, 1},
+		{10, 10},
+		{11, 10},
+		{255, 10},
+	} {
+		if hops := receivedHops(tc.received, 10); hops != tc.want {
+			t.Fatalf("received %d: expected %d hops, got %d", tc.received, tc.want, hops)
+		}
+	}
+}
+
+// TestRequestFromPeersHopLimit tests that requests that travelled the maximum
+// number of hops are not forwarded
+func TestRequestFromPeersHopLimit(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	s := New(network.NewKademlia(addr.OAddr, network.NewKadParams()), nil, addr, nil, nil)
+
+	req := storage.NewRequest(storage.Address(hash0[:]))
+	req.HopCount = 1
+	if _, _, err := s.RequestFromPeers(context.Background(), req, addr.ID()); err != ErrHopLimit {
+		t.Fatalf("expected error %v, got %v", ErrHopLimit, err)
+	}
+}
+
+// TestForwardCache tests that only requests coming back with fewer hops
+// than they were forwarded with are detected as loops
+func TestForwardCache(t *testing.T) {
+	c := newForwardCache()
+	addr := storage.Address(hash0[:])
+
+	if c.isLoop(addr, 5) {
+		t.Fatal("expected no loop without forwards")
+	}
+	remove1 := c.add(addr, 10)
+	remove2 := c.add(addr, 8)
+	if !c.isLoop(addr, 9) {
+		t.Fatal("expected loop with fewer hops than forwarded")
+	}
+	if c.isLoop(addr, 10) {
+		t.Fatal("expected no loop with as many hops as forwarded")
+	}
+	remove1()
+	remove1()
+	if !c.isLoop(addr, 7) {
+		t.Fatal("expected loop while a forward is pending")
+	}
+	remove2()
+	if c.isLoop(addr, 1) {
+		t.Fatal("expected no loop after forwards are removed")
+	}
+	if len(c.forwards) != 0 {
+		t.Fatalf("expected empty cache, got %d entries", len(c.forwards))
+	}
+}
+
+// TestRetrieveRequestRelay tests that received retrieve requests are only
+// forwarded by nodes advertising the retrieve relay capability
+func TestRetrieveRequestRelay(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		relay bool
+		want  uint8
+	}{
+		{"relay", true, 5},
+		{"no relay", false, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pk, ns, cleanup := newTestNetstore(t)
+			defer cleanup()
+
+			kad := network.NewKademlia(network.PrivateKeyToBzzKey(pk), network.NewKadParams())
+			tester, r, teardown, err := newRetrievalTester(t, pk, ns, kad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer teardown()
+
+			// light node capability, with the relay bit set or not
+			c := capability.NewCapability(network.CapabilityID, 16)
+			c.Set(0)
+			c.Set(1)
+			if tc.relay {
+				c.Set(4)
+			}
+			r.baseAddress.Capabilities = capability.NewCapabilities()
+			r.baseAddress.Capabilities.Add(c)
+
+			hopsC := make(chan uint8, 1)
+			ns.RemoteGet = func(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
+				hopsC <- req.HopCount
+				return nil, func() {}, errors.New("no peers")
+			}
+
+			err = tester.TestExchanges(p2ptest.Exchange{
+				Label: "retrieve request",
+				Triggers: []p2ptest.Trigger{
+					{
+						Code: 1,
+						Msg: &RetrieveRequest{
+							Ruid:     1,
+							Addr:     hash0[:],
+							HopCount: 5,
+						},
+						Peer: tester.Nodes[0].ID(),
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case hops := <-hopsC:
+				if hops != tc.want {
+					t.Fatalf("expected request with %d hops, got %d", tc.want, hops)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the request")
+			}
+		})
+	}
+}
+
+// TestHopCountEncoding tests that the retrieve requests without hop count are
+// encoded as in the versions before hopsVersion, that the requests of those
+// versions are decoded without hop count, and that the hop count and the price
+// survive the encoding
+func TestHopCountEncoding(t *testing.T) {
+	type legacyRetrieveRequest struct {
+		Ruid uint
+		Addr storage.Address
+	}
+	addr := storage.Address(hash0[:])
+
+	b, err := rlp.EncodeToBytes(&RetrieveRequest{Ruid: 1, Addr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := rlp.EncodeToBytes(&legacyRetrieveRequest{Ruid: 1, Addr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got encoding %x, want %x", b, want)
+	}
+	var rr RetrieveRequest
+	if err := rlp.DecodeBytes(want, &rr); err != nil {
+		t.Fatal(err)
+	}
+	if rr.HopCount != 0 || rr.MaxPrice != nil {
+		t.Fatalf("got hop count %d and price %v, want none", rr.HopCount, rr.MaxPrice)
+	}
+
+	for _, msg := range []*RetrieveRequest{
+		{Ruid: 1, Addr: addr, HopCount: 5},
+		{Ruid: 1, Addr: addr, HopCount: 5, MaxPrice: []uint64{100}},
+	} {
+		b, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got RetrieveRequest
+		if err := rlp.DecodeBytes(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&got, msg) {
+			t.Fatalf("got request %v, want %v", got, msg)
+		}
+	}
+
+	if _, err := rlp.EncodeToBytes(&RetrieveRequest{Ruid: 1, Addr: addr, MaxPrice: []uint64{100}}); !errors.Is(err, errPriceWithoutHop) {
+		t.Fatalf("got error %v, want %v", err, errPriceWithoutHop)
+	}
+	b, err = rlp.EncodeToBytes([]interface{}{uint(1), addr, uint(256)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rlp.DecodeBytes(b, &rr); err != errHopCountRange {
+		t.Fatalf("got error %v, want %v", err, errHopCountRange)
+	}
+}
+
+// TestHopCountVersion tests that retrieve requests carry a hop count
+// only with peers running hopsVersion or later
+func TestHopCountVersion(t *testing.T) {
+	for _, version := range []uint{hopsVersion - 1, hopsVersion, spec.Version} {
+		caps := []p2p.Cap{{Name: spec.Name, Version: version}}
+		pp := protocols.NewPeer(p2p.NewPeer(enode.ID{}, "", caps), nil, spec)
+		p := &Peer{BzzPeer: &network.BzzPeer{Peer: pp}}
+		if got, want := p.countsHops(), version >= hopsVersion; got != want {
+			t.Errorf("version %d: got counts hops %v, want %v", version, got, want)
+		}
+	}
+}
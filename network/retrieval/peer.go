@@ -20,7 +20,9 @@ import (
 	"bytes"
 	"errors"
 	"sync"
+	"time"
 
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
@@ -31,9 +33,17 @@ import (
 // retrievals for that peer
 type Peer struct {
 	*network.BzzPeer
-	logger     log.Logger             // logger with base and peer address
-	mtx        sync.Mutex             // synchronize retrievals
-	retrievals map[uint]chunk.Address // current ongoing retrievals
+	logger     log.Logger                // logger with base and peer address
+	mtx        sync.Mutex                // synchronize retrievals
+	retrievals map[uint]pendingRetrieval // current ongoing retrievals
+	dialed     *enode.Node               // the node dialed to retrieve from the peer as a historic peer
+}
+
+// pendingRetrieval is a retrieve request sent to the peer
+type pendingRetrieval struct {
+	addr     chunk.Address
+	sent     time.Time // for measuring the round trip time to the peer
+	maxPrice []uint64  // the price field of the request, empty if the retrieval is not priced per chunk
 }
 
 // NewPeer is the constructor for Peer
@@ -41,38 +51,78 @@ func NewPeer(peer *network.BzzPeer, baseKey *network.BzzAddr) *Peer {
 	return &Peer{
 		BzzPeer:    peer,
 		logger:     log.NewBaseAddressLogger(baseKey.ShortString(), "peer", peer.BzzAddr.ShortString()),
-		retrievals: make(map[uint]chunk.Address),
+		retrievals: make(map[uint]pendingRetrieval),
 	}
 }
 
 // chunkRequested adds a new retrieval to the retrievals map
 // this is in order to identify unsolicited chunk deliveries
-func (p *Peer) addRetrieval(ruid uint, addr storage.Address) {
+// maxPrice is the price field of the request, empty if the retrieval is not priced per chunk
+func (p *Peer) addRetrieval(ruid uint, addr storage.Address, maxPrice []uint64) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
-	p.retrievals[ruid] = addr
+	p.retrievals[ruid] = pendingRetrieval{
+		addr:     addr,
+		sent:     time.Now(),
+		maxPrice: maxPrice,
+	}
 }
 
-func (p *Peer) expireRetrieval(ruid uint) {
+// expireRetrieval removes the retrieval if it was not delivered
+// it returns the time elapsed since the request was sent, and false
+// if the retrieval was delivered already
+func (p *Peer) expireRetrieval(ruid uint) (time.Duration, bool) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
+	v, ok := p.retrievals[ruid]
+	if !ok {
+		return 0, false
+	}
 	delete(p.retrievals, ruid)
+	return time.Since(v.sent), true
+}
+
+// setDialed records the node dialed to retrieve from the peer as a historic peer
+func (p *Peer) setDialed(node *enode.Node) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.dialed = node
+}
+
+// undial returns the node the peer was dialed as once no retrievals from
+// the peer are pending, and nil if it was not dialed or retrievals are pending
+func (p *Peer) undial() *enode.Node {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.dialed == nil || len(p.retrievals) > 0 {
+		return nil
+	}
+	node := p.dialed
+	p.dialed = nil
+	return node
 }
 
 // chunkReceived is called upon ChunkDelivery message reception
 // it is meant to idenfify unsolicited chunk deliveries
-func (p *Peer) checkRequest(ruid uint, addr storage.Address) error {
+// it returns the time elapsed since the request was sent, and ErrOvercharged
+// if the delivery is charged more than offered in the request
+func (p *Peer) checkRequest(ruid uint, addr storage.Address, charge []uint64) (time.Duration, error) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	v, ok := p.retrievals[ruid]
 	if !ok {
-		return errors.New("cannot find ruid")
+		return 0, errors.New("cannot find ruid")
 	}
 	delete(p.retrievals, ruid) // since we got the delivery we wanted - it is safe to delete the retrieve request
-	if !bytes.Equal(v, addr) {
-		return errors.New("retrieve request found but address does not match")
+	if !bytes.Equal(v.addr, addr) {
+		return 0, errors.New("retrieve request found but address does not match")
+	}
+	if charged, ok := chunkPrice(charge); ok {
+		if maxPrice, ok := chunkPrice(v.maxPrice); !ok || charged > maxPrice {
+			return 0, ErrOvercharged
+		}
 	}
 
-	return nil
+	return time.Since(v.sent), nil
 }
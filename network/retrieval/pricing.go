@@ -0,0 +1,173 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/swap"
+)
+
+var (
+	// ErrPriceTooHigh is returned when a retrieve request priced per chunk
+	// offers less than the price of the chunk
+	ErrPriceTooHigh = errors.New("chunk price above the offered maximum")
+	// ErrOvercharged is returned when a chunk delivery is charged
+	// more than the node offered for the chunk
+	ErrOvercharged = errors.New("chunk delivery charged above the offered maximum")
+
+	retrieveRequestPriced       = metrics.NewRegisteredCounter("network/retrieve/request_priced", nil)
+	retrieveRequestPriceTooHigh = metrics.NewRegisteredCounter("network/retrieve/request_price_too_high", nil)
+	retrieveRequestPricedFree   = metrics.NewRegisteredCounter("network/retrieve/request_priced_free", nil)
+)
+
+// pricedVersion is the first bzz-retrieve protocol version whose peers price
+// retrievals per chunk, the price fields of the messages exchanged with peers
+// running older versions are left empty
+const pricedVersion = 4
+
+// pricesPerChunk reports whether retrievals from and for the peer can be priced per chunk
+func (p *Peer) pricesPerChunk() bool {
+	return p.Version() >= pricedVersion
+}
+
+// Pricer prices the chunks the node delivers to its peers for retrieve requests
+// priced per chunk
+type Pricer interface {
+	// ChunkPrice returns the price in honey of the delivery of the chunk at addr to peer
+	ChunkPrice(peer *network.BzzAddr, addr chunk.Address) uint64
+}
+
+// PricerFunc is an adapter to allow the use of ordinary functions as Pricers
+type PricerFunc func(peer *network.BzzAddr, addr chunk.Address) uint64
+
+// ChunkPrice calls f(peer, addr)
+func (f PricerFunc) ChunkPrice(peer *network.BzzAddr, addr chunk.Address) uint64 {
+	return f(peer, addr)
+}
+
+// FixedPrice returns the Pricer that charges the same price for every chunk
+func FixedPrice(price uint64) Pricer {
+	return PricerFunc(func(*network.BzzAddr, chunk.Address) uint64 {
+		return price
+	})
+}
+
+// FreeForNeighbours returns the Pricer that delivers chunks free of charge to the
+// peers in the neighbourhood of the node, which it syncs chunks with anyway, and
+// prices the deliveries to other peers with pricer
+func FreeForNeighbours(kad *network.Kademlia, pricer Pricer) Pricer {
+	return PricerFunc(func(peer *network.BzzAddr, addr chunk.Address) uint64 {
+		if isNeighbour(kad.BaseAddr(), peer.Over(), kad.NeighbourhoodDepth()) {
+			return 0
+		}
+		return pricer.ChunkPrice(peer, addr)
+	})
+}
+
+// isNeighbour reports whether the peer is in the neighbourhood of depth of the node at base
+func isNeighbour(base, peer []byte, depth int) bool {
+	return chunk.Proximity(base, peer) >= depth
+}
+
+// SetPricer sets how the chunks delivered for retrieve requests priced per chunk
+// are priced, every chunk at swap.DefaultChunkPrice if nil
+// The per chunk prices are settled with swap by the accounting hook of the protocol,
+// so the pricer has no effect if swap is disabled
+// It must be called before the node is started
+func (r *Retrieval) SetPricer(pricer Pricer) {
+	r.pricer = pricer
+}
+
+// SetMaxChunkPrice sets the most the node pays for a chunk it retrieves, offered in
+// its retrieve requests so that they are priced per chunk, the retrieve request and
+// the chunk delivery are priced per message if zero and with peers running versions
+// before pricedVersion
+// It must be called before the node is started
+func (r *Retrieval) SetMaxChunkPrice(price uint64) {
+	r.maxChunkPrice = price
+}
+
+// offeredPrice returns the price field of the retrieve requests sent to the peer,
+// empty if they are not priced per chunk
+func (r *Retrieval) offeredPrice(p *Peer) []uint64 {
+	if r.maxChunkPrice == 0 || !p.pricesPerChunk() {
+		return nil
+	}
+	return []uint64{r.maxChunkPrice}
+}
+
+// chargeFor returns the price field of the chunk delivery for the retrieve request
+// of the peer, empty if the request is not priced per chunk
+func (r *Retrieval) chargeFor(p *Peer, msg *RetrieveRequest) ([]uint64, error) {
+	maxPrice, ok := chunkPrice(msg.MaxPrice)
+	if !ok || !p.pricesPerChunk() {
+		return nil, nil
+	}
+	retrieveRequestPriced.Inc(1)
+	price := swap.DefaultChunkPrice
+	if r.pricer != nil {
+		price = r.pricer.ChunkPrice(p.BzzAddr, msg.Addr)
+	}
+	if price > maxPrice {
+		retrieveRequestPriceTooHigh.Inc(1)
+		return nil, ErrPriceTooHigh
+	}
+	if price == 0 {
+		retrieveRequestPricedFree.Inc(1)
+	}
+	return []uint64{price}, nil
+}
+
+// Price is the method through which a message type marks itself
+// as implementing the protocols.Price protocol and thus
+// as swap-enabled message
+// A request priced per chunk is free, it is paid for with the delivery of the chunk
+func (rr *RetrieveRequest) Price() *protocols.Price {
+	value := swap.RetrieveRequestPrice
+	if _, ok := chunkPrice(rr.MaxPrice); ok {
+		value = 0
+	}
+	return &protocols.Price{
+		Value:   value,
+		PerByte: false,
+		Payer:   protocols.Sender,
+	}
+}
+
+// Price is the method through which a message type marks itself
+// as implementing the protocols.Price protocol and thus
+// as swap-enabled message
+// A delivery priced per chunk costs the charged price, otherwise it is priced per byte
+func (cd *ChunkDelivery) Price() *protocols.Price {
+	if charge, ok := chunkPrice(cd.Charge); ok {
+		return &protocols.Price{
+			Value:   charge,
+			PerByte: false,
+			Payer:   protocols.Receiver,
+		}
+	}
+	return &protocols.Price{
+		Value:   swap.ChunkDeliveryPrice,
+		PerByte: true,
+		Payer:   protocols.Receiver,
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	p2ptest "github.com/ethersphere/swarm/p2p/testing"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/swap"
+)
+
+// TestChunkPriceMessages tests that retrievals priced per chunk are accounted
+// with the delivery of the chunk at the charged price, and that a zero charge
+// is not mistaken for a delivery priced per byte
+func TestChunkPriceMessages(t *testing.T) {
+	price := (&RetrieveRequest{MaxPrice: []uint64{100}}).Price()
+	if price.Value != 0 || price.Payer != protocols.Sender {
+		t.Fatalf("got request price %+v, want it free", price)
+	}
+	price = (&ChunkDelivery{Charge: []uint64{100}}).Price()
+	if price.For(protocols.Receiver, chunk.DefaultSize) != -100 {
+		t.Fatalf("got delivery price %+v, want 100 paid by the receiver for the chunk", price)
+	}
+	price = (&ChunkDelivery{}).Price()
+	if price.Value != swap.ChunkDeliveryPrice || !price.PerByte {
+		t.Fatalf("got delivery price %+v, want the price per byte", price)
+	}
+
+	b, err := rlp.EncodeToBytes(&ChunkDelivery{Ruid: 1, Charge: []uint64{0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cd ChunkDelivery
+	if err := rlp.DecodeBytes(b, &cd); err != nil {
+		t.Fatal(err)
+	}
+	if charge, ok := chunkPrice(cd.Charge); !ok || charge != 0 {
+		t.Fatalf("got charge %v, want zero", cd.Charge)
+	}
+}
+
+// TestChunkPriceEncoding tests that the messages without price are encoded as
+// in the versions before pricedVersion, and that the messages of those versions
+// are decoded without price
+func TestChunkPriceEncoding(t *testing.T) {
+	type legacyRetrieveRequest struct {
+		Ruid     uint
+		Addr     storage.Address
+		HopCount uint8
+	}
+	type legacyChunkDelivery struct {
+		Ruid  uint
+		Addr  storage.Address
+		SData []byte
+	}
+	addr := storage.Address(make([]byte, 32))
+	for _, tc := range []struct {
+		msg, legacy interface{}
+	}{
+		{&RetrieveRequest{Ruid: 1, Addr: addr, HopCount: 2}, &legacyRetrieveRequest{Ruid: 1, Addr: addr, HopCount: 2}},
+		{&ChunkDelivery{Ruid: 1, Addr: addr, SData: []byte{1}}, &legacyChunkDelivery{Ruid: 1, Addr: addr, SData: []byte{1}}},
+	} {
+		b, err := rlp.EncodeToBytes(tc.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := rlp.EncodeToBytes(tc.legacy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Fatalf("%T: got encoding %x, want %x", tc.msg, b, want)
+		}
+	}
+
+	b, err := rlp.EncodeToBytes(&legacyRetrieveRequest{Ruid: 1, Addr: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rr RetrieveRequest
+	if err := rlp.DecodeBytes(b, &rr); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := chunkPrice(rr.MaxPrice); ok {
+		t.Fatalf("got max price %v, want none", rr.MaxPrice)
+	}
+}
+
+// TestChunkPriceVersion tests that retrievals are priced per chunk
+// only with peers running pricedVersion or later
+func TestChunkPriceVersion(t *testing.T) {
+	r := &Retrieval{maxChunkPrice: 100}
+	for _, version := range []uint{pricedVersion - 1, pricedVersion} {
+		caps := []p2p.Cap{{Name: spec.Name, Version: version}}
+		pp := protocols.NewPeer(p2p.NewPeer(enode.ID{}, "", caps), nil, spec)
+		p := &Peer{BzzPeer: &network.BzzPeer{Peer: pp}}
+		priced := version >= pricedVersion
+
+		if _, ok := chunkPrice(r.offeredPrice(p)); ok != priced {
+			t.Errorf("version %d: got offered price %v, want priced %v", version, r.offeredPrice(p), priced)
+		}
+		charge, err := r.chargeFor(p, &RetrieveRequest{MaxPrice: []uint64{swap.DefaultChunkPrice}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := chunkPrice(charge); ok != priced {
+			t.Errorf("version %d: got charge %v, want priced %v", version, charge, priced)
+		}
+	}
+}
+
+// TestFreeForNeighbours tests that chunks are priced free of charge
+// for the peers in the neighbourhood of the node only
+func TestFreeForNeighbours(t *testing.T) {
+	addr := func(b byte) []byte {
+		a := make([]byte, 32)
+		a[0] = b
+		return a
+	}
+	base := addr(0x00)
+	for _, tc := range []struct {
+		name  string
+		peer  byte
+		depth int
+		want  bool
+	}{
+		{"in neighbourhood", 0x01, 2, true},
+		{"at depth", 0x20, 2, true},
+		{"outside neighbourhood", 0x40, 2, false},
+		{"no neighbourhood", 0x80, 0, true},
+	} {
+		if got := isNeighbour(base, addr(tc.peer), tc.depth); got != tc.want {
+			t.Errorf("%s: expected neighbour %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	// the depth of a kademlia without peers is zero, every peer is a neighbour
+	kad := network.NewKademlia(base, network.NewKadParams())
+	pricer := FreeForNeighbours(kad, FixedPrice(100))
+	if price := pricer.ChunkPrice(network.NewBzzAddr(addr(0x80), nil), addr(0x80)); price != 0 {
+		t.Fatalf("got price %d for a neighbour, want free", price)
+	}
+}
+
+// TestPricedRetrieveRequest tests that the chunk delivered for a request priced per
+// chunk is charged its price, that a request not priced per chunk is delivered
+// priced per byte, and that a request offering too little is not served
+func TestPricedRetrieveRequest(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	kad := network.NewKademlia(network.PrivateKeyToBzzKey(pk), network.NewKadParams())
+
+	tester, r, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	r.SetPricer(FixedPrice(100))
+
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	if _, err := ns.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+	node := tester.Nodes[0]
+
+	for _, tc := range []struct {
+		label    string
+		maxPrice []uint64
+		charge   []uint64
+	}{
+		{"priced per chunk", []uint64{200}, []uint64{100}},
+		{"priced per byte", nil, nil},
+	} {
+		err = tester.TestExchanges(p2ptest.Exchange{
+			Label: tc.label,
+			Triggers: []p2ptest.Trigger{
+				{
+					Code: 1,
+					Msg: &RetrieveRequest{
+						Ruid:     1,
+						Addr:     ch.Address(),
+						HopCount: 1,
+						MaxPrice: tc.maxPrice,
+					},
+					Peer: node.ID(),
+				},
+			},
+			Expects: []p2ptest.Expect{
+				{
+					Code: 0,
+					Msg: &ChunkDelivery{
+						Ruid:   1,
+						Addr:   ch.Address(),
+						SData:  ch.Data(),
+						Charge: tc.charge,
+					},
+					Peer: node.ID(),
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := r.getPeer(node.ID())
+	if _, err := r.chargeFor(p, &RetrieveRequest{Addr: ch.Address(), MaxPrice: []uint64{50}}); !errors.Is(err, ErrPriceTooHigh) {
+		t.Fatalf("got error %v, want %v", err, ErrPriceTooHigh)
+	}
+}
+
+// TestOverchargedChunkDelivery tests that a node is dropped if it charges
+// more for a chunk delivery than offered in the retrieve request
+func TestOverchargedChunkDelivery(t *testing.T) {
+	pk, ns, cleanup := newTestNetstore(t)
+	defer cleanup()
+	kad := network.NewKademlia(network.PrivateKeyToBzzKey(pk), network.NewKadParams())
+
+	tester, r, teardown, err := newRetrievalTester(t, pk, ns, kad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	node := tester.Nodes[0]
+
+	for i := 0; i < 1000 && r.getPeer(node.ID()) == nil; i++ {
+		time.Sleep(1 * time.Millisecond)
+	}
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	r.getPeer(node.ID()).addRetrieval(1234, ch.Address(), []uint64{50})
+
+	err = tester.TestExchanges(p2ptest.Exchange{
+		Label: "overcharged chunk delivery",
+		Triggers: []p2ptest.Trigger{
+			{
+				Code: 0,
+				Msg: &ChunkDelivery{
+					Ruid:   1234,
+					Addr:   ch.Address(),
+					SData:  ch.Data(),
+					Charge: []uint64{100},
+				},
+				Peer: node.ID(),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestDisconnected(&p2ptest.Disconnect{Peer: node.ID(), Error: errors.New("subprotocol error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
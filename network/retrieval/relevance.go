@@ -0,0 +1,119 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// FarFetchedPolicy is how the node treats far fetched retrieve requests, the
+// requests for chunks far outside its neighbourhood from peers that are not
+// farther from the chunk than the node itself
+// Forwarding such a request to the node makes no progress towards the chunk, so
+// they are rarely sent by the kademlia routing of honest peers, but are typical
+// of peers probing random addresses to scrape the content of storers
+type FarFetchedPolicy int
+
+const (
+	// FarFetchedServe serves far fetched requests like any other request
+	FarFetchedServe FarFetchedPolicy = iota
+	// FarFetchedLocal serves far fetched requests from the local store only, without forwarding them
+	FarFetchedLocal
+	// FarFetchedReject does not serve far fetched requests
+	FarFetchedReject
+)
+
+// DefaultFarFetchedSlack is the default number of proximity orders a chunk can be
+// outside the neighbourhood of the node before requests for it can be far fetched
+const DefaultFarFetchedSlack = 2
+
+var (
+	// ErrFarFetched is returned when a far fetched retrieve request is rejected
+	ErrFarFetched = errors.New("far fetched retrieve request")
+
+	retrieveRequestFarFetched         = metrics.NewRegisteredCounter("network/retrieve/request_farfetched", nil)
+	retrieveRequestFarFetchedRejected = metrics.NewRegisteredCounter("network/retrieve/request_farfetched_rejected", nil)
+)
+
+var farFetchedPolicyNames = map[FarFetchedPolicy]string{
+	FarFetchedServe:  "serve",
+	FarFetchedLocal:  "local",
+	FarFetchedReject: "reject",
+}
+
+func (p FarFetchedPolicy) String() string {
+	if name, ok := farFetchedPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("FarFetchedPolicy(%d)", int(p))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p FarFetchedPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (p *FarFetchedPolicy) UnmarshalText(text []byte) error {
+	policy, err := ParseFarFetchedPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// ParseFarFetchedPolicy returns the policy with the name "serve", "local" or "reject"
+func ParseFarFetchedPolicy(name string) (FarFetchedPolicy, error) {
+	for p, n := range farFetchedPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return FarFetchedServe, fmt.Errorf("unknown far fetched request policy %q", name)
+}
+
+// SetFarFetchedPolicy sets how far fetched retrieve requests are treated, and the
+// number of proximity orders a chunk can be outside the neighbourhood depth of
+// the node before requests for it are considered far fetched
+// It must be called before the node is started
+func (r *Retrieval) SetFarFetchedPolicy(policy FarFetchedPolicy, slack int) {
+	r.farFetchedPolicy = policy
+	r.farFetchedSlack = slack
+}
+
+// isFarFetched reports whether the request of the peer for the chunk at addr is
+// far fetched, it is never the case within the neighbourhood of the node
+func (r *Retrieval) isFarFetched(p *Peer, addr chunk.Address) bool {
+	return farFetched(r.kad.BaseAddr(), p.Over(), addr, r.kad.NeighbourhoodDepth(), r.farFetchedSlack)
+}
+
+// farFetched reports whether a request from peer for the chunk at addr received
+// by the node at base is far fetched: the chunk is more than slack proximity
+// orders outside the neighbourhood depth of the node, and the peer is at least
+// as close to the chunk as the node
+func farFetched(base, peer, addr []byte, depth, slack int) bool {
+	po := chunk.Proximity(base, addr)
+	if po+slack >= depth {
+		return false
+	}
+	return chunk.Proximity(peer, addr) >= po
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"testing"
+
+	"github.com/naoina/toml"
+)
+
+// TestFarFetched tests which retrieve requests are far fetched
+func TestFarFetched(t *testing.T) {
+	addr := func(b byte) []byte {
+		a := make([]byte, 32)
+		a[0] = b
+		return a
+	}
+	base := addr(0x00)
+	for _, tc := range []struct {
+		name         string
+		peer, chunk  byte
+		depth, slack int
+		want         bool
+	}{
+		{"in neighbourhood", 0x01, 0x01, 8, 2, false},
+		{"within slack", 0x03, 0x02, 8, 2, false},
+		{"progress towards chunk", 0x40, 0x08, 8, 2, false},
+		{"peer closer to chunk", 0x0c, 0x08, 8, 2, true},
+		{"peer as far from chunk", 0x80, 0xc0, 8, 2, true},
+		{"no neighbourhood", 0x80, 0x80, 0, 2, false},
+		{"no slack", 0x03, 0x02, 8, 0, true},
+	} {
+		if got := farFetched(base, addr(tc.peer), addr(tc.chunk), tc.depth, tc.slack); got != tc.want {
+			t.Errorf("%s: expected far fetched %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+// TestFarFetchedPolicyConfig tests that policies are parsed by name and round
+// trip through the toml config
+func TestFarFetchedPolicyConfig(t *testing.T) {
+	for _, p := range []FarFetchedPolicy{FarFetchedServe, FarFetchedLocal, FarFetchedReject} {
+		parsed, err := ParseFarFetchedPolicy(p.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != p {
+			t.Fatalf("expected policy %v, got %v", p, parsed)
+		}
+	}
+	if _, err := ParseFarFetchedPolicy("drop"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+
+	var config struct {
+		Policy FarFetchedPolicy
+	}
+	if err := toml.Unmarshal([]byte(`Policy = "local"`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Policy != FarFetchedLocal {
+		t.Fatalf("expected policy %v, got %v", FarFetchedLocal, config.Policy)
+	}
+}
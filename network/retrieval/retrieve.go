@@ -28,6 +28,7 @@ import (
 
 	opentracing "github.com/opentracing/opentracing-go"
 	olog "github.com/opentracing/opentracing-go/log"
+	"golang.org/x/time/rate"
 
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
@@ -36,29 +37,38 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/metrics/labels"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/spancontext"
+	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
-	"github.com/ethersphere/swarm/swap"
 )
 
 var (
 	// Compile time interface check
 	_ node.Service = &Retrieval{}
 
+	// maxBandwidthBurst is the number of bytes of chunk data delivered at once
+	// under a bandwidth cap, it holds the largest chunks
+	maxBandwidthBurst = 64 * (chunk.DefaultSize + 8)
+
 	// Metrics
 	processReceivedChunksCount    = metrics.NewRegisteredCounter("network/retrieve/received_chunks_handled", nil)
 	handleRetrieveRequestMsgCount = metrics.NewRegisteredCounter("network/retrieve/handle_retrieve_request_msg", nil)
 	retrieveChunkFail             = metrics.NewRegisteredCounter("network/retrieve/retrieve_chunks_fail", nil)
 	unsolicitedChunkDelivery      = metrics.NewRegisteredCounter("network/retrieve/unsolicited_delivery", nil)
+	retrieveRequestDropped        = metrics.NewRegisteredCounter("network/retrieve/request_dropped", nil)
+	retrieveRequestExpired        = metrics.NewRegisteredCounter("network/retrieve/request_expired", nil)
+	retrieveBandwidthExceeded     = metrics.NewRegisteredCounter("network/retrieve/bandwidth_exceeded", nil)
 
 	retrievalPeers = metrics.GetOrRegisterGauge("network/retrieve/peers", nil)
 
 	spec = &protocols.Spec{
 		Name:       "bzz-retrieve",
-		Version:    2,
+		Version:    4,
+		MinVersion: 2, // peers running versions down to MinVersion do not count hops, see countsHops, and retrieve priced per message, see pricesPerChunk
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			ChunkDelivery{},
@@ -69,65 +79,119 @@ var (
 	ErrNoPeerFound = errors.New("no peer found")
 )
 
-// Price is the method through which a message type marks itself
-// as implementing the protocols.Price protocol and thus
-// as swap-enabled message
-func (rr *RetrieveRequest) Price() *protocols.Price {
-	return &protocols.Price{
-		Value:   swap.RetrieveRequestPrice,
-		PerByte: false,
-		Payer:   protocols.Sender,
-	}
-}
-
-// Price is the method through which a message type marks itself
-// as implementing the protocols.Price protocol and thus
-// as swap-enabled message
-func (cd *ChunkDelivery) Price() *protocols.Price {
-	return &protocols.Price{
-		Value:   swap.ChunkDeliveryPrice,
-		PerByte: true,
-		Payer:   protocols.Receiver,
-	}
-}
-
 // Retrieval holds state and handles protocol messages for the `bzz-retrieve` protocol
 type Retrieval struct {
-	netStore    *storage.NetStore
-	baseAddress *network.BzzAddr
-	kad         *network.Kademlia
-	kademliaLB  *network.KademliaLoadBalancer
-	mtx         sync.RWMutex       // protect peer map
-	peers       map[enode.ID]*Peer // compatible peers
-	spec        *protocols.Spec    // protocol spec
-	logger      log.Logger         // custom logger to append a basekey
-	quit        chan struct{}      // shutdown channel
+	netStore         *storage.NetStore
+	baseAddress      *network.BzzAddr
+	kad              *network.Kademlia
+	kademliaLB       *network.KademliaLoadBalancer
+	scheduler        *scheduler            // limits concurrently served retrieve requests
+	bandwidth        *rate.Limiter         // limits the chunk data delivered for retrieve requests, unlimited by default
+	accounting       *protocols.Accounting // accounting hook, nil if swap is disabled
+	history          *peerHistory          // recently connected peers
+	forwards         *forwardCache         // pending forwarded requests, to detect loops
+	maxHops          uint8                 // hop count of the requests originating at this node
+	strategy         ForwardStrategy       // orders the peers of a bin for forwarding, nil keeps the load balancer order
+	farFetchedPolicy FarFetchedPolicy      // how far fetched requests are treated
+	farFetchedSlack  int                   // proximity orders outside the neighbourhood a request can be far fetched from
+	pricer           Pricer                // prices the chunks delivered for requests priced per chunk, nil for the default price
+	maxChunkPrice    uint64                // the most the node pays for a chunk, zero if its requests are not priced per chunk
+	stateStore       state.Store           // persists the peer history, may be nil
+	dial             func(*enode.Node)     // server callback to connect to a historic peer
+	undial           func(*enode.Node)     // server callback to disconnect from a historic peer
+	mtx              sync.RWMutex          // protect peer map
+	peers            map[enode.ID]*Peer    // compatible peers
+	spec             *protocols.Spec       // protocol spec
+	logger           log.Logger            // custom logger to append a basekey
+	quit             chan struct{}         // shutdown channel
 }
 
 // New returns a new instance of the retrieval protocol handler
-func New(kad *network.Kademlia, ns *storage.NetStore, baseKey *network.BzzAddr, balance protocols.Balance) *Retrieval {
+// The history of recently connected peers is persisted in the state store if it is not nil
+func New(kad *network.Kademlia, ns *storage.NetStore, baseKey *network.BzzAddr, balance protocols.Balance, stateStore state.Store) *Retrieval {
 	r := &Retrieval{
-		netStore:    ns,
-		baseAddress: baseKey,
-		kad:         kad,
-		kademliaLB:  network.NewKademliaLoadBalancer(kad, false),
-		peers:       make(map[enode.ID]*Peer),
-		spec:        spec,
-		logger:      log.NewBaseAddressLogger(baseKey.ShortString()),
-		quit:        make(chan struct{}),
+		netStore:        ns,
+		baseAddress:     baseKey,
+		kad:             kad,
+		kademliaLB:      network.NewKademliaLoadBalancer(kad, false),
+		scheduler:       newScheduler(maxServeConcurrency, maxPeerServeConcurrency),
+		bandwidth:       rate.NewLimiter(rate.Inf, maxBandwidthBurst),
+		history:         newPeerHistory(maxHistorySize),
+		forwards:        newForwardCache(),
+		maxHops:         DefaultMaxHops,
+		farFetchedSlack: DefaultFarFetchedSlack,
+		stateStore:      stateStore,
+		peers:           make(map[enode.ID]*Peer),
+		spec:            spec,
+		logger:          log.NewBaseAddressLogger(baseKey.ShortString()),
+		quit:            make(chan struct{}),
 	}
 	if balance != nil && !reflect.ValueOf(balance).IsNil() {
 		// swap is enabled, so setup the hook
-		r.spec.Hook = protocols.NewAccounting(balance)
+		r.accounting = protocols.NewAccounting(balance)
+		r.spec.Hook = r.accounting
 	}
 	return r
 }
 
+// SetFreeQuota sets the number of chunks and bytes every peer can retrieve from
+// the node, and the node from every peer, in each window without being accounted,
+// so that peers without swap can retrieve small amounts of content
+// Zero limits are not enforced, the quota is disabled if both are zero
+// It has no effect if swap is disabled, as then no retrieval is accounted
+func (r *Retrieval) SetFreeQuota(chunks, bytes int64, window time.Duration) {
+	if r.accounting == nil {
+		return
+	}
+	var store state.Store
+	if r.stateStore != nil {
+		store = r.stateStore.Namespace("retrieval")
+	}
+	// both the request and the delivery of a chunk are priced messages
+	r.accounting.SetFreeQuota(protocols.FreeQuota{
+		Messages: 2 * chunks,
+		Bytes:    bytes,
+		Window:   window,
+	}, store)
+}
+
+// SetBandwidth sets the number of bytes of chunk data per second the node delivers
+// at most for the retrieve requests of all peers, zero removes the cap
+// Up to maxBandwidthBurst bytes are delivered at once after an idle period
+func (r *Retrieval) SetBandwidth(bytesPerSecond int64) {
+	limit := rate.Inf
+	if bytesPerSecond > 0 {
+		limit = rate.Limit(bytesPerSecond)
+	}
+	r.bandwidth.SetLimit(limit)
+}
+
+// SetMaxHops sets the number of times the retrieve requests of the node are
+// forwarded at most, received requests travelling farther are not forwarded
+// It must be called before the node is started
+func (r *Retrieval) SetMaxHops(hops uint8) {
+	if hops == 0 {
+		hops = DefaultMaxHops
+	}
+	r.maxHops = hops
+}
+
+// SetForwardStrategy sets the order in which the peers of a kademlia bin are
+// tried for forwarding retrieve requests, the load balancer order if nil
+// It must be called before the node is started
+func (r *Retrieval) SetForwardStrategy(strategy ForwardStrategy) {
+	r.strategy = strategy
+}
+
 func (r *Retrieval) addPeer(p *Peer) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
 	r.peers[p.ID()] = p
 	retrievalPeers.Update(int64(len(r.peers)))
+	if p.IsRetrieveRelay() {
+		r.history.seen(p.BzzAddr)
+	}
+	r.history.connected(p.ID())
 }
 
 func (r *Retrieval) removePeer(p *Peer) {
@@ -135,6 +199,10 @@ func (r *Retrieval) removePeer(p *Peer) {
 	defer r.mtx.Unlock()
 	delete(r.peers, p.ID())
 	retrievalPeers.Update(int64(len(r.peers)))
+	labels.Unregister(nil, "network/retrieve/peer/", labels.PeerKey, labels.Peer(p.BzzAddr.Over()))
+	if p.IsRetrieveRelay() {
+		r.history.seen(p.BzzAddr)
+	}
 }
 
 func (r *Retrieval) getPeer(id enode.ID) *Peer {
@@ -216,7 +284,11 @@ func (r *Retrieval) findPeerLB(ctx context.Context, req *storage.Request) (retPe
 	}
 
 	r.kademliaLB.EachBinDesc(req.Addr, func(bin network.LBBin) bool {
-		for _, lbPeer := range bin.LBPeers {
+		peers := bin.LBPeers
+		if r.strategy != nil {
+			peers = r.strategy(peers)
+		}
+		for _, lbPeer := range peers {
 			id := lbPeer.Peer.ID()
 
 			// skip peer that does not support retrieval
@@ -224,6 +296,11 @@ func (r *Retrieval) findPeerLB(ctx context.Context, req *storage.Request) (retPe
 				continue
 			}
 
+			// skip peer that does not advertise relaying retrieve requests, i.e. light nodes
+			if !lbPeer.Peer.IsRetrieveRelay() {
+				continue
+			}
+
 			// do not send request back to peer who asked us. maybe merge with SkipPeer at some point
 			if bytes.Equal(req.Origin.Bytes(), id.Bytes()) {
 				continue
@@ -293,7 +370,9 @@ func (r *Retrieval) findPeerLB(ctx context.Context, req *storage.Request) (retPe
 
 // handleRetrieveRequest handles an incoming retrieve request from a certain Peer
 // if the chunk is found in the localstore it is served immediately, otherwise
-// it results in a new retrieve request to candidate peers in our kademlia
+// it results in a new retrieve request to candidate peers in our kademlia,
+// if the node relays retrieve requests, which nodes that do not store chunks
+// only do if they advertise the relay capability
 func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *RetrieveRequest) error {
 	p.logger.Debug("retrieval.handleRetrieveRequest", "ref", msg.Addr)
 	handleRetrieveRequestMsgCount.Inc(1)
@@ -306,12 +385,57 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 
 	defer osp.Finish()
 
-	ctx, cancel := context.WithTimeout(ctx, timeouts.FetcherGlobalTimeout)
+	ctx, cancel := storage.WithFetcherTimeout(ctx)
 	defer cancel()
 
+	// the requests of peers that do not count hops may travel as far as the node's own
+	hops := r.maxHops
+	if p.countsHops() {
+		hops = receivedHops(msg.HopCount, r.maxHops)
+	}
+	if r.forwards.isLoop(msg.Addr, hops) {
+		// the request is only served from the local store or by the pending
+		// request for the chunk, it is not forwarded around the loop again
+		retrieveRequestLoop.Inc(1)
+		p.logger.Debug("retrieval.handleRetrieveRequest - looping request", "ref", msg.Addr, "hops", msg.HopCount)
+		osp.LogFields(olog.Bool("loop", true))
+		hops = 1
+	}
+	if !r.baseAddress.IsRetrieveRelay() {
+		// the node does not advertise relaying, so it only serves
+		// the requests of its peers from the local store
+		retrieveRequestNoRelay.Inc(1)
+		hops = 1
+	}
+	if r.farFetchedPolicy != FarFetchedServe && r.isFarFetched(p, msg.Addr) {
+		retrieveRequestFarFetched.Inc(1)
+		p.logger.Debug("retrieval.handleRetrieveRequest - far fetched request", "ref", msg.Addr, "policy", r.farFetchedPolicy)
+		osp.LogFields(olog.Bool("farfetched", true))
+		if r.farFetchedPolicy == FarFetchedReject {
+			retrieveRequestFarFetchedRejected.Inc(1)
+			return fmt.Errorf("retrieval.handleRetrieveRequest - rejecting request for ref %s: %w", msg.Addr, ErrFarFetched)
+		}
+		// the chunk is served if the node has it, but the request is not forwarded
+		hops = 1
+	}
+
+	charge, err := r.chargeFor(p, msg)
+	if err != nil {
+		p.logger.Debug("retrieval.handleRetrieveRequest - price too high", "ref", msg.Addr, "max price", msg.MaxPrice)
+		return fmt.Errorf("retrieval.handleRetrieveRequest - not serving request for ref %s: %w", msg.Addr, err)
+	}
+
+	release, err := r.scheduler.acquire(ctx, p.ID())
+	if err != nil {
+		r.dropRetrieveRequest(p, msg)
+		return fmt.Errorf("retrieval.handleRetrieveRequest - dropping request for ref %s: %w", msg.Addr, err)
+	}
+	defer release()
+
 	req := &storage.Request{
-		Addr:   msg.Addr,
-		Origin: p.ID(),
+		Addr:     msg.Addr,
+		Origin:   p.ID(),
+		HopCount: hops,
 	}
 	chunk, err := r.netStore.Get(ctx, chunk.ModeGetRequest, req)
 	if err != nil {
@@ -319,12 +443,18 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 		return fmt.Errorf("netstore.Get can not retrieve chunk for ref %s: %w", msg.Addr, err)
 	}
 
+	if err := r.bandwidth.WaitN(ctx, len(chunk.Data())); err != nil {
+		retrieveBandwidthExceeded.Inc(1)
+		return fmt.Errorf("retrieval.handleRetrieveRequest - bandwidth cap for ref %s: %w", msg.Addr, err)
+	}
+
 	p.logger.Trace("retrieval.handleRetrieveRequest - delivery", "ref", msg.Addr)
 
 	deliveryMsg := &ChunkDelivery{
-		Ruid:  msg.Ruid,
-		Addr:  chunk.Address(),
-		SData: chunk.Data(),
+		Ruid:   msg.Ruid,
+		Addr:   chunk.Address(),
+		SData:  chunk.Data(),
+		Charge: charge,
 	}
 
 	err = p.Send(ctx, deliveryMsg)
@@ -336,16 +466,34 @@ func (r *Retrieval) handleRetrieveRequest(ctx context.Context, p *Peer, msg *Ret
 	return nil
 }
 
+// dropRetrieveRequest accounts for a retrieve request of the peer that is not served
+// because the peer exceeded its cap or no slot became free in time
+func (r *Retrieval) dropRetrieveRequest(p *Peer, msg *RetrieveRequest) {
+	p.logger.Debug("retrieval.handleRetrieveRequest - dropping request", "ref", msg.Addr)
+	retrieveRequestDropped.Inc(1)
+	metrics.GetOrRegisterCounter(labels.Name("network/retrieve/peer/request_dropped", labels.PeerKey, labels.Peer(p.BzzAddr.Over())), nil).Inc(1)
+	if r.accounting != nil {
+		r.accounting.Drop(p.Peer)
+	}
+}
+
 // handleChunkDelivery handles a ChunkDelivery message from a certain peer
 // if the chunk proximity order in relation to our base address is within depth
 // we treat the chunk as a chunk received in syncing
 func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *ChunkDelivery) error {
 	p.logger.Debug("retrieval.handleChunkDelivery", "ref", msg.Addr)
-	err := p.checkRequest(msg.Ruid, msg.Addr)
+	rtt, err := p.checkRequest(msg.Ruid, msg.Addr, msg.Charge)
+	if errors.Is(err, ErrOvercharged) {
+		return protocols.Break(fmt.Errorf("chunk delivery from peer, ruid %d, addr %s, charge %v: %w", msg.Ruid, msg.Addr, msg.Charge, err))
+	}
 	if err != nil {
 		unsolicitedChunkDelivery.Inc(1)
 		return protocols.Break(fmt.Errorf("unsolicited chunk delivery from peer, ruid %d, addr %s: %w", msg.Ruid, msg.Addr, err))
 	}
+	// the round trip times of the retrievals are the latency of the peer in kademlia,
+	// they cover the whole fetch by the peer, which may forward the request over
+	// multiple hops, not only the link to the peer
+	r.kad.UpdateLatency(p.Over(), rtt)
 	var osp opentracing.Span
 	ctx, osp = spancontext.StartSpan(
 		ctx,
@@ -356,6 +504,7 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 	// count how many chunks we receive for retrieve requests per peer
 	peermetric := fmt.Sprintf("network/retrieve/chunk/delivery/%x", p.BzzAddr.Over()[:16])
 	metrics.GetOrRegisterCounter(peermetric, nil).Inc(1)
+	metrics.GetOrRegisterCounter(labels.Name("network/retrieve/peer/chunk_delivery", labels.PeerKey, labels.Peer(p.BzzAddr.Over())), nil).Inc(1)
 
 	peerPO := chunk.Proximity(p.BzzAddr.Over(), msg.Addr)
 	po := chunk.Proximity(r.kad.BaseAddr(), msg.Addr)
@@ -386,40 +535,62 @@ func (r *Retrieval) handleChunkDelivery(ctx context.Context, p *Peer, msg *Chunk
 // RequestFromPeers sends a chunk retrieve request to the next found peer.
 // returns the next peer to try, a cleanup function to expire retrievals that were never delivered
 func (r *Retrieval) RequestFromPeers(ctx context.Context, req *storage.Request, localID enode.ID) (*enode.ID, func(), error) {
-	r.logger.Debug("retrieval.requestFromPeers", "req.Addr", req.Addr, "localID", localID)
+	logger := log.WithRequest(ctx, r.logger)
+	logger.Debug("retrieval.requestFromPeers", "req.Addr", req.Addr, "localID", localID)
 	metrics.GetOrRegisterCounter("network/retrieve/request_from_peers", nil).Inc(1)
 
+	hops, err := nextHops(req, r.maxHops)
+	if err != nil {
+		logger.Trace("not forwarding retrieve request", "ref", req.Addr, "err", err)
+		return nil, func() {}, err
+	}
+
 	const maxFindPeerRetries = 5
 	retries := 0
 
+	var protoPeer *Peer
+
 FINDPEER:
 	sp, err := r.findPeerLB(ctx, req)
-	if err != nil {
-		r.logger.Trace(err.Error())
-		return nil, func() {}, err
+	if err == ErrNoPeerFound {
+		// none of the connected peers is left to ask, fall back to
+		// the peers that were close to the chunk recently
+		protoPeer = r.historicPeer(ctx, req)
 	}
-
-	protoPeer := r.getPeer(sp.ID())
 	if protoPeer == nil {
-		r.logger.Trace("findPeer returned a peer to skip", "peer", sp.String(), "retry", retries, "ref", req.Addr)
-		req.PeersToSkip.Store(sp.ID().String(), time.Now())
-		retries++
-		if retries == maxFindPeerRetries {
-			r.logger.Trace("max find peer retries reached", "max retries", maxFindPeerRetries, "ref", req.Addr)
-			return nil, func() {}, ErrNoPeerFound
+		if err != nil {
+			logger.Trace(err.Error())
+			return nil, func() {}, err
 		}
 
-		goto FINDPEER
+		protoPeer = r.getPeer(sp.ID())
+		if protoPeer == nil {
+			logger.Trace("findPeer returned a peer to skip", "peer", sp.String(), "retry", retries, "ref", req.Addr)
+			req.PeersToSkip.Store(sp.ID().String(), time.Now())
+			retries++
+			if retries == maxFindPeerRetries {
+				logger.Trace("max find peer retries reached", "max retries", maxFindPeerRetries, "ref", req.Addr)
+				return nil, func() {}, ErrNoPeerFound
+			}
+
+			goto FINDPEER
+		}
 	}
 
 	ret := &RetrieveRequest{
-		Ruid: uint(rand.Uint32()),
-		Addr: req.Addr,
+		Ruid:     uint(rand.Uint32()),
+		Addr:     req.Addr,
+		MaxPrice: r.offeredPrice(protoPeer),
+	}
+	if protoPeer.countsHops() {
+		ret.HopCount = hops
 	}
-	protoPeer.logger.Trace("sending retrieve request", "ref", ret.Addr, "origin", localID, "ruid", ret.Ruid)
-	protoPeer.addRetrieval(ret.Ruid, ret.Addr)
+	protoPeer.logger.Trace("sending retrieve request", "ref", ret.Addr, "origin", localID, "ruid", ret.Ruid, "hops", hops)
+	protoPeer.addRetrieval(ret.Ruid, ret.Addr, ret.MaxPrice)
+	unforward := r.forwards.add(ret.Addr, hops)
 	cleanup := func() {
-		protoPeer.expireRetrieval(ret.Ruid)
+		r.expireRetrieval(protoPeer, ret.Ruid)
+		unforward()
 	}
 	err = protoPeer.Send(ctx, ret)
 	if err != nil {
@@ -432,8 +603,32 @@ FINDPEER:
 	return &spID, cleanup, nil
 }
 
+// expireRetrieval removes the retrieval from the peer once the fetch is over,
+// a retrieval not delivered within the search timeout counts as a round trip
+// of the time elapsed in the latency of the peer, so that peers that time out
+// are not preferred by the latency strategy
+func (r *Retrieval) expireRetrieval(p *Peer, ruid uint) {
+	elapsed, ok := p.expireRetrieval(ruid)
+	// historic peers are not kept connected once their retrievals are over
+	if node := p.undial(); node != nil {
+		r.undialHistoric(node)
+	}
+	if !ok || elapsed < timeouts.SearchTimeout {
+		return
+	}
+	retrieveRequestExpired.Inc(1)
+	r.kad.UpdateLatency(p.Over(), elapsed)
+}
+
 func (r *Retrieval) Start(server *p2p.Server) error {
 	r.logger.Info("starting bzz-retrieve")
+	r.dial = server.AddPeer
+	r.undial = server.RemovePeer
+	if r.stateStore != nil {
+		if err := r.history.load(r.stateStore); err != nil {
+			r.logger.Warn("could not load retrieval peer history", "err", err)
+		}
+	}
 	return nil
 }
 
@@ -441,6 +636,11 @@ func (r *Retrieval) Stop() error {
 	r.logger.Info("shutting down bzz-retrieve")
 	close(r.quit)
 	r.kademliaLB.Stop()
+	if r.stateStore != nil {
+		if err := r.history.save(r.stateStore); err != nil {
+			r.logger.Warn("could not save retrieval peer history", "err", err)
+		}
+	}
 	return nil
 }
 
@@ -463,7 +663,17 @@ func (r *Retrieval) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (r *Retrieval) APIs() []rpc.API {
-	return nil
+	if r.accounting == nil {
+		return nil
+	}
+	return []rpc.API{
+		{
+			Namespace: "accounting",
+			Version:   protocols.AccountingVersion,
+			Service:   protocols.NewFreeQuotaApi(r.accounting),
+			Public:    false,
+		},
+	}
 }
 
 func (r *Retrieval) Spec() *protocols.Spec {
@@ -44,6 +44,7 @@ import (
 	chunktesting "github.com/ethersphere/swarm/chunk/testing"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/simulation"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/state"
@@ -278,7 +279,7 @@ func TestUnsolicitedChunkDeliveryFaultyAddr(t *testing.T) {
 		time.Sleep(1 * time.Millisecond)
 	}
 	// inject a supposed retrieve request that was sent to that peer
-	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3})
+	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3}, nil)
 
 	// respond with a chunk delivery with the same Ruid but with a different chunk address
 	err = tester.TestExchanges(
@@ -352,7 +353,7 @@ func TestUnsolicitedChunkDeliveryDouble(t *testing.T) {
 		time.Sleep(1 * time.Millisecond)
 	}
 	// inject a supposed retrieve request that was sent to that peer
-	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3})
+	r.getPeer(node.ID()).addRetrieval(1234, []byte{0, 1, 2, 3}, nil)
 
 	// respond with a chunk delivery with the same Ruid and the matching chunk address
 	err = tester.TestExchanges(
@@ -506,7 +507,7 @@ func TestRequestFromPeers(t *testing.T) {
 
 	to.On(peer)
 
-	s := New(to, nil, addr, nil)
+	s := New(to, nil, addr, nil, nil)
 
 	req := storage.NewRequest(storage.Address(hash0[:]))
 	id, err := s.findPeerLB(context.Background(), req)
@@ -519,6 +520,139 @@ func TestRequestFromPeers(t *testing.T) {
 	}
 }
 
+// TestExpiredRetrievalLatency tests that a retrieval not delivered within the
+// search timeout is recorded as a round trip of the time elapsed in the latency
+// of the peer, and that delivered or quickly expired retrievals are not
+func TestExpiredRetrievalLatency(t *testing.T) {
+	dummyPeerID := enode.HexID("3431c3939e1ee2a6345e976a8234f9870152d64879f30bc272a074f6859e75e8")
+
+	addr := network.RandomBzzAddr()
+	kad := network.NewKademlia(addr.OAddr, network.NewKadParams())
+	bzzPeer := &network.BzzPeer{
+		BzzAddr: network.RandomBzzAddr(),
+		Peer:    protocols.NewPeer(p2p.NewPeer(dummyPeerID, "dummy", []p2p.Cap{{Name: "bzz-retrieve", Version: 1}}), nil, nil),
+	}
+	peer := network.NewPeer(bzzPeer, kad)
+	kad.On(peer)
+
+	r := New(kad, nil, addr, nil, nil)
+	p := NewPeer(bzzPeer, addr)
+	ref := storage.Address(hash0[:])
+
+	// delivered and quickly expired retrievals leave the latency unmeasured
+	p.addRetrieval(1, ref, nil)
+	if _, err := p.checkRequest(1, ref, nil); err != nil {
+		t.Fatal(err)
+	}
+	r.expireRetrieval(p, 1)
+	p.addRetrieval(2, ref, nil)
+	r.expireRetrieval(p, 2)
+	if latency := peer.Latency(); latency != 0 {
+		t.Fatalf("expected no latency, got %v", latency)
+	}
+
+	p.addRetrieval(3, ref, nil)
+	p.retrievals[3] = pendingRetrieval{
+		addr: ref,
+		sent: time.Now().Add(-2 * timeouts.SearchTimeout),
+	}
+	r.expireRetrieval(p, 3)
+	if latency := peer.Latency(); latency < 2*timeouts.SearchTimeout {
+		t.Fatalf("expected latency of at least %v, got %v", 2*timeouts.SearchTimeout, latency)
+	}
+}
+
+type testBalances map[enode.ID]int64
+
+func (b testBalances) PeerBalance(peer enode.ID) (int64, error) {
+	balance, ok := b[peer]
+	if !ok {
+		return 0, state.ErrNotFound
+	}
+	return balance, nil
+}
+
+func (b testBalances) Thresholds() (payment, disconnect int64) {
+	return 50, 100
+}
+
+// TestBalanceStrategy tests that peers in debt to the node are tried first
+// and peers the node owes close to the disconnect threshold last
+func TestBalanceStrategy(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	kad := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	balances := testBalances{}
+	var peers []network.LBPeer
+	for i, balance := range []int64{-95, 0, 10, -20, 0, 30, -90} {
+		id := enode.ID{byte(i + 1)}
+		p := network.NewPeer(&network.BzzPeer{
+			BzzAddr: network.RandomBzzAddr(),
+			Peer:    protocols.NewPeer(p2p.NewPeer(id, "dummy", nil), nil, nil),
+		}, kad)
+		peers = append(peers, network.LBPeer{Peer: p})
+		// the peer at index 4 has no balance
+		if i != 4 {
+			balances[id] = balance
+		}
+	}
+
+	sorted := BalanceStrategy(balances)(peers)
+	// creditors first, then the others in the given order, the peers close to the disconnect threshold last
+	want := []int{2, 5, 1, 3, 4, 0, 6}
+	for i, j := range want {
+		if sorted[i].Peer.ID() != peers[j].Peer.ID() {
+			t.Fatalf("position %d: expected peer %d, got %v", i, j, sorted[i].Peer.ID())
+		}
+	}
+	if peers[0].Peer.ID() != (enode.ID{1}) {
+		t.Fatal("expected the given peers not to be reordered")
+	}
+}
+
+// TestLatencyStrategy tests that peers are tried by increasing latency, the ones
+// without a measured latency first, and that the balance strategy takes
+// precedence if it is combined with it
+func TestLatencyStrategy(t *testing.T) {
+	addr := network.RandomBzzAddr()
+	kad := network.NewKademlia(addr.OAddr, network.NewKadParams())
+
+	balances := testBalances{}
+	var peers []network.LBPeer
+	for i, latency := range []time.Duration{300, 0, 100, 200, 0} {
+		id := enode.ID{byte(i + 1)}
+		p := network.NewPeer(&network.BzzPeer{
+			BzzAddr: network.RandomBzzAddr(),
+			Peer:    protocols.NewPeer(p2p.NewPeer(id, "dummy", nil), nil, nil),
+		}, kad)
+		if latency > 0 {
+			p.UpdateLatency(latency * time.Millisecond)
+		}
+		peers = append(peers, network.LBPeer{Peer: p})
+		balances[id] = 0
+	}
+	// the slowest peer is in debt to the node
+	balances[enode.ID{1}] = 10
+
+	for _, tc := range []struct {
+		strategy ForwardStrategy
+		want     []int
+	}{
+		{LatencyStrategy(), []int{1, 4, 2, 3, 0}},
+		{CombineStrategies(LatencyStrategy(), BalanceStrategy(balances)), []int{0, 1, 4, 2, 3}},
+	} {
+		sorted := tc.strategy(peers)
+		for i, j := range tc.want {
+			if sorted[i].Peer.ID() != peers[j].Peer.ID() {
+				t.Fatalf("position %d: expected peer %d, got %v", i, j, sorted[i].Peer.ID())
+			}
+		}
+	}
+	if CombineStrategies() != nil {
+		t.Fatal("expected no strategy to keep the load balancer order")
+	}
+}
+
 //TestHasPriceImplementation is to check that Retrieval provides priced messages
 func TestHasPriceImplementation(t *testing.T) {
 	price := (&ChunkDelivery{}).Price()
@@ -559,7 +693,7 @@ func newBzzRetrieveWithLocalstore(ctx *adapters.ServiceContext, bucket *sync.Map
 		return nil, nil, err
 	}
 
-	r := New(kad, netStore, addr, nil)
+	r := New(kad, netStore, addr, nil, store)
 	netStore.RemoteGet = r.RequestFromPeers
 	bucket.Store(bucketKeyFileStore, fileStore)
 	bucket.Store(bucketKeyNetstore, netStore)
@@ -678,7 +812,7 @@ func newRetrievalTester(t *testing.T, prvkey *ecdsa.PrivateKey, netStore *storag
 		prvkey = key
 	}
 
-	r := New(kad, netStore, network.NewBzzAddr(kad.BaseAddr(), nil), nil)
+	r := New(kad, netStore, network.NewBzzAddr(kad.BaseAddr(), nil), nil, nil)
 	protocolTester := p2ptest.NewProtocolTester(prvkey, 1, r.runProtocol)
 
 	return protocolTester, r, protocolTester.Stop, nil
@@ -0,0 +1,158 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var (
+	// maxServeConcurrency is the number of retrieve requests served concurrently from all peers
+	maxServeConcurrency = 64
+	// maxPeerServeConcurrency is the number of retrieve requests of a single peer
+	// that can be served or waiting to be served at the same time
+	maxPeerServeConcurrency = 8
+
+	// ErrPeerBusy is returned when a peer exceeds its cap of concurrent retrieve requests
+	ErrPeerBusy = errors.New("too many concurrent requests from peer")
+)
+
+// scheduler limits the number of retrieve requests served concurrently and
+// hands out free slots to the waiting peers in round robin order, so that
+// a single peer flooding us with requests cannot monopolize the local store
+type scheduler struct {
+	mtx      sync.Mutex
+	capacity int                          // number of requests served concurrently
+	peerCap  int                          // number of requests of a peer served or waiting
+	active   int                          // number of requests being served
+	held     map[enode.ID]int             // number of requests of a peer being served or waiting
+	waiting  map[enode.ID][]chan struct{} // requests of a peer waiting for a slot
+	order    []enode.ID                   // peers with waiting requests in round robin order
+}
+
+func newScheduler(capacity, peerCap int) *scheduler {
+	return &scheduler{
+		capacity: capacity,
+		peerCap:  peerCap,
+		held:     make(map[enode.ID]int),
+		waiting:  make(map[enode.ID][]chan struct{}),
+	}
+}
+
+// acquire blocks until a request of the peer can be served and returns the
+// function that must be called once serving it is done
+// It returns ErrPeerBusy immediately if the peer is over its cap, and the
+// context error if the context is done before a slot is available
+func (s *scheduler) acquire(ctx context.Context, id enode.ID) (func(), error) {
+	release := func() { s.release(id) }
+
+	s.mtx.Lock()
+	if s.held[id] >= s.peerCap {
+		s.mtx.Unlock()
+		return nil, ErrPeerBusy
+	}
+	s.held[id]++
+	if s.active < s.capacity && len(s.order) == 0 {
+		s.active++
+		s.mtx.Unlock()
+		return release, nil
+	}
+	c := make(chan struct{})
+	if len(s.waiting[id]) == 0 {
+		s.order = append(s.order, id)
+	}
+	s.waiting[id] = append(s.waiting[id], c)
+	s.mtx.Unlock()
+
+	select {
+	case <-c:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	s.mtx.Lock()
+	select {
+	case <-c:
+		// the slot was granted while the context was done
+		s.mtx.Unlock()
+		release()
+		return nil, ctx.Err()
+	default:
+	}
+	s.remove(id, c)
+	s.held[id]--
+	if s.held[id] == 0 {
+		delete(s.held, id)
+	}
+	s.mtx.Unlock()
+	return nil, ctx.Err()
+}
+
+// release frees the slot of a served request of the peer and grants it to
+// the first waiting request of the next peer in round robin order
+func (s *scheduler) release(id enode.ID) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.active--
+	s.held[id]--
+	if s.held[id] == 0 {
+		delete(s.held, id)
+	}
+	if len(s.order) == 0 {
+		return
+	}
+	next := s.order[0]
+	s.order = s.order[1:]
+	queue := s.waiting[next]
+	c := queue[0]
+	if len(queue) == 1 {
+		delete(s.waiting, next)
+	} else {
+		s.waiting[next] = queue[1:]
+		s.order = append(s.order, next)
+	}
+	s.active++
+	close(c)
+}
+
+// remove deletes a waiting request of the peer
+// the caller is expected to hold the lock
+func (s *scheduler) remove(id enode.ID, c chan struct{}) {
+	queue := s.waiting[id]
+	for i, w := range queue {
+		if w == c {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		s.waiting[id] = queue
+		return
+	}
+	delete(s.waiting, id)
+	for i, o := range s.order {
+		if o == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
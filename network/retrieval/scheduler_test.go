@@ -0,0 +1,180 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/metrics/labels"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+// TestSchedulerPeerCap tests that a peer cannot hold more than its cap of requests
+func TestSchedulerPeerCap(t *testing.T) {
+	s := newScheduler(4, 2)
+	a := enode.ID{1}
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := s.acquire(context.Background(), a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := s.acquire(context.Background(), a); err != ErrPeerBusy {
+		t.Fatalf("expected %v, got %v", ErrPeerBusy, err)
+	}
+	// other peers are not affected
+	release, err := s.acquire(context.Background(), enode.ID{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	releases[0]()
+	release, err = s.acquire(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	releases[1]()
+
+	if s.active != 0 || len(s.held) != 0 {
+		t.Fatalf("expected no held slots, got active %d held %v", s.active, s.held)
+	}
+}
+
+// TestSchedulerRoundRobin tests that free slots are granted to the waiting peers in turns
+func TestSchedulerRoundRobin(t *testing.T) {
+	s := newScheduler(1, 3)
+	a, b := enode.ID{1}, enode.ID{2}
+
+	release, err := s.acquire(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	granted := make(chan enode.ID)
+	wait := func(id enode.ID) {
+		r, err := s.acquire(context.Background(), id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		granted <- id
+		r()
+	}
+	// peer a queues two requests before peer b queues one
+	for i, id := range []enode.ID{a, a, b} {
+		go wait(id)
+		waitQueued(t, s, i+1)
+	}
+
+	release()
+	for i, want := range []enode.ID{a, b, a} {
+		if got := <-granted; got != want {
+			t.Fatalf("grant %d: expected peer %v, got %v", i, want, got)
+		}
+	}
+}
+
+// TestSchedulerContextDone tests that a waiting request gives up its place when its context is done
+func TestSchedulerContextDone(t *testing.T) {
+	s := newScheduler(1, 2)
+	a, b := enode.ID{1}, enode.ID{2}
+
+	release, err := s.acquire(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(ctx, b); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if len(s.order) != 0 || s.held[b] != 0 {
+		t.Fatalf("expected peer to be removed from the queue, got order %v held %d", s.order, s.held[b])
+	}
+	release()
+	if s.active != 0 {
+		t.Fatalf("expected no active requests, got %d", s.active)
+	}
+}
+
+// TestDropRetrieveRequestMetrics tests that dropped requests are only counted in the
+// total and in the metric labelled by the peer, which is removed when the peer disconnects
+func TestDropRetrieveRequestMetrics(t *testing.T) {
+	base := network.RandomBzzAddr()
+	kad := network.NewKademlia(base.Over(), network.NewKadParams())
+	r := New(kad, nil, base, nil, nil)
+
+	addr := network.RandomBzzAddr()
+	p := NewPeer(&network.BzzPeer{
+		BzzAddr: addr,
+		Peer:    protocols.NewPeer(p2p.NewPeer(addr.ID(), "peer", nil), nil, nil),
+	}, base)
+	r.addPeer(p)
+	r.dropRetrieveRequest(p, &RetrieveRequest{Addr: addr.Over()})
+
+	peerMetrics := func() (names []string) {
+		metrics.DefaultRegistry.Each(func(name string, _ interface{}) {
+			if strings.HasPrefix(name, "network/retrieve/") && strings.Contains(name, labels.Peer(addr.Over())) {
+				names = append(names, name)
+			}
+		})
+		return names
+	}
+	exp := labels.Name("network/retrieve/peer/request_dropped", labels.PeerKey, labels.Peer(addr.Over()))
+	if names := peerMetrics(); len(names) != 1 || names[0] != exp {
+		t.Fatalf("expected peer metrics %v, got %v", []string{exp}, names)
+	}
+	if metrics.DefaultRegistry.Get("network/retrieve/request_dropped") == nil {
+		t.Fatal("expected total of dropped requests to be registered")
+	}
+
+	r.removePeer(p)
+	if names := peerMetrics(); len(names) != 0 {
+		t.Fatalf("expected no peer metrics after disconnection, got %v", names)
+	}
+}
+
+// waitQueued waits until the scheduler has n requests waiting for a slot
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mtx.Lock()
+		var waiting int
+		for _, queue := range s.waiting {
+			waiting += len(queue)
+		}
+		s.mtx.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %d queued requests", n)
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package retrieval
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network"
+)
+
+// ForwardStrategy orders the peers of a kademlia bin by preference for forwarding
+// a retrieve request to them. The peers are given least used first, the order of
+// the load balancer, which the strategy is expected to keep among equal peers.
+type ForwardStrategy func(peers []network.LBPeer) []network.LBPeer
+
+// Balances gives the accounting balances with peers, swap.Swap implements it
+// A positive balance is owed to the node by the peer, a negative one by the node
+type Balances interface {
+	PeerBalance(peer enode.ID) (int64, error)
+	Thresholds() (payment, disconnect int64)
+}
+
+// nearDisconnectRatio is the share of the disconnect threshold the debt of the
+// node to a peer is considered close to it from, in tenths
+const nearDisconnectRatio = 9
+
+// BalanceStrategy returns the ForwardStrategy that prefers the peers that are in
+// debt to the node, whose service it already paid for, and tries the peers the
+// node owes almost as much as they disconnect at last, as requesting chunks
+// from them adds to the debt
+func BalanceStrategy(b Balances) ForwardStrategy {
+	return func(peers []network.LBPeer) []network.LBPeer {
+		_, disconnect := b.Thresholds()
+		nearDisconnect := disconnect / 10 * nearDisconnectRatio
+
+		rank := func(p network.LBPeer) int {
+			balance, err := b.PeerBalance(p.Peer.ID())
+			switch {
+			case err != nil:
+				return 1
+			case balance > 0:
+				return 0
+			case -balance >= nearDisconnect:
+				return 2
+			}
+			return 1
+		}
+
+		ranks := make(map[enode.ID]int, len(peers))
+		for _, p := range peers {
+			ranks[p.Peer.ID()] = rank(p)
+		}
+		sorted := make([]network.LBPeer, len(peers))
+		copy(sorted, peers)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return ranks[sorted[i].Peer.ID()] < ranks[sorted[j].Peer.ID()]
+		})
+		return sorted
+	}
+}
+
+// LatencyStrategy returns the ForwardStrategy that breaks the tie between the
+// equally close peers of a bin by their latency, the peers with the lowest round
+// trip time are tried first. The peers whose latency was not measured yet are
+// tried before all others, so that every peer is measured
+func LatencyStrategy() ForwardStrategy {
+	return func(peers []network.LBPeer) []network.LBPeer {
+		latencies := make(map[enode.ID]time.Duration, len(peers))
+		for _, p := range peers {
+			latencies[p.Peer.ID()] = p.Peer.Latency()
+		}
+		sorted := make([]network.LBPeer, len(peers))
+		copy(sorted, peers)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return latencies[sorted[i].Peer.ID()] < latencies[sorted[j].Peer.ID()]
+		})
+		return sorted
+	}
+}
+
+// CombineStrategies returns the ForwardStrategy that orders the peers by each
+// of the strategies in turn. As the strategies keep the order of equal peers,
+// the last one takes precedence and the ones before it break its ties
+// It returns nil, the load balancer order, if there are no strategies
+func CombineStrategies(strategies ...ForwardStrategy) ForwardStrategy {
+	switch len(strategies) {
+	case 0:
+		return nil
+	case 1:
+		return strategies[0]
+	}
+	return func(peers []network.LBPeer) []network.LBPeer {
+		for _, s := range strategies {
+			peers = s(peers)
+		}
+		return peers
+	}
+}
@@ -16,17 +16,91 @@
 
 package retrieval
 
-import "github.com/ethersphere/swarm/storage"
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/storage"
+)
+
+var (
+	errHopCountRange   = errors.New("retrieve request hop count out of range")
+	errPriceWithoutHop = errors.New("retrieve request priced without hop count")
+)
 
 // RetrieveRequest is the protocol msg for chunk retrieve requests
 type RetrieveRequest struct {
-	Ruid uint
-	Addr storage.Address
+	Ruid     uint
+	Addr     storage.Address
+	HopCount uint8    // number of hops the request may still travel, including the one to the receiver, zero with peers running versions before hopsVersion
+	MaxPrice []uint64 // the most the requester pays for the chunk if the retrieval is priced per chunk, see chunkPrice
 }
 
-// ChunkDelivery is the protocol msg for delivering a solicited chunk to a peer
-type ChunkDelivery struct {
+// retrieveRequestRLP is the encoding of retrieve requests
+// The hop count and the price are a list tail so that they are not encoded at all
+// if empty, and the requests exchanged with peers running versions before
+// hopsVersion, which leave them empty, are encoded as in those versions
+type retrieveRequestRLP struct {
 	Ruid  uint
 	Addr  storage.Address
-	SData []byte
+	Extra []uint64 `rlp:"tail"` // the hop count followed by the price, if any
+}
+
+// EncodeRLP implements rlp.Encoder
+func (rr *RetrieveRequest) EncodeRLP(w io.Writer) error {
+	enc := retrieveRequestRLP{
+		Ruid: rr.Ruid,
+		Addr: rr.Addr,
+	}
+	if rr.HopCount > 0 {
+		enc.Extra = append([]uint64{uint64(rr.HopCount)}, rr.MaxPrice...)
+	} else if len(rr.MaxPrice) > 0 {
+		return errPriceWithoutHop
+	}
+	return rlp.Encode(w, &enc)
+}
+
+// DecodeRLP implements rlp.Decoder
+func (rr *RetrieveRequest) DecodeRLP(s *rlp.Stream) error {
+	var dec retrieveRequestRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	*rr = RetrieveRequest{
+		Ruid: dec.Ruid,
+		Addr: dec.Addr,
+	}
+	if len(dec.Extra) == 0 {
+		return nil
+	}
+	if dec.Extra[0] > math.MaxUint8 {
+		return errHopCountRange
+	}
+	rr.HopCount = uint8(dec.Extra[0])
+	if len(dec.Extra) > 1 {
+		rr.MaxPrice = dec.Extra[1:]
+	}
+	return nil
+}
+
+// ChunkDelivery is the protocol msg for delivering a solicited chunk to a peer
+type ChunkDelivery struct {
+	Ruid   uint
+	Addr   storage.Address
+	SData  []byte
+	Charge []uint64 `rlp:"tail"` // the price charged for the chunk if the delivery is priced per chunk, see chunkPrice
+}
+
+// chunkPrice returns the price in honey carried by the price field of a message,
+// and false if the retrieval is not priced per chunk
+// The field is a list tail so that it is not encoded at all if empty, and the
+// messages exchanged with peers running versions before pricedVersion, which
+// leave it empty, are encoded as in those versions
+func chunkPrice(field []uint64) (uint64, bool) {
+	if len(field) == 0 {
+		return 0, false
+	}
+	return field[0], true
 }
@@ -0,0 +1,105 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/tilinna/clock"
+)
+
+// BucketKeyClock is the key under which the clock of the simulation
+// is stored in the bucket of every node, so that services constructed
+// in ServiceFunc can base their timers on it
+var BucketKeyClock BucketKey = "clock"
+
+// ErrClockNotMockable is returned when virtual time is advanced
+// on a simulation that runs on the real time clock
+var ErrClockNotMockable = errors.New("simulation clock is not a mock clock")
+
+// WithClock implements the builder pattern constructor for Simulation
+// to set the clock shared by the services of all nodes
+// It must be called before nodes are added to the simulation
+// Passing a *clock.Mock lets tests advance time deterministically
+// instead of sleeping until timers fire
+func (s *Simulation) WithClock(c clock.Clock) *Simulation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock = c
+	return s
+}
+
+// Clock returns the clock of the simulation
+func (s *Simulation) Clock() clock.Clock {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.clock
+}
+
+// AdvanceClock moves the mock clock of the simulation forward by d, firing all
+// timers that expire in the meantime, and returns the new time
+// It returns ErrClockNotMockable if the simulation runs on the real time clock
+func (s *Simulation) AdvanceClock(d time.Duration) (time.Time, error) {
+	m, ok := s.Clock().(*clock.Mock)
+	if !ok {
+		return time.Time{}, ErrClockNotMockable
+	}
+	return m.Add(d), nil
+}
+
+// clockResponse is the response of the clock HTTP endpoints
+type clockResponse struct {
+	Now  time.Time `json:"now"`
+	Mock bool      `json:"mock"`
+}
+
+// GetClock is the GET endpoint returning the current time of the simulation clock
+func (s *Simulation) GetClock(w http.ResponseWriter, req *http.Request) {
+	c := s.Clock()
+	_, mock := c.(*clock.Mock)
+	s.handler.JSON(w, http.StatusOK, &clockResponse{
+		Now:  c.Now(),
+		Mock: mock,
+	})
+}
+
+// AdvanceClockHandler is the POST endpoint advancing the mock clock of the
+// simulation by the duration given in the duration query parameter, e.g. 1m30s
+func (s *Simulation) AdvanceClockHandler(w http.ResponseWriter, req *http.Request) {
+	d, err := time.ParseDuration(req.URL.Query().Get("duration"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if d < 0 {
+		http.Error(w, "duration must not be negative", http.StatusBadRequest)
+		return
+	}
+	now, err := s.AdvanceClock(d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.handler.JSON(w, http.StatusOK, &clockResponse{
+		Now:  now,
+		Mock: true,
+	})
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/tilinna/clock"
+)
+
+// TestSimulationClock tests that the mock clock of the simulation is stored
+// in the node buckets and that advancing it fires the timers of the services
+func TestSimulationClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	sim := NewInProc(map[string]ServiceFunc{
+		"noop": func(_ *adapters.ServiceContext, b *sync.Map) (node.Service, func(), error) {
+			return newNoopService(), nil, nil
+		},
+	}).WithClock(clock.NewMock(start))
+	defer sim.Close()
+
+	id, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, ok := sim.NodeItem(id, BucketKeyClock)
+	if !ok {
+		t.Fatal("clock not found in node bucket")
+	}
+	c := item.(clock.Clock)
+	if c != sim.Clock() {
+		t.Fatal("node clock is not the simulation clock")
+	}
+
+	timer := c.NewTimer(time.Minute)
+	defer timer.Stop()
+
+	now, err := sim.AdvanceClock(30 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(30 * time.Second); !now.Equal(want) {
+		t.Fatalf("got time %v, want %v", now, want)
+	}
+	select {
+	case <-timer.C:
+		t.Fatal("timer fired before expiry")
+	default:
+	}
+
+	if _, err := sim.AdvanceClock(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-timer.C:
+	default:
+		t.Fatal("timer did not fire after expiry")
+	}
+}
+
+// TestSimulationClockRealtime tests that the clock of a simulation
+// cannot be advanced unless it is a mock clock
+func TestSimulationClockRealtime(t *testing.T) {
+	sim := NewInProc(map[string]ServiceFunc{})
+	defer sim.Close()
+
+	if _, err := sim.AdvanceClock(time.Second); err != ErrClockNotMockable {
+		t.Fatalf("got error %v, want %v", err, ErrClockNotMockable)
+	}
+}
+
+// TestSimulationClockHTTP tests the clock endpoints of the simulation HTTP API
+func TestSimulationClockHTTP(t *testing.T) {
+	start := time.Unix(1000, 0)
+	sim := NewInProc(map[string]ServiceFunc{}).WithClock(clock.NewMock(start))
+	defer sim.Close()
+
+	sim.handler = simulations.NewServer(sim.Net)
+	sim.addSimulationRoutes()
+	srv := httptest.NewServer(sim.handler)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/clock/advance?duration=1m30s", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v, want %v", resp.Status, http.StatusOK)
+	}
+
+	resp, err = http.Get(srv.URL + "/clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got clockResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(90 * time.Second); !got.Now.Equal(want) || !got.Mock {
+		t.Fatalf("got clock %v (mock %v), want %v (mock true)", got.Now, got.Mock, want)
+	}
+
+	resp, err = http.Post(srv.URL+"/clock/advance?duration=invalid", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got status %v, want %v", resp.Status, http.StatusBadRequest)
+	}
+}
+
+// TestSimulationDialHistoryClock tests that the reachability check of the nodes
+// bans repeated dials between two nodes for the dial ban timeout of the simulation clock
+func TestSimulationDialHistoryClock(t *testing.T) {
+	sim := NewInProc(map[string]ServiceFunc{
+		"noop": func(_ *adapters.ServiceContext, b *sync.Map) (node.Service, func(), error) {
+			return newNoopService(), nil, nil
+		},
+	}).WithClock(clock.NewMock(time.Unix(1000, 0)))
+	defer sim.Close()
+
+	ids, err := sim.AddNodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ban applies to the node with the lower id dialing
+	one, other := ids[0], ids[1]
+	if bytes.Compare(one.Bytes(), other.Bytes()) > 0 {
+		one, other = other, one
+	}
+	reachable := sim.Net.GetNode(one).Config.Reachable
+
+	if !reachable(other) {
+		t.Fatal("expected first dial to be allowed")
+	}
+	if reachable(other) {
+		t.Fatal("expected repeated dial to be banned")
+	}
+	if _, err := sim.AdvanceClock(simulations.DialBanTimeout / 2); err != nil {
+		t.Fatal(err)
+	}
+	if reachable(other) {
+		t.Fatal("expected dial to be banned before the ban timeout")
+	}
+	if _, err := sim.AdvanceClock(simulations.DialBanTimeout / 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable(other) {
+		t.Fatal("expected dial to be allowed after the ban timeout")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+)
+
+// dialHistory records the dials between the nodes of the simulation on its clock,
+// it replaces the dial history of the simulations network, which bans repeated
+// dials for simulations.DialBanTimeout of real time, as the reachability check of
+// the nodes added by the simulation
+type dialHistory struct {
+	mu        sync.Mutex
+	initiated map[[2]enode.ID]time.Time // time of the last dial by pair of nodes
+	up        map[[2]enode.ID]bool      // connected pairs of nodes
+	once      sync.Once                 // starts tracking the connections of the network
+}
+
+func newDialHistory() *dialHistory {
+	return &dialHistory{
+		initiated: make(map[[2]enode.ID]time.Time),
+		up:        make(map[[2]enode.ID]bool),
+	}
+}
+
+// dialKey returns the key of a pair of nodes regardless of which one dials
+func dialKey(one, other enode.ID) [2]enode.ID {
+	if bytes.Compare(one.Bytes(), other.Bytes()) > 0 {
+		one, other = other, one
+	}
+	return [2]enode.ID{one, other}
+}
+
+// track updates the connected pairs from the connection events of the network
+// until the simulation is closed, disconnected pairs may be dialed again immediately
+func (h *dialHistory) track(net *simulations.Network, done <-chan struct{}) {
+	events := make(chan *simulations.Event, 100)
+	sub := net.Events().Subscribe(events)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case e := <-events:
+				if e.Type != simulations.EventTypeConn || e.Control {
+					continue
+				}
+				key := dialKey(e.Conn.One, e.Conn.Other)
+				h.mu.Lock()
+				if e.Conn.Up {
+					h.up[key] = true
+				} else {
+					delete(h.up, key)
+					delete(h.initiated, key)
+				}
+				h.mu.Unlock()
+			case <-sub.Err():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// dial records a dial from one node to the other at the given time, it returns
+// an error if the nodes are not up, already connected or dialed recently
+func (h *dialHistory) dial(net *simulations.Network, one, other enode.ID, now time.Time) error {
+	if one == other {
+		return errors.New("refusing to connect to self")
+	}
+	for _, id := range []enode.ID{one, other} {
+		if n := net.GetNode(id); n == nil || !n.Up() {
+			return errors.New("nodes not up")
+		}
+	}
+	key := dialKey(one, other)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.up[key] {
+		return errors.New("already connected")
+	}
+	if t, ok := h.initiated[key]; ok && now.Sub(t) < simulations.DialBanTimeout {
+		return errors.New("connection recently attempted")
+	}
+	h.initiated[key] = now
+	return nil
+}
+
+// reachable returns the reachability check of the node, which is the same
+// as the one of the simulations network, except for timing the dials on the
+// clock of the simulation
+func (s *Simulation) reachable(id enode.ID) func(enode.ID) bool {
+	s.dials.once.Do(func() {
+		s.dials.track(s.Net, s.done)
+	})
+	return func(other enode.ID) bool {
+		if err := s.dials.dial(s.Net, id, other, s.Clock().Now()); err != nil && bytes.Compare(id.Bytes(), other.Bytes()) < 0 {
+			return false
+		}
+		return true
+	}
+}
@@ -58,6 +58,8 @@ func (s *Simulation) WithServer(addr string) *Simulation {
 //register additional HTTP routes
 func (s *Simulation) addSimulationRoutes() {
 	s.handler.POST("/runsim", s.RunSimulation)
+	s.handler.GET("/clock", s.GetClock)
+	s.handler.POST("/clock/advance", s.AdvanceClockHandler)
 }
 
 // RunSimulation is the actual POST endpoint runner
@@ -23,10 +23,8 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"math/rand"
 	"os"
 	"sync"
-	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -98,13 +96,19 @@ func AddNodeWithService(serviceName string) AddNodeOption {
 // By default all services will be started on a node. If one or more
 // AddNodeWithService option are provided, only specified services will be started.
 func (s *Simulation) AddNode(opts ...AddNodeOption) (id enode.ID, err error) {
-	conf := adapters.RandomNodeConfig()
+	conf, err := s.randomNodeConfig()
+	if err != nil {
+		return id, err
+	}
 	for _, o := range opts {
 		o(conf)
 	}
 	if len(conf.Services) == 0 {
 		conf.Services = s.serviceNames
 	}
+	if conf.Reachable == nil {
+		conf.Reachable = s.reachable(conf.ID)
+	}
 
 	// add ENR records to the underlying node
 	// most importantly the bzz overlay address
@@ -268,7 +272,7 @@ func (s *Simulation) StartNode(id enode.ID) (err error) {
 
 // StartRandomNode starts a random node.
 func (s *Simulation) StartRandomNode() (id enode.ID, err error) {
-	n := s.Net.GetRandomDownNode()
+	n := s.randomNode(false)
 	if n == nil {
 		return id, ErrNodeNotFound
 	}
@@ -279,7 +283,7 @@ func (s *Simulation) StartRandomNode() (id enode.ID, err error) {
 func (s *Simulation) StartRandomNodes(count int) (ids []enode.ID, err error) {
 	ids = make([]enode.ID, 0, count)
 	for i := 0; i < count; i++ {
-		n := s.Net.GetRandomDownNode()
+		n := s.randomNode(false)
 		if n == nil {
 			return nil, ErrNodeNotFound
 		}
@@ -299,18 +303,7 @@ func (s *Simulation) StopNode(id enode.ID) (err error) {
 
 // StopRandomNode stops a random node.
 func (s *Simulation) StopRandomNode(protect ...enode.ID) (id enode.ID, err error) {
-	found := false
-	var n *simulations.Node
-outer:
-	for !found {
-		n = s.Net.GetRandomUpNode()
-		for _, v := range protect {
-			if bytes.Equal(n.ID().Bytes(), v.Bytes()) {
-				continue outer
-			}
-		}
-		found = true
-	}
+	n := s.randomNode(true, protect...)
 	if n == nil {
 		return id, ErrNodeNotFound
 	}
@@ -321,7 +314,7 @@ outer:
 func (s *Simulation) StopRandomNodes(count int) (ids []enode.ID, err error) {
 	ids = make([]enode.ID, 0, count)
 	for i := 0; i < count; i++ {
-		n := s.Net.GetRandomUpNode()
+		n := s.randomNode(true)
 		if n == nil {
 			return nil, ErrNodeNotFound
 		}
@@ -334,11 +327,6 @@ func (s *Simulation) StopRandomNodes(count int) (ids []enode.ID, err error) {
 	return ids, nil
 }
 
-// seed the random generator for Simulation.randomNode.
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 // derive a private key for swarm for the node key
 // returns the private key used to generate the bzz key
 func BzzPrivateKeyFromConfig(conf *adapters.NodeConfig) (*ecdsa.PrivateKey, error) {
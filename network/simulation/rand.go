@@ -0,0 +1,152 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// SeedEnvVar is the environment variable that sets the seed of the random
+// choices of the simulations, to reproduce a run with the seed it logged
+const SeedEnvVar = "SWARM_SIM_SEED"
+
+// BucketKeyRand is the key under which a random source derived from the seed
+// of the simulation and the node ID is stored in the bucket of every node, so
+// that services constructed in ServiceFunc make the same random choices in
+// every run with the seed
+// It is not safe for concurrent use
+var BucketKeyRand BucketKey = "rand"
+
+// newSeed returns the seed set in the environment, or a new one
+func newSeed() int64 {
+	if v := os.Getenv(SeedEnvVar); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			return seed
+		}
+		log.Error("invalid simulation seed", "env", SeedEnvVar, "value", v, "err", err)
+	}
+	return time.Now().UnixNano()
+}
+
+// WithSeed implements the builder pattern constructor for Simulation
+// to set the seed of its random choices: the keys of the added nodes, the
+// nodes picked by the random node methods and the random sources of the nodes
+// and of Rand
+// It must be called before nodes are added to the simulation
+func (s *Simulation) WithSeed(seed int64) *Simulation {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	s.seed = seed
+	s.rand = rand.New(rand.NewSource(seed))
+	log.Info("simulation seed", "seed", seed, "env", SeedEnvVar)
+	return s
+}
+
+// Seed returns the seed of the random choices of the simulation, which is
+// also reported in the result of Run
+func (s *Simulation) Seed() int64 {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	return s.seed
+}
+
+// Rand returns a new random source derived from the seed of the simulation
+// for the random choices of a test, such as the nodes to stop or the order of
+// connections, so that they are the same in every run with the seed
+// It is not safe for concurrent use, every goroutine should get its own
+func (s *Simulation) Rand() *rand.Rand {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	return rand.New(rand.NewSource(s.rand.Int63()))
+}
+
+// nodeRand returns the random source of the node with the ID
+func (s *Simulation) nodeRand(id enode.ID) *rand.Rand {
+	return rand.New(rand.NewSource(s.Seed() ^ int64(binary.BigEndian.Uint64(id[:8]))))
+}
+
+// intn returns a random number in [0,n) from the random source of the simulation
+func (s *Simulation) intn(n int) int {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	return s.rand.Intn(n)
+}
+
+// randomNodeConfig returns the configuration of a new node with a private key
+// generated from the random source of the simulation
+func (s *Simulation) randomNodeConfig() (*adapters.NodeConfig, error) {
+	conf := adapters.RandomNodeConfig()
+	key, err := s.randomKey()
+	if err != nil {
+		return nil, err
+	}
+	conf.PrivateKey = key
+	conf.ID = enode.PubkeyToIDV4(&key.PublicKey)
+	conf.Name = "node_" + conf.ID.String()
+	return conf, nil
+}
+
+func (s *Simulation) randomKey() (key *ecdsa.PrivateKey, err error) {
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+
+	b := make([]byte, 32)
+	// retry for the rare values that are not valid keys
+	for i := 0; i < 10; i++ {
+		s.rand.Read(b)
+		if key, err = crypto.ToECDSA(b); err == nil {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
+// randomNode returns a node picked from the random source of the simulation
+// among the nodes that are up, or down, except the excluded ones, nil if there is none
+func (s *Simulation) randomNode(up bool, exclude ...enode.ID) *simulations.Node {
+	excluded := make(map[enode.ID]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	var nodes []*simulations.Node
+	// the nodes are in the order they were added
+	for _, n := range s.Net.GetNodes() {
+		if n.Up() == up && !excluded[n.ID()] {
+			nodes = append(nodes, n)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[s.intn(len(nodes))]
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// TestSimulationSeed tests that simulations with the same seed add the same
+// nodes and make the same random choices
+func TestSimulationSeed(t *testing.T) {
+	type run struct {
+		ids      []enode.ID
+		stopped  []enode.ID
+		started  enode.ID
+		nodeRand int64
+		testRand int64
+		seed     int64
+	}
+	simulate := func(seed int64) (r run) {
+		sim := NewInProc(map[string]ServiceFunc{
+			"noop": func(_ *adapters.ServiceContext, b *sync.Map) (node.Service, func(), error) {
+				return newNoopService(), nil, nil
+			},
+		}).WithSeed(seed)
+		defer sim.Close()
+
+		ids, err := sim.AddNodes(6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.ids = ids
+		r.nodeRand = sim.MustNodeItem(ids[0], BucketKeyRand).(*rand.Rand).Int63()
+
+		result := sim.Run(context.Background(), func(ctx context.Context, sim *Simulation) error {
+			stopped, err := sim.StopRandomNodes(2)
+			if err != nil {
+				return err
+			}
+			id, err := sim.StopRandomNode(ids[0])
+			if err != nil {
+				return err
+			}
+			r.stopped = append(stopped, id)
+			r.started, err = sim.StartRandomNode()
+			if err != nil {
+				return err
+			}
+			r.testRand = sim.Rand().Int63()
+			return nil
+		})
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		r.seed = result.Seed
+		for _, id := range r.stopped {
+			if id == ids[0] {
+				t.Fatal("protected node was stopped")
+			}
+		}
+		return r
+	}
+
+	equal := func(a, b run) bool {
+		if len(a.ids) != len(b.ids) || len(a.stopped) != len(b.stopped) {
+			return false
+		}
+		for i := range a.ids {
+			if a.ids[i] != b.ids[i] {
+				return false
+			}
+		}
+		for i := range a.stopped {
+			if a.stopped[i] != b.stopped[i] {
+				return false
+			}
+		}
+		return a.started == b.started && a.nodeRand == b.nodeRand && a.testRand == b.testRand
+	}
+
+	first, second, other := simulate(42), simulate(42), simulate(43)
+	if first.seed != 42 || other.seed != 43 {
+		t.Fatalf("got seeds %d and %d in the results, want 42 and 43", first.seed, other.seed)
+	}
+	if !equal(first, second) {
+		t.Fatalf("got different runs with the same seed: %+v, %+v", first, second)
+	}
+	if equal(first, other) {
+		t.Fatal("got the same run with different seeds")
+	}
+}
@@ -39,11 +39,15 @@ func (s *Simulation) Service(name string, id enode.ID) node.Service {
 // RandomService returns a single Service by name on a
 // randomly chosen node that is up.
 func (s *Simulation) RandomService(name string) node.Service {
-	n := s.Net.GetRandomUpNode().Node.(*adapters.SimNode)
+	n := s.randomNode(true)
 	if n == nil {
 		return nil
 	}
-	return n.Service(name)
+	simNode, ok := n.Node.(*adapters.SimNode)
+	if !ok {
+		return nil
+	}
+	return simNode.Service(name)
 }
 
 // Services returns all services with a provided name
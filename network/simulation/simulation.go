@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"os"
 	"sync"
@@ -31,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/simulations"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 	"github.com/ethersphere/swarm/network"
+	"github.com/tilinna/clock"
 )
 
 const (
@@ -59,6 +61,11 @@ type Simulation struct {
 	neighbourhoodSize int
 	baseDir           string
 	typ               int
+	clock             clock.Clock  // clock stored in node buckets under BucketKeyClock
+	dials             *dialHistory // dials between the nodes timed on clock
+	seed              int64        // seed of rand, reported in the result of Run
+	rand              *rand.Rand   // source of the random choices of the simulation
+	randMu            sync.Mutex   // protects seed and rand
 
 	httpSrv *http.Server        //attach a HTTP server via SimulationOptions
 	handler *simulations.Server //HTTP handler for the server
@@ -86,7 +93,10 @@ func NewInProc(services map[string]ServiceFunc) (s *Simulation) {
 		done:              make(chan struct{}),
 		neighbourhoodSize: network.NewKadParams().NeighbourhoodSize,
 		typ:               SimulationTypeInproc,
+		clock:             clock.Realtime(),
+		dials:             newDialHistory(),
 	}
+	s.WithSeed(newSeed())
 
 	s.addServices(services)
 	adapterServices := s.toAdapterServices(services)
@@ -130,7 +140,10 @@ func NewExec(services map[string]ServiceFunc) (s *Simulation, err error) {
 		done:              make(chan struct{}),
 		neighbourhoodSize: network.NewKadParams().NeighbourhoodSize,
 		typ:               SimulationTypeExec,
+		clock:             clock.Realtime(),
+		dials:             newDialHistory(),
 	}
+	s.WithSeed(newSeed())
 
 	s.addServices(services)
 	adapterServices := s.toAdapterServices(services)
@@ -171,6 +184,8 @@ func (s *Simulation) toAdapterServices(services map[string]ServiceFunc) map[stri
 			if !ok {
 				b = new(sync.Map)
 			}
+			b.LoadOrStore(BucketKeyClock, s.clock)
+			b.LoadOrStore(BucketKeyRand, s.nodeRand(ctx.Config.ID))
 			service, cleanup, err := serviceFunc(ctx, b)
 			if err != nil {
 				return nil, err
@@ -193,6 +208,7 @@ type RunFunc func(context.Context, *Simulation) error
 type Result struct {
 	Duration time.Duration
 	Error    error
+	Seed     int64 // seed of the simulation, to reproduce a failed run with it
 }
 
 // Run calls the RunFunc function while taking care of
@@ -210,6 +226,7 @@ func (s *Simulation) Run(ctx context.Context, f RunFunc) (r Result) {
 			return Result{
 				Duration: time.Since(start),
 				Error:    ctx.Err(),
+				Seed:     s.Seed(),
 			}
 		}
 		log.Info("Received signal from frontend - starting simulation run.")
@@ -229,9 +246,13 @@ func (s *Simulation) Run(ctx context.Context, f RunFunc) (r Result) {
 		err = ctx.Err()
 	case err = <-errc:
 	}
+	if err != nil {
+		log.Error("simulation run failed, set the seed in the environment to reproduce it", "env", SeedEnvVar, "seed", s.Seed(), "err", err)
+	}
 	return Result{
 		Duration: time.Since(start),
 		Error:    err,
+		Seed:     s.Seed(),
 	}
 }
 
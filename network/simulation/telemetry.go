@@ -0,0 +1,160 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// NodeTelemetry is a sample of the resource usage of a node.
+// In-process nodes share the goroutines and the heap of the process, other
+// adapters only report the peer connections of their nodes.
+type NodeTelemetry struct {
+	NodeID     enode.ID
+	Time       time.Time
+	Goroutines int    // number of goroutines, zero if not known
+	HeapAlloc  uint64 // bytes of allocated heap objects, zero if not known
+	Peers      int    // number of open peer connections
+	Shared     bool   // whether goroutines and heap are of the process shared by all in-process nodes
+}
+
+// Grew reports whether the goroutines or the heap grew by more than the factor
+// since the earlier sample, which hints at a leak in long running simulations.
+func (t *NodeTelemetry) Grew(since *NodeTelemetry, factor float64) bool {
+	if since.Goroutines > 0 && float64(t.Goroutines) > float64(since.Goroutines)*factor {
+		return true
+	}
+	return since.HeapAlloc > 0 && float64(t.HeapAlloc) > float64(since.HeapAlloc)*factor
+}
+
+// TelemetryEvent is the type of the channel returned by Simulation.Telemetry.
+type TelemetryEvent struct {
+	// NodeID is the ID of the node the telemetry is collected from.
+	NodeID enode.ID
+	// Telemetry is the collected sample, nil if Error is set.
+	Telemetry *NodeTelemetry
+	// Error is the error that may have happened during the collection.
+	Error error
+}
+
+// processTelemetry is the resource usage of the simulation process
+type processTelemetry struct {
+	goroutines int
+	heapAlloc  uint64
+}
+
+func readProcessTelemetry() processTelemetry {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return processTelemetry{
+		goroutines: runtime.NumGoroutine(),
+		heapAlloc:  m.HeapAlloc,
+	}
+}
+
+// NodeTelemetry collects the resource usage of the node with the given ID
+// through its adapter.
+func (s *Simulation) NodeTelemetry(ctx context.Context, id enode.ID) (*NodeTelemetry, error) {
+	return s.nodeTelemetry(ctx, id, readProcessTelemetry)
+}
+
+func (s *Simulation) nodeTelemetry(ctx context.Context, id enode.ID, process func() processTelemetry) (*NodeTelemetry, error) {
+	n := s.Net.GetNode(id)
+	if n == nil {
+		return nil, ErrNodeNotFound
+	}
+	t := &NodeTelemetry{
+		NodeID: id,
+		Time:   time.Now(),
+	}
+	if sn, ok := n.Node.(*adapters.SimNode); ok {
+		p := process()
+		t.Goroutines = p.goroutines
+		t.HeapAlloc = p.heapAlloc
+		t.Shared = true
+		if srv := sn.Server(); srv != nil {
+			t.Peers = srv.PeerCount()
+		}
+		return t, nil
+	}
+	client, err := n.Client()
+	if err != nil {
+		return nil, err
+	}
+	var peers []*p2p.PeerInfo
+	if err := client.CallContext(ctx, &peers, "admin_peers"); err != nil {
+		return nil, err
+	}
+	t.Peers = len(peers)
+	return t, nil
+}
+
+// Telemetry returns a channel of the resource usage of the nodes with provided
+// NodeIDs, or of all up nodes if none are provided, collected every interval
+// until the context is done or the simulation is closed.
+func (s *Simulation) Telemetry(ctx context.Context, interval time.Duration, ids ...enode.ID) <-chan TelemetryEvent {
+	eventC := make(chan TelemetryEvent)
+
+	s.shutdownWG.Add(1)
+	go func() {
+		defer s.shutdownWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			case <-s.Done():
+				return
+			}
+
+			nodeIDs := ids
+			if len(nodeIDs) == 0 {
+				nodeIDs = s.UpNodeIDs()
+			}
+			// the process is sampled once for all in-process nodes,
+			// as reading memory statistics stops the world
+			var process *processTelemetry
+			readProcess := func() processTelemetry {
+				if process == nil {
+					p := readProcessTelemetry()
+					process = &p
+				}
+				return *process
+			}
+			for _, id := range nodeIDs {
+				t, err := s.nodeTelemetry(ctx, id, readProcess)
+				select {
+				case eventC <- TelemetryEvent{NodeID: id, Telemetry: t, Error: err}:
+				case <-ctx.Done():
+					return
+				case <-s.Done():
+					return
+				}
+			}
+		}
+	}()
+	return eventC
+}
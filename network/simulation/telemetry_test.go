@@ -0,0 +1,86 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestTelemetry tests that the telemetry of in-process nodes reports
+// the resources of the process and the peer connections of every node
+func TestTelemetry(t *testing.T) {
+	sim := NewInProc(noopServiceFuncMap)
+	defer sim.Close()
+
+	_, err := sim.AddNodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Net.ConnectNodesChain(sim.NodeIDs()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := sim.Telemetry(ctx, 10*time.Millisecond)
+
+	connected := make(map[enode.ID]bool)
+	for len(connected) < 2 {
+		select {
+		case e := <-events:
+			if e.Error != nil {
+				t.Fatal(e.Error)
+			}
+			tm := e.Telemetry
+			if tm.NodeID != e.NodeID {
+				t.Fatalf("expected telemetry of node %v, got %v", e.NodeID, tm.NodeID)
+			}
+			if !tm.Shared || tm.Goroutines == 0 || tm.HeapAlloc == 0 {
+				t.Fatalf("expected process resources of in-process node, got %+v", tm)
+			}
+			if tm.Peers == 1 {
+				connected[tm.NodeID] = true
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for telemetry of connected nodes, got %d", len(connected))
+		}
+	}
+
+	if _, err := sim.NodeTelemetry(ctx, enode.ID{}); err != ErrNodeNotFound {
+		t.Fatalf("expected error %v, got %v", ErrNodeNotFound, err)
+	}
+}
+
+func TestNodeTelemetryGrew(t *testing.T) {
+	since := &NodeTelemetry{Goroutines: 100, HeapAlloc: 1000}
+	for _, tc := range []struct {
+		t    *NodeTelemetry
+		grew bool
+	}{
+		{&NodeTelemetry{Goroutines: 150, HeapAlloc: 1500}, false},
+		{&NodeTelemetry{Goroutines: 250, HeapAlloc: 1000}, true},
+		{&NodeTelemetry{Goroutines: 100, HeapAlloc: 2500}, true},
+	} {
+		if grew := tc.t.Grew(since, 2); grew != tc.grew {
+			t.Fatalf("expected %+v grown %v since %+v, got %v", tc.t, tc.grew, since, grew)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage"
+)
+
+const (
+	cloneStreamName = "CLONE"
+
+	// cloneBatchSize is the number of hashes requested in one batch on
+	// clone streams, larger than the sync batches for higher throughput
+	cloneBatchSize = 4 * BatchSize
+)
+
+// cloneProvider transfers the whole local store of a source node, or only
+// some of its bins, to a node that replaces it. The clone streams are served
+// only to the nodes the provider is created with and requested only from the
+// source node. The streams are bounded to the cursors of the source node at
+// the time they are requested and the transferred intervals are persisted like
+// the ones of the sync streams, so an interrupted clone resumes where it stopped.
+type cloneProvider struct {
+	*syncProvider                   // chunks are stored, offered and delivered in the same way as with syncing
+	source        enode.ID          // the node to clone, zero to only serve clone streams
+	bins          map[uint8]bool    // the bins to clone, all if empty
+	serve         map[enode.ID]bool // the nodes allowed to clone the local store
+}
+
+// NewCloneProvider creates a new clone stream provider. If source is not the
+// zero ID, the chunks in the given bins of the source node, or all of them if
+// no bins are given, are imported once it is connected. The local store is
+// cloned only by the serve nodes.
+func NewCloneProvider(ns *storage.NetStore, baseAddr *network.BzzAddr, source enode.ID, serve []enode.ID, bins ...uint8) StreamProvider {
+	sp := NewSyncProvider(ns, nil, baseAddr, true, false).(*syncProvider)
+	sp.name = cloneStreamName
+	c := &cloneProvider{
+		syncProvider: sp,
+		source:       source,
+		bins:         make(map[uint8]bool),
+		serve:        make(map[enode.ID]bool),
+	}
+	for _, bin := range bins {
+		c.bins[bin] = true
+	}
+	for _, id := range serve {
+		c.serve[id] = true
+	}
+	return c
+}
+
+// ServeStream returns true only for the nodes allowed to clone the local store
+func (c *cloneProvider) ServeStream(p *Peer, _ ID) bool {
+	return c.serve[p.ID()]
+}
+
+// WantStream returns true only for the streams of the wanted bins of the source node
+func (c *cloneProvider) WantStream(p *Peer, streamID ID) bool {
+	if !c.isSource(p) {
+		return false
+	}
+	bin, err := parseSyncKey(streamID.Key)
+	if err != nil {
+		return false
+	}
+	return c.wantBin(bin)
+}
+
+// InitPeer requests the streams of the wanted bins if the peer is the source node
+func (c *cloneProvider) InitPeer(p *Peer) {
+	if !c.isSource(p) {
+		return
+	}
+	var streams []ID
+	for bin := 0; bin <= chunk.MaxPO; bin++ {
+		if !c.wantBin(uint8(bin)) {
+			continue
+		}
+		stream := NewID(c.StreamName(), encodeSyncKey(uint8(bin)))
+		if _, err := p.getOrCreateInterval(p.peerStreamIntervalKey(stream)); err != nil {
+			p.logger.Error("clone: creating stream interval", "stream", stream, "err", err)
+		}
+		streams = append(streams, stream)
+	}
+	p.logger.Info("clone: requesting streams from source node", "streams", len(streams))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := p.Send(ctx, &StreamInfoReq{Streams: streams}); err != nil {
+		p.logger.Error("clone: requesting streams", "err", err)
+		p.Drop("error requesting clone streams")
+	}
+}
+
+// Boundedness returns true as only the chunks stored by the
+// source node at the time of the request are cloned
+func (c *cloneProvider) Boundedness() bool { return true }
+
+// BatchSize returns the number of hashes requested in one batch
+func (c *cloneProvider) BatchSize() uint { return cloneBatchSize }
+
+func (c *cloneProvider) isSource(p *Peer) bool {
+	return c.source != (enode.ID{}) && p.ID() == c.source
+}
+
+func (c *cloneProvider) wantBin(bin uint8) bool {
+	return len(c.bins) == 0 || c.bins[bin]
+}
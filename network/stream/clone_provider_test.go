@@ -0,0 +1,121 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/simulation"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestClone tests that a node imports the chunks of the clone source node,
+// of all bins or of the requested bins only, while syncing is disabled,
+// and that the source node serves them only to the nodes allowed to clone it
+func TestClone(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		bins    []uint8
+		noServe bool
+	}{
+		{name: "all bins"},
+		{name: "bin 0", bins: []uint8{0}},
+		{name: "not served", noServe: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testClone(t, tc.bins, tc.noServe)
+		})
+	}
+}
+
+func testClone(t *testing.T, bins []uint8, noServe bool) {
+	const chunkCount = 500
+
+	// the clone node is known to the source node before it is started
+	cloneConf := adapters.RandomNodeConfig()
+	var source enode.ID
+	var serve []enode.ID
+	if !noServe {
+		serve = append(serve, cloneConf.ID)
+	}
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		serviceNameStream: newSyncSimServiceFunc(&SyncSimServiceOptions{
+			StreamConstructorFunc: func(s state.Store, addr *network.BzzAddr, p ...StreamProvider) node.Service {
+				ns := p[0].(*syncProvider).netStore
+				return New(s, addr, append(p, NewCloneProvider(ns, addr, source, serve, bins...))...)
+			},
+		}),
+	}, false)
+	defer sim.Close()
+
+	source, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceStore := sim.MustNodeItem(source, bucketKeyFileStore).(*storage.FileStore)
+	uploaded := mustUploadChunks(context.Background(), t, sourceStore, chunkCount)
+
+	// the chunks of the cloned bins, in relation to the source node
+	sourceOver := nodeRegistry(sim, source).address.Over()
+	want := make(map[string]struct{})
+	for _, addr := range uploaded {
+		po := uint8(chunk.Proximity(sourceOver, addr))
+		if !noServe && (len(bins) == 0 || po == bins[0]) {
+			want[addr.Hex()] = struct{}{}
+		}
+	}
+
+	clone, err := sim.AddNode(func(conf *adapters.NodeConfig) {
+		conf.ID = cloneConf.ID
+		conf.PrivateKey = cloneConf.PrivateKey
+		conf.Name = cloneConf.Name
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Net.Connect(clone, source); err != nil {
+		t.Fatal(err)
+	}
+
+	cloneStore := sim.MustNodeItem(clone, bucketKeyFileStore).(*storage.FileStore)
+	if err := waitChunks(cloneStore, uint64(len(want)), 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// give unwanted chunks the time to arrive
+	time.Sleep(500 * time.Millisecond)
+
+	got, err := getChunks(cloneStore.ChunkStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d cloned chunks, want %d", len(got), len(want))
+	}
+	for addr := range want {
+		if _, ok := got[addr]; !ok {
+			t.Fatalf("chunk %s not cloned", addr)
+		}
+	}
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/storage/mock"
 	"github.com/ethersphere/swarm/testutil"
+	"github.com/tilinna/clock"
 )
 
 var (
@@ -126,7 +127,7 @@ func newSyncSimServiceFunc(o *SyncSimServiceOptions) func(ctx *adapters.ServiceC
 		bucket.Store(bucketKeyFileStore, fileStore)
 		bucket.Store(bucketKeyLocalStore, localStore)
 
-		ret := retrieval.New(kad, netStore, addr, nil)
+		ret := retrieval.New(kad, netStore, addr, nil, nil)
 		netStore.RemoteGet = ret.RequestFromPeers
 
 		if o.InitialChunkCount > 0 {
@@ -155,8 +156,15 @@ func newSyncSimServiceFunc(o *SyncSimServiceOptions) func(ctx *adapters.ServiceC
 		if err != nil {
 			return nil, nil, err
 		}
-		sp := NewSyncProvider(netStore, kad, addr, o.Autostart, o.SyncOnlyWithinDepth)
+		clk := clock.Realtime()
+		if c, ok := bucket.Load(simulation.BucketKeyClock); ok {
+			clk = c.(clock.Clock)
+		}
+		sp := NewSyncProviderWithClock(netStore, kad, addr, o.Autostart, o.SyncOnlyWithinDepth, clk)
 		ss := o.StreamConstructorFunc(store, addr, sp)
+		if r, ok := ss.(*Registry); ok {
+			r.SetClock(clk)
+		}
 
 		cleanup = func() {
 			//ss.Stop() // wait for handlers to finish before closing localstore
@@ -0,0 +1,235 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	bv "github.com/ethersphere/swarm/network/bitvector"
+)
+
+// compressedVersion is the first stream protocol version whose peers exchange
+// the hashes of OfferedHashes and the bitvector of WantedHashes compressed,
+// messages to and from peers running older versions keep the plain encoding
+const compressedVersion = 9
+
+// formats of the compressed hashes, given by their first byte
+const (
+	hashesPrefix byte = 0 // the prefix encoding of the hashes follows
+	hashesFlate  byte = 1 // the DEFLATE compressed prefix encoding of the hashes follows
+)
+
+// formats of the compressed bitvector, given by its first byte
+const (
+	bitVectorPlain   byte = 0 // the plain bitvector follows
+	bitVectorIndices byte = 1 // the uvarint deltas between the indices of the set bits follow
+)
+
+var (
+	errInvalidCompression = errors.New("invalid compression")
+
+	compressedHashesSaved    = metrics.GetOrRegisterCounter("network/stream/compressed_hashes_saved", nil)
+	compressedBitVectorSaved = metrics.GetOrRegisterCounter("network/stream/compressed_bitvector_saved", nil)
+
+	// flateWriters are reused as every writer allocates its compression state
+	flateWriters = sync.Pool{
+		New: func() interface{} {
+			w, _ := flate.NewWriter(nil, flate.BestSpeed)
+			return w
+		},
+	}
+)
+
+// compresses reports whether the offered hashes and wanted bitvectors
+// exchanged with the peer are compressed
+func (p *Peer) compresses() bool {
+	return p.Version() >= compressedVersion
+}
+
+// compressHashes encodes concatenated hashes as the length of the prefix
+// they all share, the prefix, and the remainder of every hash, and compresses
+// the encoding with DEFLATE if that makes it shorter. Offered hashes are taken
+// from a single proximity order bin, so they share at least the bits of the
+// bin, the remainders are random and compress only if hashes repeat.
+// An empty slice, signalling a gap in the offered range, is kept empty.
+func compressHashes(hashes []byte) []byte {
+	if len(hashes) == 0 {
+		return hashes
+	}
+	p := prefixHashes(hashes)
+	c := append([]byte{hashesPrefix}, p...)
+
+	var buf bytes.Buffer
+	buf.WriteByte(hashesFlate)
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(p); err == nil && w.Close() == nil && buf.Len() < len(c) {
+		c = buf.Bytes()
+	}
+	compressedHashesSaved.Inc(int64(len(hashes) - len(c)))
+	return c
+}
+
+// prefixHashes encodes concatenated hashes as the length of the prefix
+// they all share, the prefix, and the remainder of every hash
+func prefixHashes(hashes []byte) []byte {
+	prefix := hashes[:HashSize-1]
+	for i := HashSize; i < len(hashes) && len(prefix) > 0; i += HashSize {
+		n := 0
+		for n < len(prefix) && hashes[i+n] == prefix[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	l := len(prefix)
+	p := make([]byte, 0, 1+l+len(hashes)/HashSize*(HashSize-l))
+	p = append(p, byte(l))
+	p = append(p, prefix...)
+	for i := 0; i < len(hashes); i += HashSize {
+		p = append(p, hashes[i+l:i+HashSize]...)
+	}
+	return p
+}
+
+// decompressHashes decodes hashes encoded by compressHashes, at most MaxBatchSize of them
+func decompressHashes(c []byte) ([]byte, error) {
+	if len(c) == 0 {
+		return c, nil
+	}
+	switch c[0] {
+	case hashesPrefix:
+		return unprefixHashes(c[1:])
+	case hashesFlate:
+		// the prefix encoding of MaxBatchSize hashes is at most this long,
+		// the decompressed encoding is not read any further
+		max := 1 + MaxBatchSize*HashSize
+		r := flate.NewReader(bytes.NewReader(c[1:]))
+		defer r.Close()
+		p, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: hashes: %v", errInvalidCompression, err)
+		}
+		if len(p) > max {
+			return nil, fmt.Errorf("%w: hashes encoding longer than %d", errInvalidCompression, max)
+		}
+		return unprefixHashes(p)
+	default:
+		return nil, fmt.Errorf("%w: hashes format %d", errInvalidCompression, c[0])
+	}
+}
+
+// unprefixHashes decodes hashes encoded by prefixHashes, at most MaxBatchSize of them
+func unprefixHashes(p []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("%w: empty hashes encoding", errInvalidCompression)
+	}
+	l := int(p[0])
+	if l >= HashSize || len(p) < 1+l {
+		return nil, fmt.Errorf("%w: hashes prefix length %d", errInvalidCompression, l)
+	}
+	prefix, rest := p[1:1+l], p[1+l:]
+	size := HashSize - l
+	if len(rest) == 0 || len(rest)%size != 0 {
+		return nil, fmt.Errorf("%w: hashes length %d, prefix length %d", errInvalidCompression, len(rest), l)
+	}
+	// a server never offers more than MaxBatchSize hashes, the count is checked
+	// before allocating as short remainders expand up to HashSize times
+	if n := len(rest) / size; n > MaxBatchSize {
+		return nil, fmt.Errorf("%w: %d hashes, more than %d", errInvalidCompression, n, MaxBatchSize)
+	}
+	hashes := make([]byte, 0, len(rest)/size*HashSize)
+	for i := 0; i < len(rest); i += size {
+		hashes = append(hashes, prefix...)
+		hashes = append(hashes, rest[i:i+size]...)
+	}
+	return hashes, nil
+}
+
+// compressBitVector encodes the bitvector of length l either as it is or as
+// the uvarint deltas between the indices of its set bits, whichever is shorter.
+// Wanted hashes of a full bin sync are sparse, as most of the offered chunks
+// are already stored by the downstream peer.
+// An empty slice, signalling that no hashes are wanted, is kept empty.
+func compressBitVector(b []byte, l int) ([]byte, error) {
+	if len(b) == 0 {
+		return b, nil
+	}
+	v, err := bv.NewFromBytes(b, l)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buf  bytes.Buffer
+		tmp  [binary.MaxVarintLen64]byte
+		prev = -1
+	)
+	buf.WriteByte(bitVectorIndices)
+	for i := 0; i < l && buf.Len() <= len(b); i++ {
+		if v.Get(i) {
+			buf.Write(tmp[:binary.PutUvarint(tmp[:], uint64(i-prev))])
+			prev = i
+		}
+	}
+	if buf.Len() > len(b) {
+		c := append([]byte{bitVectorPlain}, b...)
+		compressedBitVectorSaved.Inc(int64(len(b) - len(c)))
+		return c, nil
+	}
+	compressedBitVectorSaved.Inc(int64(len(b) - buf.Len()))
+	return buf.Bytes(), nil
+}
+
+// decompressBitVector decodes a bitvector of length l encoded by compressBitVector
+func decompressBitVector(c []byte, l int) ([]byte, error) {
+	if len(c) == 0 {
+		return c, nil
+	}
+	switch c[0] {
+	case bitVectorPlain:
+		return c[1:], nil
+	case bitVectorIndices:
+		v, err := bv.New(l)
+		if err != nil {
+			return nil, err
+		}
+		r := bytes.NewReader(c[1:])
+		i := -1
+		for r.Len() > 0 {
+			d, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("%w: bitvector index: %v", errInvalidCompression, err)
+			}
+			if d == 0 || d >= uint64(l-i) {
+				return nil, fmt.Errorf("%w: bitvector index delta %d after %d, length %d", errInvalidCompression, d, i, l)
+			}
+			i += int(d)
+			v.Set(i)
+		}
+		return v.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("%w: bitvector format %d", errInvalidCompression, c[0])
+	}
+}
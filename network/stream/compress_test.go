@@ -0,0 +1,193 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	bv "github.com/ethersphere/swarm/network/bitvector"
+)
+
+// TestCompressHashes tests that offered hashes sharing a bin prefix
+// are compressed and decompress to the original hashes, and that
+// repeated hashes are compressed further
+func TestCompressHashes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		n        int
+		prefix   int
+		repeated bool
+	}{
+		{name: "empty", n: 0},
+		{name: "single", n: 1},
+		{name: "no prefix", n: 64},
+		{name: "bin prefix", n: 64, prefix: 2},
+		{name: "full batch", n: MaxBatchSize, prefix: 1},
+		{name: "repeated", n: 64, repeated: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hashes := make([]byte, tc.n*HashSize)
+			rand.Read(hashes)
+			for i := 0; i < len(hashes); i += HashSize {
+				copy(hashes[i:i+tc.prefix], []byte{0xab, 0xcd})
+				if tc.prefix == 0 && tc.n > 1 {
+					hashes[i] = byte(i / HashSize)
+				}
+				if tc.repeated && i >= 2*HashSize {
+					copy(hashes[i+1:i+HashSize], hashes[HashSize+1:2*HashSize])
+				}
+			}
+			c := compressHashes(hashes)
+			if tc.n > 1 {
+				// the format, the prefix length and the prefix precede the remainders
+				want := 2 + tc.prefix + tc.n*(HashSize-tc.prefix)
+				if tc.repeated {
+					if len(c) >= want/2 || c[0] != hashesFlate {
+						t.Fatalf("got compressed length %d, format %d, want less than %d, format %d", len(c), c[0], want/2, hashesFlate)
+					}
+				} else if len(c) > want {
+					t.Fatalf("got compressed length %d, want at most %d", len(c), want)
+				}
+			}
+			got, err := decompressHashes(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, hashes) {
+				t.Fatal("decompressed hashes do not match")
+			}
+		})
+	}
+
+	for _, c := range [][]byte{
+		{hashesPrefix},
+		{hashesPrefix, HashSize},
+		{hashesPrefix, 2, 0xab},
+		{hashesPrefix, 2, 0xab, 0xcd},
+		append([]byte{hashesPrefix, 1, 0xab}, make([]byte, HashSize)...),
+		append(append([]byte{hashesPrefix, HashSize - 1}, make([]byte, HashSize-1)...), make([]byte, MaxBatchSize+1)...),
+		{hashesFlate, 0xff},
+		flateBytes(t, make([]byte, 2+MaxBatchSize*HashSize)),
+		{2, 0, 0},
+	} {
+		if _, err := decompressHashes(c); !errors.Is(err, errInvalidCompression) {
+			t.Errorf("got error %v for %x, want %v", err, c, errInvalidCompression)
+		}
+	}
+}
+
+// flateBytes returns b compressed in the DEFLATE format of compressHashes
+func flateBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(hashesFlate)
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkCompressHashes measures compressing a full batch of hashes of a bin
+// and reports the ratio of the compressed to the original length
+func BenchmarkCompressHashes(b *testing.B) {
+	for _, bin := range []int{0, 8} {
+		b.Run(fmt.Sprintf("bin %d", bin), func(b *testing.B) {
+			b.ReportAllocs()
+			hashes := make([]byte, MaxBatchSize*HashSize)
+			rand.Read(hashes)
+			// hashes of the bin share its first bits with the base address
+			for i := 0; i < len(hashes); i += HashSize {
+				copy(hashes[i:i+bin/8], make([]byte, bin/8))
+			}
+			var c []byte
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c = compressHashes(hashes)
+			}
+			b.ReportMetric(float64(len(c))/float64(len(hashes)), "ratio")
+		})
+	}
+}
+
+// TestCompressBitVector tests that sparse wanted bitvectors are encoded
+// as set bit indices, dense ones as they are, and that both decompress
+// to the original bitvector
+func TestCompressBitVector(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		l      int
+		set    []int
+		format byte
+	}{
+		{name: "sparse", l: MaxBatchSize, set: []int{0, 17, 200, MaxBatchSize - 1}, format: bitVectorIndices},
+		{name: "single", l: 1, set: []int{0}, format: bitVectorPlain},
+		{name: "dense", l: 64, set: []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28, 30}, format: bitVectorPlain},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := bv.New(tc.l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range tc.set {
+				v.Set(i)
+			}
+			c, err := compressBitVector(v.Bytes(), tc.l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c[0] != tc.format {
+				t.Fatalf("got format %d, want %d", c[0], tc.format)
+			}
+			if len(c) > len(v.Bytes())+1 {
+				t.Fatalf("got compressed length %d, bitvector length %d", len(c), len(v.Bytes()))
+			}
+			got, err := decompressBitVector(c, tc.l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, v.Bytes()) {
+				t.Fatalf("got bitvector %x, want %x", got, v.Bytes())
+			}
+		})
+	}
+
+	if c, err := compressBitVector([]byte{}, 10); err != nil || len(c) != 0 {
+		t.Fatalf("got %x, %v for an empty bitvector, want it kept empty", c, err)
+	}
+
+	for _, c := range [][]byte{
+		{2},
+		{bitVectorIndices, 11},
+		{bitVectorIndices, 1, 0},
+		{bitVectorIndices, 0x80},
+	} {
+		if _, err := decompressBitVector(c, 10); !errors.Is(err, errInvalidCompression) {
+			t.Errorf("got error %v for %x, want %v", err, c, errInvalidCompression)
+		}
+	}
+}
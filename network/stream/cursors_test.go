@@ -41,6 +41,7 @@ import (
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pot"
 	"github.com/ethersphere/swarm/state"
+	"github.com/tilinna/clock"
 )
 
 func init() {
@@ -94,6 +95,67 @@ func TestNodesExchangeCorrectBinIndexes(t *testing.T) {
 	}
 }
 
+// backoffClock is a mock clock signalling the timers of the sync init backoff,
+// so that tests advance it only once the peers wait for the backoff
+type backoffClock struct {
+	*clock.Mock
+	backoffC chan struct{}
+}
+
+func (c *backoffClock) NewTimer(d time.Duration) *clock.Timer {
+	t := c.Mock.NewTimer(d)
+	if d == SyncInitBackoff {
+		c.backoffC <- struct{}{}
+	}
+	return t
+}
+
+// TestSyncInitBackoffClock tests that the streams of a new peer are established only once
+// the sync init backoff expires on the simulation clock, which the test advances
+// instead of waiting for the backoff in real time
+func TestSyncInitBackoffClock(t *testing.T) {
+	defer func(b time.Duration) { SyncInitBackoff = b }(SyncInitBackoff)
+	SyncInitBackoff = time.Hour
+
+	opts := &SyncSimServiceOptions{
+		InitialChunkCount: 100,
+	}
+	clk := &backoffClock{
+		Mock:     clock.NewMock(time.Now()),
+		backoffC: make(chan struct{}, 2),
+	}
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		serviceNameStream: newSyncSimServiceFunc(opts),
+	}, false).WithClock(clk)
+	defer sim.Close()
+
+	_, err := sim.AddNodesAndConnectStar(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeIDs := sim.UpNodeIDs()
+	if len(nodeIDs) != 2 {
+		t.Fatal("not enough nodes up")
+	}
+	idOne, idOther := nodeIDs[0], nodeIDs[1]
+
+	// both nodes wait for the backoff before they request the streams of each other
+	for i := 0; i < 2; i++ {
+		select {
+		case <-clk.backoffC:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for the peers to start the sync init backoff")
+		}
+	}
+	if c := getCursorsCopy(sim, idOne, idOther); len(c) != 0 {
+		t.Fatalf("got %v cursors before the backoff, want none", len(c))
+	}
+
+	clk.Add(SyncInitBackoff)
+	waitForCursors(t, sim, idOne, idOther, true)
+	waitForCursors(t, sim, idOther, idOne, true)
+}
+
 // TestNodesCorrectBinsDynamic adds nodes to a star topology, connecting new nodes to the pivot node
 // after each connection is made, the cursors on the pivot are checked, to reflect the bins that we are
 // currently still interested in. this makes sure that correct bins are of interest
@@ -0,0 +1,89 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package stream
+
+import (
+	"github.com/ethersphere/swarm/chunk"
+	bv "github.com/ethersphere/swarm/network/bitvector"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// FuzzStream is the go-fuzz entry point of the decoding of the stream protocol
+// messages received from peers and of the parsing of their contents which does not
+// depend on the state of the stream, see protocols.Spec.FuzzMsg for the format of
+// the input
+func FuzzStream(data []byte) int {
+	msg, err := Spec.FuzzMsg(data)
+	if err != nil {
+		return 0
+	}
+	switch msg := msg.(type) {
+	case *StreamInfoReq:
+		for _, s := range msg.Streams {
+			fuzzStreamID(s)
+		}
+	case *StreamInfoRes:
+		for _, s := range msg.Streams {
+			fuzzStreamID(s.Stream)
+		}
+	case *GetRange:
+		fuzzStreamID(msg.Stream)
+	case *OfferedHashes:
+		// the hashes are compressed if the peer runs compressedVersion or later
+		if hashes, err := decompressHashes(msg.Hashes); err == nil {
+			msg.Hashes = hashes
+		}
+		if len(msg.Hashes)%HashSize != 0 {
+			return 0
+		}
+		for i := 0; i < len(msg.Hashes); i += HashSize {
+			_ = chunk.Address(msg.Hashes[i : i+HashSize]).Hex()
+		}
+	case *WantedHashes:
+		if len(msg.BitVector) == 0 {
+			// no hashes wanted
+			break
+		}
+		// the bitvector is compressed if the peer runs compressedVersion or later
+		if bitvector, err := decompressBitVector(msg.BitVector, len(msg.BitVector)*8); err == nil && len(bitvector) > 0 {
+			msg.BitVector = bitvector
+		}
+		want, err := bv.NewFromBytes(msg.BitVector, len(msg.BitVector)*8)
+		if err != nil {
+			return 0
+		}
+		for i := 0; i < len(msg.BitVector)*8; i++ {
+			want.Get(i)
+		}
+	case *ChunkDelivery:
+		validator := storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash))
+		for _, c := range msg.Chunks {
+			validator.Validate(storage.NewChunk(c.Addr, c.Data))
+		}
+	}
+	return 1
+}
+
+// fuzzStreamID parses the key of the syncing streams
+func fuzzStreamID(id ID) {
+	_ = id.String()
+	if id.Name == syncStreamName {
+		parseSyncKey(id.Key)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package stream
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+var fuzzCorpus = flag.String("fuzz.corpus", "", "directory to write the corpus seeds of the fuzzers to")
+
+// TestFuzzStreamCorpus checks the seeds of FuzzStream, messages like the ones
+// exchanged in the syncing tests, and writes them to the corpus directory if it is
+// given with the fuzz.corpus flag
+func TestFuzzStreamCorpus(t *testing.T) {
+	id := NewID(syncStreamName, encodeSyncKey(3))
+	to := uint64(100)
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+
+	var seeds [][]byte
+	for _, msg := range []interface{}{
+		&StreamInfoReq{Streams: []ID{id, NewID(syncStreamName, encodeSyncKey(4))}},
+		&StreamInfoRes{Streams: []StreamDescriptor{{Stream: id, Cursor: 42}}},
+		&GetRange{Ruid: 1, Stream: id, From: 1, BatchSize: BatchSize},
+		&GetRange{Ruid: 2, Stream: id, From: 1, To: &to, BatchSize: BatchSize},
+		&OfferedHashes{Ruid: 1, LastIndex: 42, Hashes: testutil.RandomBytes(1, 3*HashSize)},
+		&OfferedHashes{Ruid: 1, LastIndex: 42, Hashes: []byte{}},
+		&WantedHashes{Ruid: 1, BitVector: []byte{0x05}},
+		&ChunkDelivery{Ruid: 1, Chunks: []DeliveredChunk{{Addr: ch.Address(), Data: ch.Data()}}},
+	} {
+		seed, err := Spec.FuzzSeed(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FuzzStream(seed) != 1 {
+			t.Fatalf("seed of message %v rejected", msg)
+		}
+		seeds = append(seeds, seed)
+	}
+	if *fuzzCorpus != "" {
+		if err := protocols.WriteFuzzCorpus(*fuzzCorpus, seeds...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
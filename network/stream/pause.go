@@ -0,0 +1,191 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var (
+	syncPausedGauge    = metrics.GetOrRegisterGauge("network/stream/sync_paused/waiting", nil)
+	syncPausedWaitTime = metrics.GetOrRegisterResettingTimer("network/stream/sync_paused/wait-time", nil)
+)
+
+// syncPause holds the exchange of hashes with the peers that syncing is paused with,
+// without dropping the peers or removing their stream cursors, so that syncing
+// continues where it stopped once it is resumed
+type syncPause struct {
+	mtx     sync.Mutex
+	all     bool                  // syncing is paused with all peers
+	peers   map[enode.ID]struct{} // peers syncing is paused with
+	resumeC chan struct{}         // closed and replaced when syncing is resumed
+	waiting int64                 // number of exchanges on hold
+}
+
+func newSyncPause() *syncPause {
+	return &syncPause{
+		peers:   make(map[enode.ID]struct{}),
+		resumeC: make(chan struct{}),
+	}
+}
+
+// pause pauses syncing with the peer, or with all peers if id is nil
+func (s *syncPause) pause(id *enode.ID) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if id == nil {
+		s.all = true
+		return
+	}
+	s.peers[*id] = struct{}{}
+}
+
+// resume resumes syncing with the peer, or with all peers if id is nil,
+// including the peers syncing was paused with individually
+// Resuming a peer while syncing is paused with all peers has no effect
+// until syncing is resumed with all peers
+func (s *syncPause) resume(id *enode.ID) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if id == nil {
+		s.all = false
+		s.peers = make(map[enode.ID]struct{})
+	} else {
+		delete(s.peers, *id)
+	}
+	close(s.resumeC)
+	s.resumeC = make(chan struct{})
+}
+
+// paused returns whether syncing is paused with the peer
+func (s *syncPause) paused(id enode.ID) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.pausedLocked(id)
+}
+
+// pausedAll returns whether syncing is paused with all peers
+func (s *syncPause) pausedAll() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.all
+}
+
+// pausedLocked returns whether syncing is paused with the peer
+// the caller is expected to hold the lock
+func (s *syncPause) pausedLocked(id enode.ID) bool {
+	if s.all {
+		return true
+	}
+	_, ok := s.peers[id]
+	return ok
+}
+
+// wait blocks while syncing with the peer is paused
+// It returns false if either of the quit channels is closed before syncing is resumed
+func (s *syncPause) wait(id enode.ID, quit, peerQuit <-chan struct{}) bool {
+	s.mtx.Lock()
+	if !s.pausedLocked(id) {
+		s.mtx.Unlock()
+		return true
+	}
+	s.waiting++
+	syncPausedGauge.Update(s.waiting)
+	s.mtx.Unlock()
+
+	start := time.Now()
+	defer func() {
+		syncPausedWaitTime.UpdateSince(start)
+		s.mtx.Lock()
+		s.waiting--
+		syncPausedGauge.Update(s.waiting)
+		s.mtx.Unlock()
+	}()
+
+	for {
+		s.mtx.Lock()
+		if !s.pausedLocked(id) {
+			s.mtx.Unlock()
+			return true
+		}
+		resumeC := s.resumeC
+		s.mtx.Unlock()
+
+		select {
+		case <-resumeC:
+		case <-quit:
+			return false
+		case <-peerQuit:
+			return false
+		}
+	}
+}
+
+// PauseSync pauses syncing with the peer, or with all peers if id is nil
+// The exchange of offered and wanted hashes is held, while the peers stay
+// connected and keep their subscriptions, so that syncing continues
+// from where it stopped when it is resumed
+func (r *Registry) PauseSync(id *enode.ID) {
+	r.pause.pause(id)
+	r.logger.Info("syncing paused", "peer", id)
+}
+
+// ResumeSync resumes syncing with the peer, or with all peers if id is nil,
+// including the peers syncing was paused with individually
+func (r *Registry) ResumeSync(id *enode.ID) {
+	r.pause.resume(id)
+	r.logger.Info("syncing resumed", "peer", id)
+}
+
+// SyncPaused returns whether syncing is paused with the peer,
+// or with all peers if id is nil
+func (r *Registry) SyncPaused(id *enode.ID) bool {
+	if id == nil {
+		return r.pause.pausedAll()
+	}
+	return r.pause.paused(*id)
+}
+
+// API is the RPC API of the stream protocol
+type API struct {
+	registry *Registry
+}
+
+// NewAPI returns a new API of the registry
+func NewAPI(r *Registry) *API {
+	return &API{registry: r}
+}
+
+// PauseSync pauses syncing with the peer, or with all peers if the peer is omitted
+func (api *API) PauseSync(id *enode.ID) {
+	api.registry.PauseSync(id)
+}
+
+// ResumeSync resumes syncing with the peer, or with all peers if the peer is omitted
+func (api *API) ResumeSync(id *enode.ID) {
+	api.registry.ResumeSync(id)
+}
+
+// SyncPaused returns whether syncing is paused with the peer,
+// or with all peers if the peer is omitted
+func (api *API) SyncPaused(id *enode.ID) bool {
+	return api.registry.SyncPaused(id)
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network/simulation"
+)
+
+// TestSyncPause validates pausing and resuming syncing
+// with all peers and with individual peers
+func TestSyncPause(t *testing.T) {
+	s := newSyncPause()
+	peer1 := enode.ID{1}
+	peer2 := enode.ID{2}
+
+	waitC := func(id enode.ID, quit chan struct{}) chan bool {
+		c := make(chan bool, 1)
+		go func() {
+			c <- s.wait(id, quit, nil)
+		}()
+		return c
+	}
+	expectResult := func(t *testing.T, c chan bool, want bool) {
+		t.Helper()
+		select {
+		case got := <-c:
+			if got != want {
+				t.Errorf("got wait result %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("wait did not return")
+		}
+	}
+	expectWaiting := func(t *testing.T, c chan bool) {
+		t.Helper()
+		select {
+		case <-c:
+			t.Fatal("wait returned while paused")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	expectResult(t, waitC(peer1, nil), true)
+
+	s.pause(&peer1)
+	if !s.paused(peer1) {
+		t.Error("peer 1 not paused")
+	}
+	if s.paused(peer2) {
+		t.Error("peer 2 paused")
+	}
+	c := waitC(peer1, nil)
+	expectWaiting(t, c)
+	expectResult(t, waitC(peer2, nil), true)
+
+	// resuming another peer does not resume the paused one
+	s.resume(&peer2)
+	expectWaiting(t, c)
+	s.resume(&peer1)
+	expectResult(t, c, true)
+
+	// a peer resumed individually stays paused while all peers are paused
+	s.pause(nil)
+	if !s.pausedAll() {
+		t.Error("not paused with all peers")
+	}
+	c = waitC(peer2, nil)
+	expectWaiting(t, c)
+	s.resume(&peer2)
+	expectWaiting(t, c)
+
+	// resuming all peers resumes the peers paused individually
+	s.pause(&peer1)
+	c1 := waitC(peer1, nil)
+	s.resume(nil)
+	expectResult(t, c, true)
+	expectResult(t, c1, true)
+	if s.paused(peer1) {
+		t.Error("peer 1 paused after resuming all peers")
+	}
+
+	// quitting while paused
+	s.pause(&peer1)
+	quit := make(chan struct{})
+	c = waitC(peer1, quit)
+	expectWaiting(t, c)
+	close(quit)
+	expectResult(t, c, false)
+}
+
+// TestTwoNodesSyncPaused validates that no chunks are synced while
+// syncing is paused and that syncing continues once it is resumed
+func TestTwoNodesSyncPaused(t *testing.T) {
+	const chunkCount = 100
+
+	sim := simulation.NewBzzInProc(map[string]simulation.ServiceFunc{
+		serviceNameStream: newSyncSimServiceFunc(&SyncSimServiceOptions{Autostart: true}),
+	}, false)
+	defer sim.Close()
+
+	uploaderNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustUploadChunks(context.Background(), t, nodeFileStore(sim, uploaderNode), chunkCount)
+
+	syncingNode, err := sim.AddNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := nodeRegistry(sim, syncingNode)
+	registry.PauseSync(nil)
+	if !registry.SyncPaused(nil) {
+		t.Fatal("syncing not paused")
+	}
+
+	if err := sim.Net.Connect(syncingNode, uploaderNode); err != nil {
+		t.Fatal(err)
+	}
+
+	syncingStore := nodeFileStore(sim, syncingNode)
+	time.Sleep(500 * time.Millisecond)
+	count, err := getChunkCount(syncingStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("got %v synced chunks while paused, want 0", count)
+	}
+
+	registry.ResumeSync(nil)
+	if err := waitChunks(syncingStore, chunkCount, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
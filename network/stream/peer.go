@@ -19,6 +19,7 @@ package stream
 import (
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -49,10 +50,11 @@ type Peer struct {
 }
 
 // newPeer is the constructor for Peer
-func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store, providers map[string]StreamProvider) *Peer {
+// the providers of the peer are set when it is added to the registry
+func newPeer(peer *network.BzzPeer, baseAddress *network.BzzAddr, i state.Store) *Peer {
 	p := &Peer{
 		BzzPeer:            peer,
-		providers:          providers,
+		providers:          make(map[string]StreamProvider),
 		intervalsStore:     i,
 		streamCursors:      make(map[string]uint64),
 		openWants:          make(map[uint]*want),
@@ -104,15 +106,47 @@ func (p *Peer) deleteCursor(stream ID) {
 	delete(p.streamCursors, stream.String())
 }
 
+// deleteStreamCursors removes the cursors of all streams with the given name
+func (p *Peer) deleteStreamCursors(name string) {
+	p.streamCursorsMu.Lock()
+	defer p.streamCursorsMu.Unlock()
+
+	for k := range p.streamCursors {
+		if strings.HasPrefix(k, name+"|") {
+			delete(p.streamCursors, k)
+		}
+	}
+}
+
 // InitProviders initializes a provider for a certain peer
 func (p *Peer) InitProviders() {
 	p.logger.Debug("peer.InitProviders")
 
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
 	for _, sp := range p.providers {
 		go sp.InitPeer(p)
 	}
 }
 
+// addProvider makes a provider registered at runtime available to the peer
+func (p *Peer) addProvider(sp StreamProvider) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.providers[sp.StreamName()] = sp
+}
+
+// removeProvider removes a deregistered provider from the peer
+// and stops the streams of the provider with the peer
+func (p *Peer) removeProvider(name string) {
+	p.mtx.Lock()
+	delete(p.providers, name)
+	p.mtx.Unlock()
+
+	// streams without a cursor are not requested any more
+	p.deleteStreamCursors(name)
+}
+
 // offer represents an open offer from a server to a client as a result of a GetRange message
 // it is stored for reference to requests on the peer.openOffers map
 type offer struct {
@@ -0,0 +1,166 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/state"
+)
+
+// mockProvider is a stream provider signalling the peers it is initialized with
+type mockProvider struct {
+	name   string
+	initC  chan *Peer
+	closeC chan struct{}
+}
+
+func newMockProvider(name string) *mockProvider {
+	return &mockProvider{name: name, initC: make(chan *Peer, 1), closeC: make(chan struct{})}
+}
+
+func (m *mockProvider) NeedData(ctx context.Context, addr ...chunk.Address) ([]bool, error) {
+	return make([]bool, len(addr)), nil
+}
+func (m *mockProvider) Get(ctx context.Context, addr ...chunk.Address) ([]chunk.Chunk, error) {
+	return nil, nil
+}
+func (m *mockProvider) Put(ctx context.Context, ch ...chunk.Chunk) ([]bool, error) {
+	return make([]bool, len(ch)), nil
+}
+func (m *mockProvider) Set(ctx context.Context, addrs ...chunk.Address) error { return nil }
+func (m *mockProvider) Subscribe(ctx context.Context, key interface{}, from, to uint64) (<-chan chunk.Descriptor, func()) {
+	return nil, func() {}
+}
+func (m *mockProvider) Cursor(string) (uint64, error)           { return 0, nil }
+func (m *mockProvider) InitPeer(p *Peer)                        { m.initC <- p }
+func (m *mockProvider) WantStream(*Peer, ID) bool               { return true }
+func (m *mockProvider) StreamName() string                      { return m.name }
+func (m *mockProvider) ParseKey(s string) (interface{}, error)  { return s, nil }
+func (m *mockProvider) EncodeKey(k interface{}) (string, error) { return k.(string), nil }
+func (m *mockProvider) Autostart() bool                         { return true }
+func (m *mockProvider) Boundedness() bool                       { return false }
+func (m *mockProvider) Close()                                  { close(m.closeC) }
+
+// TestRegisterProvider tests that providers registered on a running registry
+// are initialized with the connected peers, and that deregistered providers
+// stop their streams with the peers, notify the peers and are closed once
+// the message handlers using them returned
+func TestRegisterProvider(t *testing.T) {
+	r := New(state.NewInmemoryStore(), network.RandomBzzAddr())
+	defer r.Stop()
+
+	id := adapters.RandomNodeConfig().ID
+	rw, remote := p2p.MsgPipe()
+	defer rw.Close()
+	bp := &network.BzzPeer{
+		Peer:    protocols.NewPeer(p2p.NewPeer(id, "test", nil), rw, Spec),
+		BzzAddr: network.RandomBzzAddr(),
+	}
+	p := newPeer(bp, r.address, r.intervalsStore)
+	r.addPeer(p)
+
+	provider := newMockProvider("test")
+	if err := r.RegisterProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterProvider(newMockProvider("test")); err == nil {
+		t.Fatal("expected error registering a provider for the same stream twice")
+	}
+	select {
+	case initialized := <-provider.initC:
+		if initialized != p {
+			t.Fatalf("expected provider to be initialized with peer %v, got %v", p.ID(), initialized.ID())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the provider to be initialized with the peer")
+	}
+	stream := NewID("test", "key")
+	if r.getProvider(stream) != provider {
+		t.Fatal("expected registered provider to serve its streams")
+	}
+
+	p.setCursor(stream, 42)
+	p.setCursor(NewID("other", "key"), 42)
+
+	// a handler using the provider while it is deregistered
+	_, deregistered, release := r.acquireProvider(stream)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.DeregisterProvider("test")
+	}()
+
+	code, _ := Spec.GetCode(StreamState{})
+	if err := p2p.ExpectMsg(remote, code, StreamState{
+		Stream:  ID{Name: "test"},
+		Code:    streamUnavailable,
+		Message: "stream provider deregistered",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-deregistered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the handler to be signalled")
+	}
+	if r.getProvider(stream) != nil {
+		t.Fatal("expected deregistered provider not to serve its streams")
+	}
+	select {
+	case <-provider.closeC:
+		t.Fatal("expected provider not to be closed while a handler uses it")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-provider.closeC:
+	default:
+		t.Fatal("expected deregistered provider to be closed")
+	}
+	if _, ok := p.getCursor(stream); ok {
+		t.Fatal("expected cursor of the deregistered stream to be removed")
+	}
+	if _, ok := p.getCursor(NewID("other", "key")); !ok {
+		t.Fatal("expected cursor of other streams to be kept")
+	}
+	if err := r.DeregisterProvider("test"); err == nil {
+		t.Fatal("expected error deregistering a provider that is not registered")
+	}
+
+	// requests of peers for streams without a provider do not drop the peers
+	if err := r.serverHandleGetRange(context.Background(), p, &GetRange{Stream: stream}); err != errUnsupportedProvider {
+		t.Fatalf("expected error %v, got %v", errUnsupportedProvider, err)
+	}
+	// streams the peer notifies to be unavailable are not requested any more
+	other := NewID("other", "key")
+	if err := r.clientHandleStreamState(context.Background(), p, &StreamState{Stream: ID{Name: "other"}, Code: streamUnavailable}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.getCursor(other); ok {
+		t.Fatal("expected cursor of the unavailable stream to be removed")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	// maxDeliveryQueueSize is the number of chunks that can be wanted from all peers
+	// and not yet delivered and stored at any time
+	maxDeliveryQueueSize uint64 = 16 * BatchSize
+
+	deliveryQueueDepthGauge   = metrics.GetOrRegisterGauge("network/stream/delivery_queue/depth", nil)
+	deliveryQueueWaitingGauge = metrics.GetOrRegisterGauge("network/stream/delivery_queue/waiting", nil)
+	deliveryQueueWaitTimer    = metrics.GetOrRegisterResettingTimer("network/stream/delivery_queue/wait-time", nil)
+)
+
+// deliveryQueue bounds the number of chunks requested with WantedHashes
+// messages which are still in flight or being stored
+// Batches that do not fit wait in FIFO order until earlier batches complete,
+// which pauses the processing of OfferedHashes and in turn the requests
+// for further ranges, so that upstream peers cannot deliver more chunks
+// than the node is able to hold in memory
+type deliveryQueue struct {
+	mtx      sync.Mutex
+	capacity uint64         // maximum number of reserved chunks
+	depth    uint64         // number of reserved chunks
+	waiting  []*queueWaiter // batches waiting for capacity in arrival order
+}
+
+// queueWaiter is a batch waiting for capacity in the delivery queue
+type queueWaiter struct {
+	n uint64        // number of chunks to reserve
+	c chan struct{} // closed once the reservation is granted
+}
+
+func newDeliveryQueue(capacity uint64) *deliveryQueue {
+	return &deliveryQueue{
+		capacity: capacity,
+	}
+}
+
+// reserve blocks until n chunks fit in the queue and reserves them
+// Reservations larger than the capacity of the queue are capped to it
+// It returns false if either of the quit channels is closed before the
+// reservation is granted, in which case nothing needs to be released
+func (q *deliveryQueue) reserve(n uint64, quit, peerQuit <-chan struct{}) bool {
+	if n > q.capacity {
+		n = q.capacity
+	}
+
+	q.mtx.Lock()
+	if len(q.waiting) == 0 && q.depth+n <= q.capacity {
+		q.depth += n
+		deliveryQueueDepthGauge.Update(int64(q.depth))
+		q.mtx.Unlock()
+		return true
+	}
+	w := &queueWaiter{n: n, c: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	deliveryQueueWaitingGauge.Update(int64(len(q.waiting)))
+	q.mtx.Unlock()
+
+	start := time.Now()
+	defer deliveryQueueWaitTimer.UpdateSince(start)
+
+	select {
+	case <-w.c:
+		return true
+	case <-quit:
+	case <-peerQuit:
+	}
+
+	q.mtx.Lock()
+	select {
+	case <-w.c:
+		// the reservation was granted while quitting
+		q.mtx.Unlock()
+		q.release(n)
+		return false
+	default:
+	}
+	for i, v := range q.waiting {
+		if v == w {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			break
+		}
+	}
+	deliveryQueueWaitingGauge.Update(int64(len(q.waiting)))
+	// the removed batch may have been blocking the ones behind it
+	q.grant()
+	q.mtx.Unlock()
+	return false
+}
+
+// release frees n reserved chunks and grants the reservations
+// of the waiting batches that fit in the queue
+func (q *deliveryQueue) release(n uint64) {
+	if n > q.capacity {
+		n = q.capacity
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.depth -= n
+	q.grant()
+}
+
+// grant hands out capacity to the waiting batches in arrival order
+// the caller is expected to hold the lock
+func (q *deliveryQueue) grant() {
+	for len(q.waiting) > 0 && q.depth+q.waiting[0].n <= q.capacity {
+		w := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.depth += w.n
+		close(w.c)
+	}
+	deliveryQueueDepthGauge.Update(int64(q.depth))
+	deliveryQueueWaitingGauge.Update(int64(len(q.waiting)))
+}
+
+// len returns the number of reserved chunks
+func (q *deliveryQueue) len() uint64 {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return q.depth
+}
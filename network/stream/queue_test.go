@@ -0,0 +1,119 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDeliveryQueueBackpressure tests that reservations block while the
+// queue is full and are granted in arrival order as capacity is released
+func TestDeliveryQueueBackpressure(t *testing.T) {
+	q := newDeliveryQueue(10)
+	quit := make(chan struct{})
+
+	if !q.reserve(8, quit, nil) {
+		t.Fatal("expected reservation to be granted")
+	}
+
+	granted := make(chan uint64, 2)
+	for i, n := range []uint64{4, 1} {
+		n := n
+		go func() {
+			if q.reserve(n, quit, nil) {
+				granted <- n
+			}
+		}()
+		waitWaiting(t, q, i+1)
+	}
+
+	// the second batch would fit but must not overtake the first one
+	select {
+	case n := <-granted:
+		t.Fatalf("unexpected reservation of %d granted", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.release(8)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-granted:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for reservations")
+		}
+	}
+	if l := q.len(); l != 5 {
+		t.Fatalf("expected queue length 5, got %d", l)
+	}
+}
+
+// TestDeliveryQueueQuit tests that a waiting reservation is abandoned
+// when the quit channel is closed and does not block the ones behind it
+func TestDeliveryQueueQuit(t *testing.T) {
+	q := newDeliveryQueue(10)
+	quit := make(chan struct{})
+	peerQuit := make(chan struct{})
+
+	if !q.reserve(5, quit, nil) {
+		t.Fatal("expected reservation to be granted")
+	}
+
+	errc := make(chan bool)
+	go func() {
+		errc <- q.reserve(10, quit, peerQuit)
+	}()
+	waitWaiting(t, q, 1)
+
+	granted := make(chan bool)
+	go func() {
+		granted <- q.reserve(5, quit, nil)
+	}()
+	waitWaiting(t, q, 2)
+
+	close(peerQuit)
+	if <-errc {
+		t.Fatal("expected reservation to be abandoned")
+	}
+	select {
+	case ok := <-granted:
+		if !ok {
+			t.Fatal("expected reservation to be granted")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reservation")
+	}
+	if l := q.len(); l != 10 {
+		t.Fatalf("expected queue length 10, got %d", l)
+	}
+}
+
+// waitWaiting waits until n reservations are waiting in the queue
+func waitWaiting(t *testing.T, q *deliveryQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mtx.Lock()
+		l := len(q.waiting)
+		q.mtx.Unlock()
+		if l == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %d queued reservations", n)
+}
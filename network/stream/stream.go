@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -36,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/metrics/labels"
 	"github.com/ethersphere/swarm/network"
 	bv "github.com/ethersphere/swarm/network/bitvector"
 	"github.com/ethersphere/swarm/network/stream/intervals"
@@ -43,12 +45,20 @@ import (
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/state"
 	"github.com/ethersphere/swarm/storage"
+	"github.com/tilinna/clock"
 )
 
 const (
 	HashSize     = 32
 	BatchSize    = 64
 	MinFrameSize = 16
+
+	// MaxBatchSize is the largest batch size a server collects hashes for
+	MaxBatchSize = 4 * BatchSize
+
+	// maxBandwidthBurst is the number of bytes of chunk data delivered at once
+	// under a bandwidth cap, it holds the largest chunks
+	maxBandwidthBurst = 64 * (chunk.DefaultSize + 8)
 )
 
 var (
@@ -81,7 +91,8 @@ var (
 	// Protocol spec
 	Spec = &protocols.Spec{
 		Name:       "bzz-stream",
-		Version:    8,
+		Version:    10,
+		MinVersion: 8, // peers running versions down to MinVersion are synced with, see Peer.Version
 		MaxMsgSize: 10 * 1024 * 1024,
 		Messages: []interface{}{
 			StreamInfoReq{},
@@ -90,11 +101,25 @@ var (
 			OfferedHashes{},
 			ChunkDelivery{},
 			WantedHashes{},
+			StreamState{},
+		},
+		// syncing batches must not delay retrieve requests and hive messages
+		Lanes: map[protocols.Lane][]interface{}{
+			protocols.LaneBulk: {
+				GetRange{},
+				OfferedHashes{},
+				ChunkDelivery{},
+				WantedHashes{},
+			},
 		},
 	}
 
 	// pause the msgHandler execution, used only for tests
 	handleMsgPauser protocols.MsgPauser = nil
+
+	// errUnsupportedProvider is returned for messages of streams without a registered provider,
+	// which are not fatal as providers can be registered and deregistered at runtime
+	errUnsupportedProvider = errors.New("unsupported provider")
 )
 
 // Registry is the base type that handles all client/server operations on a node
@@ -106,11 +131,17 @@ type Registry struct {
 	peers                   map[enode.ID]*Peer        // peers
 	address                 *network.BzzAddr          // this node's base address
 	providers               map[string]StreamProvider // stream providers by name of stream
+	users                   map[string]*providerUsers // message handlers using the providers by name of stream
 	spec                    *protocols.Spec           // this protocol's spec
 	quit                    chan struct{}             // signal shutdown
 	lastReceivedChunkTimeMu sync.RWMutex              // synchronize access to lastReceivedChunkTime
 	lastReceivedChunkTime   time.Time                 // last received chunk time
 	logger                  log.Logger                // the logger for the registry. appends base address to all logs
+	deliveries              *deliveryQueue            // bounds the number of wanted chunks not yet delivered
+	batchTimeout            int64                     // nanoseconds to wait for more chunks before an incomplete batch is offered, accessed atomically
+	bandwidth               *rate.Limiter             // limits the chunk data delivered to syncing peers, unlimited by default
+	pause                   *syncPause                // holds the exchange of hashes with peers syncing is paused with
+	clock                   clock.Clock               // clock the batch timeouts of syncing are timed with
 }
 
 // New creates a new stream protocol handler
@@ -119,26 +150,48 @@ func New(intervalsStore state.Store, address *network.BzzAddr, providers ...Stre
 		intervalsStore: intervalsStore,
 		peers:          make(map[enode.ID]*Peer),
 		providers:      make(map[string]StreamProvider),
+		users:          make(map[string]*providerUsers),
 		quit:           make(chan struct{}),
 		address:        address,
 		logger:         log.New("base", address.ShortString()),
 		spec:           Spec,
+		deliveries:     newDeliveryQueue(maxDeliveryQueueSize),
+		batchTimeout:   int64(timeouts.BatchTimeout),
+		bandwidth:      rate.NewLimiter(rate.Inf, maxBandwidthBurst),
+		pause:          newSyncPause(),
+		clock:          clock.Realtime(),
 	}
 	for _, p := range providers {
 		r.providers[p.StreamName()] = p
+		r.users[p.StreamName()] = newProviderUsers()
 	}
 
 	return r
 }
 
+// providerUsers counts the message handlers using a stream provider,
+// so that a deregistered provider is closed only after they returned
+type providerUsers struct {
+	wg   sync.WaitGroup
+	quit chan struct{} // closed when the provider is deregistered
+}
+
+func newProviderUsers() *providerUsers {
+	return &providerUsers{quit: make(chan struct{})}
+}
+
 // Run is being dispatched when 2 nodes connect
 func (r *Registry) Run(bp *network.BzzPeer) error {
-	sp := newPeer(bp, r.address, r.intervalsStore, r.providers)
+	sp := newPeer(bp, r.address, r.intervalsStore)
+	sp.logger.Debug("stream protocol running", "version", sp.Version())
 	// enable msg pauser for stream protocol, this is used only in tests
 	sp.Peer.SetMsgPauser(handleMsgPauser)
 	r.addPeer(sp)
 	defer r.removePeer(sp)
-	go sp.InitProviders()
+	// only request streams from peers which store chunks
+	if bp.IsStorer() {
+		go sp.InitProviders()
+	}
 
 	return sp.Peer.Run(r.HandleMsg(sp))
 }
@@ -159,6 +212,8 @@ func (r *Registry) HandleMsg(p *Peer) func(context.Context, interface{}) error {
 			return r.serverHandleWantedHashes(ctx, p, msg)
 		case *ChunkDelivery:
 			return r.clientHandleChunkDelivery(ctx, p, msg)
+		case *StreamState:
+			return r.clientHandleStreamState(ctx, p, msg)
 
 		default:
 			// todo: maybe a special error for unknown message, or at least just log it
@@ -176,20 +231,26 @@ func (r *Registry) serverHandleStreamInfoReq(ctx context.Context, p *Peer, msg *
 
 	streamRes := &StreamInfoRes{}
 	for _, v := range msg.Streams {
-		provider := r.getProvider(v)
+		provider, _, release := r.acquireProvider(v)
 		if provider == nil {
 			return fmt.Errorf("unsupported provider for stream: %s", v)
 		}
+		if !serves(provider, p, v) {
+			release()
+			return fmt.Errorf("stream %s not served to peer", v)
+		}
 
 		// get the current cursor from the data source
 		streamCursor, err := provider.Cursor(v.Key)
+		bounded := provider.Boundedness()
+		release()
 		if err != nil {
 			return protocols.Break(fmt.Errorf("get cursor for stream key failed, name %s, key %s: %w", v.Name, v.Key, err))
 		}
 		descriptor := StreamDescriptor{
 			Stream:  v,
 			Cursor:  streamCursor,
-			Bounded: provider.Boundedness(),
+			Bounded: bounded,
 		}
 		streamRes.Streams = append(streamRes.Streams, descriptor)
 	}
@@ -222,15 +283,18 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 		s := s
 
 		// get the provider for this stream
-		provider := r.getProvider(s.Stream)
+		provider, _, release := r.acquireProvider(s.Stream)
 		if provider == nil {
-			// at this point of the message exchange unsupported providers are illegal. drop peer
-			return protocols.Break(errors.New("peer requested unsupported provider"))
+			// the provider was deregistered since the streams were requested
+			p.logger.Debug("stream provider not registered, skipping", "stream", s.Stream)
+			continue
 		}
+		wanted, autostart := provider.WantStream(p, s.Stream), provider.Autostart()
+		release()
 
 		// check if we still want the requested stream. due to the fact that under certain conditions we might not
 		// want to handle the stream by the time that StreamInfoRes has been received in response to StreamInfoReq
-		if !provider.WantStream(p, s.Stream) {
+		if !wanted {
 			if _, exists := p.getCursor(s.Stream); exists {
 				p.logger.Debug("stream cursor exists but we don't want it - removing", "stream", s.Stream)
 				p.deleteCursor(s.Stream)
@@ -247,7 +311,7 @@ func (r *Registry) clientHandleStreamInfoRes(ctx context.Context, p *Peer, msg *
 		p.logger.Debug("setting stream cursor", "stream", s.Stream, "cursor", s.Cursor)
 		p.setCursor(s.Stream, s.Cursor)
 
-		if provider.Autostart() {
+		if autostart {
 			// don't request historical ranges for streams with cursor == 0
 			if s.Cursor > 0 {
 				p.logger.Debug("requesting history stream", "stream", s.Stream, "cursor", s.Cursor)
@@ -309,6 +373,11 @@ func (r *Registry) clientRequestStreamRange(ctx context.Context, p *Peer, provid
 }
 
 func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID, from uint64, to *uint64, head bool) error {
+	// do not request ranges while syncing with the peer is paused
+	if !r.pause.wait(p.ID(), r.quit, p.quit) {
+		return nil
+	}
+
 	g := GetRange{
 		Ruid:      uint(rand.Uint32()),
 		Stream:    stream,
@@ -316,6 +385,9 @@ func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID,
 		To:        to,
 		BatchSize: BatchSize,
 	}
+	if bs, ok := r.getProvider(stream).(batchSizer); ok {
+		g.BatchSize = bs.BatchSize()
+	}
 
 	p.mtx.Lock()
 	s := p.getRangeKey(stream, head)
@@ -349,9 +421,17 @@ func (r *Registry) clientCreateSendWant(ctx context.Context, p *Peer, stream ID,
 // in the case that for the specific interval no chunks exist - the server sends an empty OfferedHashes
 // message so that the client could seal the interval and request the next
 func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRange) error {
-	provider := r.getProvider(msg.Stream)
+	// do not offer hashes while syncing with the peer is paused
+	if !r.pause.wait(p.ID(), r.quit, p.quit) {
+		return nil
+	}
+	provider, deregistered, release := r.acquireProvider(msg.Stream)
 	if provider == nil {
-		return protocols.Break(fmt.Errorf("unsupported provider"))
+		return errUnsupportedProvider
+	}
+	defer release()
+	if !serves(provider, p, msg.Stream) {
+		return fmt.Errorf("stream %s not served to peer", msg.Stream)
 	}
 
 	p.logger.Debug("serverHandleGetRange", "ruid", msg.Ruid, "head?", msg.To == nil)
@@ -384,7 +464,7 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 	if msg.To != nil {
 		to = *msg.To
 	}
-	h, _, t, e, err := r.serverCollectBatch(ctx, p, provider, key, msg.From, to)
+	h, _, t, e, err := r.serverCollectBatch(ctx, p, provider, deregistered, key, msg.From, to, serverBatchSize(msg.BatchSize))
 	if err != nil {
 		return protocols.Break(fmt.Errorf("getting live batch for stream %s: %w", msg.Stream, err))
 	}
@@ -396,6 +476,8 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 			return nil
 		case <-p.quit:
 			return nil
+		case <-deregistered:
+			return nil
 		default:
 			// if the batch is empty resulting from a request for the tip
 			// the lastIdx is msg.From
@@ -432,6 +514,9 @@ func (r *Registry) serverHandleGetRange(ctx context.Context, p *Peer, msg *GetRa
 		LastIndex: t,
 		Hashes:    h,
 	}
+	if p.compresses() {
+		offered.Hashes = compressHashes(h)
+	}
 	l := len(h) / HashSize
 	if msg.To == nil {
 		headBatchSizeGauge.Update(int64(l))
@@ -458,10 +543,18 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 	if err != nil {
 		return protocols.Break(err)
 	}
-	provider := r.getProvider(w.stream)
+	// do not want hashes while syncing with the peer is paused
+	if !r.pause.wait(p.ID(), r.quit, p.quit) {
+		return nil
+	}
+	provider, deregistered, release := r.acquireProvider(w.stream)
 	if provider == nil {
-		return protocols.Break(fmt.Errorf("unsupported provider"))
+		p.mtx.Lock()
+		delete(p.openWants, w.ruid)
+		p.mtx.Unlock()
+		return errUnsupportedProvider
 	}
+	defer release()
 
 	p.logger.Debug("clientHandleOfferedHashes", "ruid", msg.Ruid, "msg.lastIndex", msg.LastIndex)
 	start := time.Now()
@@ -469,6 +562,14 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		metrics.GetOrRegisterResettingTimer("network/stream/handle_offered_hashes/total-time", nil).UpdateSince(start)
 	}(start)
 
+	if p.compresses() {
+		hashes, err := decompressHashes(msg.Hashes)
+		if err != nil {
+			return protocols.Break(fmt.Errorf("decompressing offered hashes, ruid %d: %w", msg.Ruid, err))
+		}
+		msg.Hashes = hashes
+	}
+
 	var (
 		lenHashes                    = len(msg.Hashes)
 		ctr             uint64       = 0                                         // the number of chunks wanted out of the batch
@@ -477,7 +578,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		errc            <-chan error                                             // channel to signal end of batch
 	)
 
-	if lenHashes%HashSize != 0 {
+	if lenHashes%HashSize != 0 || lenHashes/HashSize > MaxBatchSize {
 		return protocols.Break(fmt.Errorf("invalid hashes length: %d, ruid: %d", lenHashes, msg.Ruid))
 	}
 
@@ -556,8 +657,20 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		return r.requestSubsequentRange(ctx, p, provider, w, msg.LastIndex)
 	} else {
 		// we want some hashes
+		// wait until the wanted chunks fit in the delivery queue before requesting them,
+		// this holds back further ranges from the peer while the node is busy storing chunks
+		if !r.deliveries.reserve(ctr, r.quit, p.quit) {
+			return nil
+		}
+		defer r.deliveries.release(ctr)
+
 		streamWantedHashes.Inc(1)
 		wantedHashesMsg.BitVector = want.Bytes() // set to bitvector
+		if p.compresses() {
+			if wantedHashesMsg.BitVector, err = compressBitVector(wantedHashesMsg.BitVector, lenHashes/HashSize); err != nil {
+				return protocols.Break(fmt.Errorf("compressing bitvector, ruid %d: %w", msg.Ruid, err))
+			}
+		}
 
 		errc = r.clientSealBatch(ctx, p, provider, w) // poll for the completion of the batch in a separate goroutine
 	}
@@ -577,7 +690,7 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 		if err := p.sealWant(w); err != nil {
 			return protocols.Break(fmt.Errorf("persisting interval from %d, to %d: %w", w.from, w.to, err))
 		}
-	case <-time.After(timeouts.SyncBatchTimeout):
+	case <-r.clock.After(timeouts.SyncBatchTimeout):
 		p.logger.Error("batch has timed out", "ruid", w.ruid)
 		close(w.closeC) // signal the polling goroutine to terminate
 		p.mtx.Lock()
@@ -595,6 +708,11 @@ func (r *Registry) clientHandleOfferedHashes(ctx context.Context, p *Peer, msg *
 			return protocols.Break(errors.New("batch has timed out"))
 		}
 		return nil
+	case <-deregistered:
+		// the want is kept so that chunks of the batch delivered later
+		// are ignored instead of dropping the peer
+		close(w.closeC)
+		return nil
 	case <-r.quit:
 		return nil
 	case <-p.quit:
@@ -611,10 +729,14 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 	if err != nil {
 		return protocols.Break(err)
 	}
-	provider := r.getProvider(o.stream)
+	provider, _, release := r.acquireProvider(o.stream)
 	if provider == nil {
-		return protocols.Break(errors.New("unsupported provider"))
+		p.mtx.Lock()
+		delete(p.openOffers, msg.Ruid)
+		p.mtx.Unlock()
+		return errUnsupportedProvider
 	}
+	defer release()
 
 	p.logger.Debug("serverHandleWantedHashes", "ruid", msg.Ruid)
 	start := time.Now()
@@ -646,7 +768,13 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 		}
 		return nil
 	}
-	want, err := bv.NewFromBytes(msg.BitVector, l)
+	bitvector := msg.BitVector
+	if p.compresses() {
+		if bitvector, err = decompressBitVector(bitvector, l); err != nil {
+			return protocols.Break(fmt.Errorf("decompressing bitvector, ruid %d: %w", msg.Ruid, err))
+		}
+	}
+	want, err := bv.NewFromBytes(bitvector, l)
 	if err != nil {
 		return protocols.Break(fmt.Errorf("initialising bitvector, l %d, ll %d: %w", l, len(o.hashes), err))
 	}
@@ -677,6 +805,9 @@ func (r *Registry) serverHandleWantedHashes(ctx context.Context, p *Peer, msg *W
 
 	// append the chunks to the chunk delivery message. when reaching maxFrameSize send the current batch
 	for _, v := range chunks {
+		if err := r.bandwidth.WaitN(ctx, len(v.Data())); err != nil {
+			return protocols.Break(fmt.Errorf("bandwidth cap, ruid %d: %w", msg.Ruid, err))
+		}
 		chunkD := DeliveredChunk{
 			Addr: v.Address(),
 			Data: v.Data(),
@@ -732,10 +863,11 @@ func (r *Registry) clientHandleChunkDelivery(ctx context.Context, p *Peer, msg *
 		streamChunkDeliveryFail.Inc(1)
 		return protocols.Break(err)
 	}
-	provider := r.getProvider(w.stream)
+	provider, _, release := r.acquireProvider(w.stream)
 	if provider == nil {
-		return protocols.Break(fmt.Errorf("unsupported provider"))
+		return errUnsupportedProvider
 	}
+	defer release()
 
 	p.logger.Debug("clientHandleChunkDelivery", "ruid", msg.Ruid)
 
@@ -745,6 +877,7 @@ func (r *Registry) clientHandleChunkDelivery(ctx context.Context, p *Peer, msg *
 		return nil
 	}
 	processReceivedChunksMsgCount.Inc(1)
+	metrics.GetOrRegisterCounter(labels.Name("network/stream/peer/received_chunks", labels.PeerKey, labels.Peer(p.BzzAddr.Over())), nil).Inc(int64(len(msg.Chunks)))
 	r.setLastReceivedChunkTime() // needed for IsPullSyncing
 
 	defer func(start time.Time) {
@@ -848,7 +981,7 @@ func (r *Registry) clientSealBatch(ctx context.Context, p *Peer, provider Stream
 
 // serverCollectBatch collects a batch of hashes in response for a GetRange message
 // it will block until at least one hash is received from the provider
-func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider StreamProvider, key interface{}, from, to uint64) (hashes []byte, f, t uint64, empty bool, err error) {
+func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider StreamProvider, deregistered <-chan struct{}, key interface{}, from, to uint64, size int) (hashes []byte, f, t uint64, empty bool, err error) {
 	p.logger.Debug("serverCollectBatch", "from", from, "to", to)
 
 	var (
@@ -856,7 +989,7 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 		batchSize    int
 		batchStartID *uint64
 		batchEndID   uint64
-		timer        *time.Timer
+		timer        *clock.Timer
 		timerC       <-chan time.Time
 	)
 
@@ -889,17 +1022,17 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 				batchStartID = &d.BinID
 			}
 			batchEndID = d.BinID
-			if batchSize >= BatchSize {
+			if batchSize >= size {
 				iterate = false
 				metrics.GetOrRegisterCounter("network/stream/server_collect_batch/full-batch", nil).Inc(1)
 			}
 			if timer == nil {
-				timer = time.NewTimer(timeouts.BatchTimeout)
+				timer = r.clock.NewTimer(r.BatchTimeout())
 			} else {
 				if !timer.Stop() {
 					<-timer.C
 				}
-				timer.Reset(timeouts.BatchTimeout)
+				timer.Reset(r.BatchTimeout())
 			}
 			timerC = timer.C
 		case <-timerC:
@@ -910,6 +1043,8 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 			iterate = false
 		case <-r.quit:
 			iterate = false
+		case <-deregistered:
+			iterate = false
 		}
 	}
 	if batchStartID == nil {
@@ -919,6 +1054,18 @@ func (r *Registry) serverCollectBatch(ctx context.Context, p *Peer, provider Str
 	return batch, *batchStartID, batchEndID, false, nil
 }
 
+// serverBatchSize returns the number of hashes to collect for a batch
+// requested with the given size, which is capped to MaxBatchSize
+func serverBatchSize(requested uint) int {
+	switch {
+	case requested == 0:
+		return BatchSize
+	case requested > MaxBatchSize:
+		return MaxBatchSize
+	}
+	return int(requested)
+}
+
 // requestSubsequentRange checks the cursor for the current stream, and in case needed - requests the next range
 func (r *Registry) requestSubsequentRange(ctx context.Context, p *Peer, provider StreamProvider, w *want, lastIndex uint64) error {
 	cur, ok := p.getCursor(w.stream)
@@ -952,6 +1099,31 @@ func (r *Registry) getProvider(stream ID) StreamProvider {
 	return r.providers[stream.Name]
 }
 
+// acquireProvider returns the provider of the stream for a message handler, the channel
+// closed when the provider is deregistered and the function the handler calls once it
+// no longer uses the provider, which is not closed before. The provider is nil if the
+// stream has no registered provider.
+func (r *Registry) acquireProvider(stream ID) (StreamProvider, <-chan struct{}, func()) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	provider, ok := r.providers[stream.Name]
+	if !ok {
+		return nil, nil, func() {}
+	}
+	u := r.users[stream.Name]
+	u.wg.Add(1)
+	return provider, u.quit, u.wg.Done
+}
+
+// serves returns true if the provider serves the stream to the peer
+func serves(provider StreamProvider, p *Peer, stream ID) bool {
+	if s, ok := provider.(streamServer); ok {
+		return s.ServeStream(p, stream)
+	}
+	return true
+}
+
 func (r *Registry) getPeer(id enode.ID) *Peer {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
@@ -959,9 +1131,110 @@ func (r *Registry) getPeer(id enode.ID) *Peer {
 	return p
 }
 
+// RegisterProvider adds a stream provider to the running registry and initializes
+// it with the connected peers that store chunks, so that new kinds of streams can
+// be added without restarting the node. The streams of the provider are available
+// to the peers once they register a provider for them as well.
+func (r *Registry) RegisterProvider(provider StreamProvider) error {
+	name := provider.StreamName()
+
+	r.mtx.Lock()
+	select {
+	case <-r.quit:
+		r.mtx.Unlock()
+		return errors.New("stream registry stopped")
+	default:
+	}
+	if _, ok := r.providers[name]; ok {
+		r.mtx.Unlock()
+		return fmt.Errorf("stream provider %q already registered", name)
+	}
+	r.providers[name] = provider
+	r.users[name] = newProviderUsers()
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		p.addProvider(provider)
+		peers = append(peers, p)
+	}
+	r.mtx.Unlock()
+
+	r.logger.Info("stream provider registered", "stream", name)
+	for _, p := range peers {
+		// only request streams from peers which store chunks
+		if p.IsStorer() {
+			go provider.InitPeer(p)
+		}
+	}
+	return nil
+}
+
+// DeregisterProvider removes the stream provider with the given name from the
+// running registry, stops its streams with the connected peers, notifies the peers
+// that its streams are no longer served and closes it once the message handlers
+// using it returned. Messages of the peers for its streams are ignored afterwards.
+func (r *Registry) DeregisterProvider(name string) error {
+	r.mtx.Lock()
+	provider, ok := r.providers[name]
+	if !ok {
+		r.mtx.Unlock()
+		return fmt.Errorf("stream provider %q not registered", name)
+	}
+	users := r.users[name]
+	delete(r.providers, name)
+	delete(r.users, name)
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, p := range r.peers {
+		peers = append(peers, p)
+	}
+	r.mtx.Unlock()
+
+	close(users.quit)
+	for _, p := range peers {
+		p.removeProvider(name)
+		if p.Version() >= streamStateVersion {
+			go r.sendStreamUnavailable(p, name)
+		}
+	}
+	users.wg.Wait()
+	provider.Close()
+	r.logger.Info("stream provider deregistered", "stream", name)
+	return nil
+}
+
+// sendStreamUnavailable notifies the peer that the streams with the given name are
+// no longer served, so that it stops requesting them
+func (r *Registry) sendStreamUnavailable(p *Peer, name string) {
+	msg := StreamState{
+		Stream:  ID{Name: name},
+		Code:    streamUnavailable,
+		Message: "stream provider deregistered",
+	}
+	if err := p.Send(context.Background(), msg); err != nil {
+		p.logger.Debug("sending stream unavailable", "stream", name, "err", err)
+	}
+}
+
+// clientHandleStreamState handles the StreamState message (Peer is the server)
+// streams that are no longer served by the peer are not requested any more,
+// the batches in progress are completed or time out
+func (r *Registry) clientHandleStreamState(ctx context.Context, p *Peer, msg *StreamState) error {
+	switch msg.Code {
+	case streamUnavailable:
+		p.logger.Debug("peer does not serve stream any more", "stream", msg.Stream.Name, "reason", msg.Message)
+		p.deleteStreamCursors(msg.Stream.Name)
+	default:
+		p.logger.Debug("unknown stream state", "stream", msg.Stream, "code", msg.Code, "message", msg.Message)
+	}
+	return nil
+}
+
+// addPeer adds the peer with the providers registered at the time
 func (r *Registry) addPeer(p *Peer) {
 	r.mtx.Lock()
 	defer r.mtx.Unlock()
+	for _, sp := range r.providers {
+		p.addProvider(sp)
+	}
 	r.peers[p.ID()] = p
 
 	streamPeersCount.Update(int64(len(r.peers)))
@@ -974,6 +1247,7 @@ func (r *Registry) removePeer(p *Peer) {
 		p.logger.Error("removing peer")
 		delete(r.peers, p.ID())
 		close(p.quit)
+		labels.Unregister(nil, "network/stream/peer/", labels.PeerKey, labels.Peer(p.BzzAddr.Over()))
 	}
 	streamPeersCount.Update(int64(len(r.peers)))
 }
@@ -983,6 +1257,7 @@ type PeerInfo struct {
 	Base      string                       `json:"base"` // our node's base address
 	Kademlia  string                       `json:"kademlia"`
 	Peers     []PeerState                  `json:"peers"`
+	Paused    bool                         `json:"paused"` // syncing is paused with all peers
 	Cursors   map[string]map[string]uint64 `json:"cursors"`
 	Intervals map[string]string            `json:"intervals"`
 }
@@ -991,6 +1266,7 @@ type PeerInfo struct {
 type PeerState struct {
 	Peer    string            `json:"peer"` // the peer address
 	Cursors map[string]uint64 `json:"cursors"`
+	Paused  bool              `json:"paused"` // syncing is paused with the peer
 }
 
 // PeerInfo returns a response in which the queried node's
@@ -999,8 +1275,15 @@ func (r *Registry) PeerInfo() (*PeerInfo, error) {
 	info := &PeerInfo{
 		Base:    r.address.ShortUnder(),
 		Cursors: make(map[string]map[string]uint64),
+		Paused:  r.pause.pausedAll(),
 	}
+	r.mtx.RLock()
+	providers := make(map[string]StreamProvider, len(r.providers))
 	for name, p := range r.providers {
+		providers[name] = p
+	}
+	r.mtx.RUnlock()
+	for name, p := range providers {
 		info.Cursors[name] = make(map[string]uint64)
 		if name != syncStreamName {
 			// support only sync provider, for now
@@ -1036,11 +1319,41 @@ func (r *Registry) PeerInfo() (*PeerInfo, error) {
 		info.Peers = append(info.Peers, PeerState{
 			Peer:    hex.EncodeToString(p.OAddr)[:16],
 			Cursors: p.getCursorsCopy(),
+			Paused:  r.pause.paused(p.ID()),
 		})
 	}
 	return info, nil
 }
 
+// BatchTimeout returns the time the server waits for more chunks
+// before it offers an incomplete batch to the client.
+func (r *Registry) BatchTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.batchTimeout))
+}
+
+// SetBatchTimeout changes the time the server waits for more chunks
+// before it offers an incomplete batch, it applies to the following batches.
+func (r *Registry) SetBatchTimeout(d time.Duration) {
+	atomic.StoreInt64(&r.batchTimeout, int64(d))
+}
+
+// SetBandwidth sets the number of bytes of chunk data per second the server
+// delivers at most to all syncing peers, zero removes the cap
+func (r *Registry) SetBandwidth(bytesPerSecond int64) {
+	limit := rate.Inf
+	if bytesPerSecond > 0 {
+		limit = rate.Limit(bytesPerSecond)
+	}
+	r.bandwidth.SetLimit(limit)
+}
+
+// SetClock sets the clock the batch timeouts of syncing are timed with, so that
+// simulations can advance it with a mock clock. It must be called before the
+// registry runs with any peers.
+func (r *Registry) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
 // LastReceivedChunkTime returns the time when the last chunk
 // was received by syncing. This method is used in api.Inspector
 // to detect when the syncing is complete.
@@ -1074,7 +1387,14 @@ func (r *Registry) runProtocol(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 }
 
 func (r *Registry) APIs() []rpc.API {
-	return nil
+	return []rpc.API{
+		{
+			Namespace: "stream",
+			Version:   "1.0",
+			Service:   NewAPI(r),
+			Public:    false,
+		},
+	}
 }
 
 func (r *Registry) Start(server *p2p.Server) error {
@@ -1086,11 +1406,19 @@ func (r *Registry) Start(server *p2p.Server) error {
 func (r *Registry) Stop() error {
 	log.Debug("stream registry stopping")
 	r.mtx.Lock()
-	defer r.mtx.Unlock()
 	close(r.quit)
+	peers := make([]*Peer, 0, len(r.peers))
+	for _, peer := range r.peers {
+		peers = append(peers, peer)
+	}
+	// the providers are removed so that they are not closed again by DeregisterProvider
+	providers, users := r.providers, r.users
+	r.providers = make(map[string]StreamProvider)
+	r.users = make(map[string]*providerUsers)
+	r.mtx.Unlock()
 
 	var eg errgroup.Group
-	for _, peer := range r.peers {
+	for _, peer := range peers {
 		peer := peer
 		eg.Go(func() error {
 			return peer.Stop(5 * time.Second)
@@ -1102,7 +1430,11 @@ func (r *Registry) Stop() error {
 		r.logger.Error("stream closed with still active handlers")
 	}
 
-	for _, v := range r.providers {
+	// handlers still using the providers are signalled to return and waited for before closing them
+	for name, v := range providers {
+		u := users[name]
+		close(u.quit)
+		u.wg.Wait()
 		v.Close()
 	}
 
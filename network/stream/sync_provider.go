@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	lru "github.com/hashicorp/golang-lru"
+	"github.com/tilinna/clock"
 
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/log"
@@ -58,6 +59,7 @@ type syncProvider struct {
 	setCacheMtx             sync.RWMutex      // set cache mutex
 	setCache                *lru.Cache        // cache to reduce load on localstore to not set the same chunk as synced
 	logger                  log.Logger        // logger that appends the base address to loglines
+	clock                   clock.Clock       // clock the sync delays are timed with
 }
 
 // NewSyncProvider creates a new sync provider that is used by the stream protocol to sink data and control its behaviour
@@ -65,6 +67,14 @@ type syncProvider struct {
 // established only within depth ( >=depth ). This is needed for Push Sync. When set to false, the streams are
 // established on all bins as they did traditionally with Pull Sync.
 func NewSyncProvider(ns *storage.NetStore, kad *network.Kademlia, baseAddr *network.BzzAddr, autostart bool, syncOnlyWithinDepth bool) StreamProvider {
+	return NewSyncProviderWithClock(ns, kad, baseAddr, autostart, syncOnlyWithinDepth, clock.Realtime())
+}
+
+// NewSyncProviderWithClock is the same as NewSyncProvider but times the delays of syncing,
+// the backoff before establishing the streams of a new peer, the interval of the storage
+// radius checks and the wait for the chunks it wants, with the given clock, so that
+// simulations can advance them deterministically with a mock clock
+func NewSyncProviderWithClock(ns *storage.NetStore, kad *network.Kademlia, baseAddr *network.BzzAddr, autostart bool, syncOnlyWithinDepth bool, clk clock.Clock) StreamProvider {
 	c, err := lru.New(cacheCapacity)
 	if err != nil {
 		panic(err)
@@ -84,6 +94,7 @@ func NewSyncProvider(ns *storage.NetStore, kad *network.Kademlia, baseAddr *netw
 		cache:                   c,
 		setCache:                sc,
 		logger:                  log.NewBaseAddressLogger(baseAddr.ShortString()),
+		clock:                   clk,
 	}
 }
 
@@ -141,7 +152,7 @@ func (s *syncProvider) NeedData(ctx context.Context, addrs ...chunk.Address) ([]
 				select {
 				case <-fi.Delivered:
 					metrics.GetOrRegisterResettingTimer(fmt.Sprintf("fetcher/%s/syncer", fi.CreatedBy), nil).UpdateSince(start)
-				case <-time.After(timeouts.SyncerClientWaitTimeout):
+				case <-s.clock.After(timeouts.SyncerClientWaitTimeout):
 					metrics.GetOrRegisterCounter("fetcher/syncer/timeout", nil).Inc(1)
 				}
 			}()
@@ -286,7 +297,7 @@ func (s *syncProvider) WantStream(p *Peer, streamID ID) bool {
 	depth := s.kad.NeighbourhoodDepth()
 
 	// check all subscriptions that should exist for this peer
-	subBins, _ := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+	subBins, _ := syncSubscriptionsRadiusDiff(po, -1, depth, 0, s.storageRadius(), s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
 	v, err := parseSyncKey(streamID.Key)
 	if err != nil {
 		return false
@@ -296,6 +307,10 @@ func (s *syncProvider) WantStream(p *Peer, streamID ID) bool {
 
 var (
 	SyncInitBackoff = 500 * time.Millisecond
+
+	// SyncRadiusInterval is the interval in which the storage radius
+	// is checked for changes that require updating the subscriptions
+	SyncRadiusInterval = time.Minute
 )
 
 // InitPeer creates and maintains the streams per peer.
@@ -304,10 +319,11 @@ var (
 //  - peer moves from out-of-depth to depth
 //  - peer moves from depth to out-of-depth
 //  - depth changes, and peer stays in depth, but we need more or less
+// or when the storage radius of the node changes
 // peer connects and disconnects quickly
 func (s *syncProvider) InitPeer(p *Peer) {
 	p.logger.Debug("syncProvider.InitPeer")
-	timer := time.NewTimer(SyncInitBackoff)
+	timer := s.clock.NewTimer(SyncInitBackoff)
 	defer timer.Stop()
 
 	select {
@@ -318,37 +334,59 @@ func (s *syncProvider) InitPeer(p *Peer) {
 
 	po := chunk.Proximity(p.BzzAddr.Over(), s.kad.BaseAddr())
 	depth := s.kad.NeighbourhoodDepth()
+	radius := s.storageRadius()
 
-	p.logger.Debug("update syncing subscriptions: initial", "po", po, "depth", depth)
+	p.logger.Debug("update syncing subscriptions: initial", "po", po, "depth", depth, "radius", radius)
 
-	subBins, quitBins := syncSubscriptionsDiff(po, -1, depth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+	subBins, quitBins := syncSubscriptionsRadiusDiff(po, -1, depth, 0, radius, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
 	s.updateSyncSubscriptions(p, subBins, quitBins)
 
 	depthChangeSignal, unsubscribeDepthChangeSignal := s.kad.SubscribeToNeighbourhoodDepthChange()
 	defer unsubscribeDepthChangeSignal()
 
+	ticker := s.clock.NewTicker(SyncRadiusInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case _, ok := <-depthChangeSignal:
 			if !ok {
 				return
 			}
-
-			// update subscriptions for this peer when depth changes
-			ndepth := s.kad.NeighbourhoodDepth()
-			subs, quits := syncSubscriptionsDiff(po, depth, ndepth, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
-			p.logger.Debug("update syncing subscriptions", "po", po, "depth", depth, "sub", subs, "quit", quits)
-			s.updateSyncSubscriptions(p, subs, quits)
-			depth = ndepth
+		case <-ticker.C:
+			if s.storageRadius() == radius {
+				continue
+			}
 		case <-s.quit:
 			return
 		case <-p.quit:
 			return
 		}
 
+		// update subscriptions for this peer when depth or radius changes
+		ndepth := s.kad.NeighbourhoodDepth()
+		nradius := s.storageRadius()
+		subs, quits := syncSubscriptionsRadiusDiff(po, depth, ndepth, radius, nradius, s.kad.MaxProxDisplay, s.syncBinsOnlyWithinDepth)
+		p.logger.Debug("update syncing subscriptions", "po", po, "depth", depth, "radius", radius, "sub", subs, "quit", quits)
+		s.updateSyncSubscriptions(p, subs, quits)
+		depth, radius = ndepth, nradius
 	}
 }
 
+// storageRadius returns the lowest proximity order bin the local store
+// has capacity to hold, so that lower bins are not synced
+func (s *syncProvider) storageRadius() int {
+	if s.netStore.StorageRadius == nil {
+		return 0
+	}
+	radius, err := s.netStore.StorageRadius()
+	if err != nil {
+		s.logger.Error("storage radius", "err", err)
+		return 0
+	}
+	return int(radius)
+}
+
 // updateSyncSubscriptions accepts two slices of integers, the first one
 // representing proximity order bins for required syncing subscriptions
 // and the second one representing bins for syncing subscriptions that
@@ -392,41 +430,42 @@ func (s *syncProvider) updateSyncSubscriptions(p *Peer, subBins, quitBins []int)
 // syncBinsOnlyWithinDepth toggles between having requested streams only within depth(true)
 // or rather with the old stream establishing logic (false)
 func syncSubscriptionsDiff(peerPO, prevDepth, newDepth, max int, syncBinsOnlyWithinDepth bool) (subBins, quitBins []int) {
+	return syncSubscriptionsRadiusDiff(peerPO, prevDepth, newDepth, 0, 0, max, syncBinsOnlyWithinDepth)
+}
+
+// syncSubscriptionsRadiusDiff is the same as syncSubscriptionsDiff, but also
+// leaves out the bins below the storage radius of the node, prevRadius being
+// the radius before and newRadius after the change.
+func syncSubscriptionsRadiusDiff(peerPO, prevDepth, newDepth, prevRadius, newRadius, max int, syncBinsOnlyWithinDepth bool) (subBins, quitBins []int) {
 	newStart, newEnd := syncBins(peerPO, newDepth, max, syncBinsOnlyWithinDepth)
+	newBins := radiusBins(newStart, newEnd, newRadius)
 	if prevDepth < 0 {
-		if newStart == -1 && newEnd == -1 {
-			return nil, nil
-		}
 		// no previous depth, return the complete range
 		// for subscriptions requests and nothing for quitting
-		return intRange(newStart, newEnd), nil
+		return newBins, nil
 	}
-
 	prevStart, prevEnd := syncBins(peerPO, prevDepth, max, syncBinsOnlyWithinDepth)
-	if newStart == -1 && newEnd == -1 {
-		// this means that we should not have any streams on any bins with this peer
-		// get rid of what was established on the previous depth
-		quitBins = append(quitBins, intRange(prevStart, prevEnd)...)
-		return
-	}
+	prevBins := radiusBins(prevStart, prevEnd, prevRadius)
 
-	if newStart < prevStart {
-		subBins = append(subBins, intRange(newStart, prevStart)...)
-	}
-
-	if prevStart < newStart {
-		quitBins = append(quitBins, intRange(prevStart, newStart)...)
+	for _, bin := range newBins {
+		if !checkKeyInSlice(bin, prevBins) {
+			subBins = append(subBins, bin)
+		}
 	}
-
-	if newEnd < prevEnd {
-		quitBins = append(quitBins, intRange(newEnd, prevEnd)...)
+	for _, bin := range prevBins {
+		if !checkKeyInSlice(bin, newBins) {
+			quitBins = append(quitBins, bin)
+		}
 	}
+	return subBins, quitBins
+}
 
-	if prevEnd < newEnd {
-		subBins = append(subBins, intRange(prevEnd, newEnd)...)
+// radiusBins returns the bins in range [start,end) that are not below the radius
+func radiusBins(start, end, radius int) []int {
+	if start < radius {
+		start = radius
 	}
-
-	return subBins, quitBins
+	return intRange(start, end)
 }
 
 // syncBins returns the range to which proximity order bins syncing
@@ -188,3 +188,47 @@ func TestSyncSubscriptionsDiff(t *testing.T) {
 		}
 	}
 }
+
+// TestSyncSubscriptionsRadiusDiff validates that syncSubscriptionsRadiusDiff
+// leaves out the bins below the storage radius and updates the subscriptions
+// when only the radius changes.
+func TestSyncSubscriptionsRadiusDiff(t *testing.T) {
+	max := network.NewKadParams().MaxProxDisplay
+	for _, tc := range []struct {
+		po, prevDepth, newDepth int
+		prevRadius, newRadius   int
+		subBins, quitBins       []int
+	}{
+		{
+			po: 1, prevDepth: -1, newDepth: 0, newRadius: 14, // [] -> 14-16
+			subBins: []int{14, 15, 16},
+		},
+		{
+			po: 1, prevDepth: -1, newDepth: 2, newRadius: 2, // [] -> []
+		},
+		{
+			po: 3, prevDepth: 2, newDepth: 2, prevRadius: 0, newRadius: 4, // 2-16 -> 4-16
+			quitBins: []int{2, 3},
+		},
+		{
+			po: 3, prevDepth: 2, newDepth: 2, prevRadius: 4, newRadius: 3, // 4-16 -> 3-16
+			subBins: []int{3},
+		},
+		{
+			po: 1, prevDepth: 2, newDepth: 2, prevRadius: 2, newRadius: 0, // [] -> 1
+			subBins: []int{1},
+		},
+		{
+			po: 4, prevDepth: 5, newDepth: 0, prevRadius: 0, newRadius: 3, // 4 -> 3-16
+			subBins: []int{3, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		},
+	} {
+		subBins, quitBins := syncSubscriptionsRadiusDiff(tc.po, tc.prevDepth, tc.newDepth, tc.prevRadius, tc.newRadius, max, false)
+		if fmt.Sprint(subBins) != fmt.Sprint(tc.subBins) {
+			t.Errorf("%+v: got subBins %v", tc, subBins)
+		}
+		if fmt.Sprint(quitBins) != fmt.Sprint(tc.quitBins) {
+			t.Errorf("%+v: got quitBins %v", tc, quitBins)
+		}
+	}
+}
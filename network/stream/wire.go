@@ -79,6 +79,18 @@ type StreamProvider interface {
 	Close()
 }
 
+// batchSizer is implemented by stream providers whose streams are
+// requested in batches of a size other than BatchSize
+type batchSizer interface {
+	BatchSize() uint
+}
+
+// streamServer is implemented by stream providers that serve their
+// streams only to some of the peers
+type streamServer interface {
+	ServeStream(*Peer, ID) bool
+}
+
 // StreamInfoReq is a request to get information about particular streams
 type StreamInfoReq struct {
 	Streams []ID
@@ -140,6 +152,17 @@ type StreamState struct {
 	Message string
 }
 
+// streamStateVersion is the version of the protocol from which on StreamState
+// messages are exchanged, peers running older versions are not notified
+const streamStateVersion = 10
+
+// codes of StreamState messages
+const (
+	// streamUnavailable notifies the downstream peer that the streams with the name of
+	// the Stream are no longer served, as their provider was deregistered
+	streamUnavailable uint16 = 1
+)
+
 // Stream defines a unique stream identifier in a textual representation
 type ID struct {
 	// Name is used for the Stream provider identification
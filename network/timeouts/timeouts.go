@@ -10,6 +10,10 @@ var FailedPeerSkipDelay = 20 * time.Second
 // Basically this is the amount of time a singleflight request for a given chunk lives
 var FetcherGlobalTimeout = 10 * time.Second
 
+// FailedFetchTTL is the time a chunk that could not be fetched from the network is not requested again,
+// so that repeated requests of missing content are answered without searching the network each time
+var FailedFetchTTL = 10 * time.Second
+
 // FetcherSlowChunkDeliveryThreshold is the threshold above which we log a slow chunk delivery in netstore
 var FetcherSlowChunkDeliveryThreshold = 5 * time.Second
 
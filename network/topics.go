@@ -0,0 +1,200 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/network/capability"
+)
+
+var (
+	// topicSearchFastPeriod is the time between topic searches while the node needs peers
+	topicSearchFastPeriod = time.Second
+	// topicSearchSlowPeriod is the time between topic searches once the node has enough peers
+	topicSearchSlowPeriod = time.Minute
+	// topicRedialInterval is the time after which a node found in a topic search is dialed again
+	topicRedialInterval = 10 * time.Minute
+)
+
+// topicMinPeers is the number of connected peers below which the
+// nodes found in topic searches are dialed
+const topicMinPeers = 8
+
+// BzzTopic returns the discovery v5 topic swarm nodes of the network advertise
+func BzzTopic(networkID uint64) discv5.Topic {
+	return discv5.Topic(fmt.Sprintf("bzz@%d", networkID))
+}
+
+// bzzCapabilityTopic returns the subtopic of the bzz topic advertised by the nodes
+// with the capability preset, so that e.g. light nodes can search for relays, or
+// an empty topic if the capability is none of the presets
+func bzzCapabilityTopic(networkID uint64, c *capability.Capability) discv5.Topic {
+	var name string
+	switch {
+	case isFullCapability(c):
+		name = "full"
+	case isRelayCapability(c):
+		name = "relay"
+	case isLightCapability(c):
+		name = "light"
+	default:
+		return ""
+	}
+	return discv5.Topic(fmt.Sprintf("%s/%s", BzzTopic(networkID), name))
+}
+
+// topicDiscovery advertises the bzz topics of the node with discovery v5 and
+// dials the nodes found searching the bzz topic while the node needs peers,
+// so that the node bootstraps even if the bootnodes are saturated or down
+type topicDiscovery struct {
+	hive      *Hive
+	self      enode.ID
+	topic     discv5.Topic   // searched topic
+	advertise []discv5.Topic // advertised topics
+	mtx       sync.Mutex
+	dialed    map[enode.ID]time.Time // nodes dialed by the time they were last dialed
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func newTopicDiscovery(hive *Hive, self enode.ID, networkID uint64, c *capability.Capability) *topicDiscovery {
+	t := &topicDiscovery{
+		hive:      hive,
+		self:      self,
+		topic:     BzzTopic(networkID),
+		advertise: []discv5.Topic{BzzTopic(networkID)},
+		dialed:    make(map[enode.ID]time.Time),
+		quit:      make(chan struct{}),
+	}
+	if topic := bzzCapabilityTopic(networkID, c); topic != "" {
+		t.advertise = append(t.advertise, topic)
+	}
+	return t
+}
+
+// start registers the advertised topics and searches the bzz topic until stop is called
+func (t *topicDiscovery) start(net *discv5.Network) {
+	for _, topic := range t.advertise {
+		t.wg.Add(1)
+		go func(topic discv5.Topic) {
+			defer t.wg.Done()
+			net.RegisterTopic(topic, t.quit)
+		}(topic)
+	}
+
+	setPeriod := make(chan time.Duration, 1)
+	found := make(chan *discv5.Node, 100)
+	lookup := make(chan bool, 100)
+	t.wg.Add(2)
+	go func() {
+		defer t.wg.Done()
+		net.SearchTopic(t.topic, setPeriod, found, lookup)
+	}()
+	go func() {
+		defer t.wg.Done()
+		defer close(setPeriod)
+		t.search(setPeriod, found, lookup)
+	}()
+	log.Info(fmt.Sprintf("%08x hive started topic discovery", t.hive.BaseAddr()[:4]), "topics", t.advertise)
+}
+
+// stop stops advertising and searching the topics
+func (t *topicDiscovery) stop() {
+	close(t.quit)
+	t.wg.Wait()
+}
+
+// search dials the found nodes and searches fast while the node needs peers
+func (t *topicDiscovery) search(setPeriod chan<- time.Duration, found <-chan *discv5.Node, lookup <-chan bool) {
+	period := topicSearchFastPeriod
+	select {
+	case setPeriod <- period:
+	case <-t.quit:
+		return
+	}
+	ticker := time.NewTicker(topicSearchFastPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case n := <-found:
+			t.found(n, time.Now())
+		case <-lookup:
+		case <-ticker.C:
+			next := topicSearchSlowPeriod
+			if t.needsPeers() {
+				next = topicSearchFastPeriod
+			}
+			if next != period {
+				period = next
+				select {
+				case setPeriod <- period:
+				case <-t.quit:
+					return
+				}
+			}
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// needsPeers tells whether the node has too few peers, or its neighbourhood depth is 0
+func (t *topicDiscovery) needsPeers() bool {
+	t.hive.lock.Lock()
+	peers := len(t.hive.peers)
+	t.hive.lock.Unlock()
+	return peers < topicMinPeers || t.hive.NeighbourhoodDepth() == 0
+}
+
+// found dials the node found in a topic search if the node needs peers and it
+// is neither connected nor dialed recently, and reports whether it was dialed
+func (t *topicDiscovery) found(n *discv5.Node, now time.Time) bool {
+	pubkey, err := n.ID.Pubkey()
+	if err != nil {
+		log.Debug("invalid node found in topic search", "node", n, "err", err)
+		return false
+	}
+	node := enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP))
+	id := node.ID()
+	if id == t.self || t.hive.Peer(id) != nil || !t.needsPeers() {
+		return false
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if dialed, ok := t.dialed[id]; ok && now.Sub(dialed) < topicRedialInterval {
+		return false
+	}
+	for id, dialed := range t.dialed {
+		if now.Sub(dialed) >= topicRedialInterval {
+			delete(t.dialed, id)
+		}
+	}
+	t.dialed[id] = now
+
+	log.Trace(fmt.Sprintf("%08x attempt to connect to %s found in topic search", t.hive.BaseAddr()[:4], id.TerminalString()))
+	metrics.GetOrRegisterCounter("network/hive/topic/dialed", nil).Inc(1)
+	t.hive.addPeer(node)
+	return true
+}
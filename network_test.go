@@ -47,8 +47,6 @@ var (
 )
 
 func init() {
-	rand.Seed(time.Now().UnixNano())
-
 	testutil.Init()
 }
 
@@ -318,8 +316,9 @@ func testSwarmNetwork(t *testing.T, o *testSwarmNetworkOptions, steps ...testSwa
 		}
 
 		result := sim.Run(ctx, func(ctx context.Context, sim *simulation.Simulation) error {
+			rnd := sim.Rand()
 			nodeIDs := sim.UpNodeIDs()
-			rand.Shuffle(len(nodeIDs), func(i, j int) {
+			rnd.Shuffle(len(nodeIDs), func(i, j int) {
 				nodeIDs[i], nodeIDs[j] = nodeIDs[j], nodeIDs[i]
 			})
 
@@ -338,7 +337,7 @@ func testSwarmNetwork(t *testing.T, o *testSwarmNetworkOptions, steps ...testSwa
 			}
 
 			for _, id := range nodeIDs {
-				key, data, err := uploadFile(sim.Service("swarm", id).(*Swarm))
+				key, data, err := uploadFile(sim.Service("swarm", id).(*Swarm), rnd)
 				if err != nil {
 					return err
 				}
@@ -385,9 +384,9 @@ func testSwarmNetwork(t *testing.T, o *testSwarmNetworkOptions, steps ...testSwa
 
 // uploadFile, uploads a short file to the swarm instance
 // using the api.Put method.
-func uploadFile(swarm *Swarm) (storage.Address, string, error) {
+func uploadFile(swarm *Swarm, rnd *rand.Rand) (storage.Address, string, error) {
 	b := make([]byte, 8)
-	_, err := rand.Read(b)
+	_, err := rnd.Read(b)
 	if err != nil {
 		return nil, "", err
 	}
@@ -412,7 +411,7 @@ func retrieveF(
 	sim *simulation.Simulation,
 	files []file,
 ) (missing uint64) {
-	rand.Shuffle(len(files), func(i, j int) {
+	sim.Rand().Shuffle(len(files), func(i, j int) {
 		files[i], files[j] = files[j], files[i]
 	})
 
@@ -17,9 +17,12 @@
 package protocols
 
 import (
+	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/state"
 )
 
 // define some metrics
@@ -42,6 +45,10 @@ var (
 	mPeerDrops = metrics.NewRegisteredCounterForced("account/peerdrops", metrics.AccountingRegistry)
 	// how many times local node overdrafted and dropped
 	mSelfDrops = metrics.NewRegisteredCounterForced("account/selfdrops", metrics.AccountingRegistry)
+	// how many priced requests received from remote peers were dropped without being served
+	mRequestDrops = metrics.NewRegisteredCounterForced("account/requestdrops", metrics.AccountingRegistry)
+	// total amount of priced messages exchanged free of charge within the free quota of peers
+	mMsgFree = metrics.NewRegisteredCounterForced("account/msg/free", metrics.AccountingRegistry)
 )
 
 // PricedMessage defines how a message type identifies itself as to be accounted
@@ -102,7 +109,9 @@ type Balance interface {
 // Accounting implements the Hook interface
 // It interfaces to the balances through the Balance interface
 type Accounting struct {
-	Balance // interface to accounting logic
+	Balance                   // interface to accounting logic
+	freeQuotas   *freeQuotas  // messages not accounted, nil if there is no free quota
+	freeQuotasMu sync.RWMutex // guards freeQuotas, which can be changed while messages are exchanged
 }
 
 // NewAccounting creates a new instance of Accounting
@@ -113,6 +122,31 @@ func NewAccounting(balance Balance) *Accounting {
 	return ah
 }
 
+// SetFreeQuota sets the amount of priced messages exchanged with every peer that are
+// not accounted, the usage of the quota is persisted in the store if it is not nil
+// It can be changed while messages are exchanged, the usage of the peers in their
+// current window and the store are kept unless the quota is disabled
+func (ah *Accounting) SetFreeQuota(quota FreeQuota, store state.Store) {
+	ah.freeQuotasMu.Lock()
+	defer ah.freeQuotasMu.Unlock()
+	if !quota.enabled() {
+		ah.freeQuotas = nil
+		return
+	}
+	if ah.freeQuotas != nil {
+		ah.freeQuotas.setQuota(quota)
+		return
+	}
+	ah.freeQuotas = newFreeQuotas(quota, store)
+}
+
+// getFreeQuotas returns the free quotas of the peers, nil if there is no free quota
+func (ah *Accounting) getFreeQuotas() *freeQuotas {
+	ah.freeQuotasMu.RLock()
+	defer ah.freeQuotasMu.RUnlock()
+	return ah.freeQuotas
+}
+
 // SetupAccountingMetrics uses a separate registry for p2p accounting metrics;
 // this registry should be independent of any other metrics as it persists at different endpoints.
 // It also starts the persisting go-routine which
@@ -124,6 +158,11 @@ func SetupAccountingMetrics(reportInterval time.Duration, path string) *Accounti
 
 // Apply takes a peer, the signed cost for the local node and the msg size and credits/debits local node using balance interface
 func (ah *Accounting) Apply(peer *Peer, costToLocalNode int64, size uint32) error {
+	// messages within the free quota of the peer are not accounted
+	if fq := ah.getFreeQuotas(); costToLocalNode != 0 && fq != nil && fq.release(peer.ID(), costToLocalNode) {
+		mMsgFree.Inc(1)
+		return nil
+	}
 	// do the accounting
 	err := ah.Add(costToLocalNode, peer)
 	// record metrics: just increase counters for user-facing metrics
@@ -144,6 +183,10 @@ func (ah *Accounting) Validate(peer *Peer, size uint32, msg interface{}, payer P
 	}
 	// evaluate the price for receiving messages
 	costToLocalNode := pricedMessage.Price().For(payer, size)
+	// messages within the free quota of the peer need not be checked
+	if fq := ah.getFreeQuotas(); costToLocalNode != 0 && fq != nil && fq.reserve(peer.ID(), costToLocalNode, size) {
+		return costToLocalNode, nil
+	}
 	// check that the operation would perform correctly
 	err := ah.Check(costToLocalNode, peer)
 	if err != nil {
@@ -153,6 +196,13 @@ func (ah *Accounting) Validate(peer *Peer, size uint32, msg interface{}, payer P
 	return costToLocalNode, nil
 }
 
+// Drop records that a priced request received from the peer was dropped without being served,
+// for instance because the peer exceeded its cap of concurrent requests
+func (ah *Accounting) Drop(peer *Peer) {
+	log.Debug("accounting: dropped request", "peer", peer.ID())
+	mRequestDrops.Inc(1)
+}
+
 // record some metrics
 // this is not an error handling. `err` is returned by both `Send` and `Receive`
 // `err` will only be non-nil if a limit has been violated (overdraft), in which case the peer has been dropped.
@@ -16,6 +16,27 @@
 
 package protocols
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrHandshakeTimeout is returned by Handshake if the handshake did not
+// complete within the HandshakeTimeout of the Spec
+var ErrHandshakeTimeout = errors.New("handshake timeout")
+
+// HandshakeSizeError is returned by Handshake if the handshake message of
+// the remote peer is longer than the MaxHandshakeSize of the Spec
+type HandshakeSizeError struct {
+	Size uint32 // length of the payload of the received handshake message
+	Max  uint32 // maximum accepted length
+}
+
+// Error implements function of the standard go error interface
+func (e *HandshakeSizeError) Error() string {
+	return fmt.Sprintf("handshake message too long: %v > %v", e.Size, e.Max)
+}
+
 // HandlerError wraps standard error
 // This error is handled specially by protocol.Run
 // It causes the protocol to return with ErrHandler(err)
@@ -0,0 +1,267 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/state"
+)
+
+const freeQuotaPrefix = "freequota_"
+
+var errNoFreeQuota = errors.New("free quota not enabled")
+
+// FreeQuota is the amount of priced messages exchanged with every peer in each
+// window that is not accounted, so that peers without a payment channel can
+// still exchange small amounts of data before incentives are in place
+// A zero limit is not enforced, but at least one of them needs to be set
+type FreeQuota struct {
+	Messages int64         // priced messages per peer per window
+	Bytes    int64         // bytes of priced messages per peer per window
+	Window   time.Duration // length of the window
+}
+
+// enabled reports whether the quota waives any messages
+func (q FreeQuota) enabled() bool {
+	return q.Window > 0 && (q.Messages > 0 || q.Bytes > 0)
+}
+
+// QuotaUse is the amount of priced messages exchanged free of charge
+type QuotaUse struct {
+	Messages int64
+	Bytes    int64
+}
+
+// QuotaUsage is the use of the free quota with a peer in the current window
+// The quota applies to both directions separately, so that the peers waive
+// the same messages on both sides of the exchange
+type QuotaUsage struct {
+	Peer   enode.ID
+	Start  time.Time // start of the current window
+	Credit QuotaUse  // messages the local node would have been credited for
+	Debit  QuotaUse  // messages the local node would have been debited for
+}
+
+// peerQuota is the quota usage of a peer with the number of free messages
+// validated but not yet applied, which is not persisted
+type peerQuota struct {
+	usage         QuotaUsage
+	pendingCredit int
+	pendingDebit  int
+}
+
+// freeQuotas tracks the use of the free quota of every peer
+// The usage is persisted in the state store, so that restarting the node
+// does not renew the quota of the peers
+type freeQuotas struct {
+	quota FreeQuota
+	store state.Store // may be nil
+	mtx   sync.Mutex
+	peers map[enode.ID]*peerQuota
+	now   func() time.Time // replaced in tests
+}
+
+func newFreeQuotas(quota FreeQuota, store state.Store) *freeQuotas {
+	return &freeQuotas{
+		quota: quota,
+		store: store,
+		peers: make(map[enode.ID]*peerQuota),
+		now:   time.Now,
+	}
+}
+
+// setQuota changes the quota of every peer, keeping their usage in the current window
+func (f *freeQuotas) setQuota(quota FreeQuota) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.quota = quota
+}
+
+// getQuota returns the quota of every peer
+func (f *freeQuotas) getQuota() FreeQuota {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.quota
+}
+
+// load returns the quota state of the peer with its window renewed if it expired
+// the caller is expected to hold the lock
+func (f *freeQuotas) load(id enode.ID) *peerQuota {
+	q, ok := f.peers[id]
+	if !ok {
+		q = &peerQuota{usage: QuotaUsage{Peer: id}}
+		if f.store != nil {
+			if err := f.store.Get(freeQuotaPrefix+id.String(), &q.usage); err != nil && err != state.ErrNotFound {
+				log.Warn("free quota: could not load usage", "peer", id, "err", err)
+			}
+		}
+		f.peers[id] = q
+	}
+	if now := f.now(); now.Sub(q.usage.Start) >= f.quota.Window {
+		q.usage.Start = now
+		q.usage.Credit = QuotaUse{}
+		q.usage.Debit = QuotaUse{}
+	}
+	return q
+}
+
+// reserve takes a message of the given size from the free quota of the peer
+// in the direction of the cost, and reports whether the quota allowed it
+// A reserved message is expected to be applied with release
+func (f *freeQuotas) reserve(id enode.ID, costToLocalNode int64, size uint32) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	q := f.load(id)
+	use := &q.usage.Debit
+	if costToLocalNode > 0 {
+		use = &q.usage.Credit
+	}
+	if f.quota.Messages > 0 && use.Messages+1 > f.quota.Messages {
+		return false
+	}
+	if f.quota.Bytes > 0 && use.Bytes+int64(size) > f.quota.Bytes {
+		return false
+	}
+	use.Messages++
+	use.Bytes += int64(size)
+	if costToLocalNode > 0 {
+		q.pendingCredit++
+	} else {
+		q.pendingDebit++
+	}
+
+	if f.store != nil {
+		// the usage is of no interest once the window is over
+		ttl := q.usage.Start.Add(f.quota.Window).Sub(f.now())
+		if err := f.store.PutWithTTL(freeQuotaPrefix+id.String(), q.usage, ttl); err != nil {
+			log.Warn("free quota: could not store usage", "peer", id, "err", err)
+		}
+	}
+	return true
+}
+
+// release reports whether a message with the given cost was reserved for the peer,
+// in which case it is not accounted
+func (f *freeQuotas) release(id enode.ID, costToLocalNode int64) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	q, ok := f.peers[id]
+	if !ok {
+		return false
+	}
+	pending := &q.pendingDebit
+	if costToLocalNode > 0 {
+		pending = &q.pendingCredit
+	}
+	if *pending == 0 {
+		return false
+	}
+	*pending--
+	return true
+}
+
+// usage returns the use of the free quota of the peer in the current window
+func (f *freeQuotas) usage(id enode.ID) QuotaUsage {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.load(id).usage
+}
+
+// usages returns the use of the free quota of all peers that used it in their current window
+func (f *freeQuotas) usages() ([]QuotaUsage, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var usages []QuotaUsage
+	seen := make(map[enode.ID]bool)
+	add := func(u QuotaUsage) {
+		if seen[u.Peer] || f.now().Sub(u.Start) >= f.quota.Window {
+			return
+		}
+		seen[u.Peer] = true
+		usages = append(usages, u)
+	}
+	for _, q := range f.peers {
+		add(q.usage)
+	}
+	if f.store == nil {
+		return usages, nil
+	}
+	err := f.store.Iterate(freeQuotaPrefix, func(key, value []byte) (bool, error) {
+		var u QuotaUsage
+		if err := json.Unmarshal(value, &u); err != nil {
+			return true, err
+		}
+		add(u)
+		return false, nil
+	})
+	return usages, err
+}
+
+// FreeQuotaApi provides an API to inspect the use of the free quota of the peers
+type FreeQuotaApi struct {
+	accounting *Accounting
+}
+
+// NewFreeQuotaApi creates a new FreeQuotaApi for the quota of the accounting hook
+func NewFreeQuotaApi(accounting *Accounting) *FreeQuotaApi {
+	return &FreeQuotaApi{accounting}
+}
+
+// FreeQuota returns the free quota granted to every peer
+func (a *FreeQuotaApi) FreeQuota() (FreeQuota, error) {
+	if a.accounting == nil {
+		return FreeQuota{}, errNoFreeQuota
+	}
+	fq := a.accounting.getFreeQuotas()
+	if fq == nil {
+		return FreeQuota{}, errNoFreeQuota
+	}
+	return fq.getQuota(), nil
+}
+
+// FreeQuotaUsage returns the use of the free quota of a peer in its current window
+func (a *FreeQuotaApi) FreeQuotaUsage(peer enode.ID) (QuotaUsage, error) {
+	if a.accounting == nil {
+		return QuotaUsage{}, errNoFreeQuota
+	}
+	fq := a.accounting.getFreeQuotas()
+	if fq == nil {
+		return QuotaUsage{}, errNoFreeQuota
+	}
+	return fq.usage(peer), nil
+}
+
+// FreeQuotaUsages returns the use of the free quota of all peers that used it in their current window
+func (a *FreeQuotaApi) FreeQuotaUsages() ([]QuotaUsage, error) {
+	if a.accounting == nil {
+		return nil, errNoFreeQuota
+	}
+	fq := a.accounting.getFreeQuotas()
+	if fq == nil {
+		return nil, errNoFreeQuota
+	}
+	return fq.usages()
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethersphere/swarm/state"
+)
+
+var errNoPaymentChannel = errors.New("no payment channel")
+
+// noChannelBalance is the balance of peers without a payment channel, which cannot be accounted
+type noChannelBalance struct {
+	adds int
+}
+
+func (b *noChannelBalance) Check(amount int64, peer *Peer) error {
+	return errNoPaymentChannel
+}
+
+func (b *noChannelBalance) Add(amount int64, peer *Peer) error {
+	b.adds++
+	return errNoPaymentChannel
+}
+
+// exchange validates and applies a message the way a peer sending or receiving it does
+func exchange(acc *Accounting, peer *Peer, msg interface{}, size uint32, payer Payer) error {
+	cost, err := acc.Validate(peer, size, msg, payer)
+	if err != nil {
+		return err
+	}
+	return acc.Apply(peer, cost, size)
+}
+
+// TestFreeQuota tests that priced messages within the free quota of a peer
+// are not accounted, and that the usage of the quota persists until the
+// window is over
+func TestFreeQuota(t *testing.T) {
+	balance := &noChannelBalance{}
+	store := state.NewInmemoryStore()
+	defer store.Close()
+	quota := FreeQuota{Messages: 2, Bytes: 100, Window: time.Hour}
+
+	now := time.Now()
+	newAccounting := func() *Accounting {
+		acc := NewAccounting(balance)
+		acc.SetFreeQuota(quota, store)
+		acc.freeQuotas.now = func() time.Time { return now }
+		return acc
+	}
+	acc := newAccounting()
+	id := adapters.RandomNodeConfig().ID
+	peer := NewPeer(p2p.NewPeer(id, "testPeer", nil), &dummyRW{}, createTestSpec())
+
+	// the quota applies to both directions separately
+	for _, payer := range []Payer{Sender, Receiver} {
+		for i := 0; i < 2; i++ {
+			if err := exchange(acc, peer, &perUnitMsgSenderPays{}, 10, payer); err != nil {
+				t.Fatalf("message %d within the quota: %v", i, err)
+			}
+		}
+		if err := exchange(acc, peer, &perUnitMsgSenderPays{}, 10, payer); err != errNoPaymentChannel {
+			t.Fatalf("expected message over the quota to be accounted, got %v", err)
+		}
+	}
+	if balance.adds != 0 {
+		t.Fatalf("expected no messages to be accounted, got %d", balance.adds)
+	}
+	// unpriced messages are accounted as before
+	if err := exchange(acc, peer, &nilPriceMsg{}, 10, Sender); err != errNoPaymentChannel {
+		t.Fatalf("expected unpriced message to be accounted, got %v", err)
+	}
+
+	usage, err := NewFreeQuotaApi(acc).FreeQuotaUsage(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := QuotaUse{Messages: 2, Bytes: 20}
+	if usage.Credit != want || usage.Debit != want {
+		t.Fatalf("expected usage %+v in both directions, got credit %+v and debit %+v", want, usage.Credit, usage.Debit)
+	}
+
+	// the usage is loaded from the store after a restart
+	acc = newAccounting()
+	if err := exchange(acc, peer, &perUnitMsgSenderPays{}, 10, Sender); err != errNoPaymentChannel {
+		t.Fatalf("expected quota to be used up after restart, got %v", err)
+	}
+	usages, err := NewFreeQuotaApi(acc).FreeQuotaUsages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 || usages[0].Peer != id {
+		t.Fatalf("expected usage of peer %v, got %+v", id, usages)
+	}
+
+	// the quota is renewed in the next window
+	now = now.Add(time.Hour)
+	if err := exchange(acc, peer, &perUnitMsgSenderPays{}, 10, Sender); err != nil {
+		t.Fatalf("expected quota to be renewed, got %v", err)
+	}
+	// the byte limit applies as well
+	if err := exchange(acc, peer, &perUnitMsgSenderPays{}, 91, Sender); err != errNoPaymentChannel {
+		t.Fatalf("expected message over the byte quota to be accounted, got %v", err)
+	}
+
+	// without a quota, the RPC API reports it is not enabled
+	if _, err := NewFreeQuotaApi(NewAccounting(balance)).FreeQuota(); err != errNoFreeQuota {
+		t.Fatalf("expected error %v, got %v", errNoFreeQuota, err)
+	}
+}
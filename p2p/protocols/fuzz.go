@@ -0,0 +1,79 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// FuzzMsg decodes the input of a fuzzer as a message of the protocol received
+// from a peer. The first byte of data selects the message code modulo the number
+// of messages of the protocol, the rest is the RLP encoded message.
+// It is shared by the go-fuzz entry points of the protocols, which are built with
+// the gofuzz build tag, e.g. with
+//   go-fuzz-build -func FuzzBzzHandshake github.com/ethersphere/swarm/network
+// or with the -libfuzzer flag for libFuzzer.
+func (s *Spec) FuzzMsg(data []byte) (interface{}, error) {
+	if len(data) == 0 || s.Length() == 0 {
+		return nil, errors.New("empty input")
+	}
+	if uint32(len(data)-1) > s.MaxMsgSize {
+		return nil, fmt.Errorf("message too long: %v > %v", len(data)-1, s.MaxMsgSize)
+	}
+	val, _ := s.NewMsg(uint64(data[0]) % s.Length())
+	if err := rlp.DecodeBytes(data[1:], val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// FuzzSeed returns the input of a fuzzer that FuzzMsg decodes to msg, so that
+// the corpus of the fuzzers can be seeded with the messages of the protocol tests
+func (s *Spec) FuzzSeed(msg interface{}) ([]byte, error) {
+	code, ok := s.GetCode(msg)
+	if !ok {
+		return nil, fmt.Errorf("invalid message type %T", msg)
+	}
+	data, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(code)}, data...), nil
+}
+
+// WriteFuzzCorpus writes the seeds to the corpus directory of a fuzzer, in files
+// named by the SHA1 hash of their content like the ones written by go-fuzz
+func WriteFuzzCorpus(dir string, seeds ...[]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, seed := range seeds {
+		sum := sha1.Sum(seed)
+		if err := ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:])), seed, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestFuzzMsg tests that the fuzzer inputs created from messages decode back
+// to the same messages, and that invalid inputs are rejected
+func TestFuzzMsg(t *testing.T) {
+	spec := &Spec{
+		Name:       "test",
+		Version:    42,
+		MaxMsgSize: 10 * 1024,
+		Messages: []interface{}{
+			protoHandshake{},
+			hs0{},
+			kill{},
+			drop{},
+		},
+	}
+
+	var seeds [][]byte
+	for _, msg := range []interface{}{
+		&protoHandshake{Version: 42, NetworkID: "420"},
+		&hs0{C: 1},
+		&kill{},
+		&drop{},
+	} {
+		seed, err := spec.FuzzSeed(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := spec.FuzzMsg(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Fatalf("got message %v, want %v", got, msg)
+		}
+		seeds = append(seeds, seed)
+	}
+
+	// the message code wraps around the number of messages
+	seed := append([]byte{5}, seeds[1][1:]...)
+	if got, err := spec.FuzzMsg(seed); err != nil || !reflect.DeepEqual(got, &hs0{C: 1}) {
+		t.Fatalf("got message %v, error %v, want %v", got, err, &hs0{C: 1})
+	}
+
+	for _, data := range [][]byte{nil, {1}, {1, 0xff}, append([]byte{0}, make([]byte, spec.MaxMsgSize+1)...)} {
+		if _, err := spec.FuzzMsg(data); err == nil {
+			t.Fatalf("expected error decoding %x", data)
+		}
+	}
+	if _, err := spec.FuzzSeed(&dummyMsg{}); err == nil {
+		t.Fatal("expected error creating a seed of a message not in the protocol")
+	}
+
+	dir, err := ioutil.TempDir("", "fuzz-corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	corpus := filepath.Join(dir, "corpus")
+	if err := WriteFuzzCorpus(corpus, seeds...); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(seeds) {
+		t.Fatalf("got %v files in the corpus, want %v", len(files), len(seeds))
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Lane is the priority with which messages are written to a peer
+// All subprotocols run on the same connection with the remote node, so a
+// message waiting to be written is queued behind the messages of every
+// subprotocol being written before it. Messages are written one at a time
+// and the messages waiting in the lane with the highest priority are written
+// first, so that small control and interactive messages are not delayed
+// behind large bulk messages, like syncing batches
+type Lane int
+
+const (
+	LaneControl     Lane = iota // handshakes and messages maintaining the connectivity, like hive
+	LaneInteractive             // messages a user may wait for, like retrieve requests
+	LaneBulk                    // large messages that are not time sensitive, like syncing
+	numLanes
+)
+
+// String returns the name of the lane
+func (l Lane) String() string {
+	switch l {
+	case LaneControl:
+		return "control"
+	case LaneInteractive:
+		return "interactive"
+	case LaneBulk:
+		return "bulk"
+	}
+	return fmt.Sprintf("lane(%d)", int(l))
+}
+
+// writeLanes orders the messages written to remote nodes by their lanes
+var writeLanes = newLaneScheduler()
+
+// laneScheduler orders the writes to the connections with remote nodes
+// A connection is tracked by its p2p.Peer, which all subprotocols running on it
+// share, only while there are messages being written to it
+type laneScheduler struct {
+	mtx   sync.Mutex
+	conns map[*p2p.Peer]*connLanes
+}
+
+// connLanes are the writes waiting for a connection, one queue per lane
+type connLanes struct {
+	busy    bool // a message is being written
+	waiting [numLanes][]chan struct{}
+}
+
+func newLaneScheduler() *laneScheduler {
+	return &laneScheduler{
+		conns: make(map[*p2p.Peer]*connLanes),
+	}
+}
+
+// acquire blocks until the message can be written to the connection,
+// which needs to be released after writing it, or until the context is done
+func (s *laneScheduler) acquire(ctx context.Context, peer *p2p.Peer, lane Lane) error {
+	if lane < 0 || lane >= numLanes {
+		lane = LaneInteractive
+	}
+	s.mtx.Lock()
+	c, ok := s.conns[peer]
+	if !ok {
+		c = &connLanes{}
+		s.conns[peer] = c
+	}
+	if !c.busy {
+		c.busy = true
+		s.mtx.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	c.waiting[lane] = append(c.waiting[lane], ready)
+	s.mtx.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, w := range c.waiting[lane] {
+		if w == ready {
+			c.waiting[lane] = append(c.waiting[lane][:i], c.waiting[lane][i+1:]...)
+			return ctx.Err()
+		}
+	}
+	// the connection was handed over while the context was done
+	s.handOver(peer, c)
+	return ctx.Err()
+}
+
+// release hands the connection over to the next message waiting
+func (s *laneScheduler) release(peer *p2p.Peer) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if c, ok := s.conns[peer]; ok {
+		s.handOver(peer, c)
+	}
+}
+
+// handOver hands the connection over to the first message waiting in the
+// lane with the highest priority, or stops tracking it if none is waiting
+// the caller is expected to hold the lock
+func (s *laneScheduler) handOver(peer *p2p.Peer, c *connLanes) {
+	for lane := range c.waiting {
+		if len(c.waiting[lane]) > 0 {
+			ready := c.waiting[lane][0]
+			c.waiting[lane] = c.waiting[lane][1:]
+			close(ready)
+			return
+		}
+	}
+	c.busy = false
+	delete(s.conns, peer)
+}
+
+// write writes the message to the peer in the given lane
+// Peers without a remote node are written to directly
+func (p *Peer) write(ctx context.Context, lane Lane, code uint64, msg interface{}) error {
+	if p.Peer == nil {
+		return p2p.Send(p.rw, code, msg)
+	}
+	start := time.Now()
+	if err := writeLanes.acquire(ctx, p.Peer, lane); err != nil {
+		metrics.GetOrRegisterCounter(fmt.Sprintf("peer/lane/%s/cancel", lane), nil).Inc(1)
+		return err
+	}
+	defer writeLanes.release(p.Peer)
+	metrics.GetOrRegisterResettingTimer(fmt.Sprintf("peer/lane/%s/wait", lane), nil).UpdateSince(start)
+	return p2p.Send(p.rw, code, msg)
+}
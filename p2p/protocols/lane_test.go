@@ -0,0 +1,138 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// TestLaneScheduler tests that the writes waiting for a connection are
+// handed the connection over in the order of the priority of their lanes
+func TestLaneScheduler(t *testing.T) {
+	s := newLaneScheduler()
+	id := p2p.NewPeer(enode.ID{1}, "peer", nil)
+	ctx := context.Background()
+
+	if err := s.acquire(ctx, id, LaneBulk); err != nil {
+		t.Fatal(err)
+	}
+
+	// queue a write in every lane, lowest priority first, and one that is cancelled
+	written := make(chan Lane, numLanes)
+	queue := func(ctx context.Context, lane Lane) {
+		s.mtx.Lock()
+		n := len(s.conns[id].waiting[lane])
+		s.mtx.Unlock()
+		go func() {
+			if err := s.acquire(ctx, id, lane); err != nil {
+				return
+			}
+			written <- lane
+			s.release(id)
+		}()
+		waitQueued(t, s, id, lane, n+1)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	queue(cctx, LaneControl)
+	queue(ctx, LaneBulk)
+	queue(ctx, LaneInteractive)
+	queue(ctx, LaneControl)
+	cancel()
+	waitQueued(t, s, id, LaneControl, 1)
+
+	s.release(id)
+	for _, want := range []Lane{LaneControl, LaneInteractive, LaneBulk} {
+		select {
+		case lane := <-written:
+			if lane != want {
+				t.Fatalf("got write in lane %v, want %v", lane, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for write in lane %v", want)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mtx.Lock()
+		n := len(s.conns)
+		s.mtx.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v connections tracked, want 0", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestSendErrors tests that Send returns the errors of waiting for a lane
+// and of writing the message
+func TestSendErrors(t *testing.T) {
+	spec := &Spec{
+		Name:       "test",
+		Version:    42,
+		MaxMsgSize: 10 * 1024,
+		Messages: []interface{}{
+			dummyMsg{},
+		},
+	}
+	rw1, rw2 := p2p.MsgPipe()
+	defer rw1.Close()
+	id := p2p.NewPeer(enode.ID{2}, "peer", nil)
+	p := NewPeer(id, rw1, spec)
+
+	// the connection is held by another write while the context is cancelled
+	if err := writeLanes.acquire(context.Background(), id, LaneBulk); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Send(ctx, &dummyMsg{}); err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	writeLanes.release(id)
+
+	rw2.Close()
+	if err := p.Send(context.Background(), &dummyMsg{}); err != p2p.ErrPipeClosed {
+		t.Fatalf("got error %v, want %v", err, p2p.ErrPipeClosed)
+	}
+}
+
+// waitQueued waits until the given number of writes are queued in the lane of the connection
+func waitQueued(t *testing.T, s *laneScheduler, id *p2p.Peer, lane Lane, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mtx.Lock()
+		got := len(s.conns[id].waiting[lane])
+		s.mtx.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v writes queued in lane %v, want %v", got, lane, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
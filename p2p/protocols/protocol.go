@@ -45,6 +45,10 @@ import (
 	"github.com/ethersphere/swarm/tracing"
 )
 
+// DefaultHandshakeTimeout is the time allowed for a handshake to complete
+// if the Spec of the protocol does not set HandshakeTimeout
+const DefaultHandshakeTimeout = 10 * time.Second
+
 // MsgPauser can be used to pause run execution
 // IMPORTANT: should be used only for tests
 type MsgPauser interface {
@@ -72,9 +76,22 @@ type Spec struct {
 	// Version is the version number of the protocol
 	Version uint
 
+	// MinVersion is the lowest version of the protocol that is still supported,
+	// so that peers running older versions can be peered with during upgrades
+	// Only Version is supported if it is zero
+	MinVersion uint
+
 	// MaxMsgSize is the maximum accepted length of the message payload
 	MaxMsgSize uint32
 
+	// HandshakeTimeout is the time allowed for the handshake to complete,
+	// DefaultHandshakeTimeout is used if it is zero
+	HandshakeTimeout time.Duration
+
+	// MaxHandshakeSize is the maximum accepted length of the payload of the
+	// handshake message, MaxMsgSize is used if it is zero
+	MaxHandshakeSize uint32
+
 	// Messages is a list of message data types which this protocol uses, with
 	// each message type being sent with its array index as the code (so
 	// [&foo{}, &bar{}, &baz{}] would send foo, bar and baz with codes
@@ -82,12 +99,18 @@ type Spec struct {
 	// each message must have a single unique data type
 	Messages []interface{}
 
+	// Lanes assigns message data types to the priority lanes they are written to
+	// the peer in, messages not listed are written in LaneInteractive and
+	// handshakes always in LaneControl, see Lane
+	Lanes map[Lane][]interface{}
+
 	//hook for accounting (could be extended to multiple hooks in the future)
 	Hook Hook
 
 	initOnce sync.Once
 	codes    map[reflect.Type]uint64
 	types    map[uint64]reflect.Type
+	lanes    map[uint64]Lane
 
 	// if the protocol does not allow extending the p2p msg to propagate context
 	// even if context not disabled, context will propagate only tracing is enabled
@@ -107,9 +130,84 @@ func (s *Spec) init() {
 			s.codes[typ] = code
 			s.types[code] = typ
 		}
+		s.lanes = make(map[uint64]Lane)
+		for lane, msgs := range s.Lanes {
+			for _, msg := range msgs {
+				typ := reflect.TypeOf(msg)
+				if typ.Kind() == reflect.Ptr {
+					typ = typ.Elem()
+				}
+				if code, ok := s.codes[typ]; ok {
+					s.lanes[code] = lane
+				}
+			}
+		}
 	})
 }
 
+// lane returns the priority lane of the message with the given code
+func (s *Spec) lane(code uint64) Lane {
+	s.init()
+	if lane, ok := s.lanes[code]; ok {
+		return lane
+	}
+	return LaneInteractive
+}
+
+// handshakeTimeout returns the time allowed for the handshake to complete
+func (s *Spec) handshakeTimeout() time.Duration {
+	if s.HandshakeTimeout > 0 {
+		return s.HandshakeTimeout
+	}
+	return DefaultHandshakeTimeout
+}
+
+// maxHandshakeSize returns the maximum accepted length of the handshake message payload
+func (s *Spec) maxHandshakeSize() uint32 {
+	if s.MaxHandshakeSize > 0 {
+		return s.MaxHandshakeSize
+	}
+	return s.MaxMsgSize
+}
+
+// Versions returns the supported versions of the protocol, from Version down to MinVersion
+func (s *Spec) Versions() []uint {
+	min := s.minVersion()
+	versions := make([]uint, 0, s.Version-min+1)
+	for v := s.Version; ; v-- {
+		versions = append(versions, v)
+		if v == min {
+			return versions
+		}
+	}
+}
+
+// Negotiate returns the highest supported version of the protocol that is also in the
+// capabilities of the remote peer, which is the version devp2p runs with the peer,
+// and false if there is no such version
+func (s *Spec) Negotiate(caps []p2p.Cap) (uint, bool) {
+	var version uint
+	var ok bool
+	for _, c := range caps {
+		if c.Name != s.Name || c.Version > s.Version || c.Version < s.minVersion() {
+			continue
+		}
+		if !ok || c.Version > version {
+			version = c.Version
+			ok = true
+		}
+	}
+	return version, ok
+}
+
+// minVersion returns the lowest supported version of the protocol
+func (s *Spec) minVersion() uint {
+	if s.MinVersion == 0 || s.MinVersion > s.Version {
+		return s.Version
+	}
+	return s.MinVersion
+}
+
 // Length returns the number of message types in the protocol
 func (s *Spec) Length() uint64 {
 	return uint64(len(s.Messages))
@@ -143,6 +241,7 @@ type Peer struct {
 	*p2p.Peer                         // the p2p.Peer object representing the remote
 	rw              p2p.MsgReadWriter // p2p.MsgReadWriter to send messages to and read messages from
 	spec            *Spec
+	version         uint // version of the protocol negotiated with the remote
 	encode          func(context.Context, interface{}) (interface{}, int, error)
 	decode          func(p2p.Msg) (context.Context, []byte, error)
 	wg              sync.WaitGroup
@@ -162,13 +261,28 @@ func NewPeer(peer *p2p.Peer, rw p2p.MsgReadWriter, spec *Spec) *Peer {
 		encode = encodeWithoutContext
 		decode = decodeWithoutContext
 	}
-	return &Peer{
+	p := &Peer{
 		Peer:   peer,
 		rw:     rw,
 		spec:   spec,
 		encode: encode,
 		decode: decode,
 	}
+	if spec != nil {
+		p.version = spec.Version
+		if peer != nil {
+			if v, ok := spec.Negotiate(peer.Caps()); ok {
+				p.version = v
+			}
+		}
+	}
+	return p
+}
+
+// Version returns the version of the protocol run with the remote, the highest
+// version supported by both, or the version of the spec if the remote does not advertise it
+func (p *Peer) Version() uint {
+	return p.version
 }
 
 // Run starts the forever loop that handles incoming messages.
@@ -281,6 +395,12 @@ func (p *Peer) Stop(timeout time.Duration) error {
 // this low level call will be wrapped by libraries providing routed or broadcast sends
 // but often just used to forward and push messages to directly connected peers
 func (p *Peer) Send(ctx context.Context, msg interface{}) error {
+	code, _ := p.spec.GetCode(msg)
+	return p.send(ctx, msg, p.spec.lane(code))
+}
+
+// send sends the message to the peer in the given priority lane
+func (p *Peer) send(ctx context.Context, msg interface{}, lane Lane) error {
 	defer metrics.GetOrRegisterResettingTimer("peer/send_t", nil).UpdateSince(time.Now())
 	metrics.GetOrRegisterCounter("peer/send", nil).Inc(1)
 	metrics.GetOrRegisterCounter(strings.ReplaceAll(fmt.Sprintf("peer/send/%T", msg), ".", "/"), nil).Inc(1)
@@ -313,7 +433,7 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 			return err
 		}
 		// seems like accounting would succeed, thus send the message first...
-		err = p2p.Send(p.rw, code, wmsg)
+		err = p.write(ctx, lane, code, wmsg)
 		if err != nil {
 			return err
 		}
@@ -321,9 +441,10 @@ func (p *Peer) Send(ctx context.Context, msg interface{}) error {
 		if err := p.spec.Hook.Apply(p, costToLocalNode, uint32(size)); err != nil {
 			return err
 		}
-	} else {
-		err = p2p.Send(p.rw, code, wmsg)
+	} else if err := p.write(ctx, lane, code, wmsg); err != nil {
+		return err
 	}
+	p.tapMsg(code, msg, size, MsgOut)
 
 	return nil
 }
@@ -370,6 +491,7 @@ func (p *Peer) handleMsg(msg p2p.Msg, handle func(ctx context.Context, msg inter
 	if err := rlp.DecodeBytes(msgBytes, val); err != nil {
 		return Break(fmt.Errorf("invalid message (RLP error): <= %v: %w", msg, err))
 	}
+	p.tapMsg(msg.Code, val, len(msgBytes), MsgIn)
 
 	// if the accounting hook is set, do accounting logic
 	if p.spec.Hook != nil {
@@ -413,18 +535,35 @@ func (p *Peer) handleMsg(msg p2p.Msg, handle func(ctx context.Context, msg inter
 // * expects a remote handshake back of the same type
 // * the dialing peer needs to send the handshake first and then waits for remote
 // * the listening peer waits for the remote handshake and then sends it
-// returns the remote handshake and an error
+// * the handshake must complete within the HandshakeTimeout of the Spec and the
+//   remote handshake must not be longer than its MaxHandshakeSize
+// returns the remote handshake and an error, ErrHandshakeTimeout or
+// *HandshakeSizeError if the limits of the Spec are exceeded
 func (p *Peer) Handshake(ctx context.Context, hs interface{}, verify func(interface{}) error) (interface{}, error) {
 	if _, ok := p.spec.GetCode(hs); !ok {
 		return nil, fmt.Errorf("unknown handshake message type: %T", hs)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, p.spec.handshakeTimeout())
+	defer cancel()
+
 	var rhs interface{}
 	errc := make(chan error, 2)
 
-	send := func() { errc <- p.Send(ctx, hs) }
+	send := func() { errc <- p.send(ctx, hs, LaneControl) }
 	receive := func() {
-		errc <- p.receive(func(ctx context.Context, msg interface{}) error {
+		msg, err := p.readMsg()
+		if err != nil {
+			errc <- err
+			return
+		}
+		if max := p.spec.maxHandshakeSize(); msg.Size > max {
+			msg.Discard()
+			metrics.GetOrRegisterCounter("peer/handshake/size_exceeded", nil).Inc(1)
+			errc <- &HandshakeSizeError{Size: msg.Size, Max: max}
+			return
+		}
+		errc <- p.handleMsg(msg, func(ctx context.Context, msg interface{}) error {
 			rhs = msg
 			if verify != nil {
 				return verify(rhs)
@@ -449,6 +588,10 @@ func (p *Peer) Handshake(ctx context.Context, hs interface{}, verify func(interf
 		case err = <-errc:
 		case <-ctx.Done():
 			err = ctx.Err()
+			if err == context.DeadlineExceeded {
+				metrics.GetOrRegisterCounter("peer/handshake/timeout", nil).Inc(1)
+				err = ErrHandshakeTimeout
+			}
 		}
 		if err != nil {
 			return nil, err
@@ -550,6 +550,61 @@ func TestModuleHandshakeSuccess(t *testing.T) {
 	runModuleHandshake(t, 42)
 }
 
+// TestHandshakeLimits tests that Handshake fails with typed errors if the remote
+// peer does not respond within the HandshakeTimeout of the Spec or sends a
+// handshake message longer than its MaxHandshakeSize
+func TestHandshakeLimits(t *testing.T) {
+	spec := &Spec{
+		Name:             "test",
+		Version:          42,
+		MaxMsgSize:       10 * 1024,
+		HandshakeTimeout: 100 * time.Millisecond,
+		MaxHandshakeSize: 64,
+		Messages: []interface{}{
+			protoHandshake{},
+		},
+		DisableContext: true,
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		rw, remote := p2p.MsgPipe()
+		defer rw.Close()
+		defer remote.Close()
+		peer := NewPeer(p2p.NewPeer(adapters.RandomNodeConfig().ID, "testPeer", nil), rw, spec)
+
+		// the remote peer never reads nor sends the handshake
+		_, err := peer.Handshake(context.Background(), &protoHandshake{42, "420"}, nil)
+		if err != ErrHandshakeTimeout {
+			t.Fatalf("expected error %v, got %v", ErrHandshakeTimeout, err)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		rw, remote := p2p.MsgPipe()
+		defer rw.Close()
+		defer remote.Close()
+		peer := NewPeer(p2p.NewPeer(adapters.RandomNodeConfig().ID, "testPeer", nil), rw, spec)
+
+		go func() {
+			msg, err := remote.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+			p2p.Send(remote, 0, &protoHandshake{42, string(make([]byte, 100))})
+		}()
+
+		_, err := peer.Handshake(context.Background(), &protoHandshake{42, "420"}, nil)
+		var sizeErr *HandshakeSizeError
+		if !errors.As(err, &sizeErr) {
+			t.Fatalf("expected handshake size error, got %v", err)
+		}
+		if sizeErr.Max != spec.MaxHandshakeSize {
+			t.Fatalf("expected limit %v, got %v", spec.MaxHandshakeSize, sizeErr.Max)
+		}
+	})
+}
+
 // testing complex interactions over multiple peers, relaying, dropping
 func testMultiPeerSetup(a, b enode.ID) []p2ptest.Exchange {
 
@@ -722,3 +777,45 @@ func (d *dummyRW) ReadMsg() (p2p.Msg, error) {
 		ReceivedAt: time.Now(),
 	}, nil
 }
+
+// TestSpecVersions tests that the highest version of a protocol supported by both
+// peers is negotiated, and that versions out of the supported range are not
+func TestSpecVersions(t *testing.T) {
+	spec := &Spec{
+		Name:       "test",
+		Version:    5,
+		MinVersion: 3,
+	}
+	versions := spec.Versions()
+	if fmt.Sprint(versions) != "[5 4 3]" {
+		t.Fatalf("got versions %v, want [5 4 3]", versions)
+	}
+	if v := (&Spec{Version: 5}).Versions(); fmt.Sprint(v) != "[5]" {
+		t.Fatalf("got versions %v without min version, want [5]", v)
+	}
+
+	for _, tc := range []struct {
+		caps    []p2p.Cap
+		version uint
+		ok      bool
+	}{
+		{caps: []p2p.Cap{{Name: "test", Version: 5}}, version: 5, ok: true},
+		{caps: []p2p.Cap{{Name: "test", Version: 3}, {Name: "test", Version: 4}}, version: 4, ok: true},
+		{caps: []p2p.Cap{{Name: "test", Version: 4}, {Name: "test", Version: 6}}, version: 4, ok: true},
+		{caps: []p2p.Cap{{Name: "test", Version: 2}, {Name: "test", Version: 6}}, ok: false},
+		{caps: []p2p.Cap{{Name: "other", Version: 5}}, ok: false},
+	} {
+		version, ok := spec.Negotiate(tc.caps)
+		if ok != tc.ok || version != tc.version {
+			t.Errorf("caps %v: got version %v %v, want %v %v", tc.caps, version, ok, tc.version, tc.ok)
+		}
+		peer := NewPeer(p2p.NewPeer(enode.ID{}, "", tc.caps), nil, spec)
+		want := tc.version
+		if !tc.ok {
+			want = spec.Version
+		}
+		if peer.Version() != want {
+			t.Errorf("caps %v: got peer version %v, want %v", tc.caps, peer.Version(), want)
+		}
+	}
+}
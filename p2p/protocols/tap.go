@@ -0,0 +1,169 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Directions of the tapped messages
+const (
+	MsgIn  = "in"
+	MsgOut = "out"
+)
+
+// msgTapBuffer is the number of message events buffered for a subscriber,
+// the events are dropped while the buffer of a subscriber is full
+const msgTapBuffer = 1024
+
+// MsgEvent describes a message sent to or received from a peer
+type MsgEvent struct {
+	Time      time.Time `json:"time"`
+	Peer      enode.ID  `json:"peer"`
+	Protocol  string    `json:"protocol"`
+	Code      uint64    `json:"code"`
+	Type      string    `json:"type"`
+	Size      uint32    `json:"size"`
+	Direction string    `json:"direction"`
+	Price     *Price    `json:"price,omitempty"` // set for priced messages
+}
+
+// MsgTapParams selects the messages reported to a subscriber of the tap
+type MsgTapParams struct {
+	Protocol string    `json:"protocol"` // only messages of the protocol if set
+	Peer     *enode.ID `json:"peer"`     // only messages of the peer if set
+	Sample   uint64    `json:"sample"`   // only every n-th of the selected messages if more than 1
+}
+
+// msgTap delivers the message events of all peers to the subscribers
+type msgTap struct {
+	mu    sync.RWMutex
+	subs  map[*msgTapSub]struct{}
+	count int32 // number of subscribers, checked without locking on every message
+}
+
+type msgTapSub struct {
+	params MsgTapParams
+	seen   uint64 // number of selected messages, for sampling
+	c      chan MsgEvent
+}
+
+var tap = &msgTap{subs: make(map[*msgTapSub]struct{})}
+
+// SubscribeMsgs returns a channel of the events of the messages selected by params
+// exchanged by all peers of all protocols, and a function that ends the subscription.
+// The messages are not tapped while there are no subscriptions.
+func SubscribeMsgs(params MsgTapParams) (<-chan MsgEvent, func()) {
+	sub := &msgTapSub{
+		params: params,
+		c:      make(chan MsgEvent, msgTapBuffer),
+	}
+	tap.mu.Lock()
+	tap.subs[sub] = struct{}{}
+	atomic.AddInt32(&tap.count, 1)
+	tap.mu.Unlock()
+
+	var once sync.Once
+	return sub.c, func() {
+		once.Do(func() {
+			tap.mu.Lock()
+			delete(tap.subs, sub)
+			atomic.AddInt32(&tap.count, -1)
+			tap.mu.Unlock()
+		})
+	}
+}
+
+// tapMsg reports a message of the peer to the subscribers of the tap
+func (p *Peer) tapMsg(code uint64, msg interface{}, size int, direction string) {
+	if atomic.LoadInt32(&tap.count) == 0 {
+		return
+	}
+	e := MsgEvent{
+		Time:      time.Now(),
+		Protocol:  p.spec.Name,
+		Code:      code,
+		Type:      fmt.Sprintf("%T", msg),
+		Size:      uint32(size),
+		Direction: direction,
+	}
+	if p.Peer != nil {
+		e.Peer = p.ID()
+	}
+	if pm, ok := msg.(PricedMessage); ok {
+		e.Price = pm.Price()
+	}
+
+	tap.mu.RLock()
+	defer tap.mu.RUnlock()
+	for sub := range tap.subs {
+		if sub.params.Protocol != "" && sub.params.Protocol != e.Protocol {
+			continue
+		}
+		if sub.params.Peer != nil && *sub.params.Peer != e.Peer {
+			continue
+		}
+		if n := atomic.AddUint64(&sub.seen, 1); sub.params.Sample > 1 && (n-1)%sub.params.Sample != 0 {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+		}
+	}
+}
+
+// MsgTapAPI streams the messages exchanged with the peers to RPC subscribers
+type MsgTapAPI struct{}
+
+// PeerMessages subscribes to the events of the messages exchanged with the peers
+// selected by params, to debug the protocols of a live node
+func (api *MsgTapAPI) PeerMessages(ctx context.Context, params MsgTapParams) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	events, unsubscribe := SubscribeMsgs(params)
+
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case e := <-events:
+				if err := notifier.Notify(sub.ID, e); err != nil {
+					log.Debug("peer messages subscription notify", "err", err)
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
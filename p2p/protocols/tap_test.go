@@ -0,0 +1,88 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package protocols
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+)
+
+// TestMsgTap tests that the sent and received messages are reported
+// to the subscribers whose parameters select them
+func TestMsgTap(t *testing.T) {
+	id := adapters.RandomNodeConfig().ID
+	rw := &dummyRW{}
+	peer := NewPeer(p2p.NewPeer(id, "testPeer", nil), rw, createTestSpec())
+
+	all, unsubscribeAll := SubscribeMsgs(MsgTapParams{})
+	defer unsubscribeAll()
+	other := adapters.RandomNodeConfig().ID
+	none, unsubscribeNone := SubscribeMsgs(MsgTapParams{Peer: &other})
+	defer unsubscribeNone()
+	sampled, unsubscribeSampled := SubscribeMsgs(MsgTapParams{Protocol: "test", Sample: 2})
+	defer unsubscribeSampled()
+
+	msg := &perBytesMsgSenderPays{Content: "testBalance"}
+	for i := 0; i < 2; i++ {
+		if err := peer.Send(context.TODO(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rw.msg = &perBytesMsgReceiverPays{Content: "testBalance"}
+	if err := peer.receive(func(ctx context.Context, msg interface{}) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, direction := range []string{MsgOut, MsgOut, MsgIn} {
+		select {
+		case e := <-all:
+			if e.Direction != direction || e.Peer != id || e.Protocol != "test" {
+				t.Fatalf("message %d: unexpected event %+v", i, e)
+			}
+			if e.Price == nil || e.Size == 0 {
+				t.Fatalf("message %d: expected price and size, got %+v", i, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %d: timeout waiting for event", i)
+		}
+	}
+
+	if len(none) != 0 {
+		t.Fatalf("expected no events of other peer, got %d", len(none))
+	}
+	if len(sampled) != 2 {
+		t.Fatalf("expected every second event sampled, got %d events", len(sampled))
+	}
+	if e := <-sampled; e.Code != 1 || e.Direction != MsgOut {
+		t.Fatalf("unexpected first sampled event %+v", e)
+	}
+	if e := <-sampled; e.Code != 0 || e.Direction != MsgIn {
+		t.Fatalf("unexpected second sampled event %+v", e)
+	}
+
+	unsubscribeAll()
+	if err := peer.Send(context.TODO(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Fatalf("expected no events after unsubscribe, got %d", len(all))
+	}
+}
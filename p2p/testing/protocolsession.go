@@ -60,15 +60,23 @@ type Trigger struct {
 	Code    uint64        // code of message is given
 	Peer    enode.ID      // the peer to send the message to
 	Timeout time.Duration // timeout duration for the sending
+	Delay   time.Duration // time to wait before sending, after the previous trigger of the exchange
 }
 
 // Expect is part of an exchange, outgoing message from the pivot node
 // received by a peer
+//
+// Fields of the message listed in Ignore match any value, so that messages with
+// randomly generated fields, such as request IDs, can be expected without
+// making them deterministic in the protocol. The received message can be
+// decoded into Received to learn the values of the ignored fields.
 type Expect struct {
-	Msg     interface{}   // type of message to expect
-	Code    uint64        // code of message is now given
-	Peer    enode.ID      // the peer that expects the message
-	Timeout time.Duration // timeout duration for receiving
+	Msg      interface{}   // type of message to expect
+	Code     uint64        // code of message is now given
+	Peer     enode.ID      // the peer that expects the message
+	Timeout  time.Duration // timeout duration for receiving
+	Ignore   []string      // fields of the message that match any value, nested fields are separated by dots
+	Received interface{}   // pointer the matching message is decoded into if not nil
 }
 
 // Disconnect represents a disconnect event, used and checked by TestDisconnected
@@ -223,6 +231,13 @@ func (s *ProtocolSession) testExchange(e Exchange) error {
 
 	go func() {
 		for _, trig := range e.Triggers {
+			if trig.Delay > 0 {
+				select {
+				case <-time.After(trig.Delay):
+				case <-done:
+					return
+				}
+			}
 			err := s.trigger(trig)
 			if err != nil {
 				errc <- err
@@ -253,12 +268,17 @@ func (s *ProtocolSession) testExchange(e Exchange) error {
 // TestDisconnected tests the disconnections given as arguments
 // the disconnect structs describe what disconnect error is expected on which peer
 func (s *ProtocolSession) TestDisconnected(disconnects ...*Disconnect) error {
+	return s.testDisconnected(time.Second, disconnects...)
+}
+
+// testDisconnected waits at most timeout for the disconnections given as arguments
+func (s *ProtocolSession) testDisconnected(timeout time.Duration, disconnects ...*Disconnect) error {
 	expects := make(map[enode.ID]error)
 	for _, disconnect := range disconnects {
 		expects[disconnect.Peer] = disconnect.Error
 	}
 
-	timeout := time.After(time.Second)
+	alarm := time.After(timeout)
 	for len(expects) > 0 {
 		select {
 		case event := <-s.events:
@@ -274,7 +294,7 @@ func (s *ProtocolSession) TestDisconnected(disconnects ...*Disconnect) error {
 				return fmt.Errorf("unexpected error on peer %v. expected '%v', got '%v'", event.Peer, expectErr, event.Error)
 			}
 			delete(expects, event.Peer)
-		case <-timeout:
+		case <-alarm:
 			return fmt.Errorf("timed out waiting for peers to disconnect")
 		}
 	}
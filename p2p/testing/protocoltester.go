@@ -29,6 +29,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"sync"
 
@@ -220,10 +221,19 @@ func expectMsgs(rw p2p.MsgReadWriter, exps []Expect) error {
 		}
 		var found bool
 		for i, exp := range exps {
-			if exp.Code == msg.Code && bytes.Equal(actualContent, mustEncodeMsg(exp.Msg)) {
+			ok, err := matchMsg(exp, msg.Code, actualContent)
+			if err != nil {
+				return fmt.Errorf("message #%d: %v", i, err)
+			}
+			if ok {
 				if matched[i] {
 					return fmt.Errorf("message #%d received two times", i)
 				}
+				if exp.Received != nil {
+					if err := rlp.DecodeBytes(actualContent, exp.Received); err != nil {
+						return fmt.Errorf("message #%d: %v", i, err)
+					}
+				}
 				matched[i] = true
 				found = true
 				break
@@ -258,6 +268,70 @@ func expectMsgs(rw p2p.MsgReadWriter, exps []Expect) error {
 	return nil
 }
 
+// matchMsg reports whether a received message with code and rlp encoded content
+// matches the expected one, apart from the fields the expectation ignores
+// It returns an error if an ignored field does not exist in the expected message.
+func matchMsg(exp Expect, code uint64, content []byte) (bool, error) {
+	if exp.Code != code {
+		return false, nil
+	}
+	expected := mustEncodeMsg(exp.Msg)
+	if len(exp.Ignore) == 0 {
+		return bytes.Equal(content, expected), nil
+	}
+
+	// decode the received message and take the ignored fields from the
+	// expected one, so that only the other fields are compared
+	want := reflect.ValueOf(exp.Msg)
+	typ := want.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	got := reflect.New(typ)
+	if err := rlp.DecodeBytes(content, got.Interface()); err != nil {
+		return false, nil
+	}
+	for _, path := range exp.Ignore {
+		src, err := fieldByPath(want, path)
+		if err != nil {
+			return false, err
+		}
+		dst, err := fieldByPath(got, path)
+		if err != nil {
+			return false, err
+		}
+		if !dst.CanSet() {
+			return false, fmt.Errorf("ignored field %s: not exported", path)
+		}
+		dst.Set(src)
+	}
+	actual, err := rlp.EncodeToBytes(got.Interface())
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(actual, expected), nil
+}
+
+// fieldByPath returns the field of the struct v at the dot separated path
+func fieldByPath(v reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("ignored field %s: nil pointer", path)
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("ignored field %s: not a struct", path)
+		}
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return reflect.Value{}, fmt.Errorf("ignored field %s: no such field", path)
+		}
+	}
+	return v, nil
+}
+
 // mustEncodeMsg uses rlp to encode a message.
 // In case of error it panics.
 func mustEncodeMsg(msg interface{}) []byte {
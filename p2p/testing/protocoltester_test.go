@@ -0,0 +1,94 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package testing
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+type testBody struct {
+	Nonce uint32
+	Data  []byte
+}
+
+type testMsg struct {
+	ID   uint64
+	Body *testBody
+}
+
+// TestMatchMsg tests matching received messages against expectations with ignored fields
+func TestMatchMsg(t *testing.T) {
+	received, err := rlp.EncodeToBytes(&testMsg{ID: 42, Body: &testBody{Nonce: 7, Data: []byte("data")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		exp    Expect
+		want   bool
+		errors bool
+	}{
+		{
+			name: "exact",
+			exp:  Expect{Code: 1, Msg: &testMsg{ID: 42, Body: &testBody{Nonce: 7, Data: []byte("data")}}},
+			want: true,
+		},
+		{
+			name: "different field",
+			exp:  Expect{Code: 1, Msg: &testMsg{ID: 1, Body: &testBody{Nonce: 7, Data: []byte("data")}}},
+		},
+		{
+			name: "different code",
+			exp:  Expect{Code: 2, Msg: &testMsg{ID: 42, Body: &testBody{Nonce: 7, Data: []byte("data")}}, Ignore: []string{"ID"}},
+		},
+		{
+			name: "ignored field",
+			exp:  Expect{Code: 1, Msg: testMsg{Body: &testBody{Nonce: 7, Data: []byte("data")}}, Ignore: []string{"ID"}},
+			want: true,
+		},
+		{
+			name: "ignored nested fields",
+			exp:  Expect{Code: 1, Msg: &testMsg{ID: 1, Body: &testBody{Data: []byte("data")}}, Ignore: []string{"ID", "Body.Nonce"}},
+			want: true,
+		},
+		{
+			name: "other field differs",
+			exp:  Expect{Code: 1, Msg: &testMsg{Body: &testBody{Nonce: 7, Data: []byte("other")}}, Ignore: []string{"ID"}},
+		},
+		{
+			name:   "unknown field",
+			exp:    Expect{Code: 1, Msg: &testMsg{}, Ignore: []string{"Body.Size"}},
+			errors: true,
+		},
+	} {
+		got, err := matchMsg(tc.exp, 1, received)
+		if tc.errors {
+			if err == nil {
+				t.Errorf("%s: expected error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: expected match %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
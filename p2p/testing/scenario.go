@@ -0,0 +1,93 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Scenario is a script of steps run in order against a protocol session
+// It extends single exchanges to conversations with several peers, where
+// the protocol is given time for its timers to fire between the steps and
+// peers are expected to be dropped with specific reasons
+type Scenario struct {
+	Label string
+	Steps []Step
+}
+
+// Step is a single step of a Scenario
+// The parts of a step that are set are run in the order of the fields:
+// the step waits, exchanges messages, checks the disconnections and finally
+// runs its check function
+type Step struct {
+	Label string
+	// Wait is the time to wait before the step, e.g. for a timer of the protocol to fire
+	Wait time.Duration
+	// Exchange is the exchange of messages with any number of peers,
+	// its triggers can be delayed with Trigger.Delay
+	Exchange *Exchange
+	// Disconnects are the peers expected to be dropped, with the expected reasons
+	Disconnects []*Disconnect
+	// DisconnectTimeout is the time to wait for the disconnections, 1 second if not set
+	DisconnectTimeout time.Duration
+	// Check is called last, to verify the state of the pivot node
+	Check func() error
+}
+
+// TestScenario runs the steps of the scenario in order, and returns the
+// error of the first step that fails
+func (s *ProtocolSession) TestScenario(scenario Scenario) error {
+	for i, step := range scenario.Steps {
+		if err := s.testStep(step); err != nil {
+			return fmt.Errorf("scenario %q step #%d %q: %v", scenario.Label, i, step.Label, err)
+		}
+		log.Trace(fmt.Sprintf("scenario %q step #%d %q: run successfully", scenario.Label, i, step.Label))
+	}
+	return nil
+}
+
+// testStep runs a single step of a scenario
+func (s *ProtocolSession) testStep(step Step) error {
+	if step.Exchange == nil && len(step.Disconnects) == 0 && step.Check == nil && step.Wait == 0 {
+		return errors.New("empty step")
+	}
+	if step.Wait > 0 {
+		time.Sleep(step.Wait)
+	}
+	if step.Exchange != nil {
+		if err := s.testExchange(*step.Exchange); err != nil {
+			return err
+		}
+	}
+	if len(step.Disconnects) > 0 {
+		timeout := step.DisconnectTimeout
+		if timeout == 0 {
+			timeout = time.Second
+		}
+		if err := s.testDisconnected(timeout, step.Disconnects...); err != nil {
+			return err
+		}
+	}
+	if step.Check != nil {
+		return step.Check()
+	}
+	return nil
+}
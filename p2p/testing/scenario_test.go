@@ -0,0 +1,116 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package testing
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+var errTestProtocol = errors.New("unexpected message")
+
+// newTimerProtocol returns a protocol run function replying to message 0 with
+// message 1 after the delay, and dropping peers sending any other message
+func newTimerProtocol(delay time.Duration, replies *int32) func(*p2p.Peer, p2p.MsgReadWriter) error {
+	return func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			if msg.Code != 0 {
+				msg.Discard()
+				return errTestProtocol
+			}
+			var n uint64
+			if err := msg.Decode(&n); err != nil {
+				return err
+			}
+			go func() {
+				time.Sleep(delay)
+				if p2p.Send(rw, 1, n+1) == nil {
+					atomic.AddInt32(replies, 1)
+				}
+			}()
+		}
+	}
+}
+
+// TestScenario tests a scenario of timed exchanges with several peers
+// followed by the drop of a misbehaving peer
+func TestScenario(t *testing.T) {
+	prvkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replies int32
+	tester := NewProtocolTester(prvkey, 2, newTimerProtocol(100*time.Millisecond, &replies))
+	defer tester.Stop()
+	peer0, peer1 := tester.Nodes[0].ID(), tester.Nodes[1].ID()
+
+	err = tester.TestScenario(Scenario{
+		Label: "timer",
+		Steps: []Step{
+			{
+				Label: "replies to both peers after the delay",
+				Exchange: &Exchange{
+					Triggers: []Trigger{
+						{Code: 0, Msg: uint64(1), Peer: peer0},
+						{Code: 0, Msg: uint64(41), Peer: peer1, Delay: 50 * time.Millisecond},
+					},
+					Expects: []Expect{
+						{Code: 1, Msg: uint64(2), Peer: peer0},
+						{Code: 1, Msg: uint64(42), Peer: peer1},
+					},
+				},
+			},
+			{
+				Label: "misbehaving peer is dropped",
+				Exchange: &Exchange{
+					Triggers: []Trigger{
+						{Code: 2, Msg: uint64(0), Peer: peer1},
+					},
+				},
+				Disconnects: []*Disconnect{
+					{Peer: peer1, Error: errTestProtocol},
+				},
+				Check: func() error {
+					if n := atomic.LoadInt32(&replies); n != 2 {
+						return errors.New("expected 2 replies")
+					}
+					return nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tester.TestScenario(Scenario{
+		Label: "empty",
+		Steps: []Step{{Label: "nothing"}},
+	})
+	if err == nil {
+		t.Fatal("expected error running an empty step")
+	}
+}
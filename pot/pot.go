@@ -34,6 +34,31 @@ type Pot struct {
 	po   int
 }
 
+// nodePool recycles the nodes returned by Release, so that the nodes
+// replaced by repeated updates of a Pot do not need to be garbage collected
+var nodePool = sync.Pool{
+	New: func() interface{} {
+		return new(Pot)
+	},
+}
+
+// newNode returns a node from the pool with the given fields
+func newNode(pin Val, bins []*Pot, size, po int) *Pot {
+	n := nodePool.Get().(*Pot)
+	n.pin = pin
+	n.bins = bins
+	n.size = size
+	n.po = po
+	return n
+}
+
+// putNode returns a node no longer referenced to the pool, its bins are
+// not reused as other nodes may share them
+var putNode = func(n *Pot) {
+	*n = Pot{}
+	nodePool.Put(n)
+}
+
 // Val is the element type for Pots
 type Val interface{}
 
@@ -48,11 +73,7 @@ func NewPot(v Val, po int) *Pot {
 	if v != nil {
 		size++
 	}
-	return &Pot{
-		pin:  v,
-		po:   po,
-		size: size,
-	}
+	return newNode(v, nil, size, po)
 }
 
 // Pin returns the pinned element (key) of the Pot
@@ -80,12 +101,7 @@ func Add(t *Pot, val Val, pof Pof) (*Pot, int, bool) {
 }
 
 func (t *Pot) clone() *Pot {
-	return &Pot{
-		pin:  t.pin,
-		size: t.size,
-		po:   t.po,
-		bins: t.bins,
-	}
+	return newNode(t.pin, t.bins, t.size, t.po)
 }
 
 func add(t *Pot, val Val, pof Pof) (*Pot, int, bool) {
@@ -124,22 +140,13 @@ func add(t *Pot, val Val, pof Pof) (*Pot, int, bool) {
 	}
 	if p == nil {
 		size++
-		p = &Pot{
-			pin:  val,
-			size: 1,
-			po:   po,
-		}
+		p = newNode(val, nil, 1, po)
 	}
 
 	bins := append([]*Pot{}, t.bins[:i]...)
 	bins = append(bins, p)
 	bins = append(bins, t.bins[j:]...)
-	r = &Pot{
-		pin:  t.pin,
-		size: size,
-		po:   t.po,
-		bins: bins,
-	}
+	r = newNode(t.pin, bins, size, t.po)
 
 	return r, po, found
 }
@@ -158,16 +165,11 @@ func remove(t *Pot, val Val, pof Pof) (r *Pot, po int, found bool) {
 	if found {
 		size--
 		if size == 0 {
-			return &Pot{}, po, true
+			return newNode(nil, nil, 0, 0), po, true
 		}
 		i := len(t.bins) - 1
 		last := t.bins[i]
-		r = &Pot{
-			pin:  last.pin,
-			bins: append(t.bins[:i], last.bins...),
-			size: size,
-			po:   t.po,
-		}
+		r = newNode(last.pin, append(append([]*Pot{}, t.bins[:i]...), last.bins...), size, t.po)
 		return r, t.po, true
 	}
 
@@ -189,17 +191,12 @@ func remove(t *Pot, val Val, pof Pof) (r *Pot, po int, found bool) {
 		i++
 		j++
 	}
-	bins := t.bins[:i]
+	bins := append([]*Pot{}, t.bins[:i]...)
 	if p != nil && p.pin != nil {
 		bins = append(bins, p)
 	}
 	bins = append(bins, t.bins[j:]...)
-	r = &Pot{
-		pin:  t.pin,
-		size: size,
-		po:   t.po,
-		bins: bins,
-	}
+	r = newNode(t.pin, bins, size, t.po)
 	return r, po, found
 }
 
@@ -227,21 +224,14 @@ func Swap(t *Pot, k Val, pof Pof, f func(v Val) Val) (r *Pot, po int, found bool
 		if val == nil {
 			size--
 			if size == 0 {
-				r = &Pot{
-					po: t.po,
-				}
+				r = newNode(nil, nil, 0, t.po)
 				// return empty pot
 				return r, po, true, true
 			}
 			// actually remove pin, by merging last bin
 			i := len(t.bins) - 1
 			last := t.bins[i]
-			r = &Pot{
-				pin:  last.pin,
-				bins: append(t.bins[:i], last.bins...),
-				size: size,
-				po:   t.po,
-			}
+			r = newNode(last.pin, append(append([]*Pot{}, t.bins[:i]...), last.bins...), size, t.po)
 			return r, po, true, true
 		}
 		// element found but no change
@@ -292,11 +282,7 @@ func Swap(t *Pot, k Val, pof Pof, f func(v Val) Val) (r *Pot, po int, found bool
 	}
 	///
 	size++
-	p = &Pot{
-		pin:  val,
-		size: 1,
-		po:   po,
-	}
+	p = newNode(val, nil, 1, po)
 
 	bins := append([]*Pot{}, t.bins[:i]...)
 	bins = append(bins, p)
@@ -309,6 +295,50 @@ func Swap(t *Pot, k Val, pof Pof, f func(v Val) Val) (r *Pot, po int, found bool
 	return r, po, found, true
 }
 
+// Release returns the nodes of t that are not part of r to the pool the nodes
+// of Pots are allocated from, r being the result of a single Add, Remove or
+// Swap called on t
+// Pots share the nodes that an update does not change with the Pot they are
+// derived from, so Release may only be called by the owner of t, once neither
+// t nor the Pots derived from t other than r are used any more, e.g. by an
+// owner that only reads the latest version of a Pot under the lock it updates
+// it with
+func Release(t, r *Pot) {
+	if t == nil || r == nil || t == r {
+		return
+	}
+	release(t, r)
+}
+
+// release returns t to the pool with the nodes of its bins replaced in r,
+// r is the node replacing t or the node t is merged into
+func release(t, r *Pot) {
+	for _, n := range t.bins {
+		if m, shared := r.replacement(n); !shared {
+			release(n, m)
+		}
+	}
+	putNode(t)
+}
+
+// replacement returns the node of t that replaces n, a bin of the node t replaces:
+// n itself if it is shared, the bin of t with the same proximity order if n is
+// updated, as a node has one bin per proximity order, or t itself if n is removed
+// or merged into t, when its pin is removed
+func (t *Pot) replacement(n *Pot) (m *Pot, shared bool) {
+	for _, b := range t.bins {
+		if b == n {
+			return b, true
+		}
+	}
+	for _, b := range t.bins {
+		if b.po == n.po {
+			return b, false
+		}
+	}
+	return t, false
+}
+
 // Union called on (t0, t1, pof) returns the union of t0 and t1
 // calculates the union using the applicative union
 // the second return value is the number of common elements
@@ -145,6 +145,128 @@ func TestPotRemoveDifferentBins(t *testing.T) {
 	}
 }
 
+// this test keeps older versions of a pot alive while deriving new ones
+// and checks that removing, swapping out or adding elements in the new
+// versions does not change the elements of the older ones
+func TestPotVersionsShareStructure(t *testing.T) {
+	pof := DefaultPof(8)
+	n := NewPot(newTestAddr("11111111", 0), 0)
+	n, _, _ = testAdd(n, pof, 1, "00000000", "10000000", "11000000", "11000001")
+	exp := fmt.Sprintf("%v", indexes(n))
+
+	r, _, _ := Remove(n, newTestAddr("11111111", 0), pof)
+	s, _, _, _ := Swap(n, newTestAddr("11111111", 0), pof, func(Val) Val { return nil })
+	u, _, _ := Remove(n, newTestAddr("10000000", 0), pof)
+	_, _, _ = testAdd(u, pof, 5, "01000000", "11100000")
+
+	if got := fmt.Sprintf("%v", indexes(n)); got != exp {
+		t.Fatalf("incorrect indexes in iteration over older Pot. Expected %v, got %v", exp, got)
+	}
+	if n.Size() != 5 {
+		t.Fatalf("incorrect number of elements in older Pot. Expected %v, got %v", 5, n.Size())
+	}
+	for _, v := range []*Pot{r, s, u} {
+		if v.Size() != 4 {
+			t.Fatalf("incorrect number of elements in Pot. Expected %v, got %v", 4, v.Size())
+		}
+		if got := len(indexes(v)); got != 4 {
+			t.Fatalf("incorrect number of elements in iteration over Pot. Expected %v, got %v", 4, got)
+		}
+	}
+}
+
+// nodes returns the set of nodes reachable from the root of t
+func nodes(t *Pot) map[*Pot]bool {
+	m := make(map[*Pot]bool)
+	var walk func(*Pot)
+	walk = func(n *Pot) {
+		m[n] = true
+		for _, b := range n.bins {
+			walk(b)
+		}
+	}
+	if t != nil {
+		walk(t)
+	}
+	return m
+}
+
+// releasing the previous version of a Pot returns exactly the nodes
+// not shared with the new version to the pool
+func TestPotRelease(t *testing.T) {
+	defer func(f func(*Pot)) { putNode = f }(putNode)
+	var released []*Pot
+	putNode = func(n *Pot) {
+		released = append(released, n)
+	}
+
+	pof := DefaultPof(8)
+	n := NewPot(nil, 0)
+	var vals []*testAddr
+	for i := 0; i < 1000; i++ {
+		var r *Pot
+		switch op := rand.Intn(4); {
+		case op == 0 || len(vals) == 0:
+			v := randomTestAddr(8, i)
+			r, _, _ = Add(n, v, pof)
+			// values added more than once replace the value with the same address
+			j := 0
+			for j < len(vals) && Label(vals[j].a) != Label(v.a) {
+				j++
+			}
+			if j == len(vals) {
+				vals = append(vals, v)
+			} else {
+				vals[j] = v
+			}
+		case op == 1:
+			j := rand.Intn(len(vals))
+			r, _, _ = Remove(n, vals[j], pof)
+			vals = append(vals[:j], vals[j+1:]...)
+		default:
+			j := rand.Intn(len(vals))
+			v := vals[j]
+			r, _, _, _ = Swap(n, v, pof, func(Val) Val {
+				if op == 2 {
+					return nil
+				}
+				return newTestAddr(Label(v.a), i)
+			})
+			if op == 2 {
+				vals = append(vals[:j], vals[j+1:]...)
+			} else {
+				vals[j] = newTestAddr(Label(v.a), i)
+			}
+		}
+
+		before, after := nodes(n), nodes(r)
+		released = released[:0]
+		Release(n, r)
+		got := make(map[*Pot]bool)
+		for _, m := range released {
+			if got[m] {
+				t.Fatalf("step %d: node released twice", i)
+			}
+			if after[m] {
+				t.Fatalf("step %d: released node still in use", i)
+			}
+			got[m] = true
+		}
+		for m := range before {
+			if !after[m] && !got[m] {
+				t.Fatalf("step %d: replaced node not released", i)
+			}
+		}
+		if r.Size() != len(vals) {
+			t.Fatalf("step %d: incorrect size. Expected %v, got %v", i, len(vals), r.Size())
+		}
+		if got := len(indexes(r)); got != len(vals) {
+			t.Fatalf("step %d: incorrect number of elements in iteration. Expected %v, got %v", i, len(vals), got)
+		}
+		n = r
+	}
+}
+
 func TestPotAdd(t *testing.T) {
 	pof := DefaultPof(8)
 	n := NewPot(newTestAddr("00111100", 0), 0)
@@ -884,6 +1006,39 @@ func benchmarkEachNeighbourAsync(t *testing.B, max, count int, d time.Duration)
 	runtime.ReadMemStats(stats)
 }
 
+func benchmarkSwap(b *testing.B, max int, release bool) {
+	b.ReportAllocs()
+	alen := maxkeylen
+	pof := DefaultPof(alen)
+	n := NewPot(nil, 0)
+	vals := make([]*testAddr, max)
+	for j := range vals {
+		vals[j] = randomTestAddr(alen, j)
+		n, _, _ = Add(n, vals[j], pof)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v := vals[i%max]
+		for _, f := range []func(Val) Val{
+			func(Val) Val { return nil },
+			func(Val) Val { return v },
+		} {
+			r, _, _, _ := Swap(n, v, pof, f)
+			if release {
+				Release(n, r)
+			}
+			n = r
+		}
+	}
+}
+
+func BenchmarkSwap_1000(b *testing.B) {
+	benchmarkSwap(b, 1000, false)
+}
+func BenchmarkSwapRelease_1000(b *testing.B) {
+	benchmarkSwap(b, 1000, true)
+}
+
 func BenchmarkEachNeighbourSync_3_1_0(t *testing.B) {
 	benchmarkEachNeighbourSync(t, 1000, 10, 1*time.Microsecond)
 }
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -45,6 +46,26 @@ func NewAPI(ps *Pss) *API {
 	return &API{Pss: ps}
 }
 
+// ReceiveAPI only exposes the subscription to incoming messages of the pss API,
+// it is served to the browser clients of the HTTP gateway WebSocket endpoint
+type ReceiveAPI struct {
+	api *API
+}
+
+func NewReceiveAPI(ps *Pss) *ReceiveAPI {
+	return &ReceiveAPI{api: NewAPI(ps)}
+}
+
+// Receive subscribes to the incoming messages matching the topic, see API.Receive
+func (r *ReceiveAPI) Receive(ctx context.Context, topic message.Topic, raw bool, prox bool) (*rpc.Subscription, error) {
+	return r.api.Receive(ctx, topic, raw, prox)
+}
+
+// StringToTopic returns the topic of the given string, see API.StringToTopic
+func (r *ReceiveAPI) StringToTopic(topicstring string) (message.Topic, error) {
+	return r.api.StringToTopic(topicstring)
+}
+
 // Creates a new subscription for the caller. Enables external handling of incoming messages.
 //
 // A new handler is registered in pss for the supplied topic
@@ -163,6 +184,25 @@ func (pssapi *API) SendAsym(pubkeyhex string, topic message.Topic, msg hexutil.B
 	return pssapi.Pss.SendAsym(pubkeyhex, topic, msg[:])
 }
 
+// SendMailbox deposits the message encrypted for the public key in the mailbox of the node,
+// for the recipient to fetch it when it is online, and returns the reference of its entry
+func (pssapi *API) SendMailbox(ctx context.Context, pubkeyhex string, topic message.Topic, msg hexutil.Bytes) (hexutil.Bytes, error) {
+	if err := validateMsg(msg); err != nil {
+		return nil, err
+	}
+	ref, err := pssapi.Pss.SendMailbox(ctx, pubkeyhex, topic, msg[:])
+	if err != nil {
+		return nil, err
+	}
+	return hexutil.Bytes(ref), nil
+}
+
+// FetchMailbox fetches the messages deposited for the node in the mailbox of the owner
+// and delivers them to the handlers of their topics, returning the number of delivered messages
+func (pssapi *API) FetchMailbox(ctx context.Context, owner common.Address) (int, error) {
+	return pssapi.Pss.FetchMailbox(ctx, owner)
+}
+
 func (pssapi *API) SendSym(symkeyhex string, topic message.Topic, msg hexutil.Bytes) error {
 	if err := validateMsg(msg); err != nil {
 		return err
@@ -187,6 +227,12 @@ func (pssapi *API) GetPeerAddress(pubkeyhex string, topic message.Topic) (PssAdd
 	return pssapi.Pss.getPeerAddress(pubkeyhex, topic)
 }
 
+// TraceRoute sends a trace message to the given address and returns the routes
+// the message and its reply took, as truncated overlay addresses of the nodes passed
+func (pssapi *API) TraceRoute(ctx context.Context, addr hexutil.Bytes) (*TraceResult, error) {
+	return pssapi.Pss.TraceRoute(ctx, PssAddress(addr))
+}
+
 func validateMsg(msg []byte) error {
 	if len(msg) == 0 {
 		return errors.New("invalid message length")
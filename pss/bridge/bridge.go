@@ -0,0 +1,341 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bridge relays messages between whisper and pss, so that dapps built on
+// whisper can move to pss routing one participant at a time.
+//
+// Each route maps a whisper topic to a pss topic. Messages received on one side
+// are decrypted with the key of that side and encrypted again with the key of the
+// other side before they are relayed, so neither side needs to know the key of
+// the other. The whisper envelopes sent by the bridge are remembered for a while
+// and not relayed back to pss, which keeps the bridge from looping its own
+// messages. Pss does not deliver the messages a node sends to its own handlers.
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/internal/ttlset"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/tilinna/clock"
+)
+
+// whisperKeyLength is the length of whisper symmetric keys
+const whisperKeyLength = 32
+
+// ErrNoRoutes is returned by New if the configuration has no routes
+var ErrNoRoutes = errors.New("no bridge routes")
+
+// Direction restricts the direction messages are relayed in on a route
+type Direction int
+
+const (
+	// Both relays messages from whisper to pss and from pss to whisper
+	Both Direction = iota
+	// WhisperToPss relays messages from whisper to pss only
+	WhisperToPss
+	// PssToWhisper relays messages from pss to whisper only
+	PssToWhisper
+)
+
+// Route maps a whisper topic to a pss topic
+type Route struct {
+	WhisperTopic  whisper.TopicType // topic of the whisper messages
+	WhisperSymKey []byte            // symmetric key the whisper messages are encrypted with
+	PssTopic      message.Topic     // topic of the pss messages
+	PssSymKey     []byte            // symmetric key the pss messages are encrypted with, raw messages are relayed if empty
+	PssAddress    pss.PssAddress    // address the pss messages are sent to, empty for all nodes
+	Direction     Direction         // direction messages are relayed in
+}
+
+// Validate checks the whisper key and the direction of the route
+func (r *Route) Validate() error {
+	if len(r.WhisperSymKey) != whisperKeyLength {
+		return fmt.Errorf("whisper key must be %d bytes long", whisperKeyLength)
+	}
+	if r.Direction < Both || r.Direction > PssToWhisper {
+		return fmt.Errorf("unknown direction %d", r.Direction)
+	}
+	return nil
+}
+
+func (r *Route) toPss() bool {
+	return r.Direction != PssToWhisper
+}
+
+func (r *Route) toWhisper() bool {
+	return r.Direction != WhisperToPss
+}
+
+// Config contains the Bridge configuration
+type Config struct {
+	Routes          []Route       // topics messages are relayed between
+	PollInterval    time.Duration // interval whisper is polled for new messages at
+	WhisperTTL      uint32        // time to live of relayed whisper messages in seconds
+	WhisperPoW      float64       // proof of work of relayed whisper messages
+	WhisperWorkTime uint32        // maximum seconds spent on the proof of work of a relayed whisper message
+	PssTTL          time.Duration // time to live of relayed raw pss messages
+	RelayedTTL      time.Duration // time a whisper envelope sent by the bridge is not relayed back to pss
+}
+
+// NewConfig returns a Config with default values and no routes
+func NewConfig() *Config {
+	return &Config{
+		PollInterval:    500 * time.Millisecond,
+		WhisperTTL:      whisper.DefaultTTL,
+		WhisperPoW:      whisper.DefaultMinimumPoW,
+		WhisperWorkTime: 5,
+		PssTTL:          pss.NewParams().MsgTTL,
+		RelayedTTL:      time.Minute,
+	}
+}
+
+// pssTransport is the part of pss the bridge relays messages with
+type pssTransport interface {
+	Register(topic message.Topic, raw bool, handler pss.HandlerFunc) func()
+	SetSymmetricKey(key []byte, topic message.Topic, address pss.PssAddress, addtocache bool) (string, error)
+	SendSym(symkeyid string, topic message.Topic, msg []byte) error
+	SendRaw(address pss.PssAddress, topic message.Topic, msg []byte, messageTTL time.Duration) error
+}
+
+// pssAdapter adapts Pss to the pssTransport interface
+type pssAdapter struct {
+	*pss.Pss
+}
+
+func (p pssAdapter) Register(topic message.Topic, raw bool, handler pss.HandlerFunc) func() {
+	h := pss.NewHandler(handler)
+	if raw {
+		h = h.WithRaw()
+	}
+	return p.Pss.Register(&topic, h)
+}
+
+// route is a configured Route with the state of its subscriptions
+type route struct {
+	Route
+	index      int
+	filterID   string // whisper filter, if relaying to pss
+	pssKeyID   string // pss symmetric key, if not relaying raw messages
+	deregister func() // pss handler, if relaying to whisper
+}
+
+// Bridge relays messages between whisper and pss
+// It implements node.Service, so it can run in a node alongside whisper and swarm
+type Bridge struct {
+	whisper *whisper.Whisper
+	pss     pssTransport
+	config  Config
+	routes  []*route
+
+	sent *ttlset.TTLSet // hashes of the whisper envelopes sent by the bridge
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a Bridge relaying messages between w and ps on the routes of config
+func New(w *whisper.Whisper, ps *pss.Pss, config *Config) (*Bridge, error) {
+	return newBridge(w, pssAdapter{ps}, config)
+}
+
+func newBridge(w *whisper.Whisper, ps pssTransport, config *Config) (*Bridge, error) {
+	if len(config.Routes) == 0 {
+		return nil, ErrNoRoutes
+	}
+	b := &Bridge{
+		whisper: w,
+		pss:     ps,
+		config:  *config,
+		sent: ttlset.New(&ttlset.Config{
+			EntryTTL: config.RelayedTTL,
+			Clock:    clock.Realtime(),
+		}),
+		quit: make(chan struct{}),
+	}
+	for i, r := range config.Routes {
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("route %d: %v", i, err)
+		}
+		b.routes = append(b.routes, &route{
+			Route: r,
+			index: i,
+		})
+	}
+	return b, nil
+}
+
+// Protocols implements node.Service
+func (b *Bridge) Protocols() []p2p.Protocol {
+	return nil
+}
+
+// APIs implements node.Service
+func (b *Bridge) APIs() []rpc.API {
+	return nil
+}
+
+// Start subscribes to the topics of all routes and starts relaying messages
+func (b *Bridge) Start(_ *p2p.Server) error {
+	for _, r := range b.routes {
+		if err := b.subscribe(r); err != nil {
+			b.unsubscribe()
+			return fmt.Errorf("route %d: %v", r.index, err)
+		}
+	}
+	b.wg.Add(1)
+	go b.poll()
+	log.Info("pss bridge started", "routes", len(b.routes))
+	return nil
+}
+
+// Stop stops relaying messages and unsubscribes from all topics
+func (b *Bridge) Stop() error {
+	close(b.quit)
+	b.wg.Wait()
+	b.unsubscribe()
+	return nil
+}
+
+func (b *Bridge) subscribe(r *route) error {
+	if len(r.PssSymKey) > 0 {
+		id, err := b.pss.SetSymmetricKey(r.PssSymKey, r.PssTopic, r.PssAddress, true)
+		if err != nil {
+			return err
+		}
+		r.pssKeyID = id
+	}
+	if r.toPss() {
+		id, err := b.whisper.Subscribe(&whisper.Filter{
+			KeySym: r.WhisperSymKey,
+			Topics: [][]byte{r.WhisperTopic[:]},
+		})
+		if err != nil {
+			return err
+		}
+		r.filterID = id
+	}
+	if r.toWhisper() {
+		r.deregister = b.pss.Register(r.PssTopic, r.pssKeyID == "", b.pssHandler(r))
+	}
+	return nil
+}
+
+func (b *Bridge) unsubscribe() {
+	for _, r := range b.routes {
+		if r.filterID != "" {
+			if err := b.whisper.Unsubscribe(r.filterID); err != nil {
+				log.Warn("pss bridge: whisper unsubscribe", "route", r.index, "err", err)
+			}
+			r.filterID = ""
+		}
+		if r.deregister != nil {
+			r.deregister()
+			r.deregister = nil
+		}
+	}
+}
+
+// poll relays the messages received by the whisper filters of the routes to pss
+func (b *Bridge) poll() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, r := range b.routes {
+				if r.filterID == "" {
+					continue
+				}
+				f := b.whisper.GetFilter(r.filterID)
+				if f == nil {
+					continue
+				}
+				for _, msg := range f.Retrieve() {
+					// the envelopes sent by the bridge are not relayed back
+					if b.sent.Has(msg.EnvelopeHash) {
+						continue
+					}
+					if err := b.relayToPss(r, msg.Payload); err != nil {
+						metrics.GetOrRegisterCounter("pss/bridge/topss/error", nil).Inc(1)
+						log.Warn("pss bridge: relay to pss", "route", r.index, "err", err)
+					}
+				}
+			}
+			b.sent.GC()
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+func (b *Bridge) relayToPss(r *route, payload []byte) error {
+	metrics.GetOrRegisterCounter("pss/bridge/topss", nil).Inc(1)
+	if r.pssKeyID == "" {
+		return b.pss.SendRaw(r.PssAddress, r.PssTopic, payload, b.config.PssTTL)
+	}
+	return b.pss.SendSym(r.pssKeyID, r.PssTopic, payload)
+}
+
+// pssHandler returns the handler relaying the pss messages of a route to whisper
+func (b *Bridge) pssHandler(r *route) pss.HandlerFunc {
+	return func(msg []byte, _ *p2p.Peer, asymmetric bool, keyid string) error {
+		// only relay messages encrypted with the key of the route, or raw ones if it has none
+		if asymmetric || keyid != r.pssKeyID {
+			return nil
+		}
+		if err := b.relayToWhisper(r, msg); err != nil {
+			metrics.GetOrRegisterCounter("pss/bridge/towhisper/error", nil).Inc(1)
+			log.Warn("pss bridge: relay to whisper", "route", r.index, "err", err)
+			return err
+		}
+		return nil
+	}
+}
+
+func (b *Bridge) relayToWhisper(r *route, payload []byte) error {
+	metrics.GetOrRegisterCounter("pss/bridge/towhisper", nil).Inc(1)
+	params := &whisper.MessageParams{
+		TTL:      b.config.WhisperTTL,
+		KeySym:   r.WhisperSymKey,
+		Topic:    r.WhisperTopic,
+		PoW:      b.config.WhisperPoW,
+		WorkTime: b.config.WhisperWorkTime,
+		Payload:  payload,
+	}
+	sent, err := whisper.NewSentMessage(params)
+	if err != nil {
+		return err
+	}
+	envelope, err := sent.Wrap(params)
+	if err != nil {
+		return err
+	}
+	// the envelope is recorded before it is sent, as the filters of the routes
+	// match it as soon as it is in the whisper pool
+	b.sent.Add(envelope.Hash())
+	return b.whisper.Send(envelope)
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
+	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// sentMsg is a message sent through the mock pss
+type sentMsg struct {
+	keyid   string
+	address pss.PssAddress
+	topic   message.Topic
+	payload []byte
+}
+
+// mockPss records the messages sent by the bridge and keeps its handlers
+type mockPss struct {
+	mu       sync.Mutex
+	keys     map[string][]byte
+	handlers map[message.Topic]pss.HandlerFunc
+	sent     chan sentMsg
+}
+
+func newMockPss() *mockPss {
+	return &mockPss{
+		keys:     make(map[string][]byte),
+		handlers: make(map[message.Topic]pss.HandlerFunc),
+		sent:     make(chan sentMsg, 10),
+	}
+}
+
+func (p *mockPss) Register(topic message.Topic, _ bool, handler pss.HandlerFunc) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[topic] = handler
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.handlers, topic)
+	}
+}
+
+func (p *mockPss) SetSymmetricKey(key []byte, _ message.Topic, _ pss.PssAddress, _ bool) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := string(key)
+	p.keys[id] = key
+	return id, nil
+}
+
+func (p *mockPss) SendSym(symkeyid string, topic message.Topic, msg []byte) error {
+	p.sent <- sentMsg{keyid: symkeyid, topic: topic, payload: msg}
+	return nil
+}
+
+func (p *mockPss) SendRaw(address pss.PssAddress, topic message.Topic, msg []byte, _ time.Duration) error {
+	p.sent <- sentMsg{address: address, topic: topic, payload: msg}
+	return nil
+}
+
+// receive delivers a pss message to the handler of topic
+func (p *mockPss) receive(t *testing.T, topic message.Topic, keyid string, msg []byte) {
+	t.Helper()
+	p.mu.Lock()
+	h := p.handlers[topic]
+	p.mu.Unlock()
+	if h == nil {
+		t.Fatalf("no handler for topic %v", topic)
+	}
+	if err := h(msg, nil, false, keyid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBridge tests relaying messages in both directions, re-encrypted with the
+// key of the other side, that relayed messages are not relayed back and that
+// repeated messages are relayed again
+func TestBridge(t *testing.T) {
+	cfg := whisper.DefaultConfig
+	cfg.MinimumAcceptedPOW = 0
+	w := whisper.New(&cfg)
+	if err := w.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	whisperKey := bytes.Repeat([]byte{1}, whisperKeyLength)
+	pssKey := bytes.Repeat([]byte{2}, 32)
+	symTopic := message.NewTopic([]byte("sym"))
+	rawTopic := message.NewTopic([]byte("raw"))
+
+	config := NewConfig()
+	config.PollInterval = 10 * time.Millisecond
+	config.WhisperPoW = 0
+	config.Routes = []Route{
+		{
+			WhisperTopic:  whisper.BytesToTopic([]byte("sym")),
+			WhisperSymKey: whisperKey,
+			PssTopic:      symTopic,
+			PssSymKey:     pssKey,
+		},
+		{
+			WhisperTopic:  whisper.BytesToTopic([]byte("raw")),
+			WhisperSymKey: whisperKey,
+			PssTopic:      rawTopic,
+			PssAddress:    pss.PssAddress{0xaa},
+			Direction:     PssToWhisper,
+		},
+	}
+	ps := newMockPss()
+	b, err := newBridge(w, ps, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	// a dapp still on whisper
+	dapp, err := w.Subscribe(&whisper.Filter{
+		KeySym: whisperKey,
+		Topics: [][]byte{config.Routes[0].WhisperTopic[:], config.Routes[1].WhisperTopic[:]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// whisper to pss
+	expectPss := func() {
+		t.Helper()
+		select {
+		case m := <-ps.sent:
+			if m.keyid != string(pssKey) || m.topic != symTopic || string(m.payload) != "from whisper" {
+				t.Fatalf("got pss message %+v", m)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("whisper message not relayed to pss")
+		}
+	}
+	post(t, w, config.Routes[0].WhisperTopic, whisperKey, "from whisper")
+	expectPss()
+	expectWhisper(t, w, dapp, "from whisper")
+
+	// pss to whisper, not relayed back to pss
+	ps.receive(t, symTopic, string(pssKey), []byte("from pss"))
+	expectWhisper(t, w, dapp, "from pss")
+
+	// the same payloads sent again are relayed again
+	post(t, w, config.Routes[0].WhisperTopic, whisperKey, "from whisper")
+	expectPss()
+	expectWhisper(t, w, dapp, "from whisper")
+	ps.receive(t, symTopic, string(pssKey), []byte("from pss"))
+	expectWhisper(t, w, dapp, "from pss")
+
+	// raw pss to whisper
+	ps.receive(t, rawTopic, "", []byte("raw from pss"))
+	expectWhisper(t, w, dapp, "raw from pss")
+
+	// messages encrypted with other keys are ignored
+	ps.receive(t, symTopic, "other", []byte("other key"))
+	ps.receive(t, rawTopic, string(pssKey), []byte("other key"))
+
+	time.Sleep(10 * config.PollInterval)
+	select {
+	case m := <-ps.sent:
+		t.Fatalf("unexpected pss message %+v", m)
+	default:
+	}
+	if msgs := w.GetFilter(dapp).Retrieve(); len(msgs) != 0 {
+		t.Fatalf("unexpected whisper message %q", msgs[0].Payload)
+	}
+}
+
+// post sends a whisper message the way a dapp would
+func post(t *testing.T, w *whisper.Whisper, topic whisper.TopicType, key []byte, payload string) {
+	t.Helper()
+	params := &whisper.MessageParams{
+		TTL:      whisper.DefaultTTL,
+		KeySym:   key,
+		Topic:    topic,
+		WorkTime: 1,
+		Payload:  []byte(payload),
+	}
+	sent, err := whisper.NewSentMessage(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := sent.Wrap(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Send(envelope); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectWhisper waits for the whisper filter with id to receive a message with payload
+func expectWhisper(t *testing.T, w *whisper.Whisper, id string, payload string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, msg := range w.GetFilter(id).Retrieve() {
+			if string(msg.Payload) != payload {
+				t.Fatalf("got whisper message %q, want %q", msg.Payload, payload)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("whisper message %q not received", payload)
+}
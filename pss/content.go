@@ -0,0 +1,289 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/storage"
+)
+
+const (
+	defaultContentFetchConcurrency = 8
+	defaultContentFetchesPerSender = 16 // references of a sender fetched within contentSenderWindow
+	contentSenderWindow            = time.Minute
+	contentTimeout                 = 30 * time.Second
+	maxContentSize                 = 64 * 1024 * 1024 // upper bound of the payloads fetched for a reference
+)
+
+// contentSender counts the references of a sender fetched in the current window
+type contentSender struct {
+	start time.Time
+	count int
+}
+
+var errContentTooLarge = errors.New("content too large")
+
+// ContentStore stores the payloads of messages that are too large for a pss envelope.
+// Only the reference to the content is sent, and the recipient fetches the payload with it
+type ContentStore interface {
+	// Put stores the payload encrypted and returns the reference to it, which includes the decryption key
+	Put(ctx context.Context, data []byte) (storage.Address, error)
+	// Get fetches and decrypts the payload of the reference
+	Get(ctx context.Context, ref storage.Address) ([]byte, error)
+	// Remove removes the payload of the reference stored with Put from the local store
+	Remove(ctx context.Context, ref storage.Address) error
+}
+
+// SetContentStore sets the content store that payloads larger than the content threshold
+// are stored in, and that the payloads of received references are fetched from
+func (p *Pss) SetContentStore(cs ContentStore) {
+	p.contentMu.Lock()
+	defer p.contentMu.Unlock()
+	p.contentStore = cs
+}
+
+func (p *Pss) getContentStore() ContentStore {
+	p.contentMu.Lock()
+	defer p.contentMu.Unlock()
+	return p.contentStore
+}
+
+// storeContent stores the payload in the content store if it is larger than the content
+// threshold and returns the reference to it, or the payload itself otherwise
+// The returned bool is true if the payload was replaced with a reference
+func (p *Pss) storeContent(msg []byte) ([]byte, bool, error) {
+	cs := p.getContentStore()
+	if cs == nil || p.contentThreshold <= 0 || len(msg) <= p.contentThreshold {
+		return msg, false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), contentTimeout)
+	defer cancel()
+	ref, err := cs.Put(ctx, msg)
+	if err != nil {
+		return nil, false, err
+	}
+	p.contentMu.Lock()
+	p.contentRefs[string(ref)] = time.Now().Add(p.contentTTL)
+	p.contentMu.Unlock()
+	metrics.GetOrRegisterCounter("pss/content/store", nil).Inc(1)
+	return ref, true, nil
+}
+
+// cleanContent removes the payloads stored by this node whose time to live passed
+func (p *Pss) cleanContent() {
+	cs := p.getContentStore()
+	if cs == nil {
+		return
+	}
+	now := time.Now()
+	var expired []storage.Address
+	p.contentMu.Lock()
+	for ref, expiry := range p.contentRefs {
+		if now.After(expiry) {
+			expired = append(expired, storage.Address(ref))
+			delete(p.contentRefs, ref)
+		}
+	}
+	p.contentMu.Unlock()
+	for _, ref := range expired {
+		ctx, cancel := context.WithTimeout(context.Background(), contentTimeout)
+		if err := cs.Remove(ctx, ref); err != nil {
+			log.Warn("pss content cleanup failed", "ref", ref, "err", err)
+		}
+		cancel()
+	}
+	metrics.GetOrRegisterCounter("pss/content/clean", nil).Inc(int64(len(expired)))
+
+	p.contentMu.Lock()
+	for sender, cs := range p.contentSenders {
+		if now.Sub(cs.start) >= contentSenderWindow {
+			delete(p.contentSenders, sender)
+		}
+	}
+	p.contentMu.Unlock()
+}
+
+// dispatchContent fetches the payload of a message that refers to the content in swarm
+// in its own goroutine, and dispatches it to the handlers of the topic
+// The reference is dropped if defaultContentFetchConcurrency payloads are already being
+// fetched, or if the sender, identified by the key id of the message, had more than
+// defaultContentFetchesPerSender references fetched within contentSenderWindow
+func (p *Pss) dispatchContent(topic message.Topic, ref []byte, from PssAddress, prox bool, asymmetric bool, keyid string) {
+	if !p.allowContentFetch(keyid, time.Now()) {
+		metrics.GetOrRegisterCounter("pss/content/fetch/ratelimited", nil).Inc(1)
+		log.Debug("pss content fetch rate limited", "topic", label(topic[:]), "keyid", keyid)
+		return
+	}
+	select {
+	case p.contentFetches <- struct{}{}:
+	default:
+		metrics.GetOrRegisterCounter("pss/content/fetch/dropped", nil).Inc(1)
+		log.Debug("pss content fetch dropped, too many fetches", "topic", label(topic[:]), "ref", storage.Address(ref))
+		return
+	}
+	go func() {
+		defer func() { <-p.contentFetches }()
+		p.executeContentHandlers(topic, ref, from, prox, asymmetric, keyid)
+	}()
+}
+
+// allowContentFetch accounts for a reference of the sender to be fetched at time now,
+// and returns false if the sender already used up its fetches of the current window
+func (p *Pss) allowContentFetch(sender string, now time.Time) bool {
+	p.contentMu.Lock()
+	defer p.contentMu.Unlock()
+	cs, ok := p.contentSenders[sender]
+	if !ok || now.Sub(cs.start) >= contentSenderWindow {
+		cs = &contentSender{start: now}
+		p.contentSenders[sender] = cs
+	}
+	if cs.count >= defaultContentFetchesPerSender {
+		return false
+	}
+	cs.count++
+	return true
+}
+
+// executeContentHandlers fetches the payload of a message that refers to the content in swarm,
+// and dispatches it to the handlers of the topic
+// Fetching the content may take long, so it is called in its own goroutine by dispatchContent
+func (p *Pss) executeContentHandlers(topic message.Topic, ref []byte, from PssAddress, prox bool, asymmetric bool, keyid string) {
+	payload, err := p.fetchContent(ref)
+	if err != nil {
+		metrics.GetOrRegisterCounter("pss/content/fetch/fail", nil).Inc(1)
+		log.Warn("pss content fetch failed", "topic", label(topic[:]), "ref", storage.Address(ref), "err", err)
+		return
+	}
+	p.executeHandlers(topic, payload, from, false, prox, asymmetric, keyid)
+}
+
+// fetchContent fetches the payload of the reference from the content store
+// The fetch ends after contentTimeout, or when pss stops
+func (p *Pss) fetchContent(ref []byte) ([]byte, error) {
+	cs := p.getContentStore()
+	if cs == nil {
+		return nil, errors.New("no content store")
+	}
+	defer metrics.GetOrRegisterResettingTimer("pss/content/fetch", nil).UpdateSince(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), contentTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.quitC:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return cs.Get(ctx, storage.Address(ref))
+}
+
+// swarmContentStore stores the payloads in swarm, encrypted
+type swarmContentStore struct {
+	store       storage.ChunkStore
+	putterStore storage.ChunkStore
+	params      *storage.FileStoreParams
+	fileStore   *storage.FileStore
+
+	chunks map[string][]storage.Address // addresses of the chunks of the stored payloads
+	mu     sync.Mutex
+}
+
+// NewSwarmContentStore returns a ContentStore that stores the payloads with the putter store
+// and retrieves them from the store, the same way as a FileStore created with them
+func NewSwarmContentStore(store storage.ChunkStore, putterStore storage.ChunkStore, params *storage.FileStoreParams) ContentStore {
+	return &swarmContentStore{
+		store:       store,
+		putterStore: putterStore,
+		params:      params,
+		fileStore:   storage.NewFileStore(store, putterStore, params, chunk.NewTags()),
+		chunks:      make(map[string][]storage.Address),
+	}
+}
+
+// Put implements the ContentStore interface
+// The addresses of the chunks are recorded so that they can be removed again
+func (s *swarmContentStore) Put(ctx context.Context, data []byte) (storage.Address, error) {
+	putter := &recordingStore{Store: s.putterStore}
+	fs := storage.NewFileStore(s.store, putter, s.params, chunk.NewTags())
+	ref, wait, err := fs.Store(ctx, bytes.NewReader(data), int64(len(data)), true)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.chunks[string(ref)] = putter.addrs
+	s.mu.Unlock()
+	return ref, nil
+}
+
+// Get implements the ContentStore interface
+func (s *swarmContentStore) Get(ctx context.Context, ref storage.Address) ([]byte, error) {
+	reader, _ := s.fileStore.Retrieve(ctx, ref)
+	size, err := reader.Size(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxContentSize {
+		return nil, errContentTooLarge
+	}
+	return ioutil.ReadAll(io.NewSectionReader(reader, 0, size))
+}
+
+// Remove implements the ContentStore interface
+func (s *swarmContentStore) Remove(ctx context.Context, ref storage.Address) error {
+	s.mu.Lock()
+	addrs := s.chunks[string(ref)]
+	delete(s.chunks, string(ref))
+	s.mu.Unlock()
+	if len(addrs) == 0 {
+		return nil
+	}
+	return s.putterStore.Set(ctx, chunk.ModeSetRemove, addrs...)
+}
+
+// recordingStore records the addresses of the chunks put in the store
+type recordingStore struct {
+	chunk.Store
+	addrs []storage.Address
+	mu    sync.Mutex
+}
+
+func (r *recordingStore) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) ([]bool, error) {
+	exist, err := r.Store.Put(ctx, mode, chs...)
+	if err != nil {
+		return exist, err
+	}
+	r.mu.Lock()
+	for _, ch := range chs {
+		r.addrs = append(r.addrs, ch.Address())
+	}
+	r.mu.Unlock()
+	return exist, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestContentStore tests that a payload larger than the content threshold is stored in swarm
+// and sent as a reference, that the recipient receives the payload itself, and that the
+// stored content is removed from the local store of the sender after its time to live
+func TestContentStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pss-content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+	// sender and recipient share the storage, as if they were connected to the same network
+	store := NewSwarmContentStore(localStore, localStore, storage.NewFileStoreParams())
+
+	newPss := func() *Pss {
+		key, err := ethCrypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := newTestPss(key, nil, nil)
+		ps.SetContentStore(store)
+		ps.contentThreshold = 1024
+		return ps
+	}
+	sender := newPss()
+	defer sender.Stop()
+	recipient := newPss()
+	defer recipient.Stop()
+
+	topic := message.NewTopic([]byte("content"))
+	msgC := make(chan []byte, 1)
+	recipient.Register(&topic, NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		msgC <- msg
+		return nil
+	}))
+	key := recipient.Crypto.SerializePublicKey(&recipient.privateKey.PublicKey)
+	to := PssAddress(recipient.BaseAddr())
+
+	send := func(payload []byte, wantReference bool) []byte {
+		t.Helper()
+		msg, reference, err := sender.storeContent(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reference != wantReference {
+			t.Fatalf("got reference %v, want %v", reference, wantReference)
+		}
+		pssMsg, err := sender.wrap(to, topic, msg, true, key, reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recipient.process(pssMsg, false, false, enode.ID{}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-msgC:
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("got payload of %v bytes, want %v bytes", len(got), len(payload))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not delivered")
+		}
+		return msg
+	}
+
+	// small payloads are sent as they are
+	send([]byte("small"), false)
+
+	large := make([]byte, 10*chunk.DefaultSize)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatal(err)
+	}
+	ref := send(large, true)
+	if len(ref) != 2*common.HashLength {
+		t.Fatalf("got reference of %v bytes, want an encrypted reference", len(ref))
+	}
+
+	// the content is kept until its time to live passes
+	sender.cleanContent()
+	if _, err := store.Get(context.Background(), ref); err != nil {
+		t.Fatalf("content removed before its time to live: %v", err)
+	}
+	sender.contentMu.Lock()
+	sender.contentRefs[string(ref)] = time.Now().Add(-time.Second)
+	sender.contentMu.Unlock()
+	sender.cleanContent()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := store.Get(ctx, ref); err == nil {
+		t.Fatal("content not removed after its time to live")
+	}
+}
+
+// blockingContentStore is a ContentStore whose fetches block until they are released
+type blockingContentStore struct {
+	ContentStore
+	fetches chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingContentStore) Get(ctx context.Context, ref storage.Address) ([]byte, error) {
+	s.fetches <- struct{}{}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return []byte("payload"), nil
+}
+
+// TestContentFetchLimits tests that the references received while the maximum number of
+// payloads are being fetched are dropped instead of waiting, and that the number of
+// references of a sender fetched within a window is limited
+func TestContentFetchLimits(t *testing.T) {
+	key, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(key, nil, nil)
+	defer ps.Stop()
+	store := &blockingContentStore{
+		fetches: make(chan struct{}, 2*defaultContentFetchConcurrency),
+		release: make(chan struct{}),
+	}
+	ps.SetContentStore(store)
+	topic := message.NewTopic([]byte("content"))
+
+	for i := 0; i < 2*defaultContentFetchConcurrency; i++ {
+		ps.dispatchContent(topic, []byte("ref"), nil, false, true, fmt.Sprintf("sender%d", i))
+	}
+	for i := 0; i < defaultContentFetchConcurrency; i++ {
+		select {
+		case <-store.fetches:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %v fetches, want %v", i, defaultContentFetchConcurrency)
+		}
+	}
+	close(store.release)
+	select {
+	case <-store.fetches:
+		t.Fatal("fetched a reference beyond the concurrency limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	now := time.Now()
+	for i := 0; i < defaultContentFetchesPerSender; i++ {
+		if !ps.allowContentFetch("sender", now) {
+			t.Fatalf("fetch %v of the sender not allowed", i)
+		}
+	}
+	if ps.allowContentFetch("sender", now) {
+		t.Fatal("fetch beyond the limit of the sender allowed")
+	}
+	if !ps.allowContentFetch("other", now) {
+		t.Fatal("fetch of another sender not allowed")
+	}
+	if !ps.allowContentFetch("sender", now.Add(contentSenderWindow)) {
+		t.Fatal("fetch of the sender in the next window not allowed")
+	}
+}
@@ -45,7 +45,8 @@ const (
 	padSizeLimit            = 256     // just an arbitrary number, could be changed without breaking the protocol
 	SizeMask                = byte(3) // mask used to extract the size of payload size field from the flags
 	signatureFlag           = byte(4)
-	aesKeyLength            = 32 // in bytes
+	referenceFlag           = byte(8) // set if the payload is a reference to the content in swarm
+	aesKeyLength            = 32      // in bytes
 
 	defaultPaddingByteSize = 16
 )
@@ -57,6 +58,7 @@ type WrapParams struct {
 	Sender       *ecdsa.PrivateKey // Private key of sender used for signature
 	Receiver     *ecdsa.PublicKey  // Public key of receiver for encryption
 	SymmetricKey []byte            // Symmetric key for encryption
+	Reference    bool              // If true, the payload is flagged as a reference to the content in swarm
 }
 
 // Config params to unwrap and decrypt a message.
@@ -72,6 +74,7 @@ type UnwrapParams struct {
 type ReceivedMessage interface {
 	GetPayload() ([]byte, error)
 	GetSender() *ecdsa.PublicKey
+	IsReference() bool
 }
 
 // Crypto contains methods from Message and KeyStore
@@ -154,6 +157,11 @@ func (msg *receivedMessage) GetPayload() ([]byte, error) {
 	return msg.Payload, msg.validateError
 }
 
+// IsReference returns true if the payload of the message is a reference to the content in swarm
+func (msg *receivedMessage) IsReference() bool {
+	return len(msg.Raw) > 0 && msg.Raw[0]&referenceFlag != 0
+}
+
 // validateAndParse checks that the format and the signature are correct. It also set Payload as the parsed message
 func (msg *receivedMessage) validateAndParse() error {
 	end := len(msg.Raw)
@@ -181,11 +189,14 @@ func (msg *receivedMessage) validateAndParse() error {
 	sizeOfPayloadSizeField := int(msg.Raw[0] & SizeMask) // number of bytes indicating the size of payload
 	if sizeOfPayloadSizeField != 0 {
 		log.Warn("Size of payload field", "size", sizeOfPayloadSizeField)
-		payloadSize = int(bytesToUintLittleEndian(msg.Raw[beg : beg+sizeOfPayloadSizeField]))
-		if payloadSize+1 > end {
+		if beg+sizeOfPayloadSizeField > end {
 			return errIncorrectSize
 		}
+		payloadSize = int(bytesToUintLittleEndian(msg.Raw[beg : beg+sizeOfPayloadSizeField]))
 		beg += sizeOfPayloadSizeField
+		if payloadSize > end-beg {
+			return errIncorrectSize
+		}
 		msg.Payload = msg.Raw[beg : beg+payloadSize]
 	}
 
@@ -233,6 +244,9 @@ func (crypto *defaultCryptoBackend) Wrap(plaintext []byte, params *WrapParams) (
 		flagsLength+payloadSizeFieldMaxSize+len(plaintext)+len(padding)+signatureLength+padSizeLimit)
 	// set flags byte
 	rawBytes[0] = 0 // set all the flags to zero
+	if params.Reference {
+		rawBytes[0] |= referenceFlag // set before signing, as the flags are signed
+	}
 	// add payloadSizeField
 	rawBytes = crypto.addPayloadSizeField(rawBytes, plaintext)
 	// add payload
@@ -456,6 +470,11 @@ func (crypto *defaultCryptoBackend) decryptSymmetric(rawBytes []byte, key []byte
 }
 
 // encryptAsymmetric encrypts a message with a public key.
+// ECIES derives the shared secret from a fresh ephemeral key for every message,
+// so unlike the static ECDH of the access control session keys there is no
+// secret that could be cached between messages on either the send or the
+// decrypt path. Reusing the ephemeral key would make messages to the same
+// recipient linkable, which is why it is not done.
 func (crypto *defaultCryptoBackend) encryptAsymmetric(rawBytes []byte, key *ecdsa.PublicKey) ([]byte, error) {
 	if !validatePublicKey(key) {
 		return nil, errInvalidPubkey
@@ -0,0 +1,139 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestValidateAndParse tests the parsing of decrypted messages with truncated
+// or oversized payload size fields and signatures
+func TestValidateAndParse(t *testing.T) {
+	key, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signed appends the signature of the message with the signature flag set
+	signed := func(raw []byte) []byte {
+		raw[0] |= signatureFlag
+		sig, err := ethCrypto.Sign(ethCrypto.Keccak256(raw), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(raw, sig...)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		raw     []byte
+		err     error
+		payload []byte
+		padding []byte
+	}{
+		{
+			name: "empty",
+			raw:  []byte{},
+			err:  errEmptyMessage,
+		},
+		{
+			name: "truncated size field",
+			raw:  []byte{2, 3},
+			err:  errIncorrectSize,
+		},
+		{
+			name: "missing size field",
+			raw:  []byte{3},
+			err:  errIncorrectSize,
+		},
+		{
+			name: "oversized payload",
+			raw:  []byte{1, 10, 'a', 'b', 'c'},
+			err:  errIncorrectSize,
+		},
+		{
+			name: "oversized payload of largest size field",
+			raw:  []byte{3, 0xff, 0xff, 0xff, 'a'},
+			err:  errIncorrectSize,
+		},
+		{
+			name: "truncated signature",
+			raw:  append([]byte{signatureFlag}, make([]byte, signatureLength-1)...),
+			err:  errEmptySignature,
+		},
+		{
+			name: "signature without content",
+			raw:  append([]byte{signatureFlag}, make([]byte, signatureLength)...),
+			err:  errEmptySignature,
+		},
+		{
+			name: "payload into the signature",
+			raw:  signed([]byte{1, 3, 'a'}),
+			err:  errIncorrectSize,
+		},
+		{
+			name: "invalid signature",
+			raw:  append([]byte{signatureFlag | 1, 1, 'a'}, make([]byte, signatureLength)...),
+			err:  errIncorrectSignature,
+		},
+		{
+			name:    "payload and padding",
+			raw:     []byte{1, 3, 'a', 'b', 'c', 0, 0},
+			payload: []byte("abc"),
+			padding: []byte{0, 0},
+		},
+		{
+			name:    "payload filling the message",
+			raw:     []byte{2, 3, 0, 'a', 'b', 'c'},
+			payload: []byte("abc"),
+			padding: []byte{},
+		},
+		{
+			name:    "signed payload",
+			raw:     signed([]byte{1, 3, 'a', 'b', 'c', 0}),
+			payload: []byte("abc"),
+			padding: []byte{0},
+		},
+		{
+			name:    "no size field",
+			raw:     []byte{0, 'a', 'b'},
+			padding: []byte("ab"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := &receivedMessage{
+				Raw:    tc.raw,
+				crypto: newDefaultCryptoBackend(),
+			}
+			err := msg.validateAndParse()
+			if err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(msg.Payload, tc.payload) {
+				t.Fatalf("expected payload %x, got %x", tc.payload, msg.Payload)
+			}
+			if !bytes.Equal(msg.Padding, tc.padding) {
+				t.Fatalf("expected padding %x, got %x", tc.padding, msg.Padding)
+			}
+		})
+	}
+}
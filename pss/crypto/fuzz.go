@@ -0,0 +1,43 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package crypto
+
+// fuzzKey is the symmetric key the fuzzed messages are encrypted with
+var fuzzKey = []byte("0123456789abcdef0123456789abcdef")
+
+// FuzzUnwrap is the go-fuzz entry point of the parsing and the validation of the
+// decrypted pss messages. The input is the decrypted message: the flags, the payload
+// size, the payload, the padding and the signature. It is encrypted before it is
+// unwrapped so that the parsing of messages of peers with a shared key is reached.
+func FuzzUnwrap(data []byte) int {
+	c := newDefaultCryptoBackend()
+	encrypted, err := c.encryptSymmetric(data, fuzzKey)
+	if err != nil {
+		return 0
+	}
+	msg, err := c.UnWrap(encrypted, &UnwrapParams{SymmetricKey: fuzzKey})
+	if err != nil {
+		return 0
+	}
+	if _, err := msg.GetPayload(); err != nil {
+		return 0
+	}
+	msg.GetSender()
+	return 1
+}
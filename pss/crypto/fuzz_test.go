@@ -0,0 +1,66 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package crypto
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/p2p/protocols"
+)
+
+var fuzzCorpus = flag.String("fuzz.corpus", "", "directory to write the corpus seeds of the fuzzers to")
+
+// TestFuzzUnwrapCorpus checks the seeds of FuzzUnwrap, decrypted wrapped messages
+// with and without signature, and writes them to the corpus directory if it is
+// given with the fuzz.corpus flag
+func TestFuzzUnwrapCorpus(t *testing.T) {
+	c := newDefaultCryptoBackend()
+	sender, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seeds [][]byte
+	for _, params := range []*WrapParams{
+		{SymmetricKey: fuzzKey},
+		{SymmetricKey: fuzzKey, Sender: sender},
+	} {
+		for _, payload := range [][]byte{[]byte("xyzzy"), bytes.Repeat([]byte{42}, 300)} {
+			encrypted, err := c.Wrap(payload, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seed, _, err := c.decryptSymmetric(encrypted, fuzzKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if FuzzUnwrap(seed) != 1 {
+				t.Fatalf("seed of payload %x rejected", payload)
+			}
+			seeds = append(seeds, seed)
+		}
+	}
+	if *fuzzCorpus != "" {
+		if err := protocols.WriteFuzzCorpus(*fuzzCorpus, seeds...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -42,6 +42,8 @@
 //
 // Peer keys can manually be added to the pss node through its API calls `pss_setPeerPublicKey` and `pss_setSymmetricKey`. Keys are always coupled with a topic, and the keys will only be valid for these topics.
 //
+// The recipient address of a message can be a partial address, a hint of the leading bytes of the address of the recipient. The length of the hint tells the forwarding nodes how close to the recipient the sender chose to be. With the AddressHintPadding parameter, hints of at most AddressHintMaxBits are padded to the full address length with random bits, and every message keeps a random number of bits of the hint between AddressHintMinBits and AddressHintMaxBits. The number of bits kept is carried in the flags of the message so that it can be routed, but the To field is the same length for all padded messages.
+//
 // CONNECTIONS
 //
 // A "connection" in pss is a purely virtual construct. There is no mechanisms in place to ensure that the remote peer actually is there. In fact, "adding" a peer involves merely the node's opinion that the peer is there. It may issue messages to that remote peer to a directly connected peer, which in turn passes it on. But if it is not present on the network - or if there is no route to it - the message will never reach its destination through mere forwarding.
@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/capability"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pot"
 	"github.com/ethersphere/swarm/pss/message"
@@ -318,6 +319,44 @@ func testForwardMsg(t *testing.T, ps *Pss, c *testCase) {
 	}
 }
 
+// TestIsRelayOrRecipient tests that messages are only withheld from the peers
+// which advertise that they do not forward, and that older peers which do not
+// advertise it either way are assumed to forward
+func TestIsRelayOrRecipient(t *testing.T) {
+	recipient := pot.RandomAddress()
+	other := pot.NewAddressFromBytes(recipient[:])
+	other[0] ^= 0x80
+	msg := newTestMsg(recipient[:])
+
+	newAddr := func(addr pot.Address, bits ...int) *network.BzzAddr {
+		a := network.NewBzzAddr(addr[:], nil)
+		if bits != nil {
+			cp := capability.NewCapability(CapabilityID, 8)
+			for _, b := range bits {
+				cp.Set(b)
+			}
+			a.Capabilities.Add(cp)
+		}
+		return a
+	}
+
+	for _, tc := range []struct {
+		name string
+		addr *network.BzzAddr
+		exp  bool
+	}{
+		{"no pss capability", newAddr(other), true},
+		{"older peer", newAddr(other, capabilitiesSend, capabilitiesReceive), true},
+		{"relay", newAddr(other, capabilitiesForwardFlag, capabilitiesForward), true},
+		{"not a relay", newAddr(other, capabilitiesForwardFlag), false},
+		{"recipient not a relay", newAddr(recipient, capabilitiesForwardFlag), true},
+	} {
+		if got := isRelayOrRecipient(tc.addr, msg); got != tc.exp {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.exp)
+		}
+	}
+}
+
 func addPeers(kad *network.Kademlia, addresses []pot.Address) {
 	for _, a := range addresses {
 		p := newTestDiscoveryPeer(a, kad)
@@ -0,0 +1,56 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package pss
+
+import (
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss/crypto"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+var (
+	// fuzzAddr is the overlay address the fuzzed messages are matched against
+	fuzzAddr = network.RandomBzzAddr().Over()
+	// fuzzCrypto is the backend the payloads of the fuzzed messages are decrypted with
+	fuzzCrypto = crypto.New()
+)
+
+// FuzzPssMessage is the go-fuzz entry point of the decoding of the pss envelopes
+// received from peers and of what is done with them before the payload is handled,
+// see protocols.Spec.FuzzMsg for the format of the input
+func FuzzPssMessage(data []byte) int {
+	msg, err := spec.FuzzMsg(data)
+	if err != nil {
+		return 0
+	}
+	pssmsg := msg.(*message.Message)
+	pssmsg.Digest()
+	pssmsg.Matches(fuzzAddr)
+	_ = pssmsg.String()
+	if isTrace(pssmsg) {
+		var tm traceMsg
+		rlp.DecodeBytes(pssmsg.Payload, &tm)
+	}
+	if !pssmsg.Flags.Raw {
+		key := make([]byte, 32)
+		fuzzCrypto.UnWrap(pssmsg.Payload, &crypto.UnwrapParams{SymmetricKey: key})
+	}
+	return 1
+}
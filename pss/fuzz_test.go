@@ -0,0 +1,73 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build gofuzz
+
+package pss
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+var fuzzCorpus = flag.String("fuzz.corpus", "", "directory to write the corpus seeds of the fuzzers to")
+
+// TestFuzzPssMessageCorpus checks the seeds of FuzzPssMessage, messages like the
+// ones of the pss tests, and writes them to the corpus directory if it is given
+// with the fuzz.corpus flag
+func TestFuzzPssMessageCorpus(t *testing.T) {
+	to := testutil.RandomBytes(1, 32)
+	var seeds [][]byte
+	for _, flags := range []message.Flags{
+		{},
+		{Raw: true},
+		{Symmetric: true},
+		{Raw: true, Padded: true, HintBits: 12},
+		{Raw: true, Trace: true},
+	} {
+		msg := message.New(flags)
+		msg.To = to
+		msg.Expire = 1000
+		msg.Topic = message.NewTopic([]byte("foo"))
+		msg.Payload = testutil.RandomBytes(2, 100)
+		if flags.Trace {
+			payload, err := rlp.EncodeToBytes(&traceMsg{ID: 1, Origin: to, Route: [][]byte{traceLabel(to)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg.Topic = TraceTopic
+			msg.Payload = payload
+		}
+		seed, err := spec.FuzzSeed(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FuzzPssMessage(seed) != 1 {
+			t.Fatalf("seed of message %v rejected", msg)
+		}
+		seeds = append(seeds, seed)
+	}
+	if *fuzzCorpus != "" {
+		if err := protocols.WriteFuzzCorpus(*fuzzCorpus, seeds...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -47,6 +47,20 @@ const (
 	defaultSymKeyExpiryTimeout  = 1000 * 10 // ms to wait before allowing garbage collection of an expired symkey
 	defaultSymKeySendLimit      = 256       // amount of messages a symkey is valid for
 	defaultSymKeyCapacity       = 4         // max number of symkeys to store/send simultaneously
+	defaultSymKeyRenewLimit     = 32        // remaining messages of the outgoing symkeys at which new ones are requested
+)
+
+// Symmetric key rotation events
+const (
+	RotationRequested = "requested" // new keys were requested from the peer
+	RotationRenewed   = "renewed"   // new keys were received from the peer
+	RotationFailed    = "failed"    // the request for new keys could not be sent
+)
+
+// Symmetric key rotation reasons
+const (
+	RotationReasonLimit = "limit" // the outgoing keys are running out of messages
+	RotationReasonAge   = "age"   // the outgoing keys are older than the renewal age
 )
 
 // symmetric key exchange message payload
@@ -62,11 +76,31 @@ type handshakeMsg struct {
 type handshakeKey struct {
 	symKeyID  *string
 	pubKeyID  *string
+	topic     message.Topic
+	in        bool // issued by this node, used by the peer to send messages to us
 	limit     uint16
 	count     uint16
+	createdAt time.Time
 	expiredAt time.Time
 }
 
+// pending renewal of the outgoing keys of a peer and topic
+type renewal struct {
+	reason      string
+	requestedAt time.Time
+}
+
+// HandshakeRotation reports the progress of the renewal of the
+// symmetric keys used for sending to a peer (public key) on a topic
+type HandshakeRotation struct {
+	Event  string        `json:"event"`
+	Reason string        `json:"reason"`
+	PubKey string        `json:"pubkey"`
+	Topic  message.Topic `json:"topic"`
+	Keys   []string      `json:"keys,omitempty"` // ids of the received keys, only set on renewed events
+	Time   time.Time     `json:"time"`
+}
+
 // container for all in- and outgoing keys
 // for one particular peer (public key) and topic
 type handshake struct {
@@ -84,11 +118,20 @@ type handshake struct {
 //
 // SymKeyCapacity: Ideal (and maximum) amount of symmetric keys
 // held per direction per peer (default 4)
+//
+// SymKeyRenewLimit: Amount of messages left on the valid outgoing
+// symmetric keys of a peer at which new keys are requested (default 32)
+//
+// SymKeyRenewAge: Age of the most recent outgoing symmetric key of a
+// peer after which new keys are requested, and the older keys expire
+// once they arrive. 0 disables age based renewal (default 0)
 type HandshakeParams struct {
 	SymKeyRequestTimeout time.Duration
 	SymKeyExpiryTimeout  time.Duration
 	SymKeySendLimit      uint16
 	SymKeyCapacity       uint8
+	SymKeyRenewLimit     uint16
+	SymKeyRenewAge       time.Duration
 }
 
 // Sane defaults for HandshakeController initialization
@@ -98,6 +141,7 @@ func NewHandshakeParams() *HandshakeParams {
 		SymKeyExpiryTimeout:  defaultSymKeyExpiryTimeout * time.Millisecond,
 		SymKeySendLimit:      defaultSymKeySendLimit,
 		SymKeyCapacity:       defaultSymKeyCapacity,
+		SymKeyRenewLimit:     defaultSymKeyRenewLimit,
 	}
 }
 
@@ -112,9 +156,15 @@ type HandshakeController struct {
 	symKeyExpiryTimeout  time.Duration
 	symKeySendLimit      uint16
 	symKeyCapacity       uint8
+	symKeyRenewLimit     uint16
+	symKeyRenewAge       time.Duration
 	symKeyIndex          map[string]*handshakeKey
 	handshakes           map[string]map[message.Topic]*handshake
+	renewals             map[string]map[message.Topic]*renewal // pending renewals of outgoing keys
 	deregisterFuncs      map[message.Topic]func()
+	rotationSubsMu       sync.Mutex                       // protects rotationSubs
+	rotationSubs         map[int]func(*HandshakeRotation) // callbacks of rotation subscriptions
+	rotationSubsID       int                              // id of the next rotation subscription
 }
 
 // Attach HandshakeController to pss node
@@ -128,9 +178,13 @@ func SetHandshakeController(pss *Pss, params *HandshakeParams) error {
 		symKeyExpiryTimeout:  params.SymKeyExpiryTimeout,
 		symKeySendLimit:      params.SymKeySendLimit,
 		symKeyCapacity:       params.SymKeyCapacity,
+		symKeyRenewLimit:     params.SymKeyRenewLimit,
+		symKeyRenewAge:       params.SymKeyRenewAge,
 		symKeyIndex:          make(map[string]*handshakeKey),
 		handshakes:           make(map[string]map[message.Topic]*handshake),
+		renewals:             make(map[string]map[message.Topic]*renewal),
 		deregisterFuncs:      make(map[message.Topic]func()),
+		rotationSubs:         make(map[int]func(*HandshakeRotation)),
 	}
 	api := &HandshakeAPI{
 		namespace: "pss",
@@ -189,7 +243,8 @@ func (ctl *HandshakeController) updateKeys(pubkeyid string, topic *message.Topic
 		ctl.handshakes[pubkeyid][*topic] = &handshake{}
 	}
 	var keystore *[]handshakeKey
-	expire := time.Now()
+	now := time.Now()
+	expire := now
 	if in {
 		keystore = &(ctl.handshakes[pubkeyid][*topic].inKeys)
 	} else {
@@ -201,9 +256,12 @@ func (ctl *HandshakeController) updateKeys(pubkeyid string, topic *message.Topic
 	}
 	for i := 0; i < len(symkeyids); i++ {
 		storekey := handshakeKey{
-			symKeyID: &symkeyids[i],
-			pubKeyID: &pubkeyid,
-			limit:    limit,
+			symKeyID:  &symkeyids[i],
+			pubKeyID:  &pubkeyid,
+			topic:     *topic,
+			in:        in,
+			limit:     limit,
+			createdAt: now,
 		}
 		*keystore = append(*keystore, storekey)
 		ctl.pss.mx.Lock()
@@ -328,9 +386,154 @@ func (ctl *HandshakeController) registerSymKeyUse(symkeyid string) error {
 	receiver := common.ToHex(ctl.pss.Crypto.SerializePublicKey(ctl.pss.PublicKey()))
 	log.Trace("increment symkey recv use", "symsymkeyid", symkeyid, "count", symKey.count, "limit", symKey.limit, "receiver", receiver)
 
+	// keys we send with are renewed by requesting new ones from the peer
+	// before the current ones run out, so that sending is never interrupted
+	if !symKey.in {
+		ctl.checkRenewalNoLock(*symKey.pubKeyID, symKey.topic)
+	}
+
 	return nil
 }
 
+// Checks whether the outgoing keys of a peer (public key) and topic need
+// to be renewed and requests new keys from the peer if so
+// Only one request per peer and topic is in flight at a time, unless the
+// previous one did not get a reply within the symkey request timeout
+func (ctl *HandshakeController) checkRenewalNoLock(pubkeyid string, topic message.Topic) {
+	handshake := ctl.handshakes[pubkeyid][topic]
+	if handshake == nil {
+		return
+	}
+	now := time.Now()
+	var remaining int
+	var newest time.Time
+	for _, key := range handshake.outKeys {
+		if key.limit <= key.count || (!key.expiredAt.IsZero() && key.expiredAt.Before(now)) {
+			continue
+		}
+		remaining += int(key.limit - key.count)
+		if key.createdAt.After(newest) {
+			newest = key.createdAt
+		}
+	}
+	var reason string
+	if remaining < int(ctl.symKeyRenewLimit) {
+		reason = RotationReasonLimit
+	} else if ctl.symKeyRenewAge > 0 && now.Sub(newest) > ctl.symKeyRenewAge {
+		reason = RotationReasonAge
+	} else {
+		return
+	}
+	if r := ctl.renewals[pubkeyid][topic]; r != nil && now.Sub(r.requestedAt) < ctl.symKeyRequestTimeout {
+		return
+	}
+	if _, ok := ctl.renewals[pubkeyid]; !ok {
+		ctl.renewals[pubkeyid] = make(map[message.Topic]*renewal)
+	}
+	ctl.renewals[pubkeyid][topic] = &renewal{
+		reason:      reason,
+		requestedAt: now,
+	}
+	go ctl.requestRenewal(pubkeyid, topic, reason)
+}
+
+// Sends a request for new keys to the peer (public key) without offering
+// any keys, the peer replies with fresh keys carrying its current limit
+func (ctl *HandshakeController) requestRenewal(pubkeyid string, topic message.Topic, reason string) {
+	log.Debug("requesting symkey renewal", "pubkey", pubkeyid, "topic", topic, "reason", reason)
+	ctl.notifyRotation(&HandshakeRotation{
+		Event:  RotationRequested,
+		Reason: reason,
+		PubKey: pubkeyid,
+		Topic:  topic,
+		Time:   time.Now(),
+	})
+	err := func() error {
+		keymsg := &handshakeMsg{
+			From:    ctl.pss.BaseAddr(),
+			Request: ctl.symKeyCapacity,
+			Limit:   ctl.symKeySendLimit,
+			Topic:   topic,
+		}
+		keybytes, err := rlp.EncodeToBytes(keymsg)
+		if err != nil {
+			return fmt.Errorf("rlp keymsg encode fail: %v", err)
+		}
+		return ctl.pss.SendAsym(pubkeyid, topic, keybytes)
+	}()
+	if err == nil {
+		return
+	}
+	log.Warn("symkey renewal request failed", "pubkey", pubkeyid, "topic", topic, "err", err)
+	ctl.lock.Lock()
+	delete(ctl.renewals[pubkeyid], topic)
+	ctl.lock.Unlock()
+	ctl.notifyRotation(&HandshakeRotation{
+		Event:  RotationFailed,
+		Reason: reason,
+		PubKey: pubkeyid,
+		Topic:  topic,
+		Time:   time.Now(),
+	})
+}
+
+// Completes a pending renewal of the outgoing keys of a peer (public key)
+// and topic once new keys are received
+// Keys replaced because of their age expire after the symkey expiry timeout,
+// keys replaced because of their limit stay valid until they are used up
+func (ctl *HandshakeController) completeRenewal(pubkeyid string, topic message.Topic, symkeyids []string) {
+	ctl.lock.Lock()
+	r := ctl.renewals[pubkeyid][topic]
+	if r == nil {
+		ctl.lock.Unlock()
+		return
+	}
+	delete(ctl.renewals[pubkeyid], topic)
+	if r.reason == RotationReasonAge {
+		expire := time.Now().Add(ctl.symKeyExpiryTimeout)
+		outKeys := ctl.handshakes[pubkeyid][topic].outKeys
+		for i := range outKeys {
+			if outKeys[i].createdAt.Before(r.requestedAt) && outKeys[i].expiredAt.IsZero() {
+				outKeys[i].expiredAt = expire
+			}
+		}
+	}
+	ctl.lock.Unlock()
+
+	log.Debug("symkeys renewed", "pubkey", pubkeyid, "topic", topic, "reason", r.reason, "symkeys", symkeyids)
+	ctl.notifyRotation(&HandshakeRotation{
+		Event:  RotationRenewed,
+		Reason: r.reason,
+		PubKey: pubkeyid,
+		Topic:  topic,
+		Keys:   symkeyids,
+		Time:   time.Now(),
+	})
+}
+
+// Registers a callback for symmetric key rotation events
+// Returns the function to deregister it
+func (ctl *HandshakeController) subscribeRotations(f func(*HandshakeRotation)) func() {
+	ctl.rotationSubsMu.Lock()
+	defer ctl.rotationSubsMu.Unlock()
+	id := ctl.rotationSubsID
+	ctl.rotationSubsID++
+	ctl.rotationSubs[id] = f
+	return func() {
+		ctl.rotationSubsMu.Lock()
+		defer ctl.rotationSubsMu.Unlock()
+		delete(ctl.rotationSubs, id)
+	}
+}
+
+func (ctl *HandshakeController) notifyRotation(rotation *HandshakeRotation) {
+	ctl.rotationSubsMu.Lock()
+	defer ctl.rotationSubsMu.Unlock()
+	for _, f := range ctl.rotationSubs {
+		f(rotation)
+	}
+}
+
 // Handle incoming key exchange message
 // Add keys received from peer to store
 // and enerate and send the amount of keys requested by peer
@@ -359,6 +562,7 @@ func (ctl *HandshakeController) handleKeys(pubkeyid string, keymsg *handshakeMsg
 			ctl.updateKeys(pubkeyid, &keymsg.Topic, false, sendsymkeyids, keymsg.Limit)
 
 			ctl.alertHandshake(pubkeyid, sendsymkeyids)
+			ctl.completeRenewal(pubkeyid, keymsg.Topic, sendsymkeyids)
 		}
 	}
 
@@ -512,6 +716,36 @@ func (api *HandshakeAPI) Handshake(pubkeyid string, topic message.Topic, sync bo
 	return keys, nil
 }
 
+// Subscribe to the renewals of the symmetric keys used for sending
+// to peers under the handshake scheme
+//
+// Renewals are initiated automatically when the valid outgoing keys of
+// a peer and topic run low on messages or reach the renewal age
+func (api *HandshakeAPI) HandshakeRotations(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, fmt.Errorf("Subscribe not supported")
+	}
+
+	sub := notifier.CreateSubscription()
+	deregf := api.ctrl.subscribeRotations(func(rotation *HandshakeRotation) {
+		if err := notifier.Notify(sub.ID, rotation); err != nil {
+			log.Warn(fmt.Sprintf("notification on handshake rotation rpc (sub %v) failed!", sub.ID))
+		}
+	})
+	go func() {
+		defer deregf()
+		select {
+		case err := <-sub.Err():
+			log.Warn(fmt.Sprintf("caught subscription error in handshake rotations: %v", err))
+		case <-notifier.Closed():
+			log.Warn("rpc sub notifier closed")
+		}
+	}()
+
+	return sub, nil
+}
+
 // Activate handshake functionality on a topic
 func (api *HandshakeAPI) AddHandshake(topic message.Topic) error {
 	api.ctrl.deregisterFuncs[topic] = api.ctrl.pss.Register(&topic, NewHandler(api.ctrl.handler))
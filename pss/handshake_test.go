@@ -22,7 +22,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/testutil"
 )
 
 // asymmetrical key exchange between two directly connected peers
@@ -264,3 +268,133 @@ func testHandshake(t *testing.T) {
 		t.Fatalf("pss clean count mismatch; expected 1, got %d", cleancount)
 	}
 }
+
+// TestHandshakeRotation tests that new symmetric keys are requested once the outgoing
+// keys of a peer run low on messages, and that the old keys stay valid after renewal
+func TestHandshakeRotation(t *testing.T) {
+	privkey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, nil)
+	defer ps.Stop()
+
+	params := NewHandshakeParams()
+	params.SymKeyRenewLimit = 6
+	if err := SetHandshakeController(ps, params); err != nil {
+		t.Fatal(err)
+	}
+	ctl := ctrlSingleton
+	api := &HandshakeAPI{
+		namespace: "pss",
+		ctrl:      ctl,
+	}
+
+	peerkey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic := message.NewTopic([]byte("foo:42"))
+	addr := PssAddress(testutil.RandomBytes(1, 32))
+	if err := ps.SetPeerPublicKey(&peerkey.PublicKey, topic, addr); err != nil {
+		t.Fatal(err)
+	}
+	pubkeyid := common.ToHex(ps.Crypto.SerializePublicKey(&peerkey.PublicKey))
+
+	rotations := make(chan *HandshakeRotation, 10)
+	defer ctl.subscribeRotations(func(r *HandshakeRotation) {
+		rotations <- r
+	})()
+	expectRotation := func(event string) *HandshakeRotation {
+		t.Helper()
+		select {
+		case r := <-rotations:
+			if r.Event != event {
+				t.Fatalf("expected %s rotation event, got %s", event, r.Event)
+			}
+			if r.PubKey != pubkeyid || r.Topic != topic {
+				t.Fatalf("unexpected rotation peer %s topic %s", r.PubKey, r.Topic)
+			}
+			return r
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s rotation event", event)
+		}
+		return nil
+	}
+	expectNoRotation := func() {
+		t.Helper()
+		select {
+		case r := <-rotations:
+			t.Fatalf("unexpected rotation event %s", r.Event)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// the peer hands us a key valid for 8 messages
+	if err := ctl.handleKeys(pubkeyid, &handshakeMsg{
+		From:  addr,
+		Limit: 8,
+		Keys:  [][]byte{testutil.RandomBytes(2, 32)},
+		Topic: topic,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := api.GetHandshakeKeys(pubkeyid, topic, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 outgoing key, got %d", len(keys))
+	}
+	symkeyid := keys[0]
+
+	// 6 messages left is not below the renew limit
+	for i := 0; i < 2; i++ {
+		if err := api.SendSym(symkeyid, topic, []byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectNoRotation()
+
+	if err := api.SendSym(symkeyid, topic, []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if r := expectRotation(RotationRequested); r.Reason != RotationReasonLimit {
+		t.Fatalf("expected rotation reason %s, got %s", RotationReasonLimit, r.Reason)
+	}
+
+	// no further requests while the renewal is pending
+	if err := api.SendSym(symkeyid, topic, []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	expectNoRotation()
+
+	// the peer replies with a new key
+	if err := ctl.handleKeys(pubkeyid, &handshakeMsg{
+		From:  addr,
+		Limit: 8,
+		Keys:  [][]byte{testutil.RandomBytes(3, 32)},
+		Topic: topic,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if r := expectRotation(RotationRenewed); len(r.Keys) != 1 {
+		t.Fatalf("expected 1 renewed key, got %d", len(r.Keys))
+	}
+
+	// the old key can still be used for the messages in flight
+	capacity, err := api.GetHandshakeKeyCapacity(symkeyid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity != 4 {
+		t.Fatalf("expected capacity 4 of the old key, got %d", capacity)
+	}
+	keys, err = api.GetHandshakeKeys(pubkeyid, topic, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 outgoing keys, got %d", len(keys))
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// hintPadding pads short recipient address hints of outgoing messages to the
+// full address length with random bits, and gives every message a random number
+// of bits of the hint within a range, so that neither the length of the To field
+// nor a fixed hint length reveals the proximity of the recipient chosen by the sender
+type hintPadding struct {
+	minBits int // minimum number of bits of the recipient address kept
+	maxBits int // maximum number of bits of the recipient address kept, hints longer than this are not padded
+}
+
+// pad returns the padded address and the number of bits of the hint it keeps,
+// or ok false if the hint is too long to be padded
+func (h *hintPadding) pad(hint PssAddress) (to []byte, bits int, ok bool, err error) {
+	bits = len(hint) * 8
+	if bits > h.maxBits {
+		return nil, 0, false, nil
+	}
+	// a hint shorter than the minimum keeps all of its bits
+	if bits > h.minBits {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(bits-h.minBits+1)))
+		if err != nil {
+			return nil, 0, false, err
+		}
+		bits = h.minBits + int(n.Int64())
+	}
+	to = make([]byte, addressLength)
+	if _, err := rand.Read(to); err != nil {
+		return nil, 0, false, err
+	}
+	n := bits / 8
+	copy(to, hint[:n])
+	if rest := uint(bits % 8); rest > 0 {
+		mask := byte(0xff) << (8 - rest)
+		to[n] = hint[n]&mask | to[n]&^mask
+	}
+	return to, bits, true, nil
+}
+
+// setRecipient sets the recipient address of an outgoing message,
+// padded if address hint padding is enabled and the hint is short enough
+func (p *Pss) setRecipient(msg *message.Message, address PssAddress) error {
+	if p.hintPad == nil {
+		msg.To = address
+		return nil
+	}
+	to, bits, ok, err := p.hintPad.pad(address)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		msg.To = address
+		return nil
+	}
+	msg.To = to
+	msg.Flags.Padded = true
+	msg.Flags.HintBits = uint8(bits)
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"testing"
+
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// TestAddressHintPadding tests that short recipient address hints are padded to the full
+// address length with a random number of bits kept within the range, and that the padded
+// messages are matched by the recipient and not by other nodes
+func TestAddressHintPadding(t *testing.T) {
+	localaddr := network.RandomBzzAddr().Over()
+	kad := network.NewKademlia(localaddr, network.NewKadParams())
+	privkey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := NewParams().WithPrivateKey(privkey)
+	params.AddressHintPadding = true
+	params.AddressHintMinBits = 3
+	params.AddressHintMaxBits = message.MaxHintBits + 1
+	if _, err := New(kad, params); err == nil {
+		t.Fatal("expected error creating pss with invalid address hint range")
+	}
+	params.AddressHintMaxBits = 12
+	ps, err := New(kad, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the other node differs from the local one in the first bit
+	otheraddr := make([]byte, len(localaddr))
+	copy(otheraddr, localaddr)
+	otheraddr[0] ^= 0x80
+
+	hints := make(map[uint8]bool)
+	for i := 0; i < 100; i++ {
+		msg := message.New(message.Flags{})
+		if err := ps.setRecipient(msg, PssAddress(localaddr[:1])); err != nil {
+			t.Fatal(err)
+		}
+		if len(msg.To) != addressLength {
+			t.Fatalf("expected padded address of length %d, got %d", addressLength, len(msg.To))
+		}
+		if !msg.Flags.Padded || msg.Flags.HintBits < 3 || msg.Flags.HintBits > 8 {
+			t.Fatalf("expected padded hint of 3 to 8 bits, got %+v", msg.Flags)
+		}
+		hints[msg.Flags.HintBits] = true
+
+		// the flags are kept on the wire
+		b, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded message.Message
+		if err := rlp.DecodeBytes(b, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Flags != msg.Flags || !bytes.Equal(decoded.To, msg.To) {
+			t.Fatalf("expected decoded message %v with flags %+v, got %v with flags %+v", msg, msg.Flags, &decoded, decoded.Flags)
+		}
+
+		if !ps.isSelfPossibleRecipient(&decoded, false) {
+			t.Fatalf("expected padded message to %x with %d bits to be possibly for %x", decoded.To, decoded.Flags.HintBits, localaddr)
+		}
+		if decoded.Matches(otheraddr) {
+			t.Fatalf("expected padded message to %x with %d bits not to match %x", decoded.To, decoded.Flags.HintBits, otheraddr)
+		}
+		if decoded.Luminosity() >= addressLength*8 {
+			t.Fatalf("expected padded message to be partially addressed, got luminosity %d", decoded.Luminosity())
+		}
+	}
+	if len(hints) < 2 {
+		t.Fatalf("expected random hint lengths, got %v", hints)
+	}
+
+	// hints longer than the range are not padded
+	msg := message.New(message.Flags{})
+	if err := ps.setRecipient(msg, PssAddress(localaddr[:2])); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Flags.Padded || !bytes.Equal(msg.To, localaddr[:2]) {
+		t.Fatalf("expected hint %x not padded, got %x with flags %+v", localaddr[:2], msg.To, msg.Flags)
+	}
+}
@@ -144,10 +144,10 @@ func (ks *KeyStore) getPeerAddress(keyid string, topic message.Topic) (PssAddres
 }
 
 // Attempt to decrypt, validate and unpack a symmetrically encrypted message.
-// If successful, returns the payload of the message and the id
-// of the symmetric key used to decrypt the message.
+// If successful, returns the payload of the message, the id of the symmetric key
+// used to decrypt the message and whether the payload is a reference to the content in swarm.
 // It fails if decryption of the message fails or if the message is corrupted/not valid.
-func (ks *KeyStore) processSym(pssMsg *message.Message) ([]byte, string, PssAddress, error) {
+func (ks *KeyStore) processSym(pssMsg *message.Message) ([]byte, string, PssAddress, bool, error) {
 	metrics.GetOrRegisterCounter("pss/process/sym", nil).Inc(1)
 
 	for i := ks.symKeyDecryptCacheCursor; i > ks.symKeyDecryptCacheCursor-cap(ks.symKeyDecryptCache) && i > 0; i-- {
@@ -165,7 +165,7 @@ func (ks *KeyStore) processSym(pssMsg *message.Message) ([]byte, string, PssAddr
 		}
 		payload, validateError := recvmsg.GetPayload()
 		if validateError != nil {
-			return nil, "", nil, validateError
+			return nil, "", nil, false, validateError
 		}
 
 		var from PssAddress
@@ -176,16 +176,16 @@ func (ks *KeyStore) processSym(pssMsg *message.Message) ([]byte, string, PssAddr
 		ks.mx.RUnlock()
 		ks.symKeyDecryptCacheCursor++
 		ks.symKeyDecryptCache[ks.symKeyDecryptCacheCursor%cap(ks.symKeyDecryptCache)] = symkeyid
-		return payload, *symkeyid, from, nil
+		return payload, *symkeyid, from, recvmsg.IsReference(), nil
 	}
-	return nil, "", nil, errors.New("could not decrypt message")
+	return nil, "", nil, false, errors.New("could not decrypt message")
 }
 
 // Attempt to decrypt, validate and unpack an asymmetrically encrypted message.
-// If successful, returns the payload of the message and the hex representation of
-// the public key used to decrypt the message.
+// If successful, returns the payload of the message, the hex representation of the public key
+// used to decrypt the message and whether the payload is a reference to the content in swarm.
 // It fails if decryption of message fails, or if the message is corrupted.
-func (p *Pss) processAsym(pssMsg *message.Message) ([]byte, string, PssAddress, error) {
+func (p *Pss) processAsym(pssMsg *message.Message) ([]byte, string, PssAddress, bool, error) {
 	metrics.GetOrRegisterCounter("pss/process/asym", nil).Inc(1)
 
 	unwrapParams := &crypto.UnwrapParams{
@@ -193,12 +193,12 @@ func (p *Pss) processAsym(pssMsg *message.Message) ([]byte, string, PssAddress,
 	}
 	recvmsg, err := p.Crypto.UnWrap(pssMsg.Payload, unwrapParams)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("could not decrypt message: %s", err)
+		return nil, "", nil, false, fmt.Errorf("could not decrypt message: %s", err)
 	}
 
 	payload, validateError := recvmsg.GetPayload()
 	if validateError != nil {
-		return nil, "", nil, validateError
+		return nil, "", nil, false, validateError
 	}
 
 	pubkeyid := common.ToHex(p.Crypto.SerializePublicKey(recvmsg.GetSender()))
@@ -208,7 +208,7 @@ func (p *Pss) processAsym(pssMsg *message.Message) ([]byte, string, PssAddress,
 		from = p.pubKeyPool[pubkeyid][pssMsg.Topic].address
 	}
 	p.mx.RUnlock()
-	return payload, pubkeyid, from, nil
+	return payload, pubkeyid, from, recvmsg.IsReference(), nil
 }
 
 // Symkey garbage collection
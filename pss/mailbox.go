@@ -0,0 +1,188 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss/mailbox"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// DefaultMailboxFetchInterval is the interval the watched mailboxes are fetched with
+const DefaultMailboxFetchInterval = 10 * time.Minute
+
+// ErrNoMailbox is returned when depositing a message without a mailbox set,
+// or when fetching messages without the inboxes set
+var ErrNoMailbox = errors.New("no mailbox")
+
+// SetMailbox sets the mailbox that messages for offline recipients are deposited in
+func (p *Pss) SetMailbox(mb *mailbox.Mailbox) {
+	p.mailboxMu.Lock()
+	defer p.mailboxMu.Unlock()
+	p.mailbox = mb
+}
+
+// SetInboxes sets the store and the feeds that the inboxes of this node in the
+// mailboxes of other nodes are read with. Fetching messages does not require a
+// mailbox of this node, so nodes that do not deposit messages for others can
+// fetch the messages deposited for them. The inboxes keep their latest fetched
+// entries in the state store, if it is not nil.
+func (p *Pss) SetInboxes(store mailbox.Store, feeds mailbox.Feeds, stateStore state.Store) {
+	p.mailboxMu.Lock()
+	defer p.mailboxMu.Unlock()
+	p.newInbox = func(owner common.Address, recipient []byte) *mailbox.Inbox {
+		return mailbox.NewInbox(store, feeds, stateStore, owner, recipient)
+	}
+	p.inboxes = make(map[common.Address]*mailbox.Inbox)
+}
+
+// SendMailbox encrypts the message for the public key and deposits it in the mailbox
+// of this node instead of sending it, for the recipient to fetch it when it is online
+func (p *Pss) SendMailbox(ctx context.Context, pubkeyid string, topic message.Topic, msg []byte) (storage.Address, error) {
+	p.mailboxMu.Lock()
+	mb := p.mailbox
+	p.mailboxMu.Unlock()
+	if mb == nil {
+		return nil, ErrNoMailbox
+	}
+	key := common.FromHex(pubkeyid)
+	if _, err := p.Crypto.UnmarshalPublicKey(key); err != nil {
+		return nil, fmt.Errorf("Cannot unmarshal pubkey: %x", pubkeyid)
+	}
+	// the address hint of the recipient is not needed to fetch the message,
+	// but it is kept for the message to be the same as a sent one
+	to := PssAddress{}
+	if psp, ok := p.getPeerPub(pubkeyid, topic); ok {
+		to = psp.address
+	}
+	pssMsg, err := p.wrap(to, topic, msg, true, key, false)
+	if err != nil {
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(pssMsg)
+	if err != nil {
+		return nil, err
+	}
+	return mb.Deposit(ctx, key, data)
+}
+
+// FetchMailbox fetches the messages deposited for this node in the mailbox of the owner
+// since the last fetch, dispatches them to the handlers of their topics and returns
+// the number of messages that were dispatched
+func (p *Pss) FetchMailbox(ctx context.Context, owner common.Address) (int, error) {
+	inbox, err := p.getInbox(owner)
+	if err != nil {
+		return 0, err
+	}
+	msgs, err := inbox.Fetch(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, data := range msgs {
+		var pssMsg message.Message
+		if err := rlp.DecodeBytes(data, &pssMsg); err != nil {
+			log.Warn("pss mailbox message decode", "owner", owner, "err", err)
+			continue
+		}
+		if err := p.processMailbox(&pssMsg); err != nil {
+			log.Warn("pss mailbox message process", "owner", owner, "topic", label(pssMsg.Topic[:]), "err", err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// WatchMailbox fetches the messages deposited for this node in the mailbox of the owner
+// right away, every interval, and when the neighbourhood of the node changes as it
+// connects to the network again. It returns a function that stops watching
+func (p *Pss) WatchMailbox(owner common.Address, interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	depthC, unsubscribe := p.SubscribeToNeighbourhoodDepthChange()
+	go func() {
+		defer unsubscribe()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if _, err := p.FetchMailbox(ctx, owner); err != nil {
+				log.Debug("pss mailbox fetch", "owner", owner, "err", err)
+			}
+			cancel()
+			select {
+			case <-ticker.C:
+			case <-depthC:
+			case <-quit:
+				return
+			case <-p.quitC:
+				return
+			}
+		}
+	}()
+	return func() { close(quit) }
+}
+
+// getInbox returns the inbox of this node in the mailbox of the owner
+func (p *Pss) getInbox(owner common.Address) (*mailbox.Inbox, error) {
+	p.mailboxMu.Lock()
+	defer p.mailboxMu.Unlock()
+	if p.newInbox == nil {
+		return nil, ErrNoMailbox
+	}
+	inbox, ok := p.inboxes[owner]
+	if !ok {
+		inbox = p.newInbox(owner, p.Crypto.SerializePublicKey(&p.privateKey.PublicKey))
+		p.inboxes[owner] = inbox
+	}
+	return inbox, nil
+}
+
+// processMailbox decrypts a message fetched from a mailbox and dispatches it to the
+// handlers of its topic. Unlike received messages, it is not forwarded and does not expire
+func (p *Pss) processMailbox(pssMsg *message.Message) error {
+	if pssMsg.Flags.Raw {
+		return errors.New("raw message in mailbox")
+	}
+	keyFunc := p.processAsym
+	asymmetric := true
+	if pssMsg.Flags.Symmetric {
+		keyFunc = p.processSym
+		asymmetric = false
+	}
+	payload, keyid, from, reference, err := keyFunc(pssMsg)
+	if err != nil {
+		return err
+	}
+	metrics.GetOrRegisterCounter("pss/mailbox/process", nil).Inc(1)
+	if reference {
+		p.dispatchContent(pssMsg.Topic, payload, from, false, asymmetric, keyid)
+		return nil
+	}
+	p.executeHandlers(pssMsg.Topic, payload, from, false, false, asymmetric, keyid)
+	return nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package mailbox stores pss messages for recipients that are offline in swarm,
+// so that they can fetch the messages they missed when they are back online.
+//
+// The messages deposited for a recipient are chained in swarm content, each
+// entry referencing the one deposited before it. The reference of the most
+// recent entry is published in a feed of the depositing node, with a topic
+// derived from the public key of the recipient. The recipient looks up the
+// feed and walks the chain back to the last entry it has seen, which is kept
+// in a state store so that messages are not fetched again after a restart.
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+)
+
+// topicName is mixed into the topics of mailbox feeds
+const topicName = "pss-mailbox"
+
+// DefaultMaxFetch is the maximum number of entries fetched from a mailbox at once
+const DefaultMaxFetch = 256
+
+// ErrEmptyRecipient is returned when a message is deposited for a recipient without a public key
+var ErrEmptyRecipient = errors.New("empty recipient")
+
+// Store stores and retrieves the content of mailbox entries
+type Store interface {
+	Put(ctx context.Context, data []byte) (storage.Address, error)
+	Get(ctx context.Context, addr storage.Address) ([]byte, error)
+}
+
+// Feeds publishes the heads of the mailboxes of the local node
+// and looks up the heads of the mailboxes of any node
+type Feeds interface {
+	// Owner returns the address of the user the published feeds belong to
+	Owner() common.Address
+	// Publish publishes data as the latest update of the feed with the topic
+	Publish(ctx context.Context, topic feed.Topic, data []byte) error
+	// Latest returns the data of the latest update of the feed, or nil if it has no updates
+	Latest(ctx context.Context, fd *feed.Feed) ([]byte, error)
+}
+
+// entry is a message in a mailbox, stored in swarm
+type entry struct {
+	Prev    []byte // reference of the entry deposited before, nil for the first one
+	Time    uint64 // unix time of the deposit
+	Message []byte
+}
+
+// Topic returns the topic of the mailbox feeds of the recipient with the public key
+func Topic(recipient []byte) feed.Topic {
+	topic, _ := feed.NewTopic(topicName, crypto.Keccak256(recipient))
+	return topic
+}
+
+// Mailbox deposits messages for recipients in the mailboxes of the local node
+type Mailbox struct {
+	store Store
+	feeds Feeds
+	state state.Store                    // keeps the latest fetched entries of inboxes, nil if they are not kept
+	mu    sync.Mutex                     // serializes deposits, so that entries are chained in order
+	heads map[feed.Topic]storage.Address // latest deposited entries by mailbox topic
+}
+
+// New returns a new Mailbox that stores entries in the store and publishes
+// the mailbox heads in the feeds. The inboxes of the mailbox keep their
+// latest fetched entries in the state store, if it is not nil.
+func New(store Store, feeds Feeds, stateStore state.Store) *Mailbox {
+	return &Mailbox{
+		store: store,
+		feeds: feeds,
+		state: stateStore,
+		heads: make(map[feed.Topic]storage.Address),
+	}
+}
+
+// Owner returns the address of the node whose mailboxes messages are deposited in
+func (m *Mailbox) Owner() common.Address {
+	return m.feeds.Owner()
+}
+
+// Deposit stores the message in the mailbox of the recipient with the public key
+// and returns the reference of its entry
+func (m *Mailbox) Deposit(ctx context.Context, recipient []byte, msg []byte) (ref storage.Address, err error) {
+	defer func() {
+		if err != nil {
+			metrics.GetOrRegisterCounter("pss/mailbox/deposit/err", nil).Inc(1)
+		}
+	}()
+	if len(recipient) == 0 {
+		return nil, ErrEmptyRecipient
+	}
+	topic := Topic(recipient)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.heads[topic]
+	if !ok {
+		// the mailbox may have entries from a previous run
+		prev, err = m.feeds.Latest(ctx, &feed.Feed{
+			Topic: topic,
+			User:  m.feeds.Owner(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := rlp.EncodeToBytes(&entry{
+		Prev:    prev,
+		Time:    uint64(time.Now().Unix()),
+		Message: msg,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ref, err = m.store.Put(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.feeds.Publish(ctx, topic, ref); err != nil {
+		return nil, err
+	}
+	m.heads[topic] = ref
+	metrics.GetOrRegisterCounter("pss/mailbox/deposit", nil).Inc(1)
+	return ref, nil
+}
+
+// Inbox returns an Inbox of the messages deposited for the recipient
+// with the public key in the mailbox of the owner
+func (m *Mailbox) Inbox(owner common.Address, recipient []byte) *Inbox {
+	return NewInbox(m.store, m.feeds, m.state, owner, recipient)
+}
+
+// Inbox fetches the messages deposited for a recipient in the mailbox of a node
+type Inbox struct {
+	store    Store
+	feeds    Feeds
+	state    state.Store // keeps the latest fetched entry, nil if it is not kept
+	feed     feed.Feed
+	maxFetch int
+	mu       sync.Mutex      // serializes fetches
+	last     storage.Address // reference of the latest fetched entry
+}
+
+// NewInbox returns a new Inbox of the messages deposited for the recipient
+// with the public key in the mailbox of the owner. If the state store is not
+// nil, the inbox continues from the latest entry fetched by a previous one.
+func NewInbox(store Store, feeds Feeds, stateStore state.Store, owner common.Address, recipient []byte) *Inbox {
+	i := &Inbox{
+		store: store,
+		feeds: feeds,
+		state: stateStore,
+		feed: feed.Feed{
+			Topic: Topic(recipient),
+			User:  owner,
+		},
+		maxFetch: DefaultMaxFetch,
+	}
+	if stateStore != nil {
+		if err := stateStore.Get(i.stateKey(), &i.last); err != nil && err != state.ErrNotFound {
+			log.Error("pss mailbox inbox load", "owner", owner, "err", err)
+		}
+	}
+	return i
+}
+
+// stateKey returns the state store key of the latest fetched entry
+func (i *Inbox) stateKey() string {
+	return i.feed.User.Hex() + "/" + i.feed.Topic.Hex()
+}
+
+// SetMaxFetch sets the maximum number of messages returned by a single Fetch,
+// older messages beyond the limit are skipped
+func (i *Inbox) SetMaxFetch(n int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.maxFetch = n
+}
+
+// Fetch returns the messages deposited since the last fetch, in the order they were deposited
+func (i *Inbox) Fetch(ctx context.Context) (msgs [][]byte, err error) {
+	defer func() {
+		if err != nil {
+			metrics.GetOrRegisterCounter("pss/mailbox/fetch/err", nil).Inc(1)
+		}
+	}()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	head, err := i.feeds.Latest(ctx, &i.feed)
+	if err != nil {
+		return nil, err
+	}
+	ref := head
+	for len(ref) > 0 && !bytes.Equal(ref, i.last) {
+		if len(msgs) >= i.maxFetch {
+			log.Warn("pss mailbox fetch limit reached, skipping older messages", "owner", i.feed.User, "limit", i.maxFetch)
+			break
+		}
+		data, err := i.store.Get(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		var e entry
+		if err := rlp.DecodeBytes(data, &e); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, e.Message)
+		ref = e.Prev
+	}
+	// the chain is walked from the most recent entry
+	for l, r := 0, len(msgs)-1; l < r; l, r = l+1, r-1 {
+		msgs[l], msgs[r] = msgs[r], msgs[l]
+	}
+	if len(head) > 0 && !bytes.Equal(head, i.last) {
+		i.last = head
+		if i.state != nil {
+			// the messages are returned anyway, they are only fetched
+			// again if the inbox is created again after a restart
+			if err := i.state.Put(i.stateKey(), i.last); err != nil {
+				log.Error("pss mailbox inbox save", "owner", i.feed.User, "err", err)
+			}
+		}
+	}
+	metrics.GetOrRegisterCounter("pss/mailbox/fetch", nil).Inc(int64(len(msgs)))
+	return msgs, nil
+}
@@ -0,0 +1,256 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package mailbox
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+)
+
+// TestMailbox deposits messages for two recipients and
+// validates that each of them fetches only its own messages,
+// in order and only once
+func TestMailbox(t *testing.T) {
+	store := newMockStore()
+	owner := common.HexToAddress("0x01")
+	mb := New(store, newMockFeeds(owner), nil)
+	testMailbox(t, mb)
+}
+
+// TestMailbox_swarm validates the mailbox with entries stored
+// in a FileStore and heads published in feeds
+func TestMailbox_swarm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pss-mailbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileStore, cleanup, err := storage.NewLocalFileStore(dir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	handler, err := feed.NewTestHandler(dir, &feed.HandlerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := New(NewFileStore(fileStore), NewFeeds(handler.Handler, feed.NewGenericSigner(key)), nil)
+	testMailbox(t, mb)
+}
+
+func testMailbox(t *testing.T, mb *Mailbox) {
+	t.Helper()
+
+	ctx := context.Background()
+	alice := []byte("alice public key")
+	bob := []byte("bob public key")
+
+	aliceInbox := mb.Inbox(mb.Owner(), alice)
+	bobInbox := mb.Inbox(mb.Owner(), bob)
+
+	msgs, err := aliceInbox.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("got %v messages in empty mailbox", len(msgs))
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := mb.Deposit(ctx, alice, []byte(fmt.Sprintf("to alice %v", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mb.Deposit(ctx, bob, []byte("to bob")); err != nil {
+		t.Fatal(err)
+	}
+
+	checkMessages(t, aliceInbox, "to alice 0", "to alice 1", "to alice 2")
+	checkMessages(t, bobInbox, "to bob")
+	// fetched messages are not fetched again
+	checkMessages(t, aliceInbox)
+
+	if _, err := mb.Deposit(ctx, alice, []byte("to alice 3")); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, aliceInbox, "to alice 3")
+
+	// a new inbox fetches all messages deposited in the mailbox
+	checkMessages(t, mb.Inbox(mb.Owner(), alice), "to alice 0", "to alice 1", "to alice 2", "to alice 3")
+
+	if _, err := mb.Deposit(ctx, nil, []byte("to nobody")); err != ErrEmptyRecipient {
+		t.Errorf("got error %v, want %v", err, ErrEmptyRecipient)
+	}
+}
+
+// TestInbox_SetMaxFetch validates that only the most
+// recent messages are fetched if there are too many
+func TestInbox_SetMaxFetch(t *testing.T) {
+	ctx := context.Background()
+	owner := common.HexToAddress("0x01")
+	mb := New(newMockStore(), newMockFeeds(owner), nil)
+	recipient := []byte("recipient")
+
+	for i := 0; i < 5; i++ {
+		if _, err := mb.Deposit(ctx, recipient, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inbox := mb.Inbox(owner, recipient)
+	inbox.SetMaxFetch(2)
+	checkMessages(t, inbox, "3", "4")
+	checkMessages(t, inbox)
+}
+
+// TestMailbox_restart validates that a new mailbox continues
+// the chain of entries published by a previous one
+func TestMailbox_restart(t *testing.T) {
+	ctx := context.Background()
+	owner := common.HexToAddress("0x01")
+	store := newMockStore()
+	feeds := newMockFeeds(owner)
+	recipient := []byte("recipient")
+
+	if _, err := New(store, feeds, nil).Deposit(ctx, recipient, []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+	mb := New(store, feeds, nil)
+	if _, err := mb.Deposit(ctx, recipient, []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, mb.Inbox(owner, recipient), "before", "after")
+}
+
+// TestInbox_state validates that a new inbox with the same state store
+// continues from the latest entry fetched by the previous one
+func TestInbox_state(t *testing.T) {
+	ctx := context.Background()
+	owner := common.HexToAddress("0x01")
+	mb := New(newMockStore(), newMockFeeds(owner), state.NewInmemoryStore())
+	recipient := []byte("recipient")
+
+	if _, err := mb.Deposit(ctx, recipient, []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, mb.Inbox(owner, recipient), "before")
+
+	if _, err := mb.Deposit(ctx, recipient, []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, mb.Inbox(owner, recipient), "after")
+	checkMessages(t, mb.Inbox(owner, recipient))
+	// inboxes of other recipients are not affected
+	checkMessages(t, mb.Inbox(owner, []byte("other")))
+}
+
+// checkMessages fetches messages from the inbox and validates them
+func checkMessages(t *testing.T, inbox *Inbox, want ...string) {
+	t.Helper()
+
+	msgs, err := inbox.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("got %v messages, want %v", len(msgs), len(want))
+	}
+	for i, msg := range msgs {
+		if string(msg) != want[i] {
+			t.Errorf("got message %v %q, want %q", i, msg, want[i])
+		}
+	}
+}
+
+// mockStore is an in-memory Store
+type mockStore struct {
+	data map[string][]byte
+	mu   sync.Mutex
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{
+		data: make(map[string][]byte),
+	}
+}
+
+func (s *mockStore) Put(_ context.Context, data []byte) (storage.Address, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addr := storage.Address(crypto.Keccak256(data))
+	s.data[string(addr)] = append([]byte(nil), data...)
+	return addr, nil
+}
+
+func (s *mockStore) Get(_ context.Context, addr storage.Address) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[string(addr)]
+	if !ok {
+		return nil, storage.ErrChunkNotFound
+	}
+	return data, nil
+}
+
+// mockFeeds is an in-memory Feeds keeping only the latest updates
+type mockFeeds struct {
+	owner   common.Address
+	updates map[feed.Feed][]byte
+	mu      sync.Mutex
+}
+
+func newMockFeeds(owner common.Address) *mockFeeds {
+	return &mockFeeds{
+		owner:   owner,
+		updates: make(map[feed.Feed][]byte),
+	}
+}
+
+func (f *mockFeeds) Owner() common.Address {
+	return f.owner
+}
+
+func (f *mockFeeds) Publish(_ context.Context, topic feed.Topic, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates[feed.Feed{Topic: topic, User: f.owner}] = append([]byte(nil), data...)
+	return nil
+}
+
+func (f *mockFeeds) Latest(_ context.Context, fd *feed.Feed) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.updates[*fd], nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package mailbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+)
+
+// fileStore stores mailbox entries in a FileStore
+type fileStore struct {
+	fs *storage.FileStore
+}
+
+// NewFileStore returns a Store of mailbox entries in the FileStore
+func NewFileStore(fs *storage.FileStore) Store {
+	return &fileStore{fs: fs}
+}
+
+// Put implements the Store interface
+func (s *fileStore) Put(ctx context.Context, data []byte) (storage.Address, error) {
+	addr, wait, err := s.fs.Store(ctx, bytes.NewReader(data), int64(len(data)), false)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+// Get implements the Store interface
+func (s *fileStore) Get(ctx context.Context, addr storage.Address) ([]byte, error) {
+	reader, _ := s.fs.Retrieve(ctx, addr)
+	size, err := reader.Size(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(io.NewSectionReader(reader, 0, size))
+}
+
+// feedHandler publishes and looks up mailbox heads with a feed Handler
+type feedHandler struct {
+	handler *feed.Handler
+	signer  feed.Signer
+}
+
+// NewFeeds returns Feeds that publish mailbox heads with the handler,
+// signing the updates with the signer. The signer may be nil if the
+// feeds are only used to look up the heads of mailboxes.
+func NewFeeds(handler *feed.Handler, signer feed.Signer) Feeds {
+	return &feedHandler{
+		handler: handler,
+		signer:  signer,
+	}
+}
+
+// Owner implements the Feeds interface
+func (f *feedHandler) Owner() common.Address {
+	return f.signer.Address()
+}
+
+// Publish implements the Feeds interface
+func (f *feedHandler) Publish(ctx context.Context, topic feed.Topic, data []byte) error {
+	request, err := f.handler.NewRequest(ctx, &feed.Feed{
+		Topic: topic,
+		User:  f.signer.Address(),
+	})
+	if err != nil {
+		return err
+	}
+	request.SetData(data)
+	if err := request.Sign(f.signer); err != nil {
+		return err
+	}
+	_, err = f.handler.Update(ctx, request)
+	return err
+}
+
+// Latest implements the Feeds interface
+func (f *feedHandler) Latest(ctx context.Context, fd *feed.Feed) ([]byte, error) {
+	_, err := f.handler.Lookup(ctx, feed.NewQueryLatest(fd, lookup.NoClue))
+	if err != nil {
+		if ferr, ok := err.(*feed.Error); ok && ferr.Code() == feed.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	_, data, err := f.handler.GetContent(fd)
+	return data, err
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/pss/mailbox"
+	"github.com/ethersphere/swarm/pss/message"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed"
+)
+
+// TestMailbox tests that a message deposited in the mailbox of the sender
+// is delivered to the handler of its topic when the recipient fetches it
+func TestMailbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pss-mailbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileStore, cleanup, err := storage.NewLocalFileStore(dir, make([]byte, 32), chunk.NewTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	handler, err := feed.NewTestHandler(dir, &feed.HandlerParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	store := mailbox.NewFileStore(fileStore)
+
+	// sender and recipient share the storage, as if they were connected to the same network
+	// the recipient only reads its inboxes and has no mailbox to deposit messages in
+	newPss := func() (*Pss, *ecdsa.PrivateKey) {
+		key, err := ethCrypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := newTestPss(key, nil, nil)
+		ps.SetInboxes(store, mailbox.NewFeeds(handler.Handler, nil), nil)
+		return ps, key
+	}
+	sender, senderKey := newPss()
+	defer sender.Stop()
+	signer := feed.NewGenericSigner(senderKey)
+	sender.SetMailbox(mailbox.New(store, mailbox.NewFeeds(handler.Handler, signer), nil))
+	senderAddr := signer.Address()
+	recipient, _ := newPss()
+	defer recipient.Stop()
+	if _, err := recipient.SendMailbox(context.Background(), common.ToHex(sender.Crypto.SerializePublicKey(&sender.privateKey.PublicKey)), message.NewTopic([]byte("mailbox")), []byte("no mailbox")); err != ErrNoMailbox {
+		t.Fatalf("got error %v, want %v", err, ErrNoMailbox)
+	}
+
+	topic := message.NewTopic([]byte("mailbox"))
+	msgC := make(chan []byte, 1)
+	recipient.Register(&topic, NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		if !asymmetric {
+			t.Error("expected asymmetric message")
+		}
+		msgC <- msg
+		return nil
+	}))
+
+	ctx := context.Background()
+	pubkeyid := common.ToHex(recipient.Crypto.SerializePublicKey(&recipient.privateKey.PublicKey))
+	want := "hello offline"
+	if _, err := sender.SendMailbox(ctx, pubkeyid, topic, []byte(want)); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := recipient.FetchMailbox(ctx, senderAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("got %v fetched messages, want 1", n)
+	}
+	select {
+	case msg := <-msgC:
+		if string(msg) != want {
+			t.Errorf("got message %q, want %q", msg, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message from mailbox not delivered")
+	}
+
+	// messages are fetched only once
+	n, err = recipient.FetchMailbox(ctx, senderAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %v fetched messages, want 0", n)
+	}
+
+	// a message deposited for another recipient is not fetched
+	if _, err := sender.SendMailbox(ctx, common.ToHex(sender.Crypto.SerializePublicKey(&sender.privateKey.PublicKey)), topic, []byte("to self")); err != nil {
+		t.Fatal(err)
+	}
+	n, err = recipient.FetchMailbox(ctx, senderAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %v fetched messages, want 0", n)
+	}
+}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/ethereum/go-ethereum/rlp"
@@ -9,13 +10,22 @@ import (
 
 // Flags represents the possible PSS message flags
 type Flags struct {
-	Raw       bool // message is flagged as raw or with external encryption
-	Symmetric bool // message is symmetrically encrypted
+	Raw       bool  // message is flagged as raw or with external encryption
+	Symmetric bool  // message is symmetrically encrypted
+	Trace     bool  // forwarding nodes record their address in the route of trace messages
+	Padded    bool  // recipient address is padded with random bits beyond the first HintBits
+	HintBits  uint8 // number of bits of the recipient address given in a padded message
 }
 
+// MaxHintBits is the maximum number of bits of the recipient address given in a padded message
+const MaxHintBits = 15
+
 const flagsLength = 1
 const flagSymmetric = 1 << 0
 const flagRaw = 1 << 1
+const flagTrace = 1 << 2
+const flagPadded = 1 << 3
+const flagHintBitsShift = 4
 
 // ErrIncorrectFlagsFieldLength is returned when the incoming flags field length is incorrect
 var ErrIncorrectFlagsFieldLength = errors.New("Incorrect flags field length in message")
@@ -31,6 +41,11 @@ func (f *Flags) DecodeRLP(s *rlp.Stream) error {
 	}
 	f.Symmetric = flagsBytes[0]&flagSymmetric != 0
 	f.Raw = flagsBytes[0]&flagRaw != 0
+	f.Trace = flagsBytes[0]&flagTrace != 0
+	f.Padded = flagsBytes[0]&flagPadded != 0
+	if f.Padded {
+		f.HintBits = flagsBytes[0] >> flagHintBitsShift
+	}
 	return nil
 }
 
@@ -43,6 +58,15 @@ func (f *Flags) EncodeRLP(w io.Writer) error {
 	if f.Symmetric {
 		flags |= flagSymmetric
 	}
+	if f.Trace {
+		flags |= flagTrace
+	}
+	if f.Padded {
+		if f.HintBits > MaxHintBits {
+			return fmt.Errorf("hint of %d bits longer than %d", f.HintBits, MaxHintBits)
+		}
+		flags |= flagPadded | f.HintBits<<flagHintBitsShift
+	}
 
 	return rlp.Encode(w, []byte{flags})
 }
@@ -12,46 +12,84 @@ import (
 
 var bools = []bool{true, false}
 var flagsFixture = map[string]string{
-	"r=false; s=false": "00",
-	"r=false; s=true":  "01",
-	"r=true; s=false":  "02",
-	"r=true; s=true":   "03",
+	"r=false; s=false; t=false": "00",
+	"r=false; s=true; t=false":  "01",
+	"r=true; s=false; t=false":  "02",
+	"r=true; s=true; t=false":   "03",
+	"r=false; s=false; t=true":  "04",
+	"r=false; s=true; t=true":   "05",
+	"r=true; s=false; t=true":   "06",
+	"r=true; s=true; t=true":    "07",
 }
 
 func TestFlags(t *testing.T) {
 
 	for _, r := range bools {
 		for _, s := range bools {
-			f := message.Flags{
-				Symmetric: s,
-				Raw:       r,
-			}
-			// Test encoding:
-			bytes, err := rlp.EncodeToBytes(&f)
-			if err != nil {
-				t.Fatal(err)
-			}
-			expected := flagsFixture[fmt.Sprintf("r=%t; s=%t", r, s)]
-			actual := hex.EncodeToString(bytes)
-			if expected != actual {
-				t.Fatalf("Expected RLP encoding of the flags to be %s, got %s", expected, actual)
-			}
+			for _, tr := range bools {
+				f := message.Flags{
+					Symmetric: s,
+					Raw:       r,
+					Trace:     tr,
+				}
+				// Test encoding:
+				bytes, err := rlp.EncodeToBytes(&f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := flagsFixture[fmt.Sprintf("r=%t; s=%t; t=%t", r, s, tr)]
+				actual := hex.EncodeToString(bytes)
+				if expected != actual {
+					t.Fatalf("Expected RLP encoding of the flags to be %s, got %s", expected, actual)
+				}
 
-			// Test decoding:
+				// Test decoding:
 
-			var f2 message.Flags
-			err = rlp.DecodeBytes(bytes, &f2)
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !reflect.DeepEqual(f, f2) {
-				t.Fatalf("Expected RLP decoding to return the same object. Got %v", f2)
+				var f2 message.Flags
+				err = rlp.DecodeBytes(bytes, &f2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(f, f2) {
+					t.Fatalf("Expected RLP decoding to return the same object. Got %v", f2)
+				}
 			}
 		}
 	}
 
 }
 
+func TestFlagsPadded(t *testing.T) {
+	for hintBits, expected := range map[uint8]string{
+		0:                   "08",
+		7:                   "78",
+		message.MaxHintBits: "81f8",
+	} {
+		f := message.Flags{
+			Padded:   true,
+			HintBits: hintBits,
+		}
+		bytes, err := rlp.EncodeToBytes(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := hex.EncodeToString(bytes); expected != actual {
+			t.Fatalf("Expected RLP encoding of the flags to be %s, got %s", expected, actual)
+		}
+		var f2 message.Flags
+		if err := rlp.DecodeBytes(bytes, &f2); err != nil {
+			t.Fatal(err)
+		}
+		if f != f2 {
+			t.Fatalf("Expected RLP decoding to return the same object. Got %v", f2)
+		}
+	}
+
+	if _, err := rlp.EncodeToBytes(&message.Flags{Padded: true, HintBits: message.MaxHintBits + 1}); err == nil {
+		t.Fatal("Expected an error encoding a hint longer than message.MaxHintBits")
+	}
+}
+
 func TestFlagsErrors(t *testing.T) {
 	var f2 message.Flags
 	err := rlp.DecodeBytes([]byte{0x82, 0xFF, 0xFF}, &f2)
@@ -1,6 +1,7 @@
 package message
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -40,6 +41,33 @@ func (msg *Message) Digest() Digest {
 	return d
 }
 
+// Luminosity returns the number of leading bits of the recipient address
+// given in the To field, which are all of them unless the message is padded
+func (msg *Message) Luminosity() int {
+	if msg.Flags.Padded {
+		return int(msg.Flags.HintBits)
+	}
+	return len(msg.To) * 8
+}
+
+// Matches reports whether the bits of the recipient address given in the To field
+// match the leading bits of addr
+func (msg *Message) Matches(addr []byte) bool {
+	bits := msg.Luminosity()
+	if len(addr)*8 < bits || len(msg.To)*8 < bits {
+		return false
+	}
+	n := bits / 8
+	if !bytes.Equal(msg.To[:n], addr[:n]) {
+		return false
+	}
+	if rest := uint(bits % 8); rest > 0 {
+		mask := byte(0xff) << (8 - rest)
+		return msg.To[n]&mask == addr[n]&mask
+	}
+	return true
+}
+
 // String representation of a PSS message
 func (msg *Message) String() string {
 	return fmt.Sprintf("PssMsg: Recipient: %s, Topic: %v", common.ToHex(msg.To), msg.Topic.String())
@@ -23,6 +23,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/metrics/labels"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/capability"
 	"github.com/ethersphere/swarm/p2p/protocols"
@@ -39,6 +41,7 @@ import (
 	"github.com/ethersphere/swarm/pss/crypto"
 	"github.com/ethersphere/swarm/pss/internal/ticker"
 	"github.com/ethersphere/swarm/pss/internal/ttlset"
+	"github.com/ethersphere/swarm/pss/mailbox"
 	"github.com/ethersphere/swarm/pss/message"
 	"github.com/ethersphere/swarm/pss/outbox"
 	"github.com/tilinna/clock"
@@ -51,6 +54,12 @@ const (
 	defaultMaxMsgSize          = 1024 * 1024
 	defaultCleanInterval       = time.Minute * 10
 	defaultOutboxCapacity      = 50
+	defaultHandlerConcurrency  = 8
+	otherTopicLabel            = "other" // metrics label of the topics without registered handlers
+	defaultAddressHintMinBits  = 4
+	defaultAddressHintMaxBits  = 8
+	defaultContentThreshold    = defaultMaxMsgSize - 4096 // leaves room for the envelope around the payload
+	defaultContentTTL          = defaultMsgTTL
 	protocolName               = "pss"
 	protocolVersion            = 2
 	CapabilityID               = capability.CapabilityID(1)
@@ -59,6 +68,7 @@ const (
 	capabilitiesForward        = 4 // node forwards pss messages on behalf of network
 	capabilitiesPartial        = 5 // node accepts partially addressed messages
 	capabilitiesEmpty          = 6 // node accepts messages with empty address
+	capabilitiesForwardFlag    = 7 // node advertises with capabilitiesForward whether it forwards, older nodes do not
 )
 
 var (
@@ -96,8 +106,15 @@ type Params struct {
 	CacheTTL            time.Duration
 	privateKey          *ecdsa.PrivateKey
 	SymKeyCacheCapacity int
-	AllowRaw            bool // If true, enables sending and receiving messages without builtin pss encryption
-	AllowForward        bool
+	AllowRaw            bool          // If true, enables sending and receiving messages without builtin pss encryption
+	AllowForward        bool          // If true, advertises forwarding messages on behalf of the network
+	HandlerConcurrency  int           // number of concurrent handlers of a topic that run at the same time (see WithConcurrency), unless set with SetTopicConcurrency
+	AddressHintPadding  bool          // If true, pads recipient address hints of at most AddressHintMaxBits of outgoing messages to the full address length with random bits
+	AddressHintMinBits  int           // minimum number of bits of the recipient address given in a padded message
+	AddressHintMaxBits  int           // maximum number of bits of the recipient address given in a padded message, at most message.MaxHintBits
+	ContentThreshold    int           // payloads larger than this are stored in swarm and sent as a reference, if a content store is set
+	ContentTTL          time.Duration // time the payloads stored in swarm are kept in the local store of the sender
+	AllowTrace          bool          // If true, records the route of trace messages, replies to trace requests and allows TraceRoute
 }
 
 // Sane defaults for Pss
@@ -106,6 +123,12 @@ func NewParams() *Params {
 		MsgTTL:              defaultMsgTTL,
 		CacheTTL:            defaultDigestCacheTTL,
 		SymKeyCacheCapacity: defaultSymKeyCacheCapacity,
+		AllowForward:        true,
+		HandlerConcurrency:  defaultHandlerConcurrency,
+		AddressHintMinBits:  defaultAddressHintMinBits,
+		AddressHintMaxBits:  defaultAddressHintMaxBits,
+		ContentThreshold:    defaultContentThreshold,
+		ContentTTL:          defaultContentTTL,
 	}
 }
 
@@ -133,12 +156,34 @@ type Pss struct {
 	msgTTL    time.Duration
 	capstring string
 	outbox    *outbox.Outbox
+	hintPad   *hintPadding // pads the recipient address hints of outgoing messages, nil if disabled
 
 	// message handling
 	handlers           map[message.Topic]map[*handler]bool // topic and version based pss payload handlers. See pss.Handle()
 	handlersMu         sync.RWMutex
 	topicHandlerCaps   map[message.Topic]*handlerCaps // caches capabilities of each topic's handlers
 	topicHandlerCapsMu sync.RWMutex
+	tracer             *tracer // pending trace requests, nil if tracing is not allowed
+
+	// mailboxes of offline recipients
+	mailbox   *mailbox.Mailbox                            // mailbox messages are deposited in, nil if not set
+	newInbox  func(common.Address, []byte) *mailbox.Inbox // returns an inbox of this node in the mailbox of another node, nil if not set
+	inboxes   map[common.Address]*mailbox.Inbox           // inboxes of this node in the mailboxes of other nodes
+	mailboxMu sync.Mutex
+
+	// payloads of large messages stored in swarm
+	contentStore     ContentStore         // nil if not set, in which case large payloads are sent as they are
+	contentThreshold int                  // payloads larger than this are stored in the content store
+	contentTTL       time.Duration        // time stored payloads are kept before they are removed
+	contentRefs      map[string]time.Time // expiry of the payloads stored by this node
+	contentMu        sync.Mutex
+	contentFetches   chan struct{}             // bounds the number of payloads fetched concurrently
+	contentSenders   map[string]*contentSender // fetched references by sender key id, to rate limit senders
+
+	// handler worker pools
+	handlerConcurrency int                             // default number of handlers of a topic that run concurrently
+	topicWorkers       map[message.Topic]chan struct{} // bounds the number of handlers of a topic that run concurrently
+	topicWorkersMu     sync.Mutex
 
 	// process
 	quitC chan struct{}
@@ -177,6 +222,28 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 
 		handlers:         make(map[message.Topic]map[*handler]bool),
 		topicHandlerCaps: make(map[message.Topic]*handlerCaps),
+		inboxes:          make(map[common.Address]*mailbox.Inbox),
+
+		contentThreshold: params.ContentThreshold,
+		contentTTL:       params.ContentTTL,
+		contentRefs:      make(map[string]time.Time),
+		contentFetches:   make(chan struct{}, defaultContentFetchConcurrency),
+		contentSenders:   make(map[string]*contentSender),
+
+		handlerConcurrency: params.HandlerConcurrency,
+		topicWorkers:       make(map[message.Topic]chan struct{}),
+	}
+	if ps.handlerConcurrency <= 0 {
+		ps.handlerConcurrency = defaultHandlerConcurrency
+	}
+	if params.AddressHintPadding {
+		if params.AddressHintMinBits < 0 || params.AddressHintMinBits > params.AddressHintMaxBits || params.AddressHintMaxBits > message.MaxHintBits {
+			return nil, fmt.Errorf("invalid address hint range of %d to %d bits", params.AddressHintMinBits, params.AddressHintMaxBits)
+		}
+		ps.hintPad = &hintPadding{
+			minBits: params.AddressHintMinBits,
+			maxBits: params.AddressHintMaxBits,
+		}
 	}
 	ps.forwardCache = ttlset.New(&ttlset.Config{
 		EntryTTL: params.CacheTTL,
@@ -188,6 +255,7 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 		Callback: func() {
 			ps.forwardCache.GC()
 			metrics.GetOrRegisterCounter("pss/cleanfwdcache", nil).Inc(1)
+			ps.cleanContent()
 		},
 	})
 	ps.outbox = outbox.NewOutbox(&outbox.Config{
@@ -200,11 +268,17 @@ func New(k *network.Kademlia, params *Params) (*Pss, error) {
 	cp.Set(capabilitiesReceive)
 	cp.Set(capabilitiesPartial)
 	cp.Set(capabilitiesEmpty)
+	cp.Set(capabilitiesForwardFlag)
 	if params.AllowForward {
 		cp.Set(capabilitiesForward)
 	}
 	k.Capabilities.Add(cp)
 
+	if params.AllowTrace {
+		ps.tracer = newTracer()
+		ps.Register(&TraceTopic, NewHandler(ps.handleTrace).WithRaw())
+	}
+
 	return ps, nil
 }
 
@@ -428,15 +502,21 @@ func (p *Pss) handle(ctx context.Context, peer *protocols.Peer, msg interface{})
 	if !ok {
 		return fmt.Errorf("invalid message type %s", msg)
 	}
-	return p.handlePssMsg(ctx, pssmsg)
+	var relay enode.ID
+	if peer != nil {
+		relay = peer.ID()
+	}
+	return p.handlePssMsg(ctx, pssmsg, relay)
 }
 
 // Filters incoming messages for processing or forwarding.
 // Check if address partially matches
 // If yes, it CAN be for us, and we process it
 // Only passes error to pss protocol handler if payload is not valid pssmsg
-func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message) error {
+// relay is the peer the message is received from
+func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message, relay enode.ID) error {
 	defer metrics.GetOrRegisterResettingTimer("pss/handle", nil).UpdateSince(time.Now())
+	metrics.GetOrRegisterCounter(labels.Name("pss/handle/topic", "topic", p.topicMetricLabel(pssmsg.Topic)), nil).Inc(1)
 
 	log.Trace("handler", "self", label(p.Kademlia.BaseAddr()), "topic", label(pssmsg.Topic[:]))
 	if int64(pssmsg.Expire) < time.Now().Unix() {
@@ -450,6 +530,12 @@ func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message) error {
 	}
 	p.addFwdCache(pssmsg)
 
+	// record this node on the route of trace messages
+	if p.tracer != nil && isTrace(pssmsg) && !p.recordTrace(pssmsg) {
+		log.Trace("pss trace message loop detected", "self", label(p.Kademlia.BaseAddr()))
+		return nil
+	}
+
 	psstopic := pssmsg.Topic
 
 	// raw is simplest handler contingency to check, so check that first
@@ -478,7 +564,7 @@ func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message) error {
 	}
 
 	log.Trace("pss msg processing <===", "pss", hex.EncodeToString(p.BaseAddr()), "prox", isProx, "raw", isRaw, "topic", label(pssmsg.Topic[:]))
-	if err := p.process(pssmsg, isRaw, isProx); err != nil {
+	if err := p.process(pssmsg, isRaw, isProx, relay); err != nil {
 		p.enqueue(pssmsg)
 	}
 	return nil
@@ -487,19 +573,23 @@ func (p *Pss) handlePssMsg(ctx context.Context, pssmsg *message.Message) error {
 // Entry point to processing a message for which the current node can be the intended recipient.
 // Attempts symmetric and asymmetric decryption with stored keys.
 // Dispatches message to all handlers matching the message topic
-func (p *Pss) process(pssmsg *message.Message, raw bool, prox bool) error {
+// Raw messages are not signed, the handlers get the overlay address of the relay peer
+// the message is received from instead, which is the sender if it is connected to the node
+func (p *Pss) process(pssmsg *message.Message, raw bool, prox bool, relay enode.ID) error {
 	defer metrics.GetOrRegisterResettingTimer("pss/process", nil).UpdateSince(time.Now())
 
 	var payload []byte
 	var from PssAddress
 	var asymmetric bool
 	var keyid string
-	var keyFunc func(pssMsg *message.Message) ([]byte, string, PssAddress, error)
+	var reference bool
+	var keyFunc func(pssMsg *message.Message) ([]byte, string, PssAddress, bool, error)
 
 	psstopic := pssmsg.Topic
 
 	if raw {
 		payload = pssmsg.Payload
+		from = p.peerAddress(relay)
 	} else {
 		if pssmsg.Flags.Symmetric {
 			keyFunc = p.processSym
@@ -509,19 +599,36 @@ func (p *Pss) process(pssmsg *message.Message, raw bool, prox bool) error {
 		}
 
 		var err error
-		payload, keyid, from, err = keyFunc(pssmsg)
+		payload, keyid, from, reference, err = keyFunc(pssmsg)
 		if err != nil {
 			return errors.New("decryption failed")
 		}
 	}
 
-	if len(pssmsg.To) < addressLength || prox {
+	if pssmsg.Luminosity() < addressLength*8 || prox {
 		p.enqueue(pssmsg)
 	}
+	// the content is fetched from swarm outside of the read loop of the peer
+	if reference {
+		p.dispatchContent(psstopic, payload, from, prox, asymmetric, keyid)
+		return nil
+	}
 	p.executeHandlers(psstopic, payload, from, raw, prox, asymmetric, keyid)
 	return nil
 }
 
+// peerAddress returns the overlay address of the connected peer, nil if it is not connected
+func (p *Pss) peerAddress(id enode.ID) (addr PssAddress) {
+	p.Kademlia.EachConn(nil, 255, func(peer *network.Peer, _ int) bool {
+		if peer.ID() == id {
+			addr = peer.Address()
+			return false
+		}
+		return true
+	})
+	return addr
+}
+
 // copy all registered handlers for respective topic in order to avoid data race or deadlock
 func (p *Pss) getHandlers(topic message.Topic) (ret []*handler) {
 	p.handlersMu.RLock()
@@ -532,6 +639,46 @@ func (p *Pss) getHandlers(topic message.Topic) (ret []*handler) {
 	return ret
 }
 
+// topicMetricLabel returns the label of the topic in the metrics
+// The topics without registered handlers are counted together as otherTopicLabel,
+// so that the topics of the messages relayed for other nodes do not register a metric each
+func (p *Pss) topicMetricLabel(topic message.Topic) string {
+	p.handlersMu.RLock()
+	defer p.handlersMu.RUnlock()
+	if len(p.handlers[topic]) == 0 {
+		return otherTopicLabel
+	}
+	return topic.String()
+}
+
+// SetTopicConcurrency sets the number of concurrent handlers of the topic that run at the same time
+// Handlers already running when it is called are not counted against the new limit
+func (p *Pss) SetTopicConcurrency(topic message.Topic, n int) {
+	if n <= 0 {
+		n = 1
+	}
+	p.topicWorkersMu.Lock()
+	defer p.topicWorkersMu.Unlock()
+	p.topicWorkers[topic] = make(chan struct{}, n)
+}
+
+// getTopicWorkers returns the worker pool of the topic, creating it with
+// the default concurrency if it does not exist yet
+func (p *Pss) getTopicWorkers(topic message.Topic) chan struct{} {
+	p.topicWorkersMu.Lock()
+	defer p.topicWorkersMu.Unlock()
+	workers, ok := p.topicWorkers[topic]
+	if !ok {
+		workers = make(chan struct{}, p.handlerConcurrency)
+		p.topicWorkers[topic] = workers
+	}
+	return workers
+}
+
+// executeHandlers dispatches the message to the handlers of the topic
+// Handlers run in the calling goroutine in the order the messages arrive, concurrent
+// handlers (see WithConcurrency) run in the worker pool of the topic, so they do not
+// hold up the connection the message arrived on until the pool of the topic is exhausted
 func (p *Pss) executeHandlers(topic message.Topic, payload []byte, from PssAddress, raw bool, prox bool, asymmetric bool, keyid string) {
 	defer metrics.GetOrRegisterResettingTimer("pss/execute-handlers", nil).UpdateSince(time.Now())
 
@@ -546,10 +693,35 @@ func (p *Pss) executeHandlers(topic message.Topic, payload []byte, from PssAddre
 			log.Warn("noproxhandler")
 			continue
 		}
-		err := (h.f)(payload, peer, asymmetric, keyid)
-		if err != nil {
-			log.Warn("Pss handler failed", "err", err)
+		if !h.caps.concurrent {
+			p.runHandler(topic, h, payload, peer, asymmetric, keyid)
+			continue
 		}
+		workers := p.getTopicWorkers(topic)
+		select {
+		case workers <- struct{}{}:
+		case <-p.quitC:
+			return
+		}
+		go func(h *handler) {
+			defer func() { <-workers }()
+			p.runHandler(topic, h, payload, peer, asymmetric, keyid)
+		}(h)
+	}
+}
+
+// runHandler calls the handler with the message, recovering from a panic
+// in the handler so that a faulty handler does not crash the node
+func (p *Pss) runHandler(topic message.Topic, h *handler, payload []byte, peer *p2p.Peer, asymmetric bool, keyid string) {
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.GetOrRegisterCounter("pss/handler/panic", nil).Inc(1)
+			log.Error("Pss handler panicked", "topic", label(topic[:]), "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	err := (h.f)(payload, peer, asymmetric, keyid)
+	if err != nil {
+		log.Warn("Pss handler failed", "err", err)
 	}
 }
 
@@ -564,7 +736,7 @@ func (p *Pss) isSelfPossibleRecipient(msg *message.Message, prox bool) bool {
 
 	// if a partial address matches we are possible recipient regardless of prox
 	// if not and prox is not set, we are surely not
-	if bytes.Equal(msg.To, local[:len(msg.To)]) {
+	if msg.Matches(local) {
 
 		return true
 	} else if !prox {
@@ -573,6 +745,10 @@ func (p *Pss) isSelfPossibleRecipient(msg *message.Message, prox bool) bool {
 
 	depth := p.NeighbourhoodDepth()
 	po, _ := network.Pof(p.Kademlia.BaseAddr(), msg.To, 0)
+	// the random bits of a padded address do not bring us closer
+	if lum := msg.Luminosity(); po > lum {
+		po = lum
+	}
 	log.Trace("selfpossible", "po", po, "depth", depth)
 
 	return depth <= po
@@ -605,7 +781,9 @@ func (p *Pss) SendRaw(address PssAddress, topic message.Topic, msg []byte, messa
 	}
 
 	pssMsg := message.New(pssMsgParams)
-	pssMsg.To = address
+	if err := p.setRecipient(pssMsg, address); err != nil {
+		return err
+	}
 	pssMsg.Expire = uint32(time.Now().Add(messageTTL).Unix())
 	pssMsg.Payload = msg
 	pssMsg.Topic = topic
@@ -652,16 +830,34 @@ func (p *Pss) SendAsym(pubkeyid string, topic message.Topic, msg []byte) error {
 func (p *Pss) send(to []byte, topic message.Topic, msg []byte, asymmetric bool, key []byte) error {
 	metrics.GetOrRegisterCounter("pss/send", nil).Inc(1)
 
+	// payloads too large for an envelope are stored in swarm and the reference is sent instead
+	msg, reference, err := p.storeContent(msg)
+	if err != nil {
+		return err
+	}
+	pssMsg, err := p.wrap(to, topic, msg, asymmetric, key, reference)
+	if err != nil {
+		return err
+	}
+	p.enqueue(pssMsg)
+	return nil
+}
+
+// wrap encrypts the message payload with the key and wraps it
+// in a pss message for the recipient and topic
+// If reference is true, the payload is flagged as a reference to the content in swarm
+func (p *Pss) wrap(to []byte, topic message.Topic, msg []byte, asymmetric bool, key []byte, reference bool) (*message.Message, error) {
 	if key == nil || bytes.Equal(key, []byte{}) {
-		return fmt.Errorf("Zero length key passed to pss send")
+		return nil, fmt.Errorf("Zero length key passed to pss send")
 	}
 	wrapParams := &crypto.WrapParams{
-		Sender: p.privateKey,
+		Sender:    p.privateKey,
+		Reference: reference,
 	}
 	if asymmetric {
 		pk, err := p.Crypto.UnmarshalPublicKey(key)
 		if err != nil {
-			return fmt.Errorf("Cannot unmarshal pubkey: %x", key)
+			return nil, fmt.Errorf("Cannot unmarshal pubkey: %x", key)
 		}
 		wrapParams.Receiver = pk
 	} else {
@@ -670,7 +866,7 @@ func (p *Pss) send(to []byte, topic message.Topic, msg []byte, asymmetric bool,
 	// set up outgoing message container, which does encryption and envelope wrapping
 	envelope, err := p.Crypto.Wrap(msg, wrapParams)
 	if err != nil {
-		return fmt.Errorf("failed to perform message encapsulation and encryption: %v", err)
+		return nil, fmt.Errorf("failed to perform message encapsulation and encryption: %v", err)
 	}
 	log.Trace("pssmsg wrap done", "env", envelope, "mparams payload", hex.EncodeToString(msg), "to", hex.EncodeToString(to), "asym", asymmetric, "key", hex.EncodeToString(key))
 
@@ -679,13 +875,13 @@ func (p *Pss) send(to []byte, topic message.Topic, msg []byte, asymmetric bool,
 		Symmetric: !asymmetric,
 	}
 	pssMsg := message.New(pssMsgParams)
-	pssMsg.To = to
+	if err := p.setRecipient(pssMsg, to); err != nil {
+		return nil, err
+	}
 	pssMsg.Expire = uint32(time.Now().Add(p.msgTTL).Unix())
 	pssMsg.Payload = envelope
 	pssMsg.Topic = topic
-
-	p.enqueue(pssMsg)
-	return nil
+	return pssMsg, nil
 }
 
 // sendFunc is a helper function that tries to send a message and returns true on success.
@@ -707,6 +903,10 @@ func sendMsg(p *Pss, sp *network.Peer, msg *message.Message) bool {
 		log.Trace("peer doesn't have matching pss capabilities, skipping", "peer", info.Name, "caps", info.Caps, "peer", label(sp.BzzAddr.Address()))
 		return false
 	}
+	if !isRelayOrRecipient(sp.BzzAddr, msg) {
+		log.Trace("peer doesn't forward pss messages and is not a recipient, skipping", "peer", label(sp.BzzAddr.Address()))
+		return false
+	}
 
 	// get the protocol peer from the forwarding peer cache
 	pp, ok := p.getPeer(sp.BzzPeer.Peer)
@@ -723,6 +923,23 @@ func sendMsg(p *Pss, sp *network.Peer, msg *message.Message) bool {
 	return err == nil
 }
 
+// isRelayOrRecipient returns true if the peer advertises forwarding pss messages
+// or if its address matches the (partial) recipient address of the message.
+// Peers which do not advertise the pss capability, or older ones which do not
+// advertise whether they forward, are assumed to forward.
+func isRelayOrRecipient(addr *network.BzzAddr, msg *message.Message) bool {
+	if addr.Capabilities == nil || addr.Capabilities.Get(CapabilityID) == nil {
+		return true
+	}
+	if !addr.Capabilities.Has(CapabilityID, capabilitiesForwardFlag) {
+		return true
+	}
+	if addr.Capabilities.Has(CapabilityID, capabilitiesForward) {
+		return true
+	}
+	return msg.Matches(addr.Address())
+}
+
 // Forwards a pss message to the peer(s) based on recipient address according to the algorithm
 // described below. The recipient address can be of any length, and the byte slice will be matched
 // to the MSB slice of the peer address of the equivalent length.
@@ -741,8 +958,9 @@ func (p *Pss) forward(msg *message.Message) error {
 	neighbourhoodDepth := p.NeighbourhoodDepth()
 
 	// luminosity is the opposite of darkness. the more bytes are removed from the address, the higher is darkness,
-	// but the luminosity is less. here luminosity equals the number of bits given in the destination address.
-	luminosityRadius := len(msg.To) * 8
+	// but the luminosity is less. here luminosity equals the number of bits given in the destination address,
+	// which in a padded address are followed by random ones.
+	luminosityRadius := msg.Luminosity()
 
 	// proximity order function matching up to neighbourhoodDepth bits (po <= neighbourhoodDepth)
 	pof := pot.DefaultPof(neighbourhoodDepth)
@@ -609,6 +609,125 @@ func TestRawAllow(t *testing.T) {
 	}
 }
 
+// TestHandlerConcurrency tests that no more concurrent handlers of a topic than its concurrency run at the same time
+func TestHandlerConcurrency(t *testing.T) {
+	privKey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privKey, nil, nil)
+	defer ps.Stop()
+	topic := message.NewTopic([]byte{0x2a})
+	ps.SetTopicConcurrency(topic, 2)
+
+	startedC := make(chan struct{}, 3)
+	releaseC := make(chan struct{})
+	ps.Register(&topic, NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		startedC <- struct{}{}
+		<-releaseC
+		return nil
+	}).WithConcurrency())
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			ps.executeHandlers(topic, nil, nil, false, false, false, "")
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-startedC:
+		case <-time.After(time.Second):
+			t.Fatalf("expected handler %d to start", i)
+		}
+	}
+	select {
+	case <-startedC:
+		t.Fatal("expected third handler to wait for a free worker")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	releaseC <- struct{}{}
+	select {
+	case <-startedC:
+	case <-time.After(time.Second):
+		t.Fatal("expected third handler to start once a worker is free")
+	}
+	close(releaseC)
+}
+
+// TestHandlerPanic tests that a panic in a handler does not crash the node
+// and does not prevent the other handlers of the topic from running
+func TestHandlerPanic(t *testing.T) {
+	privKey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privKey, nil, nil)
+	defer ps.Stop()
+
+	panicFunc := func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		panic("handler failure")
+	}
+
+	syncTopic := message.NewTopic([]byte{0x2a})
+	ps.Register(&syncTopic, NewHandler(panicFunc))
+	var handled bool
+	ps.Register(&syncTopic, NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		handled = true
+		return nil
+	}))
+
+	ps.executeHandlers(syncTopic, nil, nil, false, false, false, "")
+	if !handled {
+		t.Fatal("expected handler to run despite the panic of the other handler of the topic")
+	}
+
+	// with a single worker, a message can only be dispatched once
+	// the panicking handler of the previous message released the worker
+	poolTopic := message.NewTopic([]byte{0x2b})
+	ps.SetTopicConcurrency(poolTopic, 1)
+	ps.Register(&poolTopic, NewHandler(panicFunc).WithConcurrency())
+
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		for i := 0; i < 3; i++ {
+			ps.executeHandlers(poolTopic, nil, nil, false, false, false, "")
+		}
+	}()
+	select {
+	case <-doneC:
+	case <-time.After(time.Second):
+		t.Fatal("expected worker to be released after handler panic")
+	}
+}
+
+// TestTopicMetricLabel tests that only the topics with registered handlers
+// are told apart in the metrics
+func TestTopicMetricLabel(t *testing.T) {
+	privKey, err := ethCrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privKey, nil, nil)
+	defer ps.Stop()
+
+	topic := message.NewTopic([]byte{0x2a})
+	if l := ps.topicMetricLabel(topic); l != otherTopicLabel {
+		t.Fatalf("got label %q for a topic without handlers, want %q", l, otherTopicLabel)
+	}
+	deregister := ps.Register(&topic, NewHandler(func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error {
+		return nil
+	}))
+	if l := ps.topicMetricLabel(topic); l != topic.String() {
+		t.Fatalf("got label %q for a topic with a handler, want %q", l, topic.String())
+	}
+	deregister()
+	if l := ps.topicMetricLabel(topic); l != otherTopicLabel {
+		t.Fatalf("got label %q for a topic whose handler is deregistered, want %q", l, otherTopicLabel)
+	}
+}
+
 // BELOW HERE ARE TESTS USING THE SIMULATION FRAMEWORK
 
 // tests that the API layer can handle edge case values
@@ -1391,7 +1510,7 @@ func benchmarkSymkeyBruteforceChangeaddr(b *testing.B) {
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if err := ps.process(pssmsgs[len(pssmsgs)-(i%len(pssmsgs))-1], false, false); err != nil {
+		if err := ps.process(pssmsgs[len(pssmsgs)-(i%len(pssmsgs))-1], false, false, enode.ID{}); err != nil {
 			b.Fatalf("pss processing failed: %v", err)
 		}
 	}
@@ -1463,7 +1582,7 @@ func benchmarkSymkeyBruteforceSameaddr(b *testing.B) {
 		Payload: payload,
 	}
 	for i := 0; i < b.N; i++ {
-		if err := ps.process(pssmsg, false, false); err != nil {
+		if err := ps.process(pssmsg, false, false, enode.ID{}); err != nil {
 			b.Fatalf("pss processing failed: %v", err)
 		}
 	}
@@ -1618,6 +1737,7 @@ func newTestPssStart(privkey *ecdsa.PrivateKey, kad *network.Kademlia, ppextra *
 	pp := NewParams().WithPrivateKey(privkey)
 	if ppextra != nil {
 		pp.SymKeyCacheCapacity = ppextra.SymKeyCacheCapacity
+		pp.AllowTrace = ppextra.AllowTrace
 	}
 	ps, err := New(kad, pp)
 	if err != nil {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/pss/message"
 )
@@ -55,6 +56,48 @@ func (p *PubSub) IsClosestTo(addr []byte) bool {
 	return p.pss.IsClosestTo(addr, isPssPeer)
 }
 
+// NearestPeers returns the addresses of at most n pss capable peers within the
+// neighbourhood depth, nearest to addr first
+func (p *PubSub) NearestPeers(addr []byte, n int) [][]byte {
+	k := p.pss.Kademlia
+	depth := k.NeighbourhoodDepth()
+	var peers [][]byte
+	k.EachConn(addr, 255, func(peer *network.Peer, _ int) bool {
+		if len(peers) >= n {
+			return false
+		}
+		if !isPssPeer(peer.BzzPeer) || chunk.Proximity(k.BaseAddr(), peer.Address()) < depth {
+			return true
+		}
+		peers = append(peers, peer.Address())
+		return true
+	})
+	return peers
+}
+
+// IsWithinDepth reports whether addr is within the neighbourhood depth of the node
+func (p *PubSub) IsWithinDepth(addr []byte) bool {
+	k := p.pss.Kademlia
+	return chunk.Proximity(k.BaseAddr(), addr) >= k.NeighbourhoodDepth()
+}
+
+// SubscribeToPeerChanges returns a channel signalling that peers connected or disconnected,
+// which may change the nearest peers, and a function to unsubscribe
+func (p *PubSub) SubscribeToPeerChanges() (<-chan struct{}, func()) {
+	sub := p.pss.Kademlia.SubscribeToPeerChanges()
+	c := make(chan struct{}, 1)
+	go func() {
+		defer close(c)
+		for range sub.ReceiveChannel() {
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return c, sub.Unsubscribe
+}
+
 // Register registers a handler
 func (p *PubSub) Register(topic string, prox bool, handler func(msg []byte, p *p2p.Peer) error) func() {
 	f := func(msg []byte, peer *p2p.Peer, _ bool, _ string) error {
@@ -0,0 +1,267 @@
+// Copyright 2019 The Swarm authors
+// This file is part of the swarm library.
+//
+// The swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/pss/message"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// traceAddrLength is the number of bytes of the overlay address recorded by each hop
+	traceAddrLength = 4
+	// defaultTraceTimeout is the time to wait for the reply of a trace request
+	defaultTraceTimeout = 10 * time.Second
+	// traceReplyInterval is the minimum average interval between the replies to trace requests
+	traceReplyInterval = time.Second
+	// traceReplyBurst is the number of trace requests replied to at once
+	traceReplyBurst = 10
+)
+
+// TraceTopic is the built-in diagnostic topic for tracing the route of pss messages
+var TraceTopic = message.NewTopic([]byte("pss-traceroute"))
+
+var (
+	// ErrTraceTimeout is returned when no reply is received for a trace request in time
+	ErrTraceTimeout = errors.New("timeout waiting for trace reply")
+	// ErrTraceDisabled is returned by TraceRoute if tracing is not allowed in the pss params
+	ErrTraceDisabled = errors.New("pss tracing disabled")
+)
+
+// traceMsg is the payload of messages on the TraceTopic
+// Every node handling a message with the trace flag set appends its truncated
+// overlay address to Route before forwarding or processing the message
+type traceMsg struct {
+	ID      uint64   // identifies the trace request
+	Origin  []byte   // overlay address of the requesting node, used to route the reply
+	Sig     []byte   // signature of the ID and Origin by the requesting node, only set on requests
+	Reply   bool     // set if the message is the reply to a trace request
+	Forward [][]byte // route of the trace request, only set on replies
+	Route   [][]byte // route of this message
+}
+
+// TraceResult is the route a trace request and its reply took across the network
+type TraceResult struct {
+	Forward []hexutil.Bytes `json:"forward"` // truncated addresses of the nodes from the requester to the recipient
+	Return  []hexutil.Bytes `json:"return"`  // truncated addresses of the nodes from the recipient back to the requester
+}
+
+// tracer keeps track of pending trace requests
+type tracer struct {
+	mu      sync.Mutex
+	pending map[uint64]chan *traceMsg
+	replies *rate.Limiter // limits the replies to trace requests
+}
+
+func newTracer() *tracer {
+	return &tracer{
+		pending: make(map[uint64]chan *traceMsg),
+		replies: rate.NewLimiter(rate.Every(traceReplyInterval), traceReplyBurst),
+	}
+}
+
+// traceDigest returns the digest of the trace request signed by the requesting node
+func traceDigest(id uint64, origin []byte) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return crypto.Keccak256(b, origin)
+}
+
+// verifyTraceOrigin checks that the trace request is signed by the key of its origin
+// so that replies cannot be directed at other nodes
+// The overlay address of a node is the hash of its public key
+func verifyTraceOrigin(tm *traceMsg) error {
+	pub, err := crypto.SigToPub(traceDigest(tm.ID, tm.Origin), tm.Sig)
+	if err != nil {
+		return fmt.Errorf("invalid trace request signature: %v", err)
+	}
+	if !bytes.Equal(crypto.Keccak256(crypto.FromECDSAPub(pub)), tm.Origin) {
+		return fmt.Errorf("trace request origin %x does not match its signature", tm.Origin)
+	}
+	return nil
+}
+
+// traceLabel returns the truncated address recorded in trace routes
+func traceLabel(addr []byte) []byte {
+	if len(addr) > traceAddrLength {
+		addr = addr[:traceAddrLength]
+	}
+	return append([]byte{}, addr...)
+}
+
+// isTrace returns true if the message is a trace message whose route should be recorded
+func isTrace(msg *message.Message) bool {
+	return msg.Flags.Trace && msg.Topic == TraceTopic
+}
+
+// recordTrace appends the truncated address of this node to the route of a trace message
+// It returns false if the address is already on the route, i.e. the message is looping
+func (p *Pss) recordTrace(msg *message.Message) bool {
+	var tm traceMsg
+	if err := rlp.DecodeBytes(msg.Payload, &tm); err != nil {
+		log.Debug("invalid trace message", "err", err)
+		return true
+	}
+	self := traceLabel(p.BaseAddr())
+	for _, hop := range tm.Route {
+		if bytes.Equal(hop, self) {
+			return false
+		}
+	}
+	tm.Route = append(tm.Route, self)
+	payload, err := rlp.EncodeToBytes(&tm)
+	if err != nil {
+		log.Error("could not encode trace message", "err", err)
+		return true
+	}
+	msg.Payload = payload
+	return true
+}
+
+// sendTrace sends a trace message to the given address
+func (p *Pss) sendTrace(to []byte, tm *traceMsg) error {
+	tm.Route = [][]byte{traceLabel(p.BaseAddr())}
+	payload, err := rlp.EncodeToBytes(tm)
+	if err != nil {
+		return err
+	}
+	msg := message.New(message.Flags{
+		Raw:   true,
+		Trace: true,
+	})
+	msg.To = to
+	msg.Expire = uint32(time.Now().Add(p.msgTTL).Unix())
+	msg.Topic = TraceTopic
+	msg.Payload = payload
+
+	p.addFwdCache(msg)
+	p.enqueue(msg)
+	return nil
+}
+
+// handleTrace is the handler of the TraceTopic
+// It replies to trace requests signed by their origin, at most as often as
+// traceReplyInterval allows, and delivers replies to the pending requests
+func (p *Pss) handleTrace(payload []byte, _ *p2p.Peer, _ bool, _ string) error {
+	var tm traceMsg
+	if err := rlp.DecodeBytes(payload, &tm); err != nil {
+		return fmt.Errorf("invalid trace message: %v", err)
+	}
+	if !tm.Reply {
+		if err := verifyTraceOrigin(&tm); err != nil {
+			return err
+		}
+		if !p.tracer.replies.Allow() {
+			metrics.GetOrRegisterCounter("pss/trace/reply/dropped", nil).Inc(1)
+			log.Debug("trace reply rate limited", "id", tm.ID)
+			return nil
+		}
+		return p.sendTrace(tm.Origin, &traceMsg{
+			ID:      tm.ID,
+			Origin:  tm.Origin,
+			Reply:   true,
+			Forward: tm.Route,
+		})
+	}
+	if !bytes.Equal(tm.Origin, p.BaseAddr()) {
+		return nil
+	}
+	p.tracer.mu.Lock()
+	c, ok := p.tracer.pending[tm.ID]
+	p.tracer.mu.Unlock()
+	if !ok {
+		log.Debug("trace reply for unknown request", "id", tm.ID)
+		return nil
+	}
+	select {
+	case c <- &tm:
+	default:
+	}
+	return nil
+}
+
+// TraceRoute sends a trace request to the given address and waits for the reply
+// The result contains the routes of the request and the reply as the truncated
+// overlay addresses of the nodes they passed, including the end points
+func (p *Pss) TraceRoute(ctx context.Context, to PssAddress) (*TraceResult, error) {
+	if p.tracer == nil {
+		return nil, ErrTraceDisabled
+	}
+	if err := validateAddress(to); err != nil {
+		return nil, err
+	}
+	id := rand.Uint64()
+	sig, err := crypto.Sign(traceDigest(id, p.BaseAddr()), p.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *traceMsg, 1)
+	p.tracer.mu.Lock()
+	p.tracer.pending[id] = c
+	p.tracer.mu.Unlock()
+	defer func() {
+		p.tracer.mu.Lock()
+		delete(p.tracer.pending, id)
+		p.tracer.mu.Unlock()
+	}()
+
+	if err := p.sendTrace(to, &traceMsg{
+		ID:     id,
+		Origin: p.BaseAddr(),
+		Sig:    sig,
+	}); err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTraceTimeout)
+		defer cancel()
+	}
+	select {
+	case tm := <-c:
+		return &TraceResult{
+			Forward: toHexBytes(tm.Forward),
+			Return:  toHexBytes(tm.Route),
+		}, nil
+	case <-ctx.Done():
+		return nil, ErrTraceTimeout
+	}
+}
+
+func toHexBytes(route [][]byte) []hexutil.Bytes {
+	hops := make([]hexutil.Bytes, len(route))
+	for i, hop := range route {
+		hops[i] = hexutil.Bytes(hop)
+	}
+	return hops
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 The Swarm authors
+// This file is part of the swarm library.
+//
+// The swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pss
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss/message"
+)
+
+// TestTraceRecord tests that handling a trace message appends the truncated node address
+// to its route and that looping trace messages are detected
+func TestTraceRecord(t *testing.T) {
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, nil)
+	defer ps.Stop()
+
+	origin := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	payload, err := rlp.EncodeToBytes(&traceMsg{
+		ID:     42,
+		Origin: origin,
+		Route:  [][]byte{traceLabel(origin)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := message.New(message.Flags{Raw: true, Trace: true})
+	msg.Topic = TraceTopic
+	msg.Payload = payload
+	if !isTrace(msg) {
+		t.Fatal("expected message to be a trace message")
+	}
+
+	if !ps.recordTrace(msg) {
+		t.Fatal("expected route to be recorded")
+	}
+	var tm traceMsg
+	if err := rlp.DecodeBytes(msg.Payload, &tm); err != nil {
+		t.Fatal(err)
+	}
+	if len(tm.Route) != 2 {
+		t.Fatalf("expected route of 2 hops, got %d", len(tm.Route))
+	}
+	if !bytes.Equal(tm.Route[1], ps.BaseAddr()[:traceAddrLength]) {
+		t.Fatalf("expected last hop %x, got %x", ps.BaseAddr()[:traceAddrLength], tm.Route[1])
+	}
+
+	if ps.recordTrace(msg) {
+		t.Fatal("expected loop to be detected")
+	}
+
+	msg.Flags.Trace = false
+	if isTrace(msg) {
+		t.Fatal("expected message without trace flag not to be a trace message")
+	}
+}
+
+// TestTraceReply tests that trace replies are delivered to the pending trace request
+func TestTraceReply(t *testing.T) {
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, &Params{AllowTrace: true})
+	defer ps.Stop()
+
+	c := make(chan *traceMsg, 1)
+	ps.tracer.pending[42] = c
+
+	forward := [][]byte{{0x01}, {0x02}}
+	route := [][]byte{{0x02}, {0x03}, {0x01}}
+	payload, err := rlp.EncodeToBytes(&traceMsg{
+		ID:      42,
+		Origin:  ps.BaseAddr(),
+		Reply:   true,
+		Forward: forward,
+		Route:   route,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.handleTrace(payload, nil, false, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tm := <-c:
+		if tm.ID != 42 || len(tm.Forward) != len(forward) || len(tm.Route) != len(route) {
+			t.Fatalf("unexpected trace reply %v", tm)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for trace reply")
+	}
+}
+
+// TestTraceDisabled tests that tracing is not allowed by default
+func TestTraceDisabled(t *testing.T) {
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, nil)
+	defer ps.Stop()
+
+	if _, err := ps.TraceRoute(context.Background(), PssAddress(ps.BaseAddr())); err != ErrTraceDisabled {
+		t.Fatalf("expected error %v, got %v", ErrTraceDisabled, err)
+	}
+}
+
+// TestTraceRequest tests that only trace requests signed by their origin are replied to,
+// and that the replies are rate limited
+func TestTraceRequest(t *testing.T) {
+	privkey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := newTestPss(privkey, nil, &Params{AllowTrace: true})
+	defer ps.Stop()
+
+	requester, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := network.PrivateKeyToBzzKey(requester)
+	request := func(origin []byte, key *ecdsa.PrivateKey) []byte {
+		t.Helper()
+		tm := &traceMsg{ID: 42, Origin: origin}
+		if key != nil {
+			tm.Sig, err = crypto.Sign(traceDigest(tm.ID, tm.Origin), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		payload, err := rlp.EncodeToBytes(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payload
+	}
+
+	if err := ps.handleTrace(request(origin, nil), nil, false, ""); err == nil {
+		t.Fatal("expected error for unsigned trace request")
+	}
+	if err := ps.handleTrace(request(ps.BaseAddr(), requester), nil, false, ""); err == nil {
+		t.Fatal("expected error for trace request with an origin not matching its signature")
+	}
+	for i := 0; i < traceReplyBurst; i++ {
+		if err := ps.handleTrace(request(origin, requester), nil, false, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ps.tracer.replies.Allow() {
+		t.Fatal("expected trace replies to be rate limited")
+	}
+}
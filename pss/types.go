@@ -53,8 +53,9 @@ func (a *PssAddress) UnmarshalJSON(input []byte) error {
 type HandlerFunc func(msg []byte, p *p2p.Peer, asymmetric bool, keyid string) error
 
 type handlerCaps struct {
-	raw  bool
-	prox bool
+	raw        bool
+	prox       bool
+	concurrent bool // run in the worker pool of the topic instead of the goroutine receiving the message
 }
 
 // Handler defines code to be executed upon reception of content.
@@ -83,6 +84,14 @@ func (h *handler) WithProxBin() *handler {
 	return h
 }
 
+// WithConcurrency is a chainable method that makes the handler run in the worker pool of the topic
+// instead of the goroutine receiving the message, so that a slow handler does not hold up the connection
+// The handler must then cope with messages handled concurrently and out of the order they arrive
+func (h *handler) WithConcurrency() *handler {
+	h.caps.concurrent = true
+	return h
+}
+
 // the stateStore handles saving and loading PSS peers and their corresponding keys
 // it is currently unimplemented
 type stateStore struct {
@@ -26,8 +26,10 @@ import (
 )
 
 const (
-	pssChunkTopic   = "PUSHSYNC_CHUNKS"   // pss topic for chunks
-	pssReceiptTopic = "PUSHSYNC_RECEIPTS" // pss topic for statement of custody receipts
+	pssChunkTopic          = "PUSHSYNC_CHUNKS"           // pss topic for chunks
+	pssReceiptTopic        = "PUSHSYNC_RECEIPTS"         // pss topic for statement of custody receipts
+	pssReplicaTopic        = "PUSHSYNC_REPLICAS"         // pss topic for replicas of chunks sent within the neighbourhood
+	pssReplicaReceiptTopic = "PUSHSYNC_REPLICA_RECEIPTS" // pss topic for acknowledgements of stored replicas
 )
 
 // PubSub is a Postal Service interface needed to send/receive chunks and receipts for push syncing
@@ -0,0 +1,322 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// maxReplicatedChunks is the number of most recently stored chunks whose replicas are tracked
+// older chunks are left to be replicated by pull syncing only
+const maxReplicatedChunks = 10000
+
+// ReplicaStore is the storage interface of the replicator to save replicas and load
+// the chunks that are replicated again, localstore implements it
+// The replicas are received from other nodes, so the store is expected to validate
+// the chunks it saves, as chunk.ValidatorStore does
+type ReplicaStore interface {
+	Put(context.Context, chunk.ModePut, ...chunk.Chunk) ([]bool, error)
+	Get(context.Context, chunk.ModeGet, chunk.Address) (chunk.Chunk, error)
+}
+
+// Neighbourhood is the interface to the peers of the neighbourhood of the node
+type Neighbourhood interface {
+	// NearestPeers returns the addresses of at most n peers within the neighbourhood
+	// depth of the node, nearest to addr first
+	NearestPeers(addr []byte, n int) [][]byte
+	// IsWithinDepth reports whether addr is within the neighbourhood depth of the node
+	IsWithinDepth(addr []byte) bool
+	// SubscribeToPeerChanges returns a channel signalling that peers connected or
+	// disconnected, and a function to unsubscribe
+	SubscribeToPeerChanges() (<-chan struct{}, func())
+}
+
+// replicaMsg is the message sending a replica of a chunk to a peer in the neighbourhood
+// The replicas are only sent to connected peers, so the peer the message is received
+// from is the storer replicating the chunk, which the receipt is sent to
+type replicaMsg struct {
+	Addr  []byte // chunk address
+	Data  []byte // chunk data
+	Nonce []byte // nonce to make multiple instances of send immune to deduplication cache
+}
+
+// replicaReceiptMsg acknowledges that a replica is stored by the peer the message is received from
+type replicaReceiptMsg struct {
+	Addr  []byte // chunk address
+	Nonce []byte // nonce to make multiple instances of send immune to deduplication cache
+}
+
+// replica is a chunk replicated in the neighbourhood
+type replica struct {
+	acked map[string]bool // addresses of the peers that acknowledged storing the replica
+}
+
+// Replicator replicates the chunks a storer is the closest node to within its
+// neighbourhood, so that the nearest replicas-1 peers store them in addition to itself
+// The peers acknowledge the replicas they store, and the chunks are replicated again
+// to the peers that did not acknowledge them once the peers of the neighbourhood change
+type Replicator struct {
+	store       ReplicaStore
+	ps          PubSub
+	nh          Neighbourhood
+	replicas    int                 // number of nodes storing the chunk, self included
+	mtx         sync.Mutex          // guards tracked and order
+	tracked     map[string]*replica // replicas by chunk address
+	order       []string            // chunk addresses in the order they are tracked
+	deregister  []func()            // deregister the registered handlers and subscriptions
+	quit        chan struct{}
+	wg          sync.WaitGroup
+	logger      log.Logger
+	replicateMu sync.Mutex // replicating all chunks again is not run concurrently
+}
+
+// NewReplicator constructs a Replicator keeping the given number of replicas of the
+// chunks passed to Replicate, the node included, and starts following the changes
+// of the neighbourhood
+func NewReplicator(store ReplicaStore, ps PubSub, nh Neighbourhood, replicas int) *Replicator {
+	r := &Replicator{
+		store:    store,
+		ps:       ps,
+		nh:       nh,
+		replicas: replicas,
+		tracked:  make(map[string]*replica),
+		quit:     make(chan struct{}),
+		logger:   log.New("self", label(ps.BaseAddr())),
+	}
+	r.deregister = append(r.deregister,
+		ps.Register(pssReplicaTopic, false, func(msg []byte, p *p2p.Peer) error {
+			from, err := senderAddr(p)
+			if err != nil {
+				return err
+			}
+			return r.handleReplicaMsg(msg, from)
+		}),
+		ps.Register(pssReplicaReceiptTopic, false, func(msg []byte, p *p2p.Peer) error {
+			from, err := senderAddr(p)
+			if err != nil {
+				return err
+			}
+			return r.handleReplicaReceiptMsg(msg, from)
+		}),
+	)
+	changes, unsubscribe := nh.SubscribeToPeerChanges()
+	r.deregister = append(r.deregister, unsubscribe)
+	r.wg.Add(1)
+	go r.run(changes)
+	return r
+}
+
+// Close stops replicating and deregisters the handlers
+func (r *Replicator) Close() {
+	close(r.quit)
+	for _, deregister := range r.deregister {
+		deregister()
+	}
+	r.wg.Wait()
+}
+
+// Replicate sends the chunk to the nearest peers of the neighbourhood
+func (r *Replicator) Replicate(ch chunk.Chunk) {
+	r.mtx.Lock()
+	rep := r.track(ch.Address())
+	r.mtx.Unlock()
+	r.send(ch, rep)
+}
+
+// track returns the replica of the chunk, tracking it if it is not yet
+// the caller is expected to hold the lock
+func (r *Replicator) track(addr chunk.Address) *replica {
+	key := string(addr)
+	if rep, ok := r.tracked[key]; ok {
+		return rep
+	}
+	if len(r.order) >= maxReplicatedChunks {
+		delete(r.tracked, r.order[0])
+		r.order = r.order[1:]
+	}
+	rep := &replica{acked: make(map[string]bool)}
+	r.tracked[key] = rep
+	r.order = append(r.order, key)
+	return rep
+}
+
+// untrack stops tracking the replicas of a chunk that is no longer stored
+func (r *Replicator) untrack(addr chunk.Address) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	key := string(addr)
+	if _, ok := r.tracked[key]; !ok {
+		return
+	}
+	delete(r.tracked, key)
+	for i, k := range r.order {
+		if k == key {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// missing returns the nearest peers that did not acknowledge storing the replica
+func (r *Replicator) missing(addr chunk.Address, rep *replica) [][]byte {
+	peers := r.nh.NearestPeers(addr, r.replicas-1)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	var missing [][]byte
+	for _, peer := range peers {
+		if !rep.acked[string(peer)] {
+			missing = append(missing, peer)
+		}
+	}
+	return missing
+}
+
+// send sends the chunk to the nearest peers that did not acknowledge storing it
+func (r *Replicator) send(ch chunk.Chunk, rep *replica) {
+	for _, peer := range r.missing(ch.Address(), rep) {
+		msg, err := rlp.EncodeToBytes(&replicaMsg{
+			Addr:  ch.Address(),
+			Data:  ch.Data(),
+			Nonce: newNonce(),
+		})
+		if err != nil {
+			r.logger.Error("replicator: encode replica", "ref", label(ch.Address()), "err", err)
+			return
+		}
+		metrics.GetOrRegisterCounter("pushsync/replicator/send", nil).Inc(1)
+		if err := r.ps.Send(peer, pssReplicaTopic, msg); err != nil {
+			r.logger.Debug("replicator: send replica", "ref", label(ch.Address()), "to", label(peer), "err", err)
+		}
+	}
+}
+
+// run replicates the tracked chunks again whenever the peers change
+func (r *Replicator) run(changes <-chan struct{}) {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.quit:
+			return
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			r.replicateAll()
+		}
+	}
+}
+
+// replicateAll sends the tracked chunks to the nearest peers that did not
+// acknowledge storing them
+func (r *Replicator) replicateAll() {
+	r.replicateMu.Lock()
+	defer r.replicateMu.Unlock()
+
+	r.mtx.Lock()
+	keys := make([]string, len(r.order))
+	copy(keys, r.order)
+	r.mtx.Unlock()
+
+	for _, key := range keys {
+		select {
+		case <-r.quit:
+			return
+		default:
+		}
+		addr := chunk.Address(key)
+		r.mtx.Lock()
+		rep, ok := r.tracked[key]
+		r.mtx.Unlock()
+		if !ok || len(r.missing(addr, rep)) == 0 {
+			continue
+		}
+		ch, err := r.store.Get(context.Background(), chunk.ModeGetSync, addr)
+		if err != nil {
+			// the chunk is garbage collected, it is not in the neighbourhood of the node anymore
+			r.logger.Trace("replicator: chunk not stored", "ref", label(addr), "err", err)
+			r.untrack(addr)
+			continue
+		}
+		metrics.GetOrRegisterCounter("pushsync/replicator/resend", nil).Inc(1)
+		r.send(ch, rep)
+	}
+}
+
+// senderAddr returns the overlay address of the peer a message is received from,
+// which pss sets as the name of the peer passed to the handlers
+func senderAddr(p *p2p.Peer) ([]byte, error) {
+	if p == nil {
+		return nil, errors.New("unknown sender")
+	}
+	addr, err := hex.DecodeString(p.Name())
+	if err != nil || len(addr) == 0 {
+		return nil, fmt.Errorf("invalid sender %q", p.Name())
+	}
+	return addr, nil
+}
+
+// handleReplicaMsg stores the replica of a chunk and acknowledges it to the peer replicating it
+func (r *Replicator) handleReplicaMsg(msg []byte, from []byte) error {
+	var rmsg replicaMsg
+	if err := rlp.DecodeBytes(msg, &rmsg); err != nil {
+		return err
+	}
+	r.logger.Trace("handleReplicaMsg", "ref", label(rmsg.Addr), "from", label(from))
+	// only the chunks within the neighbourhood of the node are replicated to it
+	if !r.nh.IsWithinDepth(rmsg.Addr) {
+		metrics.GetOrRegisterCounter("pushsync/replicator/replica_dropped", nil).Inc(1)
+		return fmt.Errorf("replica of chunk %s outside the neighbourhood from %s", label(rmsg.Addr), label(from))
+	}
+	if _, err := r.store.Put(context.Background(), chunk.ModePutSync, storage.NewChunk(rmsg.Addr, rmsg.Data)); err != nil {
+		return err
+	}
+	receipt, err := rlp.EncodeToBytes(&replicaReceiptMsg{
+		Addr:  rmsg.Addr,
+		Nonce: newNonce(),
+	})
+	if err != nil {
+		return err
+	}
+	return r.ps.Send(from, pssReplicaReceiptTopic, receipt)
+}
+
+// handleReplicaReceiptMsg records that a peer stored a replica
+func (r *Replicator) handleReplicaReceiptMsg(msg []byte, from []byte) error {
+	var rmsg replicaReceiptMsg
+	if err := rlp.DecodeBytes(msg, &rmsg); err != nil {
+		return err
+	}
+	r.logger.Trace("handleReplicaReceiptMsg", "ref", label(rmsg.Addr), "storer", hex.EncodeToString(from))
+	metrics.GetOrRegisterCounter("pushsync/replicator/receipt", nil).Inc(1)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if rep, ok := r.tracked[string(rmsg.Addr)]; ok {
+		rep.acked[string(from)] = true
+	}
+	return nil
+}
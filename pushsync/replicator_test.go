@@ -0,0 +1,283 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pushsync
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// TestReplicator tests that the chunks are replicated to the nearest peers of the neighbourhood,
+// and replicated again to the peers that did not store them once the neighbourhood changes
+func TestReplicator(t *testing.T) {
+	router := newReplicaRouter()
+	nodes := make(map[string]*replicaTestNode)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		nodes[name] = router.add(name)
+	}
+	router.add("e") // without a replicator
+	a := nodes["a"]
+	nh := &testNeighbourhood{peers: [][]byte{[]byte("b"), []byte("c")}, changes: make(chan struct{}, 1)}
+
+	var replicators []*Replicator
+	for _, name := range []string{"a", "b", "c", "d"} {
+		n := nodes[name]
+		var neighbourhood Neighbourhood = &testNeighbourhood{changes: make(chan struct{})}
+		if name == "a" {
+			neighbourhood = nh
+		}
+		replicators = append(replicators, NewReplicator(n.store, n, neighbourhood, 3))
+	}
+	defer func() {
+		for _, r := range replicators {
+			r.Close()
+		}
+	}()
+	r := replicators[0]
+
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	if _, err := a.store.Put(context.Background(), chunk.ModePutSync, ch); err != nil {
+		t.Fatal(err)
+	}
+	r.Replicate(ch)
+	checkReplicas(t, nodes, ch.Address(), map[string]bool{"a": true, "b": true, "c": true})
+	checkSent(t, router, map[string]int{"b": 1, "c": 1})
+
+	// c leaves the neighbourhood and d joins it
+	nh.setPeers([]byte("b"), []byte("d"))
+	waitFor(t, func() bool { return nodes["d"].store.has(ch.Address()) })
+	checkReplicas(t, nodes, ch.Address(), map[string]bool{"a": true, "b": true, "c": true, "d": true})
+	checkSent(t, router, map[string]int{"b": 1, "c": 1, "d": 1})
+
+	// the chunk is not replicated once it is garbage collected
+	a.store.remove(ch.Address())
+	nh.setPeers([]byte("d"), []byte("e"))
+	waitFor(t, func() bool {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		return len(r.tracked) == 0
+	})
+	checkSent(t, router, map[string]int{"b": 1, "c": 1, "d": 1})
+}
+
+// TestReplicatorOutsideNeighbourhood tests that the replicas of
+// chunks outside the neighbourhood depth are not stored
+func TestReplicatorOutsideNeighbourhood(t *testing.T) {
+	router := newReplicaRouter()
+	a := router.add("a")
+	b := router.add("b")
+	ch := storage.GenerateRandomChunk(chunk.DefaultSize)
+	nh := &testNeighbourhood{outside: map[string]bool{string(ch.Address()): true}, changes: make(chan struct{})}
+	r := NewReplicator(a.store, a, nh, 2)
+	defer r.Close()
+
+	msg, err := rlp.EncodeToBytes(&replicaMsg{Addr: ch.Address(), Data: ch.Data(), Nonce: newNonce()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(a.addr, pssReplicaTopic, msg); err == nil {
+		t.Fatal("expected error for a replica from outside the neighbourhood")
+	}
+	if a.store.has(ch.Address()) {
+		t.Fatal("replica from outside the neighbourhood stored")
+	}
+}
+
+// checkReplicas checks which of the nodes stored the chunk
+func checkReplicas(t *testing.T, nodes map[string]*replicaTestNode, addr chunk.Address, want map[string]bool) {
+	t.Helper()
+	for name, n := range nodes {
+		if got := n.store.has(addr); got != want[name] {
+			t.Errorf("node %s: got chunk stored %v, want %v", name, got, want[name])
+		}
+	}
+}
+
+// checkSent checks the number of replicas sent to the nodes
+func checkSent(t *testing.T, router *replicaRouter, want map[string]int) {
+	t.Helper()
+	router.mtx.Lock()
+	defer router.mtx.Unlock()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if got := router.sent[name]; got != want[name] {
+			t.Errorf("node %s: got %v replicas sent, want %v", name, got, want[name])
+		}
+	}
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// replicaRouter delivers the messages sent to a node to the handlers it registered
+type replicaRouter struct {
+	mtx   sync.Mutex
+	nodes map[string]*replicaTestNode
+	sent  map[string]int // replicas sent to the nodes
+}
+
+func newReplicaRouter() *replicaRouter {
+	return &replicaRouter{
+		nodes: make(map[string]*replicaTestNode),
+		sent:  make(map[string]int),
+	}
+}
+
+func (rr *replicaRouter) add(name string) *replicaTestNode {
+	n := &replicaTestNode{
+		router:   rr,
+		addr:     []byte(name),
+		store:    &testReplicaStore{chunks: make(map[string][]byte)},
+		handlers: make(map[string]func([]byte, *p2p.Peer) error),
+	}
+	rr.nodes[name] = n
+	return n
+}
+
+// replicaTestNode implements PubSub for a node of the replicaRouter
+type replicaTestNode struct {
+	router   *replicaRouter
+	addr     []byte
+	store    *testReplicaStore
+	mtx      sync.Mutex
+	handlers map[string]func([]byte, *p2p.Peer) error
+}
+
+func (n *replicaTestNode) Register(topic string, _ bool, handler func(msg []byte, p *p2p.Peer) error) func() {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.handlers[topic] = handler
+	return func() {
+		n.mtx.Lock()
+		defer n.mtx.Unlock()
+		delete(n.handlers, topic)
+	}
+}
+
+func (n *replicaTestNode) Send(to []byte, topic string, msg []byte) error {
+	rr := n.router
+	rr.mtx.Lock()
+	dst := rr.nodes[string(to)]
+	if topic == pssReplicaTopic {
+		rr.sent[string(to)]++
+	}
+	rr.mtx.Unlock()
+	dst.mtx.Lock()
+	handler := dst.handlers[topic]
+	dst.mtx.Unlock()
+	if handler == nil {
+		return nil
+	}
+	// pss passes the overlay address of the sender as the name of the peer
+	return handler(msg, p2p.NewPeer(enode.ID{}, hex.EncodeToString(n.addr), nil))
+}
+
+func (n *replicaTestNode) BaseAddr() []byte {
+	return n.addr
+}
+
+func (n *replicaTestNode) IsClosestTo([]byte) bool {
+	return true
+}
+
+// testNeighbourhood implements Neighbourhood with the peers set by the test
+type testNeighbourhood struct {
+	mtx     sync.Mutex
+	peers   [][]byte
+	outside map[string]bool // addresses outside the neighbourhood depth, every other address is within
+	changes chan struct{}
+}
+
+func (nh *testNeighbourhood) IsWithinDepth(addr []byte) bool {
+	return !nh.outside[string(addr)]
+}
+
+func (nh *testNeighbourhood) NearestPeers(_ []byte, n int) [][]byte {
+	nh.mtx.Lock()
+	defer nh.mtx.Unlock()
+	if len(nh.peers) > n {
+		return nh.peers[:n]
+	}
+	return nh.peers
+}
+
+func (nh *testNeighbourhood) SubscribeToPeerChanges() (<-chan struct{}, func()) {
+	return nh.changes, func() {}
+}
+
+func (nh *testNeighbourhood) setPeers(peers ...[]byte) {
+	nh.mtx.Lock()
+	nh.peers = peers
+	nh.mtx.Unlock()
+	nh.changes <- struct{}{}
+}
+
+// testReplicaStore implements ReplicaStore
+type testReplicaStore struct {
+	mtx    sync.Mutex
+	chunks map[string][]byte
+}
+
+func (s *testReplicaStore) Put(_ context.Context, _ chunk.ModePut, chs ...chunk.Chunk) ([]bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	exist := make([]bool, len(chs))
+	for i, ch := range chs {
+		_, exist[i] = s.chunks[string(ch.Address())]
+		s.chunks[string(ch.Address())] = ch.Data()
+	}
+	return exist, nil
+}
+
+func (s *testReplicaStore) Get(_ context.Context, _ chunk.ModeGet, addr chunk.Address) (chunk.Chunk, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	data, ok := s.chunks[string(addr)]
+	if !ok {
+		return nil, chunk.ErrChunkNotFound
+	}
+	return chunk.NewChunk(addr, data), nil
+}
+
+func (s *testReplicaStore) has(addr chunk.Address) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	_, ok := s.chunks[string(addr)]
+	return ok
+}
+
+func (s *testReplicaStore) remove(addr chunk.Address) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.chunks, string(addr))
+}
@@ -201,7 +201,7 @@ func newServiceFunc(ctx *adapters.ServiceContext, bucket *sync.Map) (node.Servic
 
 	bucket.Store(bucketKeyNetStore, netStore)
 
-	r := retrieval.New(kad, netStore, addr, nil)
+	r := retrieval.New(kad, netStore, addr, nil, nil)
 	netStore.RemoteGet = r.RequestFromPeers
 
 	pubSub := pss.NewPubSub(ps, 1*time.Second)
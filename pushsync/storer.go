@@ -41,6 +41,7 @@ type Storer struct {
 	ps         PubSub     // pubsub interface to receive chunks and send receipts
 	deregister func()     // deregister the registered handler when Storer is closed
 	logger     log.Logger // custom logger
+	replicator *Replicator
 }
 
 // NewStorer constructs a Storer
@@ -62,6 +63,13 @@ func NewStorer(store Store, ps PubSub) *Storer {
 	return s
 }
 
+// SetReplicator makes the storer replicate the chunks it is the closest node to
+// within its neighbourhood
+// It is expected to be called before any chunk is received
+func (s *Storer) SetReplicator(r *Replicator) {
+	s.replicator = r
+}
+
 // Close needs to be called to deregister the handler
 func (s *Storer) Close() {
 	s.deregister()
@@ -101,6 +109,9 @@ func (s *Storer) processChunkMsg(ctx context.Context, chmsg *chunkMsg) error {
 	// if self is closest peer then send back a receipt
 	if s.ps.IsClosestTo(chmsg.Addr) {
 		s.logger.Trace("self is closest to ref", "ref", label(chmsg.Addr))
+		if s.replicator != nil {
+			s.replicator.Replicate(ch)
+		}
 		return s.sendReceiptMsg(ctx, chmsg)
 	}
 	return nil
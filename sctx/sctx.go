@@ -1,6 +1,10 @@
 package sctx
 
-import "context"
+import (
+	"context"
+
+	"github.com/pborman/uuid"
+)
 
 type (
 	HTTPRequestIDKey struct{}
@@ -8,6 +12,27 @@ type (
 	tagKey           struct{}
 )
 
+// NewRequestID returns a random identifier for an HTTP or RPC request
+func NewRequestID() string {
+	return uuid.New()[:8]
+}
+
+// SetRequestID sets the identifier of the HTTP or RPC request in the context,
+// so that the logs of all subsystems serving the request can be correlated
+func SetRequestID(ctx context.Context, ruid string) context.Context {
+	return context.WithValue(ctx, HTTPRequestIDKey{}, ruid)
+}
+
+// GetRequestID gets the identifier of the HTTP or RPC request from the context,
+// it is empty if the context does not belong to a request
+func GetRequestID(ctx context.Context) string {
+	v, ok := ctx.Value(HTTPRequestIDKey{}).(string)
+	if ok {
+		return v
+	}
+	return ""
+}
+
 // SetHost sets the http request host in the context
 func SetHost(ctx context.Context, domain string) context.Context {
 	return context.WithValue(ctx, requestHostKey{}, domain)
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
@@ -45,8 +46,9 @@ const (
 // It provides a schema functionality to store fields and indexes
 // information about naming and types.
 type DB struct {
-	ldb  *leveldb.DB
-	quit chan struct{} // Quit channel to stop the metrics collection before closing the database
+	ldb      *leveldb.DB
+	updateMu sync.Mutex    // serializes Update calls
+	quit     chan struct{} // Quit channel to stop the metrics collection before closing the database
 }
 
 // NewDB constructs a new DB and validates the schema
@@ -151,6 +153,34 @@ func (db *DB) WriteBatch(batch *leveldb.Batch) (err error) {
 	return nil
 }
 
+// WriteBatchSync is the same as WriteBatch, but it returns only after
+// the batch is synced to the underlying storage.
+func (db *DB) WriteBatchSync(batch *leveldb.Batch) (err error) {
+	err = db.ldb.Write(batch, &opt.WriteOptions{Sync: true})
+	if err != nil {
+		metrics.GetOrRegisterCounter("DB/writebatchsyncFail", nil).Inc(1)
+		return err
+	}
+	metrics.GetOrRegisterCounter("DB/writebatchsync", nil).Inc(1)
+	return nil
+}
+
+// Update calls fn with a new batch and writes the batch to the database
+// only if fn returns no error. It allows multiple fields and indexes to be
+// updated atomically. Update calls are serialized, so fields that are read
+// in fn and updated in the batch are consistent with other Update calls.
+// Writes that do not use Update are not serialized with it.
+func (db *DB) Update(fn func(batch *leveldb.Batch) error) (err error) {
+	db.updateMu.Lock()
+	defer db.updateMu.Unlock()
+
+	batch := new(leveldb.Batch)
+	if err = fn(batch); err != nil {
+		return err
+	}
+	return db.WriteBatch(batch)
+}
+
 // Close closes LevelDB database.
 func (db *DB) Close() (err error) {
 	close(db.quit)
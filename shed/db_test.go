@@ -17,9 +17,14 @@
 package shed
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // TestNewDB constructs a new DB
@@ -90,6 +95,89 @@ func TestDB_persistence(t *testing.T) {
 	}
 }
 
+// TestDB_Update validates that fields put in the batch of Update
+// are written together only when the update function succeeds,
+// and that concurrent updates of the same fields are consistent.
+func TestDB_Update(t *testing.T) {
+	db, cleanupFunc := newTestDB(t)
+	defer cleanupFunc()
+
+	counter, err := db.NewUint64Field("counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := db.NewStringField("name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		err := db.Update(func(batch *leveldb.Batch) error {
+			if _, err := counter.IncInBatch(batch); err != nil {
+				return err
+			}
+			name.PutInBatch(batch, "discarded")
+			return testErr
+		})
+		if err != testErr {
+			t.Fatalf("got error %v, want %v", err, testErr)
+		}
+		got, err := counter.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 0 {
+			t.Errorf("got counter %v, want %v", got, 0)
+		}
+		gotName, err := name.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotName != "" {
+			t.Errorf("got name %q, want empty", gotName)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		const count = 50
+		var wg sync.WaitGroup
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := db.Update(func(batch *leveldb.Batch) error {
+					c, err := counter.IncInBatch(batch)
+					if err != nil {
+						return err
+					}
+					name.PutInBatch(batch, strconv.FormatUint(c, 10))
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		gotCounter, err := counter.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotCounter != count {
+			t.Errorf("got counter %v, want %v", gotCounter, count)
+		}
+		gotName, err := name.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strconv.FormatUint(count, 10); gotName != want {
+			t.Errorf("got name %q, want %q", gotName, want)
+		}
+	})
+}
+
 // newTestDB is a helper function that constructs a
 // temporary database and returns a cleanup function that must
 // be called to remove the data.
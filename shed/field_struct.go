@@ -17,27 +17,46 @@
 package shed
 
 import (
+	"encoding/json"
+
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // StructField is a helper to store complex structure by
-// encoding it in RLP format.
+// encoding it in RLP or JSON format.
 type StructField struct {
-	db  *DB
-	key []byte
+	db     *DB
+	key    []byte
+	encode func(val interface{}) ([]byte, error)
+	decode func(b []byte, val interface{}) error
 }
 
-// NewStructField returns a new StructField.
+// NewStructField returns a new StructField that encodes
+// values in RLP format.
 // It validates its name and type against the database schema.
 func (db *DB) NewStructField(name string) (f StructField, err error) {
-	key, err := db.schemaFieldKey(name, "struct-rlp")
+	return db.newStructField(name, "struct-rlp", rlp.EncodeToBytes, rlp.DecodeBytes)
+}
+
+// NewJSONStructField returns a new StructField that encodes
+// values in JSON format. It is suitable for structures with
+// fields that RLP does not support, like signed integers or time.
+// It validates its name and type against the database schema.
+func (db *DB) NewJSONStructField(name string) (f StructField, err error) {
+	return db.newStructField(name, "struct-json", json.Marshal, json.Unmarshal)
+}
+
+func (db *DB) newStructField(name, fieldType string, encode func(val interface{}) ([]byte, error), decode func(b []byte, val interface{}) error) (f StructField, err error) {
+	key, err := db.schemaFieldKey(name, fieldType)
 	if err != nil {
 		return f, err
 	}
 	return StructField{
-		db:  db,
-		key: key,
+		db:     db,
+		key:    key,
+		encode: encode,
+		decode: decode,
 	}, nil
 }
 
@@ -48,12 +67,12 @@ func (f StructField) Get(val interface{}) (err error) {
 	if err != nil {
 		return err
 	}
-	return rlp.DecodeBytes(b, val)
+	return f.decode(b, val)
 }
 
 // Put marshals provided val and saves it to the database.
 func (f StructField) Put(val interface{}) (err error) {
-	b, err := rlp.EncodeToBytes(val)
+	b, err := f.encode(val)
 	if err != nil {
 		return err
 	}
@@ -62,7 +81,7 @@ func (f StructField) Put(val interface{}) (err error) {
 
 // PutInBatch marshals provided val and puts it into the batch.
 func (f StructField) PutInBatch(batch *leveldb.Batch, val interface{}) (err error) {
-	b, err := rlp.EncodeToBytes(val)
+	b, err := f.encode(val)
 	if err != nil {
 		return err
 	}
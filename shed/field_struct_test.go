@@ -18,6 +18,7 @@ package shed
 
 import (
 	"testing"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -125,3 +126,51 @@ func TestStructField(t *testing.T) {
 		})
 	})
 }
+
+// TestJSONStructField validates put and get operations
+// of the StructField with JSON encoding and that it is
+// not interchangeable with the RLP encoded field.
+func TestJSONStructField(t *testing.T) {
+	db, cleanupFunc := newTestDB(t)
+	defer cleanupFunc()
+
+	jsonField, err := db.NewJSONStructField("json-field")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type complexStructure struct {
+		A string
+		B int64
+		C time.Time
+	}
+
+	var s complexStructure
+	err = jsonField.Get(&s)
+	if err != leveldb.ErrNotFound {
+		t.Fatalf("got error %v, want %v", err, leveldb.ErrNotFound)
+	}
+
+	want := complexStructure{
+		A: "simple string value",
+		B: -42,
+		C: time.Unix(1565000000, 0).UTC(),
+	}
+	err = jsonField.Put(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got complexStructure
+	err = jsonField.Get(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.A != want.A || got.B != want.B || !got.C.Equal(want.C) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	_, err = db.NewStructField("json-field")
+	if err == nil {
+		t.Error("got no error creating an rlp field with the name of a json field")
+	}
+}
@@ -18,11 +18,18 @@ package shed
 
 import (
 	"bytes"
+	"time"
 
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// statsSampleSize is the number of keys counted by Index.Stats
+// before the number of the other keys is estimated
+const statsSampleSize = 1000
+
 // Item holds fields relevant to Swarm Chunk data and metadata.
 // All information required for swarm storage and operations
 // on that storage must be defined here.
@@ -449,3 +456,69 @@ func (f Index) CountFrom(start Item) (count int, err error) {
 	}
 	return count, it.Error()
 }
+
+// IndexStats are the statistics of an Index
+type IndexStats struct {
+	Keys        int   // number of keys, estimated if Approximate is true
+	DiskSize    int64 // approximate size of the keys and values in the table files, not including writes still in the journal
+	Approximate bool  // only the first keys were counted and the number of the others estimated
+}
+
+// Stats returns the number of keys and the size on disk of the index.
+// Only the first keys are counted, the number of the others is estimated
+// from the size on disk of the counted ones, if it is known.
+func (f Index) Stats() (stats IndexStats, err error) {
+	r := f.keyRange(nil)
+	sizes, err := f.db.ldb.SizeOf([]util.Range{r})
+	if err != nil {
+		return stats, err
+	}
+	stats.DiskSize = sizes.Sum()
+
+	it := f.db.NewIterator()
+	defer it.Release()
+
+	for ok := it.Seek(r.Start); ok; ok = it.Next() {
+		key := it.Key()
+		if key[0] != f.prefix[0] {
+			break
+		}
+		stats.Keys++
+		if stats.Keys != statsSampleSize {
+			continue
+		}
+		// the size of the sampled keys is not known while they are only in the journal
+		sampled, err := f.db.ldb.SizeOf([]util.Range{{Start: r.Start, Limit: append(append([]byte(nil), key...), 0)}})
+		if err != nil {
+			return stats, err
+		}
+		if size := sampled.Sum(); size > 0 && stats.DiskSize > size {
+			stats.Keys = int(int64(stats.Keys) * stats.DiskSize / size)
+			stats.Approximate = true
+			return stats, nil
+		}
+	}
+	return stats, it.Error()
+}
+
+// Compact compacts the keys of the index starting with the prefix, all of them if
+// the prefix is nil. Compaction removes the deleted keys from disk, which otherwise
+// amplify the reads of the index until they are compacted in the background.
+func (f Index) Compact(prefix []byte) (err error) {
+	start := time.Now()
+	err = f.db.ldb.CompactRange(f.keyRange(prefix))
+	if err != nil {
+		metrics.GetOrRegisterCounter("DB/compactFail", nil).Inc(1)
+		return err
+	}
+	metrics.GetOrRegisterCounter("DB/compact", nil).Inc(1)
+	metrics.GetOrRegisterResettingTimer("DB/compact/time", nil).UpdateSince(start)
+	return nil
+}
+
+// keyRange returns the range of the keys of the index starting with the prefix.
+func (f Index) keyRange(prefix []byte) util.Range {
+	start := append(append(make([]byte, 0, len(f.prefix)+len(prefix)), f.prefix...), prefix...)
+	// the limit is nil, the end of the database, if the start can not be incremented
+	return util.Range{Start: start, Limit: incByteSlice(start)}
+}
@@ -1104,3 +1104,75 @@ func TestIndex_HasMulti(t *testing.T) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 }
+
+// TestIndex_StatsAndCompact validates that Index.Stats reports the number of keys
+// and the size on disk of the index, and Index.Compact reclaims the space of deleted keys.
+func TestIndex_StatsAndCompact(t *testing.T) {
+	db, cleanupFunc := newTestDB(t)
+	defer cleanupFunc()
+
+	index, err := db.NewIndex("retrieval", retrievalIndexFuncs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 5000
+	batch := new(leveldb.Batch)
+	for i := 0; i < count; i++ {
+		index.PutInBatch(batch, Item{
+			Address: []byte(fmt.Sprintf("stats-hash-%05d", i)),
+			Data:    bytes.Repeat([]byte{byte(i)}, 100),
+		})
+	}
+	if err := db.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys are only in the journal, so they are all counted
+	stats, err := index.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != count || stats.Approximate {
+		t.Errorf("got %v keys, approximate %v, want %v exactly", stats.Keys, stats.Approximate, count)
+	}
+
+	// the compacted keys are in the table files, so their number is estimated
+	if err := index.Compact(nil); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = index.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DiskSize == 0 {
+		t.Error("got no size on disk after compaction")
+	}
+	if stats.Keys < count*8/10 || stats.Keys > count*12/10 || !stats.Approximate {
+		t.Errorf("got %v keys, approximate %v, want about %v", stats.Keys, stats.Approximate, count)
+	}
+	size := stats.DiskSize
+
+	batch = new(leveldb.Batch)
+	for i := 0; i < count; i++ {
+		index.DeleteInBatch(batch, Item{
+			Address: []byte(fmt.Sprintf("stats-hash-%05d", i)),
+		})
+	}
+	if err := db.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Compact([]byte("stats-hash-0")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = index.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Keys != 0 {
+		t.Errorf("got %v keys, want 0", stats.Keys)
+	}
+	if stats.DiskSize >= size {
+		t.Errorf("got size on disk %v after compaction, want less than %v", stats.DiskSize, size)
+	}
+}
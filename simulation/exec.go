@@ -1,22 +1,29 @@
 package simulation
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm"
+	"github.com/ethersphere/swarm/log"
 )
 
 // ExecAdapter can manage local exec nodes
 type ExecAdapter struct {
 	config ExecAdapterConfig
+	logs   *logFeed
 }
 
 // ExecAdapterConfig is used to configure an ExecAdapter
@@ -25,6 +32,12 @@ type ExecAdapterConfig struct {
 	ExecutablePath string `json:"executable"`
 	// BaseDataDirectory stores all the nodes' data directories
 	BaseDataDirectory string `json:"basedir"`
+	// Env is injected into the environment of every node.
+	// Variables defined in the node configuration take precedence.
+	Env []string `json:"env,omitempty"`
+	// Limits are the default resource limits of every node.
+	// Limits defined in the node configuration take precedence.
+	Limits *ResourceLimits `json:"limits,omitempty"`
 }
 
 // ExecNode is a node that is executed locally
@@ -60,6 +73,7 @@ func NewExecAdapter(config ExecAdapterConfig) (*ExecAdapter, error) {
 
 	a := &ExecAdapter{
 		config: config,
+		logs:   newLogFeed(),
 	}
 	return a, nil
 }
@@ -78,6 +92,14 @@ func (a ExecAdapter) NewNode(config NodeConfig) Node {
 	return node
 }
 
+// SubscribeLogs subscribes to the stdout and stderr output of all nodes
+// created by the adapter. Every line of output is sent as a LogEvent.
+// The nodes are not held up by a subscriber that does not keep up with their
+// output, lines are dropped for it once logBufferSize lines are pending.
+func (a ExecAdapter) SubscribeLogs(ch chan<- LogEvent) event.Subscription {
+	return a.logs.Subscribe(ch)
+}
+
 // Snapshot returns a snapshot of the adapter
 func (a ExecAdapter) Snapshot() AdapterSnapshot {
 	return AdapterSnapshot{
@@ -123,14 +145,7 @@ func (n *ExecNode) Start() error {
 	args = append(args, n.config.Args...)
 
 	// Start command
-	n.cmd = &exec.Cmd{
-		Path:   n.adapter.config.ExecutablePath,
-		Args:   args,
-		Dir:    dir,
-		Env:    n.config.Env,
-		Stdout: n.config.Stdout,
-		Stderr: n.config.Stderr,
-	}
+	n.cmd = n.newCmd(args, dir)
 
 	if err := n.cmd.Start(); err != nil {
 		n.cmd = nil
@@ -162,14 +177,7 @@ func (n *ExecNode) Start() error {
 			// Command exited
 
 			// Restart command, as the process got killed due to tcp/udp pair of ports being taken
-			n.cmd = &exec.Cmd{
-				Path:   n.adapter.config.ExecutablePath,
-				Args:   args,
-				Dir:    dir,
-				Env:    n.config.Env,
-				Stdout: n.config.Stdout,
-				Stderr: n.config.Stderr,
-			}
+			n.cmd = n.newCmd(args, dir)
 
 			if err := n.cmd.Start(); err != nil {
 				n.cmd = nil
@@ -212,6 +220,66 @@ func (n *ExecNode) Start() error {
 	return nil
 }
 
+// newCmd creates the command running the node executable with the given arguments.
+// If resource limits are configured, the executable is run through a shell which
+// sets them with ulimit before replacing itself with the node process.
+func (n *ExecNode) newCmd(args []string, dir string) *exec.Cmd {
+	cmd := &exec.Cmd{
+		Path:   n.adapter.config.ExecutablePath,
+		Args:   args,
+		Dir:    dir,
+		Env:    n.env(),
+		Stdout: n.output("stdout", n.config.Stdout),
+		Stderr: n.output("stderr", n.config.Stderr),
+	}
+	if limits := n.limits(); limits != nil && !limits.empty() {
+		shell, err := exec.LookPath("sh")
+		if err != nil {
+			log.Warn("could not find shell to apply resource limits", "node", n.config.ID, "err", err)
+			return cmd
+		}
+		script := limits.script() + `exec "$0" "$@"`
+		cmd.Path = shell
+		cmd.Args = append([]string{"sh", "-c", script, n.adapter.config.ExecutablePath}, args[1:]...)
+	}
+	return cmd
+}
+
+// env returns the environment of the node process
+func (n *ExecNode) env() []string {
+	if len(n.adapter.config.Env) == 0 {
+		return n.config.Env
+	}
+	// later values take precedence over earlier ones
+	if n.config.Env == nil {
+		return append(os.Environ(), n.adapter.config.Env...)
+	}
+	return append(append([]string{}, n.adapter.config.Env...), n.config.Env...)
+}
+
+// limits returns the resource limits of the node process
+func (n *ExecNode) limits() *ResourceLimits {
+	if n.config.Limits != nil {
+		return n.config.Limits
+	}
+	return n.adapter.config.Limits
+}
+
+// output returns the writer for the given output stream of the node process.
+// Every line written to it is published to the log subscribers of the adapter
+// and also written to w, if it is not nil.
+func (n *ExecNode) output(stream string, w io.Writer) io.Writer {
+	lw := &logWriter{
+		node:   n.config.ID,
+		stream: stream,
+		feed:   n.adapter.logs,
+	}
+	if w == nil {
+		return lw
+	}
+	return io.MultiWriter(w, lw)
+}
+
 // Stop stops the node
 func (n *ExecNode) Stop() error {
 	if n.cmd == nil {
@@ -258,3 +326,112 @@ func (n *ExecNode) ipcPath() string {
 func (n *ExecNode) dataDir() string {
 	return filepath.Join(n.adapter.config.BaseDataDirectory, string(n.config.ID))
 }
+
+// logWriter splits the output of a node process into lines
+// and publishes them as LogEvents on the feed
+type logWriter struct {
+	node   NodeID
+	stream string
+	feed   *logFeed
+	mu     sync.Mutex
+	buf    bytes.Buffer
+}
+
+// Write implements io.Writer
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// incomplete line, keep it until the rest is written
+			w.buf.Reset()
+			w.buf.WriteString(line)
+			break
+		}
+		if w.feed == nil {
+			continue
+		}
+		w.feed.send(LogEvent{
+			NodeID: w.node,
+			Stream: w.stream,
+			Time:   time.Now(),
+			Line:   strings.TrimRight(line, "\r\n"),
+		})
+	}
+	return len(p), nil
+}
+
+// logBufferSize is the number of output lines buffered for each log subscriber
+const logBufferSize = 1024
+
+// logFeed publishes the output lines of node processes to the log subscribers
+// Unlike event.Feed it never blocks the sender, the lines are buffered for every
+// subscriber and dropped while the buffer of a lagging subscriber is full
+type logFeed struct {
+	mu   sync.Mutex
+	subs map[*logSub]struct{}
+}
+
+// logSub is a subscription to a logFeed
+type logSub struct {
+	lines   chan LogEvent
+	dropped int // number of lines dropped since the last delivered one
+}
+
+func newLogFeed() *logFeed {
+	return &logFeed{
+		subs: make(map[*logSub]struct{}),
+	}
+}
+
+// Subscribe delivers the published lines on ch until the subscription is cancelled
+func (f *logFeed) Subscribe(ch chan<- LogEvent) event.Subscription {
+	sub := &logSub{
+		lines: make(chan LogEvent, logBufferSize),
+	}
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer func() {
+			f.mu.Lock()
+			delete(f.subs, sub)
+			f.mu.Unlock()
+		}()
+		for {
+			select {
+			case line := <-sub.lines:
+				select {
+				case ch <- line:
+				case <-quit:
+					return nil
+				}
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
+
+// send publishes the line to all subscribers without blocking,
+// the subscribers whose buffer is full miss it
+func (f *logFeed) send(line LogEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		select {
+		case sub.lines <- line:
+			if sub.dropped > 0 {
+				log.Warn("log subscriber lagged behind node output", "dropped lines", sub.dropped)
+				sub.dropped = 0
+			}
+		default:
+			sub.dropped++
+		}
+	}
+}
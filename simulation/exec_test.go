@@ -2,9 +2,12 @@ package simulation
 
 import (
 	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 )
@@ -92,3 +95,136 @@ func TestExecAdapter(t *testing.T) {
 		t.Fatalf("node didn't stop: %v", err)
 	}
 }
+
+func TestExecNodeCmd(t *testing.T) {
+	adapter := &ExecAdapter{
+		config: ExecAdapterConfig{
+			ExecutablePath: "/usr/local/bin/swarm",
+			Env:            []string{"A=adapter", "B=adapter"},
+			Limits:         &ResourceLimits{CPUSeconds: 10},
+		},
+		logs: newLogFeed(),
+	}
+	node := &ExecNode{
+		adapter: adapter,
+		config: NodeConfig{
+			ID:     "node1",
+			Env:    []string{"B=node"},
+			Limits: &ResourceLimits{MemoryBytes: 1 << 30, OpenFiles: 1024},
+		},
+	}
+
+	cmd := node.newCmd([]string{"swarm", "--datadir", "/tmp"}, "/tmp")
+
+	env := strings.Join(cmd.Env, " ")
+	if env != "A=adapter B=adapter B=node" {
+		t.Fatalf("expected node environment to take precedence, got %q", env)
+	}
+
+	if cmd.Args[0] != "sh" || cmd.Args[1] != "-c" {
+		t.Fatalf("expected command to be run through a shell, got %v", cmd.Args)
+	}
+	script := cmd.Args[2]
+	if script != `ulimit -v 1048576 && ulimit -n 1024 && exec "$0" "$@"` {
+		t.Fatalf("unexpected limits script %q", script)
+	}
+	if args := strings.Join(cmd.Args[3:], " "); args != "/usr/local/bin/swarm --datadir /tmp" {
+		t.Fatalf("unexpected command arguments %q", args)
+	}
+
+	// without limits the executable is run directly
+	node.config.Limits = &ResourceLimits{}
+	cmd = node.newCmd([]string{"swarm", "--datadir", "/tmp"}, "/tmp")
+	if cmd.Path != "/usr/local/bin/swarm" || cmd.Args[0] != "swarm" {
+		t.Fatalf("expected executable to be run directly, got %s %v", cmd.Path, cmd.Args)
+	}
+}
+
+func TestExecNodeLogCapture(t *testing.T) {
+	adapter := &ExecAdapter{
+		logs: newLogFeed(),
+	}
+	node := &ExecNode{
+		adapter: adapter,
+		config: NodeConfig{
+			ID: "node1",
+		},
+	}
+
+	events := make(chan LogEvent, 10)
+	sub := adapter.SubscribeLogs(events)
+	defer sub.Unsubscribe()
+
+	w := node.output("stderr", nil)
+	fmt.Fprint(w, "first line\nsecond ")
+	fmt.Fprint(w, "line\r\nincomplete")
+
+	for _, expected := range []string{"first line", "second line"} {
+		select {
+		case e := <-events:
+			if e.NodeID != "node1" || e.Stream != "stderr" {
+				t.Fatalf("unexpected event origin %s %s", e.NodeID, e.Stream)
+			}
+			if e.Line != expected {
+				t.Fatalf("expected line %q, got %q", expected, e.Line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for line %q", expected)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event for incomplete line: %q", e.Line)
+	default:
+	}
+}
+
+func TestExecNodeLogLagging(t *testing.T) {
+	adapter := &ExecAdapter{
+		logs: newLogFeed(),
+	}
+	node := &ExecNode{
+		adapter: adapter,
+		config: NodeConfig{
+			ID: "node1",
+		},
+	}
+
+	// the subscriber does not read while the node writes
+	events := make(chan LogEvent)
+	sub := adapter.SubscribeLogs(events)
+	defer sub.Unsubscribe()
+
+	written := logBufferSize + 100
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := node.output("stdout", nil)
+		for i := 0; i < written; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected writes not to block on a lagging subscriber")
+	}
+
+	var received int
+loop:
+	for {
+		select {
+		case e := <-events:
+			if expected := fmt.Sprintf("line %d", received); e.Line != expected {
+				t.Fatalf("expected line %q, got %q", expected, e.Line)
+			}
+			received++
+		case <-time.After(100 * time.Millisecond):
+			break loop
+		}
+	}
+	// one line may be held by the subscription on top of the buffered ones
+	if received < logBufferSize || received > logBufferSize+1 {
+		t.Fatalf("expected %d buffered lines, got %d", logBufferSize, received)
+	}
+}
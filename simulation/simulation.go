@@ -15,6 +15,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethersphere/swarm/log"
@@ -252,6 +253,16 @@ func (s *Simulation) DefaultAdapter() Adapter {
 	return s.adapter
 }
 
+// SubscribeLogs subscribes to the output of the nodes created with the default adapter,
+// if the adapter captures it
+func (s *Simulation) SubscribeLogs(ch chan<- LogEvent) (event.Subscription, error) {
+	ls, ok := s.adapter.(LogSubscriber)
+	if !ok {
+		return nil, fmt.Errorf("adapter %T does not capture node logs", s.adapter)
+	}
+	return ls.SubscribeLogs(ch), nil
+}
+
 // Init initializes a node with the NodeConfig with the default Adapter
 func (s *Simulation) Init(config NodeConfig) error {
 	return s.InitWithAdapter(config, s.DefaultAdapter())
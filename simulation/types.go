@@ -1,7 +1,11 @@
 package simulation
 
 import (
+	"fmt"
 	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
 )
 
 // Node is a node within a simulation
@@ -37,6 +41,53 @@ type NodeConfig struct {
 	// Stdout and Stderr specify the nodes' standard output and error
 	Stdout io.Writer `json:"-"`
 	Stderr io.Writer `json:"-"`
+	// Resource limits of the node process, if supported by the adapter
+	Limits *ResourceLimits `json:"limits,omitempty"`
+}
+
+// ResourceLimits are limits on the resources a node process can use.
+// Zero values mean no limit.
+type ResourceLimits struct {
+	// Maximum size of the virtual memory of the process in bytes
+	MemoryBytes uint64 `json:"memory,omitempty"`
+	// Maximum CPU time of the process in seconds
+	CPUSeconds uint64 `json:"cpu,omitempty"`
+	// Maximum number of open file descriptors
+	OpenFiles uint64 `json:"files,omitempty"`
+}
+
+// empty returns true if no limit is set
+func (l *ResourceLimits) empty() bool {
+	return l.MemoryBytes == 0 && l.CPUSeconds == 0 && l.OpenFiles == 0
+}
+
+// script returns the shell commands setting the limits with ulimit
+func (l *ResourceLimits) script() (s string) {
+	if l.MemoryBytes > 0 {
+		// ulimit -v is expressed in kilobytes
+		s += fmt.Sprintf("ulimit -v %d && ", (l.MemoryBytes+1023)/1024)
+	}
+	if l.CPUSeconds > 0 {
+		s += fmt.Sprintf("ulimit -t %d && ", l.CPUSeconds)
+	}
+	if l.OpenFiles > 0 {
+		s += fmt.Sprintf("ulimit -n %d && ", l.OpenFiles)
+	}
+	return s
+}
+
+// LogEvent is a line of output captured from a node process
+type LogEvent struct {
+	NodeID NodeID    `json:"node"`
+	Stream string    `json:"stream"` // stdout or stderr
+	Time   time.Time `json:"time"`
+	Line   string    `json:"line"`
+}
+
+// LogSubscriber is implemented by adapters which capture the output of their nodes
+type LogSubscriber interface {
+	// SubscribeLogs sends every line of output of the adapter's nodes on the channel
+	SubscribeLogs(ch chan<- LogEvent) event.Subscription
 }
 
 // NodeInfo contains the nodes information and connections strings
@@ -18,8 +18,11 @@ package state
 
 import (
 	"encoding"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/storage"
@@ -29,20 +32,36 @@ import (
 // ErrNotFound is returned when no results are returned from the database
 var ErrNotFound = errors.New("ErrorNotFound")
 
+const (
+	// namespaceSeparator separates the namespaces of a key from the key
+	namespaceSeparator = "/"
+	// expiryPrefix prefixes the keys recording the expiry time of entries put with a TTL
+	// the expiry of a key is stored under expiryPrefix + key, outside of any namespace
+	expiryPrefix = "\x00expiry/"
+)
+
+// now returns the current time, tests can reassign it to expire entries
+var now = time.Now
+
 // Store defines methods required to get, set, delete values for different keys
 // and close the underlying resources.
 type Store interface {
 	Get(key string, i interface{}) (err error)
 	Put(key string, i interface{}) (err error)
+	PutWithTTL(key string, i interface{}, ttl time.Duration) (err error)
 	Delete(key string) (err error)
 	Iterate(prefix string, iterFunc iterFunction) (err error)
 	WriteBatch(batch *StoreBatch) (err error)
+	Namespace(name string) Store
 	Close() error
 }
 
 // DBStore uses LevelDB to store values.
+// A DBStore returned by Namespace shares the database of its parent
+// and stores its keys prefixed with the name of the namespace
 type DBStore struct {
-	db *leveldb.DB
+	db     *leveldb.DB
+	prefix string // namespace prefix of the keys, empty for the root store
 }
 
 // NewDBStore creates a new instance of DBStore.
@@ -67,17 +86,58 @@ func NewInmemoryStore() *DBStore {
 	}
 }
 
+// Namespace returns a store sharing the database of this store with its keys in the
+// namespace name, so that subsystems can use one database without key collisions
+// Keys passed to and returned from the namespaced store do not include the namespace
+// Namespaces can be nested, and closing a namespaced store does not close the database
+func (s *DBStore) Namespace(name string) Store {
+	return &DBStore{
+		db:     s.db,
+		prefix: s.prefix + name + namespaceSeparator,
+	}
+}
+
+// key returns the database key of a key of the store
+func (s *DBStore) key(key string) []byte {
+	return []byte(s.prefix + key)
+}
+
+// expiryKey returns the database key of the expiry time of a database key
+func expiryKey(dbKey []byte) []byte {
+	return append([]byte(expiryPrefix), dbKey...)
+}
+
+// expired returns true if the expiry time value has passed
+func expired(value []byte) bool {
+	if len(value) != 8 {
+		return false
+	}
+	return now().UnixNano() >= int64(binary.BigEndian.Uint64(value))
+}
+
 // Get retrieves a persisted value for a specific key. If there is no results
 // ErrNotFound is returned. The provided parameter should be either a byte slice or
 // a struct that implements the encoding.BinaryUnmarshaler interface
+// Entries put with a TTL that expired are not returned and removed from the store
 func (s *DBStore) Get(key string, i interface{}) (err error) {
-	data, err := s.db.Get([]byte(key), nil)
+	dbKey := s.key(key)
+	data, err := s.db.Get(dbKey, nil)
 	if err != nil {
 		if err == leveldb.ErrNotFound {
 			return ErrNotFound
 		}
 		return err
 	}
+	expiry, err := s.db.Get(expiryKey(dbKey), nil)
+	if err != nil && err != leveldb.ErrNotFound {
+		return err
+	}
+	if err == nil && expired(expiry) {
+		if err := s.purge(dbKey); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
 
 	unmarshaler, ok := i.(encoding.BinaryUnmarshaler)
 	if !ok {
@@ -87,23 +147,39 @@ func (s *DBStore) Get(key string, i interface{}) (err error) {
 }
 
 // Put stores an object that implements Binary for a specific key.
+// Any TTL previously set for the key is removed.
 func (s *DBStore) Put(key string, i interface{}) (err error) {
-	var bytes []byte
-	if marshaler, ok := i.(encoding.BinaryMarshaler); ok {
-		if bytes, err = marshaler.MarshalBinary(); err != nil {
-			return err
-		}
-	} else {
-		if bytes, err = json.Marshal(i); err != nil {
-			return err
-		}
+	batch := new(StoreBatch)
+	if err := batch.Put(key, i); err != nil {
+		return err
+	}
+	return s.WriteBatch(batch)
+}
+
+// PutWithTTL stores an object for a specific key the same way as Put,
+// but the entry expires after ttl, after which it is not returned by Get
+// and Iterate any more and is removed lazily when it is encountered
+func (s *DBStore) PutWithTTL(key string, i interface{}, ttl time.Duration) (err error) {
+	batch := new(StoreBatch)
+	if err := batch.PutWithTTL(key, i, ttl); err != nil {
+		return err
 	}
-	return s.db.Put([]byte(key), bytes, nil)
+	return s.WriteBatch(batch)
 }
 
 // Delete removes entries stored under a specific key.
 func (s *DBStore) Delete(key string) (err error) {
-	return s.db.Delete([]byte(key), nil)
+	return s.purge(s.key(key))
+}
+
+// purge removes the entry and its expiry time stored under a database key
+func (s *DBStore) purge(dbKeys ...[]byte) error {
+	batch := new(leveldb.Batch)
+	for _, k := range dbKeys {
+		batch.Delete(k)
+		batch.Delete(expiryKey(k))
+	}
+	return s.db.Write(batch, nil)
 }
 
 // iterFunction is a function called on every key/value pair obtained
@@ -114,11 +190,44 @@ func (s *DBStore) Delete(key string) (err error) {
 type iterFunction func(key, value []byte) (stop bool, err error)
 
 // Iterate entries (key/value pair) which have keys matching the given prefix
+// The keys passed to iterFunc do not include the namespace of the store
+// Expired entries are skipped and removed from the store once the iteration is done
 func (s *DBStore) Iterate(prefix string, iterFunc iterFunction) (err error) {
-	iter := s.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	dbPrefix := s.key(prefix)
+
+	// collect the expiry times of the entries in range up front
+	expiries := make(map[string][]byte)
+	expIter := s.db.NewIterator(util.BytesPrefix(expiryKey(dbPrefix)), nil)
+	for expIter.Next() {
+		expiries[string(expIter.Key()[len(expiryPrefix):])] = append([]byte(nil), expIter.Value()...)
+	}
+	expIter.Release()
+	if err := expIter.Error(); err != nil {
+		return err
+	}
+
+	var purge [][]byte
+	defer func() {
+		if len(purge) > 0 {
+			if perr := s.purge(purge...); perr != nil && err == nil {
+				err = perr
+			}
+		}
+	}()
+
+	iter := s.db.NewIterator(util.BytesPrefix(dbPrefix), nil)
 	defer iter.Release()
 	for iter.Next() {
-		stop, err := iterFunc(iter.Key(), iter.Value())
+		key := iter.Key()
+		// the expiry times are stored outside of the namespaces
+		if strings.HasPrefix(string(key), expiryPrefix) {
+			continue
+		}
+		if expiry, ok := expiries[string(key)]; ok && expired(expiry) {
+			purge = append(purge, append([]byte(nil), key...))
+			continue
+		}
+		stop, err := iterFunc(key[len(s.prefix):], iter.Value())
 		if err != nil {
 			return err
 		}
@@ -130,38 +239,99 @@ func (s *DBStore) Iterate(prefix string, iterFunc iterFunction) (err error) {
 }
 
 // Close releases the resources used by the underlying LevelDB.
+// Closing a namespaced store does nothing, the database is closed with the root store.
 func (s *DBStore) Close() error {
+	if s.prefix != "" {
+		return nil
+	}
 	return s.db.Close()
 }
 
 // StoreBatch is a wrapper around a leveldb batch that takes care of the proper encoding.
+// The keys of the operations are resolved in the namespace of the store the batch is written to.
 type StoreBatch struct {
 	leveldb.Batch
+	ops []batchOp
+}
+
+// batchOp is an operation of a StoreBatch
+type batchOp struct {
+	key    string
+	value  []byte
+	delete bool
+	expiry time.Time // zero if the entry does not expire
+}
+
+// encode returns the binary or json encoding of the value
+func encode(i interface{}) ([]byte, error) {
+	if marshaler, ok := i.(encoding.BinaryMarshaler); ok {
+		return marshaler.MarshalBinary()
+	}
+	return json.Marshal(i)
 }
 
 // Put encodes the value and puts a corresponding Put operation into the underlying batch.
 // This only returns an error if the encoding failed.
 func (b *StoreBatch) Put(key string, i interface{}) (err error) {
-	var bytes []byte
-	if marshaler, ok := i.(encoding.BinaryMarshaler); ok {
-		if bytes, err = marshaler.MarshalBinary(); err != nil {
-			return err
-		}
-	} else {
-		if bytes, err = json.Marshal(i); err != nil {
-			return err
-		}
+	bytes, err := encode(i)
+	if err != nil {
+		return err
 	}
-	b.Batch.Put([]byte(key), bytes)
+	b.ops = append(b.ops, batchOp{key: key, value: bytes})
+	return nil
+}
+
+// PutWithTTL adds a Put operation of an entry that expires after ttl into the batch.
+// This only returns an error if the encoding failed.
+func (b *StoreBatch) PutWithTTL(key string, i interface{}, ttl time.Duration) (err error) {
+	bytes, err := encode(i)
+	if err != nil {
+		return err
+	}
+	b.ops = append(b.ops, batchOp{key: key, value: bytes, expiry: now().Add(ttl)})
 	return nil
 }
 
 // Delete adds a delete operation to the underlying batch.
 func (b *StoreBatch) Delete(key string) {
-	b.Batch.Delete([]byte(key))
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+}
+
+// batchReplay writes the operations of the embedded leveldb batch in a namespace
+type batchReplay struct {
+	s     *DBStore
+	batch *leveldb.Batch
+}
+
+func (r *batchReplay) Put(key, value []byte) {
+	r.batch.Put(r.s.key(string(key)), value)
+}
+
+func (r *batchReplay) Delete(key []byte) {
+	r.batch.Delete(r.s.key(string(key)))
 }
 
 // WriteBatch executes the batch on the underlying database.
 func (s *DBStore) WriteBatch(batch *StoreBatch) error {
-	return s.db.Write(&batch.Batch, nil)
+	b := new(leveldb.Batch)
+	if err := batch.Batch.Replay(&batchReplay{s: s, batch: b}); err != nil {
+		return err
+	}
+	for _, op := range batch.ops {
+		dbKey := s.key(op.key)
+		switch {
+		case op.delete:
+			b.Delete(dbKey)
+			b.Delete(expiryKey(dbKey))
+		case op.expiry.IsZero():
+			b.Put(dbKey, op.value)
+			b.Delete(expiryKey(dbKey))
+		default:
+			expiry := make([]byte, 8)
+			binary.BigEndian.PutUint64(expiry, uint64(op.expiry.UnixNano()))
+			b.Put(dbKey, op.value)
+			b.Put(expiryKey(dbKey), expiry)
+		}
+	}
+	return s.db.Write(b, nil)
 }
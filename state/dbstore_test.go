@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 var ErrInvalidArraySize = errors.New("invalid byte array size")
@@ -244,3 +245,149 @@ func testStoreBatch(t *testing.T, store Store) {
 		t.Fatal("expected key2 to be deleted")
 	}
 }
+
+// TestDBStoreNamespace tests that namespaced stores sharing a database
+// do not see each other's keys and iterate their keys without the namespace.
+func TestDBStoreNamespace(t *testing.T) {
+	store := NewInmemoryStore()
+	defer store.Close()
+
+	pss := store.Namespace("pss")
+	swap := store.Namespace("swap")
+
+	if err := pss.Put("key", "pss value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Put("key", "swap value"); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if err := pss.Get("key", &value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "pss value" {
+		t.Fatalf("expected pss value, got %q", value)
+	}
+	if err := store.Get("key", &value); err != ErrNotFound {
+		t.Fatalf("expected key not to be found in the root store, got %v", err)
+	}
+
+	entries := make(map[string]string)
+	err := swap.Iterate("", func(key, value []byte) (bool, error) {
+		var entry string
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return true, err
+		}
+		entries[string(key)] = entry
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEntries := map[string]string{"key": "swap value"}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Fatalf("expected store entries to be %v, are %v instead", expectedEntries, entries)
+	}
+
+	// batches are written in the namespace of the store
+	batch := new(StoreBatch)
+	batch.Delete("key")
+	if err := pss.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := pss.Get("key", &value); err != ErrNotFound {
+		t.Fatalf("expected key to be deleted from the pss namespace, got %v", err)
+	}
+	if err := swap.Get("key", &value); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing a namespace does not close the database
+	if err := swap.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := swap.Get("key", &value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDBStoreTTL tests that expired entries are skipped by Get and Iterate
+// and removed from the database.
+func TestDBStoreTTL(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	current := time.Unix(1000, 0)
+	now = func() time.Time { return current }
+
+	store := NewInmemoryStore()
+	defer store.Close()
+
+	if err := store.PutWithTTL("test_expiring", "value1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	batch := new(StoreBatch)
+	if err := batch.PutWithTTL("test_batched", "value2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Put("test_persistent", "value3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	iterate := func() []string {
+		var keys []string
+		err := store.Iterate("test_", func(key, value []byte) (bool, error) {
+			keys = append(keys, string(key))
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+
+	if keys := iterate(); len(keys) != 3 {
+		t.Fatalf("expected 3 entries, got %v", keys)
+	}
+
+	current = current.Add(time.Minute)
+
+	var value string
+	if err := store.Get("test_expiring", &value); err != ErrNotFound {
+		t.Fatalf("expected expired entry not to be found, got %v", err)
+	}
+	if keys := iterate(); !reflect.DeepEqual(keys, []string{"test_batched", "test_persistent"}) {
+		t.Fatalf("expected unexpired entries, got %v", keys)
+	}
+
+	current = current.Add(time.Hour)
+
+	if keys := iterate(); !reflect.DeepEqual(keys, []string{"test_persistent"}) {
+		t.Fatalf("expected unexpired entries, got %v", keys)
+	}
+
+	// expired entries and their expiry times are purged
+	iter := store.db.NewIterator(nil, nil)
+	defer iter.Release()
+	var count int
+	for iter.Next() {
+		count++
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 entry in the database, got %d", count)
+	}
+
+	// putting an entry without ttl removes its expiry
+	if err := store.PutWithTTL("test_persistent", "value3", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("test_persistent", "value3"); err != nil {
+		t.Fatal(err)
+	}
+	current = current.Add(time.Hour)
+	if err := store.Get("test_persistent", &value); err != nil {
+		t.Fatal(err)
+	}
+}
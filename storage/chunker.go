@@ -371,8 +371,17 @@ type LazyChunkReader struct {
 	hashSize  int64 // inherit from chunker
 	depth     int
 	getter    Getter
+
+	rootMu sync.Mutex // guards loading of the root chunk in Size
 }
 
+// writeToSectionSize is the size of the sections WriteTo reads with ReadAt
+// and writeToPrefetch the number of sections read ahead of the writer
+var (
+	writeToSectionSize int64 = 32 * chunk.DefaultSize
+	writeToPrefetch          = 2
+)
+
 func (tc *TreeChunker) Join(ctx context.Context) *LazyChunkReader {
 	return &LazyChunkReader{
 		addr:      tc.addr,
@@ -400,7 +409,9 @@ func (r *LazyChunkReader) Size(ctx context.Context, quitC chan bool) (n int64, e
 		"lcr.size")
 	defer sp.Finish()
 
-	log.Debug("lazychunkreader.size", "addr", r.addr)
+	log.Request(ctx).Debug("lazychunkreader.size", "addr", r.addr)
+	r.rootMu.Lock()
+	defer r.rootMu.Unlock()
 	if r.chunkData == nil {
 		startTime := time.Now()
 		chunkData, err := r.getter.Get(cctx, Reference(r.addr))
@@ -409,18 +420,25 @@ func (r *LazyChunkReader) Size(ctx context.Context, quitC chan bool) (n int64, e
 			return 0, err
 		}
 		metrics.GetOrRegisterResettingTimer("lcr/getter/get", nil).UpdateSince(startTime)
+		// the chunk size and branching factor of the tree are given by the
+		// chunker profile recorded in the root chunk
+		profile, err := chunkData.Profile()
+		if err != nil {
+			return 0, err
+		}
+		r.chunkSize = profile.ChunkSize
+		r.branches = profile.ChunkSize / r.hashSize
 		r.chunkData = chunkData
 	}
 
 	s := r.chunkData.Size()
-	log.Debug("lazychunkreader.size", "key", r.addr, "size", s)
+	log.Request(ctx).Debug("lazychunkreader.size", "key", r.addr, "size", s)
 
 	return int64(s), nil
 }
 
 // read at can be called numerous times
-// concurrent reads are allowed
-// Size() needs to be called synchronously on the LazyChunkReader first
+// concurrent reads are allowed, the root chunk is loaded once by the first of them
 func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 	metrics.GetOrRegisterCounter("lazychunkreader/readat", nil).Inc(1)
 
@@ -444,9 +462,12 @@ func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 	quitC := make(chan bool)
 	size, err := r.Size(cctx, quitC)
 	if err != nil {
-		log.Debug("lazychunkreader.readat.size", "size", size, "err", err)
+		log.Request(r.ctx).Debug("lazychunkreader.readat.size", "size", size, "err", err)
 		return 0, err
 	}
+	if off >= size {
+		return 0, io.EOF
+	}
 
 	errC := make(chan error)
 
@@ -473,7 +494,7 @@ func (r *LazyChunkReader) ReadAt(b []byte, off int64) (read int, err error) {
 
 	err = <-errC
 	if err != nil {
-		log.Debug("lazychunkreader.readat.errc", "err", err)
+		log.Request(r.ctx).Debug("lazychunkreader.readat.errc", "err", err)
 		close(quitC)
 		return 0, err
 	}
@@ -567,7 +588,7 @@ func (r *LazyChunkReader) Read(b []byte) (read int, err error) {
 
 	read, err = r.ReadAt(b, r.off)
 	if err != nil && err != io.EOF {
-		log.Trace("lazychunkreader.readat", "read", read, "err", err)
+		log.Request(r.ctx).Trace("lazychunkreader.readat", "read", read, "err", err)
 		metrics.GetOrRegisterCounter("lazychunkreader/read/err", nil).Inc(1)
 	}
 
@@ -577,6 +598,67 @@ func (r *LazyChunkReader) Read(b []byte) (read int, err error) {
 	return read, err
 }
 
+// WriteTo writes the document from the current offset to w, reading ahead
+// sections of it with ReadAt while the previous ones are being written.
+// As Read, it advances the cursor so it cannot be called simultaneously with it.
+func (r *LazyChunkReader) WriteTo(w io.Writer) (n int64, err error) {
+	metrics.GetOrRegisterCounter("lazychunkreader/writeto", nil).Inc(1)
+
+	size, err := r.Size(r.ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	type section struct {
+		data []byte
+		err  error
+	}
+	sectionC := make(chan section, writeToPrefetch)
+	quitC := make(chan struct{})
+	defer close(quitC)
+
+	go func(off int64) {
+		defer close(sectionC)
+		for ; off < size; off += writeToSectionSize {
+			length := writeToSectionSize
+			if off+length > size {
+				length = size - off
+			}
+			b := make([]byte, length)
+			read, err := r.ReadAt(b, off)
+			if err == io.EOF {
+				err = nil
+			}
+			select {
+			case sectionC <- section{data: b[:read], err: err}:
+			case <-quitC:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}(r.off)
+
+	for s := range sectionC {
+		if s.err != nil {
+			metrics.GetOrRegisterCounter("lazychunkreader/writeto/err", nil).Inc(1)
+			return n, s.err
+		}
+		written, err := w.Write(s.data)
+		n += int64(written)
+		r.off += int64(written)
+		if err != nil {
+			return n, err
+		}
+		if written < len(s.data) {
+			return n, io.ErrShortWrite
+		}
+	}
+	metrics.GetOrRegisterCounter("lazychunkreader/writeto/bytes", nil).Inc(n)
+	return n, nil
+}
+
 // completely analogous to standard SectionReader implementation
 var errWhence = errors.New("Seek: invalid whence")
 var errOffset = errors.New("Seek: invalid offset")
@@ -587,7 +669,7 @@ func (r *LazyChunkReader) Seek(offset int64, whence int) (int64, error) {
 		"lcr.seek")
 	defer sp.Finish()
 
-	log.Debug("lazychunkreader.seek", "key", r.addr, "offset", offset)
+	log.Request(r.ctx).Debug("lazychunkreader.seek", "key", r.addr, "offset", offset)
 	switch whence {
 	default:
 		return 0, errWhence
@@ -596,14 +678,12 @@ func (r *LazyChunkReader) Seek(offset int64, whence int) (int64, error) {
 	case 1:
 		offset += r.off
 	case 2:
-
-		if r.chunkData == nil { //seek from the end requires rootchunk for size. call Size first
-			_, err := r.Size(cctx, nil)
-			if err != nil {
-				return 0, fmt.Errorf("can't get size: %v", err)
-			}
+		// seek from the end requires rootchunk for size
+		size, err := r.Size(cctx, nil)
+		if err != nil {
+			return 0, fmt.Errorf("can't get size: %v", err)
 		}
-		offset += int64(r.chunkData.Size())
+		offset += size
 	}
 
 	if offset < 0 {
@@ -22,6 +22,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
 
 	"github.com/ethersphere/swarm/chunk"
@@ -267,6 +268,74 @@ func TestRandomBrokenData(t *testing.T) {
 	}
 }
 
+// TestLazyChunkReaderConcurrentReadAtAndWriteTo tests that fresh readers can be
+// read with ReadAt from multiple goroutines and drained with WriteTo from an offset
+func TestLazyChunkReaderConcurrentReadAtAndWriteTo(t *testing.T) {
+	defer func(s int64) { writeToSectionSize = s }(writeToSectionSize)
+	writeToSectionSize = 3*chunk.DefaultSize + 17
+
+	n := 1234567
+	input := testutil.RandomBytes(1, n)
+	putGetter := newTestHasherStore(NewMapChunkStore(), BMTHash)
+	ctx := context.Background()
+	addr, wait, err := PyramidSplit(ctx, bytes.NewReader(input), putGetter, putGetter, mockTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := TreeJoin(ctx, addr, putGetter, 0)
+	var wg sync.WaitGroup
+	errC := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(off int) {
+			defer wg.Done()
+			b := make([]byte, 10000)
+			read, err := reader.ReadAt(b, int64(off))
+			if err != nil && err != io.EOF {
+				errC <- err
+				return
+			}
+			if !bytes.Equal(b[:read], input[off:off+read]) {
+				errC <- fmt.Errorf("input and output mismatch at offset %v", off)
+			}
+		}(i * n / 16)
+	}
+	wg.Wait()
+	close(errC)
+	for err := range errC {
+		t.Fatal(err)
+	}
+
+	if _, err := reader.ReadAt(make([]byte, 1), int64(n)); err != io.EOF {
+		t.Fatalf("expected %v reading at the end, got %v", io.EOF, err)
+	}
+
+	for _, off := range []int64{0, 4097, int64(n) - 1, int64(n)} {
+		reader := TreeJoin(ctx, addr, putGetter, 0)
+		if _, err := reader.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		written, err := io.Copy(&buf, reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != int64(n)-off {
+			t.Fatalf("expected %v bytes written from offset %v, got %v", int64(n)-off, off, written)
+		}
+		if !bytes.Equal(buf.Bytes(), input[off:]) {
+			t.Fatalf("input and output mismatch from offset %v", off)
+		}
+		if read, err := reader.Read(make([]byte, 1)); read != 0 || err != io.EOF {
+			t.Fatalf("expected reader at the end after WriteTo, got read %v err %v", read, err)
+		}
+	}
+}
+
 func benchReadAll(reader LazySectionReader) {
 	size, _ := reader.Size(context.TODO(), nil)
 	output := make([]byte, 1000)
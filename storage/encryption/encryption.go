@@ -21,6 +21,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"hash"
+	"reflect"
+	"runtime"
 	"sync"
 )
 
@@ -34,6 +36,34 @@ type Encryption interface {
 	Reset()
 }
 
+// segmentsPerWorker is the number of segments transformed by a single
+// goroutine, data with up to this many segments is transformed without
+// starting goroutines
+const segmentsPerWorker = 16
+
+// maxWorkers limits the number of goroutines transforming the data of a
+// single Encrypt or Decrypt call
+var maxWorkers = runtime.NumCPU()
+
+// hasherPools holds pools of hashers by their constructor functions, so
+// that the states of hashers are reused across encryption instances,
+// which are usually created for a single chunk
+var hasherPools sync.Map // map[uintptr]*sync.Pool
+
+// hasherPool returns the pool of hashers created by hashFunc
+func hasherPool(hashFunc func() hash.Hash) *sync.Pool {
+	key := reflect.ValueOf(hashFunc).Pointer()
+	if p, ok := hasherPools.Load(key); ok {
+		return p.(*sync.Pool)
+	}
+	p, _ := hasherPools.LoadOrStore(key, &sync.Pool{
+		New: func() interface{} {
+			return hashFunc()
+		},
+	})
+	return p.(*sync.Pool)
+}
+
 type encryption struct {
 	key      Key              // the encryption key (hashSize bytes long)
 	keyLen   int              // length of the key = length of blockcipher block
@@ -41,9 +71,13 @@ type encryption struct {
 	index    int              // counter index
 	initCtr  uint32           // initial counter used for counter mode blockcipher
 	hashFunc func() hash.Hash // hasher constructor function
+	hashers  *sync.Pool       // reused hashers created by hashFunc
 }
 
 // New constructs a new encryptor/decryptor
+// Hashers created by hashFunc are reused by all encryptions with the same
+// hashFunc, which must not be a closure returning differently configured
+// hashers.
 func New(key Key, padding int, initCtr uint32, hashFunc func() hash.Hash) *encryption {
 	return &encryption{
 		key:      key,
@@ -51,6 +85,7 @@ func New(key Key, padding int, initCtr uint32, hashFunc func() hash.Hash) *encry
 		padding:  padding,
 		initCtr:  initCtr,
 		hashFunc: hashFunc,
+		hashers:  hasherPool(hashFunc),
 	}
 }
 
@@ -87,50 +122,99 @@ func (e *encryption) Reset() {
 	e.index = 0
 }
 
-// split up input into keylength segments and encrypt sequentially
+// split up input into keylength segments and encrypt them, in parallel
+// by ranges of segments if there are more than segmentsPerWorker of them
 func (e *encryption) transform(in, out []byte) {
 	inLength := len(in)
-	wg := sync.WaitGroup{}
-	wg.Add((inLength-1)/e.keyLen + 1)
-	for i := 0; i < inLength; i += e.keyLen {
-		l := min(e.keyLen, inLength-i)
-		go func(i int, x, y []byte) {
-			defer wg.Done()
-			e.Transcrypt(i, x, y)
-		}(e.index, in[i:i+l], out[i:i+l])
-		e.index++
+	segments := (inLength + e.keyLen - 1) / e.keyLen
+	index := e.index
+	e.index += segments
+
+	workers := segments / segmentsPerWorker
+	if workers > maxWorkers {
+		workers = maxWorkers
+	}
+	if workers <= 1 {
+		e.transcryptSegments(index, in, out[:inLength])
+	} else {
+		// ranges of whole segments for every worker
+		size := (segments + workers - 1) / workers * e.keyLen
+		var wg sync.WaitGroup
+		for i := 0; i < inLength; i += size {
+			l := min(size, inLength-i)
+			wg.Add(1)
+			go func(index int, x, y []byte) {
+				defer wg.Done()
+				e.transcryptSegments(index, x, y)
+			}(index+i/e.keyLen, in[i:i+l], out[i:i+l])
+		}
+		defer wg.Wait()
 	}
 	// pad the rest if out is longer
 	pad(out[inLength:])
-	wg.Wait()
+}
+
+// transcryptSegments transforms consecutive segments starting
+// from the segment with index i, with a single hasher
+func (e *encryption) transcryptSegments(i int, in, out []byte) {
+	hasher := e.hashers.Get().(hash.Hash)
+	defer e.hashers.Put(hasher)
+
+	buf := make([]byte, 0, hasher.Size())
+	for j := 0; j < len(in); j += e.keyLen {
+		l := min(e.keyLen, len(in)-j)
+		buf = e.transcrypt(hasher, buf, i, in[j:j+l], out[j:j+l])
+		i++
+	}
 }
 
 // used for segmentwise transformation
 // if in is shorter than out, padding is used
 func (e *encryption) Transcrypt(i int, in []byte, out []byte) {
+	hasher := e.hashers.Get().(hash.Hash)
+	defer e.hashers.Put(hasher)
+
+	e.transcrypt(hasher, nil, i, in, out)
+	// insert padding if out is longer
+	pad(out[len(in):])
+}
+
+// transcrypt XORs the segment with index i with the segment key derived from
+// the key and the counter, using the hasher and the buffer to compute it. It
+// returns the buffer to be reused for the following segments.
+func (e *encryption) transcrypt(hasher hash.Hash, buf []byte, i int, in []byte, out []byte) []byte {
 	// first hash key with counter (initial counter + i)
-	hasher := e.hashFunc()
+	hasher.Reset()
 	hasher.Write(e.key)
 
-	ctrBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(ctrBytes, uint32(i)+e.initCtr)
-	hasher.Write(ctrBytes)
+	var ctrBytes [4]byte
+	binary.LittleEndian.PutUint32(ctrBytes[:], uint32(i)+e.initCtr)
+	hasher.Write(ctrBytes[:])
 
-	ctrHash := hasher.Sum(nil)
+	buf = hasher.Sum(buf[:0])
 	hasher.Reset()
 
 	// second round of hashing for selective disclosure
-	hasher.Write(ctrHash)
-	segmentKey := hasher.Sum(nil)
+	hasher.Write(buf)
+	buf = hasher.Sum(buf[:0])
 	hasher.Reset()
 
 	// XOR bytes uptil length of in (out must be at least as long)
-	inLength := len(in)
-	for j := 0; j < inLength; j++ {
-		out[j] = in[j] ^ segmentKey[j]
+	xor(out, in, buf)
+	return buf
+}
+
+// xor sets dst to a XOR b for the length of a, eight bytes at a time
+// where possible; dst and b must be at least as long as a
+func xor(dst, a, b []byte) {
+	n := len(a)
+	j := 0
+	for ; j+8 <= n; j += 8 {
+		binary.LittleEndian.PutUint64(dst[j:], binary.LittleEndian.Uint64(a[j:])^binary.LittleEndian.Uint64(b[j:]))
+	}
+	for ; j < n; j++ {
+		dst[j] = a[j] ^ b[j]
 	}
-	// insert padding if out is longer
-	pad(out[inLength:])
 }
 
 func pad(b []byte) {
@@ -19,6 +19,8 @@ package encryption
 import (
 	"bytes"
 	crand "crypto/rand"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -191,3 +193,53 @@ func TestEncryptSectioned(t *testing.T) {
 		}
 	}
 }
+
+// TestEncryptWorkers tests that the cipherText is the same regardless of
+// the number of goroutines the data is encrypted by, and that encryptions
+// can be used concurrently
+func TestEncryptWorkers(t *testing.T) {
+	defer func(m int) { maxWorkers = m }(maxWorkers)
+
+	for _, length := range []int{31, 32, 33, 1000, 4096, 4096*3 + 5} {
+		t.Run(fmt.Sprintf("length %v", length), func(t *testing.T) {
+			data := testutil.RandomBytes(1, length)
+
+			maxWorkers = 1
+			want, err := New(testKey, 0, 42, hashFunc).Encrypt(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			maxWorkers = 8
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := New(testKey, 0, 42, hashFunc).Encrypt(data)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if !bytes.Equal(got, want) {
+						t.Errorf("got %x, want %x", got, want)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	data := testutil.RandomBytes(1, 4096)
+	key := GenerateRandomKey(KeyLength)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(key, 4096, 0, hashFunc).Encrypt(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
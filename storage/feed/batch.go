@@ -0,0 +1,114 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package feed
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/storage/feed/lookup"
+)
+
+// lookupBatchConcurrency is the number of feeds looked up in parallel by LookupBatch
+const lookupBatchConcurrency = 8
+
+// UpdateBatch publishes the updates of several feeds at once, for instance the
+// inboxes of many users, storing all of them with a single put
+// The requests are signed with the signer unless it is nil, in which case they
+// must be signed already. No update is published if any of them is invalid.
+func (h *Handler) UpdateBatch(ctx context.Context, signer Signer, requests []*Request) (updateAddrs []storage.Address, err error) {
+	// we can't update anything without a store
+	if h.chunkStore == nil {
+		return nil, NewError(ErrInit, "Call Handler.SetStore() before updating")
+	}
+
+	feedUpdates := make([]*cacheEntry, len(requests))
+	chunks := make([]storage.Chunk, len(requests))
+	seen := make(map[string]bool, len(requests))
+	for i, r := range requests {
+		if signer != nil {
+			if err := r.Sign(signer); err != nil {
+				return nil, err
+			}
+		}
+		feedUpdates[i] = h.get(&r.Feed)
+		if feedUpdates[i] != nil && feedUpdates[i].Epoch.Equals(r.Epoch) {
+			return nil, NewErrorf(ErrInvalidValue, "A former update in this epoch is already known to exist, update %d", i)
+		}
+		chunks[i], err = r.toChunk()
+		if err != nil {
+			return nil, err
+		}
+		key := string(chunks[i].Address())
+		if seen[key] {
+			return nil, NewErrorf(ErrInvalidValue, "Update %d is in the same epoch of the same feed as a former one of the batch", i)
+		}
+		seen[key] = true
+	}
+
+	// send the chunks
+	if _, err := h.chunkStore.Put(ctx, chunk.ModePutUpload, chunks...); err != nil {
+		return nil, err
+	}
+
+	updateAddrs = make([]storage.Address, len(requests))
+	for i, r := range requests {
+		h.updated(feedUpdates[i], r)
+		updateAddrs[i] = r.idAddr
+	}
+	return updateAddrs, nil
+}
+
+// LookupResult is the outcome of the lookup of a feed by LookupBatch
+type LookupResult struct {
+	Feed  Feed            // the feed looked up
+	Epoch lookup.Epoch    // epoch of the update found
+	Addr  storage.Address // address of the update found
+	Data  []byte          // data of the update found
+	Err   error           // error of the lookup, the other fields are not set if it is not nil
+}
+
+// LookupBatch looks up several feeds concurrently, as Lookup does for each of
+// them, and returns the outcome of the lookups in the order of the queries
+func (h *Handler) LookupBatch(ctx context.Context, queries []*Query) []LookupResult {
+	results := make([]LookupResult, len(queries))
+	sem := make(chan struct{}, lookupBatchConcurrency)
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		results[i].Feed = query.Feed
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *LookupResult, query *Query) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			feedUpdate, err := h.Lookup(ctx, query)
+			if err != nil {
+				result.Err = err
+				return
+			}
+			result.Epoch = feedUpdate.Epoch
+			result.Addr = feedUpdate.lastKey
+			result.Data = feedUpdate.data
+		}(&results[i], query)
+	}
+	wg.Wait()
+	return results
+}
@@ -21,6 +21,7 @@ package feed
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -189,11 +190,14 @@ func (h *Handler) Lookup(ctx context.Context, query *Query) (*cacheEntry, error)
 		}
 		ctx, cancel := context.WithTimeout(ctx, defaultRetrieveTimeout)
 		defer cancel()
+		// the updates are looked up before they exist, so that the failures to
+		// fetch them must not hide the updates published since
+		ctx = storage.WithRefresh(ctx)
 
 		r := storage.NewRequest(id.Addr())
 		ch, err := h.chunkStore.Get(ctx, chunk.ModeGetLookup, r)
 		if err != nil {
-			if err == context.DeadlineExceeded || err == storage.ErrNoSuitablePeer { // chunk not found
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, storage.ErrNoSuitablePeer) { // chunk not found
 				return nil, nil
 			}
 			return nil, err
@@ -270,6 +274,12 @@ func (h *Handler) Update(ctx context.Context, r *Request) (updateAddr storage.Ad
 		return nil, err
 	}
 
+	h.updated(feedUpdate, r)
+	return r.idAddr, nil
+}
+
+// updated records a published update in the cache entry of its feed
+func (h *Handler) updated(feedUpdate *cacheEntry, r *Request) {
 	// update our feed updates map cache entry if the new update is older than the one we have, if we have it.
 	if feedUpdate != nil && r.Epoch.After(feedUpdate.Epoch) {
 		feedUpdate.Epoch = r.Epoch
@@ -278,8 +288,6 @@ func (h *Handler) Update(ctx context.Context, r *Request) (updateAddr storage.Ad
 		copy(feedUpdate.data, r.data)
 		feedUpdate.Reader = bytes.NewReader(feedUpdate.data)
 	}
-
-	return r.idAddr, nil
 }
 
 // Retrieves the feed update cache value for the given nameHash
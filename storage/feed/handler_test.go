@@ -238,6 +238,76 @@ func TestFeedsHandler(t *testing.T) {
 
 }
 
+// TestBatch tests that the updates of several feeds are published with a single
+// call and looked up together
+func TestBatch(t *testing.T) {
+	clock := &fakeTimeProvider{
+		currentTime: startTime.Time,
+	}
+	signer := newAliceSigner()
+	feedsHandler, _, teardownTest, err := setupTest(clock, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownTest()
+	ctx := context.Background()
+
+	newRequest := func(name, data string) *Request {
+		topic, _ := NewTopic(name, nil)
+		request := NewFirstRequest(topic)
+		request.SetData([]byte(data))
+		return request
+	}
+
+	var requests []*Request
+	var queries []*Query
+	for i := 0; i < 12; i++ {
+		request := newRequest(fmt.Sprintf("inbox-%d", i), fmt.Sprintf("message %d", i))
+		requests = append(requests, request)
+	}
+	addrs, err := feedsHandler.UpdateBatch(ctx, signer, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the feeds of the requests are owned by the signer once they are signed
+	for _, request := range requests {
+		queries = append(queries, NewQueryLatest(&request.Feed, lookup.NoClue))
+	}
+	if len(addrs) != len(requests) {
+		t.Fatalf("got %d update addresses, want %d", len(addrs), len(requests))
+	}
+
+	// no update of an invalid batch is published
+	extra := newRequest("extra", "extra")
+	duplicate := newRequest("duplicate", "duplicate")
+	if _, err := feedsHandler.UpdateBatch(ctx, signer, []*Request{extra, duplicate, duplicate}); err == nil {
+		t.Fatal("expected error for two updates of a feed in the same epoch")
+	}
+	queries = append(queries, NewQueryLatest(&extra.Feed, lookup.NoClue))
+
+	results := feedsHandler.LookupBatch(ctx, queries)
+	if len(results) != len(queries) {
+		t.Fatalf("got %d results, want %d", len(results), len(queries))
+	}
+	for i, result := range results[:len(requests)] {
+		if result.Err != nil {
+			t.Fatalf("lookup %d: %v", i, result.Err)
+		}
+		if want := fmt.Sprintf("message %d", i); string(result.Data) != want {
+			t.Errorf("lookup %d: got data %q, want %q", i, result.Data, want)
+		}
+		if !bytes.Equal(result.Addr, addrs[i]) {
+			t.Errorf("lookup %d: got address %v, want %v", i, result.Addr, addrs[i])
+		}
+		if result.Feed != requests[i].Feed {
+			t.Errorf("lookup %d: got feed %v, want %v", i, result.Feed, requests[i].Feed)
+		}
+	}
+	if err := results[len(requests)].Err; err == nil {
+		t.Fatal("expected the update of the invalid batch not to be found")
+	}
+}
+
 const Day = 60 * 60 * 24
 const Year = Day * 365
 const Month = Day * 30
@@ -0,0 +1,61 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type fetchStatsKey struct{}
+
+// FetchStats counts the chunks got with NetStore under a context returned by
+// WithFetchStats, by whether they were found in the local store or needed to
+// be fetched from peers.
+type FetchStats struct {
+	hits    uint64
+	fetches uint64
+}
+
+// WithFetchStats returns a context that counts the chunks got with it in stats
+func WithFetchStats(ctx context.Context, stats *FetchStats) context.Context {
+	return context.WithValue(ctx, fetchStatsKey{}, stats)
+}
+
+// Hits returns the number of chunks found in the local store
+func (s *FetchStats) Hits() uint64 {
+	return atomic.LoadUint64(&s.hits)
+}
+
+// Fetches returns the number of chunks requested from peers
+func (s *FetchStats) Fetches() uint64 {
+	return atomic.LoadUint64(&s.fetches)
+}
+
+// countHit counts a chunk found in the local store if the context has stats
+func countHit(ctx context.Context) {
+	if s, ok := ctx.Value(fetchStatsKey{}).(*FetchStats); ok {
+		atomic.AddUint64(&s.hits, 1)
+	}
+}
+
+// countFetch counts a chunk requested from peers if the context has stats
+func countFetch(ctx context.Context) {
+	if s, ok := ctx.Value(fetchStatsKey{}).(*FetchStats); ok {
+		atomic.AddUint64(&s.fetches, 1)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestFetchStats tests that the chunks got with a context with fetch stats
+// are counted by whether they were found locally or requested from peers
+func TestFetchStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	netStore := NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	netStore.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		return nil, func() {}, errors.New("not found")
+	}
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	if _, err := netStore.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &FetchStats{}
+	ctx := WithFetchStats(context.Background(), stats)
+	for i := 0; i < 2; i++ {
+		if _, err := netStore.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := chunktesting.GenerateTestRandomChunk()
+	if _, err := netStore.Get(ctx, chunk.ModeGetRequest, NewRequest(missing.Address())); err == nil {
+		t.Fatal("expected error getting missing chunk")
+	}
+	if _, err := netStore.Get(context.Background(), chunk.ModeGetRequest, NewRequest(ch.Address())); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Hits() != 2 {
+		t.Fatalf("expected 2 hits, got %d", stats.Hits())
+	}
+	if stats.Fetches() != 1 {
+		t.Fatalf("expected 1 fetch, got %d", stats.Fetches())
+	}
+}
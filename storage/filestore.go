@@ -17,12 +17,14 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"sort"
 	"sync"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/storage/localstore"
 )
 
@@ -47,16 +49,19 @@ type FileStore struct {
 	ChunkStore
 	putterStore ChunkStore
 	hashFunc    SwarmHasher
+	profile     *ChunkerProfile
 	tags        *chunk.Tags
 }
 
 type FileStoreParams struct {
-	Hash string
+	Hash           string
+	ChunkerProfile string // name of the chunker profile content is split with
 }
 
 func NewFileStoreParams() *FileStoreParams {
 	return &FileStoreParams{
-		Hash: DefaultHash,
+		Hash:           DefaultHash,
+		ChunkerProfile: DefaultChunkerProfile.Name,
 	}
 }
 
@@ -74,10 +79,20 @@ func NewLocalFileStore(datadir string, basekey []byte, tags *chunk.Tags) (*FileS
 
 func NewFileStore(store ChunkStore, putterStore ChunkStore, params *FileStoreParams, tags *chunk.Tags) *FileStore {
 	hashFunc := MakeHashFunc(params.Hash)
+	profile := DefaultChunkerProfile
+	if params.ChunkerProfile != "" {
+		p, err := ChunkerProfileByName(params.ChunkerProfile)
+		if err != nil {
+			log.Error("using default chunker profile", "err", err)
+		} else {
+			profile = p
+		}
+	}
 	return &FileStore{
 		ChunkStore:  store,
 		putterStore: putterStore,
 		hashFunc:    hashFunc,
+		profile:     profile,
 		tags:        tags,
 	}
 }
@@ -86,9 +101,11 @@ func NewFileStore(store ChunkStore, putterStore ChunkStore, params *FileStorePar
 // FS-aware API and httpaccess
 // Chunk retrieval blocks on netStore requests with a timeout so reader will
 // report error if retrieval of chunks within requested range time out.
+// The chunker profile the content was stored with is read from the root chunk.
 // It returns a reader with the chunk data and whether the content was encrypted
 func (f *FileStore) Retrieve(ctx context.Context, addr Address) (reader *LazyChunkReader, isEncrypted bool) {
 	isEncrypted = len(addr) > f.hashFunc().Size()
+	log.Request(ctx).Trace("filestore.retrieve", "addr", addr, "encrypted", isEncrypted)
 	tag, err := f.tags.GetFromContext(ctx)
 	if err != nil {
 		tag = chunk.NewTag(0, "ephemeral-retrieval-tag", 0, false)
@@ -101,6 +118,7 @@ func (f *FileStore) Retrieve(ctx context.Context, addr Address) (reader *LazyChu
 
 // Store is a public API. Main entry point for document storage directly. Used by the
 // FS-aware API and httpaccess
+// The data is split into chunks of the size given by the chunker profile of the FileStore
 func (f *FileStore) Store(ctx context.Context, data io.Reader, size int64, toEncrypt bool) (addr Address, wait func(context.Context) error, err error) {
 	tag, err := f.tags.GetFromContext(ctx)
 	if err != nil {
@@ -111,14 +129,73 @@ func (f *FileStore) Store(ctx context.Context, data io.Reader, size int64, toEnc
 		tag = chunk.NewTag(0, "", 0, false)
 		//return nil, nil, err
 	}
+	log.Request(ctx).Trace("filestore.store", "size", size, "encrypted", toEncrypt, "tag", tag.Uid)
 	putter := NewHasherStore(f.putterStore, f.hashFunc, toEncrypt, tag)
-	return PyramidSplit(ctx, data, putter, putter, tag)
+	return PyramidSplitProfile(ctx, data, putter, putter, tag, f.profile)
+}
+
+// UploadStats reports how many chunks of an upload were newly stored and how many
+// were deduplicated because they were already present
+type UploadStats struct {
+	Stored int64 // chunks newly stored in the local store
+	Seen   int64 // chunks already present in the local store
+	Synced int64 // chunks confirmed to be present in the network by a receipt
+}
+
+// NewUploadStats returns the deduplication statistics of the upload tracked by the tag
+func NewUploadStats(tag *chunk.Tag) UploadStats {
+	seen := tag.Get(chunk.StateSeen)
+	return UploadStats{
+		Stored: tag.Get(chunk.StateStored) - seen,
+		Seen:   seen,
+		Synced: tag.Get(chunk.StateSynced),
+	}
+}
+
+// UploadStats returns the deduplication statistics of the upload whose tag is set
+// in the context. It should be called after the wait function returned by Store,
+// as only then all the chunks of the upload are accounted for.
+func (f *FileStore) UploadStats(ctx context.Context) (UploadStats, error) {
+	tag, err := f.tags.GetFromContext(ctx)
+	if err != nil {
+		return UploadStats{}, err
+	}
+	return NewUploadStats(tag), nil
+}
+
+// ChunkSize returns the size of the chunks content is split into by the FileStore
+func (f *FileStore) ChunkSize() int64 {
+	return f.profile.ChunkSize
 }
 
 func (f *FileStore) HashSize() int {
 	return f.hashFunc().Size()
 }
 
+// Matches reports whether the unencrypted data split with any of the chunker profiles
+// hashes to the address, without storing its chunks. It verifies content that was not
+// retrieved by its address, like the data inlined in manifest entries.
+func (f *FileStore) Matches(ctx context.Context, data []byte, addr Address) bool {
+	if len(addr) != f.HashSize() {
+		return false
+	}
+	for _, profile := range chunkerProfiles {
+		tag := chunk.NewTag(0, "ephemeral-tag", 0, false)
+		putter := NewHasherStore(&FakeChunkStore{}, f.hashFunc, false, tag)
+		a, wait, err := PyramidSplitProfile(ctx, bytes.NewReader(data), putter, putter, tag, profile)
+		if err != nil {
+			return false
+		}
+		if err := wait(ctx); err != nil {
+			return false
+		}
+		if bytes.Equal(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAllReferences is a public API. This endpoint returns all chunk hashes (only) for a given file
 func (f *FileStore) GetAllReferences(ctx context.Context, data io.Reader) (addrs AddressCollection, err error) {
 	tag := chunk.NewTag(0, "ephemeral-tag", 0, false) //this tag is just a mock ephemeral tag since we don't want to save these results
@@ -128,7 +205,7 @@ func (f *FileStore) GetAllReferences(ctx context.Context, data io.Reader) (addrs
 		hasherStore: NewHasherStore(f.ChunkStore, f.hashFunc, false, tag),
 	}
 	// do the actual splitting anyway, no way around it
-	_, wait, err := PyramidSplit(ctx, data, putter, putter, tag)
+	_, wait, err := PyramidSplitProfile(ctx, data, putter, putter, tag, f.profile)
 	if err != nil {
 		return nil, err
 	}
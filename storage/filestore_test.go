@@ -19,6 +19,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"io"
 	"io/ioutil"
 	"os"
@@ -26,6 +27,7 @@ import (
 	"testing"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/storage/localstore"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -200,3 +202,164 @@ func TestGetAllReferences(t *testing.T) {
 		}
 	}
 }
+
+// TestFileStoreUploadStats tests that storing the same data twice reports
+// all the chunks of the second upload as already present
+func TestFileStoreUploadStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	tags := chunk.NewTags()
+	fileStore := NewFileStore(localStore, localStore, NewFileStoreParams(), tags)
+	slice := testutil.RandomBytes(1, 10*testDataSize)
+
+	upload := func() UploadStats {
+		tag, err := tags.Create("test", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := sctx.SetTag(context.Background(), tag.Uid)
+		_, wait, err := fileStore.Store(ctx, bytes.NewReader(slice), int64(len(slice)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := fileStore.UploadStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	first := upload()
+	if first.Stored == 0 || first.Seen != 0 {
+		t.Fatalf("expected all chunks of first upload to be new, got stored %d seen %d", first.Stored, first.Seen)
+	}
+	second := upload()
+	if second.Stored != 0 || second.Seen != first.Stored {
+		t.Fatalf("expected %d chunks of second upload to be seen, got stored %d seen %d", first.Stored, second.Stored, second.Seen)
+	}
+
+	if _, err := fileStore.UploadStats(context.Background()); err == nil {
+		t.Fatal("expected error for context without tag")
+	}
+}
+
+// TestFileStoreChunkerProfile tests that content stored with a non default chunker profile
+// is split into chunks of the profile's size, and that a FileStore with the default
+// profile picks up the profile from the root chunk on retrieval
+func TestFileStoreChunkerProfile(t *testing.T) {
+	for _, toEncrypt := range []bool{false, true} {
+		for _, size := range []int{100, 1024, 5000, 70000} {
+			testFileStoreChunkerProfile(t, toEncrypt, size)
+		}
+	}
+}
+
+func testFileStoreChunkerProfile(t *testing.T, toEncrypt bool, size int) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	params := NewFileStoreParams()
+	params.ChunkerProfile = SmallChunkerProfile.Name
+	tags := chunk.NewTags()
+	fileStore := NewFileStore(localStore, localStore, params, tags)
+
+	tag, err := tags.Create("test-profile", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := sctx.SetTag(context.Background(), tag.Uid)
+
+	data := testutil.RandomBytes(1, size)
+	addr, wait, err := fileStore.Store(ctx, bytes.NewReader(data), int64(size), toEncrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tree of 1024 byte chunks has more data chunks than a tree of default chunks
+	minChunks := int64((size + int(SmallChunkerProfile.ChunkSize) - 1) / int(SmallChunkerProfile.ChunkSize))
+	if split := tag.Get(chunk.StateSplit); split < minChunks {
+		t.Fatalf("size %d encrypted %v: expected at least %d chunks, got %d", size, toEncrypt, minChunks, split)
+	}
+
+	getter := NewHasherStore(localStore, fileStore.hashFunc, toEncrypt, chunk.NewTag(0, "", 0, false))
+	root, err := getter.Get(ctx, Reference(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := root.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile != SmallChunkerProfile {
+		t.Fatalf("size %d encrypted %v: expected profile %d in root chunk, got %d", size, toEncrypt, SmallChunkerProfile.ID, profile.ID)
+	}
+	if root.Size() != uint64(size) {
+		t.Fatalf("size %d encrypted %v: expected root chunk size %d, got %d", size, toEncrypt, size, root.Size())
+	}
+
+	defaultStore := NewFileStore(localStore, localStore, NewFileStoreParams(), chunk.NewTags())
+	reader, _ := defaultStore.Retrieve(context.Background(), addr)
+	got, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("size %d encrypted %v: %v", size, toEncrypt, err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("size %d encrypted %v: retrieved data does not match", size, toEncrypt)
+	}
+}
+
+// TestChunkerProfileSpan tests that the spans of the default profile are unversioned,
+// that the spans of other profiles record the format version along with the profile id,
+// and that spans of unknown format versions are rejected
+func TestChunkerProfileSpan(t *testing.T) {
+	span := func(v uint64) ChunkData {
+		c := make(ChunkData, 8)
+		binary.LittleEndian.PutUint64(c, v)
+		return c
+	}
+	for _, p := range chunkerProfiles {
+		c := span(p.span(4096))
+		if c.Size() != 4096 {
+			t.Fatalf("profile %s: expected size 4096, got %d", p.Name, c.Size())
+		}
+		got, err := c.Profile()
+		if err != nil {
+			t.Fatalf("profile %s: %v", p.Name, err)
+		}
+		if got != p {
+			t.Fatalf("expected profile %s, got %s", p.Name, got.Name)
+		}
+	}
+	if s := DefaultChunkerProfile.span(4096); s != 4096 {
+		t.Fatalf("expected unversioned span of default profile, got %x", s)
+	}
+	if _, err := span(4096 | 0x21<<spanProfileShift).Profile(); err == nil {
+		t.Fatal("expected error for unknown span format version")
+	}
+	if _, err := span(4096 | 0x1f<<spanProfileShift).Profile(); err == nil {
+		t.Fatal("expected error for unknown profile id")
+	}
+}
@@ -23,7 +23,10 @@ import (
 	"sync/atomic"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/spancontext"
 	"github.com/ethersphere/swarm/storage/encryption"
+	"github.com/opentracing/opentracing-go"
+	olog "github.com/opentracing/opentracing-go/log"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -110,6 +113,13 @@ func (h *hasherStore) Get(ctx context.Context, ref Reference) (ChunkData, error)
 		return nil, err
 	}
 
+	var sp opentracing.Span
+	ctx, sp = spancontext.StartSpan(
+		ctx,
+		"hasherstore.get")
+	defer sp.Finish()
+	sp.LogFields(olog.String("ref", addr.String()))
+
 	chunk, err := h.store.Get(ctx, chunk.ModeGetRequest, addr)
 	if err != nil {
 		return nil, err
@@ -222,10 +232,15 @@ func (h *hasherStore) decryptChunkData(chunkData ChunkData, encryptionKey encryp
 	}
 
 	// removing extra bytes which were just added for padding
+	profile, err := ChunkData(decryptedSpan).Profile()
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := uint64(profile.ChunkSize)
 	length := ChunkData(decryptedSpan).Size()
-	for length > chunk.DefaultSize {
-		length = length + (chunk.DefaultSize - 1)
-		length = length / chunk.DefaultSize
+	for length > chunkSize {
+		length = length + (chunkSize - 1)
+		length = length / chunkSize
 		length *= uint64(h.refSize)
 	}
 
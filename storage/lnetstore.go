@@ -20,7 +20,6 @@ import (
 	"context"
 
 	"github.com/ethersphere/swarm/chunk"
-	"github.com/ethersphere/swarm/network/timeouts"
 )
 
 // LNetStore is a wrapper of NetStore, which implements the chunk.Store interface. It is used only by the FileStore,
@@ -39,7 +38,7 @@ func NewLNetStore(store *NetStore) *LNetStore {
 // Get converts a chunk reference to a chunk Request (with empty Origin), handled by the NetStore, and
 // returns the requested chunk, or error.
 func (n *LNetStore) Get(ctx context.Context, mode chunk.ModeGet, ref Address) (ch Chunk, err error) {
-	ctx, cancel := context.WithTimeout(ctx, timeouts.FetcherGlobalTimeout)
+	ctx, cancel := WithFetcherTimeout(ctx)
 	defer cancel()
 
 	return n.NetStore.Get(ctx, mode, NewRequest(ref))
@@ -17,10 +17,12 @@
 package localstore
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/shed"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -87,7 +89,6 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		}
 	}()
 
-	batch := new(leveldb.Batch)
 	target := db.gcTarget()
 
 	// protect database from changing idexes and gcSize
@@ -102,48 +103,93 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		return 0, true, err
 	}
 
-	gcSize, err := db.gcSize.Get()
-	if err != nil {
-		return 0, true, err
-	}
-	metrics.GetOrRegisterGauge(metricName+"/gcsize", nil).Update(int64(gcSize))
-
 	done = true
-	err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
-		if gcSize-collectedCount <= target {
-			return true, nil
+	// number of chunks removed from pull index bins
+	binCountChanges := make(map[uint8]int64)
+	// gcSize and the last gc run are updated atomically
+	// together with removal of items from indexes,
+	// the last gc run only if chunks are removed
+	err = db.shed.Update(func(batch *leveldb.Batch) error {
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			return err
 		}
+		metrics.GetOrRegisterGauge(metricName+"/gcsize", nil).Update(int64(gcSize))
 
-		metrics.GetOrRegisterGauge(metricName+"/storets", nil).Update(item.StoreTimestamp)
-		metrics.GetOrRegisterGauge(metricName+"/accessts", nil).Update(item.AccessTimestamp)
-
-		// delete from retrieve, pull, gc
-		db.retrievalDataIndex.DeleteInBatch(batch, item)
-		db.retrievalAccessIndex.DeleteInBatch(batch, item)
-		db.pullIndex.DeleteInBatch(batch, item)
-		db.gcIndex.DeleteInBatch(batch, item)
-		collectedCount++
-		if collectedCount >= gcBatchSize {
-			// bach size limit reached,
-			// another gc run is needed
-			done = false
-			return true, nil
+		err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			if gcSize-collectedCount <= target {
+				return true, nil
+			}
+
+			metrics.GetOrRegisterGauge(metricName+"/storets", nil).Update(item.StoreTimestamp)
+			metrics.GetOrRegisterGauge(metricName+"/accessts", nil).Update(item.AccessTimestamp)
+
+			// chunks stored by request are not in the pull index
+			inPull, err := db.pullIndex.Has(item)
+			if err != nil {
+				return true, err
+			}
+			if inPull {
+				binCountChanges[db.po(item.Address)]--
+			}
+
+			// delete from retrieve, pull, gc
+			db.retrievalDataIndex.DeleteInBatch(batch, item)
+			db.retrievalAccessIndex.DeleteInBatch(batch, item)
+			db.pullIndex.DeleteInBatch(batch, item)
+			db.gcIndex.DeleteInBatch(batch, item)
+			collectedCount++
+			if collectedCount >= gcBatchSize {
+				// bach size limit reached,
+				// another gc run is needed
+				done = false
+				return true, nil
+			}
+			return false, nil
+		}, nil)
+		if err != nil {
+			return err
 		}
-		return false, nil
-	}, nil)
+
+		if collectedCount == 0 {
+			return nil
+		}
+		db.gcSize.PutInBatch(batch, gcSize-collectedCount)
+		return db.lastGCRun.PutInBatch(batch, &GCRun{
+			Time:      time.Unix(0, now()).UTC(),
+			Collected: collectedCount,
+			GCSize:    gcSize - collectedCount,
+		})
+	})
 	if err != nil {
+		metrics.GetOrRegisterCounter(metricName+"/update/err", nil).Inc(1)
 		return 0, false, err
 	}
+	db.updateBinCounts(binCountChanges)
 	metrics.GetOrRegisterCounter(metricName+"/collected-count", nil).Inc(int64(collectedCount))
+	return collectedCount, done, nil
+}
 
-	db.gcSize.PutInBatch(batch, gcSize-collectedCount)
+// GCRun is the summary of a garbage collection run that removed chunks.
+type GCRun struct {
+	Time      time.Time `json:"time"`      // when the run finished
+	Collected uint64    `json:"collected"` // number of removed chunks
+	GCSize    uint64    `json:"gcSize"`    // gc size after the run
+}
 
-	err = db.shed.WriteBatch(batch)
+// LastGCRun returns the summary of the last garbage collection run that
+// removed chunks. Runs that find nothing to remove are not recorded. If no
+// chunks were ever garbage collected, nil is returned with no error.
+func (db *DB) LastGCRun() (r *GCRun, err error) {
+	r = new(GCRun)
+	err = db.lastGCRun.Get(r)
 	if err != nil {
-		metrics.GetOrRegisterCounter(metricName+"/writebatch/err", nil).Inc(1)
-		return 0, false, err
+		if err == leveldb.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
 	}
-	return collectedCount, done, nil
+	return r, nil
 }
 
 // removeChunksInExcludeIndexFromGC removed any recently chunks in the exclude Index, from the gcIndex.
@@ -211,7 +257,31 @@ func (db *DB) removeChunksInExcludeIndexFromGC() (err error) {
 // gcTrigger retruns the absolute value for garbage collection
 // target value, calculated from db.capacity and gcTargetRatio.
 func (db *DB) gcTarget() (target uint64) {
-	return uint64(float64(db.capacity) * gcTargetRatio)
+	return uint64(float64(db.Capacity()) * gcTargetRatio)
+}
+
+// Capacity returns the number of chunks in garbage collection index
+// above which garbage collection is triggered.
+func (db *DB) Capacity() uint64 {
+	return atomic.LoadUint64(&db.capacity)
+}
+
+// SetCapacity changes the capacity of the database while it is running, as in Options
+// zero sets the default capacity. Garbage collection is triggered if the index is
+// already over the new capacity.
+func (db *DB) SetCapacity(capacity uint64) error {
+	if capacity == 0 {
+		capacity = defaultCapacity
+	}
+	atomic.StoreUint64(&db.capacity, capacity)
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		return err
+	}
+	if gcSize >= capacity {
+		db.triggerGarbageCollection()
+	}
+	return nil
 }
 
 // triggerGarbageCollection signals collectGarbageWorker
@@ -252,7 +322,7 @@ func (db *DB) incGCSizeInBatch(batch *leveldb.Batch, change int64) (err error) {
 	db.gcSize.PutInBatch(batch, new)
 
 	// trigger garbage collection if we reached the capacity
-	if new >= db.capacity {
+	if new >= db.Capacity() {
 		db.triggerGarbageCollection()
 	}
 	return nil
@@ -262,3 +332,156 @@ func (db *DB) incGCSizeInBatch(batch *leveldb.Batch, change int64) (err error) {
 // information when a garbage collection run is done
 // and how many items it removed.
 var testHookCollectGarbage func(collectedCount uint64)
+
+// GCSimulation is the report of a simulated garbage collection run.
+type GCSimulation struct {
+	GCSize  uint64 `json:"gcSize"`  // number of chunks in gc index before the run
+	Target  uint64 `json:"target"`  // number of chunks in gc index after the run
+	Evicted uint64 `json:"evicted"` // number of chunks that would be removed
+	// evicted chunks by proximity order bin
+	Bins map[uint8]*GCSimulationBin `json:"bins,omitempty"`
+	// evicted chunks that are still pinned, these should never be at risk
+	Pinned []chunk.Address `json:"pinned,omitempty"`
+	// number of evicted chunks that are not yet push synced
+	Unsynced uint64 `json:"unsynced"`
+	// number of evicted chunks that were stored recently
+	Recent uint64 `json:"recent"`
+}
+
+// GCSimulationBin reports the chunks that would be evicted
+// from a single proximity order bin.
+type GCSimulationBin struct {
+	Count        uint64    `json:"count"`
+	OldestAccess time.Time `json:"oldestAccess"`
+	NewestAccess time.Time `json:"newestAccess"`
+}
+
+// SimulateGarbageCollection reports which chunks a garbage collection
+// run would remove to reduce the gc index to the target size, without
+// removing anything. If target is 0, the target of regular garbage
+// collection runs is used. Evicted chunks stored later than the recent
+// duration ago are reported as recent.
+func (db *DB) SimulateGarbageCollection(target uint64, recent time.Duration) (sim *GCSimulation, err error) {
+	metricName := "localstore/gc/simulate"
+	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
+	defer totalTimeMetric(metricName, time.Now())
+
+	if target == 0 {
+		target = db.gcTarget()
+	}
+	recentTimestamp := now() - int64(recent)
+
+	// the gc size and the recently pinned chunks, which are removed from the gc
+	// index at the start of a garbage collection run, are read consistently,
+	// the gc index is scanned afterwards without blocking updates
+	gcSize, excluded, err := db.gcSizeAndExcluded()
+	if err != nil {
+		return nil, err
+	}
+	sim = &GCSimulation{
+		GCSize: gcSize,
+		Target: target,
+		Bins:   make(map[uint8]*GCSimulationBin),
+	}
+	gcSize -= uint64(len(excluded))
+
+	err = db.gcIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if gcSize-sim.Evicted <= target {
+			return true, nil
+		}
+		if excluded[string(item.Address)] {
+			return false, nil
+		}
+		sim.Evicted++
+
+		accessed := time.Unix(0, item.AccessTimestamp)
+		po := db.po(item.Address)
+		bin, ok := sim.Bins[po]
+		if !ok {
+			bin = &GCSimulationBin{
+				OldestAccess: accessed,
+			}
+			sim.Bins[po] = bin
+		}
+		bin.Count++
+		bin.NewestAccess = accessed
+
+		pinned, err := db.pinIndex.Has(item)
+		if err != nil {
+			return true, err
+		}
+		if pinned {
+			sim.Pinned = append(sim.Pinned, item.Address)
+		}
+
+		i, err := db.retrievalDataIndex.Get(item)
+		if err != nil {
+			if err == leveldb.ErrNotFound {
+				// the chunk was removed since the scan started
+				return false, nil
+			}
+			return true, err
+		}
+		item.StoreTimestamp = i.StoreTimestamp
+		if item.StoreTimestamp > recentTimestamp {
+			sim.Recent++
+		}
+		unsynced, err := db.pushIndex.Has(item)
+		if err != nil {
+			return true, err
+		}
+		if unsynced {
+			sim.Unsynced++
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// gcSizeAndExcluded returns the size of the gc index and the addresses of the chunks
+// in both the gc index and the gc exclude index. It only iterates the exclude index,
+// which holds the chunks pinned since the last garbage collection run.
+func (db *DB) gcSizeAndExcluded() (gcSize uint64, excluded map[string]bool, err error) {
+	// protect database from changing idexes and gcSize
+	db.batchMu.Lock()
+	defer db.batchMu.Unlock()
+
+	gcSize, err = db.gcSize.Get()
+	if err != nil {
+		return 0, nil, err
+	}
+	excluded = make(map[string]bool)
+	err = db.gcExcludeIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		i, err := db.retrievalAccessIndex.Get(item)
+		if err != nil {
+			if err == leveldb.ErrNotFound {
+				return false, nil
+			}
+			return true, err
+		}
+		item.AccessTimestamp = i.AccessTimestamp
+		i, err = db.retrievalDataIndex.Get(item)
+		if err != nil {
+			if err == leveldb.ErrNotFound {
+				return false, nil
+			}
+			return true, err
+		}
+		item.BinID = i.BinID
+		has, err := db.gcIndex.Has(item)
+		if err != nil {
+			return true, err
+		}
+		if has {
+			excluded[string(item.Address)] = true
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return gcSize, excluded, nil
+}
@@ -56,10 +56,18 @@ func testDBCollectGarbageWorker(t *testing.T) {
 	db, cleanupFunc := newTestDB(t, &Options{
 		Capacity: 100,
 	})
-	testHookCollectGarbageChan := make(chan uint64)
+	// gc size is read in the hook, before the next gc run can start
+	type gcRun struct {
+		collected, gcSize uint64
+	}
+	testHookCollectGarbageChan := make(chan gcRun)
 	defer setTestHookCollectGarbage(func(collectedCount uint64) {
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Error(err)
+		}
 		select {
-		case testHookCollectGarbageChan <- collectedCount:
+		case testHookCollectGarbageChan <- gcRun{collected: collectedCount, gcSize: gcSize}:
 		case <-db.close:
 		}
 	})()
@@ -87,18 +95,13 @@ func testDBCollectGarbageWorker(t *testing.T) {
 
 	gcTarget := db.gcTarget()
 
-	for {
+	// the run that reduced gc size to the target
+	var lastRun gcRun
+	for lastRun.gcSize != gcTarget {
 		select {
-		case <-testHookCollectGarbageChan:
+		case lastRun = <-testHookCollectGarbageChan:
 		case <-time.After(10 * time.Second):
-			t.Error("collect garbage timeout")
-		}
-		gcSize, err := db.gcSize.Get()
-		if err != nil {
-			t.Fatal(err)
-		}
-		if gcSize == gcTarget {
-			break
+			t.Fatal("collect garbage timeout")
 		}
 	}
 
@@ -108,6 +111,45 @@ func testDBCollectGarbageWorker(t *testing.T) {
 
 	t.Run("gc size", newIndexGCSizeTest(db))
 
+	t.Run("proximity histogram", newProximityHistogramTest(db))
+
+	t.Run("last gc run", func(t *testing.T) {
+		r, err := db.LastGCRun()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r == nil {
+			t.Fatal("no last gc run")
+		}
+		if r.GCSize != gcTarget {
+			t.Errorf("got last gc run gc size %v, want %v", r.GCSize, gcTarget)
+		}
+		if r.Collected != lastRun.collected {
+			t.Errorf("got last gc run collected %v, want %v", r.Collected, lastRun.collected)
+		}
+		if r.Time.IsZero() {
+			t.Error("got zero last gc run time")
+		}
+
+		// a run with nothing to collect is not recorded
+		db.triggerGarbageCollection()
+		select {
+		case run := <-testHookCollectGarbageChan:
+			if run.collected != 0 {
+				t.Fatalf("got %v collected chunks, want none", run.collected)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		got, err := db.LastGCRun()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || *got != *r {
+			t.Errorf("got last gc run %+v, want %+v", got, r)
+		}
+	})
+
 	// the first synced chunk should be removed
 	t.Run("get the first synced chunk", func(t *testing.T) {
 		_, err := db.Get(context.Background(), chunk.ModeGetRequest, addrs[0])
@@ -529,3 +571,91 @@ func TestSetTestHookCollectGarbage(t *testing.T) {
 		t.Errorf("got hook value %v, want %v", got, original)
 	}
 }
+
+// TestDB_SimulateGarbageCollection tests that a simulated garbage collection
+// run reports the chunks a real run would remove without removing them.
+func TestDB_SimulateGarbageCollection(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		Capacity: 100,
+	})
+	defer cleanupFunc()
+
+	chunkCount := 20
+	addrs := make([]chunk.Address, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		ch := generateTestRandomChunk()
+		_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Set(context.Background(), chunk.ModeSetSyncPull, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ch.Address())
+	}
+	// the second half of the chunks is push synced and accessed later
+	for _, addr := range addrs[chunkCount/2:] {
+		err := db.Set(context.Background(), chunk.ModeSetSyncPush, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a pinned chunk is excluded from garbage collection
+	err := db.Set(context.Background(), chunk.ModeSetPin, addrs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a chunk in the pin index that is still in the gc index is at risk
+	err = db.pinIndex.Put(shed.Item{Address: addrs[0], PinCounter: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim, err := db.SimulateGarbageCollection(15, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.GCSize != uint64(chunkCount) {
+		t.Errorf("got gc size %v, want %v", sim.GCSize, chunkCount)
+	}
+	// one chunk is excluded, so 4 chunks are evicted to reach the target
+	if sim.Evicted != 4 {
+		t.Errorf("got %v evicted chunks, want 4", sim.Evicted)
+	}
+	var binCount uint64
+	for _, bin := range sim.Bins {
+		binCount += bin.Count
+		if bin.NewestAccess.Before(bin.OldestAccess) {
+			t.Errorf("newest access %v before oldest access %v", bin.NewestAccess, bin.OldestAccess)
+		}
+	}
+	if binCount != sim.Evicted {
+		t.Errorf("got %v chunks in bins, want %v", binCount, sim.Evicted)
+	}
+	if len(sim.Pinned) != 1 || !bytes.Equal(sim.Pinned[0], addrs[0]) {
+		t.Errorf("got pinned chunks %v, want %v", sim.Pinned, addrs[0])
+	}
+	if sim.Unsynced != 4 {
+		t.Errorf("got %v unsynced chunks, want 4", sim.Unsynced)
+	}
+	if sim.Recent != 4 {
+		t.Errorf("got %v recent chunks, want 4", sim.Recent)
+	}
+
+	sim, err = db.SimulateGarbageCollection(15, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Recent != 0 {
+		t.Errorf("got %v recent chunks, want 0", sim.Recent)
+	}
+
+	// nothing is removed
+	t.Run("gc index count", newItemsCountTest(db.gcIndex, chunkCount))
+	for _, addr := range addrs {
+		if _, err := db.Get(context.Background(), chunk.ModeGetRequest, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
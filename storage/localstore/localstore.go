@@ -42,14 +42,22 @@ var (
 	// is updated in parallel and one of the updates
 	// takes longer then the configured timeout duration.
 	ErrAddressLockTimeout = errors.New("address lock timeout")
+	// ErrUnknownIndex is returned when an index is not known by its name.
+	ErrUnknownIndex = errors.New("unknown index")
 )
 
+// MinCapacity is the smallest Capacity DB option a node can be configured with,
+// smaller stores are garbage collected almost continuously.
+const MinCapacity uint64 = 1000
+
 var (
 	// Default value for Capacity DB option.
 	defaultCapacity uint64 = 5000000
 	// Limit the number of goroutines created by Getters
 	// that call updateGC function. Value 0 sets no limit.
 	maxParallelUpdateGC = 1000
+	// Default value for PutGroupSize DB option.
+	defaultPutGroupSize = 1024
 )
 
 // DB is the local store implementation and holds
@@ -92,10 +100,20 @@ type DB struct {
 	// field that stores number of intems in gc index
 	gcSize shed.Uint64Field
 
+	// field that stores the summary of the last garbage
+	// collection run, updated together with gcSize
+	lastGCRun shed.StructField
+
 	// garbage collection is triggered when gcSize exceeds
 	// the capacity value
 	capacity uint64
 
+	// number of chunks in the pull index per proximity order
+	// bin, counted when the database is opened and updated with
+	// every write to the pull index, see ProximityHistogram
+	binCounts   []uint64
+	binCountsMu sync.Mutex
+
 	// triggers garbage collection event loop
 	collectGarbageTrigger chan struct{}
 
@@ -111,6 +129,14 @@ type DB struct {
 
 	batchMu sync.Mutex
 
+	// queue of concurrent puts that are written together
+	// in a single batch by the put that leads the group
+	putQueue      []*putOp
+	putLeading    bool
+	putQueueMu    sync.Mutex
+	putGroupDelay time.Duration
+	putGroupSize  int
+
 	// this channel is closed when close function is called
 	// to terminate other goroutines
 	close chan struct{}
@@ -146,6 +172,14 @@ type Options struct {
 	// to verify whether that chunk needs to be Set and added to
 	// garbage collection index too
 	PutToGCCheck func([]byte) bool
+	// PutGroupDelay is the time that a Put waits for other
+	// concurrent Put calls to be written in the same batch.
+	// Puts that are called while a batch is written are grouped
+	// even if the delay is zero, which is the default.
+	PutGroupDelay time.Duration
+	// PutGroupSize is the maximal number of chunks from concurrent
+	// Put calls that are written in a single batch.
+	PutGroupSize int
 }
 
 // New returns a new DB.  All fields and indexes are initialized
@@ -175,10 +209,15 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		close:                    make(chan struct{}),
 		collectGarbageWorkerDone: make(chan struct{}),
 		putToGCCheck:             o.PutToGCCheck,
+		putGroupDelay:            o.PutGroupDelay,
+		putGroupSize:             o.PutGroupSize,
 	}
 	if db.capacity <= 0 {
 		db.capacity = defaultCapacity
 	}
+	if db.putGroupSize <= 0 {
+		db.putGroupSize = defaultPutGroupSize
+	}
 	if maxParallelUpdateGC > 0 {
 		db.updateGCSem = make(chan struct{}, maxParallelUpdateGC)
 	}
@@ -216,6 +255,10 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 	if err != nil {
 		return nil, err
 	}
+	db.lastGCRun, err = db.shed.NewJSONStructField("last-gc-run")
+	if err != nil {
+		return nil, err
+	}
 	// Functions for retrieval data index.
 	var (
 		encodeValueFunc func(fields shed.Item) (value []byte, err error)
@@ -430,6 +473,11 @@ func New(path string, baseKey []byte, o *Options) (db *DB, err error) {
 		return nil, err
 	}
 
+	err = db.countBins()
+	if err != nil {
+		return nil, err
+	}
+
 	// start garbage collection worker
 	go db.collectGarbageWorker()
 	return db, nil
@@ -470,15 +518,7 @@ func (db *DB) po(addr chunk.Address) (bin uint8) {
 // the returned map keys are the index name, values are the number of elements in the index
 func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 	indexInfo = make(map[string]int)
-	for k, v := range map[string]shed.Index{
-		"retrievalDataIndex":   db.retrievalDataIndex,
-		"retrievalAccessIndex": db.retrievalAccessIndex,
-		"pushIndex":            db.pushIndex,
-		"pullIndex":            db.pullIndex,
-		"gcIndex":              db.gcIndex,
-		"gcExcludeIndex":       db.gcExcludeIndex,
-		"pinIndex":             db.pinIndex,
-	} {
+	for k, v := range db.indexes() {
 		indexSize, err := v.Count()
 		if err != nil {
 			return indexInfo, err
@@ -494,6 +534,43 @@ func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 	return indexInfo, err
 }
 
+// IndexStats returns the approximate number of keys and size on disk of all indexes in localstore
+func (db *DB) IndexStats() (stats map[string]shed.IndexStats, err error) {
+	stats = make(map[string]shed.IndexStats)
+	for k, v := range db.indexes() {
+		s, err := v.Stats()
+		if err != nil {
+			return nil, err
+		}
+		stats[k] = s
+	}
+	return stats, nil
+}
+
+// CompactIndex compacts the keys of the named index starting with the prefix,
+// the whole index if the prefix is empty, so that the space of deleted keys is
+// reclaimed, for example after the garbage collection removed many chunks
+func (db *DB) CompactIndex(name string, prefix []byte) (err error) {
+	index, ok := db.indexes()[name]
+	if !ok {
+		return ErrUnknownIndex
+	}
+	return index.Compact(prefix)
+}
+
+// indexes returns the indexes of localstore by their names
+func (db *DB) indexes() map[string]shed.Index {
+	return map[string]shed.Index{
+		"retrievalDataIndex":   db.retrievalDataIndex,
+		"retrievalAccessIndex": db.retrievalAccessIndex,
+		"pushIndex":            db.pushIndex,
+		"pullIndex":            db.pullIndex,
+		"gcIndex":              db.gcIndex,
+		"gcExcludeIndex":       db.gcExcludeIndex,
+		"pinIndex":             db.pinIndex,
+	}
+}
+
 // chunkToItem creates new Item with data provided by the Chunk.
 func chunkToItem(ch chunk.Chunk) shed.Item {
 	return shed.Item{
@@ -616,3 +616,48 @@ func TestDBDebugIndexes(t *testing.T) {
 	testIndexCounts(t, 1, 1, 0, 1, 1, 1, 1, indexCounts)
 
 }
+
+// TestDBIndexStats tests that the statistics of all indexes are reported
+// and that the indexes can be compacted by their names
+func TestDBIndexStats(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	ch := generateTestRandomChunk()
+	if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.IndexStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{
+		"retrievalDataIndex":   1,
+		"retrievalAccessIndex": 0,
+		"pushIndex":            1,
+		"pullIndex":            1,
+		"gcIndex":              0,
+		"gcExcludeIndex":       0,
+		"pinIndex":             0,
+	} {
+		s, ok := stats[name]
+		if !ok {
+			t.Errorf("no stats of %s", name)
+			continue
+		}
+		if s.Keys != want {
+			t.Errorf("%s: got %v keys, want %v", name, s.Keys, want)
+		}
+	}
+
+	if err := db.CompactIndex("retrievalDataIndex", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompactIndex("pullIndex", []byte{db.po(ch.Address())}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompactIndex("unknownIndex", nil); err != ErrUnknownIndex {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownIndex)
+	}
+}
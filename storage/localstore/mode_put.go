@@ -32,13 +32,18 @@ import (
 // on the Putter mode, it updates required indexes.
 // Put is required to implement chunk.Store
 // interface.
+//
+// Concurrent Put calls are grouped and written to the database
+// in a single batch. Put returns after the batch is written, but
+// the batch is synced to the disk only if the context is returned
+// by the WithSyncPut function.
 func (db *DB) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
 	metricName := fmt.Sprintf("localstore/Put/%s", mode)
 
 	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
 	defer totalTimeMetric(metricName, time.Now())
 
-	exist, err = db.put(mode, chs...)
+	exist, err = db.put(mode, isSyncPut(ctx), chs...)
 	if err != nil {
 		metrics.GetOrRegisterCounter(metricName+"/error", nil).Inc(1)
 	}
@@ -46,14 +51,127 @@ func (db *DB) Put(ctx context.Context, mode chunk.ModePut, chs ...chunk.Chunk) (
 	return exist, err
 }
 
-// put stores Chunks to database and updates other indexes. It acquires lockAddr
-// to protect two calls of this function for the same address in parallel. Item
-// fields Address and Data must not be with their nil values. If chunks with the
-// same address are passed in arguments, only the first chunk will be stored,
-// and following ones will have exist set to true for their index in exist
-// slice. This is the same behaviour as if the same chunks are passed one by one
-// in multiple put method calls.
-func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err error) {
+// put stores Chunks to database and updates other indexes. Item fields Address
+// and Data must not be with their nil values. If chunks with the same address
+// are passed in arguments, only the first chunk will be stored, and following
+// ones will have exist set to true for their index in exist slice. This is the
+// same behaviour as if the same chunks are passed one by one in multiple put
+// method calls.
+//
+// Chunks are added to the queue of puts. If no other put is writing
+// queued puts, this put leads and writes its chunks in the same batch
+// with chunks of other queued puts with the same mode. Otherwise, it
+// waits for its chunks to be written by the leading put or to take the
+// lead from it.
+func (db *DB) put(mode chunk.ModePut, sync bool, chs ...chunk.Chunk) (exist []bool, err error) {
+	switch mode {
+	case chunk.ModePutRequest, chunk.ModePutUpload, chunk.ModePutSync:
+	default:
+		return nil, ErrInvalidMode
+	}
+
+	op := &putOp{
+		mode: mode,
+		chs:  chs,
+		sync: sync,
+		done: make(chan struct{}),
+		lead: make(chan struct{}),
+	}
+
+	db.putQueueMu.Lock()
+	db.putQueue = append(db.putQueue, op)
+	leader := !db.putLeading
+	db.putLeading = true
+	db.putQueueMu.Unlock()
+
+	if !leader {
+		select {
+		case <-op.done:
+			return op.exist, op.err
+		case <-op.lead:
+		}
+	}
+
+	if db.putGroupDelay > 0 {
+		// allow other puts to join the group
+		time.Sleep(db.putGroupDelay)
+	}
+
+	// the leading put is always the first one in the queue,
+	// so it is written with this group
+	db.writePutGroup(db.nextPutGroup())
+
+	// hand over the lead to the first queued put
+	db.putQueueMu.Lock()
+	if len(db.putQueue) > 0 {
+		close(db.putQueue[0].lead)
+	} else {
+		db.putLeading = false
+	}
+	db.putQueueMu.Unlock()
+
+	return op.exist, op.err
+}
+
+// putOp holds arguments and results of a single put
+// that is written in a group with other puts.
+type putOp struct {
+	mode  chunk.ModePut
+	chs   []chunk.Chunk
+	sync  bool
+	exist []bool
+	err   error
+	// closed when the put is written or failed
+	done chan struct{}
+	// closed when the put should lead writing of the next group
+	lead chan struct{}
+}
+
+// nextPutGroup removes the first put from the queue together with
+// following puts of the same mode, as long as their total number of
+// chunks is not greater than the put group size.
+func (db *DB) nextPutGroup() (ops []*putOp) {
+	db.putQueueMu.Lock()
+	defer db.putQueueMu.Unlock()
+
+	if len(db.putQueue) == 0 {
+		return nil
+	}
+	mode := db.putQueue[0].mode
+	var size int
+	queue := db.putQueue[:0]
+	for _, op := range db.putQueue {
+		if op.mode == mode && (len(ops) == 0 || size+len(op.chs) <= db.putGroupSize) {
+			ops = append(ops, op)
+			size += len(op.chs)
+			continue
+		}
+		queue = append(queue, op)
+	}
+	// clear references to grouped puts
+	for i := len(queue); i < len(db.putQueue); i++ {
+		db.putQueue[i] = nil
+	}
+	db.putQueue = queue
+	return ops
+}
+
+// writePutGroup writes chunks of all puts in a single batch. A put that
+// fails to add its chunks to the batch does not prevent others from being
+// written. The batch is synced if any of the puts requires it.
+func (db *DB) writePutGroup(ops []*putOp) {
+	if len(ops) == 0 {
+		return
+	}
+	defer func() {
+		for _, op := range ops {
+			close(op.done)
+		}
+	}()
+
+	metrics.GetOrRegisterCounter("localstore/Put/group", nil).Inc(1)
+	metrics.GetOrRegisterCounter("localstore/Put/group/puts", nil).Inc(int64(len(ops)))
+
 	// protect parallel updates
 	db.batchMu.Lock()
 	defer db.batchMu.Unlock()
@@ -66,93 +184,163 @@ func (db *DB) put(mode chunk.ModePut, chs ...chunk.Chunk) (exist []bool, err err
 	var triggerPushFeed bool                    // signal push feed subscriptions to iterate
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
 
-	exist = make([]bool, len(chs))
-
 	// A lazy populated map of bin ids to properly set
 	// BinID values for new chunks based on initial value from database
 	// and incrementing them.
 	// Values from this map are stored with the batch
 	binIDs := make(map[uint8]uint64)
 
-	switch mode {
-	case chunk.ModePutRequest:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putRequest(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			gcSizeChange += c
+	// addresses of chunks added to the batch by previous puts
+	added := make(map[string]struct{})
+
+	var sync bool
+	written := make([]*putOp, 0, len(ops))
+	for _, op := range ops {
+		// every put is added to its own batch with a copy
+		// of bin ids, so that its changes can be discarded
+		// if it fails
+		opBatch := new(leveldb.Batch)
+		opBinIDs := make(map[uint8]uint64, len(binIDs))
+		for po, id := range binIDs {
+			opBinIDs[po] = id
+		}
+		exist, c, err := db.putChunks(opBatch, opBinIDs, added, op.mode, op.chs, triggerPullFeed, &triggerPushFeed)
+		if err != nil {
+			op.err = err
+			continue
+		}
+		if err := opBatch.Replay(batch); err != nil {
+			op.err = err
+			continue
+		}
+		binIDs = opBinIDs
+		gcSizeChange += c
+		op.exist = exist
+		if op.sync {
+			sync = true
 		}
+		written = append(written, op)
+	}
+	if len(written) == 0 {
+		return
+	}
 
-	case chunk.ModePutUpload:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putUpload(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			if !exists {
-				// chunk is new so, trigger subscription feeds
-				// after the batch is successfully written
-				triggerPullFeed[db.po(ch.Address())] = struct{}{}
-				triggerPushFeed = true
-			}
-			gcSizeChange += c
+	err := db.writePutBatch(batch, binIDs, gcSizeChange, sync)
+	if err != nil {
+		for _, op := range written {
+			op.exist = nil
+			op.err = err
 		}
+		return
+	}
 
-	case chunk.ModePutSync:
-		for i, ch := range chs {
-			if containsChunk(ch.Address(), chs[:i]...) {
-				exist[i] = true
-				continue
-			}
-			exists, c, err := db.putSync(batch, binIDs, chunkToItem(ch))
-			if err != nil {
-				return nil, err
-			}
-			exist[i] = exists
-			if !exists {
-				// chunk is new so, trigger pull subscription feed
-				// after the batch is successfully written
-				triggerPullFeed[db.po(ch.Address())] = struct{}{}
+	// new chunks are added to the pull index in upload and sync modes
+	binCountChanges := make(map[uint8]int64)
+	for _, op := range written {
+		if op.mode == chunk.ModePutRequest {
+			continue
+		}
+		for i, ch := range op.chs {
+			if !op.exist[i] {
+				binCountChanges[db.po(ch.Address())]++
 			}
-			gcSizeChange += c
 		}
+	}
+	db.updateBinCounts(binCountChanges)
 
-	default:
-		return nil, ErrInvalidMode
+	for po := range triggerPullFeed {
+		db.triggerPullSubscriptions(po)
 	}
+	if triggerPushFeed {
+		db.triggerPushSubscriptions()
+	}
+}
 
+// writePutBatch adds bin ids and gc size change to the batch
+// and writes it to the database.
+func (db *DB) writePutBatch(batch *leveldb.Batch, binIDs map[uint8]uint64, gcSizeChange int64, sync bool) (err error) {
 	for po, id := range binIDs {
 		db.binIDs.PutInBatch(batch, uint64(po), id)
 	}
 
 	err = db.incGCSizeInBatch(batch, gcSizeChange)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = db.shed.WriteBatch(batch)
-	if err != nil {
-		return nil, err
+	if sync {
+		return db.shed.WriteBatchSync(batch)
 	}
+	return db.shed.WriteBatch(batch)
+}
 
-	for po := range triggerPullFeed {
-		db.triggerPullSubscriptions(po)
+// putChunks adds chunks of a single put to the batch depending on the mode.
+// Chunks with addresses in the added map are reported as existing, as they
+// are already added to the group batch by previous puts. On success, addresses
+// of all chunks are stored in the added map.
+func (db *DB) putChunks(batch *leveldb.Batch, binIDs map[uint8]uint64, added map[string]struct{}, mode chunk.ModePut, chs []chunk.Chunk, triggerPullFeed map[uint8]struct{}, triggerPushFeed *bool) (exist []bool, gcSizeChange int64, err error) {
+	exist = make([]bool, len(chs))
+
+	for i, ch := range chs {
+		if _, ok := added[string(ch.Address())]; ok || containsChunk(ch.Address(), chs[:i]...) {
+			exist[i] = true
+			continue
+		}
+		var exists bool
+		var c int64
+		switch mode {
+		case chunk.ModePutRequest:
+			exists, c, err = db.putRequest(batch, binIDs, chunkToItem(ch))
+		case chunk.ModePutUpload:
+			exists, c, err = db.putUpload(batch, binIDs, chunkToItem(ch))
+		case chunk.ModePutSync:
+			exists, c, err = db.putSync(batch, binIDs, chunkToItem(ch))
+		default:
+			return nil, 0, ErrInvalidMode
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		exist[i] = exists
+		gcSizeChange += c
 	}
-	if triggerPushFeed {
-		db.triggerPushSubscriptions()
+
+	for i, ch := range chs {
+		// existing chunks may also have their indexes updated in the batch
+		added[string(ch.Address())] = struct{}{}
+		if exist[i] {
+			continue
+		}
+		switch mode {
+		case chunk.ModePutUpload:
+			// chunk is new so, trigger subscription feeds
+			// after the batch is successfully written
+			triggerPullFeed[db.po(ch.Address())] = struct{}{}
+			*triggerPushFeed = true
+		case chunk.ModePutSync:
+			// chunk is new so, trigger pull subscription feed
+			// after the batch is successfully written
+			triggerPullFeed[db.po(ch.Address())] = struct{}{}
+		}
+	}
+	return exist, gcSizeChange, nil
+}
+
+type syncPutKey struct{}
+
+// WithSyncPut returns a context that makes Put calls with it
+// return only after chunks are synced to the disk.
+func WithSyncPut(ctx context.Context) context.Context {
+	return context.WithValue(ctx, syncPutKey{}, true)
+}
+
+// isSyncPut returns true if the context is returned by WithSyncPut.
+func isSyncPut(ctx context.Context) bool {
+	if ctx == nil {
+		return false
 	}
-	return exist, nil
+	sync, _ := ctx.Value(syncPutKey{}).(bool)
+	return sync
 }
 
 // putRequest adds an Item to the batch by updating required indexes:
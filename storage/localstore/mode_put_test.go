@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -477,6 +478,177 @@ func TestPutDuplicateChunks(t *testing.T) {
 	}
 }
 
+// TestModePut_group puts chunks concurrently, some of them in multiple
+// puts, and validates that grouped writes result in the same indexes as
+// if puts were called one by one.
+func TestModePut_group(t *testing.T) {
+	for _, mode := range []chunk.ModePut{
+		chunk.ModePutUpload,
+		chunk.ModePutRequest,
+		chunk.ModePutSync,
+	} {
+		t.Run(mode.String(), func(t *testing.T) {
+			db, cleanupFunc := newTestDB(t, &Options{
+				PutGroupDelay: 10 * time.Millisecond,
+				PutGroupSize:  16,
+			})
+			defer cleanupFunc()
+
+			putsCount := 50
+			chunksCount := 4
+
+			// a chunk that is put by every put
+			shared := generateTestRandomChunk()
+
+			chunks := make([][]chunk.Chunk, putsCount)
+			for i := range chunks {
+				chunks[i] = append(generateTestRandomChunks(chunksCount-1), shared)
+			}
+
+			exist := make([][]bool, putsCount)
+			errs := make(chan error, putsCount)
+			for i := 0; i < putsCount; i++ {
+				go func(i int) {
+					var err error
+					exist[i], err = db.Put(context.Background(), mode, chunks[i]...)
+					errs <- err
+				}(i)
+			}
+			for i := 0; i < putsCount; i++ {
+				if err := <-errs; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var sharedNew int
+			for i := range exist {
+				for j, e := range exist[i] {
+					if j == chunksCount-1 {
+						if !e {
+							sharedNew++
+						}
+						continue
+					}
+					if e {
+						t.Errorf("put %v chunk %v: got exists, want not", i, j)
+					}
+				}
+			}
+			if sharedNew != 1 {
+				t.Errorf("got shared chunk stored %v times, want 1", sharedNew)
+			}
+
+			want := putsCount*(chunksCount-1) + 1
+			newItemsCountTest(db.retrievalDataIndex, want)(t)
+			if mode == chunk.ModePutRequest {
+				newItemsCountTest(db.gcIndex, want)(t)
+				newIndexGCSizeTest(db)(t)
+				return
+			}
+			newItemsCountTest(db.pullIndex, want)(t)
+
+			// bin ids must be unique and without gaps
+			binIDs := make(map[uint8][]uint64)
+			err := db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+				po := db.po(item.Address)
+				binIDs[po] = append(binIDs[po], item.BinID)
+				return false, nil
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for po, ids := range binIDs {
+				for i, id := range ids {
+					if id != uint64(i+1) {
+						t.Fatalf("bin %v: got bin id %v at position %v, want %v", po, id, i, i+1)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestDB_nextPutGroup validates that puts are grouped
+// by mode and limited by the put group size.
+func TestDB_nextPutGroup(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{
+		PutGroupSize: 4,
+	})
+	defer cleanupFunc()
+
+	newOp := func(mode chunk.ModePut, count int) *putOp {
+		return &putOp{
+			mode: mode,
+			chs:  generateTestRandomChunks(count),
+		}
+	}
+
+	ops := []*putOp{
+		newOp(chunk.ModePutUpload, 2),
+		newOp(chunk.ModePutSync, 1),
+		newOp(chunk.ModePutUpload, 1),
+		newOp(chunk.ModePutUpload, 2),
+		newOp(chunk.ModePutUpload, 1),
+		newOp(chunk.ModePutSync, 5),
+	}
+	db.putQueue = append(db.putQueue, ops...)
+
+	for i, want := range [][]*putOp{
+		{ops[0], ops[2], ops[4]},
+		{ops[1]},
+		{ops[3]},
+		// the first put is grouped even if it has more chunks than the size
+		{ops[5]},
+		nil,
+	} {
+		got := db.nextPutGroup()
+		if len(got) != len(want) {
+			t.Fatalf("group %v: got %v puts, want %v", i, len(got), len(want))
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Errorf("group %v: got put %v, want %v", i, got[j], want[j])
+			}
+		}
+	}
+}
+
+// TestModePut_syncPut validates that puts with the context
+// returned by WithSyncPut are stored and that put is synced.
+func TestModePut_syncPut(t *testing.T) {
+	if isSyncPut(context.Background()) {
+		t.Error("background context is sync put")
+	}
+	ctx := WithSyncPut(context.Background())
+	if !isSyncPut(ctx) {
+		t.Error("context is not sync put")
+	}
+
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	chunks := generateTestRandomChunks(10)
+	exist, err := db.Put(ctx, chunk.ModePutUpload, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range exist {
+		if e {
+			t.Errorf("chunk %v: got exists, want not", i)
+		}
+	}
+	for _, ch := range chunks {
+		got, err := db.Get(context.Background(), chunk.ModeGetLookup, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data(), ch.Data()) {
+			t.Errorf("got chunk %s data %x, want %x", ch.Address().Hex(), got.Data(), ch.Data())
+		}
+	}
+	newItemsCountTest(db.pushIndex, len(chunks))(t)
+}
+
 // BenchmarkPutUpload runs a series of benchmarks that upload
 // a specific number of chunks in parallel.
 //
@@ -0,0 +1,129 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Reupload puts stored chunks back to the push and pull sync indexes as if
+// they were uploaded again, so that they are synced to their neighbourhood
+// again. The chunks get a new bin id, so that pull syncing peers that already
+// synced the bin up to the previous one receive them as well.
+// It returns chunk.ErrChunkNotFound if any of the chunks is not stored.
+func (db *DB) Reupload(ctx context.Context, addrs ...chunk.Address) (err error) {
+	metricName := "localstore/Reupload"
+
+	metrics.GetOrRegisterCounter(metricName, nil).Inc(1)
+	defer totalTimeMetric(metricName, time.Now())
+
+	err = db.reupload(addrs...)
+	if err != nil {
+		metrics.GetOrRegisterCounter(metricName+"/error", nil).Inc(1)
+	}
+	return err
+}
+
+func (db *DB) reupload(addrs ...chunk.Address) (err error) {
+	// protect parallel updates
+	db.batchMu.Lock()
+	defer db.batchMu.Unlock()
+
+	batch := new(leveldb.Batch)
+	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
+	binCountChanges := make(map[uint8]int64)    // number of chunks added to pull index bins
+	binIDs := make(map[uint8]uint64)
+
+	for _, addr := range addrs {
+		item := addressToItem(addr)
+		po := db.po(addr)
+
+		i, err := db.retrievalDataIndex.Get(item)
+		switch err {
+		case nil:
+		case leveldb.ErrNotFound:
+			return chunk.ErrChunkNotFound
+		default:
+			return err
+		}
+		item = i
+
+		// the gc index is keyed by the bin id,
+		// so the item needs to be replaced
+		inGC := false
+		i, err = db.retrievalAccessIndex.Get(item)
+		switch err {
+		case nil:
+			item.AccessTimestamp = i.AccessTimestamp
+			inGC, err = db.gcIndex.Has(item)
+			if err != nil {
+				return err
+			}
+		case leveldb.ErrNotFound:
+			// the chunk is not accessed before
+		default:
+			return err
+		}
+		if inGC {
+			db.gcIndex.DeleteInBatch(batch, item)
+		}
+		// chunks stored by request are not in the pull index yet
+		inPull, err := db.pullIndex.Has(item)
+		if err != nil {
+			return err
+		}
+		if !inPull {
+			binCountChanges[po]++
+		}
+		db.pullIndex.DeleteInBatch(batch, item)
+
+		item.BinID, err = db.incBinID(binIDs, po)
+		if err != nil {
+			return err
+		}
+		db.retrievalDataIndex.PutInBatch(batch, item)
+		db.pullIndex.PutInBatch(batch, item)
+		db.pushIndex.PutInBatch(batch, item)
+		if inGC {
+			db.gcIndex.PutInBatch(batch, item)
+		}
+		triggerPullFeed[po] = struct{}{}
+	}
+
+	for po, id := range binIDs {
+		db.binIDs.PutInBatch(batch, uint64(po), id)
+	}
+
+	err = db.shed.WriteBatch(batch)
+	if err != nil {
+		return err
+	}
+	db.updateBinCounts(binCountChanges)
+
+	for po := range triggerPullFeed {
+		db.triggerPullSubscriptions(po)
+	}
+	if len(addrs) > 0 {
+		db.triggerPushSubscriptions()
+	}
+	return nil
+}
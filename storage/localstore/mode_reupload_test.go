@@ -0,0 +1,78 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// TestReupload validates that a synced chunk is put back to the push and
+// pull indexes with a new bin id, keeping its place in the gc index.
+func TestReupload(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	wantTimestamp := time.Now().UTC().UnixNano()
+	defer setNow(func() (t int64) {
+		return wantTimestamp
+	})()
+
+	ch := generateTestRandomChunk()
+
+	_, err := db.Put(context.Background(), chunk.ModePutUpload, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Set(context.Background(), chunk.ModeSetSyncPush, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("synced", func(t *testing.T) {
+		newPushIndexTest(db, ch, wantTimestamp, leveldb.ErrNotFound)(t)
+		newGCIndexTest(db, ch, wantTimestamp, wantTimestamp, 1, nil)(t)
+	})
+
+	err = db.Reupload(context.Background(), ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reuploaded", func(t *testing.T) {
+		newRetrieveIndexesTestWithAccess(db, ch, wantTimestamp, wantTimestamp)(t)
+		newPushIndexTest(db, ch, wantTimestamp, nil)(t)
+		newPullIndexTest(db, ch, 1, leveldb.ErrNotFound)(t)
+		newPullIndexTest(db, ch, 2, nil)(t)
+		newGCIndexTest(db, ch, wantTimestamp, wantTimestamp, 1, leveldb.ErrNotFound)(t)
+		newGCIndexTest(db, ch, wantTimestamp, wantTimestamp, 2, nil)(t)
+		newItemsCountTest(db.pullIndex, 1)(t)
+		newItemsCountTest(db.gcIndex, 1)(t)
+		newIndexGCSizeTest(db)(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := db.Reupload(context.Background(), generateTestRandomChunk().Address())
+		if err != chunk.ErrChunkNotFound {
+			t.Fatalf("got error %v, want %v", err, chunk.ErrChunkNotFound)
+		}
+	})
+}
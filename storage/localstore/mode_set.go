@@ -59,6 +59,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 	// to be done after write batch function successfully executes
 	var gcSizeChange int64                      // number to add or subtract from gcSize
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
+	binCountChanges := make(map[uint8]int64)    // number of chunks added to or removed from pull index bins
 
 	switch mode {
 	case chunk.ModeSetAccess:
@@ -68,7 +69,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 		binIDs := make(map[uint8]uint64)
 		for _, addr := range addrs {
 			po := db.po(addr)
-			c, err := db.setAccess(batch, binIDs, addr, po)
+			c, err := db.setAccess(batch, binIDs, binCountChanges, addr, po)
 			if err != nil {
 				return err
 			}
@@ -90,7 +91,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 
 	case chunk.ModeSetRemove:
 		for _, addr := range addrs {
-			c, err := db.setRemove(batch, addr)
+			c, err := db.setRemove(batch, binCountChanges, addr)
 			if err != nil {
 				return err
 			}
@@ -125,6 +126,7 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 	if err != nil {
 		return err
 	}
+	db.updateBinCounts(binCountChanges)
 	for po := range triggerPullFeed {
 		db.triggerPullSubscriptions(po)
 	}
@@ -133,8 +135,8 @@ func (db *DB) set(mode chunk.ModeSet, addrs ...chunk.Address) (err error) {
 
 // setAccess sets the chunk access time by updating required indexes:
 //  - add to pull, insert to gc
-// Provided batch and binID map are updated.
-func (db *DB) setAccess(batch *leveldb.Batch, binIDs map[uint8]uint64, addr chunk.Address, po uint8) (gcSizeChange int64, err error) {
+// Provided batch, binID and bin count changes maps are updated.
+func (db *DB) setAccess(batch *leveldb.Batch, binIDs map[uint8]uint64, binCountChanges map[uint8]int64, addr chunk.Address, po uint8) (gcSizeChange int64, err error) {
 
 	item := addressToItem(addr)
 
@@ -170,6 +172,13 @@ func (db *DB) setAccess(batch *leveldb.Batch, binIDs map[uint8]uint64, addr chun
 	}
 	item.AccessTimestamp = now()
 	db.retrievalAccessIndex.PutInBatch(batch, item)
+	inPull, err := db.pullIndex.Has(item)
+	if err != nil {
+		return 0, err
+	}
+	if !inPull {
+		binCountChanges[po]++
+	}
 	db.pullIndex.PutInBatch(batch, item)
 
 	ok, err := db.pinIndex.Has(item)
@@ -318,8 +327,8 @@ func (db *DB) setSync(batch *leveldb.Batch, addr chunk.Address, mode chunk.ModeS
 
 // setRemove removes the chunk by updating indexes:
 //  - delete from retrieve, pull, gc
-// Provided batch is updated.
-func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange int64, err error) {
+// Provided batch and bin count changes map are updated.
+func (db *DB) setRemove(batch *leveldb.Batch, binCountChanges map[uint8]int64, addr chunk.Address) (gcSizeChange int64, err error) {
 	item := addressToItem(addr)
 
 	// need to get access timestamp here as it is not
@@ -340,6 +349,14 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr chunk.Address) (gcSizeChange
 	item.StoreTimestamp = i.StoreTimestamp
 	item.BinID = i.BinID
 
+	inPull, err := db.pullIndex.Has(item)
+	if err != nil {
+		return 0, err
+	}
+	if inPull {
+		binCountChanges[db.po(addr)]--
+	}
+
 	db.retrievalDataIndex.DeleteInBatch(batch, item)
 	db.retrievalAccessIndex.DeleteInBatch(batch, item)
 	db.pullIndex.DeleteInBatch(batch, item)
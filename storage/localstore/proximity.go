@@ -0,0 +1,228 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+)
+
+// ProximityHistogram returns the number of chunks in database for every
+// proximity order bin, the index of the slice being the proximity order of
+// the chunks to the base key. The histogram metrics and the storage radius
+// metric are updated along.
+func (db *DB) ProximityHistogram() (histogram []uint64, err error) {
+	metricName := "localstore/proximity"
+
+	db.binCountsMu.Lock()
+	histogram = append([]uint64(nil), db.binCounts...)
+	db.binCountsMu.Unlock()
+
+	for bin, count := range histogram {
+		metrics.GetOrRegisterGauge(fmt.Sprintf("%s/bin/%d", metricName, bin), nil).Update(int64(count))
+	}
+	radius := storageRadius(histogram, db.Capacity())
+	metrics.GetOrRegisterGauge(metricName+"/radius", nil).Update(int64(radius))
+	return histogram, nil
+}
+
+// StorageRadius returns the lowest proximity order from which all chunks
+// stored in database fit in its capacity. Chunks of lower bins are stored
+// only until they are garbage collected, so there is no point in syncing
+// them.
+func (db *DB) StorageRadius() (radius uint8, err error) {
+	db.binCountsMu.Lock()
+	defer db.binCountsMu.Unlock()
+
+	return storageRadius(db.binCounts, db.Capacity()), nil
+}
+
+// countBins counts the chunks in the pull index for every proximity
+// order bin. It is called only when the database is opened, as the counts
+// are kept up to date by updateBinCounts after that.
+func (db *DB) countBins() (err error) {
+	counts := make([]uint64, chunk.MaxPO+1)
+	for bin := range counts {
+		err = db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			counts[bin]++
+			return false, nil
+		}, &shed.IterateOptions{
+			Prefix: []byte{uint8(bin)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	db.binCountsMu.Lock()
+	db.binCounts = counts
+	db.binCountsMu.Unlock()
+	return nil
+}
+
+// updateBinCounts adds changes of the number of chunks in the pull index
+// to the counts of their proximity order bins. It must be called only
+// after the batch with the changes is written to the database.
+func (db *DB) updateBinCounts(changes map[uint8]int64) {
+	if len(changes) == 0 {
+		return
+	}
+
+	db.binCountsMu.Lock()
+	defer db.binCountsMu.Unlock()
+
+	for bin, change := range changes {
+		if change < 0 && uint64(-change) > db.binCounts[bin] {
+			db.binCounts[bin] = 0
+			continue
+		}
+		db.binCounts[bin] = uint64(int64(db.binCounts[bin]) + change)
+	}
+}
+
+// Sample returns the addresses of up to n chunks chosen at random from the chunks
+// in database with proximity order to the base key from minPO to maxPO, so that
+// the possession of stored chunks can be spot-checked.
+//
+// The samples are spread over the bins by their chunk counts. In every bin the pull
+// index is sought from a random bin id, instead of being iterated over, and the first
+// chunk from it not sampled yet is taken, so that the cost is proportional to n.
+func (db *DB) Sample(minPO, maxPO uint8, n int) (addrs []chunk.Address, err error) {
+	if n <= 0 || minPO > maxPO {
+		return nil, nil
+	}
+	if maxPO > chunk.MaxPO {
+		maxPO = chunk.MaxPO
+	}
+	db.binCountsMu.Lock()
+	counts := append([]uint64(nil), db.binCounts[minPO:int(maxPO)+1]...)
+	db.binCountsMu.Unlock()
+
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	// draw the positions of the samples among the chunks of all bins
+	// and count the samples that fall in every bin
+	quotas := make([]uint64, len(counts))
+	if uint64(n) >= total {
+		copy(quotas, counts)
+	} else {
+		positions := make(map[uint64]struct{}, n)
+		for len(positions) < n {
+			positions[uint64(rand.Int63n(int64(total)))] = struct{}{}
+		}
+		for pos := range positions {
+			i := 0
+			for pos >= counts[i] {
+				pos -= counts[i]
+				i++
+			}
+			quotas[i]++
+		}
+	}
+
+	sampled := make(map[string]struct{})
+	for i, quota := range quotas {
+		if quota == 0 {
+			continue
+		}
+		bin := minPO + uint8(i)
+		if quota == counts[i] {
+			// all chunks of the bin are sampled
+			err := db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+				addrs = append(addrs, append(chunk.Address(nil), item.Address...))
+				return len(addrs) >= n, nil
+			}, &shed.IterateOptions{
+				Prefix: []byte{bin},
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		last, err := db.binIDs.Get(uint64(bin))
+		if err != nil {
+			return nil, err
+		}
+		if last == 0 {
+			continue
+		}
+		for ; quota > 0; quota-- {
+			addr, err := db.sampleBin(bin, uint64(rand.Int63n(int64(last)))+1, sampled)
+			if err != nil {
+				return nil, err
+			}
+			if addr == nil {
+				// all chunks of the bin are sampled
+				break
+			}
+			sampled[string(addr)] = struct{}{}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// sampleBin returns the address of the first chunk in the pull index of the bin
+// from the bin id that is not sampled yet, wrapping around to the start of the bin.
+// It returns nil if all chunks of the bin are sampled.
+func (db *DB) sampleBin(bin uint8, binID uint64, sampled map[string]struct{}) (addr chunk.Address, err error) {
+	find := func(startFrom *shed.Item, until uint64) error {
+		return db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			if until > 0 && item.BinID >= until {
+				return true, nil
+			}
+			if _, ok := sampled[string(item.Address)]; ok {
+				return false, nil
+			}
+			addr = append(chunk.Address(nil), item.Address...)
+			return true, nil
+		}, &shed.IterateOptions{
+			StartFrom: startFrom,
+			Prefix:    []byte{bin},
+		})
+	}
+	if err := find(&shed.Item{Address: db.addressInBin(bin), BinID: binID}, 0); err != nil || addr != nil {
+		return addr, err
+	}
+	return addr, find(nil, binID)
+}
+
+// storageRadius returns the lowest bin of the histogram for which
+// the number of chunks in it and all higher bins is not over capacity.
+// The highest bin is never out of the radius.
+func storageRadius(histogram []uint64, capacity uint64) (radius uint8) {
+	var count uint64
+	for bin := len(histogram) - 1; bin >= 0; bin-- {
+		count += histogram[bin]
+		if count > capacity {
+			if bin == len(histogram)-1 {
+				return uint8(bin)
+			}
+			return uint8(bin + 1)
+		}
+	}
+	return 0
+}
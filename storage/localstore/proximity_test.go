@@ -0,0 +1,177 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package localstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/shed"
+)
+
+// TestProximityHistogram validates that the histogram counts the stored
+// chunks by their proximity order to the base key
+func TestProximityHistogram(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, &Options{Capacity: 100})
+	defer cleanupFunc()
+
+	want := make([]uint64, chunk.MaxPO+1)
+	for i := 0; i < 50; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		want[db.po(ch.Address())]++
+	}
+
+	histogram, err := db.ProximityHistogram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(histogram) != fmt.Sprint(want) {
+		t.Fatalf("got histogram %v, want %v", histogram, want)
+	}
+	radius, err := db.StorageRadius()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if radius != 0 {
+		t.Fatalf("got radius %v, want 0", radius)
+	}
+
+	// chunks stored by request are counted only
+	// when they are added to the pull index by access
+	ch := generateTestRandomChunk()
+	if _, err := db.Put(context.Background(), chunk.ModePutRequest, ch); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("put request", newProximityHistogramTest(db))
+
+	if err := db.Set(context.Background(), chunk.ModeSetAccess, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("set access", newProximityHistogramTest(db))
+
+	if err := db.Set(context.Background(), chunk.ModeSetRemove, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("set remove", newProximityHistogramTest(db))
+}
+
+// newProximityHistogramTest returns a test function that validates
+// that the proximity histogram matches the chunks in the pull index.
+func newProximityHistogramTest(db *DB) func(t *testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+
+		want := make([]uint64, chunk.MaxPO+1)
+		err := db.pullIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+			want[db.po(item.Address)]++
+			return false, nil
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		histogram, err := db.ProximityHistogram()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(histogram) != fmt.Sprint(want) {
+			t.Fatalf("got histogram %v, want %v", histogram, want)
+		}
+	}
+}
+
+// TestSample validates that the sampled chunks are stored and
+// within the proximity order range, and are not sampled twice
+func TestSample(t *testing.T) {
+	db, cleanupFunc := newTestDB(t, nil)
+	defer cleanupFunc()
+
+	stored := make(map[string]uint8)
+	for i := 0; i < 100; i++ {
+		ch := generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), chunk.ModePutUpload, ch); err != nil {
+			t.Fatal(err)
+		}
+		stored[ch.Address().Hex()] = db.po(ch.Address())
+	}
+
+	for _, tc := range []struct {
+		minPO, maxPO uint8
+		n            int
+	}{
+		{0, chunk.MaxPO, 10},
+		{0, chunk.MaxPO, 200},
+		{1, 2, 10},
+		{2, 1, 10},
+		{0, chunk.MaxPO, 0},
+	} {
+		var want int
+		for _, po := range stored {
+			if po >= tc.minPO && po <= tc.maxPO {
+				want++
+			}
+		}
+		if want > tc.n {
+			want = tc.n
+		}
+
+		addrs, err := db.Sample(tc.minPO, tc.maxPO, tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != want {
+			t.Fatalf("range %v-%v: got %v chunks, want %v", tc.minPO, tc.maxPO, len(addrs), want)
+		}
+		seen := make(map[string]bool)
+		for _, addr := range addrs {
+			po, ok := stored[addr.Hex()]
+			if !ok {
+				t.Fatalf("sampled chunk %s not stored", addr)
+			}
+			if po < tc.minPO || po > tc.maxPO {
+				t.Fatalf("sampled chunk %s with proximity order %v out of range %v-%v", addr, po, tc.minPO, tc.maxPO)
+			}
+			if seen[addr.Hex()] {
+				t.Fatalf("chunk %s sampled twice", addr)
+			}
+			seen[addr.Hex()] = true
+		}
+	}
+}
+
+func TestStorageRadius(t *testing.T) {
+	for _, tc := range []struct {
+		histogram []uint64
+		capacity  uint64
+		radius    uint8
+	}{
+		{[]uint64{0, 0, 0, 0}, 10, 0},
+		{[]uint64{5, 3, 1, 1}, 10, 0},
+		{[]uint64{6, 3, 1, 1}, 10, 1},
+		{[]uint64{6, 5, 4, 1}, 10, 1},
+		{[]uint64{6, 5, 5, 1}, 10, 2},
+		{[]uint64{6, 5, 5, 11}, 10, 3},
+	} {
+		if radius := storageRadius(tc.histogram, tc.capacity); radius != tc.radius {
+			t.Errorf("histogram %v, capacity %v: got radius %v, want %v", tc.histogram, tc.capacity, radius, tc.radius)
+		}
+	}
+}
@@ -41,6 +41,8 @@ import (
 const (
 	// capacity for the fetchers LRU cache
 	fetchersCapacity = 500000
+	// capacity for the LRU cache of the chunks that failed to be fetched
+	failedFetchesCapacity = 10000
 )
 
 var (
@@ -63,6 +65,8 @@ type Fetcher struct {
 	CreatedBy string    // who created the fetcher - "request" or "syncing", used for metrics measuring lifecycle of fetchers
 
 	RequestedBySyncer bool // whether we have issued at least once a request through Offered/Wanted hashes flow
+
+	probe bool // created by Probe and not requested by anyone else, the delivered chunk is not stored, protected by putMu
 }
 
 // NewFetcher is a constructor for a Fetcher
@@ -99,25 +103,87 @@ type NetStore struct {
 	requestGroup singleflight.Group
 	RemoteGet    RemoteGetFunc
 	logger       log.Logger
+
+	// StorageRadius reports the lowest proximity order bin the local store
+	// has capacity to hold, nil meaning that it holds all bins
+	StorageRadius func() (uint8, error)
+
+	// FailedFetchTTL is how long the chunks that could not be fetched from the
+	// network are not requested again, 0 disabling the negative caching.
+	// Get requests with a context returned by WithRefresh are always fetched.
+	FailedFetchTTL time.Duration
+	failedFetches  *lru.Cache
+}
+
+// failedFetch records why and when a chunk could not be fetched
+type failedFetch struct {
+	err error
+	at  time.Time
+}
+
+// refreshKey is the context key set by WithRefresh
+type refreshKey struct{}
+
+// WithRefresh returns a context with which NetStore fetches the chunks that
+// are not in the local store from the network, even if they recently failed
+// to be fetched
+func WithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+// isRefresh returns true if the context is returned by WithRefresh
+func isRefresh(ctx context.Context) bool {
+	refresh, _ := ctx.Value(refreshKey{}).(bool)
+	return refresh
+}
+
+type fetcherTimeoutKey struct{}
+
+// WithFetcherTimeout returns a context that ends with the global fetcher timeout.
+// Chunks that are not delivered before it passes are recorded as failed to be
+// fetched, unlike the ones that are not delivered before an earlier deadline
+// of the parent context or of a context derived from the returned one.
+func WithFetcherTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(timeouts.FetcherGlobalTimeout)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return context.WithValue(ctx, fetcherTimeoutKey{}, deadline), cancel
+}
+
+// isFetcherTimeout returns true if the context ended with
+// the global fetcher timeout set by WithFetcherTimeout
+func isFetcherTimeout(ctx context.Context) bool {
+	if ctx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	timeout, ok := ctx.Value(fetcherTimeoutKey{}).(time.Time)
+	if !ok {
+		return false
+	}
+	deadline, _ := ctx.Deadline()
+	return deadline.Equal(timeout)
 }
 
 // NewNetStore creates a new NetStore using the provided chunk.Store and localID of the node.
 func NewNetStore(store chunk.Store, baseAddr *network.BzzAddr) *NetStore {
 	fetchers, _ := lru.New(fetchersCapacity)
+	failedFetches, _ := lru.New(failedFetchesCapacity)
 
 	return &NetStore{
-		fetchers: fetchers,
-		Store:    store,
-		LocalID:  baseAddr.ID(),
-		logger:   log.NewBaseAddressLogger(baseAddr.ShortString()),
+		fetchers:      fetchers,
+		failedFetches: failedFetches,
+		Store:         store,
+		LocalID:       baseAddr.ID(),
+		logger:        log.NewBaseAddressLogger(baseAddr.ShortString()),
 	}
 }
 
 // Put stores a chunk in localstore, and delivers to all requestor peers using the fetcher stored in
 // the fetchers cache
+// The chunks delivered only for a Probe are not stored, they are reported as not existing
 func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([]bool, error) {
 	// first notify all goroutines waiting on the fetcher that the chunk has been received
 
+	var probed map[int]bool // indexes of the chunks delivered for probes
 	n.putMu.Lock()
 	for i, ch := range chs {
 		n.logger.Trace("netstore.put", "index", i, "ref", ch.Address().String(), "mode", mode)
@@ -127,20 +193,51 @@ func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([
 			// delivered through syncing and through a retrieve request
 			fii := fi.(*Fetcher)
 			fii.SafeClose(ch)
+			if fii.probe {
+				n.fetchers.Remove(ch.Address().String())
+				if probed == nil {
+					probed = make(map[int]bool)
+				}
+				probed[i] = true
+			}
 		}
 	}
 	n.putMu.Unlock()
 
+	stored := chs
+	if len(probed) > 0 {
+		metrics.GetOrRegisterCounter("netstore/probe/discarded", nil).Inc(int64(len(probed)))
+		stored = make([]Chunk, 0, len(chs)-len(probed))
+		for i, ch := range chs {
+			if !probed[i] {
+				stored = append(stored, ch)
+			}
+		}
+		if len(stored) == 0 {
+			return make([]bool, len(chs)), nil
+		}
+	}
+
 	// put the chunk to the localstore, there should be no error
-	exist, err := n.Store.Put(ctx, mode, chs...)
+	exist, err := n.Store.Put(ctx, mode, stored...)
 	if err != nil {
 		return nil, err
 	}
+	if len(probed) > 0 {
+		all := make([]bool, len(chs))
+		for i, j := 0, 0; i < len(chs); i++ {
+			if !probed[i] {
+				all[i] = exist[j]
+				j++
+			}
+		}
+		exist = all
+	}
 
 	n.putMu.Lock()
 	defer n.putMu.Unlock()
 
-	for _, ch := range chs {
+	for _, ch := range stored {
 		fi, ok := n.fetchers.Get(ch.Address().String())
 		if ok {
 			fii := fi.(*Fetcher)
@@ -155,6 +252,8 @@ func (n *NetStore) Put(ctx context.Context, mode chunk.ModePut, chs ...Chunk) ([
 			}
 			n.fetchers.Remove(ch.Address().String())
 		}
+		// the chunk is no longer missing
+		n.failedFetches.Remove(ch.Address().String())
 	}
 
 	return exist, nil
@@ -172,15 +271,25 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 	start := time.Now()
 
 	ref := req.Addr
+	logger := log.WithRequest(ctx, n.logger)
 
 	ch, err = n.Store.Get(ctx, mode, ref)
 	if err != nil {
 		// TODO: fix comparison - we should be comparing against leveldb.ErrNotFound, this error should be wrapped.
 		if err != ErrChunkNotFound && err != leveldb.ErrNotFound {
-			n.logger.Error("localstore get error", "err", err)
+			logger.Error("localstore get error", "err", err)
 		}
 
-		n.logger.Trace("netstore.chunk-not-in-localstore", "ref", ref.String())
+		logger.Trace("netstore.chunk-not-in-localstore", "ref", ref.String())
+
+		if !isRefresh(ctx) {
+			if err := n.recentFetchFailure(ref); err != nil {
+				metrics.GetOrRegisterCounter("netstore/get/failed_fetch_cached", nil).Inc(1)
+				logger.Trace("netstore.get recently failed to fetch", "ref", ref.String(), "err", err)
+				return nil, err
+			}
+		}
+		countFetch(ctx)
 
 		v, err, _ := n.requestGroup.Do(ref.String(), func() (interface{}, error) {
 			// currently we issue a retrieve request if a fetcher
@@ -193,6 +302,7 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 			if ok {
 				ch, err = n.RemoteFetch(ctx, req, fi)
 				if err != nil {
+					n.addFetchFailure(ctx, req, err)
 					return nil, err
 				}
 			}
@@ -206,15 +316,16 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 		})
 
 		if err != nil {
-			n.logger.Trace(err.Error(), "ref", ref)
+			logger.Trace(err.Error(), "ref", ref)
 			return nil, err
 		}
 
-		n.logger.Trace("netstore.singleflight returned", "ref", ref.String(), "err", err)
+		logger.Trace("netstore.singleflight returned", "ref", ref.String(), "err", err)
 
 		return v.(Chunk), nil
 	}
-	n.logger.Trace("netstore.get returned", "ref", ref.String())
+	logger.Trace("netstore.get returned", "ref", ref.String())
+	countHit(ctx)
 
 	ctx, ssp := spancontext.StartSpan(
 		ctx,
@@ -224,6 +335,46 @@ func (n *NetStore) Get(ctx context.Context, mode chunk.ModeGet, req *Request) (c
 	return ch, nil
 }
 
+// recentFetchFailure returns the error of the last failed fetch of the chunk
+// if it failed within FailedFetchTTL, nil otherwise
+func (n *NetStore) recentFetchFailure(ref Address) error {
+	if n.FailedFetchTTL <= 0 {
+		return nil
+	}
+	v, ok := n.failedFetches.Get(ref.String())
+	if !ok {
+		return nil
+	}
+	f := v.(*failedFetch)
+	since := time.Since(f.at)
+	if since > n.FailedFetchTTL {
+		n.failedFetches.Remove(ref.String())
+		return nil
+	}
+	return fmt.Errorf("chunk %s failed to be fetched %v ago: %w", ref, since.Round(time.Millisecond), f.err)
+}
+
+// addFetchFailure records that the chunk could not be fetched from the network,
+// as there was no peer left to request it from or as it was not delivered within
+// the global fetcher timeout. Requests that ended otherwise with their context,
+// cancelled or with a shorter deadline of the caller, are not recorded as they
+// say nothing about the chunk. Neither are the requests of peers, which can be
+// limited to fewer hops than the node's own requests and would otherwise let
+// any peer block the node from fetching a chunk.
+func (n *NetStore) addFetchFailure(ctx context.Context, req *Request, err error) {
+	if n.FailedFetchTTL <= 0 || req.HopCount != 0 {
+		return
+	}
+	if err != ErrNoSuitablePeer && !isFetcherTimeout(ctx) {
+		return
+	}
+	ref := req.Addr
+	n.failedFetches.Add(ref.String(), &failedFetch{
+		err: err,
+		at:  time.Now(),
+	})
+}
+
 // RemoteFetch is handling the retry mechanism when making a chunk request to our peers.
 // For a given chunk Request, we call RemoteGet, which selects the next eligible peer and
 // issues a RetrieveRequest and we wait for a delivery. If a delivery doesn't arrive within the SearchTimeout
@@ -234,6 +385,7 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 	metrics.GetOrRegisterCounter("remote/fetch", nil).Inc(1)
 
 	ref := req.Addr
+	logger := log.WithRequest(ctx, n.logger)
 
 	for {
 		metrics.GetOrRegisterCounter("remote/fetch/inner", nil).Inc(1)
@@ -245,11 +397,11 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 
 		ctx = context.WithValue(ctx, "remote.fetch", osp)
 
-		log.Trace("remote.fetch", "ref", ref)
+		logger.Trace("remote.fetch", "ref", ref)
 
 		currentPeer, cleanup, err := n.RemoteGet(ctx, req, n.LocalID)
 		if err != nil {
-			n.logger.Trace(err.Error(), "ref", ref)
+			logger.Trace(err.Error(), "ref", ref)
 			osp.LogFields(olog.String("err", err.Error()))
 			osp.Finish()
 			return nil, ErrNoSuitablePeer
@@ -257,12 +409,12 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 		defer cleanup()
 
 		// add peer to the set of peers to skip from now
-		n.logger.Trace("remote.fetch, adding peer to skip", "ref", ref, "peer", currentPeer.String())
+		logger.Trace("remote.fetch, adding peer to skip", "ref", ref, "peer", currentPeer.String())
 		req.PeersToSkip.Store(currentPeer.String(), time.Now())
 
 		select {
 		case <-fi.Delivered:
-			n.logger.Trace("remote.fetch, chunk delivered", "ref", ref, "base", hex.EncodeToString(n.LocalID[:16]))
+			logger.Trace("remote.fetch, chunk delivered", "ref", ref, "base", hex.EncodeToString(n.LocalID[:16]))
 
 			osp.LogFields(olog.Bool("delivered", true))
 			osp.Finish()
@@ -274,7 +426,7 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 			osp.Finish()
 			break
 		case <-ctx.Done(): // global fetcher timeout
-			n.logger.Trace("remote.fetch, global timeout fail", "ref", ref, "err", ctx.Err())
+			logger.Trace("remote.fetch, global timeout fail", "ref", ref, "err", ctx.Err())
 			metrics.GetOrRegisterCounter("remote/fetch/timeout/global", nil).Inc(1)
 
 			osp.LogFields(olog.Bool("fail", true))
@@ -284,6 +436,33 @@ func (n *NetStore) RemoteFetch(ctx context.Context, req *Request, fi *Fetcher) (
 	}
 }
 
+// Probe checks whether a chunk can be retrieved from the network, bypassing the
+// LocalStore. It is used to find locally stored chunks that are missing from
+// their neighbourhood. The delivered chunk is discarded unless it is requested
+// by others while it is probed. It returns false if no peer delivered the chunk
+// before the context deadline, and an error only if the context is cancelled.
+func (n *NetStore) Probe(ctx context.Context, ref Address) (bool, error) {
+	metrics.GetOrRegisterCounter("netstore/probe", nil).Inc(1)
+
+	fi, loaded, _ := n.GetOrCreateFetcher(ctx, ref, "probe")
+	_, err := n.RemoteFetch(ctx, NewRequest(ref), fi)
+	switch err {
+	case nil:
+		return true, nil
+	case ErrNoSuitablePeer, context.DeadlineExceeded:
+		metrics.GetOrRegisterCounter("netstore/probe/miss", nil).Inc(1)
+		log.WithRequest(ctx, n.logger).Trace("netstore.probe miss", "ref", ref.String(), "err", err)
+		// the fetcher of an undelivered probe is not waited for by anyone else
+		if !loaded {
+			n.putMu.Lock()
+			n.fetchers.Remove(ref.String())
+			n.putMu.Unlock()
+		}
+		return false, nil
+	}
+	return false, err
+}
+
 // Has is the storage layer entry point to query the underlying
 // database to return if it has a chunk or not.
 func (n *NetStore) Has(ctx context.Context, ref Address) (bool, error) {
@@ -301,8 +480,13 @@ func (n *NetStore) GetOrCreateFetcher(ctx context.Context, ref Address, interest
 	n.logger.Trace("netstore.has-with-callback.loadorstore", "localID", n.LocalID.String()[:16], "ref", ref.String(), "loaded", loaded, "createdBy", interestedParty)
 	if loaded {
 		f = v.(*Fetcher)
+		// the chunk is also wanted by others than probes
+		if interestedParty != "probe" {
+			f.probe = false
+		}
 	} else {
 		f.CreatedBy = interestedParty
+		f.probe = interestedParty == "probe"
 		n.fetchers.Add(ref.String(), f)
 	}
 
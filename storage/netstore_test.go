@@ -0,0 +1,281 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/chunk"
+	chunktesting "github.com/ethersphere/swarm/chunk/testing"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/network/timeouts"
+	"github.com/ethersphere/swarm/storage/localstore"
+)
+
+// TestNetStoreFailedFetch tests that the chunks which failed to be fetched are
+// not requested from peers again until FailedFetchTTL passes, the chunk is put
+// or the request is a refresh
+func TestNetStoreFailedFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	netStore := NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	netStore.FailedFetchTTL = 500 * time.Millisecond
+	var requests int
+	netStore.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		requests++
+		return nil, func() {}, errors.New("not found")
+	}
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	get := func(ctx context.Context, wantRequests int) error {
+		t.Helper()
+		_, err := netStore.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+		if requests != wantRequests {
+			t.Fatalf("got %v requests, want %v", requests, wantRequests)
+		}
+		return err
+	}
+
+	ctx := context.Background()
+	if err := get(ctx, 1); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	// the failure is cached with its reason
+	if err := get(ctx, 1); !errors.Is(err, ErrNoSuitablePeer) {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	// refresh requests are fetched
+	if err := get(WithRefresh(ctx), 2); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	if err := get(ctx, 2); !errors.Is(err, ErrNoSuitablePeer) {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	// the failure expires
+	time.Sleep(netStore.FailedFetchTTL)
+	if err := get(ctx, 3); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	// the failure is forgotten when the chunk is put
+	if _, err := netStore.Put(ctx, chunk.ModePutSync, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// failures are not cached if disabled
+	netStore.FailedFetchTTL = 0
+	ch = chunktesting.GenerateTestRandomChunk()
+	for i := 4; i < 6; i++ {
+		if err := get(ctx, i); err != ErrNoSuitablePeer {
+			t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+		}
+	}
+}
+
+// TestNetStoreFailedFetchPeerRequest tests that the failed fetches of peer
+// requests, which may be limited to a single hop, are not cached and so do
+// not block the fetches of the node's own requests
+func TestNetStoreFailedFetchPeerRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	netStore := NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	netStore.FailedFetchTTL = time.Minute
+	ch := chunktesting.GenerateTestRandomChunk()
+	var requests int
+	// requests with a single hop left are not forwarded, the others are
+	// delivered by the peer
+	netStore.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		requests++
+		if req.HopCount == 1 {
+			return nil, func() {}, errors.New("hop limit")
+		}
+		go netStore.Put(context.Background(), chunk.ModePutRequest, ch)
+		return &enode.ID{}, func() {}, nil
+	}
+
+	ctx := context.Background()
+	req := NewRequest(ch.Address())
+	req.HopCount = 1
+	if _, err := netStore.Get(ctx, chunk.ModeGetRequest, req); err != ErrNoSuitablePeer {
+		t.Fatalf("got error %v, want %v", err, ErrNoSuitablePeer)
+	}
+	got, err := netStore.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Address(), ch.Address()) {
+		t.Fatalf("got chunk %v, want %v", got.Address(), ch.Address())
+	}
+	if requests != 2 {
+		t.Fatalf("got %v requests, want %v", requests, 2)
+	}
+}
+
+// TestNetStoreFailedFetchDeadline tests that fetches which are not delivered
+// are cached as failed only if they ended with the global fetcher timeout, and
+// not if they ended earlier with the deadline of the caller
+func TestNetStoreFailedFetchDeadline(t *testing.T) {
+	defer func(d time.Duration) { timeouts.FetcherGlobalTimeout = d }(timeouts.FetcherGlobalTimeout)
+	timeouts.FetcherGlobalTimeout = 100 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	netStore := NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	netStore.FailedFetchTTL = time.Minute
+	var requests int
+	// the peer never delivers the chunk
+	netStore.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		requests++
+		return &enode.ID{}, func() {}, nil
+	}
+
+	ch := chunktesting.GenerateTestRandomChunk()
+	get := func(ctx context.Context, wantRequests int) error {
+		t.Helper()
+		_, err := netStore.Get(ctx, chunk.ModeGetRequest, NewRequest(ch.Address()))
+		if requests != wantRequests {
+			t.Fatalf("got %v requests, want %v", requests, wantRequests)
+		}
+		return err
+	}
+
+	// the shorter deadline of the caller is not cached,
+	// whether it is set before or after the fetcher timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ctx, cancel = WithFetcherTimeout(ctx)
+	defer cancel()
+	if err := get(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	ctx, cancel = WithFetcherTimeout(context.Background())
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := get(ctx, 2); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// the fetcher timeout is cached
+	ctx, cancel = WithFetcherTimeout(context.Background())
+	defer cancel()
+	if err := get(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	ctx, cancel = WithFetcherTimeout(context.Background())
+	defer cancel()
+	if err := get(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestNetStoreProbe tests that the chunks delivered for probes are not stored,
+// unlike the other chunks delivered along with them
+func TestNetStoreProbe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "swarm-storage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	localStore, err := localstore.New(dir, make([]byte, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localStore.Close()
+
+	netStore := NewNetStore(localStore, network.NewBzzAddr(make([]byte, 32), nil))
+	probed := chunktesting.GenerateTestRandomChunk()
+	other := chunktesting.GenerateTestRandomChunk()
+	delivered := make(chan []bool, 1)
+	netStore.RemoteGet = func(ctx context.Context, req *Request, localID enode.ID) (*enode.ID, func(), error) {
+		go func() {
+			exist, err := netStore.Put(ctx, chunk.ModePutRequest, other, probed)
+			if err != nil {
+				t.Error(err)
+			}
+			delivered <- exist
+		}()
+		return &enode.ID{}, func() {}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ok, err := netStore.Probe(ctx, probed.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected probed chunk to be delivered")
+	}
+	if exist := <-delivered; len(exist) != 2 || exist[0] || exist[1] {
+		t.Fatalf("expected no delivered chunk to exist, got %v", exist)
+	}
+
+	for _, tc := range []struct {
+		ch   chunk.Chunk
+		want bool
+	}{
+		{probed, false},
+		{other, true},
+	} {
+		has, err := localStore.Has(ctx, tc.ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != tc.want {
+			t.Fatalf("chunk %s: expected stored %v, got %v", tc.ch.Address(), tc.want, has)
+		}
+	}
+	if _, ok := netStore.fetchers.Get(probed.Address().String()); ok {
+		t.Fatal("expected fetcher of the probed chunk to be removed")
+	}
+}
@@ -86,6 +86,9 @@ type API struct {
 	tag        *chunk.Tags
 	hashSize   int
 	state      state.Store // the state store used to store info about pinned files
+	// withinDepth reports whether a chunk is within the neighbourhood depth
+	// of the node, which stores it for the network
+	withinDepth func([]byte) bool
 }
 
 // NewAPI creates a API object that is required for pinning and unpinning
@@ -101,6 +104,12 @@ func NewAPI(lstore *localstore.DB, stateStore state.Store, params *storage.FileS
 	}
 }
 
+// SetWithinDepth sets the function that reports whether a chunk is within the
+// neighbourhood depth of the node, such chunks are not removed by RemoveFiles
+func (p *API) SetWithinDepth(f func([]byte) bool) {
+	p.withinDepth = f
+}
+
 // PinFiles is used to pin a RAW file or a collection (which hash manifest's)
 // to the local Swarm node. It takes the root hash as the argument and walks
 // down the merkle tree and pin all the chunks that are encountered on the
@@ -397,6 +406,15 @@ QuitChunkFor:
 					return
 				}
 
+				profile, err := chunkData.Profile()
+				if err != nil {
+					log.Error("Invalid chunk data from localstore.",
+						"Address", hex.EncodeToString(ref), "err", err)
+					chunkErrC <- err
+					close(doneChunkWorker)
+					return
+				}
+
 				subTreeSize := chunkData.Size()
 				fileSizeLock.Lock()
 				if actualFileSize < subTreeSize {
@@ -404,7 +422,7 @@ QuitChunkFor:
 				}
 				fileSizeLock.Unlock()
 
-				if subTreeSize > chunk.DefaultSize {
+				if subTreeSize > uint64(profile.ChunkSize) {
 					// this is a tree chunk
 					// load the tree's branches
 					branches := (datalen - 8) / hashSize
@@ -418,7 +436,7 @@ QuitChunkFor:
 				} else {
 					// this is a data chunk
 					fileSizeLock.Lock()
-					rcvdFileSize = rcvdFileSize + chunk.DefaultSize
+					rcvdFileSize = rcvdFileSize + uint64(profile.ChunkSize)
 					got := rcvdFileSize
 					need := actualFileSize
 					fileSizeLock.Unlock()
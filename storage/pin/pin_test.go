@@ -86,9 +86,17 @@ func TestPinCollectionUploadEncrypted(t *testing.T) {
 // TestWalker tests the walkChunksFromRootHash function which is the crux of
 // commands like pin, unpin & list.
 func TestWalker(t *testing.T) {
-	sizes := []int{1, 4095, 4096, 4097, 123456}
+	for _, profile := range []*storage.ChunkerProfile{storage.DefaultChunkerProfile, storage.SmallChunkerProfile} {
+		t.Run(profile.Name, func(t *testing.T) {
+			testWalker(t, profile)
+		})
+	}
+}
+
+func testWalker(t *testing.T, profile *storage.ChunkerProfile) {
+	sizes := []int{1, 1023, 1024, 1025, 4095, 4096, 4097, 123456}
 	for i := range sizes {
-		p, f, closeFunc := getPinApiAndFileStore(t)
+		p, f, closeFunc := getPinApiAndFileStoreWithProfile(t, profile.Name)
 		defer closeFunc()
 
 		data := testutil.RandomBytes(1, sizes[i])
@@ -216,6 +224,14 @@ func TestListPinInfo(t *testing.T) {
 func getPinApiAndFileStore(t *testing.T) (*API, *storage.FileStore, func()) {
 	t.Helper()
 
+	return getPinApiAndFileStoreWithProfile(t, storage.DefaultChunkerProfile.Name)
+}
+
+// getPinApiAndFileStoreWithProfile returns a file store which splits the
+// content with the chunker profile of the given name
+func getPinApiAndFileStoreWithProfile(t *testing.T, profile string) (*API, *storage.FileStore, func()) {
+	t.Helper()
+
 	swarmDir, err := ioutil.TempDir("", "swarm-storage-test")
 	if err != nil {
 		t.Fatalf("could not create temp dir. Error: %s", err.Error())
@@ -231,7 +247,9 @@ func getPinApiAndFileStore(t *testing.T) (*API, *storage.FileStore, func()) {
 		t.Fatalf("could not create localstore. Error: %s", err.Error())
 	}
 	tags := chunk.NewTags()
-	fileStore := storage.NewFileStore(lStore, lStore, storage.NewFileStoreParams(), tags)
+	fileStoreParams := storage.NewFileStoreParams()
+	fileStoreParams.ChunkerProfile = profile
+	fileStore := storage.NewFileStore(lStore, lStore, fileStoreParams, tags)
 
 	// Swarm feeds test setup
 	feedsDir, err := ioutil.TempDir("", "swarm-feeds-test")
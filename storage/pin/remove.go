@@ -0,0 +1,172 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+)
+
+// RemoveStats are the counts of the chunks of content walked when removing it from the local store
+type RemoveStats struct {
+	Removed       int `json:"removed"`       // chunks removed from the local store
+	Pinned        int `json:"pinned"`        // chunks kept because they are pinned
+	Shared        int `json:"shared"`        // chunks kept because they also belong to the upload of another tag
+	Neighbourhood int `json:"neighbourhood"` // chunks kept because they are within the neighbourhood depth of the node
+	Unreadable    int `json:"unreadable"`    // uploads of other tags that could not be walked, their chunks are not kept
+}
+
+// RemoveFiles removes the chunks of a RAW file or a collection from the local store,
+// so that the space taken by a mistaken upload can be reclaimed. It takes the root
+// hash as the argument and walks down the merkle tree like PinFiles. Chunks that are
+// pinned, or that also belong to the upload of another tag of the node, are kept so that
+// the other content stays complete. Chunks within the neighbourhood depth of the node are
+// kept as well, as the node stores them for the network. Only the local store is affected,
+// the chunks already synced to other nodes remain in the network.
+func (p *API) RemoveFiles(addr []byte, isRaw bool, credentials string) (RemoveStats, error) {
+	var stats RemoveStats
+	ctx := context.Background()
+	hasChunk, err := p.db.Has(ctx, chunk.Address(p.removeDecryptionKeyFromChunkHash(addr)))
+	if err != nil {
+		return stats, err
+	}
+	if !hasChunk {
+		return stats, fmt.Errorf("root hash %s not found in the local store", hex.EncodeToString(addr))
+	}
+
+	chunks, err := p.collectChunks(addr, isRaw, credentials)
+	if err != nil {
+		return stats, err
+	}
+	shared, unreadable, err := p.taggedChunks(addr)
+	if err != nil {
+		return stats, err
+	}
+	stats.Unreadable = unreadable
+
+	for key := range chunks {
+		chunkAddr := chunk.Address(key)
+		_, err := p.getPinCounterOfChunk(chunkAddr)
+		if err == nil {
+			stats.Pinned++
+			continue
+		}
+		if err != chunk.ErrChunkNotFound {
+			return stats, err
+		}
+		if _, ok := shared[key]; ok {
+			stats.Shared++
+			continue
+		}
+		if p.withinDepth != nil && p.withinDepth(chunkAddr) {
+			stats.Neighbourhood++
+			continue
+		}
+		if err := p.db.Set(ctx, chunk.ModeSetRemove, chunkAddr); err != nil {
+			log.Error("Could not remove chunk", "Address", hex.EncodeToString(chunkAddr), "err", err)
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	log.Debug("Files removed", "Address", hex.EncodeToString(addr), "removed", stats.Removed, "pinned", stats.Pinned, "shared", stats.Shared, "neighbourhood", stats.Neighbourhood, "unreadable", stats.Unreadable)
+	return stats, nil
+}
+
+// RemoveTag removes the chunks of the upload of the tag from the local store
+// with RemoveFiles, once the upload is split and the root hash is set on the tag
+func (p *API) RemoveTag(uid uint32, isRaw bool, credentials string) (RemoveStats, error) {
+	tag, err := p.tag.Get(uid)
+	if err != nil {
+		return RemoveStats{}, err
+	}
+	if len(tag.Address) == 0 {
+		return RemoveStats{}, fmt.Errorf("tag %d has no root hash", uid)
+	}
+	return p.RemoveFiles(tag.Address, isRaw, credentials)
+}
+
+// collectChunks returns the set of the addresses of the chunks of the content with the root hash
+func (p *API) collectChunks(addr []byte, isRaw bool, credentials string) (map[string]struct{}, error) {
+	chunks := make(map[string]struct{})
+	var mu sync.Mutex
+	err := p.walkChunksFromRootHash(addr, isRaw, credentials, func(ref storage.Reference) error {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks[string(p.removeDecryptionKeyFromChunkHash(ref))] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// taggedChunks returns the set of the addresses of the chunks of the uploads of all tags
+// except those with the root hash, and the number of uploads that could not be walked.
+// Uploads whose root chunk is not in the local store anymore are skipped, as they are
+// not complete locally in any case.
+// Whether an upload is a RAW file or a collection is not recorded with the tag, so it is
+// walked as a collection if its root is a manifest. Collections with access controlled
+// entries cannot be walked without the credentials of their publisher, they are skipped
+// along with the uploads that fail to be walked.
+func (p *API) taggedChunks(addr []byte) (map[string]struct{}, int, error) {
+	ctx := context.Background()
+	chunks := make(map[string]struct{})
+	var unreadable int
+	for _, tag := range p.tag.All() {
+		if len(tag.Address) == 0 || bytes.Equal(tag.Address, addr) {
+			continue
+		}
+		hasChunk, err := p.db.Has(ctx, chunk.Address(p.removeDecryptionKeyFromChunkHash(tag.Address)))
+		if err != nil {
+			return nil, 0, err
+		}
+		if !hasChunk {
+			continue
+		}
+		var protected bool
+		_, err = p.api.NewManifestWalker(ctx, storage.Address(tag.Address), func(*api.ManifestEntry) error {
+			protected = true
+			return api.ErrDecrypt
+		}, nil)
+		if protected {
+			log.Warn("Skipping access controlled upload", "tag", tag.Uid, "Address", hex.EncodeToString(tag.Address))
+			unreadable++
+			continue
+		}
+		isRaw := err != nil
+		tagged, err := p.collectChunks(tag.Address, isRaw, "")
+		if err != nil {
+			log.Warn("Skipping upload that cannot be walked", "tag", tag.Uid, "Address", hex.EncodeToString(tag.Address), "err", err)
+			unreadable++
+			continue
+		}
+		for key := range tagged {
+			chunks[key] = struct{}{}
+		}
+	}
+	return chunks, unreadable, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ethersphere/swarm/api"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestRemoveFiles tests that the chunks of a collection are removed from the local store,
+// except those that are pinned or belong to the upload of another tag
+func TestRemoveFiles(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+	ctx := context.Background()
+
+	hash := uploadCollection(t, p, f, false)
+	chunks, err := p.collectChunks(hash, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the content of the second file of the collection is pinned
+	pinned := uploadFile(t, f, testutil.RandomBytes(2, 10000), false)
+	if err := p.PinFiles(pinned, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	// the content of the first file of the collection is also uploaded with another tag
+	shared := uploadFile(t, f, testutil.RandomBytes(1, 10000), false)
+	tag, err := p.tag.Create("shared", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.DoneSplit(shared)
+	// unrelated content is not touched
+	other := uploadFile(t, f, testutil.RandomBytes(20, 10000), false)
+
+	stats, err := p.RemoveFiles(hash, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a file of 10000 bytes has three data chunks and a root chunk
+	if stats.Pinned != 4 {
+		t.Errorf("got %v pinned chunks, want 4", stats.Pinned)
+	}
+	if stats.Shared != 4 {
+		t.Errorf("got %v shared chunks, want 4", stats.Shared)
+	}
+	if stats.Removed+stats.Pinned+stats.Shared != len(chunks) {
+		t.Errorf("got %v removed chunks, want %v", stats.Removed, len(chunks)-stats.Pinned-stats.Shared)
+	}
+
+	for _, tc := range []struct {
+		name string
+		addr []byte
+		want bool
+	}{
+		{name: "collection", addr: hash, want: false},
+		{name: "pinned", addr: pinned, want: true},
+		{name: "shared", addr: shared, want: true},
+		{name: "other", addr: other, want: true},
+	} {
+		has, err := p.db.Has(ctx, chunk.Address(tc.addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != tc.want {
+			t.Errorf("%s: got root chunk in local store %v, want %v", tc.name, has, tc.want)
+		}
+	}
+	if _, err := p.RemoveFiles(hash, false, ""); err == nil {
+		t.Error("expected error removing content not in the local store")
+	}
+
+	// the content of the tag is not shared with other uploads anymore
+	stats, err = p.RemoveTag(tag.Uid, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Removed != 4 || stats.Pinned != 0 || stats.Shared != 0 {
+		t.Errorf("got stats %+v removing tag, want 4 removed chunks", stats)
+	}
+	has, err := p.db.Has(ctx, chunk.Address(shared))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has {
+		t.Error("root chunk of tag upload still in local store")
+	}
+}
+
+// TestRemoveFilesNeighbourhood tests that the chunks within the neighbourhood depth of the node are kept
+func TestRemoveFilesNeighbourhood(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+	ctx := context.Background()
+
+	hash := uploadFile(t, f, testutil.RandomBytes(1, 100000), false)
+	chunks, err := p.collectChunks(hash, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the chunks in one half of the address space are within depth
+	within := func(addr []byte) bool {
+		return addr[0]&0x80 != 0
+	}
+	p.SetWithinDepth(within)
+	var want int
+	for key := range chunks {
+		if within([]byte(key)) {
+			want++
+		}
+	}
+	if want == 0 || want == len(chunks) {
+		t.Fatalf("got %v of %v chunks within depth, want some", want, len(chunks))
+	}
+
+	stats, err := p.RemoveFiles(hash, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Neighbourhood != want || stats.Removed != len(chunks)-want {
+		t.Errorf("got stats %+v, want %v chunks within depth and %v removed", stats, want, len(chunks)-want)
+	}
+	for key := range chunks {
+		has, err := p.db.Has(ctx, chunk.Address(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != within([]byte(key)) {
+			t.Errorf("chunk %x: got in local store %v, want %v", key, has, !has)
+		}
+	}
+}
+
+// TestRemoveFilesUnreadable tests that uploads of other tags that cannot be walked
+// are recorded instead of failing the removal
+func TestRemoveFilesUnreadable(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+
+	// a collection with an access controlled entry
+	access, err := api.NewAccessEntryPassword(make([]byte, 32), api.DefaultKdfParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := uploadFile(t, f, testutil.RandomBytes(1, 10000), false)
+	manifest, err := json.Marshal(&api.Manifest{Entries: []api.ManifestEntry{
+		{Hash: protected.Hex(), ContentType: api.ManifestType, Access: access},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := uploadFile(t, f, manifest, false)
+	tag, err := p.tag.Create("protected", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.DoneSplit(root)
+
+	hash := uploadFile(t, f, testutil.RandomBytes(2, 10000), false)
+	stats, err := p.RemoveFiles(hash, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unreadable != 1 || stats.Removed != 4 {
+		t.Errorf("got stats %+v, want 1 unreadable upload and 4 removed chunks", stats)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/log"
+	"github.com/ethersphere/swarm/storage"
+)
+
+var (
+	repairRoundCount    = metrics.NewRegisteredCounter("pin/repair/round", nil)
+	repairRootFailCount = metrics.NewRegisteredCounter("pin/repair/root/fail", nil)
+	repairProbedCount   = metrics.NewRegisteredCounter("pin/repair/probed", nil)
+	repairMissingCount  = metrics.NewRegisteredCounter("pin/repair/missing", nil)
+	repairRepushedCount = metrics.NewRegisteredCounter("pin/repair/repushed", nil)
+)
+
+// ProbeFunc reports whether a chunk can be retrieved from the network
+type ProbeFunc func(ctx context.Context, addr storage.Address) (bool, error)
+
+// RepairParams are the scheduling knobs of the repair job
+type RepairParams struct {
+	Interval     time.Duration // time between repair rounds, 0 disables the job
+	SampleSize   int           // number of chunks of each root probed in a round
+	ProbeTimeout time.Duration // time to wait for a probed chunk to be delivered
+}
+
+// NewRepairParams returns the default repair job parameters
+func NewRepairParams() *RepairParams {
+	return &RepairParams{
+		Interval:     6 * time.Hour,
+		SampleSize:   16,
+		ProbeTimeout: 10 * time.Second,
+	}
+}
+
+// repairRoot is the root hash of content whose chunks are checked by the repair job
+type repairRoot struct {
+	addr  storage.Address
+	isRaw bool
+	guess bool // whether the content is a manifest is not known
+}
+
+// Repairer is the maintenance job that periodically samples chunks of the
+// pinned and uploaded content of the node, checks whether they can be
+// retrieved from their neighbourhood and re-pushes the ones that cannot
+// Without it, content uploaded by a node that was offline for long decays silently
+type Repairer struct {
+	api    *API
+	prober *storage.Prober
+	params *RepairParams
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRepairer creates the repair job of the content known to the pinning API
+func NewRepairer(p *API, probe ProbeFunc, params *RepairParams) *Repairer {
+	return &Repairer{
+		api:    p,
+		prober: storage.NewProber(storage.ProbeFunc(probe), 1, params.ProbeTimeout),
+		params: params,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start starts the repair rounds in the background
+func (r *Repairer) Start() {
+	if r.params.Interval <= 0 {
+		return
+	}
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Stop stops the repair job and waits for the current round to be interrupted
+func (r *Repairer) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+func (r *Repairer) run() {
+	defer r.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-r.quit
+		cancel()
+	}()
+
+	ticker := time.NewTicker(r.params.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Repair(ctx); err != nil {
+				log.Debug("pin repair round interrupted", "err", err)
+			}
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// Repair runs a repair round: it probes a sample of the chunks of every root
+// and re-pushes the probed chunks that no peer delivered
+// It returns an error only if the context is done before the round completes
+func (r *Repairer) Repair(ctx context.Context) error {
+	repairRoundCount.Inc(1)
+
+	roots, err := r.roots()
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		// content that is not stored locally anymore cannot be re-pushed
+		has, err := r.api.db.Has(ctx, chunk.Address(r.api.removeDecryptionKeyFromChunkHash(root.addr)))
+		if err != nil || !has {
+			repairRootFailCount.Inc(1)
+			log.Debug("pin repair: root not stored", "root", root.addr, "err", err)
+			continue
+		}
+		addrs, err := r.sample(root)
+		if err != nil {
+			repairRootFailCount.Inc(1)
+			log.Debug("pin repair: could not walk root", "root", root.addr, "err", err)
+			continue
+		}
+
+		repairProbedCount.Inc(int64(len(addrs)))
+		refs := make([]storage.Address, len(addrs))
+		for i, addr := range addrs {
+			refs[i] = storage.Address(addr)
+		}
+		report, err := r.prober.Probe(ctx, refs...)
+		if err != nil {
+			return err
+		}
+		if report.Failed > 0 {
+			log.Debug("pin repair: probes failed", "root", root.addr, "count", report.Failed)
+		}
+		var missing []chunk.Address
+		for _, ref := range report.MissingRefs() {
+			missing = append(missing, chunk.Address(ref))
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		repairMissingCount.Inc(int64(len(missing)))
+		if err := r.api.db.Reupload(ctx, missing...); err != nil {
+			log.Warn("pin repair: could not re-push missing chunks", "root", root.addr, "count", len(missing), "err", err)
+			continue
+		}
+		repairRepushedCount.Inc(int64(len(missing)))
+		log.Info("pin repair: re-pushed chunks missing from their neighbourhood", "root", root.addr, "probed", len(addrs), "missing", len(missing), "latency", report.MeanLatency)
+	}
+	return nil
+}
+
+// roots returns the root hashes of the pinned content and of the content
+// uploaded by the node, the latter being known from the upload tags
+func (r *Repairer) roots() (roots []repairRoot, err error) {
+	pins, err := r.api.ListPins()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range pins {
+		seen[p.Address.Hex()] = true
+		roots = append(roots, repairRoot{addr: p.Address, isRaw: p.IsRaw})
+	}
+	if r.api.tag == nil {
+		return roots, nil
+	}
+	for _, t := range r.api.tag.All() {
+		if t.Anonymous || len(t.Address) == 0 || seen[t.Address.Hex()] {
+			continue
+		}
+		seen[t.Address.Hex()] = true
+		roots = append(roots, repairRoot{addr: storage.Address(t.Address), guess: true})
+	}
+	return roots, nil
+}
+
+// sample walks the chunks of the root and returns at most SampleSize
+// of their addresses picked at random
+func (r *Repairer) sample(root repairRoot) ([]chunk.Address, error) {
+	var mu sync.Mutex
+	var addrs []chunk.Address
+	seen := 0
+	collect := func(ref storage.Reference) error {
+		addr := chunk.Address(r.api.removeDecryptionKeyFromChunkHash(ref))
+
+		mu.Lock()
+		defer mu.Unlock()
+		// reservoir sampling, so that the walk does not need to keep all addresses
+		seen++
+		if len(addrs) < r.params.SampleSize {
+			addrs = append(addrs, addr)
+		} else if i := rand.Intn(seen); i < len(addrs) {
+			addrs[i] = addr
+		}
+		return nil
+	}
+
+	err := r.api.walkChunksFromRootHash(root.addr, root.isRaw, "", collect)
+	if (err != nil || seen == 0) && root.guess {
+		// uploads of single files are not wrapped in a manifest
+		addrs, seen = nil, 0
+		err = r.api.walkChunksFromRootHash(root.addr, true, "", collect)
+	}
+	return addrs, err
+}
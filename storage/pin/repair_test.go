@@ -0,0 +1,109 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package pin
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethersphere/swarm/chunk"
+	"github.com/ethersphere/swarm/storage"
+	"github.com/ethersphere/swarm/testutil"
+)
+
+// TestRepair tests that a repair round probes the chunks of pinned and uploaded
+// content and puts only the chunks reported missing back to the push index
+func TestRepair(t *testing.T) {
+	p, f, closeFunc := getPinApiAndFileStore(t)
+	defer closeFunc()
+
+	pinned := uploadFile(t, f, testutil.RandomBytes(1, 10000), false)
+	if err := p.PinFiles(pinned, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	uploaded := uploadFile(t, f, testutil.RandomBytes(2, 10000), false)
+	tag, err := p.tag.Create("upload", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.DoneSplit(uploaded)
+
+	params := NewRepairParams()
+	params.SampleSize = 100
+	params.ProbeTimeout = time.Second
+
+	var mu sync.Mutex
+	probed := make(map[string]bool)
+	missing := make(map[string]bool)
+	probe := func(ctx context.Context, addr storage.Address) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		probed[addr.Hex()] = true
+		return !missing[addr.Hex()], nil
+	}
+	r := NewRepairer(p, probe, params)
+
+	// the chunks of both files are synced, and one chunk of each is missing
+	var all []chunk.Address
+	for _, root := range []storage.Address{pinned, uploaded} {
+		addrs, err := r.sample(repairRoot{addr: root, guess: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 4 {
+			t.Fatalf("expected 4 chunks of root %s, got %d", root, len(addrs))
+		}
+		missing[addrs[0].Hex()] = true
+		all = append(all, addrs...)
+	}
+	if err := p.db.Set(context.Background(), chunk.ModeSetSyncPush, all...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Repair(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(probed) != len(all) {
+		t.Fatalf("expected %d chunks probed, got %d", len(all), len(probed))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, stop := p.db.SubscribePush(ctx)
+	defer stop()
+	repushed := make(map[string]bool)
+	for len(repushed) < len(missing) {
+		select {
+		case ch := <-c:
+			repushed[ch.Address().Hex()] = true
+		case <-ctx.Done():
+			t.Fatalf("expected %d chunks re-pushed, got %d", len(missing), len(repushed))
+		}
+	}
+	for addr := range missing {
+		if !repushed[addr] {
+			t.Fatalf("missing chunk %s not re-pushed", addr)
+		}
+	}
+	select {
+	case ch := <-c:
+		t.Fatalf("unexpected chunk %s re-pushed", ch.Address())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	probeLatencyTimer = metrics.NewRegisteredResettingTimer("storage/prober/latency", nil)
+	probeMissingCount = metrics.NewRegisteredCounter("storage/prober/missing", nil)
+)
+
+// ProbeFunc reports whether a chunk can be retrieved from the network,
+// NetStore.Probe is the one used by the node
+type ProbeFunc func(ctx context.Context, ref Address) (bool, error)
+
+// ProbeResult is the outcome of probing a single chunk
+type ProbeResult struct {
+	Ref       Address       `json:"ref"`
+	Retrieved bool          `json:"retrieved"`
+	Latency   time.Duration `json:"latency"`         // time until delivery, or until the probe gave up
+	Err       string        `json:"error,omitempty"` // set if the probe could not be completed
+}
+
+// ProbeReport summarises the retrievability of a list of chunks
+type ProbeReport struct {
+	Results     []ProbeResult `json:"results"` // in the order of the probed references
+	Retrieved   int           `json:"retrieved"`
+	Missing     int           `json:"missing"`
+	Failed      int           `json:"failed"`
+	MeanLatency time.Duration `json:"meanLatency"` // of the retrieved chunks
+	MaxLatency  time.Duration `json:"maxLatency"`  // of the retrieved chunks
+}
+
+// MissingRefs returns the references of the chunks that no peer delivered
+func (r *ProbeReport) MissingRefs() (refs []Address) {
+	for _, res := range r.Results {
+		if !res.Retrieved && res.Err == "" {
+			refs = append(refs, res.Ref)
+		}
+	}
+	return refs
+}
+
+// Prober measures the retrievability and retrieval latency of chunks from
+// the network without looking them up in the local store. It is used for
+// smoke testing deployments and by the repair job to find missing chunks.
+type Prober struct {
+	probe       ProbeFunc
+	concurrency int           // number of chunks probed at the same time
+	timeout     time.Duration // time to wait for a chunk to be delivered
+}
+
+// NewProber creates a Prober that probes at most concurrency chunks at the
+// same time, each waiting for at most timeout
+func NewProber(probe ProbeFunc, concurrency int, timeout time.Duration) *Prober {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Prober{
+		probe:       probe,
+		concurrency: concurrency,
+		timeout:     timeout,
+	}
+}
+
+// Probe probes the chunks with the given references and returns the report
+// It returns an error only if the context is done before all chunks are probed
+func (p *Prober) Probe(ctx context.Context, refs ...Address) (*ProbeReport, error) {
+	report := &ProbeReport{
+		Results: make([]ProbeResult, len(refs)),
+	}
+
+	sem := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int, ref Address) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			report.Results[i] = p.probeChunk(ctx, ref)
+		}(i, ref)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var total time.Duration
+	for _, res := range report.Results {
+		switch {
+		case res.Err != "":
+			report.Failed++
+		case res.Retrieved:
+			report.Retrieved++
+			total += res.Latency
+			if res.Latency > report.MaxLatency {
+				report.MaxLatency = res.Latency
+			}
+		default:
+			report.Missing++
+		}
+	}
+	if report.Retrieved > 0 {
+		report.MeanLatency = total / time.Duration(report.Retrieved)
+	}
+	return report, nil
+}
+
+func (p *Prober) probeChunk(ctx context.Context, ref Address) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	ok, err := p.probe(ctx, ref)
+	res := ProbeResult{
+		Ref:       ref,
+		Retrieved: ok,
+		Latency:   time.Since(start),
+	}
+	switch {
+	case err != nil:
+		res.Err = err.Error()
+	case ok:
+		probeLatencyTimer.Update(res.Latency)
+	default:
+		probeMissingCount.Inc(1)
+	}
+	return res
+}
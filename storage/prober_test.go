@@ -0,0 +1,73 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestProber tests that the report of a Prober tells apart retrieved,
+// missing and failed chunks and measures the latency of retrieved ones
+func TestProber(t *testing.T) {
+	refs := []Address{{1}, {2}, {3}, {4}}
+	probe := func(ctx context.Context, ref Address) (bool, error) {
+		switch ref[0] {
+		case 1:
+			time.Sleep(10 * time.Millisecond)
+			return true, nil
+		case 2:
+			return true, nil
+		case 3:
+			<-ctx.Done()
+			return false, nil
+		}
+		return false, errors.New("probe error")
+	}
+
+	report, err := NewProber(probe, 2, 50*time.Millisecond).Probe(context.Background(), refs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Retrieved != 2 || report.Missing != 1 || report.Failed != 1 {
+		t.Fatalf("expected 2 retrieved, 1 missing and 1 failed, got %+v", report)
+	}
+	for i, res := range report.Results {
+		if !bytes.Equal(res.Ref, refs[i]) {
+			t.Fatalf("result %d: expected ref %v, got %v", i, refs[i], res.Ref)
+		}
+	}
+	if report.Results[3].Err == "" {
+		t.Fatal("expected error of failed probe to be reported")
+	}
+	if report.MaxLatency < 10*time.Millisecond || report.MeanLatency > report.MaxLatency {
+		t.Fatalf("unexpected latencies mean %v max %v", report.MeanLatency, report.MaxLatency)
+	}
+	missing := report.MissingRefs()
+	if len(missing) != 1 || !bytes.Equal(missing[0], refs[2]) {
+		t.Fatalf("expected missing refs %v, got %v", refs[2:3], missing)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewProber(probe, 1, time.Second).Probe(ctx, refs...); err != context.Canceled {
+		t.Fatalf("expected context cancelled error, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+const (
+	// spanProfileShift is the bit position of the profile byte in the span
+	// the span of a chunk is a little endian uint64 and sizes never reach the most
+	// significant byte, so it is used to record the profile the chunk tree was split with
+	spanProfileShift = 56
+	// spanSizeMask masks the size of the subtree in the span
+	spanSizeMask = 1<<spanProfileShift - 1
+	// spanProfileVersion is the version of the format of the profile byte, given by
+	// its upper four bits, the lower four bits are the id of the profile
+	// A zero profile byte is the unversioned span of content split with the default
+	// profile, so that its chunks keep the addresses they had before profiles
+	spanProfileVersion = 1
+	// maxChunkerProfileID is the largest profile id the profile byte records
+	maxChunkerProfileID = 0x0f
+)
+
+// ChunkerProfile is the chunk size content is split into
+// The branching factor of the intermediate chunks of the tree is the chunk size
+// divided by the reference size, e.g. 128 for the default profile and unencrypted content
+// Chunk sizes are capped to chunk.DefaultSize, the largest chunk that the
+// BMT hasher and the content address validators of the network accept
+type ChunkerProfile struct {
+	ID        uint8  // recorded in the span of every chunk of the tree, at most maxChunkerProfileID
+	Name      string // name used in the configuration
+	ChunkSize int64  // maximum size of the data of a chunk
+}
+
+var (
+	// DefaultChunkerProfile splits content into 4096 byte chunks
+	DefaultChunkerProfile = &ChunkerProfile{ID: 0, Name: "default", ChunkSize: chunk.DefaultSize}
+	// SmallChunkerProfile splits content into 1024 byte chunks
+	// Small objects and small reads of large documents retrieve less data,
+	// at the cost of deeper trees with more intermediate chunks
+	SmallChunkerProfile = &ChunkerProfile{ID: 1, Name: "small", ChunkSize: 1024}
+
+	chunkerProfiles = []*ChunkerProfile{
+		DefaultChunkerProfile,
+		SmallChunkerProfile,
+	}
+)
+
+// GetChunkerProfile returns the chunker profile with the given id
+func GetChunkerProfile(id uint8) (*ChunkerProfile, error) {
+	for _, p := range chunkerProfiles {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown chunker profile id %d", id)
+}
+
+// ChunkerProfileByName returns the chunker profile with the given name
+func ChunkerProfileByName(name string) (*ChunkerProfile, error) {
+	for _, p := range chunkerProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown chunker profile %q", name)
+}
+
+// span returns the span of a chunk covering size bytes of content split with the profile
+func (p *ChunkerProfile) span(size uint64) uint64 {
+	if p.ID == DefaultChunkerProfile.ID {
+		return size
+	}
+	return size | uint64(spanProfileVersion<<4|p.ID)<<spanProfileShift
+}
+
+// spanProfile returns the chunker profile recorded in the profile byte of a span
+func spanProfile(b uint8) (*ChunkerProfile, error) {
+	if b == 0 {
+		return DefaultChunkerProfile, nil
+	}
+	if v := b >> 4; v != spanProfileVersion {
+		return nil, fmt.Errorf("unknown span format version %d", v)
+	}
+	return GetChunkerProfile(b & maxChunkerProfileID)
+}
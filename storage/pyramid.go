@@ -73,22 +73,24 @@ const (
 
 type PyramidSplitterParams struct {
 	SplitterParams
-	getter Getter
+	getter  Getter
+	profile *ChunkerProfile
 }
 
-func NewPyramidSplitterParams(addr Address, reader io.Reader, putter Putter, getter Getter, chunkSize int64) *PyramidSplitterParams {
+func NewPyramidSplitterParams(addr Address, reader io.Reader, putter Putter, getter Getter, profile *ChunkerProfile) *PyramidSplitterParams {
 	hashSize := putter.RefSize()
 	return &PyramidSplitterParams{
 		SplitterParams: SplitterParams{
 			ChunkerParams: ChunkerParams{
-				chunkSize: chunkSize,
+				chunkSize: profile.ChunkSize,
 				hashSize:  hashSize,
 			},
 			reader: reader,
 			putter: putter,
 			addr:   addr,
 		},
-		getter: getter,
+		getter:  getter,
+		profile: profile,
 	}
 }
 
@@ -97,11 +99,27 @@ func NewPyramidSplitterParams(addr Address, reader io.Reader, putter Putter, get
 	New chunks to store are store using the putter which the caller provides.
 */
 func PyramidSplit(ctx context.Context, reader io.Reader, putter Putter, getter Getter, tag *chunk.Tag) (Address, func(context.Context) error, error) {
-	return NewPyramidSplitter(NewPyramidSplitterParams(nil, reader, putter, getter, chunk.DefaultSize), tag).Split(ctx)
+	return PyramidSplitProfile(ctx, reader, putter, getter, tag, DefaultChunkerProfile)
 }
 
+// PyramidSplitProfile splits the data into chunks of the size given by the chunker profile
+// The profile is recorded in the chunks, so that joiners pick it up from the root chunk
+func PyramidSplitProfile(ctx context.Context, reader io.Reader, putter Putter, getter Getter, tag *chunk.Tag, profile *ChunkerProfile) (Address, func(context.Context) error, error) {
+	return NewPyramidSplitter(NewPyramidSplitterParams(nil, reader, putter, getter, profile), tag).Split(ctx)
+}
+
+// PyramidAppend appends the data to the content with the given root address
+// using the chunker profile the content was split with
 func PyramidAppend(ctx context.Context, addr Address, reader io.Reader, putter Putter, getter Getter, tag *chunk.Tag) (Address, func(context.Context) error, error) {
-	return NewPyramidSplitter(NewPyramidSplitterParams(addr, reader, putter, getter, chunk.DefaultSize), tag).Append(ctx)
+	root, err := getter.Get(ctx, Reference(addr))
+	if err != nil {
+		return nil, nil, errLoadingTreeRootChunk
+	}
+	profile, err := root.Profile()
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewPyramidSplitter(NewPyramidSplitterParams(addr, reader, putter, getter, profile), tag).Append(ctx)
 }
 
 // Entry to create a tree node
@@ -135,6 +153,7 @@ type chunkJob struct {
 }
 
 type PyramidChunker struct {
+	profile     *ChunkerProfile
 	chunkSize   int64
 	hashSize    int64
 	branches    int64
@@ -156,6 +175,7 @@ type PyramidChunker struct {
 
 func NewPyramidSplitter(params *PyramidSplitterParams, tag *chunk.Tag) (pc *PyramidChunker) {
 	pc = &PyramidChunker{}
+	pc.profile = params.profile
 	pc.reader = params.reader
 	pc.hashSize = params.hashSize
 	pc.branches = params.chunkSize / pc.hashSize
@@ -649,7 +669,7 @@ func (pc *PyramidChunker) enqueueTreeChunk(ent *TreeEntry, chunkWG *sync.WaitGro
 			chunkWG.Wait()
 		}
 
-		binary.LittleEndian.PutUint64(ent.chunk[:8], ent.subtreeSize)
+		binary.LittleEndian.PutUint64(ent.chunk[:8], pc.profile.span(ent.subtreeSize))
 		ent.key = make([]byte, pc.hashSize)
 		chunkWG.Add(1)
 		select {
@@ -669,7 +689,7 @@ func (pc *PyramidChunker) enqueueTreeChunk(ent *TreeEntry, chunkWG *sync.WaitGro
 }
 
 func (pc *PyramidChunker) enqueueDataChunk(chunkData []byte, size uint64, parent *TreeEntry, chunkWG *sync.WaitGroup) Address {
-	binary.LittleEndian.PutUint64(chunkData[:8], size)
+	binary.LittleEndian.PutUint64(chunkData[:8], pc.profile.span(size))
 	pkey := parent.chunk[8+parent.branchCount*pc.hashSize : 8+(parent.branchCount+1)*pc.hashSize]
 
 	chunkWG.Add(1)
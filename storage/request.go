@@ -32,6 +32,7 @@ type Request struct {
 	Addr        Address  // chunk address
 	Origin      enode.ID // who is sending us that request? we compare Origin to the suggested peer from RequestFromPeers
 	PeersToSkip sync.Map // peers not to request chunk from
+	HopCount    uint8    // hops left of the received retrieve request, 0 if the request originates at this node
 }
 
 // NewRequest returns a new instance of Request based on chunk address skip check and
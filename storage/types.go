@@ -176,7 +176,12 @@ type Getter interface {
 
 // NOTE: this returns invalid data if chunk is encrypted
 func (c ChunkData) Size() uint64 {
-	return binary.LittleEndian.Uint64(c[:8])
+	return binary.LittleEndian.Uint64(c[:8]) & spanSizeMask
+}
+
+// Profile returns the chunker profile recorded in the span
+func (c ChunkData) Profile() (*ChunkerProfile, error) {
+	return spanProfile(uint8(binary.LittleEndian.Uint64(c[:8]) >> spanProfileShift))
 }
 
 type ChunkValidator = chunk.Validator
@@ -0,0 +1,165 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethersphere/swarm/chunk"
+)
+
+// verifyConcurrency is the number of chunks retrieved in parallel by VerifyTree
+const verifyConcurrency = 8
+
+// ErrChunkCorrupt is reported for chunks whose content does not hash to their address
+var ErrChunkCorrupt = errors.New("chunk content does not match its address")
+
+// VerifyTree retrieves every chunk of the tree with the given root reference and
+// checks that its content hashes to its address. It calls fail for each chunk
+// that is missing, corrupt or malformed, whose subtree is then not walked, and
+// returns the number of chunks verified. An error is returned only if the
+// context is done before the whole tree is walked.
+func (f *FileStore) VerifyTree(ctx context.Context, ref Reference, fail func(ref Reference, err error)) (int, error) {
+	v := f.newTreeVerifier(ref, fail)
+	v.wg.Add(1)
+	go v.verify(ctx, ref, -1)
+	v.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return v.count, nil
+}
+
+// TreeExists reports whether the chunks of the tree with the given root reference
+// are retrievable down to depth levels below the root, the whole tree if depth is
+// negative, so only the root chunk is retrieved if depth is zero. The walk stops at
+// the first chunk that is missing or corrupt. An error is returned only if the
+// context is done before the answer is known.
+func (f *FileStore) TreeExists(ctx context.Context, ref Reference, depth int) (bool, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var missing bool
+	v := f.newTreeVerifier(ref, func(Reference, error) {
+		missing = true
+		cancel()
+	})
+	v.wg.Add(1)
+	go v.verify(wctx, ref, depth)
+	v.wg.Wait()
+	if missing {
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *FileStore) newTreeVerifier(ref Reference, fail func(ref Reference, err error)) *treeVerifier {
+	isEncrypted := len(ref) > f.hashFunc().Size()
+	return &treeVerifier{
+		store:     NewHasherStore(f.ChunkStore, f.hashFunc, isEncrypted, nil),
+		validator: NewContentAddressValidator(f.hashFunc),
+		fail:      fail,
+		sem:       make(chan struct{}, verifyConcurrency),
+	}
+}
+
+// treeVerifier walks a chunk tree verifying its chunks
+type treeVerifier struct {
+	store     *hasherStore
+	validator *ContentAddressValidator
+	fail      func(ref Reference, err error)
+	sem       chan struct{} // limits the chunks retrieved in parallel
+	wg        sync.WaitGroup
+	mu        sync.Mutex // serialises fail calls and protects count
+	count     int
+}
+
+// verify verifies the chunk of the reference and its subtree down to depth
+// levels below it, the whole subtree if depth is negative
+func (v *treeVerifier) verify(ctx context.Context, ref Reference, depth int) {
+	defer v.wg.Done()
+
+	select {
+	case v.sem <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	data, err := v.get(ctx, ref)
+	<-v.sem
+	if ctx.Err() != nil {
+		return
+	}
+
+	v.mu.Lock()
+	if err != nil {
+		v.fail(ref, err)
+	} else {
+		v.count++
+	}
+	v.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	profile, err := data.Profile()
+	if err != nil {
+		v.mu.Lock()
+		v.fail(ref, err)
+		v.mu.Unlock()
+		return
+	}
+	// leaf chunks hold the content, the others the references of their children
+	if data.Size() <= uint64(profile.ChunkSize) || depth == 0 {
+		return
+	}
+	refs := data[8:]
+	if len(refs)%int(v.store.refSize) != 0 {
+		v.mu.Lock()
+		v.fail(ref, fmt.Errorf("invalid intermediate chunk of %d bytes", len(data)))
+		v.mu.Unlock()
+		return
+	}
+	for i := 0; i < len(refs); i += int(v.store.refSize) {
+		v.wg.Add(1)
+		go v.verify(ctx, Reference(refs[i:i+int(v.store.refSize)]), depth-1)
+	}
+}
+
+// get retrieves and validates the chunk of the reference and returns its decrypted data
+func (v *treeVerifier) get(ctx context.Context, ref Reference) (ChunkData, error) {
+	addr, key, err := parseReference(ref, v.store.hashSize)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := v.store.store.Get(ctx, chunk.ModeGetRequest, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !v.validator.Validate(ch) {
+		return nil, ErrChunkCorrupt
+	}
+	data := ChunkData(ch.Data())
+	if key != nil {
+		return v.store.decryptChunkData(data, key)
+	}
+	return data, nil
+}
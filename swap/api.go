@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
 	contract "github.com/ethersphere/swarm/contracts/swap"
@@ -30,6 +31,9 @@ type swapAPI interface {
 	Balances() (map[enode.ID]int64, error)
 	PeerCheques(peer enode.ID) (PeerCheques, error)
 	Cheques() (map[enode.ID]*PeerCheques, error)
+	PendingCashouts() (map[common.Address]*Cheque, error)
+	Transactions() (map[common.Hash]*TransactionStatus, error)
+	Transaction(hash common.Hash) (*TransactionStatus, error)
 }
 
 // API would be the API accessor for protocol methods
@@ -229,3 +233,22 @@ func (s *Swap) addStoreCheques(chequePrefix string, cheques map[enode.ID]*PeerCh
 	}
 	return s.store.Iterate(chequePrefix, chequesIterFunction)
 }
+
+// PendingCashouts returns the received cheques that were not cashed yet, by the chequebook they are drawn on
+// Only the last cheque of every chequebook is returned, as cashing it pays out the amounts of all previous ones
+// The cheques issued to a beneficiary other than the owner are cashed by the beneficiary, not by the node
+func (s *Swap) PendingCashouts() (map[common.Address]*Cheque, error) {
+	cheques := make(map[common.Address]*Cheque)
+	err := s.store.Iterate(pendingCashoutPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		var cheque Cheque
+		if err := json.Unmarshal(value, &cheque); err != nil {
+			return true, err
+		}
+		cheques[common.HexToAddress(string(key[len(pendingCashoutPrefix):]))] = &cheque
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cheques, nil
+}
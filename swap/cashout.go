@@ -33,9 +33,10 @@ const CashChequeBeneficiaryTransactionCost = 50000
 
 // CashoutProcessor holds all relevant fields needed for processing cashouts
 type CashoutProcessor struct {
-	backend    chain.Backend     // ethereum backend to use
-	privateKey *ecdsa.PrivateKey // private key to use
-	Logger     Logger
+	backend      chain.Backend       // ethereum backend to use
+	privateKey   *ecdsa.PrivateKey   // private key to use
+	transactions *transactionTracker // tracker of the cashout transactions until they are confirmed
+	Logger       Logger
 }
 
 // CashoutRequest represents a request for a cashout operation
@@ -57,11 +58,18 @@ func newCashoutProcessor(backend chain.Backend, privateKey *ecdsa.PrivateKey) *C
 	return &CashoutProcessor{
 		backend:    backend,
 		privateKey: privateKey,
+		transactions: &transactionTracker{
+			backend:       backend,
+			confirmations: 1,
+			logger:        newLogger(emptyLogPath, DefaultSwapLogLevel, nil),
+		},
 	}
 }
 
 // cashCheque tries to cash the cheque specified in the request
-// after the transaction is sent it waits on its success
+// after the transaction is sent it waits on its confirmation
+// if the transaction is dropped the cheque is validated against the chequebook again
+// and the transaction is sent again unless the cheque was already cashed
 func (c *CashoutProcessor) cashCheque(ctx context.Context, request *CashoutRequest) error {
 	cheque := request.Cheque
 	opts := bind.NewKeyedTransactor(c.privateKey)
@@ -72,17 +80,37 @@ func (c *CashoutProcessor) cashCheque(ctx context.Context, request *CashoutReque
 		return err
 	}
 
-	tx, err := otherSwap.CashChequeBeneficiaryStart(opts, request.Destination, cheque.CumulativePayout, cheque.Signature)
-	if err != nil {
-		return err
+	var dropped common.Hash
+	for attempt := 0; ; attempt++ {
+		tx, err := otherSwap.CashChequeBeneficiaryStart(opts, request.Destination, cheque.CumulativePayout, cheque.Signature)
+		if err != nil {
+			return err
+		}
+		if (dropped != common.Hash{}) {
+			c.transactions.replaced(dropped, tx.Hash())
+		}
+
+		// this blocks until the cashout has been successfully processed
+		err = c.waitForAndProcessActiveCashout(&ActiveCashout{
+			Request:         *request,
+			TransactionHash: tx.Hash(),
+			Logger:          request.Logger,
+		})
+		if err != chain.ErrTransactionDropped || attempt >= MaxTransactionResubmissions {
+			return err
+		}
+		dropped = tx.Hash()
+
+		expectedPayout, _, err := c.estimatePayout(ctx, &cheque)
+		if err != nil {
+			return err
+		}
+		if expectedPayout.Cmp(new(int256.Uint256)) == 0 {
+			request.Logger.Info(CashChequeAction, "cashout transaction dropped, cheque already cashed", "tx", dropped)
+			return nil
+		}
+		request.Logger.Warn(CashChequeAction, "cashout transaction dropped, sending it again", "tx", dropped)
 	}
-
-	// this blocks until the cashout has been successfully processed
-	return c.waitForAndProcessActiveCashout(&ActiveCashout{
-		Request:         *request,
-		TransactionHash: tx.Hash(),
-		Logger:          request.Logger,
-	})
 }
 
 // estimatePayout estimates the payout for a given cheque as well as the transaction cost
@@ -134,7 +162,7 @@ func (c *CashoutProcessor) waitForAndProcessActiveCashout(activeCashout *ActiveC
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultTransactionTimeout)
 	defer cancel()
 
-	receipt, err := chain.WaitMined(ctx, c.backend, activeCashout.TransactionHash)
+	receipt, err := c.transactions.wait(ctx, activeCashout.TransactionHash, CashChequeAction)
 	if err != nil {
 		return err
 	}
@@ -3,10 +3,12 @@ package chain
 import (
 	"context"
 	"errors"
+	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -15,13 +17,20 @@ import (
 var (
 	// ErrTransactionReverted is given when the transaction that cashes a cheque is reverted
 	ErrTransactionReverted = errors.New("Transaction reverted")
+	// ErrTransactionDropped is given when a transaction is neither included in the chain
+	// nor known to the backend anymore, it needs to be sent again
+	ErrTransactionDropped = errors.New("Transaction dropped")
 )
 
+// pollInterval is the time between checks of the state of a transaction
+var pollInterval = 1 * time.Second
+
 // Backend is the minimum amount of functionality required by the underlying ethereum backend
 type Backend interface {
 	bind.ContractBackend
 	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
 	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
 }
 
 // WaitMined waits until either the transaction with the given hash has been mined or the context is cancelled
@@ -44,7 +53,86 @@ func WaitMined(ctx context.Context, b Backend, hash common.Hash) (*types.Receipt
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-time.After(1 * time.Second):
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// WaitConfirmed waits until the transaction with the given hash has been mined and the block including it
+// has the given number of confirmations, the including block counting as the first one
+// If the including block is reorganised out of the chain, the confirmations are counted again from the
+// block including the transaction next. ErrTransactionDropped is returned if the transaction is neither
+// included nor known to the backend anymore.
+// The update function, if not nil, is called whenever the confirmations change, with a nil receipt once the
+// transaction is not included in the chain anymore
+func WaitConfirmed(ctx context.Context, b Backend, hash common.Hash, confirmations uint64, update func(receipt *types.Receipt, confirmations uint64)) (*types.Receipt, error) {
+	if update == nil {
+		update = func(*types.Receipt, uint64) {}
+	}
+	var included *types.Receipt
+	var lastConfirmations uint64
+	for {
+		receipt, confirmed, err := receiptConfirmations(ctx, b, hash)
+		if err != nil {
+			log.Error("confirmations retrieval failed", "tx", hash, "err", err)
+		} else if receipt != nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return nil, ErrTransactionReverted
+			}
+			if included == nil || included.BlockHash != receipt.BlockHash || confirmed != lastConfirmations {
+				included, lastConfirmations = receipt, confirmed
+				update(receipt, confirmed)
+			}
+			if confirmed >= confirmations {
+				return receipt, nil
+			}
+		} else {
+			if included != nil {
+				log.Warn("transaction reorganised out of the chain", "tx", hash, "block", included.BlockHash)
+				included, lastConfirmations = nil, 0
+				update(nil, 0)
+			}
+			if _, _, err := b.TransactionByHash(ctx, hash); err == ethereum.NotFound {
+				return nil, ErrTransactionDropped
+			}
 		}
+
+		log.Trace("transaction not yet confirmed", "tx", hash, "confirmations", lastConfirmations)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// receiptConfirmations returns the receipt of the transaction with the number of confirmations
+// of the including block, or a nil receipt if the transaction is not included in the canonical chain
+func receiptConfirmations(ctx context.Context, b Backend, hash common.Hash) (*types.Receipt, uint64, error) {
+	receipt, err := b.TransactionReceipt(ctx, hash)
+	if err == ethereum.NotFound {
+		return nil, 0, nil
+	}
+	if err != nil || receipt == nil {
+		return nil, 0, err
+	}
+	// the receipt may still refer to a block which is not canonical anymore
+	header, err := b.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err == ethereum.NotFound {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if header == nil || header.Hash() != receipt.BlockHash {
+		return nil, 0, nil
+	}
+	head, err := b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if head.Number.Cmp(receipt.BlockNumber) < 0 {
+		return receipt, 0, nil
 	}
+	return receipt, new(big.Int).Sub(head.Number, receipt.BlockNumber).Uint64() + 1, nil
 }
@@ -0,0 +1,214 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package chain
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// reorgBackend is a backend with a chain of empty headers which can be reorganised
+// including a single transaction
+type reorgBackend struct {
+	Backend
+	mu      sync.Mutex
+	headers []*types.Header
+	tx      common.Hash
+	txBlock int  // number of the block including the transaction, -1 if not included
+	known   bool // whether the transaction is known to the backend
+}
+
+func newReorgBackend(tx common.Hash) *reorgBackend {
+	b := &reorgBackend{tx: tx, txBlock: -1, known: true}
+	b.mine(1, 0)
+	return b
+}
+
+// mine appends blocks to the chain from the given number on, dropping the blocks
+// of a different fork, the extra data distinguishes the forks
+func (b *reorgBackend) mine(n int, fork byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < n; i++ {
+		number := len(b.headers)
+		header := &types.Header{Number: big.NewInt(int64(number)), Extra: []byte{fork}}
+		if number > 0 {
+			header.ParentHash = b.headers[number-1].Hash()
+		}
+		b.headers = append(b.headers, header)
+	}
+}
+
+// reorg drops the blocks from the given number on
+func (b *reorgBackend) reorg(from int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.headers = b.headers[:from]
+	if b.txBlock >= from {
+		b.txBlock = -1
+	}
+}
+
+func (b *reorgBackend) include() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.txBlock = len(b.headers) - 1
+}
+
+func (b *reorgBackend) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.known = false
+}
+
+func (b *reorgBackend) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hash != b.tx || b.txBlock < 0 {
+		return nil, ethereum.NotFound
+	}
+	header := b.headers[b.txBlock]
+	return &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      hash,
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+	}, nil
+}
+
+func (b *reorgBackend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if hash != b.tx || !b.known {
+		return nil, false, ethereum.NotFound
+	}
+	return &types.Transaction{}, b.txBlock < 0, nil
+}
+
+func (b *reorgBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if number == nil {
+		return b.headers[len(b.headers)-1], nil
+	}
+	if number.Int64() >= int64(len(b.headers)) {
+		return nil, ethereum.NotFound
+	}
+	return b.headers[number.Int64()], nil
+}
+
+// TestWaitConfirmedReorg tests that the confirmations of a transaction are counted
+// again from the block including it after a reorganisation of the chain
+func TestWaitConfirmedReorg(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = time.Millisecond
+
+	tx := common.HexToHash("0x01")
+	b := newReorgBackend(tx)
+	b.mine(1, 0)
+	b.include()
+
+	type update struct {
+		receipt       *types.Receipt
+		confirmations uint64
+	}
+	updates := make(chan update, 16)
+	result := make(chan *types.Receipt, 1)
+	errc := make(chan error, 1)
+	go func() {
+		receipt, err := WaitConfirmed(context.Background(), b, tx, 3, func(receipt *types.Receipt, confirmations uint64) {
+			updates <- update{receipt, confirmations}
+		})
+		if err != nil {
+			errc <- err
+			return
+		}
+		result <- receipt
+	}()
+
+	expectUpdate := func(included bool, confirmations uint64) *types.Receipt {
+		t.Helper()
+		select {
+		case u := <-updates:
+			if (u.receipt != nil) != included || u.confirmations != confirmations {
+				t.Fatalf("expected update with receipt %v and %d confirmations, got %v and %d", included, confirmations, u.receipt != nil, u.confirmations)
+			}
+			return u.receipt
+		case err := <-errc:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for update")
+		}
+		return nil
+	}
+
+	first := expectUpdate(true, 1)
+	b.mine(1, 0)
+	expectUpdate(true, 2)
+
+	// the blocks including the transaction are replaced by a fork not including it yet
+	b.reorg(1)
+	expectUpdate(false, 0)
+	b.mine(2, 1)
+	b.include()
+	second := expectUpdate(true, 1)
+	if second.BlockHash == first.BlockHash {
+		t.Fatal("expected transaction to be included in the block of the new fork")
+	}
+	b.mine(1, 1)
+	expectUpdate(true, 2)
+	b.mine(1, 1)
+	expectUpdate(true, 3)
+
+	select {
+	case receipt := <-result:
+		if receipt.BlockHash != second.BlockHash {
+			t.Fatalf("expected receipt of block %v, got %v", second.BlockHash, receipt.BlockHash)
+		}
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for confirmation")
+	}
+}
+
+// TestWaitConfirmedDropped tests that waiting for a transaction which was reorganised
+// out of the chain and dropped by the backend fails with ErrTransactionDropped
+func TestWaitConfirmedDropped(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = time.Millisecond
+
+	tx := common.HexToHash("0x01")
+	b := newReorgBackend(tx)
+	b.mine(1, 0)
+	b.include()
+	b.reorg(1)
+	b.drop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := WaitConfirmed(ctx, b, tx, 2, nil); err != ErrTransactionDropped {
+		t.Fatalf("expected error %v, got %v", ErrTransactionDropped, err)
+	}
+}
@@ -2,6 +2,7 @@ package mock
 
 import (
 	"context"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -21,6 +22,15 @@ func (b *TestBackend) SendTransaction(ctx context.Context, tx *types.Transaction
 	return err
 }
 
+// HeaderByNumber returns the header of the canonical block with the given number,
+// or of the latest block if the number is nil
+func (b *TestBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return b.Blockchain().CurrentHeader(), nil
+	}
+	return b.Blockchain().GetHeaderByNumber(number.Uint64()), nil
+}
+
 // Close overrides the Close function of the underlying SimulatedBackend so that it does nothing
 // This allows the same SimulatedBackend backend to be reused across tests
 // This is necessary due to some memory leakage issues with the used version of the SimulatedBackend
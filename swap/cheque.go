@@ -118,8 +118,8 @@ func (cheque *Cheque) verifyChequeProperties(p *Peer, expectedBeneficiary common
 		return fmt.Errorf("wrong cheque parameters: expected contract: %x, was: %x", p.contractAddress, cheque.Contract)
 	}
 
-	// the beneficiary is the owner of the counterparty swap contract
-	if err := cheque.VerifySig(p.beneficiary); err != nil {
+	// the cheque is signed by the owner of the counterparty swap contract
+	if err := cheque.VerifySig(p.issuer); err != nil {
 		return err
 	}
 
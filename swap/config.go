@@ -39,4 +39,9 @@ const (
 	// Until we deploy swap officially, it's only allowed to be enabled under a specific network ID (use the --bzznetworkid flag to set it)
 	AllowedNetworkID          = 5
 	DefaultTransactionTimeout = 10 * time.Minute
+	// DefaultTransactionConfirmations is the number of blocks, the including block counting as the first one,
+	// after which the transactions deploying the chequebook and cashing cheques are considered final
+	DefaultTransactionConfirmations = 6
+	// MaxTransactionResubmissions is the number of times a dropped transaction is sent again
+	MaxTransactionResubmissions = 3
 )
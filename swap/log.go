@@ -37,6 +37,10 @@ const (
 	CashChequeAction string = "cash_cheque"
 	// DeployChequebookAction used when deploying chequebooks
 	DeployChequebookAction string = "deploy_chequebook_contract"
+	// ChangeBeneficiaryAction used when a peer announces a new beneficiary
+	ChangeBeneficiaryAction string = "change_beneficiary"
+	// ChangeThresholdsAction used when the payment and disconnect thresholds are changed
+	ChangeThresholdsAction string = "change_thresholds"
 )
 
 // DefaultSwapLogLevel indicates default filter level of log messages
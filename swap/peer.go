@@ -37,7 +37,8 @@ type Peer struct {
 	*protocols.Peer
 	lock               sync.RWMutex
 	swap               *Swap
-	beneficiary        common.Address // address of the peers chequebook owner
+	issuer             common.Address // address of the peers chequebook owner, who signs the peers cheques
+	beneficiary        common.Address // address cheques for the peer are issued to
 	contractAddress    common.Address // address of the peers chequebook
 	lastReceivedCheque *Cheque        // last cheque we received from the peer
 	lastSentCheque     *Cheque        // last cheque that was sent to peer that was confirmed
@@ -47,11 +48,14 @@ type Peer struct {
 }
 
 // NewPeer creates a new swap Peer instance
-func NewPeer(p *protocols.Peer, s *Swap, beneficiary common.Address, contractAddress common.Address) (peer *Peer, err error) {
+// the issuer is the owner of the peers chequebook, which is also the
+// beneficiary of cheques for the peer until the peer announces a different one
+func NewPeer(p *protocols.Peer, s *Swap, issuer common.Address, contractAddress common.Address) (peer *Peer, err error) {
 	peer = &Peer{
 		Peer:            p,
 		swap:            s,
-		beneficiary:     beneficiary,
+		issuer:          issuer,
+		beneficiary:     issuer,
 		contractAddress: contractAddress,
 		logger:          newPeerLogger(s, p.ID()),
 	}
@@ -100,6 +104,19 @@ func (p *Peer) setLastReceivedCheque(cheque *Cheque) error {
 	return p.swap.saveLastReceivedCheque(p.ID(), cheque)
 }
 
+// receiveCheque sets the given cheque as the last one received from this peer and the balance
+// of the peer reduced by its amount, both are persisted at once before they are applied
+// the caller is expected to hold p.lock
+func (p *Peer) receiveCheque(cheque *Cheque, balance int64) error {
+	if err := p.swap.saveReceivedCheque(p.ID(), cheque, balance); err != nil {
+		return err
+	}
+	p.lastReceivedCheque = cheque
+	p.balance = balance
+	p.logger.Debug(UpdateBalanceAction, "balance", strconv.FormatInt(balance, 10))
+	return nil
+}
+
 // setLastReceivedCheque sets the given cheque as the last sent cheque for this peer
 // the caller is expected to hold p.lock
 func (p *Peer) setLastSentCheque(cheque *Cheque) error {
@@ -114,14 +131,55 @@ func (p *Peer) setPendingCheque(cheque *Cheque) error {
 	return p.swap.savePendingCheque(p.ID(), cheque)
 }
 
-// getLastSentCumulativePayout returns the cumulative payout of the last sent cheque or 0 if there is none
+// getLastReceivedBeneficiaryCheque returns the last cheque we received from this peer for the given beneficiary
+// the caller is expected to hold p.lock
+func (p *Peer) getLastReceivedBeneficiaryCheque(beneficiary common.Address) (*Cheque, error) {
+	lastCheque := p.getLastReceivedCheque()
+	if lastCheque != nil && lastCheque.Beneficiary == beneficiary {
+		return lastCheque, nil
+	}
+	return p.swap.loadBeneficiaryCheque(beneficiaryReceivedChequeKey(p.ID(), beneficiary))
+}
+
+// getLastSentCumulativePayout returns the cumulative payout of the last cheque sent to the
+// current beneficiary of the peer or 0 if there is none, as the chequebook keeps track
+// of the paid out amount per beneficiary
 // the caller is expected to hold p.lock
-func (p *Peer) getLastSentCumulativePayout() *int256.Uint256 {
+func (p *Peer) getLastSentCumulativePayout() (*int256.Uint256, error) {
 	lastCheque := p.getLastSentCheque()
+	if lastCheque == nil || lastCheque.Beneficiary != p.beneficiary {
+		var err error
+		lastCheque, err = p.swap.loadBeneficiaryCheque(beneficiarySentChequeKey(p.ID(), p.beneficiary))
+		if err != nil {
+			return nil, err
+		}
+	}
 	if lastCheque != nil {
-		return lastCheque.CumulativePayout
+		return lastCheque.CumulativePayout, nil
+	}
+	return int256.Uint256From(0), nil
+}
+
+// setBeneficiary changes the address cheques for the peer are issued to
+// a pending cheque to the previous beneficiary will not be accepted by the peer anymore,
+// so it is dropped and its amount is owed to the peer again
+// the caller is expected to hold p.lock
+func (p *Peer) setBeneficiary(beneficiary common.Address) error {
+	if beneficiary == p.beneficiary {
+		return nil
+	}
+	p.logger.Info(ChangeBeneficiaryAction, "peer changed beneficiary", "previous", p.beneficiary, "beneficiary", beneficiary)
+	p.beneficiary = beneficiary
+
+	pending := p.getPendingCheque()
+	if pending == nil || pending.Beneficiary == beneficiary {
+		return nil
+	}
+	p.logger.Warn(ChangeBeneficiaryAction, "dropping pending cheque to previous beneficiary", "cheque", pending)
+	if err := p.setPendingCheque(nil); err != nil {
+		return err
 	}
-	return int256.Uint256From(0)
+	return p.updateBalance(-int64(pending.Honey))
 }
 
 // the caller is expected to hold p.lock
@@ -168,7 +226,10 @@ func (p *Peer) createCheque() (*Cheque, error) {
 	}
 	price := int256.Uint256From(oraclePrice)
 
-	cumulativePayout := p.getLastSentCumulativePayout()
+	cumulativePayout, err := p.getLastSentCumulativePayout()
+	if err != nil {
+		return nil, err
+	}
 	newCumulativePayout, err := new(int256.Uint256).Add(cumulativePayout, price)
 	if err != nil {
 		return nil, err
@@ -44,6 +44,9 @@ allowing for a multi-currency design.
 const (
 	RetrieveRequestPrice = uint64(8043036262)
 	ChunkDeliveryPrice   = uint64(17672687)
+	// DefaultChunkPrice is the price of a retrieval priced per chunk rather than per message,
+	// the price of the request and of the delivery of a full chunk of 4096 bytes
+	DefaultChunkPrice = RetrieveRequestPrice + 4096*ChunkDeliveryPrice
 	// default conversion of honey into output currency - currently ETH in Wei
 	defaultHoneyPrice = uint64(1)
 )
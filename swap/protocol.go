@@ -41,9 +41,11 @@ var (
 
 	// Spec is the swap protocol specification
 	Spec = &protocols.Spec{
-		Name:       "swap",
-		Version:    1,
-		MaxMsgSize: 10 * 1024 * 1024,
+		Name:             "swap",
+		Version:          2,
+		MinVersion:       1, // peers running versions down to MinVersion issue cheques to the chequebook owner, see announcesBeneficiary
+		MaxMsgSize:       10 * 1024 * 1024,
+		MaxHandshakeSize: 4 * 1024,
 		Messages: []interface{}{
 			HandshakeMsg{},
 			EmitChequeMsg{},
@@ -52,16 +54,31 @@ var (
 	}
 )
 
+// beneficiaryVersion is the first swap protocol version whose peers announce the
+// beneficiary of their cheques in the handshake, the beneficiary of the handshakes
+// exchanged with peers running older versions is left empty
+const beneficiaryVersion = 2
+
+// announcesBeneficiary reports whether the peer and this node announce the
+// beneficiary of their cheques to each other
+func announcesBeneficiary(p *protocols.Peer) bool {
+	return p.Version() >= beneficiaryVersion
+}
+
 // Protocols is a node.Service interface method
+// It returns a protocol for each version supported by Spec, devp2p runs the
+// highest version supported by both peers
 func (s *Swap) Protocols() []p2p.Protocol {
-	return []p2p.Protocol{
-		{
+	var protos []p2p.Protocol
+	for _, v := range Spec.Versions() {
+		protos = append(protos, p2p.Protocol{
 			Name:    Spec.Name,
-			Version: Spec.Version,
+			Version: v,
 			Length:  Spec.Length(),
 			Run:     s.run,
-		},
+		})
 	}
+	return protos
 }
 
 // Start is a node.Service interface method
@@ -98,10 +115,14 @@ func (s *Swap) verifyHandshake(msg interface{}) error {
 func (s *Swap) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 	protoPeer := protocols.NewPeer(p, rw, Spec)
 
-	handshake, err := protoPeer.Handshake(context.Background(), &HandshakeMsg{
+	msg := &HandshakeMsg{
 		ContractAddress: s.GetParams().ContractAddress,
 		ChainID:         s.chainID,
-	}, s.verifyHandshake)
+	}
+	if announcesBeneficiary(protoPeer) {
+		msg.Beneficiary = s.beneficiary()
+	}
+	handshake, err := protoPeer.Handshake(context.Background(), msg, s.verifyHandshake)
 	if err != nil {
 		return err
 	}
@@ -111,17 +132,26 @@ func (s *Swap) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 		return ErrInvalidHandshakeMsg
 	}
 
-	beneficiary, err := s.getContractOwner(context.Background(), response.ContractAddress)
+	issuer, err := s.getContractOwner(context.Background(), response.ContractAddress)
 	if err != nil {
 		return err
 	}
 
-	swapPeer, err := s.addPeer(protoPeer, beneficiary, response.ContractAddress)
+	swapPeer, err := s.addPeer(protoPeer, issuer, response.ContractAddress)
 	if err != nil {
 		return err
 	}
 	defer s.removePeer(swapPeer)
 
+	if (response.Beneficiary != common.Address{}) {
+		swapPeer.lock.Lock()
+		err = swapPeer.setBeneficiary(response.Beneficiary)
+		swapPeer.lock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
 	return swapPeer.Run(s.handleMsg(swapPeer))
 }
 
@@ -131,10 +161,10 @@ func (s *Swap) removePeer(p *Peer) {
 	delete(s.peers, p.ID())
 }
 
-func (s *Swap) addPeer(protoPeer *protocols.Peer, beneficiary common.Address, contractAddress common.Address) (*Peer, error) {
+func (s *Swap) addPeer(protoPeer *protocols.Peer, issuer common.Address, contractAddress common.Address) (*Peer, error) {
 	s.peersLock.Lock()
 	defer s.peersLock.Unlock()
-	p, err := NewPeer(protoPeer, s, beneficiary, contractAddress)
+	p, err := NewPeer(protoPeer, s, issuer, contractAddress)
 	if err != nil {
 		return nil, err
 	}
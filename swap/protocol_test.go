@@ -17,6 +17,7 @@
 package swap
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -30,9 +31,12 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	contract "github.com/ethersphere/swarm/contracts/swap"
+	"github.com/ethersphere/swarm/p2p/protocols"
 	p2ptest "github.com/ethersphere/swarm/p2p/testing"
 	"github.com/ethersphere/swarm/swap/int256"
 	colorable "github.com/mattn/go-colorable"
@@ -129,7 +133,9 @@ func newSwapHandshakeMsg(contractAddress common.Address, chainID uint64) *Handsh
 
 // creates the correct HandshakeMsg based on Swap instance
 func correctSwapHandshakeMsg(swap *Swap) *HandshakeMsg {
-	return newSwapHandshakeMsg(swap.GetParams().ContractAddress, swap.chainID)
+	msg := newSwapHandshakeMsg(swap.GetParams().ContractAddress, swap.chainID)
+	msg.Beneficiary = swap.beneficiary()
+	return msg
 }
 
 // TestHandshake tests the correct handshake scenario
@@ -216,6 +222,65 @@ func TestHandshakeInvalidContract(t *testing.T) {
 	}
 }
 
+// TestHandshakeBeneficiaryVersion tests that handshakes without beneficiary are
+// encoded as in the versions before beneficiaryVersion, that the handshakes of those
+// versions are decoded without beneficiary, and that the cheques of peers running
+// those versions are expected to be issued to the owner
+func TestHandshakeBeneficiaryVersion(t *testing.T) {
+	type legacyHandshakeMsg struct {
+		ChainID         uint64
+		ContractAddress common.Address
+	}
+	msg := newSwapHandshakeMsg(testChequeContract, 1)
+	b, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := rlp.EncodeToBytes(&legacyHandshakeMsg{ChainID: 1, ContractAddress: testChequeContract})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got encoding %x, want %x", b, want)
+	}
+	var got HandshakeMsg
+	if err := rlp.DecodeBytes(want, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, msg) {
+		t.Fatalf("got handshake %v, want %v", got, msg)
+	}
+
+	msg.Beneficiary = beneficiaryAddress
+	if b, err = rlp.EncodeToBytes(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := rlp.DecodeBytes(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, msg) {
+		t.Fatalf("got handshake %v, want %v", got, msg)
+	}
+
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+	swap, testDir := newBaseTestSwap(t, ownerKey, testBackend)
+	defer os.RemoveAll(testDir)
+	swap.params.Beneficiary = beneficiaryAddress
+
+	for _, version := range []uint{beneficiaryVersion - 1, beneficiaryVersion} {
+		caps := []p2p.Cap{{Name: Spec.Name, Version: version}}
+		protoPeer := protocols.NewPeer(p2p.NewPeer(enode.ID{}, "", caps), nil, Spec)
+		want := swap.owner.address
+		if version >= beneficiaryVersion {
+			want = beneficiaryAddress
+		}
+		if got := swap.beneficiaryFor(&Peer{Peer: protoPeer}); got != want {
+			t.Errorf("version %d: got beneficiary %x, want %x", version, got, want)
+		}
+	}
+}
+
 // TestEmitCheque tests the correct processing of EmitChequeMsg messages
 // One protocol tester is created which will receive the EmitChequeMsg
 // A second swap instance is created for easy creation of a chequebook contract which is deployed to the simulated backend
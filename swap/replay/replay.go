@@ -0,0 +1,580 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package replay replays a recorded sequence of accounted messages through the Swap
+// engine, to compute the resulting balances and the cheques exchanged with the peers
+// without a live network.
+//
+// It is meant for tuning the thresholds and for regression testing of price changes:
+// the messages tapped from a running node (see protocols.SubscribeMsgs) can be replayed
+// with different parameters and the results compared.
+//
+// The local node and every peer run a Swap instance with an in-memory store on a simulated
+// chain, where their chequebooks are deployed. The instances are connected by the swap
+// protocol and every record is accounted on both sides with Swap.Check and Swap.Add, the
+// cheques they trigger are exchanged and confirmed before the next record is applied.
+// The peers are assumed to account the same messages with the same prices.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	contractFactory "github.com/ethersphere/go-sw3/contracts-v0-2-0/simpleswapfactory"
+	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/swap"
+	"github.com/ethersphere/swarm/swap/chain/mock"
+	"github.com/ethersphere/swarm/swap/int256"
+)
+
+// Record is an accounted message sent to or received from a peer
+type Record struct {
+	Peer      enode.ID         `json:"peer"`
+	Type      string           `json:"type"`            // type of the message, as reported by the message tap
+	Size      uint32           `json:"size"`            // size of the encoded message
+	Direction string           `json:"direction"`       // protocols.MsgIn or protocols.MsgOut
+	Price     *protocols.Price `json:"price,omitempty"` // recorded price, nil for messages which are not priced
+}
+
+// RecordOf returns the record of a message event reported by the message tap
+func RecordOf(e protocols.MsgEvent) Record {
+	return Record{
+		Peer:      e.Peer,
+		Type:      e.Type,
+		Size:      e.Size,
+		Direction: e.Direction,
+		Price:     e.Price,
+	}
+}
+
+// ReadRecords reads a stream of JSON encoded message events, as delivered by the
+// subscriptions of the message tap, and returns their records
+func ReadRecords(r io.Reader) (records []Record, err error) {
+	dec := json.NewDecoder(r)
+	for {
+		var e protocols.MsgEvent
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return records, nil
+			}
+			return nil, fmt.Errorf("decoding message event %d: %w", len(records), err)
+		}
+		records = append(records, RecordOf(e))
+	}
+}
+
+// Prices maps message types to the prices used in the replay instead of the recorded ones
+type Prices map[string]*protocols.Price
+
+// PricesOf returns the prices of the messages keyed by their type as reported by the message tap
+func PricesOf(msgs ...protocols.PricedMessage) Prices {
+	prices := make(Prices, len(msgs))
+	for _, msg := range msgs {
+		prices[fmt.Sprintf("%T", msg)] = msg.Price()
+	}
+	return prices
+}
+
+// Params are the parameters of the Swap engine a sequence of messages is replayed with
+type Params struct {
+	PaymentThreshold    int64  // honey amount at which a payment is triggered
+	DisconnectThreshold int64  // honey amount at which a peer disconnects
+	PeerThreshold       int64  // honey amount at which the peers pay, the payment threshold if 0
+	Prices              Prices // optional prices overriding the recorded ones
+}
+
+// NewParams returns the parameters with the default thresholds of the Swap engine
+func NewParams() *Params {
+	return &Params{
+		PaymentThreshold:    int64(swap.DefaultPaymentThreshold),
+		DisconnectThreshold: int64(swap.DefaultDisconnectThreshold),
+	}
+}
+
+var (
+	// chainID is the chain id of the simulated backend
+	chainID uint64 = 1337
+	// chainFunds is the ether allocated to the local node on the simulated chain,
+	// it pays for its chequebook and funds the peers
+	chainFunds = new(big.Int).Exp(big.NewInt(10), big.NewInt(30), nil)
+	// peerFunds is the ether transferred to a peer to deploy its chequebook
+	peerFunds = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	// gasLimit is the block gas limit of the simulated chain
+	gasLimit uint64 = 8000000
+	// exchangeTimeout is the time allowed to connect to a peer or to exchange a cheque
+	exchangeTimeout = 10 * time.Second
+	// pollInterval is the interval at which the connections and the pending cheques are polled
+	pollInterval = 5 * time.Millisecond
+)
+
+// ErrInvalidDirection is returned for records which are neither sent nor received
+var ErrInvalidDirection = errors.New("invalid direction")
+
+// Cheque is a cheque sent to or received from a peer during the replay
+type Cheque struct {
+	Record    int      // index of the record which triggered the cheque
+	Peer      enode.ID // the peer the cheque was exchanged with
+	Direction string   // protocols.MsgOut for cheques sent to the peer, protocols.MsgIn for received ones
+	Honey     uint64   // honey amount of the cheque
+	Amount    uint64   // amount of the cheque as given by the oracle of the engine
+}
+
+// PeerResult is the outcome of the replay for a peer
+type PeerResult struct {
+	Balance       int64  // honey balance with the peer, positive if the peer owes the local node
+	Messages      uint64 // number of accounted messages
+	Rejected      uint64 // number of priced messages refused by Swap.Check of either node
+	HoneySent     uint64 // total honey amount of the cheques sent to the peer
+	HoneyReceived uint64 // total honey amount of the cheques received from the peer
+}
+
+// Result is the outcome of a replay
+type Result struct {
+	Peers   map[enode.ID]*PeerResult
+	Cheques []Cheque // exchanged cheques in the order of the records which triggered them
+}
+
+// Replayer applies records one by one
+type Replayer struct {
+	params  *Params
+	count   int // number of applied records
+	result  *Result
+	backend *mock.TestBackend // simulated chain of the chequebooks
+	factory common.Address    // address of the chequebook factory on the simulated chain
+	key     *ecdsa.PrivateKey // key of the local node
+	local   *swap.Swap        // engine of the local node
+	peers   map[enode.ID]*peer
+}
+
+// peer is a peer of the replay, connected to the local node by the swap protocol
+type peer struct {
+	swap   *swap.Swap      // engine of the peer
+	local  *protocols.Peer // the peer as accounted by the local node
+	remote *protocols.Peer // the local node as accounted by the peer
+	pipes  []io.Closer
+	wg     sync.WaitGroup
+	errc   chan error // errors of the swap protocols of the two sides
+}
+
+// NewReplayer returns a replayer for the params, which are validated as by Swap.SetThresholds.
+// It deploys the chequebook factory and the chequebook of the local node on a new simulated
+// chain, the replayer must be closed when it is no longer used
+func NewReplayer(params *Params) (*Replayer, error) {
+	p := *params
+	params = &p
+	if params.PaymentThreshold <= 0 {
+		return nil, fmt.Errorf("payment threshold must be positive, found %d", params.PaymentThreshold)
+	}
+	if params.DisconnectThreshold <= params.PaymentThreshold {
+		return nil, fmt.Errorf("disconnect threshold lower or at payment threshold. DisconnectThreshold: %d, PaymentThreshold: %d", params.DisconnectThreshold, params.PaymentThreshold)
+	}
+	if params.PeerThreshold < 0 {
+		return nil, fmt.Errorf("peer payment threshold must not be negative, found %d", params.PeerThreshold)
+	}
+	if params.PeerThreshold == 0 {
+		params.PeerThreshold = params.PaymentThreshold
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	backend := mock.NewTestBackend(backends.NewSimulatedBackend(core.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: chainFunds},
+	}, gasLimit))
+	token, _, _, err := contractFactory.DeployERC20Mintable(bind.NewKeyedTransactor(key), backend)
+	if err != nil {
+		backend.SimulatedBackend.Close()
+		return nil, fmt.Errorf("deploying token: %w", err)
+	}
+	factory, _, _, err := contractFactory.DeploySimpleSwapFactory(bind.NewKeyedTransactor(key), backend, token)
+	if err != nil {
+		backend.SimulatedBackend.Close()
+		return nil, fmt.Errorf("deploying chequebook factory: %w", err)
+	}
+	r := &Replayer{
+		params:  params,
+		backend: backend,
+		factory: factory,
+		key:     key,
+		peers:   make(map[enode.ID]*peer),
+		result: &Result{
+			Peers: make(map[enode.ID]*PeerResult),
+		},
+	}
+	r.local, err = r.newSwap(key, params.PaymentThreshold, params.DisconnectThreshold)
+	if err != nil {
+		backend.SimulatedBackend.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Replay applies the records with the params and returns the result
+func Replay(params *Params, records []Record) (*Result, error) {
+	r, err := NewReplayer(params)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, rec := range records {
+		if err := r.Apply(rec); err != nil {
+			return nil, err
+		}
+	}
+	return r.Result(), nil
+}
+
+// Apply accounts a record on both sides with Swap.Check and Swap.Add and waits
+// for the cheques it triggers to be confirmed
+func (r *Replayer) Apply(rec Record) error {
+	index := r.count
+	r.count++
+
+	var payer protocols.Payer
+	switch rec.Direction {
+	case protocols.MsgOut:
+		payer = protocols.Sender
+	case protocols.MsgIn:
+		payer = protocols.Receiver
+	default:
+		return fmt.Errorf("record %d: %w %q", index, ErrInvalidDirection, rec.Direction)
+	}
+
+	price := rec.Price
+	if p, ok := r.params.Prices[rec.Type]; ok {
+		price = p
+	}
+	if price == nil {
+		return nil
+	}
+	p, err := r.peer(rec.Peer)
+	if err != nil {
+		return fmt.Errorf("record %d: connecting to peer %s: %w", index, rec.Peer, err)
+	}
+	result := r.peerResult(rec.Peer)
+	// the amount is accounted by the local node, the peer accounts its opposite
+	amount := price.For(payer, rec.Size)
+
+	// as in the accounting of the protocols, the message is refused if either side would
+	// incur more debt over its disconnect threshold
+	if r.local.Check(amount, p.local) != nil || p.swap.Check(-amount, p.remote) != nil {
+		result.Rejected++
+		return nil
+	}
+
+	sent, err := lastSentCheque(r.local, rec.Peer)
+	if err != nil {
+		return fmt.Errorf("record %d: %w", index, err)
+	}
+	received, err := lastSentCheque(p.swap, p.remote.ID())
+	if err != nil {
+		return fmt.Errorf("record %d: %w", index, err)
+	}
+
+	// the creditor accounts first, so that the cheque the debtor may send is not
+	// processed before the debt it pays is accounted
+	adds := []func() error{
+		func() error { return r.local.Add(amount, p.local) },
+		func() error { return p.swap.Add(-amount, p.remote) },
+	}
+	if amount < 0 {
+		adds[0], adds[1] = adds[1], adds[0]
+	}
+	for _, add := range adds {
+		if err := add(); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+	}
+	if err := p.await(func() (bool, error) {
+		for _, c := range []struct {
+			s  *swap.Swap
+			id enode.ID
+		}{{r.local, rec.Peer}, {p.swap, p.remote.ID()}} {
+			cheques, err := c.s.PeerCheques(c.id)
+			if err != nil || cheques.PendingCheque != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return fmt.Errorf("record %d: exchanging cheques: %w", index, err)
+	}
+
+	if result.Balance, err = r.local.PeerBalance(rec.Peer); err != nil {
+		return fmt.Errorf("record %d: %w", index, err)
+	}
+	result.Messages++
+	if cheque, err := r.newCheque(index, rec.Peer, protocols.MsgOut, r.local, rec.Peer, sent); err != nil {
+		return err
+	} else if cheque != nil {
+		result.HoneySent += cheque.Honey
+	}
+	if cheque, err := r.newCheque(index, rec.Peer, protocols.MsgIn, p.swap, p.remote.ID(), received); err != nil {
+		return err
+	} else if cheque != nil {
+		result.HoneyReceived += cheque.Honey
+	}
+	return nil
+}
+
+// Result returns the outcome of the records applied so far
+func (r *Replayer) Result() *Result {
+	return r.result
+}
+
+// Close disconnects the peers and closes their engines and the simulated chain
+func (r *Replayer) Close() error {
+	var errs []error
+	for _, p := range r.peers {
+		for _, pipe := range p.pipes {
+			pipe.Close()
+		}
+		p.wg.Wait()
+		if err := p.swap.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := r.local.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	r.backend.SimulatedBackend.Close()
+	if len(errs) > 0 {
+		return fmt.Errorf("closing swap: %v", errs)
+	}
+	return nil
+}
+
+// newSwap returns an engine with the thresholds and its chequebook deployed on the simulated chain
+func (r *Replayer) newSwap(key *ecdsa.PrivateKey, payment, disconnect int64) (*swap.Swap, error) {
+	// cheques are issued to an address other than the owner of the engine, so that they
+	// are not cashed on the simulated chain, which does not change the accounting
+	beneficiary, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	id := enode.PubkeyToIDV4(&key.PublicKey)
+	return swap.NewWithBackend(state.NewInmemoryStore(), key, r.backend, chainID, &swap.Params{
+		BaseAddrs:           network.NewBzzAddr(id.Bytes(), nil),
+		LogLevel:            swap.DefaultSwapLogLevel,
+		PaymentThreshold:    payment,
+		DisconnectThreshold: disconnect,
+		Beneficiary:         crypto.PubkeyToAddress(beneficiary.PublicKey),
+	}, r.factory)
+}
+
+// peer returns the peer with the id, which is created and connected to the local node
+// by the swap protocol at the first record of the peer
+func (r *Replayer) peer(id enode.ID) (*peer, error) {
+	if p, ok := r.peers[id]; ok {
+		return p, nil
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.fund(crypto.PubkeyToAddress(key.PublicKey)); err != nil {
+		return nil, err
+	}
+	// the peers disconnect at the same margin over their payment threshold as the local node
+	peerSwap, err := r.newSwap(key, r.params.PeerThreshold, r.params.PeerThreshold+r.params.DisconnectThreshold-r.params.PaymentThreshold)
+	if err != nil {
+		return nil, err
+	}
+	localID := enode.PubkeyToIDV4(&r.key.PublicKey)
+	p := &peer{
+		swap:   peerSwap,
+		local:  protocols.NewPeer(p2p.NewPeer(id, "", nil), nil, nil),
+		remote: protocols.NewPeer(p2p.NewPeer(localID, "", nil), nil, nil),
+		errc:   make(chan error, 2),
+	}
+	r.peers[id] = p
+
+	pipe, peerRW := p2p.MsgPipe()
+	localRW := newQueuedRW(pipe)
+	p.pipes = []io.Closer{localRW, peerRW}
+	p.run(r.local, id, localRW)
+	p.run(peerSwap, localID, peerRW)
+	// the peers are only known to the engines after the handshake,
+	// a zero amount is accepted by Swap.Check for any known peer
+	return p, p.await(func() (bool, error) {
+		return r.local.Check(0, p.local) == nil && peerSwap.Check(0, p.remote) == nil, nil
+	})
+}
+
+// fund transfers ether from the local node to the address on the simulated chain
+func (r *Replayer) fund(address common.Address) error {
+	ctx := context.Background()
+	nonce, err := r.backend.PendingNonceAt(ctx, crypto.PubkeyToAddress(r.key.PublicKey))
+	if err != nil {
+		return err
+	}
+	gasPrice, err := r.backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	tx, err := types.SignTx(types.NewTransaction(nonce, address, peerFunds, 21000, gasPrice, nil), types.HomesteadSigner{}, r.key)
+	if err != nil {
+		return err
+	}
+	return r.backend.SendTransaction(ctx, tx)
+}
+
+func (r *Replayer) peerResult(id enode.ID) *PeerResult {
+	result, ok := r.result.Peers[id]
+	if !ok {
+		result = new(PeerResult)
+		r.result.Peers[id] = result
+	}
+	return result
+}
+
+// newCheque records the cheque sent by the engine to the peer with the id if it is not the last
+// one sent before the record, it returns nil if no cheque was sent
+func (r *Replayer) newCheque(index int, peer enode.ID, direction string, s *swap.Swap, id enode.ID, last *swap.Cheque) (*Cheque, error) {
+	cheque, err := lastSentCheque(s, id)
+	if err != nil {
+		return nil, fmt.Errorf("record %d: %w", index, err)
+	}
+	if cheque == nil || (last != nil && cheque.Equal(last)) {
+		return nil, nil
+	}
+	amount := cheque.CumulativePayout
+	if last != nil {
+		if amount, err = new(int256.Uint256).Sub(cheque.CumulativePayout, last.CumulativePayout); err != nil {
+			return nil, fmt.Errorf("record %d: %w", index, err)
+		}
+	}
+	r.result.Cheques = append(r.result.Cheques, Cheque{
+		Record:    index,
+		Peer:      peer,
+		Direction: direction,
+		Honey:     cheque.Honey,
+		Amount:    amount.Value().Uint64(),
+	})
+	return &r.result.Cheques[len(r.result.Cheques)-1], nil
+}
+
+// lastSentCheque returns the last cheque confirmed by the peer with the id, nil if there is none
+func lastSentCheque(s *swap.Swap, id enode.ID) (*swap.Cheque, error) {
+	cheques, err := s.PeerCheques(id)
+	if err != nil {
+		return nil, err
+	}
+	return cheques.LastSentCheque, nil
+}
+
+// run runs the swap protocol of the engine with the peer with the id on the pipe
+func (p *peer) run(s *swap.Swap, id enode.ID, rw p2p.MsgReadWriter) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.errc <- s.Protocols()[0].Run(p2p.NewPeer(id, "", nil), rw)
+	}()
+}
+
+// queuedRW queues the messages written to a pipe, so that both sides of the swap
+// handshake can send before receiving as over a network connection
+type queuedRW struct {
+	*p2p.MsgPipeRW
+	queue  chan p2p.Msg
+	closed chan struct{}
+}
+
+func newQueuedRW(rw *p2p.MsgPipeRW) *queuedRW {
+	q := &queuedRW{
+		MsgPipeRW: rw,
+		queue:     make(chan p2p.Msg, 16),
+		closed:    make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case msg := <-q.queue:
+				if err := rw.WriteMsg(msg); err != nil {
+					return
+				}
+			case <-q.closed:
+				return
+			}
+		}
+	}()
+	return q
+}
+
+// WriteMsg queues the message with its payload read
+func (q *queuedRW) WriteMsg(msg p2p.Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	select {
+	case q.queue <- msg:
+		return nil
+	case <-q.closed:
+		return p2p.ErrPipeClosed
+	}
+}
+
+// Close closes the pipe and stops writing the queued messages
+func (q *queuedRW) Close() error {
+	err := q.MsgPipeRW.Close()
+	close(q.closed)
+	return err
+}
+
+// await polls the condition until it holds, it fails if the swap protocol of either
+// side stops or if the condition does not hold within the exchange timeout
+func (p *peer) await(cond func() (bool, error)) error {
+	timeout := time.After(exchangeTimeout)
+	for {
+		ok, err := cond()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case err := <-p.errc:
+			p.errc <- err
+			return fmt.Errorf("swap protocol stopped: %v", err)
+		case <-timeout:
+			return errors.New("timeout")
+		case <-time.After(pollInterval):
+		}
+	}
+}
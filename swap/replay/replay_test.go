@@ -0,0 +1,172 @@
+// Copyright 2019 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethersphere/swarm/network/retrieval"
+	"github.com/ethersphere/swarm/p2p/protocols"
+	"github.com/ethersphere/swarm/swap"
+)
+
+var (
+	request  = &protocols.Price{Value: 10, Payer: protocols.Sender}
+	delivery = &protocols.Price{Value: 1, PerByte: true, Payer: protocols.Receiver}
+)
+
+func testParams() *Params {
+	return &Params{
+		PaymentThreshold:    100,
+		DisconnectThreshold: 200,
+	}
+}
+
+// TestReplay tests that the balances and the cheques of the replayed messages
+// follow the thresholds of the params
+func TestReplay(t *testing.T) {
+	a, b := enode.ID{1}, enode.ID{2}
+	var records []Record
+	// the local node requests from a and pays with a cheque at the 10th request
+	for i := 0; i < 12; i++ {
+		records = append(records, Record{Peer: a, Type: "request", Size: 50, Direction: protocols.MsgOut, Price: request})
+	}
+	// b retrieves 150 bytes and pays with a cheque, then 30 bytes more
+	records = append(records,
+		Record{Peer: b, Type: "delivery", Size: 150, Direction: protocols.MsgOut, Price: delivery},
+		Record{Peer: b, Type: "delivery", Size: 30, Direction: protocols.MsgOut, Price: delivery},
+		Record{Peer: b, Type: "status", Size: 1000, Direction: protocols.MsgIn},
+	)
+
+	result, err := Replay(testParams(), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa := result.Peers[a]; pa.Balance != -20 || pa.Messages != 12 || pa.HoneySent != 100 || pa.HoneyReceived != 0 {
+		t.Errorf("peer a: got %+v", pa)
+	}
+	if pb := result.Peers[b]; pb.Balance != 30 || pb.Messages != 2 || pb.HoneySent != 0 || pb.HoneyReceived != 150 {
+		t.Errorf("peer b: got %+v", pb)
+	}
+	want := []Cheque{
+		{Record: 9, Peer: a, Direction: protocols.MsgOut, Honey: 100, Amount: 100},
+		{Record: 12, Peer: b, Direction: protocols.MsgIn, Honey: 150, Amount: 150},
+	}
+	if len(result.Cheques) != len(want) {
+		t.Fatalf("got %d cheques, want %d", len(result.Cheques), len(want))
+	}
+	for i, c := range result.Cheques {
+		if c != want[i] {
+			t.Errorf("cheque %d: got %+v, want %+v", i, c, want[i])
+		}
+	}
+}
+
+// TestReplayPrices tests that the prices of the params override the recorded ones
+func TestReplayPrices(t *testing.T) {
+	a := enode.ID{1}
+	records := []Record{
+		{Peer: a, Type: "request", Direction: protocols.MsgOut, Price: request},
+		{Peer: a, Type: "request", Direction: protocols.MsgIn, Price: request},
+		{Peer: a, Type: "request", Direction: protocols.MsgIn, Price: request},
+	}
+	params := testParams()
+	params.Prices = Prices{"request": &protocols.Price{Value: 40, Payer: protocols.Sender}}
+	result, err := Replay(params, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := result.Peers[a].Balance; balance != 40 {
+		t.Errorf("got balance %d, want 40", balance)
+	}
+}
+
+// TestReplayDisconnect tests that no more debt is incurred by peers over the
+// disconnect threshold if they pay later than that
+func TestReplayDisconnect(t *testing.T) {
+	a := enode.ID{1}
+	var records []Record
+	for i := 0; i < 5; i++ {
+		records = append(records, Record{Peer: a, Type: "request", Direction: protocols.MsgIn, Price: &protocols.Price{Value: 60, Payer: protocols.Sender}})
+	}
+	params := testParams()
+	params.PeerThreshold = 1000
+	result, err := Replay(params, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pa := result.Peers[a]; pa.Balance != 240 || pa.Messages != 4 || pa.Rejected != 1 {
+		t.Errorf("got %+v", pa)
+	}
+	if len(result.Cheques) != 0 {
+		t.Errorf("got %d cheques, want none", len(result.Cheques))
+	}
+}
+
+// TestReplayInvalid tests the errors of invalid params and records
+func TestReplayInvalid(t *testing.T) {
+	if _, err := NewReplayer(&Params{PaymentThreshold: 100, DisconnectThreshold: 100}); err == nil {
+		t.Error("expected error for disconnect threshold at payment threshold")
+	}
+	_, err := Replay(testParams(), []Record{{Type: "request", Direction: "sideways", Price: request}})
+	if !errors.Is(err, ErrInvalidDirection) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidDirection)
+	}
+}
+
+// TestReadRecords tests that the records are read from the JSON encoded events of
+// the message tap and are priced by the prices of the messages
+func TestReadRecords(t *testing.T) {
+	events := []protocols.MsgEvent{
+		{Peer: enode.ID{1}, Type: "*retrieval.RetrieveRequest", Size: 40, Direction: protocols.MsgOut, Price: request},
+		{Peer: enode.ID{2}, Type: "*retrieval.ChunkDelivery", Size: 4100, Direction: protocols.MsgIn},
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(events) {
+		t.Fatalf("got %d records, want %d", len(records), len(events))
+	}
+	if r := records[0]; r.Peer != events[0].Peer || r.Size != 40 || r.Direction != protocols.MsgOut || *r.Price != *request {
+		t.Errorf("got record %+v", r)
+	}
+
+	params := NewParams()
+	params.Prices = PricesOf(&retrieval.RetrieveRequest{}, &retrieval.ChunkDelivery{})
+	result, err := Replay(params, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := result.Peers[enode.ID{1}].Balance; balance != -int64(swap.RetrieveRequestPrice) {
+		t.Errorf("got balance %d, want %d", balance, -int64(swap.RetrieveRequestPrice))
+	}
+	if balance := result.Peers[enode.ID{2}].Balance; balance != -4100*int64(swap.ChunkDeliveryPrice) {
+		t.Errorf("got balance %d, want %d", balance, -4100*int64(swap.ChunkDeliveryPrice))
+	}
+}
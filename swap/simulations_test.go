@@ -662,8 +662,15 @@ func waitForChequeProcessed(t *testing.T, backend *swapTestBackend, counter metr
 				return
 			default:
 				p.lock.Lock()
-				lastPayout := p.getLastSentCumulativePayout()
+				lastPayout, err := p.getLastSentCumulativePayout()
 				p.lock.Unlock()
+				if err != nil {
+					lock.Lock()
+					errs = append(errs, err.Error())
+					lock.Unlock()
+					wg.Done()
+					return
+				}
 				if !lastPayout.Equals(int256.Uint256From(expectedLastPayout)) {
 					time.Sleep(5 * time.Millisecond)
 					continue
@@ -45,6 +45,9 @@ import (
 // ErrInvalidChequeSignature indicates the signature on the cheque was invalid
 var ErrInvalidChequeSignature = errors.New("invalid cheque signature")
 
+// ErrChequeReplayed indicates a cheque with a cumulative payout not higher than the last cheque received from the peer
+var ErrChequeReplayed = errors.New("cheque replayed")
+
 // ErrSkipDeposit indicates that the user has specified an amount to deposit (swap-deposit-amount) but also indicated that depositing should be skipped (swap-skip-deposit)
 var ErrSkipDeposit = errors.New("swap-deposit-amount non-zero, but swap-skip-deposit true")
 
@@ -60,10 +63,13 @@ type Swap struct {
 	backend           chain.Backend              // the backend (blockchain) used
 	chainID           uint64                     // id of the chain the backend is connected to
 	params            *Params                    // economic and operational parameters
+	thresholdsLock    sync.RWMutex               // lock for the thresholds in params, which can be changed while running
 	contract          contract.Contract          // reference to the smart contract
 	chequebookFactory contract.SimpleSwapFactory // the chequebook factory used
 	honeyPriceOracle  HoneyOracle                // oracle which resolves the price of honey (in Wei)
 	cashoutProcessor  *CashoutProcessor          // processor for cashing out
+	transactions      *transactionTracker        // tracker of the transactions sent until they are confirmed
+	cashoutLock       sync.Mutex                 // lock for the cheques pending cashing in the store
 	logger            Logger                     //Swap Logger
 }
 
@@ -81,10 +87,20 @@ type Params struct {
 	LogLevel            int              // optional indicates audit filter level of swap log messages
 	PaymentThreshold    int64            // honey amount at which a payment is triggered
 	DisconnectThreshold int64            // honey amount at which a peer disconnects
+	Beneficiary         common.Address   // optional address cheques for this node are issued to, defaults to the owner address
+	Confirmations       uint64           // blocks after which transactions are considered final, the including block counting as the first one
 }
 
 // newSwapInstance is a swap constructor function without integrity checks
 func newSwapInstance(stateStore state.Store, owner *Owner, backend chain.Backend, chainID uint64, params *Params, chequebookFactory contract.SimpleSwapFactory, logger Logger) *Swap {
+	transactions := &transactionTracker{
+		backend:       backend,
+		store:         stateStore,
+		confirmations: params.Confirmations,
+		logger:        logger,
+	}
+	cashoutProcessor := newCashoutProcessor(backend, owner.privateKey)
+	cashoutProcessor.transactions = transactions
 	return &Swap{
 		store:             stateStore,
 		peers:             make(map[enode.ID]*Peer),
@@ -94,7 +110,8 @@ func newSwapInstance(stateStore state.Store, owner *Owner, backend chain.Backend
 		chequebookFactory: chequebookFactory,
 		honeyPriceOracle:  NewHoneyPriceOracle(),
 		chainID:           chainID,
-		cashoutProcessor:  newCashoutProcessor(backend, owner.privateKey),
+		cashoutProcessor:  cashoutProcessor,
+		transactions:      transactions,
 		logger:            logger,
 	}
 }
@@ -188,11 +205,38 @@ func New(dbPath string, prvkey *ecdsa.PrivateKey, backendURL string, params *Par
 	return swap, nil
 }
 
+// NewWithBackend creates a swap instance on the given state store and backend and starts
+// its chequebook, deploying it through the factory if the store was not used before.
+// Unlike New it neither dials a backend nor deposits into the chequebook, it is used to run
+// the engine on a simulated chain
+func NewWithBackend(stateStore state.Store, prvkey *ecdsa.PrivateKey, backend chain.Backend, chainID uint64, params *Params, factoryAddress common.Address) (swap *Swap, err error) {
+	swapLogger := newSwapLogger(params.LogPath, params.LogLevel, params.BaseAddrs)
+	if params.DisconnectThreshold <= params.PaymentThreshold {
+		return nil, fmt.Errorf("disconnect threshold lower or at payment threshold. DisconnectThreshold: %d, PaymentThreshold: %d", params.DisconnectThreshold, params.PaymentThreshold)
+	}
+	if err := checkChainID(chainID, stateStore, swapLogger); err != nil {
+		return nil, err
+	}
+	factory, err := createFactory(factoryAddress, new(big.Int).SetUint64(chainID), backend, swapLogger)
+	if err != nil {
+		return nil, err
+	}
+	swap = newSwapInstance(stateStore, createOwner(prvkey), backend, chainID, params, factory, swapLogger)
+	if swap.contract, err = swap.StartChequebook(common.Address{}); err != nil {
+		return nil, err
+	}
+	return swap, nil
+}
+
 const (
 	balancePrefix          = "balance_"
 	sentChequePrefix       = "sent_cheque_"
 	receivedChequePrefix   = "received_cheque_"
 	pendingChequePrefix    = "pending_cheque_"
+	beneficiarySentPrefix  = "beneficiary_sent_cheque_"
+	beneficiaryRecvPrefix  = "beneficiary_received_cheque_"
+	pendingCashoutPrefix   = "pending_cashout_"
+	transactionPrefix      = "transaction_"
 	connectedChequebookKey = "connected_chequebook"
 	connectedBlockchainKey = "connected_blockchain"
 )
@@ -255,6 +299,21 @@ func pendingChequeKey(peer enode.ID) string {
 	return pendingChequePrefix + peer.String()
 }
 
+// returns the store key for retrieving the last cheque sent to a peer's beneficiary
+func beneficiarySentChequeKey(peer enode.ID, beneficiary common.Address) string {
+	return beneficiarySentPrefix + peer.String() + "_" + beneficiary.Hex()
+}
+
+// returns the store key for retrieving the last cheque received from a peer for one of our beneficiaries
+func beneficiaryReceivedChequeKey(peer enode.ID, beneficiary common.Address) string {
+	return beneficiaryRecvPrefix + peer.String() + "_" + beneficiary.Hex()
+}
+
+// returns the store key for retrieving the last received cheque of a chequebook that was not cashed yet
+func pendingCashoutKey(contract common.Address) string {
+	return pendingCashoutPrefix + contract.Hex()
+}
+
 func keyToID(key string, prefix string) enode.ID {
 	return enode.HexID(key[len(prefix):])
 }
@@ -269,12 +328,37 @@ func createOwner(prvkey *ecdsa.PrivateKey) *Owner {
 	}
 }
 
+// Thresholds returns the honey amounts at which a payment is triggered and at which a peer disconnects
+func (s *Swap) Thresholds() (payment, disconnect int64) {
+	s.thresholdsLock.RLock()
+	defer s.thresholdsLock.RUnlock()
+	return s.params.PaymentThreshold, s.params.DisconnectThreshold
+}
+
+// SetThresholds changes the payment and disconnect thresholds while running,
+// the new thresholds apply to the following accounted messages
+func (s *Swap) SetThresholds(payment, disconnect int64) error {
+	if payment <= 0 {
+		return fmt.Errorf("payment threshold must be positive, found %d", payment)
+	}
+	if disconnect <= payment {
+		return fmt.Errorf("disconnect threshold lower or at payment threshold. DisconnectThreshold: %d, PaymentThreshold: %d", disconnect, payment)
+	}
+	s.thresholdsLock.Lock()
+	defer s.thresholdsLock.Unlock()
+	s.logger.Info(ChangeThresholdsAction, "changing thresholds", "payment threshold", payment, "disconnect threshold", disconnect, "old payment threshold", s.params.PaymentThreshold, "old disconnect threshold", s.params.DisconnectThreshold)
+	s.params.PaymentThreshold = payment
+	s.params.DisconnectThreshold = disconnect
+	return nil
+}
+
 // modifyBalanceOk checks that the amount would not result in crossing the disconnection threshold
 func (s *Swap) modifyBalanceOk(amount int64, swapPeer *Peer) (err error) {
 	// check if balance with peer is over the disconnect threshold and if the message would increase the existing debt
 	balance := swapPeer.getBalance()
-	if balance >= s.params.DisconnectThreshold && amount > 0 {
-		return fmt.Errorf("balance for peer %s is over the disconnect threshold %d and cannot incur more debt, disconnecting", swapPeer.ID().String(), s.params.DisconnectThreshold)
+	_, disconnectThreshold := s.Thresholds()
+	if balance >= disconnectThreshold && amount > 0 {
+		return fmt.Errorf("balance for peer %s is over the disconnect threshold %d and cannot incur more debt, disconnecting", swapPeer.ID().String(), disconnectThreshold)
 	}
 
 	return nil
@@ -322,8 +406,9 @@ func (s *Swap) Add(amount int64, peer *protocols.Peer) (err error) {
 // that the balance is *below* the threshold
 // the caller is expected to hold swapPeer.lock
 func (s *Swap) checkPaymentThresholdAndSendCheque(swapPeer *Peer) error {
-	if swapPeer.getBalance() <= -s.params.PaymentThreshold {
-		swapPeer.logger.Info(SendChequeAction, "balance for peer went over the payment threshold, sending cheque", "payment threshold", s.params.PaymentThreshold)
+	paymentThreshold, _ := s.Thresholds()
+	if swapPeer.getBalance() <= -paymentThreshold {
+		swapPeer.logger.Info(SendChequeAction, "balance for peer went over the payment threshold, sending cheque", "payment threshold", paymentThreshold)
 		return swapPeer.sendCheque()
 	}
 	return nil
@@ -367,15 +452,7 @@ func (s *Swap) handleEmitChequeMsg(ctx context.Context, p *Peer, msg *EmitCheque
 
 	p.logger.Debug(HandleChequeAction, "processed and verified received cheque", "beneficiary", cheque.Beneficiary, "cumulative payout", cheque.CumulativePayout)
 
-	// reset balance by amount
-	// as this is done by the creditor, receiving the cheque, the amount should be negative,
-	// so that updateBalance will calculate balance + amount which result in reducing the peer's balance
 	honeyAmount := int64(cheque.Honey)
-	err = p.updateBalance(-honeyAmount)
-	if err != nil {
-		return protocols.Break(fmt.Errorf("updating balance: %w", err))
-	}
-
 	metrics.GetOrRegisterCounter("swap/cheques/received/num", nil).Inc(1)
 	metrics.GetOrRegisterCounter("swap/cheques/received/honey", nil).Inc(honeyAmount)
 
@@ -397,6 +474,13 @@ func (s *Swap) handleEmitChequeMsg(ctx context.Context, p *Peer, msg *EmitCheque
 		return err
 	}
 
+	// cheques to a different beneficiary can only be cashed by the beneficiary itself,
+	// they are kept pending cashing for it (see PendingCashouts)
+	if cheque.Beneficiary != s.owner.address {
+		p.logger.Info(HandleChequeAction, "cheque issued to a different beneficiary pending cashing by the beneficiary", "beneficiary", cheque.Beneficiary)
+		return nil
+	}
+
 	// do a payout transaction if we get 2 times the gas costs
 	if expectedPayout.Cmp(costThreshold) == 1 {
 		go defaultCashCheque(s, cheque)
@@ -424,6 +508,11 @@ func (s *Swap) handleConfirmChequeMsg(ctx context.Context, p *Peer, msg *Confirm
 		return protocols.Break(fmt.Errorf("encoding cheque failed: %w", err))
 	}
 
+	err = batch.Put(beneficiarySentChequeKey(p.ID(), cheque.Beneficiary), cheque)
+	if err != nil {
+		return protocols.Break(fmt.Errorf("encoding cheque failed: %w", err))
+	}
+
 	err = batch.Put(pendingChequeKey(p.ID()), nil)
 	if err != nil {
 		return protocols.Break(fmt.Errorf("encoding pending cheque failed: %w", err))
@@ -452,18 +541,34 @@ func cashCheque(s *Swap, cheque *Cheque) {
 	if err != nil {
 		metrics.GetOrRegisterCounter("swap/cheques/cashed/errors", nil).Inc(1)
 		s.logger.Error(CashChequeAction, "cashing cheque:", err)
+		return
+	}
+
+	if err := s.removePendingCashout(cheque); err != nil {
+		s.logger.Error(CashChequeAction, "removing cashed cheque from pending cashouts:", err)
 	}
 }
 
 // processAndVerifyCheque verifies the cheque and compares it with the last received cheque
-// if the cheque is valid it will also be saved as the new last cheque
+// if the cheque is valid it is saved as the new last cheque and the balance of the peer is
+// reduced by its honey amount in a single write, so that the cheque cannot be replayed
+// after a restart once it was credited
 // the caller is expected to hold p.lock
 func (s *Swap) processAndVerifyCheque(cheque *Cheque, p *Peer) (*int256.Uint256, error) {
-	if err := cheque.verifyChequeProperties(p, s.owner.address); err != nil {
+	if err := cheque.verifyChequeProperties(p, s.beneficiaryFor(p)); err != nil {
+		return nil, err
+	}
+
+	lastCheque, err := p.getLastReceivedBeneficiaryCheque(cheque.Beneficiary)
+	if err != nil {
 		return nil, err
 	}
 
-	lastCheque := p.getLastReceivedCheque()
+	// the cumulative payout serves as the serial of the cheques of a beneficiary
+	if lastCheque != nil && cheque.CumulativePayout.Cmp(lastCheque.CumulativePayout) < 1 {
+		metrics.GetOrRegisterCounter("swap/cheques/received/replayed", nil).Inc(1)
+		return nil, fmt.Errorf("%w: cumulative payout %v, last received %v", ErrChequeReplayed, cheque.CumulativePayout, lastCheque.CumulativePayout)
+	}
 
 	// TODO: there should probably be a lock here?
 	expectedAmount, err := s.honeyPriceOracle.GetPrice(cheque.Honey)
@@ -483,9 +588,8 @@ func (s *Swap) processAndVerifyCheque(cheque *Cheque, p *Peer) (*int256.Uint256,
 		return nil, fmt.Errorf("received cheque would result in balance %d which exceeds tolerance %d and would cause debt", newBalance, ChequeDebtTolerance)
 	}
 
-	if err := p.setLastReceivedCheque(cheque); err != nil {
-		p.logger.Error(HandleChequeAction, "error while saving last received cheque", "err", err.Error())
-		// TODO: what do we do here? Related issue: https://github.com/ethersphere/swarm/issues/1515
+	if err := p.receiveCheque(cheque, newBalance); err != nil {
+		return nil, fmt.Errorf("saving received cheque: %w", err)
 	}
 
 	return actualAmount, nil
@@ -543,14 +647,86 @@ func (s *Swap) loadBalance(p enode.ID) (balance int64, err error) {
 	return balance, nil
 }
 
+// loadBeneficiaryCheque loads the last cheque of the chain of a beneficiary stored under key
+// and returns nil when there never was a cheque saved for the beneficiary
+func (s *Swap) loadBeneficiaryCheque(key string) (cheque *Cheque, err error) {
+	err = s.store.Get(key, &cheque)
+	if err == state.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cheque, nil
+}
+
 // saveLastReceivedCheque saves cheque as the last received cheque for peer
+// and as the last cheque received for its beneficiary
 func (s *Swap) saveLastReceivedCheque(p enode.ID, cheque *Cheque) error {
-	return s.store.Put(receivedChequeKey(p), cheque)
+	return s.saveCheque(receivedChequeKey(p), beneficiaryReceivedChequeKey, p, cheque)
 }
 
-// saveLastSentCheque saves cheque as the last received cheque for peer
+// saveLastSentCheque saves cheque as the last sent cheque for peer
+// and as the last cheque sent to its beneficiary
 func (s *Swap) saveLastSentCheque(p enode.ID, cheque *Cheque) error {
-	return s.store.Put(sentChequeKey(p), cheque)
+	return s.saveCheque(sentChequeKey(p), beneficiarySentChequeKey, p, cheque)
+}
+
+// saveCheque saves cheque under key and in the chain of its beneficiary
+func (s *Swap) saveCheque(key string, beneficiaryKey func(enode.ID, common.Address) string, p enode.ID, cheque *Cheque) error {
+	batch := new(state.StoreBatch)
+	if err := batch.Put(key, cheque); err != nil {
+		return err
+	}
+	if cheque != nil {
+		if err := batch.Put(beneficiaryKey(p, cheque.Beneficiary), cheque); err != nil {
+			return err
+		}
+	}
+	return s.store.WriteBatch(batch)
+}
+
+// saveReceivedCheque saves cheque as the last received cheque for peer together with the
+// new balance of the peer, and as pending cashing
+func (s *Swap) saveReceivedCheque(p enode.ID, cheque *Cheque, balance int64) error {
+	s.cashoutLock.Lock()
+	defer s.cashoutLock.Unlock()
+
+	batch := new(state.StoreBatch)
+	if err := batch.Put(receivedChequeKey(p), cheque); err != nil {
+		return err
+	}
+	if err := batch.Put(beneficiaryReceivedChequeKey(p, cheque.Beneficiary), cheque); err != nil {
+		return err
+	}
+	if err := batch.Put(balanceKey(p), balance); err != nil {
+		return err
+	}
+	// cheques to a different beneficiary are kept pending too, so that it can cash them
+	if err := batch.Put(pendingCashoutKey(cheque.Contract), cheque); err != nil {
+		return err
+	}
+	return s.store.WriteBatch(batch)
+}
+
+// removePendingCashout removes the cheque pending cashing for its chequebook
+// unless a cheque with a higher cumulative payout was received in the meantime
+func (s *Swap) removePendingCashout(cheque *Cheque) error {
+	s.cashoutLock.Lock()
+	defer s.cashoutLock.Unlock()
+
+	var pending *Cheque
+	err := s.store.Get(pendingCashoutKey(cheque.Contract), &pending)
+	if err == state.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pending != nil && pending.CumulativePayout.Cmp(cheque.CumulativePayout) > 0 {
+		return nil
+	}
+	return s.store.Delete(pendingCashoutKey(cheque.Contract))
 }
 
 // savePendingCheque saves cheque as the last pending cheque for peer
@@ -573,6 +749,23 @@ func (s *Swap) GetParams() *contract.Params {
 	return s.contract.ContractParams()
 }
 
+// beneficiary returns the address cheques for this node are to be issued to
+func (s *Swap) beneficiary() common.Address {
+	if (s.params.Beneficiary != common.Address{}) {
+		return s.params.Beneficiary
+	}
+	return s.owner.address
+}
+
+// beneficiaryFor returns the address the cheques of the peer for this node are to be
+// issued to, the owner address if the beneficiary was not announced to the peer
+func (s *Swap) beneficiaryFor(p *Peer) common.Address {
+	if !announcesBeneficiary(p.Peer) {
+		return s.owner.address
+	}
+	return s.beneficiary()
+}
+
 // getContractOwner retrieve the owner of the chequebook at address from the blockchain
 func (s *Swap) getContractOwner(ctx context.Context, address common.Address) (common.Address, error) {
 	contr, err := contract.InstanceAt(address, s.backend)
@@ -656,15 +849,39 @@ func (s *Swap) bindToContractAt(address common.Address) (contract.Contract, erro
 }
 
 // Deploy deploys the Swap contract
+// It blocks until the deployment is confirmed, the deployment is sent again if its transaction is dropped
 func (s *Swap) Deploy(ctx context.Context) (contract.Contract, error) {
 	opts := bind.NewKeyedTransactor(s.owner.privateKey)
 	opts.Context = ctx
 	s.logger.Info(DeployChequebookAction, "Deploying new swap", "owner", opts.From.Hex())
-	chequebook, err := s.chequebookFactory.DeploySimpleSwap(opts, s.owner.address, big.NewInt(int64(defaultHarddepositTimeoutDuration)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to deploy chequebook: %w", err)
+	var dropped common.Hash
+	for attempt := 0; ; attempt++ {
+		tx, err := s.chequebookFactory.SendDeploySimpleSwap(opts, s.owner.address, big.NewInt(int64(defaultHarddepositTimeoutDuration)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to deploy chequebook: %w", err)
+		}
+		if (dropped != common.Hash{}) {
+			s.transactions.replaced(dropped, tx.Hash())
+		}
+		receipt, err := s.transactions.wait(ctx, tx.Hash(), DeployChequebookAction)
+		if err == chain.ErrTransactionDropped && attempt < MaxTransactionResubmissions {
+			s.logger.Warn(DeployChequebookAction, "chequebook deployment dropped, sending it again", "tx", tx.Hash())
+			dropped = tx.Hash()
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to deploy chequebook: %w", err)
+		}
+		chequebook, err := s.chequebookFactory.DeployedSimpleSwap(receipt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deploy chequebook: %w", err)
+		}
+		// the factory only knows the chequebook if the deployment is part of the canonical chain
+		if err := s.chequebookFactory.VerifyContract(chequebook.ContractParams().ContractAddress); err != nil {
+			return nil, fmt.Errorf("failed to deploy chequebook: %w", err)
+		}
+		return chequebook, nil
 	}
-	return chequebook, nil
 }
 
 // Deposit deposits ERC20 into the chequebook contract
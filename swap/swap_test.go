@@ -23,6 +23,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -1240,6 +1241,106 @@ func TestPeerProcessAndVerifyChequeInvalid(t *testing.T) {
 	}
 }
 
+// TestChequeReplayAfterRestart tests that a received cheque is persisted together with the
+// balance change, so that it is rejected if it is replayed after a restart, and that it is
+// listed as pending cashing until it was cashed
+func TestChequeReplayAfterRestart(t *testing.T) {
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+
+	swap, testDir := newBaseTestSwap(t, ownerKey, testBackend)
+	defer os.RemoveAll(testDir)
+
+	dummy := newDummyPeer()
+	peer, err := swap.addPeer(dummy.Peer, ownerAddress, testChequeContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swap.owner.address = beneficiaryAddress
+
+	cheque := newTestCheque()
+	cheque.Signature, _ = cheque.Sign(ownerKey)
+	if _, err := swap.processAndVerifyCheque(cheque, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart with the persisted state
+	if err := swap.store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	swap.store, err = state.NewDBStore(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer swap.store.Close()
+	swap.peers = make(map[enode.ID]*Peer)
+	peer, err = swap.addPeer(dummy.Peer, ownerAddress, testChequeContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance := peer.getBalance(); balance != -int64(cheque.Honey) {
+		t.Fatalf("expected balance %d after restart, got %d", -int64(cheque.Honey), balance)
+	}
+	if _, err := swap.processAndVerifyCheque(cheque, peer); !errors.Is(err, ErrChequeReplayed) {
+		t.Fatalf("expected error %v, got %v", ErrChequeReplayed, err)
+	}
+	if balance := peer.getBalance(); balance != -int64(cheque.Honey) {
+		t.Fatalf("expected balance %d after replay, got %d", -int64(cheque.Honey), balance)
+	}
+
+	pending, err := swap.PendingCashouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || !pending[cheque.Contract].Equal(cheque) {
+		t.Fatalf("expected cheque %v pending cashing, got %v", cheque, pending)
+	}
+
+	if err := swap.removePendingCashout(cheque); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = swap.PendingCashouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no cheques pending cashing, got %v", pending)
+	}
+}
+
+// TestChequeToBeneficiaryPending tests that the cheques issued to a beneficiary configured
+// in place of the owner are accepted and kept pending cashing by the beneficiary
+func TestChequeToBeneficiaryPending(t *testing.T) {
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+
+	swap, testDir := newBaseTestSwap(t, ownerKey, testBackend)
+	defer os.RemoveAll(testDir)
+	swap.params.Beneficiary = beneficiaryAddress
+
+	peer, err := swap.addPeer(newDummyPeerWithSpec(Spec).Peer, ownerAddress, testChequeContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheque := newTestCheque()
+	cheque.Signature, _ = cheque.Sign(ownerKey)
+	if cheque.Beneficiary == swap.owner.address {
+		t.Fatal("expected cheque to a beneficiary other than the owner")
+	}
+	if _, err := swap.processAndVerifyCheque(cheque, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := swap.PendingCashouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || !pending[cheque.Contract].Equal(cheque) {
+		t.Fatalf("expected cheque %v pending cashing, got %v", cheque, pending)
+	}
+}
+
 func TestSwapLogToFile(t *testing.T) {
 	// create a log dir
 	logDirDebitor, err := ioutil.TempDir("", "swap_test_log")
@@ -1414,17 +1515,88 @@ func TestPeerGetLastSentCumulativePayout(t *testing.T) {
 	_, peer, clean := newTestSwapAndPeer(t, ownerKey)
 	defer clean()
 
-	if !peer.getLastSentCumulativePayout().Equals(int256.Uint256From(0)) {
-		t.Fatalf("last cumulative payout should be 0 in the beginning, was %v", peer.getLastSentCumulativePayout())
+	payout, err := peer.getLastSentCumulativePayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payout.Equals(int256.Uint256From(0)) {
+		t.Fatalf("last cumulative payout should be 0 in the beginning, was %v", payout)
 	}
 
 	cheque := newTestCheque()
+	cheque.Beneficiary = peer.beneficiary
 	if err := peer.setLastSentCheque(cheque); err != nil {
 		t.Fatal(err)
 	}
 
-	if peer.getLastSentCumulativePayout() != cheque.CumulativePayout {
-		t.Fatalf("last cumulative payout should be the payout of the last sent cheque, was: %v, expected %v", peer.getLastSentCumulativePayout(), cheque.CumulativePayout)
+	payout, err = peer.getLastSentCumulativePayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payout != cheque.CumulativePayout {
+		t.Fatalf("last cumulative payout should be the payout of the last sent cheque, was: %v, expected %v", payout, cheque.CumulativePayout)
+	}
+}
+
+// TestPeerChangeBeneficiary tests that cumulative payouts are tracked per beneficiary
+// when a peer rotates its beneficiary and that a pending cheque to the previous beneficiary is dropped
+func TestPeerChangeBeneficiary(t *testing.T) {
+	_, peer, clean := newTestSwapAndPeer(t, ownerKey)
+	defer clean()
+
+	previous := peer.beneficiary
+	cheque := newTestCheque()
+	cheque.Beneficiary = previous
+	if err := peer.setLastSentCheque(cheque); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := newTestCheque()
+	pending.Beneficiary = previous
+	pending.CumulativePayout = int256.Uint256From(84)
+	if err := peer.setPendingCheque(pending); err != nil {
+		t.Fatal(err)
+	}
+	// the amount of the pending cheque was already credited to the balance
+	if err := peer.setBalance(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new beneficiary starts a new chain
+	if err := peer.setBeneficiary(beneficiaryAddress); err != nil {
+		t.Fatal(err)
+	}
+	payout, err := peer.getLastSentCumulativePayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payout.Equals(int256.Uint256From(0)) {
+		t.Fatalf("last cumulative payout for new beneficiary should be 0, was %v", payout)
+	}
+	if peer.getPendingCheque() != nil {
+		t.Fatalf("expected pending cheque to previous beneficiary to be dropped, got %v", peer.getPendingCheque())
+	}
+	if peer.getBalance() != -int64(pending.Honey) {
+		t.Fatalf("expected balance %d, got %d", -int64(pending.Honey), peer.getBalance())
+	}
+
+	other := newTestCheque()
+	other.Beneficiary = beneficiaryAddress
+	other.CumulativePayout = int256.Uint256From(10)
+	if err := peer.setLastSentCheque(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotating back continues the chain of the previous beneficiary
+	if err := peer.setBeneficiary(previous); err != nil {
+		t.Fatal(err)
+	}
+	payout, err = peer.getLastSentCumulativePayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !payout.Equals(cheque.CumulativePayout) {
+		t.Fatalf("last cumulative payout for previous beneficiary should be %v, was %v", cheque.CumulativePayout, payout)
 	}
 }
 
@@ -0,0 +1,156 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethersphere/swarm/state"
+	"github.com/ethersphere/swarm/swap/chain"
+)
+
+// TransactionState is the state of a transaction sent by swap
+type TransactionState string
+
+const (
+	// TransactionPending is the state of a transaction not included in a block yet
+	TransactionPending TransactionState = "pending"
+	// TransactionMined is the state of a transaction included in a block with fewer than the required confirmations
+	TransactionMined TransactionState = "mined"
+	// TransactionReorged is the state of a transaction whose block was reorganised out of the chain,
+	// waiting to be included again
+	TransactionReorged TransactionState = "reorged"
+	// TransactionConfirmed is the state of a transaction included in a block with the required confirmations
+	TransactionConfirmed TransactionState = "confirmed"
+	// TransactionFailed is the state of a transaction that was reverted, dropped or timed out
+	TransactionFailed TransactionState = "failed"
+)
+
+// TransactionStatus is the status of a transaction sent by swap, as tracked until it is confirmed
+type TransactionStatus struct {
+	Hash          common.Hash
+	Action        string // swap action the transaction was sent for, DeployChequebookAction or CashChequeAction
+	State         TransactionState
+	BlockNumber   uint64      // number of the including block, zero if not included
+	BlockHash     common.Hash // hash of the including block, zero if not included
+	Confirmations uint64      // confirmations of the including block, the including block counting as the first one
+	ReplacedBy    common.Hash // transaction sent again in place of this one after it was dropped, if any
+	Error         string      // reason of the failure of the transaction
+	Updated       time.Time
+}
+
+// transactionTracker waits for the transactions sent by swap to be confirmed
+// and keeps their status in the state store
+type transactionTracker struct {
+	backend       chain.Backend
+	store         state.Store // store of the transaction status, nil if the status is not kept
+	confirmations uint64      // confirmations after which transactions are considered final
+	logger        Logger
+}
+
+func transactionKey(hash common.Hash) string {
+	return transactionPrefix + hash.Hex()
+}
+
+// wait blocks until the transaction is confirmed, reorganisations of the chain
+// resetting the confirmations of the transaction
+func (t *transactionTracker) wait(ctx context.Context, hash common.Hash, action string) (*types.Receipt, error) {
+	status := &TransactionStatus{
+		Hash:   hash,
+		Action: action,
+		State:  TransactionPending,
+	}
+	t.save(status)
+
+	receipt, err := chain.WaitConfirmed(ctx, t.backend, hash, t.confirmations, func(receipt *types.Receipt, confirmations uint64) {
+		if receipt == nil {
+			t.logger.Warn(action, "transaction reorganised out of the chain", "tx", hash, "block", status.BlockHash)
+			status.State = TransactionReorged
+			status.BlockNumber = 0
+			status.BlockHash = common.Hash{}
+			status.Confirmations = 0
+		} else {
+			status.State = TransactionMined
+			status.BlockNumber = receipt.BlockNumber.Uint64()
+			status.BlockHash = receipt.BlockHash
+			status.Confirmations = confirmations
+		}
+		t.save(status)
+	})
+	if err != nil {
+		status.State = TransactionFailed
+		status.Error = err.Error()
+	} else {
+		status.State = TransactionConfirmed
+	}
+	t.save(status)
+	return receipt, err
+}
+
+// replaced records that the transaction was sent again with a new hash after it was dropped
+func (t *transactionTracker) replaced(hash common.Hash, by common.Hash) {
+	if t.store == nil {
+		return
+	}
+	var status TransactionStatus
+	if err := t.store.Get(transactionKey(hash), &status); err != nil {
+		t.logger.Error(status.Action, "loading transaction status:", err, "tx", hash)
+		return
+	}
+	status.ReplacedBy = by
+	t.save(&status)
+}
+
+func (t *transactionTracker) save(status *TransactionStatus) {
+	if t.store == nil {
+		return
+	}
+	status.Updated = time.Now()
+	if err := t.store.Put(transactionKey(status.Hash), status); err != nil {
+		t.logger.Error(status.Action, "saving transaction status:", err, "tx", status.Hash)
+	}
+}
+
+// Transactions returns the status of the transactions sent by swap to deploy the chequebook and cash cheques
+func (s *Swap) Transactions() (map[common.Hash]*TransactionStatus, error) {
+	transactions := make(map[common.Hash]*TransactionStatus)
+	err := s.store.Iterate(transactionPrefix, func(key []byte, value []byte) (stop bool, err error) {
+		var status TransactionStatus
+		if err := json.Unmarshal(value, &status); err != nil {
+			return true, err
+		}
+		transactions[status.Hash] = &status
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Transaction returns the status of the transaction with the given hash sent by swap
+func (s *Swap) Transaction(hash common.Hash) (*TransactionStatus, error) {
+	var status TransactionStatus
+	if err := s.store.Get(transactionKey(hash), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
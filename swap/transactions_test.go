@@ -0,0 +1,58 @@
+// Copyright 2020 The Swarm Authors
+// This file is part of the Swarm library.
+//
+// The Swarm library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Swarm library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Swarm library. If not, see <http://www.gnu.org/licenses/>.
+
+package swap
+
+import (
+	"context"
+	"testing"
+)
+
+// TestDeployTransactionStatus tests that the status of the transaction deploying
+// the chequebook is kept until it is confirmed
+func TestDeployTransactionStatus(t *testing.T) {
+	testBackend := newTestBackend(t)
+	defer testBackend.Close()
+	swap, clean := newTestSwap(t, ownerKey, testBackend)
+	defer clean()
+
+	if _, err := swap.Deploy(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	transactions, err := swap.Transactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(transactions))
+	}
+	for hash, status := range transactions {
+		if status.Action != DeployChequebookAction || status.State != TransactionConfirmed {
+			t.Fatalf("expected confirmed %s transaction, got %s %s", DeployChequebookAction, status.State, status.Action)
+		}
+		if status.BlockNumber == 0 || status.Confirmations == 0 {
+			t.Fatalf("expected transaction to be included in a block, got block %d with %d confirmations", status.BlockNumber, status.Confirmations)
+		}
+		stored, err := swap.Transaction(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.BlockHash != status.BlockHash {
+			t.Fatalf("expected transaction in block %v, got %v", status.BlockHash, stored.BlockHash)
+		}
+	}
+}
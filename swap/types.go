@@ -17,7 +17,10 @@
 package swap
 
 import (
+	"io"
+
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethersphere/swarm/swap/int256"
 )
 
@@ -39,6 +42,45 @@ type Cheque struct {
 type HandshakeMsg struct {
 	ChainID         uint64         // chain id of the blockchain the peer is connected to
 	ContractAddress common.Address // chequebook contract address of the peer
+	Beneficiary     common.Address // address cheques for the peer are to be issued to, the chequebook owner if empty, always empty with peers running versions before beneficiaryVersion
+}
+
+// handshakeMsgRLP is the encoding of handshake messages
+// The beneficiary is a list tail so that it is not encoded at all if empty, and
+// the handshakes exchanged with peers running versions before beneficiaryVersion,
+// which leave it empty, are encoded as in those versions
+type handshakeMsgRLP struct {
+	ChainID         uint64
+	ContractAddress common.Address
+	Beneficiary     []common.Address `rlp:"tail"`
+}
+
+// EncodeRLP implements rlp.Encoder
+func (m *HandshakeMsg) EncodeRLP(w io.Writer) error {
+	enc := handshakeMsgRLP{
+		ChainID:         m.ChainID,
+		ContractAddress: m.ContractAddress,
+	}
+	if (m.Beneficiary != common.Address{}) {
+		enc.Beneficiary = []common.Address{m.Beneficiary}
+	}
+	return rlp.Encode(w, &enc)
+}
+
+// DecodeRLP implements rlp.Decoder
+func (m *HandshakeMsg) DecodeRLP(s *rlp.Stream) error {
+	var dec handshakeMsgRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	*m = HandshakeMsg{
+		ChainID:         dec.ChainID,
+		ContractAddress: dec.ContractAddress,
+	}
+	if len(dec.Beneficiary) > 0 {
+		m.Beneficiary = dec.Beneficiary[0]
+	}
+	return nil
 }
 
 // EmitChequeMsg is sent from the debitor to the creditor with the actual cheque
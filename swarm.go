@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -35,19 +36,25 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rpc"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
 	"github.com/ethersphere/swarm/api"
 	httpapi "github.com/ethersphere/swarm/api/http"
 	"github.com/ethersphere/swarm/bzzeth"
 	"github.com/ethersphere/swarm/chunk"
 	"github.com/ethersphere/swarm/contracts/ens"
 	"github.com/ethersphere/swarm/fuse"
+	"github.com/ethersphere/swarm/internal/debug"
 	"github.com/ethersphere/swarm/log"
 	"github.com/ethersphere/swarm/network"
 	"github.com/ethersphere/swarm/network/retrieval"
 	"github.com/ethersphere/swarm/network/stream"
+	"github.com/ethersphere/swarm/network/timeouts"
 	"github.com/ethersphere/swarm/p2p/protocols"
 	"github.com/ethersphere/swarm/pss"
+	"github.com/ethersphere/swarm/pss/bridge"
+	"github.com/ethersphere/swarm/pss/mailbox"
 	pssmessage "github.com/ethersphere/swarm/pss/message"
 	"github.com/ethersphere/swarm/pushsync"
 	"github.com/ethersphere/swarm/state"
@@ -69,6 +76,9 @@ var (
 	uptimeGauge        = metrics.NewRegisteredGauge("stack/uptime", nil)
 )
 
+// cloneStaticPeerRole is the static peer role the clone source is kept connected with
+const cloneStaticPeerRole = "clone"
+
 // Swarm abstracts the complete Swarm stack
 type Swarm struct {
 	config            *api.Config        // swarm configuration
@@ -84,15 +94,25 @@ type Swarm struct {
 	netStore          *storage.NetStore
 	sfs               *fuse.SwarmFS // need this to cleanup all the active mounts on node exit
 	ps                *pss.Pss
+	whisper           *whisper.Whisper // whisper node of the pss bridge, nil if the bridge has no routes
+	bridge            *bridge.Bridge   // relays messages between whisper and pss, nil if it has no routes
 	pushSync          *pushsync.Pusher
 	storer            *pushsync.Storer
+	replicator        *pushsync.Replicator
 	swap              *swap.Swap
 	stateStore        *state.DBStore
 	tags              *chunk.Tags
 	accountingMetrics *protocols.AccountingMetrics
 	cleanupFuncs      []func() error
-	pinAPI            *pin.API // API object implements all pinning related commands
+	pinAPI            *pin.API         // API object implements all pinning related commands
+	pinRepairer       *pin.Repairer    // re-pushes pinned and uploaded chunks missing from their neighbourhood
+	apiKeys           *httpapi.APIKeys // API keys of the HTTP gateway, nil if not enabled
 	inspector         *api.Inspector
+	localStore        *localstore.DB
+	configLoader      func() (*api.Config, error) // loads the config on reload, nil if reloading is not supported
+	configChanges     []ConfigChange              // latest config changes applied to the node
+	reloadLock        sync.Mutex                  // serializes config reloads
+	configMu          sync.RWMutex                // protects the replacement of the config by reloads
 
 	tracerClose io.Closer
 }
@@ -117,12 +137,13 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	log.Debug("Setting up Swarm service components")
 
 	bzzconfig := &network.BzzConfig{
-		NetworkID:    config.NetworkID,
-		Address:      network.NewBzzAddr(common.FromHex(config.BzzKey), []byte(config.Enode.URLv4())),
-		HiveParams:   config.HiveParams,
-		LightNode:    config.LightNodeEnabled,
-		BootnodeMode: config.BootnodeMode,
-		SyncEnabled:  config.SyncEnabled,
+		NetworkID:     config.NetworkID,
+		Address:       network.NewBzzAddr(common.FromHex(config.BzzKey), []byte(config.Enode.URLv4())),
+		HiveParams:    config.HiveParams,
+		LightNode:     config.LightNodeEnabled,
+		RetrieveRelay: config.RetrieveRelay,
+		BootnodeMode:  config.BootnodeMode,
+		SyncEnabled:   config.SyncEnabled,
 	}
 
 	// Swap initialization
@@ -137,6 +158,8 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 			LogLevel:            self.config.SwapLogLevel,
 			DisconnectThreshold: int64(self.config.SwapDisconnectThreshold),
 			PaymentThreshold:    int64(self.config.SwapPaymentThreshold),
+			Confirmations:       self.config.SwapConfirmations,
+			Beneficiary:         self.config.SwapBeneficiary,
 		}
 
 		// create the accounting objects
@@ -167,6 +190,14 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	if err != nil {
 		return
 	}
+	if err := self.loadConfigChanges(); err != nil {
+		return nil, err
+	}
+	if config.LogVmodule != "" {
+		if err := debug.Handler.Vmodule(config.LogVmodule); err != nil {
+			return nil, fmt.Errorf("log vmodule: %v", err)
+		}
+	}
 
 	// set up high level api
 	var resolver *api.MultiResolver
@@ -232,6 +263,7 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	if err != nil {
 		return nil, err
 	}
+	self.localStore = localStore
 	lstore := chunk.NewValidatorStore(
 		localStore,
 		storage.NewContentAddressValidator(storage.MakeHashFunc(storage.DefaultHash)),
@@ -239,8 +271,36 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	)
 
 	self.netStore = storage.NewNetStore(lstore, bzzconfig.Address)
-	self.retrieval = retrieval.New(to, self.netStore, bzzconfig.Address, self.swap)
+	self.retrieval = retrieval.New(to, self.netStore, bzzconfig.Address, self.swap, self.stateStore)
+	self.retrieval.SetFreeQuota(config.SwapFreeChunks, config.SwapFreeBytes, config.SwapFreeWindow)
+	self.retrieval.SetBandwidth(config.RetrieveBandwidth)
+	self.retrieval.SetMaxHops(config.RetrieveMaxHops)
+	self.retrieval.SetFarFetchedPolicy(config.RetrieveFarFetched, config.RetrieveFarSlack)
+	if config.RetrieveChunkPrice > 0 || config.RetrieveFreeNeighbours {
+		price := config.RetrieveChunkPrice
+		if price == 0 {
+			price = swap.DefaultChunkPrice
+		}
+		pricer := retrieval.FixedPrice(price)
+		if config.RetrieveFreeNeighbours {
+			pricer = retrieval.FreeForNeighbours(to, pricer)
+		}
+		self.retrieval.SetPricer(pricer)
+	}
+	self.retrieval.SetMaxChunkPrice(config.RetrieveMaxChunkPrice)
+	var strategies []retrieval.ForwardStrategy
+	if config.RetrieveByLatency {
+		// among equally close peers, forward retrieve requests to the fastest first
+		strategies = append(strategies, retrieval.LatencyStrategy())
+	}
+	if self.swap != nil {
+		// forward retrieve requests to the peers that owe the node service first
+		strategies = append(strategies, retrieval.BalanceStrategy(self.swap))
+	}
+	self.retrieval.SetForwardStrategy(retrieval.CombineStrategies(strategies...))
 	self.netStore.RemoteGet = self.retrieval.RequestFromPeers
+	self.netStore.StorageRadius = localStore.StorageRadius
+	self.netStore.FailedFetchTTL = timeouts.FailedFetchTTL
 
 	feedsHandler.SetStore(self.netStore)
 
@@ -250,7 +310,40 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	}
 
 	syncProvider := stream.NewSyncProvider(self.netStore, to, bzzconfig.Address, syncing, false)
-	self.streamer = stream.New(self.stateStore, bzzconfig.Address, syncProvider)
+
+	// clone streams are provided only to import chunks from the clone source
+	// or to serve the local store to the nodes allowed to clone it
+	var cloneSource enode.ID
+	if config.CloneSource != "" {
+		source, err := enode.ParseV4(config.CloneSource)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clone source %q: %v", config.CloneSource, err)
+		}
+		cloneSource = source.ID()
+		// keep the clone source connected regardless of kademlia
+		if config.HiveParams.StaticPeers == nil {
+			config.HiveParams.StaticPeers = make(map[string][]string)
+		}
+		config.HiveParams.StaticPeers[cloneStaticPeerRole] = append(config.HiveParams.StaticPeers[cloneStaticPeerRole], config.CloneSource)
+		log.Info("Clone mode", "source", cloneSource, "bins", config.CloneBins)
+	}
+	var cloneServe []enode.ID
+	for _, url := range config.CloneServe {
+		n, err := enode.ParseV4(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clone serve node %q: %v", url, err)
+		}
+		cloneServe = append(cloneServe, n.ID())
+	}
+	providers := []stream.StreamProvider{syncProvider}
+	if cloneSource != (enode.ID{}) || len(cloneServe) > 0 {
+		providers = append(providers, stream.NewCloneProvider(self.netStore, bzzconfig.Address, cloneSource, cloneServe, config.CloneBins...))
+	}
+	self.streamer = stream.New(self.stateStore, bzzconfig.Address, providers...)
+	if config.SyncUpdateDelay > 0 {
+		self.streamer.SetBatchTimeout(config.SyncUpdateDelay)
+	}
+	self.streamer.SetBandwidth(config.SyncBandwidth)
 
 	// Swarm Hash Merklised Chunking for Arbitrary-length Document/File storage
 	lnetStore := storage.NewLNetStore(self.netStore)
@@ -268,21 +361,81 @@ func NewSwarm(config *api.Config, mockStore *mock.NodeStore) (self *Swarm, err e
 	if pss.IsActiveHandshake {
 		pss.SetHandshakeController(self.ps, pss.NewHandshakeParams())
 	}
+	// messages for offline recipients are deposited in swarm and referenced from feeds of this node,
+	// which are signed with the private key, so there is no mailbox without it
+	mailboxStore := mailbox.NewFileStore(self.fileStore)
+	mailboxState := self.stateStore.Namespace("pss-mailbox")
+	if config.PssMailbox && self.privateKey != nil {
+		mailboxFeeds := mailbox.NewFeeds(feedsHandler, feed.NewGenericSigner(self.privateKey))
+		self.ps.SetMailbox(mailbox.New(mailboxStore, mailboxFeeds, mailboxState))
+	}
+	// the messages deposited for this node in the mailboxes of other nodes
+	// are fetched whether or not this node deposits messages for others
+	self.ps.SetInboxes(mailboxStore, mailbox.NewFeeds(feedsHandler, nil), mailboxState)
+	// payloads too large for a pss envelope are stored in swarm and only their references are sent
+	self.ps.SetContentStore(pss.NewSwarmContentStore(lnetStore, localStore, self.config.FileStoreParams))
+	// the bridge relays messages with a whisper node run by swarm, which
+	// the dapps still on whisper can use over the shh RPC API
+	if config.PssBridge != nil && len(config.PssBridge.Routes) > 0 {
+		self.whisper = whisper.New(&whisper.DefaultConfig)
+		self.bridge, err = bridge.New(self.whisper, self.ps, config.PssBridge)
+		if err != nil {
+			return nil, fmt.Errorf("pss bridge: %v", err)
+		}
+	}
 
 	if config.PushSyncEnabled {
 		// expire time for push-sync messages should be lower than regular chat-like messages to avoid network flooding
 		pubsub := pss.NewPubSub(self.ps, 20*time.Second)
 		self.pushSync = pushsync.NewPusher(localStore, pubsub, self.tags)
 		self.storer = pushsync.NewStorer(self.netStore, pubsub)
+		if config.PushSyncReplicas > 1 {
+			self.replicator = pushsync.NewReplicator(lstore, pubsub, pubsub, config.PushSyncReplicas)
+			self.storer.SetReplicator(self.replicator)
+		}
 	}
 
 	self.api = api.NewAPI(self.fileStore, self.dns, self.rns, feedsHandler, self.privateKey, self.tags)
+	if config.GatewayMode {
+		log.Info("Swarm running in read-only gateway mode, uploads and updates are disabled")
+		self.api.SetReadOnly(true)
+	}
+	self.api.SetInlineSize(config.ManifestInlineSize)
+
+	if config.EnableAPIKeys {
+		self.apiKeys = httpapi.NewAPIKeys(self.stateStore.Namespace("apikeys"))
+	}
 
 	if config.EnablePinning {
 		// Instantiate the pinAPI object with the already opened localstore
 		self.pinAPI = pin.NewAPI(localStore, self.stateStore, self.config.FileStoreParams, self.tags, self.api)
+		self.pinAPI.SetWithinDepth(to.IsWithinDepth)
+		if config.PinRepairInterval > 0 {
+			self.pinRepairer = pin.NewRepairer(self.pinAPI, self.netStore.Probe, &pin.RepairParams{
+				Interval:     config.PinRepairInterval,
+				SampleSize:   config.PinRepairSampleSize,
+				ProbeTimeout: config.PinRepairProbeTimeout,
+			})
+		}
 	}
 	self.sfs = fuse.NewSwarmFS(self.api)
+	if config.SwarmFSCheckpointInterval > 0 || config.SwarmFSCheckpointBytes > 0 {
+		self.sfs.SetCheckpointParams(&fuse.CheckpointParams{
+			Dir:        filepath.Join(config.Path, "swarmfs"),
+			Interval:   config.SwarmFSCheckpointInterval,
+			DirtyBytes: config.SwarmFSCheckpointBytes,
+		})
+	}
+	if config.SwarmFSTrash {
+		self.sfs.SetTrash(true)
+	}
+	self.sfs.SetUmask(config.SwarmFSUmask)
+	if config.SwarmFSReadahead != 0 || config.SwarmFSReadCacheSize != 0 {
+		self.sfs.SetReadCacheParams(&fuse.ReadCacheParams{
+			Readahead: config.SwarmFSReadahead,
+			Size:      config.SwarmFSReadCacheSize,
+		})
+	}
 	log.Debug("Initialized FUSE filesystem")
 	self.inspector = api.NewInspector(self.api, self.bzz.Hive, self.netStore, self.streamer, localStore)
 
@@ -386,11 +539,45 @@ func (s *Swarm) Start(srv *p2p.Server) error {
 
 	if s.ps != nil {
 		s.ps.Start(srv)
+		// messages deposited while the node was offline are fetched
+		// as it connects to the network again
+		for _, owner := range s.config.PssMailboxOwners {
+			stop := s.ps.WatchMailbox(owner, pss.DefaultMailboxFetchInterval)
+			s.cleanupFuncs = append(s.cleanupFuncs, func() error {
+				stop()
+				return nil
+			})
+		}
+	}
+	if s.bridge != nil {
+		if err := s.whisper.Start(srv); err != nil {
+			return err
+		}
+		if err := s.bridge.Start(srv); err != nil {
+			return err
+		}
+	}
+	if s.pinRepairer != nil {
+		s.pinRepairer.Start()
 	}
 	// start swarm http proxy server
 	if s.config.Port != "" {
 		addr := net.JoinHostPort(s.config.ListenAddr, s.config.Port)
 		server := httpapi.NewServer(s.api, s.pinAPI, s.config.Cors)
+		if s.apiKeys != nil {
+			log.Info("Swarm HTTP proxy requires API keys")
+			server.SetAPIKeys(s.apiKeys)
+		}
+		if s.config.WebSocketPss && s.ps != nil {
+			log.Info("Swarm HTTP proxy serves pss subscriptions over WebSocket")
+			if err := server.RegisterWebSocketAPI("pss", pss.NewReceiveAPI(s.ps)); err != nil {
+				return err
+			}
+		}
+		// images are served resized with the resize transformer, up to 4096x4096 pixels
+		if err := server.RegisterTransformer("resize", &httpapi.ImageResizer{MaxPixels: 4096 * 4096}); err != nil {
+			return err
+		}
 
 		if s.config.Cors != "" {
 			log.Info("Swarm HTTP proxy CORS headers", "allowedOrigins", s.config.Cors)
@@ -450,10 +637,17 @@ func (s *Swarm) Stop() error {
 		}
 	}
 
+	if s.pinRepairer != nil {
+		s.pinRepairer.Stop()
+	}
 	if s.pushSync != nil {
 		s.pushSync.Close()
 	}
 
+	if s.bridge != nil {
+		s.bridge.Stop()
+		s.whisper.Stop()
+	}
 	if s.ps != nil {
 		s.ps.Stop()
 	}
@@ -481,11 +675,16 @@ func (s *Swarm) Stop() error {
 	if s.storer != nil {
 		s.storer.Close()
 	}
+	if s.replicator != nil {
+		s.replicator.Close()
+	}
 
 	if s.netStore != nil {
 		s.netStore.Close()
 	}
 	s.sfs.Stop()
+	// the shared secrets of the node key are not needed once the api is stopped
+	api.ForgetSharedSecrets(s.privateKey)
 	stopCounter.Inc(1)
 
 	err := s.bzzEth.Stop()
@@ -518,6 +717,9 @@ func (s *Swarm) Protocols() (protos []p2p.Protocol) {
 		if s.ps != nil {
 			protos = append(protos, s.ps.Protocols()...)
 		}
+		if s.whisper != nil {
+			protos = append(protos, s.whisper.Protocols()...)
+		}
 
 		if s.swap != nil {
 			protos = append(protos, s.swap.Protocols()...)
@@ -534,7 +736,7 @@ func (s *Swarm) APIs() []rpc.API {
 		{
 			Namespace: "bzz",
 			Version:   "4.0",
-			Service:   &Info{s.config},
+			Service:   &Info{Config: s.config, swarm: s},
 			Public:    true,
 		},
 		// admin APIs
@@ -556,6 +758,12 @@ func (s *Swarm) APIs() []rpc.API {
 			Service:   protocols.NewAccountingApi(s.accountingMetrics),
 			Public:    false,
 		},
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   &protocols.MsgTapAPI{},
+			Public:    false,
+		},
 	}
 
 	apis = append(apis, s.bzz.APIs()...)
@@ -570,9 +778,34 @@ func (s *Swarm) APIs() []rpc.API {
 	if s.ps != nil {
 		apis = append(apis, s.ps.APIs()...)
 	}
+	if s.whisper != nil {
+		apis = append(apis, s.whisper.APIs()...)
+	}
 
 	if s.config.SwapEnabled {
 		apis = append(apis, s.swap.APIs()...)
+		apis = append(apis, s.retrieval.APIs()...)
+	}
+
+	apis = append(apis, rpc.API{
+		Namespace: "bzzconfig",
+		Version:   "1.0",
+		Service:   &ConfigAPI{s},
+		Public:    false,
+	}, rpc.API{
+		Namespace: "debug",
+		Version:   "1.0",
+		Service:   &LogAPI{s},
+		Public:    false,
+	})
+
+	if s.apiKeys != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "apikeys",
+			Version:   "1.0",
+			Service:   s.apiKeys,
+			Public:    false,
+		})
 	}
 
 	return apis
@@ -586,9 +819,13 @@ func (s *Swarm) RegisterPssProtocol(topic *pssmessage.Topic, spec *protocols.Spe
 // Info represents the current Swarm node's configuration
 type Info struct {
 	*api.Config
+	swarm *Swarm // node whose current config is returned, nil for a fixed config
 }
 
 // Info returns the current Swarm configuration
 func (i *Info) Info() *Info {
-	return i
+	if i.swarm == nil {
+		return i
+	}
+	return &Info{Config: i.swarm.Config()}
 }
@@ -31,8 +31,11 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv6"
 	"github.com/ethersphere/swarm/api"
 	"github.com/ethersphere/swarm/network"
+	"github.com/ethersphere/swarm/pss/bridge"
+	pssmessage "github.com/ethersphere/swarm/pss/message"
 	"github.com/ethersphere/swarm/sctx"
 	"github.com/ethersphere/swarm/testutil"
 )
@@ -133,6 +136,21 @@ func TestNewSwarm(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "pss bridge",
+			configure: func(config *api.Config) {
+				config.PssBridge.Routes = []bridge.Route{{
+					WhisperTopic:  whisper.BytesToTopic([]byte("foo")),
+					WhisperSymKey: make([]byte, 32),
+					PssTopic:      pssmessage.NewTopic([]byte("foo")),
+				}}
+			},
+			check: func(t *testing.T, s *Swarm, _ *api.Config) {
+				if s.bridge == nil || s.whisper == nil {
+					t.Error("pss bridge is not initialized")
+				}
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			config := api.NewConfig()